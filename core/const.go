@@ -21,4 +21,5 @@ var (
 	ErrRangeError                    = errors.New("range check error")
 	ErrNotSupported                  = errors.New("feature not currently supported")
 	ErrNotANumber                    = errors.New("not a number")
+	ErrNotLinearized                 = errors.New("file is not linearized")
 )
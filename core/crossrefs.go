@@ -66,113 +66,154 @@ type objectStreams map[int]objectStream
 // have already been parsed.
 type objectCache map[int]PdfObject
 
-// lookupObjectViaOS returns an object from an object stream.
-func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObject, error) {
-	var bufReader *bytes.Reader
-	var objstm objectStream
-	var cached bool
+// loadObjectStream loads the object stream `sobjNumber`, decoding the stream and parsing the
+// offsets of the contained objects. The result is cached.
+func (parser *PdfParser) loadObjectStream(sobjNumber int) (objectStream, error) {
+	if objstm, cached := parser.objstms[sobjNumber]; cached {
+		return objstm, nil
+	}
 
-	objstm, cached = parser.objstms[sobjNumber]
-	if !cached {
-		soi, err := parser.LookupByNumber(sobjNumber)
-		if err != nil {
-			common.Log.Debug("Missing object stream with number %d", sobjNumber)
-			return nil, err
-		}
+	soi, _, err := parser.lookupByNumberWrapper(sobjNumber, true)
+	if err != nil {
+		common.Log.Debug("Missing object stream with number %d", sobjNumber)
+		return objectStream{}, err
+	}
 
-		so, ok := soi.(*PdfObjectStream)
-		if !ok {
-			return nil, errors.New("invalid object stream")
-		}
+	so, ok := soi.(*PdfObjectStream)
+	if !ok {
+		return objectStream{}, errors.New("invalid object stream")
+	}
 
-		if parser.crypter != nil && !parser.crypter.isDecrypted(so) {
-			return nil, errors.New("need to decrypt the stream")
-		}
+	if parser.crypter != nil && !parser.crypter.isDecrypted(so) {
+		return objectStream{}, errors.New("need to decrypt the stream")
+	}
 
-		sod := so.PdfObjectDictionary
-		common.Log.Trace("so d: %s\n", sod.String())
-		name, ok := sod.Get("Type").(*PdfObjectName)
-		if !ok {
-			common.Log.Debug("ERROR: Object stream should always have a Type")
-			return nil, errors.New("object stream missing Type")
-		}
-		if strings.ToLower(string(*name)) != "objstm" {
-			common.Log.Debug("ERROR: Object stream type shall always be ObjStm !")
-			return nil, errors.New("object stream type != ObjStm")
-		}
+	sod := so.PdfObjectDictionary
+	common.Log.Trace("so d: %s\n", sod.String())
+	name, ok := sod.Get("Type").(*PdfObjectName)
+	if !ok {
+		common.Log.Debug("ERROR: Object stream should always have a Type")
+		return objectStream{}, errors.New("object stream missing Type")
+	}
+	if strings.ToLower(string(*name)) != "objstm" {
+		common.Log.Debug("ERROR: Object stream type shall always be ObjStm !")
+		return objectStream{}, errors.New("object stream type != ObjStm")
+	}
 
-		N, ok := sod.Get("N").(*PdfObjectInteger)
-		if !ok {
-			return nil, errors.New("invalid N in stream dictionary")
-		}
-		firstOffset, ok := sod.Get("First").(*PdfObjectInteger)
-		if !ok {
-			return nil, errors.New("invalid First in stream dictionary")
-		}
+	N, ok := sod.Get("N").(*PdfObjectInteger)
+	if !ok {
+		return objectStream{}, errors.New("invalid N in stream dictionary")
+	}
+	firstOffset, ok := sod.Get("First").(*PdfObjectInteger)
+	if !ok {
+		return objectStream{}, errors.New("invalid First in stream dictionary")
+	}
 
-		common.Log.Trace("type: %s number of objects: %d", name, *N)
-		ds, err := DecodeStream(so)
+	salvage := parser.repairOpts != nil && parser.repairOpts.SalvageObjectStreams
+	common.Log.Trace("type: %s number of objects: %d", name, *N)
+	parser.resolveStreamDict(sod)
+	ds, err := DecodeStream(so)
+	if err != nil {
+		if !salvage {
+			return objectStream{}, err
+		}
+		common.Log.Debug("ERROR: Failed decoding object stream %d: %v", sobjNumber, err)
+		ds, err = salvageFlateData(so)
 		if err != nil {
-			return nil, err
+			return objectStream{}, err
 		}
+		parser.repairReport.add(RepairObjectStream, sobjNumber, "damaged object stream, recovered %d bytes", len(ds))
+	}
 
-		common.Log.Trace("Decoded: %s", ds)
+	common.Log.Trace("Decoded: %s", ds)
 
-		// Temporarily change the reader object to this decoded buffer.
-		// Change back afterwards.
-		bakOffset := parser.GetFileOffset()
-		defer func() { parser.SetFileOffset(bakOffset) }()
+	// Temporarily change the reader object to this decoded buffer.
+	// Change back afterwards.
+	bakOffset := parser.GetFileOffset()
+	defer func() { parser.SetFileOffset(bakOffset) }()
 
-		bufReader = bytes.NewReader(ds)
-		parser.reader = bufio.NewReader(bufReader)
+	parser.reader = bufio.NewReader(bytes.NewReader(ds))
 
-		common.Log.Trace("Parsing offset map")
-		// Load the offset map (relative to the beginning of the stream...)
-		offsets := map[int]int64{}
-		// Object list and offsets.
-		for i := 0; i < int(*N); i++ {
-			parser.skipSpaces()
-			// Object number.
-			obj, err := parser.parseNumber()
-			if err != nil {
-				return nil, err
-			}
-			onum, ok := obj.(*PdfObjectInteger)
-			if !ok {
-				return nil, errors.New("invalid object stream offset table")
+	common.Log.Trace("Parsing offset map")
+	// Load the offset map (relative to the beginning of the stream...)
+	offsets := map[int]int64{}
+	lost := 0
+	// Object list and offsets.
+	for i := 0; i < int(*N); i++ {
+		onum, offset, err := parser.parseObjectStreamOffset()
+		if err != nil {
+			if !salvage {
+				return objectStream{}, err
 			}
+			parser.repairReport.add(RepairObjectStream, sobjNumber, "invalid offset table, recovered %d of %d objects", i, *N)
+			break
+		}
+		if salvage && int64(*firstOffset)+offset >= int64(len(ds)) {
+			// Truncated stream.
+			lost++
+			continue
+		}
 
-			parser.skipSpaces()
-			// Offset.
-			obj, err = parser.parseNumber()
-			if err != nil {
-				return nil, err
-			}
-			offset, ok := obj.(*PdfObjectInteger)
-			if !ok {
-				return nil, errors.New("invalid object stream offset table")
-			}
+		common.Log.Trace("obj %d offset %d", onum, offset)
+		offsets[onum] = int64(*firstOffset) + offset
+	}
 
-			common.Log.Trace("obj %d offset %d", *onum, *offset)
-			offsets[int(*onum)] = int64(*firstOffset + *offset)
-		}
+	if lost > 0 {
+		parser.repairReport.add(RepairObjectStream, sobjNumber, "truncated object stream, %d of %d objects lost", lost, *N)
+	}
 
-		objstm = objectStream{N: int(*N), ds: ds, offsets: offsets}
-		parser.objstms[sobjNumber] = objstm
-	} else {
-		// Temporarily change the reader object to this decoded buffer.
-		// Point back afterwards.
-		bakOffset := parser.GetFileOffset()
-		defer func() { parser.SetFileOffset(bakOffset) }()
+	objstm := objectStream{N: int(*N), ds: ds, offsets: offsets}
+	parser.objstms[sobjNumber] = objstm
+	return objstm, nil
+}
 
-		bufReader = bytes.NewReader(objstm.ds)
-		// Temporarily change the reader object to this decoded buffer.
-		parser.reader = bufio.NewReader(bufReader)
+// parseObjectStreamOffset parses an entry of the offset table of an object stream, returning the
+// object number and the offset of the object.
+func (parser *PdfParser) parseObjectStreamOffset() (int, int64, error) {
+	parser.skipSpaces()
+	// Object number.
+	obj, err := parser.parseNumber()
+	if err != nil {
+		return 0, 0, err
+	}
+	onum, ok := obj.(*PdfObjectInteger)
+	if !ok {
+		return 0, 0, errors.New("invalid object stream offset table")
+	}
+
+	parser.skipSpaces()
+	// Offset.
+	obj, err = parser.parseNumber()
+	if err != nil {
+		return 0, 0, err
+	}
+	offset, ok := obj.(*PdfObjectInteger)
+	if !ok {
+		return 0, 0, errors.New("invalid object stream offset table")
+	}
+	return int(*onum), int64(*offset), nil
+}
+
+// lookupObjectViaOS returns an object from an object stream.
+func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObject, error) {
+	objstm, err := parser.loadObjectStream(sobjNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	offset := objstm.offsets[objNum]
+	// Temporarily change the reader object to this decoded buffer.
+	// Point back afterwards.
+	bakOffset := parser.GetFileOffset()
+	defer func() { parser.SetFileOffset(bakOffset) }()
+
+	offset, ok := objstm.offsets[objNum]
+	if !ok && parser.repairOpts != nil {
+		common.Log.Debug("ERROR: object %d not found in object stream %d", objNum, sobjNumber)
+		return &PdfIndirectObject{PdfObjectReference: PdfObjectReference{ObjectNumber: int64(objNum)}, PdfObject: MakeNull()}, nil
+	}
 	common.Log.Trace("ACTUAL offset[%d] = %d", objNum, offset)
 
+	bufReader := bytes.NewReader(objstm.ds)
 	bufReader.Seek(offset, os.SEEK_SET)
 	parser.reader = bufio.NewReader(bufReader)
 
@@ -198,6 +239,9 @@ func (parser *PdfParser) lookupObjectViaOS(sobjNumber int, objNum int) (PdfObjec
 
 // LookupByNumber looks up a PdfObject by object number.  Returns an error on failure.
 func (parser *PdfParser) LookupByNumber(objNumber int) (PdfObject, error) {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	// Outside interface for lookupByNumberWrapper.  Default attempts repairs of bad xref tables.
 	obj, _, err := parser.lookupByNumberWrapper(objNumber, true)
 	return obj, err
@@ -213,6 +257,9 @@ func (parser *PdfParser) lookupByNumberWrapper(objNumber int, attemptRepairs boo
 	// If encrypted, decrypt it prior to returning.
 	// Do not attempt to decrypt objects within object streams.
 	if !inObjStream && parser.crypter != nil && !parser.crypter.isDecrypted(obj) {
+		if so, ok := obj.(*PdfObjectStream); ok {
+			parser.resolveStreamDict(so.PdfObjectDictionary)
+		}
 		err := parser.crypter.Decrypt(obj, 0, 0)
 		if err != nil {
 			return nil, inObjStream, err
@@ -273,7 +320,7 @@ func (parser *PdfParser) lookupByNumber(objNumber int, attemptRepairs bool) (Pdf
 					common.Log.Debug("ERROR Failed repair (%s)", err)
 					return nil, false, err
 				}
-				parser.xrefs = *xrefTable
+				parser.setRepairedXrefs(xrefTable)
 				return parser.lookupByNumber(objNumber, false)
 			}
 			return nil, false, err
@@ -340,6 +387,17 @@ func (parser *PdfParser) LookupByReference(ref PdfObjectReference) (PdfObject, e
 
 // Resolve resolves a PdfObject to direct object, looking up and resolving references as needed (unlike TraceToDirect).
 func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
+	if _, isRef := obj.(*PdfObjectReference); !isRef {
+		// Direct object already.
+		return obj, nil
+	}
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+	return parser.resolve(obj)
+}
+
+// resolve is Resolve for callers which hold the lookup lock.
+func (parser *PdfParser) resolve(obj PdfObject) (PdfObject, error) {
 	ref, isRef := obj.(*PdfObjectReference)
 	if !isRef {
 		// Direct object already.
@@ -349,7 +407,7 @@ func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
 	bakOffset := parser.GetFileOffset()
 	defer func() { parser.SetFileOffset(bakOffset) }()
 
-	o, err := parser.LookupByReference(*ref)
+	o, _, err := parser.lookupByNumberWrapper(int(ref.ObjectNumber), true)
 	if err != nil {
 		return nil, err
 	}
@@ -368,6 +426,47 @@ func (parser *PdfParser) Resolve(obj PdfObject) (PdfObject, error) {
 	return o, nil
 }
 
+// resolveStreamDict replaces the references of the entries of stream dictionary `dict` which are
+// needed for decoding and decrypting the stream by the referenced objects, including the
+// references nested in them (e.g. /DecodeParms << /Columns 5 0 R >>). This must be done while
+// holding the lookup lock, as the references could otherwise not be resolved (with
+// TraceToDirectObject) by the decoding and decryption code.
+func (parser *PdfParser) resolveStreamDict(dict *PdfObjectDictionary) {
+	for _, key := range []PdfObjectName{"Type", "Filter", "DecodeParms"} {
+		if obj := dict.Get(key); obj != nil {
+			dict.Set(key, parser.resolveNested(obj, 0))
+		}
+	}
+}
+
+// resolveNested returns `obj` with the references in it, and in the dictionaries and arrays it
+// contains, replaced by the referenced objects. Unresolvable references are left in place.
+func (parser *PdfParser) resolveNested(obj PdfObject, depth int) PdfObject {
+	if depth > traceMaxDepth {
+		common.Log.Debug("ERROR: Stream dictionary depth level beyond %d - not going deeper!", traceMaxDepth)
+		return obj
+	}
+	if _, isRef := obj.(*PdfObjectReference); isRef {
+		resolved, err := parser.resolve(obj)
+		if err != nil {
+			common.Log.Debug("ERROR: Failed to resolve stream dictionary entry: %v", err)
+			return obj
+		}
+		obj = resolved
+	}
+	switch t := obj.(type) {
+	case *PdfObjectDictionary:
+		for _, key := range t.Keys() {
+			t.Set(key, parser.resolveNested(t.Get(key), depth+1))
+		}
+	case *PdfObjectArray:
+		for i, elem := range t.Elements() {
+			t.Set(i, parser.resolveNested(elem, depth+1))
+		}
+	}
+	return obj
+}
+
 func printXrefTable(xrefTable XrefTable) {
 	common.Log.Debug("=X=X=X=")
 	common.Log.Debug("Xref table:")
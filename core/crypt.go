@@ -6,8 +6,10 @@
 package core
 
 import (
+	stdcrypto "crypto"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"time"
@@ -28,8 +30,31 @@ type EncryptInfo struct {
 	ID0, ID1 string
 }
 
+// CryptFilterIdentity is the name of the crypt filter that passes data unchanged.
+const CryptFilterIdentity = "Identity"
+
+// EncryptConfig contains optional parameters that control which parts of the document are encrypted.
+// It can only be used with crypt filters that support V>=4 (AESV2 and AESV3).
+type EncryptConfig struct {
+	// StreamFilter, StringFilter and EmbeddedFileFilter set crypt filters used by default for
+	// streams (StmF), strings (StrF) and embedded file streams (EFF). An empty value selects
+	// the document crypt filter and CryptFilterIdentity leaves the data unencrypted.
+	StreamFilter       string
+	StringFilter       string
+	EmbeddedFileFilter string
+
+	// PlainMetadata leaves the document-level metadata stream unencrypted (EncryptMetadata set to false).
+	PlainMetadata bool
+}
+
 // PdfCryptNewEncrypt makes the document crypt handler based on a specified crypt filter.
 func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions) (*PdfCrypt, *EncryptInfo, error) {
+	return PdfCryptNewEncryptConfig(cf, userPass, ownerPass, perm, nil)
+}
+
+// PdfCryptNewEncryptConfig makes the document crypt handler based on a specified crypt filter
+// and an optional encryption config.
+func PdfCryptNewEncryptConfig(cf crypto.Filter, userPass, ownerPass []byte, perm security.Permissions, cfg *EncryptConfig) (*PdfCrypt, *EncryptInfo, error) {
 	crypter := &PdfCrypt{
 		encryptedObjects: make(map[PdfObject]bool),
 		cryptFilters:     make(cryptFilters),
@@ -38,50 +63,75 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 			EncryptMetadata: true,
 		},
 	}
-	var vers Version
-	if cf != nil {
-		v := cf.PDFVersion()
-		vers.Major, vers.Minor = v[0], v[1]
+	crypter.encrypt.Filter = stdSecurityHandler
+	vers := crypter.setFilter(cf, stdCryptFilter)
+	if err := crypter.applyConfig(cfg, stdCryptFilter); err != nil {
+		return nil, nil, err
+	}
+	crypter.encryptStd.EncryptMetadata = crypter.encryptMetadata
+	ed := crypter.newEncryptDict()
 
-		V, R := cf.HandlerVersion()
-		crypter.encrypt.V = V
-		crypter.encryptStd.R = R
+	id0, id1 := generateIDs()
+	crypter.id0 = id0
 
-		crypter.encrypt.Length = cf.KeyLength() * 8
+	err := crypter.generateParams(userPass, ownerPass)
+	if err != nil {
+		return nil, nil, err
 	}
-	const (
-		defaultFilter = stdCryptFilter
-	)
-	crypter.cryptFilters[defaultFilter] = cf
+	// encode parameters generated by the Standard security handler
+	encodeEncryptStd(&crypter.encryptStd, ed)
 	if crypter.encrypt.V >= 4 {
-		crypter.streamFilter = defaultFilter
-		crypter.stringFilter = defaultFilter
+		if err := crypter.saveCryptFilters(ed); err != nil {
+			return nil, nil, err
+		}
 	}
-	ed := crypter.newEncryptDict()
 
-	// Prepare the ID object for the trailer.
-	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
-	id0 := string(hashcode[:])
-	b := make([]byte, 100)
-	rand.Read(b)
-	hashcode = md5.Sum(b)
-	id1 := string(hashcode[:])
-	common.Log.Trace("Random b: % x", b)
+	return crypter, &EncryptInfo{
+		Version: vers,
+		Encrypt: ed,
+		ID0:     id0, ID1: id1,
+	}, nil
+}
 
-	common.Log.Trace("Gen Id 0: % x", id0)
+// PdfCryptNewEncryptPubKey makes the document crypt handler for the public-key security handler.
+// The file encryption key is shared with all recipients using their certificates. Recipients
+// with the same permissions are stored in a single PKCS#7 object. The encryption config is optional.
+func PdfCryptNewEncryptPubKey(cf crypto.Filter, recipients []security.Recipient, cfg *EncryptConfig) (*PdfCrypt, *EncryptInfo, error) {
+	crypter := &PdfCrypt{
+		encryptedObjects: make(map[PdfObject]bool),
+		cryptFilters:     make(cryptFilters),
+	}
+	crypter.encrypt.Filter = pubKeySecurityHandler
+	vers := crypter.setFilter(cf, pubKeyCryptFilter)
+	if err := crypter.applyConfig(cfg, pubKeyCryptFilter); err != nil {
+		return nil, nil, err
+	}
+	crypter.encryptPubKey.EncryptMetadata = crypter.encryptMetadata
+	if crypter.encrypt.V >= 4 {
+		crypter.encrypt.SubFilter = security.SubFilterPKCS7S5
+	} else {
+		crypter.encrypt.SubFilter = security.SubFilterPKCS7S4
+	}
+	crypter.encryptPubKey.SubFilter = crypter.encrypt.SubFilter
+	ed := crypter.newEncryptDict()
 
-	crypter.id0 = string(id0)
+	id0, id1 := generateIDs()
+	crypter.id0 = id0
 
-	err := crypter.generateParams(userPass, ownerPass)
+	h := security.NewHandlerPubKey()
+	ekey, err := h.GenerateParams(&crypter.encryptPubKey, recipients, crypter.keyLength())
 	if err != nil {
 		return nil, nil, err
 	}
-	// encode parameters generated by the Standard security handler
-	encodeEncryptStd(&crypter.encryptStd, ed)
+	crypter.encryptionKey = ekey
+	crypter.authenticated = true
+
 	if crypter.encrypt.V >= 4 {
 		if err := crypter.saveCryptFilters(ed); err != nil {
 			return nil, nil, err
 		}
+	} else {
+		ed.Set("Recipients", encodeRecipients(crypter.encryptPubKey.Recipients))
 	}
 
 	return crypter, &EncryptInfo{
@@ -91,11 +141,101 @@ func PdfCryptNewEncrypt(cf crypto.Filter, userPass, ownerPass []byte, perm secur
 	}, nil
 }
 
+// setFilter sets the default crypt filter and the handler version.
+// It returns the minimal PDF version required by the filter.
+func (crypt *PdfCrypt) setFilter(cf crypto.Filter, name string) Version {
+	var vers Version
+	if cf != nil {
+		v := cf.PDFVersion()
+		vers.Major, vers.Minor = v[0], v[1]
+
+		V, R := cf.HandlerVersion()
+		crypt.encrypt.V = V
+		crypt.encryptStd.R = R
+
+		crypt.encrypt.Length = cf.KeyLength() * 8
+	}
+	if crypt.encrypt.V < 4 {
+		// Legacy filters always use the standard name.
+		name = stdCryptFilter
+	}
+	crypt.cryptFilters[name] = cf
+	if crypt.encrypt.V >= 4 {
+		crypt.streamFilter = name
+		crypt.stringFilter = name
+	}
+	crypt.encryptMetadata = true
+	return vers
+}
+
+// applyConfig sets default crypt filters for streams, strings and embedded files
+// according to the encryption config. The name is a name of the document crypt filter.
+func (crypt *PdfCrypt) applyConfig(cfg *EncryptConfig, name string) error {
+	if cfg == nil {
+		return nil
+	}
+	if crypt.encrypt.V < 4 {
+		return errors.New("encryption config requires crypt filters (V>=4)")
+	}
+	crypt.cryptFilters[CryptFilterIdentity] = crypto.NewIdentity()
+
+	choose := func(field, filter string) (string, error) {
+		switch filter {
+		case "":
+			return name, nil
+		case name, CryptFilterIdentity:
+			return filter, nil
+		}
+		return "", fmt.Errorf("unknown crypt filter for %s: %q", field, filter)
+	}
+	var err error
+	if crypt.streamFilter, err = choose("StmF", cfg.StreamFilter); err != nil {
+		return err
+	}
+	if crypt.stringFilter, err = choose("StrF", cfg.StringFilter); err != nil {
+		return err
+	}
+	if crypt.embeddedFileFilter, err = choose("EFF", cfg.EmbeddedFileFilter); err != nil {
+		return err
+	}
+	crypt.encryptMetadata = !cfg.PlainMetadata
+
+	if crypt.streamFilter == CryptFilterIdentity && crypt.stringFilter == CryptFilterIdentity &&
+		crypt.embeddedFileFilter == name {
+		// Only embedded files are encrypted, so the document can be opened without authentication.
+		crypt.authEvent = security.EventEFOpen
+	}
+	return nil
+}
+
+// isReadableWithoutAuth returns whether the objects of the document can be read without
+// authentication, its strings and streams not being encrypted (Identity crypt filters) but
+// its embedded files and the streams with a Crypt filter.
+func (crypt *PdfCrypt) isReadableWithoutAuth() bool {
+	return crypt.encrypt.V >= 4 && crypt.streamFilter == CryptFilterIdentity &&
+		crypt.stringFilter == CryptFilterIdentity
+}
+
+// generateIDs prepares the ID pair for the trailer.
+func generateIDs() (id0, id1 string) {
+	hashcode := md5.Sum([]byte(time.Now().Format(time.RFC850)))
+	id0 = string(hashcode[:])
+	b := make([]byte, 100)
+	rand.Read(b)
+	hashcode = md5.Sum(b)
+	id1 = string(hashcode[:])
+	common.Log.Trace("Random b: % x", b)
+
+	common.Log.Trace("Gen Id 0: % x", id0)
+	return id0, id1
+}
+
 // PdfCrypt provides PDF encryption/decryption support.
 // The PDF standard supports encryption of strings and streams (Section 7.6).
 type PdfCrypt struct {
-	encrypt    encryptDict
-	encryptStd security.StdEncryptDict
+	encrypt       encryptDict
+	encryptStd    security.StdEncryptDict
+	encryptPubKey security.PubKeyEncryptDict
 
 	id0              string
 	encryptionKey    []byte
@@ -103,9 +243,12 @@ type PdfCrypt struct {
 	encryptedObjects map[PdfObject]bool
 	authenticated    bool
 	// Crypt filters (V4).
-	cryptFilters cryptFilters
-	streamFilter string
-	stringFilter string
+	cryptFilters       cryptFilters
+	streamFilter       string
+	stringFilter       string
+	embeddedFileFilter string // EFF; defaults to the stream filter when empty
+	authEvent          security.AuthEvent
+	encryptMetadata    bool
 
 	parser *PdfParser
 
@@ -119,10 +262,12 @@ func encodeEncryptStd(d *security.StdEncryptDict, ed *PdfObjectDictionary) {
 
 	ed.Set("O", MakeStringFromBytes(d.O))
 	ed.Set("U", MakeStringFromBytes(d.U))
+	if d.R >= 4 {
+		ed.Set("EncryptMetadata", MakeBool(d.EncryptMetadata))
+	}
 	if d.R >= 5 {
 		ed.Set("OE", MakeStringFromBytes(d.OE))
 		ed.Set("UE", MakeStringFromBytes(d.UE))
-		ed.Set("EncryptMetadata", MakeBool(d.EncryptMetadata))
 		if d.R > 5 {
 			ed.Set("Perms", MakeStringFromBytes(d.Perms))
 		}
@@ -246,7 +391,10 @@ func decodeCryptFilter(cf *crypto.FilterDict, d *PdfObjectDictionary) error {
 func (crypt *PdfCrypt) newEncryptDict() *PdfObjectDictionary {
 	// Generate the encryption dictionary.
 	ed := MakeDict()
-	ed.Set("Filter", MakeName("Standard"))
+	ed.Set("Filter", MakeName(crypt.encrypt.Filter))
+	if crypt.encrypt.SubFilter != "" {
+		ed.Set("SubFilter", MakeName(crypt.encrypt.SubFilter))
+	}
 	ed.Set("V", MakeInteger(int64(crypt.encrypt.V)))
 	ed.Set("Length", MakeInteger(int64(crypt.encrypt.Length)))
 	return ed
@@ -297,8 +445,77 @@ type encryptDict struct {
 	CF map[string]crypto.FilterDict // Crypt filters dictionary.
 }
 
-// stdCryptFilter is a default name for a standard crypt filter.
-const stdCryptFilter = "StdCF"
+const (
+	// stdCryptFilter is a default name for a standard crypt filter.
+	stdCryptFilter = "StdCF"
+	// pubKeyCryptFilter is a default name for a crypt filter of the public-key security handler.
+	pubKeyCryptFilter = "DefaultCryptFilter"
+)
+
+// Names of supported security handlers.
+const (
+	stdSecurityHandler    = "Standard"
+	pubKeySecurityHandler = "Adobe.PubSec"
+)
+
+// isPubKey checks if the document uses the public-key security handler.
+func (crypt *PdfCrypt) isPubKey() bool {
+	return crypt.encrypt.Filter == pubKeySecurityHandler
+}
+
+// keyLength returns the length of the file encryption key in bytes.
+func (crypt *PdfCrypt) keyLength() int {
+	if crypt.encrypt.V >= 4 {
+		if f, ok := crypt.cryptFilters[crypt.streamFilter]; ok && f.KeyLength() > 0 {
+			return f.KeyLength()
+		}
+	}
+	return crypt.encrypt.Length / 8
+}
+
+// encodeRecipients encodes PKCS#7 objects of the public-key security handler to a Recipients array.
+func encodeRecipients(recipients [][]byte) *PdfObjectArray {
+	arr := MakeArray()
+	for _, r := range recipients {
+		arr.Append(MakeHexString(string(r)))
+	}
+	return arr
+}
+
+// decodeRecipients decodes PKCS#7 objects of the public-key security handler from a Recipients entry.
+func (crypt *PdfCrypt) decodeRecipients(obj PdfObject) ([][]byte, error) {
+	obj = crypt.resolve(obj)
+	if s, ok := obj.(*PdfObjectString); ok {
+		// A single recipient is allowed to be stored as a string.
+		return [][]byte{s.Bytes()}, nil
+	}
+	arr, ok := obj.(*PdfObjectArray)
+	if !ok {
+		return nil, fmt.Errorf("invalid Recipients entry: %T", obj)
+	}
+	var recipients [][]byte
+	for _, o := range arr.Elements() {
+		s, ok := crypt.resolve(o).(*PdfObjectString)
+		if !ok {
+			return nil, fmt.Errorf("invalid recipient: %T", o)
+		}
+		recipients = append(recipients, s.Bytes())
+	}
+	return recipients, nil
+}
+
+// resolve resolves a reference to a direct object, if the parser is available.
+func (crypt *PdfCrypt) resolve(obj PdfObject) PdfObject {
+	if ref, isRef := obj.(*PdfObjectReference); isRef && crypt.parser != nil {
+		o, err := crypt.parser.LookupByReference(*ref)
+		if err != nil {
+			common.Log.Debug("Error looking up reference: %v", err)
+			return nil
+		}
+		obj = o
+	}
+	return TraceToDirectObject(obj)
+}
 
 func newCryptFiltersV2(length int) cryptFilters {
 	return cryptFilters{
@@ -356,6 +573,11 @@ func (crypt *PdfCrypt) loadCryptFilters(ed *PdfObjectDictionary) error {
 		if err := decodeCryptFilter(&cfd, dict); err != nil {
 			return err
 		}
+		if crypt.isPubKey() && len(crypt.encryptPubKey.Recipients) == 0 {
+			if err := crypt.decodePubKeyFilter(dict); err != nil {
+				return err
+			}
+		}
 		cf, err := crypto.NewFilter(cfd)
 		if err != nil {
 			return err
@@ -383,6 +605,15 @@ func (crypt *PdfCrypt) loadCryptFilters(ed *PdfObjectDictionary) error {
 		crypt.streamFilter = string(*stmf)
 	}
 
+	// EFF embedded files filter. Defaults to StmF.
+	crypt.embeddedFileFilter = ""
+	if eff, ok := ed.Get("EFF").(*PdfObjectName); ok {
+		if _, exists := crypt.cryptFilters[string(*eff)]; !exists {
+			return fmt.Errorf("crypt filter for EFF not specified in CF dictionary (%s)", *eff)
+		}
+		crypt.embeddedFileFilter = string(*eff)
+	}
+
 	return nil
 }
 
@@ -410,11 +641,18 @@ func (crypt *PdfCrypt) saveCryptFilters(ed *PdfObjectDictionary) error {
 		if name == "Identity" {
 			continue
 		}
-		v := encodeCryptFilter(filter, "")
+		v := encodeCryptFilter(filter, crypt.authEvent)
+		if crypt.isPubKey() {
+			v.Set("Recipients", encodeRecipients(crypt.encryptPubKey.Recipients))
+			v.Set("EncryptMetadata", MakeBool(crypt.encryptPubKey.EncryptMetadata))
+		}
 		cf.Set(PdfObjectName(name), v)
 	}
 	ed.Set("StrF", MakeName(crypt.stringFilter))
 	ed.Set("StmF", MakeName(crypt.streamFilter))
+	if crypt.embeddedFileFilter != "" {
+		ed.Set("EFF", MakeName(crypt.embeddedFileFilter))
+	}
 	return nil
 }
 
@@ -434,16 +672,22 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 		common.Log.Debug("ERROR Crypt dictionary missing required Filter field!")
 		return crypter, errors.New("required crypt field Filter missing")
 	}
-	if *filter != "Standard" {
+	if *filter != stdSecurityHandler && *filter != pubKeySecurityHandler {
 		common.Log.Debug("ERROR Unsupported filter (%s)", *filter)
 		return crypter, errors.New("unsupported Filter")
 	}
 	crypter.encrypt.Filter = string(*filter)
+	crypter.encryptPubKey.EncryptMetadata = true // True by default.
 
-	if subfilter, ok := ed.Get("SubFilter").(*PdfObjectString); ok {
+	switch subfilter := ed.Get("SubFilter").(type) {
+	case *PdfObjectName:
+		crypter.encrypt.SubFilter = string(*subfilter)
+		common.Log.Debug("Using subfilter %s", subfilter)
+	case *PdfObjectString:
 		crypter.encrypt.SubFilter = subfilter.Str()
 		common.Log.Debug("Using subfilter %s", subfilter)
 	}
+	crypter.encryptPubKey.SubFilter = crypter.encrypt.SubFilter
 
 	if L, ok := ed.Get("Length").(*PdfObjectInteger); ok {
 		if (*L % 8) != 0 {
@@ -472,9 +716,23 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 		}
 	}
 
-	// decode Standard security handler parameters
-	if err := decodeEncryptStd(&crypter.encryptStd, ed); err != nil {
-		return crypter, err
+	if crypter.isPubKey() {
+		// decode public-key security handler parameters
+		if len(crypter.encryptPubKey.Recipients) == 0 {
+			if err := crypter.decodePubKeyFilter(ed); err != nil {
+				return crypter, err
+			}
+		}
+		if len(crypter.encryptPubKey.Recipients) == 0 {
+			return crypter, errors.New("encrypt dictionary missing Recipients")
+		}
+		crypter.encryptMetadata = crypter.encryptPubKey.EncryptMetadata
+	} else {
+		// decode Standard security handler parameters
+		if err := decodeEncryptStd(&crypter.encryptStd, ed); err != nil {
+			return crypter, err
+		}
+		crypter.encryptMetadata = crypter.encryptStd.EncryptMetadata
 	}
 
 	// Default: empty ID.
@@ -495,8 +753,30 @@ func PdfCryptNewDecrypt(parser *PdfParser, ed, trailer *PdfObjectDictionary) (*P
 	return crypter, nil
 }
 
+// decodePubKeyFilter decodes Recipients and EncryptMetadata fields of the public-key security handler
+// from an Encrypt dictionary (adbe.pkcs7.s4) or a crypt filter dictionary (adbe.pkcs7.s5).
+func (crypt *PdfCrypt) decodePubKeyFilter(d *PdfObjectDictionary) error {
+	obj := d.Get("Recipients")
+	if obj == nil {
+		return nil
+	}
+	recipients, err := crypt.decodeRecipients(obj)
+	if err != nil {
+		return err
+	}
+	crypt.encryptPubKey.Recipients = recipients
+	if em, ok := d.Get("EncryptMetadata").(*PdfObjectBool); ok {
+		crypt.encryptPubKey.EncryptMetadata = bool(*em)
+	}
+	return nil
+}
+
 // GetAccessPermissions returns the PDF access permissions as an AccessPermissions object.
+// For the public-key security handler, permissions of the authenticated recipient are returned.
 func (crypt *PdfCrypt) GetAccessPermissions() security.Permissions {
+	if crypt.isPubKey() {
+		return crypt.encryptPubKey.P
+	}
 	return crypt.encryptStd.P
 }
 
@@ -511,6 +791,10 @@ func (crypt *PdfCrypt) securityHandler() security.StdHandler {
 // Also build the encryption/decryption key.
 func (crypt *PdfCrypt) authenticate(password []byte) (bool, error) {
 	crypt.authenticated = false
+	if crypt.isPubKey() {
+		// Passwords cannot be used with the public-key security handler.
+		return false, nil
+	}
 	h := crypt.securityHandler()
 	fkey, perm, err := h.Authenticate(&crypt.encryptStd, password)
 	if err != nil {
@@ -523,6 +807,26 @@ func (crypt *PdfCrypt) authenticate(password []byte) (bool, error) {
 	return true, nil
 }
 
+// Check whether the specified recipient certificate and private key can be used to decrypt
+// the document protected by the public-key security handler. Also build the encryption/decryption key.
+func (crypt *PdfCrypt) authenticatePubKey(cert *x509.Certificate, pkey stdcrypto.PrivateKey) (bool, error) {
+	crypt.authenticated = false
+	if !crypt.isPubKey() {
+		return false, fmt.Errorf("unsupported security handler: %s", crypt.encrypt.Filter)
+	}
+	h := security.NewHandlerPubKey()
+	fkey, perm, err := h.Authenticate(&crypt.encryptPubKey, crypt.keyLength(), cert, pkey)
+	if err != nil {
+		return false, err
+	} else if len(fkey) == 0 {
+		return false, nil
+	}
+	crypt.authenticated = true
+	crypt.encryptionKey = fkey
+	crypt.encryptPubKey.P = perm
+	return true, nil
+}
+
 // Check access rights and permissions for a specified password.  If either user/owner password is specified,
 // full rights are granted, otherwise the access rights are specified by the Permissions flag.
 //
@@ -530,6 +834,9 @@ func (crypt *PdfCrypt) authenticate(password []byte) (bool, error) {
 // The AccessPermissions shows what access the user has for editing etc.
 // An error is returned if there was a problem performing the authentication.
 func (crypt *PdfCrypt) checkAccessRights(password []byte) (bool, security.Permissions, error) {
+	if crypt.isPubKey() {
+		return false, 0, nil
+	}
 	h := crypt.securityHandler()
 	// TODO(dennwc): it computes an encryption key as well; if necessary, define a new interface method to optimize this
 	fkey, perm, err := h.Authenticate(&crypt.encryptStd, password)
@@ -596,6 +903,105 @@ func (crypt *PdfCrypt) isDecrypted(obj PdfObject) bool {
 	return false
 }
 
+// streamFilterFor returns the name of the crypt filter that applies to a stream with the specified dictionary.
+// The filter can be overridden by the Crypt filter (7.4.10), which shall be the first entry of the Filter array.
+// Embedded file streams use the EFF filter, and the document-level metadata stream is not encrypted if
+// EncryptMetadata is false.
+func (crypt *PdfCrypt) streamFilterFor(dict *PdfObjectDictionary) string {
+	if crypt.encrypt.V < 4 {
+		return stdCryptFilter // Default RC4.
+	}
+	if name, ok := cryptFilterName(dict); ok {
+		if _, ok := crypt.cryptFilters[name]; ok {
+			common.Log.Trace("Using stream filter %s", name)
+			return name
+		}
+		// Default option of the Crypt filter is Identity.
+		return CryptFilterIdentity
+	}
+	if typ, ok := GetName(dict.Get("Type")); ok {
+		switch *typ {
+		case "EmbeddedFile":
+			if crypt.embeddedFileFilter != "" {
+				return crypt.embeddedFileFilter
+			}
+		case "Metadata":
+			if !crypt.encryptMetadata {
+				return CryptFilterIdentity
+			}
+		}
+	}
+	return crypt.streamFilter
+}
+
+// cryptFilterName checks if the first filter of the stream is the Crypt filter and returns
+// the name of the crypt filter from the corresponding decode parameters.
+func cryptFilterName(dict *PdfObjectDictionary) (string, bool) {
+	var params PdfObject
+	switch filter := TraceToDirectObject(dict.Get("Filter")).(type) {
+	case *PdfObjectName:
+		if *filter != StreamEncodingFilterNameCrypt {
+			return "", false
+		}
+		params = dict.Get("DecodeParms")
+	case *PdfObjectArray:
+		// Crypt filter can only be the first entry.
+		if name, ok := GetName(filter.Get(0)); !ok || *name != StreamEncodingFilterNameCrypt {
+			return "", false
+		}
+		params = dict.Get("DecodeParms")
+		if arr, ok := GetArray(params); ok {
+			params = arr.Get(0)
+		}
+	default:
+		return "", false
+	}
+	if decodeParams, ok := GetDict(params); ok {
+		if name, ok := GetName(decodeParams.Get("Name")); ok {
+			return string(*name), true
+		}
+	}
+	return CryptFilterIdentity, true
+}
+
+// decryptStreamData decrypts the data of the stream `obj` with the crypt filter `filter`.
+func (crypt *PdfCrypt) decryptStreamData(obj *PdfObjectStream, filter string) error {
+	okey, err := crypt.makeKey(filter, uint32(obj.ObjectNumber), uint32(obj.GenerationNumber), crypt.encryptionKey)
+	if err != nil {
+		return err
+	}
+
+	obj.Stream, err = crypt.decryptBytes(obj.Stream, filter, okey)
+	if err != nil {
+		return err
+	}
+	// Update the length based on the decrypted stream.
+	obj.Set("Length", MakeInteger(int64(len(obj.Stream))))
+	return nil
+}
+
+// decryptDeferred decrypts the stream `obj` which was left encrypted when loaded before
+// authentication (see isReadableWithoutAuth). An error is returned if the document has not been
+// decrypted since.
+func (crypt *PdfCrypt) decryptDeferred(obj *PdfObjectStream) error {
+	if crypt.parser != nil {
+		crypt.parser.mu.Lock()
+		defer crypt.parser.mu.Unlock()
+	}
+	if obj.crypter == nil {
+		// Decrypted meanwhile.
+		return nil
+	}
+	if !crypt.authenticated {
+		return errors.New("stream is encrypted, the document needs to be decrypted first")
+	}
+	if err := crypt.decryptStreamData(obj, crypt.streamFilterFor(obj.PdfObjectDictionary)); err != nil {
+		return err
+	}
+	obj.crypter = nil
+	return nil
+}
+
 // Decrypt a buffer with a selected crypt filter.
 func (crypt *PdfCrypt) decryptBytes(buf []byte, filter string, okey []byte) ([]byte, error) {
 	common.Log.Trace("Decrypt bytes")
@@ -646,60 +1052,24 @@ func (crypt *PdfCrypt) Decrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 		genNum := obj.GenerationNumber
 		common.Log.Trace("Decrypting stream %d %d !", objNum, genNum)
 
-		// TODO: Check for crypt filter (V4).
-		// The Crypt filter shall be the first filter in the Filter array entry.
-
-		streamFilter := stdCryptFilter // Default RC4.
-		if crypt.encrypt.V >= 4 {
-			streamFilter = crypt.streamFilter
-			common.Log.Trace("this.streamFilter = %s", crypt.streamFilter)
-
-			if filters, ok := dict.Get("Filter").(*PdfObjectArray); ok {
-				// Crypt filter can only be the first entry.
-				if firstFilter, ok := GetName(filters.Get(0)); ok {
-					if *firstFilter == "Crypt" {
-						// Crypt filter overriding the default.
-						// Default option is Identity.
-						streamFilter = "Identity"
-
-						// Check if valid crypt filter specified in the decode params.
-						if decodeParams, ok := dict.Get("DecodeParms").(*PdfObjectDictionary); ok {
-							if filterName, ok := decodeParams.Get("Name").(*PdfObjectName); ok {
-								if _, ok := crypt.cryptFilters[string(*filterName)]; ok {
-									common.Log.Trace("Using stream filter %s", *filterName)
-									streamFilter = string(*filterName)
-								}
-							}
-						}
-					}
-				}
-			}
-
-			common.Log.Trace("with %s filter", streamFilter)
-			if streamFilter == "Identity" {
-				// Identity: pass unchanged.
-				return nil
-			}
-		}
-
-		err := crypt.Decrypt(dict, objNum, genNum)
-		if err != nil {
-			return err
+		streamFilter := crypt.streamFilterFor(dict)
+		common.Log.Trace("with %s filter", streamFilter)
+		if streamFilter == CryptFilterIdentity {
+			// Identity: pass unchanged.
+			return nil
 		}
-
-		okey, err := crypt.makeKey(streamFilter, uint32(objNum), uint32(genNum), crypt.encryptionKey)
-		if err != nil {
-			return err
+		if !crypt.authenticated && crypt.isReadableWithoutAuth() {
+			// Embedded file of a document read without authentication: the key is only required
+			// when decoding it.
+			obj.crypter = crypt
+			return nil
 		}
 
-		obj.Stream, err = crypt.decryptBytes(obj.Stream, streamFilter, okey)
+		err := crypt.Decrypt(dict, objNum, genNum)
 		if err != nil {
 			return err
 		}
-		// Update the length based on the decrypted stream.
-		dict.Set("Length", MakeInteger(int64(len(obj.Stream))))
-
-		return nil
+		return crypt.decryptStreamData(obj, streamFilter)
 	case *PdfObjectString:
 		common.Log.Trace("Decrypting string!")
 
@@ -829,42 +1199,11 @@ func (crypt *PdfCrypt) Encrypt(obj PdfObject, parentObjNum, parentGenNum int64)
 		genNum := obj.GenerationNumber
 		common.Log.Trace("Encrypting stream %d %d !", objNum, genNum)
 
-		// TODO: Check for crypt filter (V4).
-		// The Crypt filter shall be the first filter in the Filter array entry.
-
-		streamFilter := stdCryptFilter // Default RC4.
-		if crypt.encrypt.V >= 4 {
-			// For now.  Need to change when we add support for more than
-			// Identity / RC4.
-			streamFilter = crypt.streamFilter
-			common.Log.Trace("this.streamFilter = %s", crypt.streamFilter)
-
-			if filters, ok := dict.Get("Filter").(*PdfObjectArray); ok {
-				// Crypt filter can only be the first entry.
-				if firstFilter, ok := GetName(filters.Get(0)); ok {
-					if *firstFilter == "Crypt" {
-						// Crypt filter overriding the default.
-						// Default option is Identity.
-						streamFilter = "Identity"
-
-						// Check if valid crypt filter specified in the decode params.
-						if decodeParams, ok := dict.Get("DecodeParms").(*PdfObjectDictionary); ok {
-							if filterName, ok := decodeParams.Get("Name").(*PdfObjectName); ok {
-								if _, ok := crypt.cryptFilters[string(*filterName)]; ok {
-									common.Log.Trace("Using stream filter %s", *filterName)
-									streamFilter = string(*filterName)
-								}
-							}
-						}
-					}
-				}
-			}
-
-			common.Log.Trace("with %s filter", streamFilter)
-			if streamFilter == "Identity" {
-				// Identity: pass unchanged.
-				return nil
-			}
+		streamFilter := crypt.streamFilterFor(dict)
+		common.Log.Trace("with %s filter", streamFilter)
+		if streamFilter == CryptFilterIdentity {
+			// Identity: pass unchanged.
+			return nil
 		}
 
 		err := crypt.Encrypt(obj.PdfObjectDictionary, objNum, genNum)
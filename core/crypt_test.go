@@ -12,6 +12,7 @@ import (
 
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core/security"
+	crypto "github.com/carmel/unipdf/core/security/crypt"
 )
 
 func init() {
@@ -95,3 +96,108 @@ func TestDecryption1(t *testing.T) {
 		return
 	}
 }
+
+// Test encryption with default crypt filters for streams, strings and embedded files, and per-stream Crypt filters.
+func TestEncryptConfig(t *testing.T) {
+	const plain = "BT (Hello) Tj ET"
+
+	newStream := func(num int64, typ string, crypt bool) *PdfObjectStream {
+		stream := &PdfObjectStream{PdfObjectDictionary: MakeDict(), Stream: []byte(plain)}
+		stream.ObjectNumber = num
+		if typ != "" {
+			stream.Set("Type", MakeName(typ))
+		}
+		if crypt {
+			stream.Set("Filter", MakeArray(MakeName(StreamEncodingFilterNameCrypt)))
+			params := MakeDict()
+			params.Set("Name", MakeName(CryptFilterIdentity))
+			stream.Set("DecodeParms", MakeArray(params))
+		}
+		return stream
+	}
+
+	cases := []struct {
+		name      string
+		cfg       *EncryptConfig
+		encrypted map[string]bool // by stream kind
+	}{
+		{
+			name:      "default",
+			encrypted: map[string]bool{"content": true, "Metadata": true, "EmbeddedFile": true, "Crypt": false, "string": true},
+		},
+		{
+			name:      "plain metadata",
+			cfg:       &EncryptConfig{PlainMetadata: true},
+			encrypted: map[string]bool{"content": true, "Metadata": false, "EmbeddedFile": true, "Crypt": false, "string": true},
+		},
+		{
+			name: "embedded files only",
+			cfg: &EncryptConfig{
+				StreamFilter: CryptFilterIdentity,
+				StringFilter: CryptFilterIdentity,
+			},
+			encrypted: map[string]bool{"content": false, "Metadata": false, "EmbeddedFile": true, "Crypt": false, "string": false},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			crypter, info, err := PdfCryptNewEncryptConfig(crypto.NewFilterAESV3(), []byte("user"), []byte("owner"), security.PermOwner, c.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			streams := map[string]*PdfObjectStream{
+				"content":      newStream(1, "", false),
+				"Metadata":     newStream(2, "Metadata", false),
+				"EmbeddedFile": newStream(3, "EmbeddedFile", false),
+				"Crypt":        newStream(4, "", true),
+			}
+			str := MakeString(plain)
+			strObj := MakeIndirectObject(MakeArray(str))
+			strObj.ObjectNumber = 5
+
+			for _, s := range streams {
+				if err := crypter.Encrypt(s, 0, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := crypter.Encrypt(strObj, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			for kind, s := range streams {
+				if enc := string(s.Stream) != plain; enc != c.encrypted[kind] {
+					t.Fatalf("%s: expected encrypted=%v", kind, c.encrypted[kind])
+				}
+			}
+			if enc := str.Str() != plain; enc != c.encrypted["string"] {
+				t.Fatalf("string: expected encrypted=%v", c.encrypted["string"])
+			}
+
+			// Decrypt using the generated encryption dictionary.
+			trailer := MakeDict()
+			trailer.Set("ID", MakeArray(MakeHexString(info.ID0), MakeHexString(info.ID1)))
+			decrypter, err := PdfCryptNewDecrypt(nil, info.Encrypt, trailer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := decrypter.authenticate([]byte("user")); err != nil || !ok {
+				t.Fatalf("failed to authenticate: %v", err)
+			}
+			for kind, s := range streams {
+				if err := decrypter.Decrypt(s, 0, 0); err != nil {
+					t.Fatal(err)
+				}
+				if string(s.Stream) != plain {
+					t.Fatalf("%s: wrong decrypted data", kind)
+				}
+			}
+			if err := decrypter.Decrypt(strObj, 0, 0); err != nil {
+				t.Fatal(err)
+			}
+			if str.Str() != plain {
+				t.Fatal("string: wrong decrypted data")
+			}
+		})
+	}
+}
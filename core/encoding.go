@@ -50,6 +50,7 @@ const (
 	StreamEncodingFilterNameJBIG2     = "JBIG2Decode"
 	StreamEncodingFilterNameJPX       = "JPXDecode"
 	StreamEncodingFilterNameRaw       = "Raw"
+	StreamEncodingFilterNameCrypt     = "Crypt"
 )
 
 const (
@@ -2148,6 +2149,9 @@ func newMultiEncoderFromStream(streamObj *PdfObjectStream) (*MultiEncoder, error
 			mencoder.AddEncoder(encoder)
 			common.Log.Trace("Added DCT encoder...")
 			common.Log.Trace("Multi encoder: %#v", mencoder)
+		} else if *name == StreamEncodingFilterNameCrypt {
+			// The data is decrypted by the security handler when the stream is loaded.
+			common.Log.Trace("Skipping Crypt filter")
 		} else {
 			common.Log.Error("Unsupported filter %s", *name)
 			return nil, fmt.Errorf("invalid filter in multi filter array")
@@ -0,0 +1,434 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/carmel/unipdf/common"
+)
+
+// Linearization contains the linearization parameters of a linearized ("fast web view") PDF
+// file, as specified in the linearization parameter dictionary (ISO 32000-1 Annex F).
+type Linearization struct {
+	// Length is the length of the entire file in bytes (L).
+	Length int64
+	// HintOffset and HintLength locate the primary hint stream (H).
+	HintOffset int64
+	HintLength int64
+	// OverflowHintOffset and OverflowHintLength locate the overflow hint stream, if any.
+	OverflowHintOffset int64
+	OverflowHintLength int64
+	// FirstPageObject is the object number of the page object of the first page (O).
+	FirstPageObject int64
+	// FirstPageEnd is the offset of the end of the first page section (E). Reading the file up to
+	// this offset is sufficient to display the first page.
+	FirstPageEnd int64
+	// NumPages is the number of pages in the document (N).
+	NumPages int
+	// MainXrefOffset is the offset of the white-space character preceding the first entry of the
+	// main cross-reference table (T).
+	MainXrefOffset int64
+	// FirstPage is the index of the first page section page (P), 0 unless specified.
+	FirstPage int
+}
+
+// LinearizedPage locates the objects of a page section of a linearized file.
+type LinearizedPage struct {
+	// Offset and Length locate the page section, starting with the page object.
+	Offset int64
+	Length int64
+	// NumObjects is the number of objects in the page section.
+	NumObjects int
+	// SharedGroups are the indices of the shared object groups referenced by the page, in
+	// LinearizationHints.SharedGroups.
+	SharedGroups []int
+}
+
+// LinearizedObjectGroup locates a group of consecutive shared objects of a linearized file.
+type LinearizedObjectGroup struct {
+	Offset     int64
+	Length     int64
+	NumObjects int
+}
+
+// LinearizationHints contains the page offset and shared object hint tables of a linearized
+// file. All offsets are actual file offsets.
+type LinearizationHints struct {
+	Pages        []LinearizedPage
+	SharedGroups []LinearizedObjectGroup
+}
+
+// newLinearization loads linearization parameters from linearization parameter dictionary `dict`.
+func newLinearization(dict *PdfObjectDictionary) (*Linearization, error) {
+	if _, err := GetNumberAsFloat(TraceToDirectObject(dict.Get("Linearized"))); err != nil {
+		return nil, ErrNotLinearized
+	}
+
+	lin := &Linearization{}
+	ints := []struct {
+		key PdfObjectName
+		val *int64
+	}{
+		{"L", &lin.Length},
+		{"O", &lin.FirstPageObject},
+		{"E", &lin.FirstPageEnd},
+		{"T", &lin.MainXrefOffset},
+	}
+	for _, e := range ints {
+		v, ok := GetIntVal(dict.Get(e.key))
+		if !ok {
+			return nil, fmt.Errorf("invalid linearization dictionary: missing %s", e.key)
+		}
+		*e.val = int64(v)
+	}
+	n, ok := GetIntVal(dict.Get("N"))
+	if !ok {
+		return nil, errors.New("invalid linearization dictionary: missing N")
+	}
+	lin.NumPages = n
+	lin.FirstPage, _ = GetIntVal(dict.Get("P"))
+
+	hints, ok := GetArray(dict.Get("H"))
+	if !ok || (hints.Len() != 2 && hints.Len() != 4) {
+		return nil, errors.New("invalid linearization dictionary: invalid H")
+	}
+	vals, err := hints.ToInt64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("invalid linearization dictionary: %v", err)
+	}
+	lin.HintOffset, lin.HintLength = vals[0], vals[1]
+	if len(vals) == 4 {
+		lin.OverflowHintOffset, lin.OverflowHintLength = vals[2], vals[3]
+	}
+	return lin, nil
+}
+
+// readLinearization parses the first object of the file and returns the linearization
+// parameters, or ErrNotLinearized if the first object is not a linearization parameter
+// dictionary. The parser is positioned after the object on return.
+func (parser *PdfParser) readLinearization() (*Linearization, error) {
+	// The linearization dictionary is the first object in the file, within the first 1024 bytes.
+	parser.SetFileOffset(0)
+	bb := make([]byte, 1024)
+	n, err := io.ReadFull(parser.reader, bb)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	loc := reIndirectObject.FindIndex(bb[:n])
+	if loc == nil {
+		return nil, ErrNotLinearized
+	}
+	parser.SetFileOffset(int64(loc[0]))
+
+	obj, err := parser.ParseIndirectObject()
+	if err != nil {
+		return nil, err
+	}
+	ind, ok := obj.(*PdfIndirectObject)
+	if !ok {
+		return nil, ErrNotLinearized
+	}
+	dict, ok := ind.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return nil, ErrNotLinearized
+	}
+	return newLinearization(dict)
+}
+
+// GetLinearization returns the linearization parameters of the file, or nil if the file is not
+// linearized. Nil is also returned when the file length does not match the linearization
+// parameters, for example if the file has been updated incrementally.
+func (parser *PdfParser) GetLinearization() *Linearization {
+	if parser.linearizationLoaded {
+		return parser.linearization
+	}
+	parser.linearizationLoaded = true
+
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	lin, err := parser.readLinearization()
+	if err != nil {
+		if err != ErrNotLinearized {
+			common.Log.Debug("ERROR: unable to read linearization dictionary: %v", err)
+		}
+		return nil
+	}
+	if lin.Length != parser.fileSize {
+		common.Log.Debug("Linearization dictionary length %d does not match file size %d", lin.Length, parser.fileSize)
+		return nil
+	}
+	parser.linearization = lin
+	return lin
+}
+
+// NewParserFirstPage creates a new parser for a linearized PDF file, which only loads the
+// first page cross-reference section. Only the objects of the first page section are
+// accessible. `rs` only needs to contain the file data up to the end of the first page section,
+// see Linearization.FirstPageEnd. ErrNotLinearized is returned if the file is not linearized.
+func NewParserFirstPage(rs io.ReadSeeker) (*PdfParser, error) {
+	parser := &PdfParser{
+		rs:                                    rs,
+		ObjCache:                              make(objectCache),
+		streamLengthReferenceLookupInProgress: map[int64]bool{},
+	}
+	parser.xrefs.ObjectMap = make(map[int]XrefObject)
+	parser.objstms = make(objectStreams)
+
+	majorVersion, minorVersion, err := parser.parsePdfVersion()
+	if err != nil {
+		return nil, err
+	}
+	parser.version.Major = majorVersion
+	parser.version.Minor = minorVersion
+
+	if parser.fileSize, err = rs.Seek(0, io.SeekEnd); err != nil {
+		return nil, err
+	}
+	lin, err := parser.readLinearization()
+	if err != nil {
+		return nil, err
+	}
+	parser.linearization = lin
+	parser.linearizationLoaded = true
+
+	// The first page cross-reference section follows the linearization dictionary.
+	parser.skipSpaces()
+	parser.xrefOffset = parser.GetFileOffset()
+	if parser.trailer, err = parser.parseXref(); err != nil {
+		return nil, err
+	}
+	if len(parser.xrefs.ObjectMap) == 0 {
+		return nil, errors.New("empty first page xref table")
+	}
+	return parser, nil
+}
+
+// GetLinearizationHints loads the hint tables from the primary hint stream of a linearized file.
+// Encrypted files need to be decrypted first.
+func (parser *PdfParser) GetLinearizationHints() (*LinearizationHints, error) {
+	lin := parser.GetLinearization()
+	if lin == nil {
+		return nil, ErrNotLinearized
+	}
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	// Look up the hint stream by number so that it is decrypted if needed.
+	parser.SetFileOffset(lin.HintOffset)
+	obj, err := parser.ParseIndirectObject()
+	if err != nil {
+		return nil, err
+	}
+	num, _, err := getObjectNumber(obj)
+	if err != nil {
+		return nil, err
+	}
+	if obj, err = parser.LookupByNumber(int(num)); err != nil {
+		return nil, err
+	}
+	stream, ok := obj.(*PdfObjectStream)
+	if !ok {
+		return nil, errors.New("hint stream is not a stream")
+	}
+	data, err := DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	sharedOffset, ok := GetIntVal(stream.Get("S"))
+	if !ok || sharedOffset < 0 || sharedOffset > len(data) {
+		return nil, errors.New("invalid shared object hint table offset")
+	}
+
+	// Offsets in hint tables do not account for the primary hint stream.
+	adjust := func(offset int64) int64 {
+		if offset >= lin.HintOffset {
+			return offset + lin.HintLength
+		}
+		return offset
+	}
+
+	hints := &LinearizationHints{}
+	firstPageOffset, err := hints.readPageTable(newHintReader(data[:sharedOffset]), lin.NumPages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page offset hint table: %v", err)
+	}
+	if err := hints.readSharedTable(newHintReader(data[sharedOffset:]), firstPageOffset); err != nil {
+		return nil, fmt.Errorf("invalid shared object hint table: %v", err)
+	}
+	for i := range hints.Pages {
+		hints.Pages[i].Offset = adjust(hints.Pages[i].Offset)
+	}
+	for i := range hints.SharedGroups {
+		hints.SharedGroups[i].Offset = adjust(hints.SharedGroups[i].Offset)
+	}
+	return hints, nil
+}
+
+// readPageTable reads the page offset hint table for `numPages` pages. Returns the offset of the
+// first page object.
+func (hints *LinearizationHints) readPageTable(r *hintReader, numPages int) (int64, error) {
+	// Header (ISO 32000-1 Table F.3).
+	minObjects := r.read(32)
+	firstPageOffset := int64(r.read(32))
+	objectsBits := r.read(16)
+	minLength := r.read(32)
+	lengthBits := r.read(16)
+	r.read(32) // Least content stream offset.
+	contentOffsetBits := r.read(16)
+	r.read(32) // Least content stream length.
+	contentLengthBits := r.read(16)
+	sharedCountBits := r.read(16)
+	sharedIDBits := r.read(16)
+	numeratorBits := r.read(16)
+	r.read(16) // Denominator.
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	// Per-page entries (Table F.4), each item for all pages.
+	pages := make([]LinearizedPage, numPages)
+	for i := range pages {
+		pages[i].NumObjects = int(minObjects + r.read(objectsBits))
+	}
+	r.align()
+	for i := range pages {
+		pages[i].Length = int64(minLength + r.read(lengthBits))
+	}
+	r.align()
+	for i := range pages {
+		pages[i].SharedGroups = make([]int, r.read(sharedCountBits))
+	}
+	r.align()
+	for i := range pages {
+		for j := range pages[i].SharedGroups {
+			pages[i].SharedGroups[j] = int(r.read(sharedIDBits))
+		}
+	}
+	r.align()
+	for i := range pages {
+		for range pages[i].SharedGroups {
+			r.read(numeratorBits)
+		}
+	}
+	r.align()
+	for range pages {
+		r.read(contentOffsetBits)
+	}
+	r.align()
+	for range pages {
+		r.read(contentLengthBits)
+	}
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	offset := firstPageOffset
+	for i := range pages {
+		pages[i].Offset = offset
+		offset += pages[i].Length
+	}
+	hints.Pages = pages
+	return firstPageOffset, nil
+}
+
+// readSharedTable reads the shared object hint table. The groups of the first page section
+// start at `firstPageOffset`.
+func (hints *LinearizationHints) readSharedTable(r *hintReader, firstPageOffset int64) error {
+	// Header (ISO 32000-1 Table F.5).
+	r.read(32) // Object number of the first object in the shared objects section.
+	sharedOffset := int64(r.read(32))
+	numFirstPage := int(r.read(32))
+	numGroups := int(r.read(32))
+	objectsBits := r.read(16)
+	minLength := r.read(32)
+	lengthBits := r.read(16)
+	if r.err != nil {
+		return r.err
+	}
+	if numFirstPage > numGroups {
+		return errors.New("invalid number of shared object groups")
+	}
+
+	// Group entries (Table F.6).
+	groups := make([]LinearizedObjectGroup, numGroups)
+	for i := range groups {
+		groups[i].Length = int64(minLength + r.read(lengthBits))
+	}
+	r.align()
+	hasSignature := make([]bool, numGroups)
+	for i := range groups {
+		hasSignature[i] = r.read(1) == 1
+	}
+	r.align()
+	for i := range groups {
+		if hasSignature[i] {
+			r.read(64)
+			r.read(64)
+		}
+	}
+	r.align()
+	for i := range groups {
+		groups[i].NumObjects = int(r.read(objectsBits)) + 1
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	offset := firstPageOffset
+	for i := range groups {
+		if i == numFirstPage {
+			offset = sharedOffset
+		}
+		groups[i].Offset = offset
+		offset += groups[i].Length
+	}
+	hints.SharedGroups = groups
+	return nil
+}
+
+// hintReader reads the bit fields of hint tables. The first error is kept in err, after which
+// all reads return 0.
+type hintReader struct {
+	data []byte
+	pos  uint64 // Position in bits.
+	err  error
+}
+
+func newHintReader(data []byte) *hintReader {
+	return &hintReader{data: data}
+}
+
+// read reads a `bits` wide big-endian unsigned value.
+func (r *hintReader) read(bits uint64) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	if bits > 64 {
+		r.err = fmt.Errorf("invalid bit field width %d", bits)
+		return 0
+	}
+	var v uint64
+	for i := uint64(0); i < bits; i++ {
+		byteIdx := r.pos / 8
+		if byteIdx >= uint64(len(r.data)) {
+			r.err = io.ErrUnexpectedEOF
+			return 0
+		}
+		bit := (r.data[byteIdx] >> (7 - r.pos%8)) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v
+}
+
+// align skips to the next byte boundary.
+func (r *hintReader) align() {
+	r.pos = (r.pos + 7) / 8 * 8
+}
@@ -8,6 +8,8 @@ package core
 import (
 	"bufio"
 	"bytes"
+	"crypto"
+	"crypto/x509"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -17,6 +19,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core/security"
@@ -35,9 +38,16 @@ var reXrefSubsection = regexp.MustCompile(`(\d+)\s+(\d+)\s*$`)
 var reXrefEntry = regexp.MustCompile(`(\d+)\s+(\d+)\s+([nf])\s*$`)
 
 // PdfParser parses a PDF file and provides access to the object structure of the PDF.
+//
+// Once created, the parser is safe for concurrent use: object lookups (LookupByNumber,
+// LookupByReference, Resolve and resolving references) are serialized. The ObjCache field
+// must not be accessed directly while lookups may be in progress.
 type PdfParser struct {
 	version Version
 
+	// Serializes object lookups, which share the file offset, the reader and the caches.
+	mu sync.Mutex
+
 	rs               io.ReadSeeker
 	reader           *bufio.Reader
 	fileSize         int64
@@ -49,6 +59,21 @@ type PdfParser struct {
 	crypter          *PdfCrypt
 	repairsAttempted bool // Avoid multiple attempts for repair.
 
+	// Linearization parameters, loaded on first use.
+	linearization       *Linearization
+	linearizationLoaded bool
+
+	// Revisions of incrementally updated files, loaded on first use.
+	revisions []*Revision
+
+	// Object numbers of free entries of the parsed xref sections. Only tracked if not nil (when
+	// loading revisions).
+	freeObjects map[int]struct{}
+
+	// Repairs of damaged files, nil unless the parser is created with NewParserWithRepair.
+	repairOpts   *RepairOptions
+	repairReport *RepairReport
+
 	ObjCache objectCache
 
 	// Tracker for reference lookups when looking up Length entry of stream objects.
@@ -84,6 +109,14 @@ func (parser *PdfParser) IsAuthenticated() bool {
 	return parser.crypter.authenticated
 }
 
+// IsAuthenticationRequired returns true if the PDF is encrypted and must be authenticated before
+// its objects can be accessed. Documents in which only the embedded files are encrypted (with
+// Identity StmF and StrF crypt filters) can be accessed without authentication, their embedded
+// file streams failing to decode until the document is decrypted.
+func (parser *PdfParser) IsAuthenticationRequired() bool {
+	return parser.crypter != nil && !parser.crypter.authenticated && !parser.crypter.isReadableWithoutAuth()
+}
+
 // GetTrailer returns the PDFs trailer dictionary. The trailer dictionary is typically the starting point for a PDF,
 // referencing other key objects that are important in the document structure.
 func (parser *PdfParser) GetTrailer() *PdfObjectDictionary {
@@ -775,6 +808,8 @@ func (parser *PdfParser) parseXrefTable() (*PdfObjectDictionary, error) {
 						Offset: first, Generation: gen}
 					parser.xrefs.ObjectMap[curObjNum] = obj
 				}
+			} else if strings.ToLower(third) == "f" && parser.freeObjects != nil && curObjNum > 0 {
+				parser.freeObjects[curObjNum] = struct{}{}
 			}
 
 			curObjNum++
@@ -1034,6 +1069,9 @@ func (parser *PdfParser) parseXrefStream(xstm *PdfObjectInteger) (*PdfObjectDict
 		common.Log.Trace("%d. xref: %d %d %d", objNum, ftype, n2, n3)
 		if ftype == 0 {
 			common.Log.Trace("- Free object - can probably ignore")
+			if parser.freeObjects != nil && objNum > 0 {
+				parser.freeObjects[objNum] = struct{}{}
+			}
 		} else if ftype == 1 {
 			common.Log.Trace("- In use - uncompressed via offset %b", p2)
 			// If offset (n2) is same as the XRefs table offset, then update the Object number with the
@@ -1383,7 +1421,7 @@ func (parser *PdfParser) traceStreamLength(lengthObj PdfObject) (PdfObject, erro
 		parser.streamLengthReferenceLookupInProgress[lengthRef.ObjectNumber] = true
 	}
 
-	slo, err := parser.Resolve(lengthObj)
+	slo, err := parser.resolve(lengthObj)
 	if err != nil {
 		return nil, err
 	}
@@ -1449,6 +1487,11 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 	for {
 		bb, err := parser.reader.Peek(2)
 		if err != nil {
+			if parser.repairOpts != nil && indirect.PdfObject != nil {
+				// Truncated file.
+				parser.repairReport.add(RepairMissingEndobj, int(indirect.ObjectNumber), "missing endobj at end of file")
+				break
+			}
 			return &indirect, err
 		}
 		common.Log.Trace("Ind. peek: %s (% x)!", string(bb), string(bb))
@@ -1514,6 +1557,10 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 					}
 					common.Log.Trace("Stream dict %s", dict)
 
+					if parser.repairOpts != nil && parser.repairOpts.RecoverStreams {
+						parser.repairStreamLength(indirect.ObjectNumber, dict)
+					}
+
 					// Special stream length tracing function used to avoid endless recursive looping.
 					slo, err := parser.traceStreamLength(dict.Get("Length"))
 					if err != nil {
@@ -1578,6 +1625,12 @@ func (parser *PdfParser) ParseIndirectObject() (PdfObject, error) {
 				}
 			}
 
+			if parser.repairOpts != nil && indirect.PdfObject != nil {
+				// The object is not terminated, e.g. followed by the next object.
+				parser.repairReport.add(RepairMissingEndobj, int(indirect.ObjectNumber), "missing endobj")
+				break
+			}
+
 			indirect.PdfObject, err = parser.parseObject()
 			if indirect.PdfObject == nil {
 				common.Log.Debug("INCOMPATIBILITY: Indirect object not containing an object - assuming null object")
@@ -1613,11 +1666,21 @@ func NewParserFromString(txt string) *PdfParser {
 // NewParser creates a new parser for a PDF file via ReadSeeker. Loads the cross reference stream and trailer.
 // An error is returned on failure.
 func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
+	return newParser(rs, nil)
+}
+
+// newParser creates a new parser for `rs`, repairing damaged files as specified by `repairOpts`
+// if not nil.
+func newParser(rs io.ReadSeeker, repairOpts *RepairOptions) (*PdfParser, error) {
 	parser := &PdfParser{
 		rs:                                    rs,
 		ObjCache:                              make(objectCache),
 		streamLengthReferenceLookupInProgress: map[int64]bool{},
 	}
+	if repairOpts != nil {
+		parser.repairOpts = repairOpts
+		parser.repairReport = &RepairReport{}
+	}
 
 	// Parse PDF version.
 	majorVersion, minorVersion, err := parser.parsePdfVersion()
@@ -1631,10 +1694,18 @@ func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
 	// Start by reading the xrefs (from bottom).
 	if parser.trailer, err = parser.loadXrefs(); err != nil {
 		common.Log.Debug("ERROR: Failed to load xref table! %s", err)
-		return nil, err
+		if repairOpts == nil || !repairOpts.RebuildXrefs {
+			return nil, err
+		}
 	}
 	common.Log.Trace("Trailer: %s", parser.trailer)
 
+	if repairOpts != nil && repairOpts.RebuildXrefs {
+		if err := parser.repairXrefs(err != nil); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(parser.xrefs.ObjectMap) == 0 {
 		return nil, fmt.Errorf("empty XREF table - Invalid")
 	}
@@ -1644,11 +1715,14 @@ func NewParser(rs io.ReadSeeker) (*PdfParser, error) {
 
 // Resolves a reference, returning the object and indicates whether or not it was cached.
 func (parser *PdfParser) resolveReference(ref *PdfObjectReference) (PdfObject, bool, error) {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	cachedObj, isCached := parser.ObjCache[int(ref.ObjectNumber)]
 	if isCached {
 		return cachedObj, true, nil
 	}
-	obj, err := parser.LookupByReference(*ref)
+	obj, _, err := parser.lookupByNumberWrapper(int(ref.ObjectNumber), true)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1751,6 +1825,16 @@ func (parser *PdfParser) Decrypt(password []byte) (bool, error) {
 	return authenticated, err
 }
 
+// DecryptWithCertificate attempts to decrypt the PDF file protected by the public-key security handler
+// using a recipient certificate and the corresponding private key. Returns true if successful, false otherwise.
+// An error is returned when there is a problem with decrypting.
+func (parser *PdfParser) DecryptWithCertificate(cert *x509.Certificate, pkey crypto.PrivateKey) (bool, error) {
+	if parser.crypter == nil {
+		return false, errors.New("check encryption first")
+	}
+	return parser.crypter.authenticatePubKey(cert, pkey)
+}
+
 // CheckAccessRights checks access rights and permissions for a specified password. If either user/owner password is
 // specified, full rights are granted, otherwise the access rights are specified by the Permissions flag.
 //
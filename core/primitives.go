@@ -81,6 +81,10 @@ type PdfObjectStream struct {
 	PdfObjectReference
 	*PdfObjectDictionary
 	Stream []byte
+
+	// Crypt handler of an embedded file stream left encrypted when loaded from a document which
+	// can be read without authentication, decrypted when decoded.
+	crypter *PdfCrypt
 }
 
 // PdfObjectStreams represents the primitive PDF object streams.
@@ -8,10 +8,14 @@
 package core
 
 import (
+	"bytes"
+	"compress/zlib"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"regexp"
+	"sort"
 
 	"bufio"
 	"io"
@@ -62,7 +66,7 @@ func (parser *PdfParser) rebuildXrefTable() error {
 				common.Log.Debug("ERROR: Failed xref rebuild repair (%s)", err)
 				return err
 			}
-			parser.xrefs = *xrefTable
+			parser.setRepairedXrefs(xrefTable)
 			common.Log.Debug("Repaired xref table built")
 			return nil
 		}
@@ -285,3 +289,429 @@ func (parser *PdfParser) seekPdfVersionTopDown() (int, int, error) {
 
 	return 0, 0, errors.New("version not found")
 }
+
+// RepairOptions specifies the repairs attempted by a parser created with NewParserWithRepair.
+type RepairOptions struct {
+	// RebuildXrefs rebuilds the cross-reference table by scanning the file for objects if it
+	// cannot be loaded, and reconstructs the trailer if it does not reference a valid catalog.
+	RebuildXrefs bool
+
+	// RecoverStreams corrects missing or wrong stream lengths by searching for the endstream
+	// keyword, and accepts objects that are missing the endobj keyword.
+	RecoverStreams bool
+
+	// SalvageObjectStreams recovers the objects of object streams that are not referenced by
+	// a rebuilt cross-reference table, and the objects that can be decoded from truncated or
+	// corrupted object streams.
+	SalvageObjectStreams bool
+}
+
+// DefaultRepairOptions returns repair options with all repairs enabled.
+func DefaultRepairOptions() *RepairOptions {
+	return &RepairOptions{
+		RebuildXrefs:         true,
+		RecoverStreams:       true,
+		SalvageObjectStreams: true,
+	}
+}
+
+// RepairType is the type of a repair made to a damaged file.
+type RepairType int
+
+const (
+	// RepairXrefs indicates that the cross-reference table was rebuilt by scanning the file.
+	RepairXrefs RepairType = iota
+	// RepairTrailer indicates that the trailer was recovered or reconstructed.
+	RepairTrailer
+	// RepairStreamLength indicates that the length of a stream was corrected.
+	RepairStreamLength
+	// RepairMissingEndobj indicates that an object was missing the endobj keyword.
+	RepairMissingEndobj
+	// RepairObjectStream indicates that objects were salvaged from a damaged object stream.
+	RepairObjectStream
+	// RepairCatalog indicates that the document catalog was reconstructed.
+	RepairCatalog
+	// RepairPageTree indicates that the page tree was rebuilt.
+	RepairPageTree
+)
+
+// String returns a string describing the repair type.
+func (t RepairType) String() string {
+	switch t {
+	case RepairXrefs:
+		return "xrefs"
+	case RepairTrailer:
+		return "trailer"
+	case RepairStreamLength:
+		return "stream length"
+	case RepairMissingEndobj:
+		return "missing endobj"
+	case RepairObjectStream:
+		return "object stream"
+	case RepairCatalog:
+		return "catalog"
+	case RepairPageTree:
+		return "page tree"
+	}
+	return fmt.Sprintf("RepairType(%d)", int(t))
+}
+
+// Repair describes a repair made to a damaged file.
+type Repair struct {
+	Type RepairType
+	// ObjectNumber is the number of the repaired object, 0 if the repair does not concern a
+	// specific object.
+	ObjectNumber int
+	Description  string
+}
+
+// String returns a string describing the repair.
+func (r Repair) String() string {
+	if r.ObjectNumber != 0 {
+		return fmt.Sprintf("%s (object %d): %s", r.Type, r.ObjectNumber, r.Description)
+	}
+	return fmt.Sprintf("%s: %s", r.Type, r.Description)
+}
+
+// RepairReport lists the repairs made while reading a damaged file, in order.
+type RepairReport struct {
+	Repairs []Repair
+}
+
+// Add adds a repair of type `t` of object `objNum` to the report, described by `format` and `args`.
+func (r *RepairReport) Add(t RepairType, objNum int, format string, args ...interface{}) {
+	common.Log.Debug("Repair: %s %d: %s", t, objNum, fmt.Sprintf(format, args...))
+	r.Repairs = append(r.Repairs, Repair{Type: t, ObjectNumber: objNum, Description: fmt.Sprintf(format, args...)})
+}
+
+// add is Add for parsers which may not be in repair mode (nil report).
+func (r *RepairReport) add(t RepairType, objNum int, format string, args ...interface{}) {
+	if r != nil {
+		r.Add(t, objNum, format, args...)
+	}
+}
+
+// Repaired returns true if any repair has been made.
+func (r *RepairReport) Repaired() bool {
+	return r != nil && len(r.Repairs) > 0
+}
+
+// NewParserWithRepair creates a new parser for `rs` like NewParser, repairing the damage found in
+// the file as specified by `opts` (all repairs if nil). The repairs made are listed by
+// RepairReport, including those made when objects are loaded later on.
+func NewParserWithRepair(rs io.ReadSeeker, opts *RepairOptions) (*PdfParser, error) {
+	if opts == nil {
+		opts = DefaultRepairOptions()
+	}
+	return newParser(rs, opts)
+}
+
+// RepairReport returns the report of the repairs made by the parser, nil if the parser has not
+// been created with NewParserWithRepair.
+func (parser *PdfParser) RepairReport() *RepairReport {
+	return parser.repairReport
+}
+
+// repairXrefs rebuilds the cross-reference table by scanning the file if it could not be loaded
+// (`broken`), and the trailer if it does not reference a valid catalog.
+func (parser *PdfParser) repairXrefs(broken bool) error {
+	if broken || len(parser.xrefs.ObjectMap) == 0 {
+		xrefTable, err := parser.repairRebuildXrefsTopDown()
+		if err != nil {
+			return err
+		}
+		if len(xrefTable.ObjectMap) == 0 {
+			return errors.New("repair: no objects found")
+		}
+		parser.objstms = make(objectStreams)
+		parser.setRepairedXrefs(xrefTable)
+	}
+
+	var root PdfObject
+	if parser.trailer != nil {
+		root = parser.trailer.Get("Root")
+	}
+	if !parser.isCatalog(root) {
+		parser.repairTrailer()
+	}
+	return nil
+}
+
+// setRepairedXrefs sets the cross-reference table rebuilt by scanning the file.
+func (parser *PdfParser) setRepairedXrefs(xrefTable *XrefTable) {
+	parser.xrefs = *xrefTable
+	if parser.repairOpts == nil {
+		return
+	}
+	parser.repairReport.add(RepairXrefs, 0, "cross-reference table rebuilt, %d objects found", len(xrefTable.ObjectMap))
+	if parser.repairOpts.SalvageObjectStreams {
+		parser.repairObjectStreamEntries()
+	}
+}
+
+// repairObjectStreamEntries adds the cross-reference entries of the objects contained in object
+// streams, which are not found when scanning the file for objects.
+func (parser *PdfParser) repairObjectStreamEntries() {
+	if parser.crypter != nil || parser.trailer != nil && parser.trailer.Get("Encrypt") != nil {
+		// The object streams cannot be decoded before authentication.
+		return
+	}
+
+	var nums []int
+	for num, xref := range parser.xrefs.ObjectMap {
+		if xref.XType == XrefTypeTableEntry {
+			nums = append(nums, num)
+		}
+	}
+	sort.Ints(nums)
+
+	for _, num := range nums {
+		obj, _, err := parser.lookupByNumber(num, false)
+		if err != nil {
+			continue
+		}
+		stream, ok := obj.(*PdfObjectStream)
+		if !ok {
+			continue
+		}
+		parser.resolveStreamDict(stream.PdfObjectDictionary)
+		if name, _ := GetNameVal(stream.Get("Type")); name != "ObjStm" {
+			continue
+		}
+		objstm, err := parser.loadObjectStream(num)
+		if err != nil {
+			common.Log.Debug("ERROR: Failed loading object stream %d: %v", num, err)
+			continue
+		}
+
+		var objNums []int
+		for objNum := range objstm.offsets {
+			objNums = append(objNums, objNum)
+		}
+		sort.Ints(objNums)
+		for i, objNum := range objNums {
+			if _, has := parser.xrefs.ObjectMap[objNum]; has {
+				// Defined outside of the object stream, e.g. by an incremental update.
+				continue
+			}
+			parser.xrefs.ObjectMap[objNum] = XrefObject{
+				XType:        XrefTypeObjectStream,
+				ObjectNumber: objNum,
+				OsObjNumber:  num,
+				OsObjIndex:   i,
+			}
+		}
+	}
+}
+
+// isCatalog returns true if `obj` is a reference to a catalog dictionary.
+func (parser *PdfParser) isCatalog(obj PdfObject) bool {
+	ref, ok := obj.(*PdfObjectReference)
+	if !ok {
+		return false
+	}
+	catalog, err := parser.LookupByReference(*ref)
+	if err != nil {
+		return false
+	}
+	ind, ok := catalog.(*PdfIndirectObject)
+	if !ok {
+		return false
+	}
+	dict, ok := ind.PdfObject.(*PdfObjectDictionary)
+	if !ok {
+		return false
+	}
+	name, _ := GetNameVal(dict.Get("Type"))
+	return name == "Catalog" || dict.Get("Pages") != nil
+}
+
+// repairTrailer recovers the trailer from the trailer dictionaries and cross-reference streams
+// present in the file, or reconstructs it from the catalog object if none of them references a
+// valid catalog. The trailer is left without Root if there is no catalog object.
+func (parser *PdfParser) repairTrailer() {
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	// Candidate trailers in file order.
+	var candidates []*PdfObjectDictionary
+	for _, off := range parser.repairFindKeyword("trailer") {
+		parser.SetFileOffset(off + int64(len("trailer")))
+		parser.skipSpaces()
+		if dict, err := parser.ParseDict(); err == nil {
+			candidates = append(candidates, dict)
+		}
+	}
+
+	// Cross-reference streams and catalogs, by offset.
+	var nums []int
+	for num, xref := range parser.xrefs.ObjectMap {
+		if xref.XType == XrefTypeTableEntry {
+			nums = append(nums, num)
+		}
+	}
+	sort.Slice(nums, func(i, j int) bool {
+		return parser.xrefs.ObjectMap[nums[i]].Offset < parser.xrefs.ObjectMap[nums[j]].Offset
+	})
+	catalogNum := 0
+	for _, num := range nums {
+		obj, _, err := parser.lookupByNumber(num, false)
+		if err != nil {
+			continue
+		}
+		switch t := obj.(type) {
+		case *PdfObjectStream:
+			if name, _ := GetNameVal(t.Get("Type")); name == "XRef" {
+				candidates = append(candidates, t.PdfObjectDictionary)
+			}
+		case *PdfIndirectObject:
+			if dict, ok := t.PdfObject.(*PdfObjectDictionary); ok {
+				if name, _ := GetNameVal(dict.Get("Type")); name == "Catalog" {
+					catalogNum = num
+				}
+			}
+		}
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if parser.isCatalog(candidates[i].Get("Root")) {
+			parser.trailer = candidates[i]
+			parser.repairReport.add(RepairTrailer, 0, "trailer recovered")
+			return
+		}
+	}
+
+	// Keep the other entries (encryption, document information) if possible.
+	trailer := parser.trailer
+	if trailer == nil && len(candidates) > 0 {
+		trailer = candidates[len(candidates)-1]
+	}
+	if trailer == nil {
+		trailer = MakeDict()
+	}
+	parser.trailer = trailer
+	if catalogNum == 0 {
+		trailer.Remove("Root")
+		parser.repairReport.add(RepairTrailer, 0, "catalog not found")
+		return
+	}
+	trailer.Set("Root", &PdfObjectReference{parser: parser, ObjectNumber: int64(catalogNum)})
+	parser.repairReport.add(RepairTrailer, 0, "trailer reconstructed with catalog %d", catalogNum)
+}
+
+// repairFindKeyword returns the offsets of the occurrences of `keyword` in the file.
+func (parser *PdfParser) repairFindKeyword(keyword string) []int64 {
+	parser.SetFileOffset(0)
+	var offsets []int64
+	var pos int64
+	matched := 0
+	for {
+		b, err := parser.reader.ReadByte()
+		if err != nil {
+			break
+		}
+		pos++
+		switch {
+		case b == keyword[matched]:
+			matched++
+		case b == keyword[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+		if matched == len(keyword) {
+			offsets = append(offsets, pos-int64(len(keyword)))
+			matched = 0
+		}
+	}
+	return offsets
+}
+
+// repairStreamLength checks the Length of the stream with dictionary `dict` of object `objNum`,
+// whose data starts at the current position, and corrects it if the data is not followed by the
+// endstream keyword. The actual length is found by searching for endstream (or endobj).
+func (parser *PdfParser) repairStreamLength(objNum int64, dict *PdfObjectDictionary) {
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	length := int64(-1)
+	if obj, err := parser.traceStreamLength(dict.Get("Length")); err == nil {
+		if val, ok := GetIntVal(obj); ok && val >= 0 {
+			length = int64(val)
+		}
+	}
+	if length >= 0 && offset+length <= parser.fileSize && parser.isStreamEnd(offset+length) {
+		return
+	}
+
+	actual := parser.findStreamEnd(offset)
+	dict.Set("Length", MakeInteger(actual))
+	if length < 0 {
+		parser.repairReport.add(RepairStreamLength, int(objNum), "invalid length, set to %d", actual)
+	} else {
+		parser.repairReport.add(RepairStreamLength, int(objNum), "length %d corrected to %d", length, actual)
+	}
+}
+
+// isStreamEnd returns true if `offset` is followed by the endstream keyword (after white space).
+func (parser *PdfParser) isStreamEnd(offset int64) bool {
+	parser.SetFileOffset(offset)
+	parser.skipSpaces()
+	bb, _ := parser.reader.Peek(9)
+	return string(bb) == "endstream"
+}
+
+// findStreamEnd returns the length of the data of the stream starting at `offset`, up to the end
+// of line preceding the first endstream or endobj keyword, or up to the end of the file.
+func (parser *PdfParser) findStreamEnd(offset int64) int64 {
+	parser.SetFileOffset(offset)
+	window := make([]byte, 0, 12)
+	pos := offset
+	for {
+		b, err := parser.reader.ReadByte()
+		if err != nil {
+			return pos - offset
+		}
+		pos++
+		if len(window) == cap(window) {
+			window = append(window[:0], window[1:]...)
+		}
+		window = append(window, b)
+
+		for _, keyword := range []string{"endstream", "endobj"} {
+			if !bytes.HasSuffix(window, []byte(keyword)) {
+				continue
+			}
+			end := pos - int64(len(keyword))
+			eol := window[:len(window)-len(keyword)]
+			if n := len(eol); n > 0 && eol[n-1] == '\n' {
+				end--
+				eol = eol[:n-1]
+			}
+			if n := len(eol); n > 0 && eol[n-1] == '\r' {
+				end--
+			}
+			if end < offset {
+				end = offset
+			}
+			return end - offset
+		}
+	}
+}
+
+// salvageFlateData returns the data that can be decompressed from the Flate encoded stream `so`,
+// which is truncated or corrupted.
+func salvageFlateData(so *PdfObjectStream) ([]byte, error) {
+	if name, _ := GetNameVal(so.Get("Filter")); name != StreamEncodingFilterNameFlate {
+		return nil, errors.New("unsupported filter for salvaging stream data")
+	}
+	r, err := zlib.NewReader(bytes.NewReader(so.Stream))
+	if err != nil {
+		return nil, err
+	}
+	data, _ := ioutil.ReadAll(r)
+	if len(data) == 0 {
+		return nil, errors.New("no data recovered")
+	}
+	return data, nil
+}
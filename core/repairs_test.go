@@ -0,0 +1,146 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// repairTypes returns the types of the repairs in `report`.
+func repairTypes(report *RepairReport) map[RepairType]int {
+	types := map[RepairType]int{}
+	for _, repair := range report.Repairs {
+		types[repair.Type]++
+	}
+	return types
+}
+
+func TestRepairStreamLength(t *testing.T) {
+	content := "BT /F1 12 Tf (Hello) Tj ET"
+	data := "%PDF-1.7\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n" +
+		"3 0 obj\n<< /Length 5 >>\nstream\n" + content + "\nendstream\nendobj\n" +
+		"4 0 obj\n<< /Length 7 0 R >>\nstream\n" + content + "\r\nendstream\nendobj\n" +
+		"trailer\n<< /Size 5 /Root 1 0 R >>\nstartxref\n123456\n%%EOF\n"
+
+	// Fails without repair.
+	_, err := NewParser(strings.NewReader(data))
+	require.Error(t, err)
+
+	parser, err := NewParserWithRepair(strings.NewReader(data), nil)
+	require.NoError(t, err)
+	for _, num := range []int{3, 4} {
+		obj, err := parser.LookupByNumber(num)
+		require.NoError(t, err)
+		stream, ok := obj.(*PdfObjectStream)
+		require.True(t, ok)
+		require.Equal(t, content, string(stream.Stream))
+	}
+
+	types := repairTypes(parser.RepairReport())
+	require.Equal(t, 1, types[RepairXrefs])
+	require.Equal(t, 2, types[RepairStreamLength])
+}
+
+func TestRepairMissingEndobj(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := map[int]int{}
+	for num, obj := range []string{
+		"<< /Type /Catalog /Pages 2 0 R >>\nendobj\n",
+		"<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n",
+		"(missing)\n",
+		"(last)\nendobj\n",
+	} {
+		offsets[num+1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s", num+1, obj)
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n0 5\n0000000000 65535 f \n")
+	for num := 1; num <= 4; num++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[num])
+	}
+	// The trailer has no Root.
+	fmt.Fprintf(&buf, "trailer\n<< /Size 5 >>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	parser, err := NewParserWithRepair(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	root, ok := GetIndirect(parser.GetTrailer().Get("Root"))
+	require.True(t, ok)
+	require.Equal(t, int64(1), root.ObjectNumber)
+
+	obj, err := parser.LookupByNumber(3)
+	require.NoError(t, err)
+	require.Equal(t, "missing", obj.(*PdfIndirectObject).PdfObject.(*PdfObjectString).Str())
+	obj, err = parser.LookupByNumber(4)
+	require.NoError(t, err)
+	require.Equal(t, "last", obj.(*PdfIndirectObject).PdfObject.(*PdfObjectString).Str())
+
+	types := repairTypes(parser.RepairReport())
+	require.Equal(t, 0, types[RepairXrefs])
+	require.Equal(t, 1, types[RepairTrailer])
+	require.Equal(t, 1, types[RepairMissingEndobj])
+}
+
+func TestRepairObjectStream(t *testing.T) {
+	// Object stream with objects 3..202, stored uncompressed so that the beginning of the
+	// truncated data can be decoded.
+	var header, body bytes.Buffer
+	for num := 3; num < 203; num++ {
+		fmt.Fprintf(&header, "%d %d ", num, body.Len())
+		fmt.Fprintf(&body, "(object %d) ", num)
+	}
+	first := header.Len()
+	var encoded bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&encoded, zlib.NoCompression)
+	require.NoError(t, err)
+	_, err = zw.Write(append(header.Bytes(), body.Bytes()...))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	truncated := encoded.Bytes()[:encoded.Len()/2]
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	buf.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	buf.WriteString("2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n")
+	fmt.Fprintf(&buf, "203 0 obj\n<< /Type /ObjStm /N 200 /First %d /Filter /FlateDecode /Length %d >>\nstream\n",
+		first, len(truncated))
+	buf.Write(truncated)
+	buf.WriteString("\nendstream\nendobj\n")
+	// No cross-reference table, the objects of the object stream are found by the repair.
+	buf.WriteString("trailer\n<< /Size 204 /Root 1 0 R >>\n%%EOF\n")
+
+	parser, err := NewParserWithRepair(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	obj, err := parser.LookupByNumber(3)
+	require.NoError(t, err)
+	require.Equal(t, "object 3", obj.(*PdfIndirectObject).PdfObject.(*PdfObjectString).Str())
+
+	// The last objects are lost.
+	obj, err = parser.LookupByNumber(202)
+	require.NoError(t, err)
+	require.True(t, IsNullObject(TraceToDirectObject(obj)))
+
+	types := repairTypes(parser.RepairReport())
+	require.Equal(t, 1, types[RepairXrefs])
+	require.NotZero(t, types[RepairObjectStream])
+
+	// Object streams are not salvaged if disabled.
+	opts := DefaultRepairOptions()
+	opts.SalvageObjectStreams = false
+	parser, err = NewParserWithRepair(bytes.NewReader(buf.Bytes()), opts)
+	require.NoError(t, err)
+	obj, err = parser.LookupByNumber(3)
+	require.NoError(t, err)
+	require.True(t, IsNullObject(TraceToDirectObject(obj)))
+}
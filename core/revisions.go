@@ -0,0 +1,308 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/carmel/unipdf/common"
+)
+
+// Revision is a revision of a PDF file. The original document is the first revision and each
+// incremental update appends a revision to the file, containing the new and changed objects, a
+// cross-reference section and a trailer.
+type Revision struct {
+	// Index is the index of the revision, 0 being the original document.
+	Index int
+	// Offset is the offset of the first byte of the revision, 0 for the original document.
+	Offset int64
+	// End is the offset following the end-of-file marker of the revision, i.e. the size of the
+	// file as it was at this revision.
+	End int64
+	// XrefOffset is the offset of the cross-reference section of the revision (the startxref
+	// value of the revision).
+	XrefOffset int64
+	// Trailer is the trailer dictionary of the revision.
+	Trailer *PdfObjectDictionary
+
+	// Cross-reference sections of the revision, in file order of the Prev chain (oldest first).
+	// Linearized files have two sections in the first revision.
+	sections []*xrefSection
+}
+
+// xrefSection contains the entries of a single cross-reference section.
+type xrefSection struct {
+	offset  int64
+	trailer *PdfObjectDictionary
+	objects map[int]XrefObject
+	free    map[int]struct{}
+}
+
+// RevisionChanges lists the numbers of the objects that differ between two revisions, sorted.
+type RevisionChanges struct {
+	// Added are the objects defined in the later revision only.
+	Added []int
+	// Changed are the objects defined in both revisions which have been redefined in between.
+	Changed []int
+	// Removed are the objects defined in the earlier revision only (freed).
+	Removed []int
+}
+
+// GetRevisions returns the revisions of the file, oldest first, by following the chain of
+// cross-reference sections. Files that have not been incrementally updated have a single revision.
+func (parser *PdfParser) GetRevisions() ([]*Revision, error) {
+	if parser.revisions != nil {
+		return parser.revisions, nil
+	}
+	offset := parser.GetFileOffset()
+	defer parser.SetFileOffset(offset)
+
+	// Load the sections, latest first.
+	var chain []*xrefSection
+	visited := map[int64]bool{}
+	for xrefOffset := parser.xrefOffset; !visited[xrefOffset]; {
+		visited[xrefOffset] = true
+		section, err := parser.parseXrefSection(xrefOffset)
+		if err != nil {
+			if len(chain) == 0 {
+				return nil, err
+			}
+			common.Log.Debug("ERROR: failed to load xref section at %d: %v", xrefOffset, err)
+			break
+		}
+		chain = append(chain, section)
+		prev, ok := GetIntVal(section.trailer.Get("Prev"))
+		if !ok {
+			break
+		}
+		xrefOffset = int64(prev)
+	}
+
+	var revisions []*Revision
+	for i := len(chain) - 1; i >= 0; i-- {
+		section := chain[i]
+		if n := len(revisions); n > 0 && section.offset < revisions[n-1].End {
+			// A section located within the previous revision belongs to it. This is the case for
+			// the first page cross-reference section of linearized files, which points to the main
+			// section at the end of the file.
+			rev := revisions[n-1]
+			rev.sections = append(rev.sections, section)
+			rev.XrefOffset = section.offset
+			rev.Trailer = section.trailer
+			continue
+		}
+		rev := &Revision{
+			Index:      len(revisions),
+			End:        parser.revisionEnd(section.offset),
+			XrefOffset: section.offset,
+			Trailer:    section.trailer,
+			sections:   []*xrefSection{section},
+		}
+		if n := len(revisions); n > 0 {
+			rev.Offset = revisions[n-1].End
+		}
+		revisions = append(revisions, rev)
+	}
+	parser.revisions = revisions
+	return revisions, nil
+}
+
+// parseXrefSection parses the cross-reference section at `offset` without adding the entries to
+// the cross-reference table of the parser.
+func (parser *PdfParser) parseXrefSection(offset int64) (*xrefSection, error) {
+	p := &PdfParser{
+		rs:                                    parser.rs,
+		fileSize:                              parser.fileSize,
+		ObjCache:                              make(objectCache),
+		streamLengthReferenceLookupInProgress: map[int64]bool{},
+		freeObjects:                           map[int]struct{}{},
+	}
+	p.xrefs.ObjectMap = make(map[int]XrefObject)
+	p.SetFileOffset(offset)
+
+	trailer, err := p.parseXref()
+	if err != nil {
+		return nil, err
+	}
+	// Hybrid-reference files.
+	if xrefStm, ok := GetInt(trailer.Get("XRefStm")); ok {
+		if _, err := p.parseXrefStream(xrefStm); err != nil {
+			return nil, err
+		}
+	}
+	return &xrefSection{offset: offset, trailer: trailer, objects: p.xrefs.ObjectMap, free: p.freeObjects}, nil
+}
+
+// revisionEnd returns the offset following the end-of-file marker (and end-of-line) after the
+// cross-reference section at `offset`. Returns the file size if there is no such marker.
+func (parser *PdfParser) revisionEnd(offset int64) int64 {
+	if _, err := parser.rs.Seek(offset, io.SeekStart); err != nil {
+		return parser.fileSize
+	}
+	r := bufio.NewReader(parser.rs)
+	const marker = "%%EOF"
+	pos, matched := offset, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return parser.fileSize
+		}
+		pos++
+		switch {
+		case b == marker[matched]:
+			matched++
+		case b == '%' && matched == 2:
+			// "%%%" still matches the "%%" prefix.
+		case b == '%':
+			matched = 1
+		default:
+			matched = 0
+		}
+		if matched == len(marker) {
+			break
+		}
+	}
+	if b, err := r.ReadByte(); err == nil {
+		switch b {
+		case '\r':
+			pos++
+			if b, err := r.ReadByte(); err == nil && b == '\n' {
+				pos++
+			}
+		case '\n':
+			pos++
+		}
+	}
+	return pos
+}
+
+// revisionObjects returns the cross-reference entries in effect at revision `index`.
+func revisionObjects(revisions []*Revision, index int) map[int]XrefObject {
+	objects := map[int]XrefObject{}
+	for _, rev := range revisions[:index+1] {
+		for _, section := range rev.sections {
+			for num := range section.free {
+				delete(objects, num)
+			}
+			for num, obj := range section.objects {
+				objects[num] = obj
+			}
+		}
+	}
+	return objects
+}
+
+// CompareRevisions returns the objects that have been added, changed or removed in revision `to`
+// relative to revision `from`. Objects are considered changed if they have been redefined by an
+// incremental update, even if their content is the same. Cross-reference streams are ignored.
+func (parser *PdfParser) CompareRevisions(from, to int) (*RevisionChanges, error) {
+	revisions, err := parser.GetRevisions()
+	if err != nil {
+		return nil, err
+	}
+	if from < 0 || to < 0 || from >= len(revisions) || to >= len(revisions) {
+		return nil, ErrRangeError
+	}
+
+	xrefStreams := map[int64]bool{}
+	for _, rev := range revisions {
+		for _, section := range rev.sections {
+			xrefStreams[section.offset] = true
+			if xrefStm, ok := GetIntVal(section.trailer.Get("XRefStm")); ok {
+				xrefStreams[int64(xrefStm)] = true
+			}
+		}
+	}
+	isXrefStream := func(obj XrefObject) bool {
+		return obj.XType == XrefTypeTableEntry && xrefStreams[obj.Offset]
+	}
+
+	before, after := revisionObjects(revisions, from), revisionObjects(revisions, to)
+	changes := &RevisionChanges{}
+	for num, obj := range after {
+		if isXrefStream(obj) {
+			continue
+		}
+		old, ok := before[num]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, num)
+		case old != obj:
+			changes.Changed = append(changes.Changed, num)
+		case obj.XType == XrefTypeObjectStream && before[obj.OsObjNumber] != after[obj.OsObjNumber]:
+			// Same location within a redefined object stream.
+			changes.Changed = append(changes.Changed, num)
+		}
+	}
+	for num, obj := range before {
+		if _, ok := after[num]; !ok && !isXrefStream(obj) {
+			changes.Removed = append(changes.Removed, num)
+		}
+	}
+	sort.Ints(changes.Added)
+	sort.Ints(changes.Changed)
+	sort.Ints(changes.Removed)
+	return changes, nil
+}
+
+// RevisionReader returns a reader of the file as it was at revision `index`, i.e. of the file
+// data up to the end of the revision. The returned reader reads from the same source as the
+// parser, so they should not be used concurrently.
+func (parser *PdfParser) RevisionReader(index int) (io.ReadSeeker, error) {
+	revisions, err := parser.GetRevisions()
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(revisions) {
+		return nil, ErrRangeError
+	}
+	return &limitedReadSeeker{rs: parser.rs, size: revisions[index].End}, nil
+}
+
+// limitedReadSeeker reads the first `size` bytes of `rs`. The position of `rs` is set before each
+// read, so that `rs` can be shared.
+type limitedReadSeeker struct {
+	rs   io.ReadSeeker
+	size int64
+	pos  int64
+}
+
+// Read implements io.Reader.
+func (l *limitedReadSeeker) Read(p []byte) (int, error) {
+	if l.pos >= l.size {
+		return 0, io.EOF
+	}
+	if remaining := l.size - l.pos; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	if _, err := l.rs.Seek(l.pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := l.rs.Read(p)
+	l.pos += int64(n)
+	return n, err
+}
+
+// Seek implements io.Seeker.
+func (l *limitedReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += l.pos
+	case io.SeekEnd:
+		offset += l.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	l.pos = offset
+	return offset, nil
+}
@@ -0,0 +1,112 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// appendRevision appends an incremental update with objects `objects` (object number to object
+// data) and free objects `free` to `buf`, with previous xref section at `prev` (-1 for none).
+// Returns the offset of the xref section.
+func appendRevision(buf *bytes.Buffer, objects map[int]string, free []int, prev int) int {
+	offsets := map[int]int{}
+	for num := 1; num <= 10; num++ {
+		if data, ok := objects[num]; ok {
+			offsets[num] = buf.Len()
+			fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", num, data)
+		}
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n0 1\n0000000000 65535 f \n")
+	for num := 1; num <= 10; num++ {
+		if off, ok := offsets[num]; ok {
+			fmt.Fprintf(buf, "%d 1\n%010d 00000 n \n", num, off)
+		}
+	}
+	for _, num := range free {
+		fmt.Fprintf(buf, "%d 1\n0000000000 00001 f \n", num)
+	}
+	buf.WriteString("trailer\n<< /Size 11 /Root 1 0 R")
+	if prev >= 0 {
+		fmt.Fprintf(buf, " /Prev %d", prev)
+	}
+	fmt.Fprintf(buf, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return xref
+}
+
+func TestRevisions(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	xref0 := appendRevision(&buf, map[int]string{
+		1: "<< /Type /Catalog /Pages 2 0 R >>",
+		2: "<< /Type /Pages /Kids [] /Count 0 >>",
+		3: "(original)",
+	}, nil, -1)
+	end0 := buf.Len()
+	xref1 := appendRevision(&buf, map[int]string{3: "(changed)", 4: "(added)"}, nil, xref0)
+	end1 := buf.Len()
+	appendRevision(&buf, nil, []int{4}, xref1)
+
+	parser, err := NewParser(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	revisions, err := parser.GetRevisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	require.Equal(t, int64(0), revisions[0].Offset)
+	require.Equal(t, int64(end0), revisions[0].End)
+	require.Equal(t, int64(xref0), revisions[0].XrefOffset)
+	require.Nil(t, revisions[0].Trailer.Get("Prev"))
+	require.Equal(t, int64(end0), revisions[1].Offset)
+	require.Equal(t, int64(end1), revisions[1].End)
+	require.Equal(t, int64(buf.Len()), revisions[2].End)
+	for i, rev := range revisions {
+		require.Equal(t, i, rev.Index)
+	}
+
+	changes, err := parser.CompareRevisions(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []int{4}, changes.Added)
+	require.Equal(t, []int{3}, changes.Changed)
+	require.Empty(t, changes.Removed)
+
+	changes, err = parser.CompareRevisions(1, 2)
+	require.NoError(t, err)
+	require.Empty(t, changes.Added)
+	require.Empty(t, changes.Changed)
+	require.Equal(t, []int{4}, changes.Removed)
+
+	_, err = parser.CompareRevisions(0, 3)
+	require.Equal(t, ErrRangeError, err)
+
+	// The reader of the first revision contains the original object only.
+	rs, err := parser.RevisionReader(0)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(rs)
+	require.NoError(t, err)
+	require.Equal(t, buf.Bytes()[:end0], data)
+
+	rs, err = parser.RevisionReader(0)
+	require.NoError(t, err)
+	original, err := NewParser(rs)
+	require.NoError(t, err)
+	obj, err := original.LookupByNumber(3)
+	require.NoError(t, err)
+	require.Equal(t, "original", obj.(*PdfIndirectObject).PdfObject.(*PdfObjectString).Str())
+	obj, err = original.LookupByNumber(4)
+	require.NoError(t, err)
+	require.IsType(t, &PdfObjectNull{}, obj)
+
+	// The parser of the full file is not affected.
+	obj, err = parser.LookupByNumber(3)
+	require.NoError(t, err)
+	require.Equal(t, "changed", obj.(*PdfIndirectObject).PdfObject.(*PdfObjectString).Str())
+}
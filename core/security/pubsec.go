@@ -0,0 +1,190 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+
+	"github.com/unidoc/pkcs7"
+
+	"github.com/carmel/unipdf/common"
+)
+
+// Public-key security handler sub-filters (7.6.5.2, Table 23).
+const (
+	// SubFilterPKCS7S4 is used with document-wide encryption keys (V=1 or 2).
+	SubFilterPKCS7S4 = "adbe.pkcs7.s4"
+	// SubFilterPKCS7S5 is used with crypt filters (V=4 or 5).
+	SubFilterPKCS7S5 = "adbe.pkcs7.s5"
+)
+
+// pubKeySeedLen is the length of the random seed that is shared with recipients.
+const pubKeySeedLen = 20
+
+var _ PubKeyHandler = pubKeyHandler{}
+
+// PubKeyHandler is an interface for public-key security handlers.
+type PubKeyHandler interface {
+	// GenerateParams generates a random file encryption key of keyLen bytes and fills the Recipients
+	// field with one PKCS#7 enveloped data object for each distinct set of recipient permissions.
+	// It assumes that EncryptMetadata is already set.
+	GenerateParams(d *PubKeyEncryptDict, recipients []Recipient, keyLen int) ([]byte, error)
+
+	// Authenticate uses the recipient certificate and private key to open one of the PKCS#7 objects
+	// and calculates the document encryption key. It also returns permissions granted to the recipient.
+	// In case of failed authentication, it returns empty key and zero permissions with no error.
+	Authenticate(d *PubKeyEncryptDict, keyLen int, cert *x509.Certificate, pkey crypto.PrivateKey) ([]byte, Permissions, error)
+}
+
+// Recipient is a document recipient for the public-key security handler.
+type Recipient struct {
+	// Certificate is an X.509 certificate of the recipient. Only RSA keys are supported.
+	Certificate *x509.Certificate
+	// Permissions granted to the recipient.
+	Permissions Permissions
+}
+
+// PubKeyEncryptDict is a set of additional fields used in public-key encryption dictionaries
+// and crypt filters (7.6.5, Table 24 and Table 27).
+type PubKeyEncryptDict struct {
+	SubFilter       string
+	EncryptMetadata bool // Indicates whether the document-level metadata stream shall be encrypted.
+
+	// set by security handlers:
+
+	Recipients [][]byte // DER-encoded PKCS#7 enveloped data objects.
+	P          Permissions
+}
+
+// NewHandlerPubKey creates a new public-key security handler (Adobe.PubSec).
+func NewHandlerPubKey() PubKeyHandler {
+	return pubKeyHandler{}
+}
+
+// pubKeyHandler is an implementation of the public-key security handler.
+type pubKeyHandler struct{}
+
+// pkcs7Mu guards the package-level content encryption algorithm of the pkcs7 package.
+var pkcs7Mu sync.Mutex
+
+// envelope encrypts the content for a set of recipients using AES-256 as a content encryption algorithm.
+func envelope(content []byte, certs []*x509.Certificate) ([]byte, error) {
+	pkcs7Mu.Lock()
+	defer pkcs7Mu.Unlock()
+	prev := pkcs7.ContentEncryptionAlgorithm
+	pkcs7.ContentEncryptionAlgorithm = pkcs7.EncryptionAlgorithmAES256CBC
+	defer func() {
+		pkcs7.ContentEncryptionAlgorithm = prev
+	}()
+	return pkcs7.Encrypt(content, certs)
+}
+
+// GenerateParams implements PubKeyHandler interface.
+func (sh pubKeyHandler) GenerateParams(d *PubKeyEncryptDict, recipients []Recipient, keyLen int) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients specified")
+	}
+	seed := make([]byte, pubKeySeedLen)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	// Recipients sharing the same permissions are grouped into a single PKCS#7 object.
+	var (
+		order  []Permissions
+		groups = make(map[Permissions][]*x509.Certificate)
+	)
+	for i, r := range recipients {
+		if r.Certificate == nil {
+			return nil, fmt.Errorf("recipient %d: missing certificate", i)
+		}
+		if _, ok := r.Certificate.PublicKey.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("recipient %d: unsupported public key type %T", i, r.Certificate.PublicKey)
+		}
+		if _, ok := groups[r.Permissions]; !ok {
+			order = append(order, r.Permissions)
+		}
+		groups[r.Permissions] = append(groups[r.Permissions], r.Certificate)
+	}
+
+	d.Recipients = d.Recipients[:0]
+	for _, perm := range order {
+		// 20 bytes of the seed followed by 4 bytes of permissions (high-order byte first).
+		content := make([]byte, pubKeySeedLen+4)
+		copy(content, seed)
+		binary.BigEndian.PutUint32(content[pubKeySeedLen:], uint32(perm))
+
+		env, err := envelope(content, groups[perm])
+		if err != nil {
+			return nil, err
+		}
+		d.Recipients = append(d.Recipients, env)
+	}
+	d.P = PermOwner
+	return sh.makeKey(d, seed, keyLen)
+}
+
+// Authenticate implements PubKeyHandler interface.
+func (sh pubKeyHandler) Authenticate(d *PubKeyEncryptDict, keyLen int, cert *x509.Certificate, pkey crypto.PrivateKey) ([]byte, Permissions, error) {
+	if cert == nil || pkey == nil {
+		return nil, 0, errors.New("certificate and private key must be specified")
+	}
+	for i, r := range d.Recipients {
+		p7, err := pkcs7.Parse(r)
+		if err != nil {
+			common.Log.Debug("Invalid recipient %d: %v", i, err)
+			continue
+		}
+		content, err := p7.Decrypt(cert, pkey)
+		if err != nil {
+			common.Log.Trace("Recipient %d: %v", i, err)
+			continue
+		}
+		if err = checkAtLeast("Authenticate", "Recipients", pubKeySeedLen+4, content); err != nil {
+			return nil, 0, err
+		}
+		perm := Permissions(binary.BigEndian.Uint32(content[pubKeySeedLen:]))
+		key, err := sh.makeKey(d, content[:pubKeySeedLen], keyLen)
+		if err != nil {
+			return nil, 0, err
+		}
+		return key, perm, nil
+	}
+	return nil, 0, nil
+}
+
+// makeKey computes the file encryption key from the seed and all recipient objects.
+// 7.6.5.3 Public-Key Encryption Algorithms (page 90)
+func (pubKeyHandler) makeKey(d *PubKeyEncryptDict, seed []byte, keyLen int) ([]byte, error) {
+	var h hash.Hash
+	if keyLen > sha1.Size {
+		// AES-256 (ISO 32000-2) uses SHA-256 instead of SHA-1.
+		h = sha256.New()
+	} else {
+		h = sha1.New()
+	}
+	if keyLen > h.Size() {
+		return nil, fmt.Errorf("unsupported key length: %d", keyLen)
+	}
+	h.Write(seed)
+	for _, r := range d.Recipients {
+		h.Write(r)
+	}
+	if !d.EncryptMetadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	return h.Sum(nil)[:keyLen], nil
+}
@@ -0,0 +1,93 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package security
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestRecipient(t *testing.T, serial int64) (*x509.Certificate, *rsa.PrivateKey) {
+	pkey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "recipient"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &pkey.PublicKey, pkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, pkey
+}
+
+func TestPubKeyHandler(t *testing.T) {
+	cert1, key1 := newTestRecipient(t, 1)
+	cert2, key2 := newTestRecipient(t, 2)
+	cert3, key3 := newTestRecipient(t, 3)
+
+	const (
+		perms1 = PermPrinting | PermFillForms
+		perms2 = PermOwner
+	)
+
+	for _, keyLen := range []int{16, 32} {
+		for _, encMeta := range []bool{true, false} {
+			h := NewHandlerPubKey()
+			d := &PubKeyEncryptDict{SubFilter: SubFilterPKCS7S5, EncryptMetadata: encMeta}
+			ekey, err := h.GenerateParams(d, []Recipient{
+				{Certificate: cert1, Permissions: perms1},
+				{Certificate: cert2, Permissions: perms2},
+			}, keyLen)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(ekey) != keyLen {
+				t.Fatalf("unexpected key length: %d", len(ekey))
+			} else if len(d.Recipients) != 2 {
+				t.Fatalf("expected a PKCS#7 object per permission set, got %d", len(d.Recipients))
+			}
+
+			for i, c := range []struct {
+				cert  *x509.Certificate
+				key   *rsa.PrivateKey
+				perms Permissions
+			}{
+				{cert1, key1, perms1},
+				{cert2, key2, perms2},
+			} {
+				fkey, perm, err := h.Authenticate(d, keyLen, c.cert, c.key)
+				if err != nil {
+					t.Fatalf("recipient %d: %v", i, err)
+				} else if !bytes.Equal(ekey, fkey) {
+					t.Fatalf("recipient %d: wrong encryption key", i)
+				} else if perm != c.perms {
+					t.Fatalf("recipient %d: wrong permissions: %x vs %x", i, perm, c.perms)
+				}
+			}
+
+			fkey, perm, err := h.Authenticate(d, keyLen, cert3, key3)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(fkey) != 0 || perm != 0 {
+				t.Fatal("unknown recipient was able to authenticate")
+			}
+		}
+	}
+}
@@ -74,6 +74,9 @@ func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
 		return newJBIG2DecoderFromStream(streamObj, nil)
 	case StreamEncodingFilterNameJPX:
 		return NewJPXEncoder(), nil
+	case StreamEncodingFilterNameCrypt:
+		// The data is decrypted by the security handler when the stream is loaded.
+		return NewRawEncoder(), nil
 	}
 	common.Log.Debug("ERROR: Unsupported encoding method!")
 	return nil, fmt.Errorf("unsupported encoding method (%s)", *method)
@@ -84,6 +87,12 @@ func NewEncoderFromStream(streamObj *PdfObjectStream) (StreamEncoder, error) {
 func DecodeStream(streamObj *PdfObjectStream) ([]byte, error) {
 	common.Log.Trace("Decode stream")
 
+	if streamObj.crypter != nil {
+		if err := streamObj.crypter.decryptDeferred(streamObj); err != nil {
+			common.Log.Debug("ERROR: Stream decryption failed: %v", err)
+			return nil, err
+		}
+	}
 	encoder, err := NewEncoderFromStream(streamObj)
 	if err != nil {
 		common.Log.Debug("ERROR: Stream decoding failed: %v", err)
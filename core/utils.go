@@ -43,6 +43,9 @@ func (parser *PdfParser) Inspect() (map[string]int, error) {
 
 // GetObjectNums returns a sorted list of object numbers of the PDF objects in the file.
 func (parser *PdfParser) GetObjectNums() []int {
+	parser.mu.Lock()
+	defer parser.mu.Unlock()
+
 	var objNums []int
 	for _, x := range parser.xrefs.ObjectMap {
 		objNums = append(objNums, x.ObjectNumber)
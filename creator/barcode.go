@@ -0,0 +1,571 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"errors"
+	goimage "image"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/datamatrix"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/pdf417"
+	"github.com/boombuler/barcode/qr"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/model"
+)
+
+// BarcodeType represents the symbology of a barcode.
+type BarcodeType int
+
+// Barcode types.
+const (
+	// BarcodeQR is a QR Code, see NewQRCode for the error correction levels.
+	BarcodeQR BarcodeType = iota
+
+	// BarcodeDataMatrix is an ECC 200 Data Matrix.
+	BarcodeDataMatrix
+
+	// BarcodePDF417 is a PDF417 barcode, with error correction level 2. Its rows are 3 modules
+	// high by default, see Barcode.SetRowHeight.
+	BarcodePDF417
+
+	// BarcodeCode128 is a Code 128 barcode.
+	BarcodeCode128
+
+	// BarcodeCode39 is a Code 39 barcode, in the full ASCII mode and without check digit.
+	BarcodeCode39
+
+	// BarcodeEAN13 is an EAN-13 barcode, of 12 digits and the check digit, which is computed when
+	// omitted.
+	BarcodeEAN13
+
+	// BarcodeUPCA is a UPC-A barcode, of 11 digits and the check digit, which is computed when
+	// omitted.
+	BarcodeUPCA
+
+	// BarcodeGS1128 is a GS1-128 barcode. The data is made of element strings, application
+	// identifiers in parentheses followed by their values, e.g. "(01)09501101530003(17)251231".
+	BarcodeGS1128
+)
+
+// QRErrorCorrectionLevel represents the error correction level of a QR Code, the proportion of the
+// code words which can be restored.
+type QRErrorCorrectionLevel int
+
+// QR Code error correction levels.
+const (
+	QRErrorCorrectionL QRErrorCorrectionLevel = iota // About 7%.
+	QRErrorCorrectionM                               // About 15%.
+	QRErrorCorrectionQ                               // About 25%.
+	QRErrorCorrectionH                               // About 30%.
+)
+
+// Barcode is a drawable barcode, drawn as vector paths. Its size is set by the width of the
+// modules, the narrowest bars and spaces of linear barcodes and the squares of matrix barcodes,
+// and the height of the bars of linear barcodes. The barcode is surrounded by a quiet zone, and
+// linear barcodes are drawn with their human-readable text below the bars.
+type Barcode struct {
+	kind BarcodeType
+
+	// Rows of the modules of the barcode, one row for linear barcodes, true for the dark modules.
+	modules [][]bool
+
+	// Width of the modules, height of the bars of linear barcodes, height of the rows of matrix
+	// and stacked barcodes and width of the quiet zone in modules.
+	moduleWidth float64
+	barHeight   float64
+	rowHeight   float64
+	quietZone   int
+
+	// Colors of the dark modules and of the background, nil for a transparent background.
+	color      Color
+	background Color
+
+	// Human-readable text drawn below the barcode, and its style.
+	text      string
+	textStyle TextStyle
+
+	// Digits of EAN-13 and UPC-A barcodes, check digit included, drawn in groups when they are
+	// the text.
+	digits string
+
+	// Positioning: relative / absolute.
+	positioning positioning
+
+	// Absolute coordinates (when in absolute mode).
+	xPos float64
+	yPos float64
+
+	// Margins to be applied around the block when drawing on Page.
+	margins margins
+
+	// Horizontal alignment of the barcode, when in relative mode.
+	hAlignment HorizontalAlignment
+}
+
+// newBarcode creates a barcode of type `kind` encoding `data`, the QR Codes with the error
+// correction level `level`.
+func newBarcode(kind BarcodeType, data string, level QRErrorCorrectionLevel, style TextStyle) (*Barcode, error) {
+	b := &Barcode{
+		kind:        kind,
+		moduleWidth: 1,
+		barHeight:   50,
+		rowHeight:   1,
+		color:       ColorBlack,
+		textStyle:   style,
+	}
+
+	var code barcode.Barcode
+	var err error
+	switch kind {
+	case BarcodeQR:
+		levels := []qr.ErrorCorrectionLevel{qr.L, qr.M, qr.Q, qr.H}
+		if level < 0 || int(level) >= len(levels) {
+			return nil, errors.New("invalid QR Code error correction level")
+		}
+		code, err = qr.Encode(data, levels[level], qr.Auto)
+		b.moduleWidth = 2
+		b.quietZone = 4
+	case BarcodeDataMatrix:
+		code, err = datamatrix.Encode(data)
+		b.moduleWidth = 2
+		b.quietZone = 1
+	case BarcodePDF417:
+		code, err = pdf417.Encode(data, 2)
+		b.quietZone = 2
+		b.rowHeight = 3
+	case BarcodeCode128:
+		code, err = code128.Encode(data)
+		b.quietZone = 10
+		b.text = data
+	case BarcodeCode39:
+		code, err = code39.Encode(data, false, true)
+		b.quietZone = 10
+		b.text = data
+	case BarcodeEAN13:
+		code, err = encodeEAN(data, 12)
+		b.quietZone = 11
+	case BarcodeUPCA:
+		code, err = encodeEAN("0"+data, 12)
+		b.quietZone = 9
+	case BarcodeGS1128:
+		var content string
+		if content, b.text, err = gs1Content(data); err == nil {
+			code, err = code128.Encode(content)
+		}
+		b.quietZone = 10
+	default:
+		err = errors.New("unsupported barcode type")
+	}
+	if err != nil {
+		common.Log.Debug("ERROR: unable to encode barcode: %v", err)
+		return nil, err
+	}
+
+	if kind == BarcodeEAN13 || kind == BarcodeUPCA {
+		// The check digit is part of the text.
+		b.digits = code.Content()
+		if kind == BarcodeUPCA {
+			b.digits = b.digits[1:]
+		}
+		b.text = b.digits
+	}
+	b.modules = barcodeModules(code)
+	if kind == BarcodePDF417 {
+		// The encoder draws each row twice, the rows are drawn at the row height instead.
+		rows := b.modules[:0]
+		for r := 0; r < len(b.modules); r += 2 {
+			rows = append(rows, b.modules[r])
+		}
+		b.modules = rows
+	}
+	return b, nil
+}
+
+// encodeEAN encodes the EAN-13 `data` of `digits` digits and the optional check digit.
+func encodeEAN(data string, digits int) (barcode.Barcode, error) {
+	if len(data) != digits && len(data) != digits+1 {
+		return nil, errors.New("invalid number of digits")
+	}
+	for _, r := range data {
+		if r < '0' || r > '9' {
+			return nil, errors.New("invalid digit")
+		}
+	}
+	return ean.Encode(data)
+}
+
+// gs1FixedLengths are the first two digits of the application identifiers of the GS1 element
+// strings of predefined length, which are not terminated by a separator.
+var gs1FixedLengths = map[string]bool{
+	"00": true, "01": true, "02": true, "03": true, "04": true, "11": true, "12": true, "13": true,
+	"14": true, "15": true, "16": true, "17": true, "18": true, "19": true, "20": true, "31": true,
+	"32": true, "33": true, "34": true, "35": true, "36": true, "41": true,
+}
+
+// gs1Content returns the content of the Code 128 barcode encoding the GS1 element strings `data`,
+// in which the application identifiers are in parentheses, and the human-readable text.
+func gs1Content(data string) (string, string, error) {
+	content := []rune{code128.FNC1}
+	var text strings.Builder
+	separate := false
+	for rest := data; rest != ""; {
+		end := strings.IndexByte(rest, ')')
+		if rest[0] != '(' || end < 0 {
+			return "", "", errors.New("GS1 application identifier not in parentheses")
+		}
+		ai := rest[1:end]
+		if len(ai) < 2 || len(ai) > 4 || strings.Trim(ai, "0123456789") != "" {
+			return "", "", errors.New("invalid GS1 application identifier")
+		}
+		rest = rest[end+1:]
+
+		next := strings.IndexByte(rest, '(')
+		if next < 0 {
+			next = len(rest)
+		}
+		value := rest[:next]
+		rest = rest[next:]
+		if value == "" {
+			return "", "", errors.New("empty GS1 element string value")
+		}
+
+		if separate {
+			content = append(content, code128.FNC1)
+		}
+		content = append(content, []rune(ai+value)...)
+		text.WriteString("(" + ai + ")" + value)
+		separate = !gs1FixedLengths[ai[:2]]
+	}
+	if len(content) == 1 {
+		return "", "", errors.New("empty GS1 data")
+	}
+	return string(content), text.String(), nil
+}
+
+// barcodeModules returns the rows of the modules of the barcode `code`, true for the dark modules.
+func barcodeModules(code goimage.Image) [][]bool {
+	bounds := code.Bounds()
+	modules := make([][]bool, 0, bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := make([]bool, 0, bounds.Dx())
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := code.At(x, y).RGBA()
+			row = append(row, r+g+b < 3*0x8000)
+		}
+		modules = append(modules, row)
+	}
+	return modules
+}
+
+// isLinear returns whether the barcode is a linear barcode, of one row of bars.
+func (b *Barcode) isLinear() bool {
+	return len(b.modules) == 1
+}
+
+// SetModuleWidth sets the width of the modules of the barcode, the narrowest bars and spaces of
+// linear barcodes and the squares of matrix barcodes. Defaults to 1 point, and 2 points for QR
+// Codes and Data Matrix.
+func (b *Barcode) SetModuleWidth(width float64) {
+	b.moduleWidth = width
+}
+
+// ModuleWidth returns the width of the modules of the barcode.
+func (b *Barcode) ModuleWidth() float64 {
+	return b.moduleWidth
+}
+
+// ScaleToWidth sets the width of the modules of the barcode so that the barcode, with its quiet
+// zones, is `width` wide.
+func (b *Barcode) ScaleToWidth(width float64) {
+	b.moduleWidth = width / float64(len(b.modules[0])+2*b.quietZone)
+}
+
+// SetBarHeight sets the height of the bars of linear barcodes (50 points by default). The height
+// of matrix barcodes is set by the width of their modules, and by the row height for PDF417.
+func (b *Barcode) SetBarHeight(height float64) {
+	b.barHeight = height
+}
+
+// SetRowHeight sets the height of the rows of PDF417 barcodes, in modules. Defaults to 3, the
+// minimum allowed by ISO/IEC 15438. The rows of the other matrix barcodes are 1 module high.
+func (b *Barcode) SetRowHeight(modules float64) {
+	if b.kind == BarcodePDF417 {
+		b.rowHeight = modules
+	}
+}
+
+// SetQuietZone sets the width of the quiet zone around the barcode, in modules. The quiet zone is
+// on the sides of linear barcodes, and on all sides of matrix barcodes.
+func (b *Barcode) SetQuietZone(modules int) {
+	b.quietZone = modules
+}
+
+// SetColor sets the color of the bars and dark modules of the barcode.
+func (b *Barcode) SetColor(color Color) {
+	b.color = color
+}
+
+// SetBackgroundColor sets the color of the background of the barcode, quiet zone and text
+// included. The background is transparent by default.
+func (b *Barcode) SetBackgroundColor(color Color) {
+	b.background = color
+}
+
+// SetText sets the human-readable text drawn below the barcode. Defaults to the data of linear
+// barcodes, with the check digit of EAN-13 and UPC-A barcodes; the text of matrix barcodes is not
+// drawn by default. An empty text is not drawn.
+func (b *Barcode) SetText(text string) {
+	b.text = text
+}
+
+// Text returns the human-readable text drawn below the barcode.
+func (b *Barcode) Text() string {
+	return b.text
+}
+
+// SetTextStyle sets the style of the human-readable text.
+func (b *Barcode) SetTextStyle(style TextStyle) {
+	b.textStyle = style
+}
+
+// SetPos sets the absolute position of the upper left corner of the barcode. Changes object
+// positioning to absolute.
+func (b *Barcode) SetPos(x, y float64) {
+	b.positioning = positionAbsolute
+	b.xPos = x
+	b.yPos = y
+}
+
+// SetMargins sets the margins of the barcode.
+func (b *Barcode) SetMargins(left, right, top, bottom float64) {
+	b.margins.left = left
+	b.margins.right = right
+	b.margins.top = top
+	b.margins.bottom = bottom
+}
+
+// GetMargins returns the margins of the barcode: left, right, top, bottom.
+func (b *Barcode) GetMargins() (float64, float64, float64, float64) {
+	return b.margins.left, b.margins.right, b.margins.top, b.margins.bottom
+}
+
+// SetHorizontalAlignment sets the horizontal alignment of the barcode.
+func (b *Barcode) SetHorizontalAlignment(alignment HorizontalAlignment) {
+	b.hAlignment = alignment
+}
+
+// quietWidth returns the width of the quiet zone.
+func (b *Barcode) quietWidth() float64 {
+	return float64(b.quietZone) * b.moduleWidth
+}
+
+// symbolHeight returns the height of the bars or modules of the barcode, quiet zone included.
+func (b *Barcode) symbolHeight() float64 {
+	if b.isLinear() {
+		return b.barHeight
+	}
+	return float64(len(b.modules))*b.rowHeight*b.moduleWidth + 2*b.quietWidth()
+}
+
+// textGap returns the space between the barcode and its text.
+func (b *Barcode) textGap() float64 {
+	return b.textStyle.FontSize / 4
+}
+
+// Width returns the width of the barcode, quiet zone included.
+func (b *Barcode) Width() float64 {
+	return float64(len(b.modules[0]))*b.moduleWidth + 2*b.quietWidth()
+}
+
+// Height returns the height of the barcode, quiet zone and text included.
+func (b *Barcode) Height() float64 {
+	height := b.symbolHeight()
+	if b.text != "" {
+		height += b.textGap() + b.textStyle.FontSize
+	}
+	return height
+}
+
+// GeneratePageBlocks draws the barcode on a block, implementing the Drawable interface.
+func (b *Barcode) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
+	var blocks []*Block
+	origCtx := ctx
+
+	blk := NewBlock(ctx.PageWidth, ctx.PageHeight)
+	width, height := b.Width(), b.Height()
+	if b.positioning.isRelative() {
+		if height > ctx.Height {
+			// Draw on the next page.
+			blocks = append(blocks, blk)
+			blk = NewBlock(ctx.PageWidth, ctx.PageHeight)
+
+			ctx.Page++
+			newContext := ctx
+			newContext.Y = ctx.Margins.top
+			newContext.X = ctx.Margins.left + b.margins.left
+			newContext.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - b.margins.bottom
+			newContext.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - b.margins.left - b.margins.right
+			ctx = newContext
+		} else {
+			ctx.Y += b.margins.top
+			ctx.Height -= b.margins.top + b.margins.bottom
+			ctx.X += b.margins.left
+			ctx.Width -= b.margins.left + b.margins.right
+		}
+	} else {
+		ctx.X = b.xPos
+		ctx.Y = b.yPos
+	}
+
+	x := ctx.X
+	if b.positioning.isRelative() {
+		switch b.hAlignment {
+		case HorizontalAlignmentCenter:
+			x += (ctx.Width - width) / 2
+		case HorizontalAlignmentRight:
+			x += ctx.Width - width
+		}
+	}
+	if err := b.drawOnBlock(blk, x, ctx.Y); err != nil {
+		return nil, ctx, err
+	}
+	blocks = append(blocks, blk)
+
+	if b.positioning.isAbsolute() {
+		return blocks, origCtx, nil
+	}
+	ctx.X = origCtx.X
+	ctx.Width = origCtx.Width
+	ctx.Y += height + b.margins.bottom
+	ctx.Height -= height + b.margins.bottom
+	return blocks, ctx, nil
+}
+
+// drawOnBlock draws the barcode on `blk`, its upper left corner at (x, y).
+func (b *Barcode) drawOnBlock(blk *Block, x, y float64) error {
+	pageHeight := blk.height
+	mw := b.moduleWidth
+	quiet := b.quietWidth()
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q()
+	if b.background != nil {
+		cc.SetNonStrokingColor(model.NewPdfColorDeviceRGB(b.background.ToRGB()))
+		cc.Add_re(x, pageHeight-y-b.Height(), b.Width(), b.Height()).Add_f()
+	}
+
+	// The bars and the runs of dark modules of the rows.
+	top, rowHeight := y, b.rowHeight*mw
+	if b.isLinear() {
+		rowHeight = b.barHeight
+	} else {
+		top += quiet
+	}
+	cc.SetNonStrokingColor(model.NewPdfColorDeviceRGB(b.color.ToRGB()))
+	for r, row := range b.modules {
+		for start := 0; start < len(row); start++ {
+			if !row[start] {
+				continue
+			}
+			end := start
+			for end < len(row) && row[end] {
+				end++
+			}
+			h := rowHeight
+			if b.isLinear() && b.text != "" && b.isGuardModule(start) {
+				// The guard bars extend between the digits.
+				h += b.textGap() + b.textStyle.FontSize/2
+			}
+			rowTop := top + float64(r)*rowHeight
+			cc.Add_re(x+quiet+float64(start)*mw, pageHeight-rowTop-h, float64(end-start)*mw, h)
+			start = end
+		}
+	}
+	cc.Add_f()
+	cc.Add_Q()
+	blk.addContents(cc.Operations())
+
+	if b.text == "" {
+		return nil
+	}
+	return b.drawText(blk, x, y+b.symbolHeight()+b.textGap())
+}
+
+// isGuardModule returns whether the module at column `col` is part of the guard bars of EAN-13
+// barcodes, or the bars of the first and last digits of UPC-A barcodes, which are extended.
+func (b *Barcode) isGuardModule(col int) bool {
+	switch b.kind {
+	case BarcodeEAN13:
+		return col < 3 || (col >= 45 && col < 50) || col >= 92
+	case BarcodeUPCA:
+		return col < 10 || (col >= 45 && col < 50) || col >= 85
+	}
+	return false
+}
+
+// drawText draws the human-readable text of the barcode on `blk`, from the top `y`.
+func (b *Barcode) drawText(blk *Block, x, y float64) error {
+	mw := b.moduleWidth
+	quiet := b.quietWidth()
+	symbolX := x + quiet
+	switch {
+	case b.kind == BarcodeEAN13 && b.text == b.digits:
+		// The first digit in the quiet zone, and the digits in groups between the guard bars.
+		return b.drawTextParts(blk, y, []string{b.text[:1], b.text[1:7], b.text[7:]}, []float64{
+			x, symbolX - mw,
+			symbolX + 3*mw, symbolX + 45*mw,
+			symbolX + 50*mw, symbolX + 92*mw,
+		})
+	case b.kind == BarcodeUPCA && b.text == b.digits:
+		// The first and last digits in the quiet zones, and the digits in groups between the
+		// bars of the first and last digits.
+		return b.drawTextParts(blk, y, []string{b.text[:1], b.text[1:6], b.text[6:11], b.text[11:]}, []float64{
+			x, symbolX - mw,
+			symbolX + 10*mw, symbolX + 45*mw,
+			symbolX + 50*mw, symbolX + 85*mw,
+			symbolX + 96*mw, x + b.Width(),
+		})
+	}
+	return b.drawTextParts(blk, y, []string{b.text}, []float64{symbolX, symbolX + b.Width() - 2*quiet})
+}
+
+// drawTextParts draws the `parts` of the text on `blk` from the top `y`, each part centered in
+// the horizontal interval of `bounds` of its index.
+func (b *Barcode) drawTextParts(blk *Block, y float64, parts []string, bounds []float64) error {
+	for i, part := range parts {
+		p := newStyledParagraph(b.textStyle)
+		p.SetEnableWrap(false)
+		p.Append(part)
+		width := p.Width()
+		p.SetWidth(width)
+		p.SetPos((bounds[2*i]+bounds[2*i+1]-width)/2, y)
+
+		ctx := DrawContext{
+			Page:       1,
+			Width:      width,
+			Height:     blk.height,
+			PageWidth:  blk.width,
+			PageHeight: blk.height,
+		}
+		blocks, _, err := p.GeneratePageBlocks(ctx)
+		if err != nil {
+			return err
+		}
+		for _, textBlk := range blocks {
+			if err := blk.mergeBlocks(textBlk); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/boombuler/barcode/code128"
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+func TestBarcodeTypes(t *testing.T) {
+	testcases := []struct {
+		kind BarcodeType
+		data string
+		text string
+	}{
+		{BarcodeQR, "https://example.com/parcel/123456", ""},
+		{BarcodeDataMatrix, "PARCEL 123456", ""},
+		{BarcodePDF417, "PARCEL 123456", ""},
+		{BarcodeCode128, "Parcel-123456", "Parcel-123456"},
+		{BarcodeCode39, "PARCEL 123456", "PARCEL 123456"},
+		{BarcodeEAN13, "400638133393", "4006381333931"},
+		{BarcodeEAN13, "4006381333931", "4006381333931"},
+		{BarcodeUPCA, "03600029145", "036000291452"},
+		{BarcodeGS1128, "(01)09501101530003(10)AB12(17)251231", "(01)09501101530003(10)AB12(17)251231"},
+	}
+
+	c := New()
+	for _, tc := range testcases {
+		b, err := c.NewBarcode(tc.kind, tc.data)
+		require.NoError(t, err)
+		require.Equal(t, tc.text, b.Text())
+
+		// The size is set by the width of the modules and the quiet zone.
+		b.SetModuleWidth(0.5)
+		b.SetQuietZone(4)
+		columns := len(b.modules[0])
+		require.InDelta(t, float64(columns+8)*0.5, b.Width(), 1e-9)
+		if b.isLinear() {
+			b.SetBarHeight(30)
+			require.InDelta(t, 30+1.25*b.textStyle.FontSize, b.Height(), 1e-9)
+		} else {
+			require.InDelta(t, (float64(len(b.modules))*b.rowHeight+8)*0.5, b.Height(), 1e-9)
+		}
+		b.ScaleToWidth(200)
+		require.InDelta(t, 200, b.Width(), 1e-9)
+
+		b.SetMargins(0, 0, 0, 10)
+		require.NoError(t, c.Draw(b))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := r.GetNumPages()
+	require.NoError(t, err)
+
+	// The bars are drawn as rectangles, with the human-readable text below them.
+	var text string
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		require.NoError(t, err)
+		contents, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, contents, " re\n")
+
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		pageText, err := e.ExtractText()
+		require.NoError(t, err)
+		text += strings.Join(strings.Fields(pageText), "")
+	}
+	for _, tc := range testcases {
+		require.Contains(t, text, strings.ReplaceAll(tc.text, " ", ""))
+	}
+}
+
+func TestBarcodeModules(t *testing.T) {
+	c := New()
+
+	// The finder patterns of QR Codes are at the corners but the bottom right one.
+	b, err := c.NewQRCode("12345", QRErrorCorrectionH)
+	require.NoError(t, err)
+	size := len(b.modules)
+	require.Equal(t, size, len(b.modules[0]))
+	for _, corner := range [][2]int{{0, 0}, {0, size - 7}, {size - 7, 0}} {
+		for i := 0; i < 7; i++ {
+			require.True(t, b.modules[corner[0]][corner[1]+i])
+			require.True(t, b.modules[corner[0]+i][corner[1]])
+		}
+	}
+
+	// A higher error correction level needs more modules for the same data.
+	low, err := c.NewQRCode(strings.Repeat("12345", 10), QRErrorCorrectionL)
+	require.NoError(t, err)
+	high, err := c.NewQRCode(strings.Repeat("12345", 10), QRErrorCorrectionH)
+	require.NoError(t, err)
+	require.Greater(t, len(high.modules), len(low.modules))
+
+	// EAN-13 barcodes have 95 modules, starting and ending with guard bars.
+	b, err = c.NewBarcode(BarcodeEAN13, "400638133393")
+	require.NoError(t, err)
+	require.Len(t, b.modules, 1)
+	require.Len(t, b.modules[0], 95)
+	require.Equal(t, []bool{true, false, true}, b.modules[0][:3])
+	require.Equal(t, []bool{true, false, true}, b.modules[0][92:])
+}
+
+func TestBarcodePDF417Rows(t *testing.T) {
+	c := New()
+	b, err := c.NewBarcode(BarcodePDF417, "PARCEL 123456")
+	require.NoError(t, err)
+	b.SetModuleWidth(0.5)
+	b.SetQuietZone(0)
+
+	// The rows start with the same start pattern, and are 3 modules high by default.
+	rows := len(b.modules)
+	require.Greater(t, rows, 2)
+	for _, row := range b.modules {
+		require.Equal(t, b.modules[0][:17], row[:17])
+	}
+	require.InDelta(t, float64(rows)*1.5, b.Height(), 1e-9)
+	b.SetRowHeight(4)
+	require.InDelta(t, float64(rows)*2, b.Height(), 1e-9)
+	b.SetRowHeight(3)
+
+	// The bars of the rows are drawn at the row height.
+	blk := NewBlock(200, 200)
+	require.NoError(t, b.drawOnBlock(blk, 0, 0))
+	var heights []float64
+	for _, op := range *blk.contents {
+		if op.Operand != "re" {
+			continue
+		}
+		h, err := core.GetNumberAsFloat(op.Params[3])
+		require.NoError(t, err)
+		heights = append(heights, h)
+	}
+	require.NotEmpty(t, heights)
+	for _, h := range heights {
+		require.InDelta(t, 1.5, h, 1e-9)
+	}
+
+	// The row height only applies to PDF417 barcodes.
+	qr, err := c.NewQRCode("12345", QRErrorCorrectionM)
+	require.NoError(t, err)
+	qr.SetRowHeight(3)
+	require.InDelta(t, qr.Width(), qr.Height(), 1e-9)
+}
+
+func TestBarcodeGS1Content(t *testing.T) {
+	fnc1 := string(code128.FNC1)
+	content, text, err := gs1Content("(01)09501101530003(10)AB12(17)251231(21)XYZ")
+	require.NoError(t, err)
+
+	// The variable length values are followed by a separator, unless last.
+	require.Equal(t, fnc1+"0109501101530003"+"10AB12"+fnc1+"17251231"+"21XYZ", content)
+	require.Equal(t, "(01)09501101530003(10)AB12(17)251231(21)XYZ", text)
+
+	for _, data := range []string{"", "0109501101530003", "(01", "(A1)123", "(01)", "(01)1(17)"} {
+		_, _, err := gs1Content(data)
+		require.Error(t, err, data)
+	}
+}
+
+func TestBarcodeErrors(t *testing.T) {
+	c := New()
+	for _, tc := range []struct {
+		kind BarcodeType
+		data string
+	}{
+		{BarcodeEAN13, "40063813339"},
+		{BarcodeEAN13, "4006381333932"},
+		{BarcodeEAN13, "40063813339A"},
+		{BarcodeUPCA, "0360002914"},
+		{BarcodeCode128, "é"},
+		{BarcodeGS1128, "0109501101530003"},
+		{BarcodeType(-1), "123"},
+	} {
+		_, err := c.NewBarcode(tc.kind, tc.data)
+		require.Error(t, err, tc.data)
+	}
+
+	_, err := c.NewQRCode("123", QRErrorCorrectionLevel(4))
+	require.Error(t, err)
+}
+
+func TestBarcodeInTable(t *testing.T) {
+	c := New()
+	b, err := c.NewBarcode(BarcodeGS1128, "(00)123456789012345675")
+	require.NoError(t, err)
+	b.SetBarHeight(40)
+
+	// The rows are high enough for the barcodes.
+	table := c.NewTable(2)
+	require.NoError(t, table.NewCell().SetContent(c.NewParagraph("SSCC")))
+	require.NoError(t, table.NewCell().SetContent(b))
+	require.NoError(t, c.Draw(table))
+	require.GreaterOrEqual(t, table.Height(), b.Height())
+
+	div := c.NewDivision()
+	require.NoError(t, div.Add(b))
+	require.InDelta(t, b.Height(), div.Height(), 1e-9)
+}
@@ -86,6 +86,9 @@ func newChapter(parent *Chapter, toc *TOC, outline *model.Outline, title string,
 	p.SetFont(style.Font)
 	p.SetFontSize(style.FontSize)
 
+	// The heading is kept with the first contents of the chapter.
+	p.SetKeepWithNext(true)
+
 	chapter.heading = p
 	return chapter
 }
@@ -140,7 +143,7 @@ func (chap *Chapter) Add(d Drawable) error {
 	}
 
 	switch d.(type) {
-	case *Paragraph, *StyledParagraph, *Image, *Block, *Table, *PageBreak, *Chapter:
+	case *Paragraph, *StyledParagraph, *Image, *Block, *Table, *List, *Division, *PageBreak, *Chapter:
 		chap.contents = append(chap.contents, d)
 	default:
 		common.Log.Debug("Unsupported: %T", d)
@@ -193,10 +196,27 @@ func (chap *Chapter) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		ctx.Height -= chap.margins.top
 	}
 
-	blocks, c, err := chap.heading.GeneratePageBlocks(ctx)
+	// Start on the next page if the heading would be split from the contents kept with it.
+	var blocks []*Block
+	runEnd := 0
+	if chap.heading.KeepWithNext() {
+		run := append([]Drawable{chap.heading}, chap.contents...)
+		n, b, c, err := breakKeptRun(run, blocks, ctx)
+		if err != nil {
+			return blocks, ctx, err
+		}
+		blocks, ctx, runEnd = b, c, n-1
+	}
+
+	headingBlocks, c, err := chap.heading.GeneratePageBlocks(ctx)
 	if err != nil {
 		return blocks, ctx, err
 	}
+	if len(blocks) > 0 && len(headingBlocks) > 0 {
+		blocks[len(blocks)-1].mergeBlocks(headingBlocks[0])
+		headingBlocks = headingBlocks[1:]
+	}
+	blocks = append(blocks, headingBlocks...)
 	ctx = c
 
 	// Generate chapter title and number.
@@ -234,8 +254,16 @@ func (chap *Chapter) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		outlineDest.Y = posY
 	}
 
-	for _, d := range chap.contents {
-		newBlocks, c, err := d.GeneratePageBlocks(ctx)
+	for i, d := range chap.contents {
+		if i >= runEnd {
+			n, b, c, err := breakKeptRun(chap.contents[i:], blocks, ctx)
+			if err != nil {
+				return blocks, ctx, err
+			}
+			blocks, ctx, runEnd = b, c, i+n
+		}
+
+		newBlocks, c, err := generateKeptBlocks(d, ctx)
 		if err != nil {
 			return blocks, ctx, err
 		}
@@ -0,0 +1,697 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"math"
+
+	"github.com/carmel/unipdf/contentstream/draw"
+	"github.com/carmel/unipdf/model"
+)
+
+// FloatPosition represents the side of a column a floating drawable is placed on.
+type FloatPosition int
+
+// Float positions.
+const (
+	FloatPositionLeft FloatPosition = iota
+	FloatPositionRight
+)
+
+// ColumnSection is a container component laying out its contents in columns. The contents flow
+// from the bottom of a column to the top of the next one, and from the last column to the first
+// column of the next page. Styled paragraphs are split between columns line by line, the other
+// drawables are split as they are between pages.
+//
+// Spanning drawables, e.g. headings, are laid out across all the columns, below the contents
+// preceding them. Floating drawables are placed at a side of the column, the lines of the styled
+// paragraphs next to them being shortened to wrap around them.
+type ColumnSection struct {
+	columns int
+	items   []columnItem
+
+	// Space between the columns, and width and color of the rules drawn between them.
+	gap       float64
+	ruleWidth float64
+	ruleColor Color
+
+	// Controls whether the columns of the last page are balanced.
+	balanced bool
+
+	// Space between floating drawables and the text wrapped around them.
+	floatSpacing float64
+
+	// Margins to be applied around the section when drawing on Page.
+	margins margins
+}
+
+// columnItemKind is the layout of a drawable of a column section.
+type columnItemKind int
+
+const (
+	columnItemFlow columnItemKind = iota
+	columnItemSpan
+	columnItemFloat
+)
+
+// columnItem is a drawable of a column section.
+type columnItem struct {
+	drawable Drawable
+	kind     columnItemKind
+	position FloatPosition
+}
+
+// newColumnSection returns a new section of `columns` columns.
+func newColumnSection(columns int) *ColumnSection {
+	if columns < 1 {
+		columns = 1
+	}
+
+	return &ColumnSection{
+		columns:      columns,
+		gap:          12,
+		ruleColor:    ColorBlack,
+		floatSpacing: 6,
+	}
+}
+
+// Columns returns the number of columns of the section.
+func (s *ColumnSection) Columns() int {
+	return s.columns
+}
+
+// SetGap sets the space between the columns (12 points by default).
+func (s *ColumnSection) SetGap(gap float64) {
+	s.gap = gap
+}
+
+// SetColumnRule sets the width and color of the vertical lines drawn in the middle of the space
+// between the columns. No lines are drawn for a width of 0, the default.
+func (s *ColumnSection) SetColumnRule(width float64, color Color) {
+	s.ruleWidth = width
+	s.ruleColor = color
+}
+
+// SetBalanced sets whether the contents of the columns of the last page of the section, or
+// preceding a spanning drawable, are balanced, i.e. laid out with the same height instead of
+// filling the first columns.
+func (s *ColumnSection) SetBalanced(balanced bool) {
+	s.balanced = balanced
+}
+
+// SetFloatSpacing sets the space between the floating drawables and the text wrapped around
+// them (6 points by default).
+func (s *ColumnSection) SetFloatSpacing(spacing float64) {
+	s.floatSpacing = spacing
+}
+
+// SetMargins sets the margins of the section.
+func (s *ColumnSection) SetMargins(left, right, top, bottom float64) {
+	s.margins.left = left
+	s.margins.right = right
+	s.margins.top = top
+	s.margins.bottom = bottom
+}
+
+// GetMargins returns the margins of the section: left, right, top, bottom.
+func (s *ColumnSection) GetMargins() (float64, float64, float64, float64) {
+	return s.margins.left, s.margins.right, s.margins.top, s.margins.bottom
+}
+
+// Add adds a drawable flowing in the columns of the section.
+func (s *ColumnSection) Add(d Drawable) {
+	s.items = append(s.items, columnItem{drawable: d, kind: columnItemFlow})
+}
+
+// AddSpanning adds a drawable laid out across all the columns of the section, below the contents
+// added before it.
+func (s *ColumnSection) AddSpanning(d Drawable) {
+	s.items = append(s.items, columnItem{drawable: d, kind: columnItemSpan})
+}
+
+// AddFloat adds a drawable floating at the side `position` of the column, at the position of the
+// contents added before it, or at the top of the next column if it does not fit. The lines of the
+// styled paragraphs next to it are shortened to wrap around it, the other drawables are placed
+// below it. Images wider than the columns are scaled to their width.
+func (s *ColumnSection) AddFloat(d VectorDrawable, position FloatPosition) {
+	s.items = append(s.items, columnItem{drawable: d, kind: columnItemFloat, position: position})
+}
+
+// GeneratePageBlocks generates the page blocks of the section. Multiple blocks are generated
+// if the contents wrap over multiple pages. Implements the Drawable interface.
+func (s *ColumnSection) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
+	origCtx := ctx
+
+	ctx.X += s.margins.left
+	ctx.Y += s.margins.top
+	ctx.Width -= s.margins.left + s.margins.right
+	ctx.Height -= s.margins.top + s.margins.bottom
+
+	l := &columnLayout{
+		section:    s,
+		ctx:        ctx,
+		left:       ctx.X,
+		width:      ctx.Width,
+		pageBottom: ctx.Y + ctx.Height,
+	}
+	l.startBand(ctx.Y)
+
+	// The flowing and floating drawables between the spanning ones are laid out in bands of
+	// columns.
+	var group []columnItem
+	for _, item := range s.items {
+		if item.kind != columnItemSpan {
+			group = append(group, item)
+			continue
+		}
+		if _, err := l.flowGroup(group); err != nil {
+			return nil, ctx, err
+		}
+		group = nil
+		if err := l.span(item.drawable); err != nil {
+			return nil, ctx, err
+		}
+	}
+	if _, err := l.flowGroup(group); err != nil {
+		return nil, ctx, err
+	}
+	if len(l.pending) > 0 {
+		if err := l.newPage(); err != nil {
+			return nil, ctx, err
+		}
+	}
+	if err := l.closeBand(); err != nil {
+		return nil, ctx, err
+	}
+	l.pageBlock()
+
+	ctx = origCtx
+	ctx.Page += l.page
+	ctx.Y = l.used + s.margins.bottom
+	ctx.Height = ctx.PageHeight - ctx.Margins.bottom - ctx.Y
+	return l.blocks, ctx, nil
+}
+
+// columnFloat is the area of a column taken by a floating drawable, including the spacing around
+// it.
+type columnFloat struct {
+	position    FloatPosition
+	top, bottom float64
+	width       float64
+}
+
+// columnLayout lays out the drawables of a column section. The columns of a page between spanning
+// drawables form a band, which contents are laid out from top to bottom and from the first column
+// to the last.
+type columnLayout struct {
+	section *ColumnSection
+
+	// Context of the section on its first page.
+	ctx DrawContext
+
+	// Blocks of the pages of the section. A trial layout, checking whether drawables fit in a
+	// band, draws on discarded blocks.
+	blocks []*Block
+	trial  bool
+
+	// Current page relative to the first page of the section, with the horizontal position and
+	// width of the section and the bottom of the area available on it.
+	page       int
+	left       float64
+	width      float64
+	pageBottom float64
+
+	// Current band, with its current column and position in the column, and the bottom of the
+	// contents laid out in it.
+	top, bottom float64
+	col         int
+	y           float64
+	used        float64
+
+	// Floating drawables of the columns of the band, and the drawables floating on the next page.
+	floats  [][]columnFloat
+	pending []columnItem
+}
+
+// columnWidth returns the width of the columns of the current page.
+func (l *columnLayout) columnWidth() float64 {
+	n := float64(l.section.columns)
+	return (l.width - l.section.gap*(n-1)) / n
+}
+
+// columnX returns the horizontal position of the column `col` of the current page.
+func (l *columnLayout) columnX(col int) float64 {
+	return l.left + float64(col)*(l.columnWidth()+l.section.gap)
+}
+
+// mark records the position `y` reached by the contents of the band.
+func (l *columnLayout) mark(y float64) {
+	if y > l.used {
+		l.used = y
+	}
+}
+
+// context returns the context of an area of the current page at the current position in the
+// column, of width `width` and height `height`, starting at `x`.
+func (l *columnLayout) context(x, width, height float64) DrawContext {
+	ctx := l.ctx
+	ctx.Page += l.page
+	ctx.X = x
+	ctx.Y = l.y
+	ctx.Width = width
+	ctx.Height = height
+	ctx.Inline = false
+	return ctx
+}
+
+// pageBlock returns the block of the current page, nil for trial layouts.
+func (l *columnLayout) pageBlock() *Block {
+	if l.trial {
+		return nil
+	}
+	for len(l.blocks) <= l.page {
+		l.blocks = append(l.blocks, NewBlock(l.ctx.PageWidth, l.ctx.PageHeight))
+	}
+	return l.blocks[l.page]
+}
+
+// merge merges `blk` in the block of the current page.
+func (l *columnLayout) merge(blk *Block) error {
+	if l.trial {
+		return nil
+	}
+	return l.pageBlock().mergeBlocks(blk)
+}
+
+// startBand starts a band of columns at the position `top` of the current page.
+func (l *columnLayout) startBand(top float64) {
+	l.top = top
+	l.bottom = l.pageBottom
+	l.col = 0
+	l.y = top
+	l.used = top
+	l.floats = make([][]columnFloat, l.section.columns)
+}
+
+// closeBand draws the rules between the columns of the current band containing contents.
+func (l *columnLayout) closeBand() error {
+	s := l.section
+	if l.trial || s.ruleWidth <= 0 || l.used <= l.top {
+		return nil
+	}
+
+	blk := l.pageBlock()
+	for col := 1; col <= l.col; col++ {
+		x := l.columnX(col) - s.gap/2
+		rule := draw.Line{
+			LineWidth:        s.ruleWidth,
+			Opacity:          1.0,
+			LineColor:        model.NewPdfColorDeviceRGB(s.ruleColor.ToRGB()),
+			LineEndingStyle1: draw.LineEndingStyleNone,
+			LineEndingStyle2: draw.LineEndingStyleNone,
+			X1:               x,
+			Y1:               l.ctx.PageHeight - l.top,
+			X2:               x,
+			Y2:               l.ctx.PageHeight - l.used,
+		}
+		contents, _, err := rule.Draw("")
+		if err != nil {
+			return err
+		}
+		if err := blk.addContentsByString(string(contents)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextPage moves the layout to the next page, which area starts below the page margins.
+func (l *columnLayout) nextPage() {
+	s := l.section
+	ctx := l.ctx
+	l.page++
+	l.left = ctx.Margins.left + s.margins.left
+	l.width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - s.margins.left - s.margins.right
+	l.pageBottom = ctx.PageHeight - ctx.Margins.bottom - s.margins.bottom
+}
+
+// newPage closes the current band and starts a band on the next page, placing the drawables
+// floating on it.
+func (l *columnLayout) newPage() error {
+	if err := l.closeBand(); err != nil {
+		return err
+	}
+	l.nextPage()
+	l.startBand(l.ctx.Margins.top)
+
+	pending := l.pending
+	l.pending = nil
+	for _, item := range pending {
+		if _, err := l.placeFloat(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextColumn moves the layout to the top of the next column of the band. Returns false if the
+// current column is the last one.
+func (l *columnLayout) nextColumn() bool {
+	if l.col+1 >= l.section.columns {
+		return false
+	}
+	l.col++
+	l.y = l.top
+	return true
+}
+
+// clone returns a trial copy of the layout.
+func (l *columnLayout) clone() *columnLayout {
+	t := *l
+	t.trial = true
+	t.blocks = nil
+	t.pending = nil
+	t.floats = make([][]columnFloat, len(l.floats))
+	for i, floats := range l.floats {
+		t.floats[i] = append([]columnFloat(nil), floats...)
+	}
+	return &t
+}
+
+// flowGroup lays out the flowing and floating drawables `items`, continuing on the next pages
+// as needed. A trial layout stops at the end of the band, returning false if the drawables did
+// not fit in it.
+func (l *columnLayout) flowGroup(items []columnItem) (bool, error) {
+	queue := append([]columnItem(nil), items...)
+	for len(queue) > 0 {
+		if l.section.balanced && !l.trial && l.col == 0 && l.y == l.top && l.bottom == l.pageBottom {
+			if err := l.balance(queue); err != nil {
+				return false, err
+			}
+		}
+
+		item := queue[0]
+		var rest Drawable
+		if item.kind == columnItemFloat {
+			fits, err := l.placeFloat(item)
+			if err != nil {
+				return false, err
+			}
+			if !fits {
+				return false, nil
+			}
+		} else {
+			var err error
+			if rest, err = l.flow(item.drawable); err != nil {
+				return false, err
+			}
+		}
+		if rest == nil {
+			queue = queue[1:]
+			continue
+		}
+
+		// The band is full.
+		if l.trial {
+			return false, nil
+		}
+		queue[0].drawable = rest
+		if err := l.newPage(); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// balance lowers the bottom of the band starting at the current position to the lowest one in
+// which `items` fit, if they fit in the band.
+func (l *columnLayout) balance(items []columnItem) error {
+	fits := func(height float64) (bool, error) {
+		t := l.clone()
+		t.bottom = t.top + height
+		return t.flowGroup(items)
+	}
+
+	low, high := 0.0, l.bottom-l.top
+	if ok, err := fits(high); !ok || err != nil {
+		return err
+	}
+	for high-low > 1 {
+		mid := (low + high) / 2
+		ok, err := fits(mid)
+		if err != nil {
+			return err
+		}
+		if ok {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	l.bottom = l.top + high
+	return nil
+}
+
+// columnParagraph is the part of a styled paragraph left to lay out in the next columns.
+type columnParagraph struct {
+	*StyledParagraph
+}
+
+// flow lays out the flowing drawable `d` from the current position. Returns the part of `d` left
+// to lay out when the band is full.
+func (l *columnLayout) flow(d Drawable) (Drawable, error) {
+	switch t := d.(type) {
+	case *StyledParagraph:
+		return l.flowParagraph(t, true)
+	case *columnParagraph:
+		return l.flowParagraph(t.StyledParagraph, false)
+	}
+	return l.flowDrawable(d)
+}
+
+// slot returns the area of the current column available at the current position: its horizontal
+// position and width, beside the floating drawables, and its bottom. Returns false if the area
+// is too narrow for text.
+func (l *columnLayout) slot() (float64, float64, float64, bool) {
+	colWidth := l.columnWidth()
+	x, width, bottom := l.columnX(l.col), colWidth, l.bottom
+	for _, f := range l.floats[l.col] {
+		if f.top <= l.y && l.y < f.bottom {
+			if f.position == FloatPositionLeft {
+				x += f.width
+			}
+			width -= f.width
+			bottom = math.Min(bottom, f.bottom)
+		} else if f.top > l.y {
+			bottom = math.Min(bottom, f.top)
+		}
+	}
+	return x, width, bottom, width >= colWidth/4 && bottom > l.y
+}
+
+// flowParagraph lays out the lines of `p` in the available areas of the columns, wrapping them
+// at the width of each area. The top margin is applied to the `first` part of the paragraph.
+func (l *columnLayout) flowParagraph(p *StyledParagraph, first bool) (Drawable, error) {
+	for {
+		x, width, bottom, ok := l.slot()
+		if ok {
+			// A line too high for the columns is drawn at the top of an empty column.
+			force := !l.trial && l.y == l.top && bottom == l.bottom && len(l.floats[l.col]) == 0
+			ctx := l.context(x, width, bottom-l.y)
+
+			blk := l.pageBlock()
+			if blk == nil {
+				blk = NewBlock(ctx.PageWidth, ctx.PageHeight)
+			}
+			newCtx, rest, drawn, err := p.drawPartOnBlock(blk, ctx, first, force)
+			if err != nil {
+				return nil, err
+			}
+			if drawn {
+				first = false
+				l.y = newCtx.Y
+				l.mark(l.y)
+				if rest == nil {
+					return nil, nil
+				}
+				p = rest
+			}
+		}
+
+		// Continue below the floating drawables, or in the next column.
+		if bottom < l.bottom {
+			l.y = bottom
+			continue
+		}
+		if !l.nextColumn() {
+			return &columnParagraph{p}, nil
+		}
+	}
+}
+
+// flowDrawable lays out `d` below the floating drawables of the current column. The contents
+// overflowing the column continue in the next columns, as they would on the next pages.
+func (l *columnLayout) flowDrawable(d Drawable) (Drawable, error) {
+	for {
+		for _, f := range l.floats[l.col] {
+			if f.top <= l.y && l.y < f.bottom {
+				l.y = f.bottom
+			}
+		}
+		if l.y < l.bottom {
+			break
+		}
+		if !l.nextColumn() {
+			return d, nil
+		}
+	}
+
+	colWidth := l.columnWidth()
+	ctx := l.context(l.columnX(l.col), colWidth, l.bottom-l.y)
+
+	// The margins of the context set the area of the next column, or of the first column of the
+	// next page.
+	next := *l
+	next.trial = true
+	if !next.nextColumn() {
+		next.nextPage()
+		next.startBand(l.ctx.Margins.top)
+	}
+	nextX := next.columnX(next.col)
+	ctx.Margins.left = nextX
+	ctx.Margins.right = ctx.PageWidth - nextX - next.columnWidth()
+	ctx.Margins.top = next.top
+	ctx.Margins.bottom = ctx.PageHeight - next.bottom
+
+	blocks, newCtx, err := d.GeneratePageBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, nil
+	}
+	if err := l.merge(blocks[0]); err != nil {
+		return nil, err
+	}
+
+	// Move the blocks of the next columns from the area of the next column.
+	for _, blk := range blocks[1:] {
+		l.mark(l.bottom)
+		if !l.nextColumn() {
+			if l.trial {
+				return d, nil
+			}
+			if err := l.newPage(); err != nil {
+				return nil, err
+			}
+		}
+		blk.translate(l.columnX(l.col)-nextX, l.top-next.top)
+		if err := l.merge(blk); err != nil {
+			return nil, err
+		}
+	}
+
+	l.y = newCtx.Y
+	if len(blocks) > 1 {
+		l.y += l.top - next.top
+	}
+	l.mark(l.y)
+	return nil, nil
+}
+
+// placeFloat places the floating drawable of `item` at the current position, or at the top of
+// the next column if it does not fit. It is placed on the next page if it does not fit in the
+// last column, except for trial layouts which return false.
+func (l *columnLayout) placeFloat(item columnItem) (bool, error) {
+	s := l.section
+	d, ok := item.drawable.(VectorDrawable)
+	if !ok {
+		return true, nil
+	}
+
+	colWidth := l.columnWidth()
+	if img, ok := d.(*Image); ok && img.Width() > colWidth {
+		img.ScaleToWidth(colWidth)
+	}
+	width, height := d.Width(), d.Height()
+
+	for col, y := l.col, l.y; col < s.columns; col, y = col+1, l.top {
+		// Below the drawables floating on the same side.
+		for moved := true; moved; {
+			moved = false
+			for _, f := range l.floats[col] {
+				if f.position == item.position && f.top <= y && y < f.bottom {
+					y = f.bottom
+					moved = true
+				}
+			}
+		}
+
+		// A drawable too high for the columns is placed at the top of an empty column.
+		if y+height > l.bottom && (l.trial || y != l.top) {
+			continue
+		}
+
+		x := l.columnX(col)
+		if item.position == FloatPositionRight {
+			x += colWidth - width
+		}
+		l.floats[col] = append(l.floats[col], columnFloat{
+			position: item.position,
+			top:      y,
+			bottom:   y + height + s.floatSpacing,
+			width:    math.Min(width+s.floatSpacing, colWidth),
+		})
+		l.mark(y + height)
+		if l.trial {
+			return true, nil
+		}
+
+		pos := *l
+		pos.y = y
+		blocks, _, err := d.GeneratePageBlocks(pos.context(x, width, math.Max(height, l.bottom-y)))
+		if err != nil {
+			return false, err
+		}
+		if len(blocks) > 0 {
+			if err := l.merge(blocks[0]); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	if l.trial {
+		return false, nil
+	}
+	l.pending = append(l.pending, item)
+	return true, nil
+}
+
+// span lays out the spanning drawable `d` across the columns, below the contents of the band,
+// and starts a band below it.
+func (l *columnLayout) span(d Drawable) error {
+	if err := l.closeBand(); err != nil {
+		return err
+	}
+
+	l.y = l.used
+	ctx := l.context(l.left, l.width, l.pageBottom-l.used)
+	blocks, newCtx, err := d.GeneratePageBlocks(ctx)
+	if err != nil {
+		return err
+	}
+	for i, blk := range blocks {
+		if i > 0 {
+			l.nextPage()
+		}
+		if err := l.merge(blk); err != nil {
+			return err
+		}
+	}
+	l.startBand(newCtx.Y)
+	return nil
+}
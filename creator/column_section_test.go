@@ -0,0 +1,227 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"fmt"
+	goimage "image"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+// columnWord is a word drawn on a page: its index in the text, the position of its left side and
+// the top of its line.
+type columnWord struct {
+	index int
+	x     float64
+	top   float64
+}
+
+// numberedText returns `count` words made of "w" and their 4 digits index.
+func numberedText(count int) string {
+	words := make([]string, count)
+	for i := range words {
+		words[i] = fmt.Sprintf("w%04d", i)
+	}
+	return strings.Join(words, " ")
+}
+
+// readColumnWords returns the numbered words of the pages of the PDF `data`, by page.
+func readColumnWords(t *testing.T, data []byte) [][]columnWord {
+	r, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPages, err := r.GetNumPages()
+	require.NoError(t, err)
+
+	var pages [][]columnWord
+	for i := 1; i <= numPages; i++ {
+		page, err := r.GetPage(i)
+		require.NoError(t, err)
+		e, err := extractor.New(page)
+		require.NoError(t, err)
+		pageText, _, _, err := e.ExtractPageText()
+		require.NoError(t, err)
+
+		var words []columnWord
+		marks := pageText.Marks().Elements()
+		for k := 0; k+4 < len(marks); k++ {
+			if marks[k].Text != "w" || marks[k].Meta {
+				continue
+			}
+			var index int
+			digits := marks[k+1].Text + marks[k+2].Text + marks[k+3].Text + marks[k+4].Text
+			if _, err := fmt.Sscanf(digits, "%04d", &index); err != nil {
+				continue
+			}
+			words = append(words, columnWord{index: index, x: marks[k].BBox.Llx, top: marks[k].BBox.Ury})
+		}
+		pages = append(pages, words)
+	}
+	return pages
+}
+
+func TestColumnSectionFlow(t *testing.T) {
+	c := New()
+	c.NewPage()
+	left, right, _, _ := c.GetPageMargins()
+	pageWidth := c.Context().PageWidth
+	columnWidth := (pageWidth - left - right - 20) / 2
+	top := c.Context().Y
+
+	section := c.NewColumnSection(2)
+	section.SetGap(20)
+	section.SetColumnRule(0.5, ColorBlack)
+
+	heading := c.NewStyledParagraph()
+	heading.Append("Heading").Style.FontSize = 24
+	section.AddSpanning(heading)
+
+	body := c.NewStyledParagraph()
+	body.Append(numberedText(1500))
+	section.Add(body)
+	require.NoError(t, c.Draw(section))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := readColumnWords(t, buf.Bytes())
+	require.GreaterOrEqual(t, len(pages), 2)
+
+	// The heading is above the columns.
+	headingBottom := c.Context().PageHeight - top - 24
+	require.LessOrEqual(t, pages[0][0].top, headingBottom+0.01)
+
+	// The words are in the columns of the pages, in order.
+	var count int
+	last := -1
+	for _, words := range pages {
+		var columns [2][]columnWord
+		for _, w := range words {
+			if w.x < left+columnWidth {
+				require.GreaterOrEqual(t, w.x, left-1)
+				columns[0] = append(columns[0], w)
+			} else {
+				require.GreaterOrEqual(t, w.x, left+columnWidth+20-1)
+				require.Less(t, w.x, pageWidth-right)
+				columns[1] = append(columns[1], w)
+			}
+		}
+		for _, column := range columns {
+			for _, w := range column {
+				require.Equal(t, last+1, w.index)
+				last = w.index
+				count++
+			}
+		}
+	}
+	require.Equal(t, 1500, count)
+
+	// Both columns of the first page are filled from the same top.
+	first := pages[0]
+	var secondColumn []columnWord
+	for _, w := range first {
+		if w.x >= left+columnWidth {
+			secondColumn = append(secondColumn, w)
+		}
+	}
+	require.NotEmpty(t, secondColumn)
+	require.InDelta(t, first[0].top, secondColumn[0].top, 0.01)
+}
+
+func TestColumnSectionBalanced(t *testing.T) {
+	c := New()
+	c.NewPage()
+	left, right, _, _ := c.GetPageMargins()
+	columnWidth := (c.Context().PageWidth - left - right - 2*12) / 3
+
+	section := c.NewColumnSection(3)
+	section.SetBalanced(true)
+	body := c.NewStyledParagraph()
+	body.Append(numberedText(90))
+	section.Add(body)
+	require.NoError(t, c.Draw(section))
+
+	after := c.NewStyledParagraph()
+	after.Append("w9999")
+	require.NoError(t, c.Draw(after))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := readColumnWords(t, buf.Bytes())
+	require.Len(t, pages, 1)
+
+	// The words are in 3 columns of about the same number of lines, above the paragraph drawn
+	// after the section.
+	lines := make([]map[float64]bool, 3)
+	bottom := c.Context().PageHeight
+	var afterTop float64
+	for _, w := range pages[0] {
+		if w.index == 9999 {
+			afterTop = w.top
+			continue
+		}
+		col := int((w.x - left) / (columnWidth + 12))
+		if lines[col] == nil {
+			lines[col] = map[float64]bool{}
+		}
+		lines[col][w.top] = true
+		if w.top < bottom {
+			bottom = w.top
+		}
+	}
+	for col := 1; col < 3; col++ {
+		require.InDelta(t, len(lines[0]), len(lines[col]), 1)
+	}
+	require.Greater(t, len(lines[2]), 1)
+	require.Less(t, afterTop, bottom)
+}
+
+func TestColumnSectionFloat(t *testing.T) {
+	c := New()
+	c.NewPage()
+	left, _, _, _ := c.GetPageMargins()
+	top := c.Context().Y
+
+	img, err := c.NewImageFromGoImage(goimage.NewGray(goimage.Rect(0, 0, 100, 100)))
+	require.NoError(t, err)
+	img.ScaleToWidth(80)
+
+	section := c.NewColumnSection(2)
+	section.SetFloatSpacing(10)
+	section.AddFloat(img, FloatPositionLeft)
+	body := c.NewStyledParagraph()
+	body.Append(numberedText(200))
+	section.Add(body)
+	require.NoError(t, c.Draw(section))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := readColumnWords(t, buf.Bytes())
+	require.Len(t, pages, 1)
+
+	// The lines next to the image start after it, the lines below at the left of the column.
+	pageHeight := c.Context().PageHeight
+	floatBottom := pageHeight - top - img.Height() - 10
+	var beside, below int
+	for _, w := range pages[0] {
+		if w.x > left+c.Context().Width/2 {
+			continue
+		}
+		if w.top > floatBottom {
+			require.GreaterOrEqual(t, w.x, left+90-0.01)
+			beside++
+		} else if w.x < left+1 {
+			below++
+		}
+	}
+	require.Greater(t, beside, 3)
+	require.Greater(t, below, 3)
+}
@@ -41,6 +41,20 @@ const (
 	TextAlignmentJustify
 )
 
+// TextDirection is the base direction of the text of paragraphs, which determines the display
+// order of text mixing left-to-right and right-to-left scripts.
+type TextDirection int
+
+// The options supported for text direction are:
+// auto - TextDirectionAuto, from the first strong directional character, left-to-right if none
+// left-to-right - TextDirectionLeftToRight
+// right-to-left - TextDirectionRightToLeft
+const (
+	TextDirectionAuto TextDirection = iota
+	TextDirectionLeftToRight
+	TextDirectionRightToLeft
+)
+
 // TextRenderingMode determines whether showing text shall cause glyph
 // outlines to be stroked, filled, used as a clipping boundary, or some
 // combination of the three.
@@ -99,3 +113,15 @@ const (
 	HorizontalAlignmentCenter
 	HorizontalAlignmentRight
 )
+
+// LineBreaking is the algorithm choosing the positions paragraphs are broken into lines at.
+type LineBreaking int
+
+// The options supported for line breaking are:
+// greedy - LineBreakingGreedy, filling each line with as much text as fits
+// optimal - LineBreakingOptimal, the Knuth-Plass algorithm minimizing the variation of the spacing
+// of the lines over the whole paragraph
+const (
+	LineBreakingGreedy LineBreaking = iota
+	LineBreakingOptimal
+)
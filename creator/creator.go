@@ -15,6 +15,7 @@ import (
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core"
 	"github.com/carmel/unipdf/model"
+	"github.com/carmel/unipdf/model/xmputil"
 )
 
 // Creator is a wrapper around functionality for creating PDF reports and/or adding new
@@ -69,12 +70,27 @@ type Creator struct {
 	// Optimizer.
 	optimizer model.Optimizer
 
+	// PDF/A output options.
+	pdfa *model.PdfAOptions
+
+	// Document information and XMP metadata.
+	info *model.PdfInfo
+	xmp  *xmputil.Packet
+
 	// Fonts that have been enabled for subsetting prior to write.
 	subsetFonts []*model.PdfFont
 
 	// Default fonts used by all components instantiated through the creator.
 	defaultFontRegular *model.PdfFont
 	defaultFontBold    *model.PdfFont
+
+	// Streaming output, nil unless StartStreaming has been called.
+	streaming *creatorStreaming
+
+	// Drawables kept with the next ones, drawn with them, and the error of their drawing by
+	// NewPage, returned by the next call to Draw.
+	kept    []Drawable
+	keptErr error
 }
 
 // SetForms adds an Acroform to a PDF file.  Sets the specified form for writing.
@@ -176,6 +192,24 @@ func (c *Creator) GetOptimizer() model.Optimizer {
 	return c.optimizer
 }
 
+// SetPdfA makes the creator output a PDF/A document with the specified options.
+// The default fonts of the creator (Helvetica and Helvetica-Bold) are not embedded, so either
+// use embedded fonts for all text or specify replacements in the Fonts field of the options.
+// Passing nil disables PDF/A output.
+func (c *Creator) SetPdfA(opts *model.PdfAOptions) {
+	c.pdfa = opts
+}
+
+// SetDocInfo sets the document information (title, author etc) of the output.
+func (c *Creator) SetDocInfo(info *model.PdfInfo) {
+	c.info = info
+}
+
+// SetXMP sets the XMP metadata of the output, see model.PdfWriter.SetXMP.
+func (c *Creator) SetXMP(packet *xmputil.Packet) {
+	c.xmp = packet
+}
+
 // SetPageMargins sets the page margins: left, right, top, bottom.
 // The default page margins are 10% of document width.
 func (c *Creator) SetPageMargins(left, right, top, bottom float64) {
@@ -185,6 +219,11 @@ func (c *Creator) SetPageMargins(left, right, top, bottom float64) {
 	c.pageMargins.bottom = bottom
 }
 
+// GetPageMargins returns the page margins: left, right, top, bottom.
+func (c *Creator) GetPageMargins() (float64, float64, float64, float64) {
+	return c.pageMargins.left, c.pageMargins.right, c.pageMargins.top, c.pageMargins.bottom
+}
+
 // Width returns the current page width.
 func (c *Creator) Width() float64 {
 	return c.pageWidth
@@ -307,6 +346,14 @@ func (c *Creator) initContext() {
 
 // NewPage adds a new Page to the Creator and sets as the active Page.
 func (c *Creator) NewPage() *model.PdfPage {
+	c.flushKept()
+	if c.streaming != nil {
+		// The pages created so far are complete. A write error is returned by the next call to
+		// Draw or FinishStreaming.
+		if err := c.flushStreamingPages(); err != nil {
+			common.Log.Debug("ERROR: Failed to write pages: %v", err)
+		}
+	}
 	page := c.newPage()
 	c.pages = append(c.pages, page)
 	c.context.Page++
@@ -315,6 +362,14 @@ func (c *Creator) NewPage() *model.PdfPage {
 
 // AddPage adds the specified page to the creator.
 func (c *Creator) AddPage(page *model.PdfPage) error {
+	if err := c.drawKept(); err != nil {
+		return err
+	}
+	if c.streaming != nil {
+		if err := c.flushStreamingPages(); err != nil {
+			return err
+		}
+	}
 	mbox, err := page.GetMediaBox()
 	if err != nil {
 		common.Log.Debug("Failed to get page mediabox: %v", err)
@@ -356,7 +411,8 @@ func (c *Creator) RotateDeg(angleDeg int64) error {
 	return nil
 }
 
-// Context returns the current drawing context.
+// Context returns the current drawing context. The drawables kept with the next ones which are
+// still waiting for it (see Draw) are not accounted for.
 func (c *Creator) Context() DrawContext {
 	return c.context
 }
@@ -379,6 +435,12 @@ func (c *Creator) Finalize() error {
 	if c.finalized {
 		return nil
 	}
+	if c.streaming != nil {
+		return errors.New("creator is streaming, use FinishStreaming")
+	}
+	if err := c.drawKept(); err != nil {
+		return err
+	}
 
 	totPages := len(c.pages)
 
@@ -476,34 +538,11 @@ func (c *Creator) Finalize() error {
 
 	// Account for the front page and the table of content pages.
 	if c.outline != nil && c.AddOutlines {
-		var adjustOutlineDest func(item *model.OutlineItem)
-		adjustOutlineDest = func(item *model.OutlineItem) {
-			item.Dest.Page += int64(genpages)
-
-			// Get page indirect object.
-			if page := int(item.Dest.Page); page >= 0 && page < len(c.pages) {
-				item.Dest.PageObj = c.pages[page].GetPageAsIndirectObject()
-			} else {
-				common.Log.Debug("WARN: could not get page container for page %d", page)
-			}
-
-			// Reverse the Y axis of the destination coordinates.
-			// The user passes in the annotation coordinates as if
-			// position 0, 0 is at the top left of the page.
-			// However, position 0, 0 in the PDF is at the bottom
-			// left of the page.
-			item.Dest.Y = c.pageHeight - item.Dest.Y
-
-			outlineItems := item.Items()
-			for _, outlineItem := range outlineItems {
-				adjustOutlineDest(outlineItem)
-			}
-		}
-
-		outlineItems := c.outline.Items()
-		for _, outlineItem := range outlineItems {
-			adjustOutlineDest(outlineItem)
+		pageObjs := make([]*core.PdfIndirectObject, len(c.pages))
+		for i, page := range c.pages {
+			pageObjs[i] = page.GetPageAsIndirectObject()
 		}
+		c.adjustOutlineDests(int64(genpages), pageObjs)
 
 		// Add outline TOC item.
 		if c.AddTOC {
@@ -524,58 +563,100 @@ func (c *Creator) Finalize() error {
 	}
 
 	for idx, page := range c.pages {
-		c.setActivePage(page)
-
-		// Draw page header.
-		if c.drawHeaderFunc != nil {
-			// Prepare a block to draw on.
-			// Header is drawn on the top of the page. Has width of the page, but height limited to
-			// the page margin top height.
-			headerBlock := NewBlock(c.pageWidth, c.pageMargins.top)
-			args := HeaderFunctionArgs{
-				PageNum:    idx + 1,
-				TotalPages: totPages,
-			}
-			c.drawHeaderFunc(headerBlock, args)
-			headerBlock.SetPos(0, 0)
+		if err := c.finalizePage(page, idx+1, totPages); err != nil {
+			return err
+		}
+	}
 
-			if err := c.Draw(headerBlock); err != nil {
-				common.Log.Debug("ERROR: drawing header: %v", err)
-				return err
-			}
+	c.finalized = true
+	return nil
+}
+
+// adjustOutlineDests offsets the page indices of the outline destinations by `pageOffset`, sets
+// their page objects from `pageObjs` and converts their coordinates to PDF coordinates.
+func (c *Creator) adjustOutlineDests(pageOffset int64, pageObjs []*core.PdfIndirectObject) {
+	var adjustOutlineDest func(item *model.OutlineItem)
+	adjustOutlineDest = func(item *model.OutlineItem) {
+		item.Dest.Page += pageOffset
+
+		// Get page indirect object.
+		if page := int(item.Dest.Page); page >= 0 && page < len(pageObjs) {
+			item.Dest.PageObj = pageObjs[page]
+		} else {
+			common.Log.Debug("WARN: could not get page container for page %d", page)
 		}
 
-		// Draw page footer.
-		if c.drawFooterFunc != nil {
-			// Prepare a block to draw on.
-			// Footer is drawn on the bottom of the page. Has width of the page, but height limited
-			// to the page margin bottom height.
-			footerBlock := NewBlock(c.pageWidth, c.pageMargins.bottom)
-			args := FooterFunctionArgs{
-				PageNum:    idx + 1,
-				TotalPages: totPages,
-			}
-			c.drawFooterFunc(footerBlock, args)
-			footerBlock.SetPos(0, c.pageHeight-footerBlock.height)
+		// Reverse the Y axis of the destination coordinates.
+		// The user passes in the annotation coordinates as if
+		// position 0, 0 is at the top left of the page.
+		// However, position 0, 0 in the PDF is at the bottom
+		// left of the page.
+		item.Dest.Y = c.pageHeight - item.Dest.Y
 
-			if err := c.Draw(footerBlock); err != nil {
-				common.Log.Debug("ERROR: drawing footer: %v", err)
-				return err
-			}
+		outlineItems := item.Items()
+		for _, outlineItem := range outlineItems {
+			adjustOutlineDest(outlineItem)
 		}
+	}
 
-		// Draw page blocks.
-		block, ok := c.pageBlocks[page]
-		if !ok {
-			continue
+	outlineItems := c.outline.Items()
+	for _, outlineItem := range outlineItems {
+		adjustOutlineDest(outlineItem)
+	}
+}
+
+// finalizePage draws the header, footer and blocks of `page`, which is page number `pageNum` of
+// `totPages`.
+func (c *Creator) finalizePage(page *model.PdfPage, pageNum, totPages int) error {
+	c.setActivePage(page)
+
+	// Draw page header.
+	if c.drawHeaderFunc != nil {
+		// Prepare a block to draw on.
+		// Header is drawn on the top of the page. Has width of the page, but height limited to
+		// the page margin top height.
+		headerBlock := NewBlock(c.pageWidth, c.pageMargins.top)
+		args := HeaderFunctionArgs{
+			PageNum:    pageNum,
+			TotalPages: totPages,
 		}
-		if err := block.drawToPage(page); err != nil {
-			common.Log.Debug("ERROR: drawing page %d blocks: %v", idx+1, err)
+		c.drawHeaderFunc(headerBlock, args)
+		headerBlock.SetPos(0, 0)
+
+		if err := c.Draw(headerBlock); err != nil {
+			common.Log.Debug("ERROR: drawing header: %v", err)
 			return err
 		}
 	}
 
-	c.finalized = true
+	// Draw page footer.
+	if c.drawFooterFunc != nil {
+		// Prepare a block to draw on.
+		// Footer is drawn on the bottom of the page. Has width of the page, but height limited
+		// to the page margin bottom height.
+		footerBlock := NewBlock(c.pageWidth, c.pageMargins.bottom)
+		args := FooterFunctionArgs{
+			PageNum:    pageNum,
+			TotalPages: totPages,
+		}
+		c.drawFooterFunc(footerBlock, args)
+		footerBlock.SetPos(0, c.pageHeight-footerBlock.height)
+
+		if err := c.Draw(footerBlock); err != nil {
+			common.Log.Debug("ERROR: drawing footer: %v", err)
+			return err
+		}
+	}
+
+	// Draw page blocks.
+	block, ok := c.pageBlocks[page]
+	if !ok {
+		return nil
+	}
+	if err := block.drawToPage(page); err != nil {
+		common.Log.Debug("ERROR: drawing page %d blocks: %v", pageNum, err)
+		return err
+	}
 	return nil
 }
 
@@ -611,13 +692,77 @@ func (c *Creator) MoveDown(dy float64) {
 // page. Each generated block is assigned to the creator page it will be
 // rendered to. In order to render the generated blocks to the creator pages,
 // call Finalize, Write or WriteToFile.
+// Drawables kept with the next drawable (see StyledParagraph.SetKeepWithNext) are drawn with it,
+// starting on a new page if the drawables would be split between pages. Drawables kept together
+// (see Table.SetKeepTogether) start on a new page if they do not fit on the current one. The
+// drawables kept with the next ones are drawn from the position of the context when the next one
+// is drawn, or when a page is added.
 func (c *Creator) Draw(d Drawable) error {
+	if c.streaming != nil && c.streaming.err != nil {
+		return c.streaming.err
+	}
 	if c.getActivePage() == nil {
 		// Add a new Page if none added already.
 		c.NewPage()
 	}
 
-	blocks, ctx, err := d.GeneratePageBlocks(c.context)
+	c.kept = append(c.kept, d)
+	if keepsWithNext(d) {
+		err := c.keptErr
+		c.keptErr = nil
+		return err
+	}
+	return c.drawKept()
+}
+
+// drawKept draws the drawables kept with the next ones and the drawable following them. They are
+// moved to a new page if they would be split between pages. The error of the drawing of previous
+// drawables by NewPage is returned if the drawing succeeds.
+func (c *Creator) drawKept() error {
+	run := c.kept
+	c.kept = nil
+	if err := c.drawRun(run); err != nil {
+		return err
+	}
+	err := c.keptErr
+	c.keptErr = nil
+	return err
+}
+
+// drawRun draws the drawables of `run`, kept with the next ones but the last.
+func (c *Creator) drawRun(run []Drawable) error {
+	breaks, err := breaksKeptRun(run, c.context)
+	if err != nil {
+		return err
+	}
+	if breaks {
+		c.NewPage()
+	}
+	for _, d := range run {
+		if err := c.draw(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushKept draws the drawables kept with the next ones before a page is added by NewPage. The
+// error is returned by the next call to Draw, Finalize or FinishStreaming.
+func (c *Creator) flushKept() {
+	if len(c.kept) == 0 {
+		return
+	}
+	run := c.kept
+	c.kept = nil
+	if err := c.drawRun(run); err != nil && c.keptErr == nil {
+		common.Log.Debug("ERROR: drawing kept drawables: %v", err)
+		c.keptErr = err
+	}
+}
+
+// draw generates the blocks of `d` and assigns them to the creator pages.
+func (c *Creator) draw(d Drawable) error {
+	blocks, ctx, err := generateKeptBlocks(d, c.context)
 	if err != nil {
 		return err
 	}
@@ -656,29 +801,14 @@ func (c *Creator) Write(ws io.Writer) error {
 
 	pdfWriter := model.NewPdfWriter()
 	pdfWriter.SetOptimizer(c.optimizer)
-
-	// Form fields.
-	if c.acroForm != nil {
-		err := pdfWriter.SetForms(c.acroForm)
-		if err != nil {
-			common.Log.Debug("Failure: %v", err)
-			return err
-		}
-	}
-
-	// Outlines.
-	if c.externalOutline != nil {
-		pdfWriter.AddOutlineTree(c.externalOutline)
-	} else if c.outline != nil && c.AddOutlines {
-		pdfWriter.AddOutlineTree(&c.outline.ToPdfOutline().PdfOutlineTreeNode)
+	pdfWriter.SetPdfA(c.pdfa)
+	if c.info != nil {
+		pdfWriter.SetDocInfo(c.info)
 	}
+	pdfWriter.SetXMP(c.xmp)
 
-	// Page labels.
-	if c.pageLabels != nil {
-		if err := pdfWriter.SetPageLabels(c.pageLabels); err != nil {
-			common.Log.Debug("ERROR: Could not set page labels: %v", err)
-			return err
-		}
+	if err := c.setDocumentObjects(&pdfWriter); err != nil {
+		return err
 	}
 
 	if c.subsetFonts != nil {
@@ -716,6 +846,34 @@ func (c *Creator) Write(ws io.Writer) error {
 	return nil
 }
 
+// setDocumentObjects sets the forms, outlines and page labels of the output of `pdfWriter`.
+func (c *Creator) setDocumentObjects(pdfWriter *model.PdfWriter) error {
+	// Form fields.
+	if c.acroForm != nil {
+		err := pdfWriter.SetForms(c.acroForm)
+		if err != nil {
+			common.Log.Debug("Failure: %v", err)
+			return err
+		}
+	}
+
+	// Outlines.
+	if c.externalOutline != nil {
+		pdfWriter.AddOutlineTree(c.externalOutline)
+	} else if c.outline != nil && c.AddOutlines {
+		pdfWriter.AddOutlineTree(&c.outline.ToPdfOutline().PdfOutlineTreeNode)
+	}
+
+	// Page labels.
+	if c.pageLabels != nil {
+		if err := pdfWriter.SetPageLabels(c.pageLabels); err != nil {
+			common.Log.Debug("ERROR: Could not set page labels: %v", err)
+			return err
+		}
+	}
+	return nil
+}
+
 // SetPdfWriterAccessFunc sets a PdfWriter access function/hook.
 // Exposes the PdfWriter just prior to writing the PDF.  Can be used to encrypt the output PDF, etc.
 //
@@ -797,6 +955,11 @@ func (c *Creator) NewDivision() *Division {
 	return newDivision()
 }
 
+// NewColumnSection returns a new section laying out its contents in `columns` columns.
+func (c *Creator) NewColumnSection(columns int) *ColumnSection {
+	return newColumnSection(columns)
+}
+
 // NewTOC creates a new table of contents.
 func (c *Creator) NewTOC(title string) *TOC {
 	headingStyle := c.NewTextStyle()
@@ -887,3 +1050,19 @@ func (c *Creator) NewImageFromFile(path string) (*Image, error) {
 func (c *Creator) NewImageFromGoImage(goimg goimage.Image) (*Image, error) {
 	return newImageFromGoImage(goimg)
 }
+
+// NewImportedPage creates an ImportedPage drawing `page`, a page of an existing document.
+func (c *Creator) NewImportedPage(page *model.PdfPage) (*ImportedPage, error) {
+	return newImportedPage(page)
+}
+
+// NewBarcode creates a barcode of type `kind` encoding `data`. QR Codes are created with the
+// error correction level M, see NewQRCode.
+func (c *Creator) NewBarcode(kind BarcodeType, data string) (*Barcode, error) {
+	return newBarcode(kind, data, QRErrorCorrectionM, c.NewTextStyle())
+}
+
+// NewQRCode creates a QR Code encoding `data` with the error correction level `level`.
+func (c *Creator) NewQRCode(data string, level QRErrorCorrectionLevel) (*Barcode, error) {
+	return newBarcode(BarcodeQR, data, level, c.NewTextStyle())
+}
@@ -0,0 +1,127 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"errors"
+	"io"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// creatorStreaming is the state of the streaming output of a creator.
+type creatorStreaming struct {
+	writer *model.PdfWriter
+
+	// Page objects of the written pages, for resolving outline destinations.
+	pageObjs []*core.PdfIndirectObject
+
+	// First error encountered while writing pages, returned by the next calls to Draw, AddPage
+	// and FinishStreaming.
+	err error
+}
+
+// StartStreaming makes the creator write the document to `out` while it is being created, so that
+// very large documents can be generated without keeping all the pages in memory. Each page is
+// rendered and written out once the next page is started, and FinishStreaming must be called
+// instead of Write to complete the output. The creator settings (document information,
+// PdfWriter access function, etc.) must be set before calling StartStreaming.
+//
+// As the total number of pages is not known while streaming, headers and footers are drawn
+// with a TotalPages argument of 0. Front pages, tables of contents and font subsetting are not
+// supported, nor are the output options not supported by model.PdfWriter.StartStreaming.
+// Outlines, forms and page labels are written by FinishStreaming.
+func (c *Creator) StartStreaming(out io.Writer) error {
+	switch {
+	case c.streaming != nil:
+		return errors.New("creator is already streaming")
+	case c.finalized:
+		return errors.New("creator is finalized")
+	case c.genFrontPageFunc != nil:
+		return errors.New("front page is not supported when streaming")
+	case c.AddTOC:
+		return errors.New("table of contents is not supported when streaming")
+	case c.subsetFonts != nil:
+		return errors.New("font subsetting is not supported when streaming")
+	}
+
+	pdfWriter := model.NewPdfWriter()
+	pdfWriter.SetOptimizer(c.optimizer)
+	pdfWriter.SetPdfA(c.pdfa)
+	if c.info != nil {
+		pdfWriter.SetDocInfo(c.info)
+	}
+	pdfWriter.SetXMP(c.xmp)
+
+	// Pdf Writer access hook.
+	if c.pdfWriterAccessFunc != nil {
+		if err := c.pdfWriterAccessFunc(&pdfWriter); err != nil {
+			common.Log.Debug("Failure: %v", err)
+			return err
+		}
+	}
+
+	if err := pdfWriter.StartStreaming(out); err != nil {
+		return err
+	}
+	c.streaming = &creatorStreaming{writer: &pdfWriter}
+	return nil
+}
+
+// flushStreamingPages renders the pages of the creator and writes them out. The pages and their
+// blocks are released.
+func (c *Creator) flushStreamingPages() error {
+	s := c.streaming
+	if s.err != nil {
+		return s.err
+	}
+
+	// Drawing headers and footers moves the context, which applies to the next page.
+	context := c.context
+	for _, page := range c.pages {
+		if s.err = c.finalizePage(page, len(s.pageObjs)+1, 0); s.err != nil {
+			return s.err
+		}
+		if s.err = s.writer.AddPage(page); s.err != nil {
+			common.Log.Debug("ERROR: Failed to add Page: %v", s.err)
+			return s.err
+		}
+		s.pageObjs = append(s.pageObjs, page.GetPageAsIndirectObject())
+		delete(c.pageBlocks, page)
+	}
+	c.pages = []*model.PdfPage{}
+	c.setActivePage(nil)
+	c.context = context
+	return nil
+}
+
+// FinishStreaming writes out the remaining pages and completes the output of the creator, see
+// StartStreaming.
+func (c *Creator) FinishStreaming() error {
+	if c.streaming == nil {
+		return errors.New("creator is not streaming")
+	}
+	if c.finalized {
+		return errors.New("streaming output already finished")
+	}
+	if err := c.drawKept(); err != nil {
+		return err
+	}
+	if err := c.flushStreamingPages(); err != nil {
+		return err
+	}
+	c.finalized = true
+
+	if c.outline != nil && c.AddOutlines && c.externalOutline == nil {
+		c.adjustOutlineDests(0, c.streaming.pageObjs)
+	}
+	if err := c.setDocumentObjects(c.streaming.writer); err != nil {
+		return err
+	}
+	return c.streaming.writer.FinishStreaming()
+}
@@ -14,6 +14,7 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	goimage "image"
 	"math"
@@ -3245,3 +3246,167 @@ func testRender(t *testing.T, pdfPath string) {
 
 	testutils.RunRenderTest(t, pdfPath, tempDir, baselineRenderPath, saveBaseline)
 }
+
+func TestCreatorPdfA(t *testing.T) {
+	font, err := model.NewPdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+
+	c := New()
+	p := c.NewParagraph("PDF/A-1b document")
+	p.SetFont(font)
+	require.NoError(t, c.Draw(p))
+	rect := c.NewRectangle(50, 100, 200, 100)
+	rect.SetFillColor(ColorRGBFrom8bit(255, 0, 0))
+	require.NoError(t, c.Draw(rect))
+
+	c.SetPdfA(&model.PdfAOptions{Conformance: model.PdfA1B})
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	version := reader.PdfVersion()
+	require.Equal(t, 1, version.Major)
+	require.LessOrEqual(t, version.Minor, 4)
+	// The metadata stream is not compressed.
+	require.Contains(t, buf.String(), "<pdfaid:part>1</pdfaid:part>")
+
+	// The default font of the creator is not embedded.
+	c = New()
+	require.NoError(t, c.Draw(c.NewParagraph("Helvetica")))
+	c.SetPdfA(&model.PdfAOptions{Conformance: model.PdfA1B})
+	err = c.Write(&bytes.Buffer{})
+	require.EqualError(t, err, "PDF/A-1b: font Helvetica is not embedded")
+}
+
+func TestCreatorStreaming(t *testing.T) {
+	c := New()
+	c.DrawFooter(func(footer *Block, args FooterFunctionArgs) {
+		p := c.NewParagraph(fmt.Sprintf("Footer %d of %d", args.PageNum, args.TotalPages))
+		p.SetPos(50, 20)
+		footer.Draw(p)
+	})
+	c.SetDocInfo(&model.PdfInfo{Title: core.MakeString("Streamed report")})
+
+	var buf bytes.Buffer
+	require.NoError(t, c.StartStreaming(&buf))
+	require.Error(t, c.StartStreaming(&buf))
+
+	for i := 1; i <= 3; i++ {
+		ch := c.NewChapter(fmt.Sprintf("Chapter %d", i))
+		for j := 0; j < 80; j++ {
+			ch.Add(c.NewParagraph(fmt.Sprintf("Chapter %d line %d", i, j)))
+		}
+		require.NoError(t, c.Draw(ch))
+
+		// Only the active page is kept.
+		require.Len(t, c.pages, 1)
+	}
+	require.Error(t, c.Write(&bytes.Buffer{}))
+	require.NoError(t, c.FinishStreaming())
+	require.Error(t, c.FinishStreaming())
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Greater(t, numPages, 3)
+
+	for i, page := range reader.PageList {
+		ex, err := extractor.New(page)
+		require.NoError(t, err)
+		text, err := ex.ExtractText()
+		require.NoError(t, err)
+		require.Contains(t, text, fmt.Sprintf("Footer %d of 0", i+1))
+	}
+
+	info, err := reader.GetPdfInfo()
+	require.NoError(t, err)
+	require.Equal(t, "Streamed report", info.Title.Decoded())
+
+	// The chapter outlines point to the written pages.
+	outlines, err := reader.GetOutlines()
+	require.NoError(t, err)
+	require.Len(t, outlines.Entries, 3)
+	require.Equal(t, int64(0), outlines.Entries[0].Dest.Page)
+	for _, entry := range outlines.Entries {
+		require.Less(t, int(entry.Dest.Page), numPages)
+	}
+	require.Greater(t, outlines.Entries[2].Dest.Page, outlines.Entries[1].Dest.Page)
+
+	// Images wrap between the streamed pages, and are written once.
+	c = New()
+	buf.Reset()
+	require.NoError(t, c.StartStreaming(&buf))
+	imgData, err := os.ReadFile(testImageFile1)
+	require.NoError(t, err)
+	img, err := c.NewImageFromData(imgData)
+	require.NoError(t, err)
+	for j := 0; j < 80; j++ {
+		img.ScaleToWidth(100 + 10*float64(j+1))
+		require.NoError(t, c.Draw(img))
+		require.Len(t, c.pages, 1)
+	}
+	require.NoError(t, c.FinishStreaming())
+
+	reader, err = model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Greater(t, len(reader.PageList), 20)
+	imgNums := map[int64]struct{}{}
+	for _, page := range reader.PageList {
+		xobjs, ok := core.GetDict(page.Resources.XObject)
+		require.True(t, ok)
+		for _, key := range xobjs.Keys() {
+			ximg, err := page.Resources.GetXObjectImageByName(key)
+			require.NoError(t, err)
+			_, err = ximg.ToImage()
+			require.NoError(t, err)
+			stream, ok := core.GetStream(xobjs.Get(key))
+			require.True(t, ok)
+			imgNums[stream.ObjectNumber] = struct{}{}
+		}
+	}
+	require.Len(t, imgNums, 1)
+
+	// Unsupported features.
+	c = New()
+	c.AddTOC = true
+	require.Error(t, c.StartStreaming(&bytes.Buffer{}))
+	c = New()
+	c.SetOptimizer(optimize.New(optimize.Options{}))
+	require.Error(t, c.StartStreaming(&bytes.Buffer{}))
+}
+
+// failingWriter is a writer failing once `n` bytes have been written.
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("write failed")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestCreatorStreamingWriteError(t *testing.T) {
+	c := New()
+	require.NoError(t, c.StartStreaming(&failingWriter{n: 100}))
+
+	// The pages are written when the next ones are started, by Draw or NewPage.
+	var err error
+	for i := 0; i < 200 && err == nil; i++ {
+		err = c.Draw(c.NewParagraph(fmt.Sprintf("Line %d", i)))
+	}
+	require.EqualError(t, err, "write failed")
+
+	c = New()
+	require.NoError(t, c.StartStreaming(&failingWriter{n: 100}))
+	require.NoError(t, c.Draw(c.NewParagraph("First page")))
+	c.NewPage()
+	require.EqualError(t, c.Draw(c.NewParagraph("Second page")), "write failed")
+	require.EqualError(t, c.FinishStreaming(), "write failed")
+}
@@ -12,7 +12,8 @@ import (
 )
 
 // Division is a container component which can wrap across multiple pages (unlike Block).
-// It can contain multiple Drawable components (currently supporting Paragraph and Image).
+// It can contain multiple Drawable components (currently supporting Paragraph, StyledParagraph,
+// Image, Barcode, Table, List and Division).
 //
 // The component stacking behavior is vertical, where the Drawables are drawn on top of each other.
 // Also supports horizontal stacking by activating the inline mode.
@@ -27,6 +28,10 @@ type Division struct {
 
 	// Controls whether the components are stacked horizontally
 	inline bool
+
+	// Controls whether the division is kept on one page, and on the page of the next drawable.
+	keepTogether bool
+	keepWithNext bool
 }
 
 // newDivision returns a new Division container component.
@@ -46,8 +51,44 @@ func (div *Division) SetInline(inline bool) {
 	div.inline = inline
 }
 
+// SetMargins sets the margins of the division.
+func (div *Division) SetMargins(left, right, top, bottom float64) {
+	div.margins.left = left
+	div.margins.right = right
+	div.margins.top = top
+	div.margins.bottom = bottom
+}
+
+// GetMargins returns the margins of the division: left, right, top, bottom.
+func (div *Division) GetMargins() (float64, float64, float64, float64) {
+	return div.margins.left, div.margins.right, div.margins.top, div.margins.bottom
+}
+
+// SetKeepTogether sets whether the division is kept on one page, starting on the next page if it
+// does not fit on the current one.
+func (div *Division) SetKeepTogether(keep bool) {
+	div.keepTogether = keep
+}
+
+// KeepTogether returns whether the division is kept on one page.
+func (div *Division) KeepTogether() bool {
+	return div.keepTogether
+}
+
+// SetKeepWithNext sets whether the division is kept on the page of the start of the next
+// drawable.
+func (div *Division) SetKeepWithNext(keep bool) {
+	div.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the division is kept on the page of the next drawable.
+func (div *Division) KeepWithNext() bool {
+	return div.keepWithNext
+}
+
 // Add adds a VectorDrawable to the Division container.
-// Currently supported VectorDrawables: *Paragraph, *StyledParagraph, *Image.
+// Currently supported VectorDrawables: *Paragraph, *StyledParagraph, *Image, *Barcode, *Table,
+// *List, *Division.
 func (div *Division) Add(d VectorDrawable) error {
 	supported := false
 
@@ -56,7 +97,9 @@ func (div *Division) Add(d VectorDrawable) error {
 		supported = true
 	case *StyledParagraph:
 		supported = true
-	case *Image:
+	case *Image, *Barcode:
+		supported = true
+	case *Table, *List, *Division:
 		supported = true
 	}
 
@@ -84,6 +127,16 @@ func (div *Division) Height() float64 {
 			p := t
 			compWidth += p.margins.left + p.margins.right
 			compHeight += p.margins.top + p.margins.bottom
+		case *Image:
+			compHeight += t.margins.top + t.margins.bottom
+		case *Barcode:
+			compHeight += t.margins.top + t.margins.bottom
+		case *Table:
+			compHeight += t.margins.top + t.margins.bottom
+		case *List:
+			compHeight += t.margins.top + t.margins.bottom
+		case *Division:
+			compHeight += t.margins.top + t.margins.bottom
 		}
 
 		// Vertical stacking.
@@ -94,6 +147,25 @@ func (div *Division) Height() float64 {
 	return yMax
 }
 
+// layoutHeight returns the height of the division, margins included, laid out in the width
+// `width`. The components are laid out on a mock page, which measures their actual heights
+// unlike Height.
+func (div *Division) layoutHeight(width float64) float64 {
+	const pageHeight = 1e6
+	ctx := DrawContext{
+		Page:       1,
+		Width:      width,
+		Height:     pageHeight,
+		PageWidth:  width,
+		PageHeight: pageHeight,
+	}
+	blocks, ctx, err := div.GeneratePageBlocks(ctx)
+	if err != nil || len(blocks) != 1 {
+		return div.Height() + div.margins.top + div.margins.bottom
+	}
+	return ctx.Y + div.margins.bottom
+}
+
 // Width is not used. Not used as a Division element is designed to fill into available width depending on
 // context.  Returns 0.
 func (div *Division) Width() float64 {
@@ -123,7 +195,24 @@ func (div *Division) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	tmpCtx := ctx
 	var lineHeight float64
 
-	for _, component := range div.components {
+	// Drawables kept with the next ones, in the vertical stacking.
+	var kept []Drawable
+	if !ctx.Inline {
+		for _, component := range div.components {
+			kept = append(kept, component)
+		}
+	}
+	runEnd := 0
+
+	for i, component := range div.components {
+		if !ctx.Inline && i >= runEnd {
+			n, blocks, c, err := breakKeptRun(kept[i:], pageblocks, ctx)
+			if err != nil {
+				return nil, ctx, err
+			}
+			pageblocks, ctx, runEnd = blocks, c, i+n
+		}
+
 		if ctx.Inline {
 			// Check whether the component fits on the current line.
 			if (ctx.X-divCtx.X)+component.Width() <= ctx.Width {
@@ -139,7 +228,7 @@ func (div *Division) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			}
 		}
 
-		newblocks, updCtx, err := component.GeneratePageBlocks(ctx)
+		newblocks, updCtx, err := generateKeptBlocks(component, ctx)
 		if err != nil {
 			common.Log.Debug("Error generating page blocks: %v", err)
 			return nil, ctx, err
@@ -46,4 +46,9 @@ type DrawContext struct {
 
 	// Controls whether the components are stacked horizontally
 	Inline bool
+
+	// Whether the blocks are generated on trial, to be discarded, e.g. to check whether
+	// drawables kept with the next ones fit on the page. Side effects such as calling the
+	// table footer functions are skipped.
+	trial bool
 }
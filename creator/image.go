@@ -53,6 +53,9 @@ type Image struct {
 
 	// Encoder
 	encoder core.StreamEncoder
+
+	// Controls whether the image is kept on the page of the next drawable.
+	keepWithNext bool
 }
 
 // newImage create a new image from a unidoc image (model.Image).
@@ -158,6 +161,17 @@ func (img *Image) GetMargins() (float64, float64, float64, float64) {
 	return img.margins.left, img.margins.right, img.margins.top, img.margins.bottom
 }
 
+// SetKeepWithNext sets whether the image is kept on the page of the start of the next
+// drawable.
+func (img *Image) SetKeepWithNext(keep bool) {
+	img.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the image is kept on the page of the next drawable.
+func (img *Image) KeepWithNext() bool {
+	return img.keepWithNext
+}
+
 // ConvertToBinary converts current image data into binary (Bi-level image) format.
 // If provided image is RGB or GrayScale the function converts it into binary image
 // using histogram auto threshold method.
@@ -0,0 +1,304 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"fmt"
+
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/contentstream/draw"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// ImportedPage is a drawable showing a page of an existing document, such as a letterhead or form
+// template, a stamp or a thumbnail. The page is drawn as displayed (clipped to its crop box and
+// rotated as specified by its Rotate attribute) and is clipped to the area of the drawable.
+//
+// To use an imported page as a template under generated content, draw it first on each page at
+// position (0, 0) with the dimensions of the page.
+type ImportedPage struct {
+	xform *model.XObjectForm
+
+	// Rotation angle.
+	angle float64
+
+	// The dimensions of the page, as placed on the PDF.
+	width, height float64
+
+	// The original dimensions of the page.
+	origWidth, origHeight float64
+
+	// Positioning: relative / absolute.
+	positioning positioning
+
+	// Horizontal alignment in relative positioning.
+	hAlignment HorizontalAlignment
+
+	// Absolute coordinates (when in absolute mode).
+	xPos float64
+	yPos float64
+
+	// Opacity (alpha value).
+	opacity float64
+
+	// Margins to be applied around the block when drawing on Page.
+	margins margins
+}
+
+// newImportedPage creates an ImportedPage drawing `page`.
+func newImportedPage(page *model.PdfPage) (*ImportedPage, error) {
+	xform, err := page.ToXObjectForm()
+	if err != nil {
+		return nil, err
+	}
+	width, height, err := page.GetDisplaySize()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ImportedPage{
+		xform:       xform,
+		origWidth:   width,
+		origHeight:  height,
+		width:       width,
+		height:      height,
+		opacity:     1.0,
+		positioning: positionRelative,
+	}, nil
+}
+
+// XObjectForm returns the Form XObject drawing the page.
+func (ip *ImportedPage) XObjectForm() *model.XObjectForm {
+	return ip.xform
+}
+
+// Height returns the height of the imported page on the document.
+func (ip *ImportedPage) Height() float64 {
+	return ip.height
+}
+
+// Width returns the width of the imported page on the document.
+func (ip *ImportedPage) Width() float64 {
+	return ip.width
+}
+
+// SetWidth sets the width of the imported page on the document.
+func (ip *ImportedPage) SetWidth(w float64) {
+	ip.width = w
+}
+
+// SetHeight sets the height of the imported page on the document.
+func (ip *ImportedPage) SetHeight(h float64) {
+	ip.height = h
+}
+
+// Scale scales the imported page by a constant factor, both width and height.
+func (ip *ImportedPage) Scale(xFactor, yFactor float64) {
+	ip.width = xFactor * ip.width
+	ip.height = yFactor * ip.height
+}
+
+// ScaleToWidth scales the imported page to width `w`, maintaining the aspect ratio.
+func (ip *ImportedPage) ScaleToWidth(w float64) {
+	ratio := ip.height / ip.width
+	ip.width = w
+	ip.height = w * ratio
+}
+
+// ScaleToHeight scales the imported page to height `h`, maintaining the aspect ratio.
+func (ip *ImportedPage) ScaleToHeight(h float64) {
+	ratio := ip.width / ip.height
+	ip.height = h
+	ip.width = h * ratio
+}
+
+// SetAngle sets the rotation angle of the imported page in degrees.
+func (ip *ImportedPage) SetAngle(angle float64) {
+	ip.angle = angle
+}
+
+// SetOpacity sets the opacity of the imported page.
+func (ip *ImportedPage) SetOpacity(opacity float64) {
+	ip.opacity = opacity
+}
+
+// SetPos sets the absolute position. Changes object positioning to absolute.
+func (ip *ImportedPage) SetPos(x, y float64) {
+	ip.positioning = positionAbsolute
+	ip.xPos = x
+	ip.yPos = y
+}
+
+// GetHorizontalAlignment returns the horizontal alignment of the imported page.
+func (ip *ImportedPage) GetHorizontalAlignment() HorizontalAlignment {
+	return ip.hAlignment
+}
+
+// SetHorizontalAlignment sets the horizontal alignment of the imported page.
+func (ip *ImportedPage) SetHorizontalAlignment(alignment HorizontalAlignment) {
+	ip.hAlignment = alignment
+}
+
+// SetMargins sets the margins of the imported page (in relative mode): left, right, top, bottom.
+func (ip *ImportedPage) SetMargins(left, right, top, bottom float64) {
+	ip.margins.left = left
+	ip.margins.right = right
+	ip.margins.top = top
+	ip.margins.bottom = bottom
+}
+
+// GetMargins returns the margins of the imported page: left, right, top, bottom.
+func (ip *ImportedPage) GetMargins() (float64, float64, float64, float64) {
+	return ip.margins.left, ip.margins.right, ip.margins.top, ip.margins.bottom
+}
+
+// rotatedSize returns the width and height of the rotated bounding box of the imported page.
+func (ip *ImportedPage) rotatedSize() (float64, float64) {
+	if ip.angle == 0 {
+		return ip.width, ip.height
+	}
+
+	bbox := draw.Path{Points: []draw.Point{
+		draw.NewPoint(0, 0).Rotate(ip.angle),
+		draw.NewPoint(ip.width, 0).Rotate(ip.angle),
+		draw.NewPoint(0, ip.height).Rotate(ip.angle),
+		draw.NewPoint(ip.width, ip.height).Rotate(ip.angle),
+	}}.GetBoundingBox()
+
+	return bbox.Width, bbox.Height
+}
+
+// GeneratePageBlocks draws the imported page on a block, implementing the Drawable interface.
+func (ip *ImportedPage) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
+	var blocks []*Block
+	origCtx := ctx
+
+	blk := NewBlock(ctx.PageWidth, ctx.PageHeight)
+	if ip.positioning.isRelative() {
+		_, rotatedHeight := ip.rotatedSize()
+		if rotatedHeight > ctx.Height {
+			// Goes out of the bounds. Continue on a new page at the upper left corner.
+			blocks = append(blocks, blk)
+			blk = NewBlock(ctx.PageWidth, ctx.PageHeight)
+
+			ctx.Page++
+			newContext := ctx
+			newContext.Y = ctx.Margins.top
+			newContext.X = ctx.Margins.left + ip.margins.left
+			newContext.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - ip.margins.bottom
+			newContext.Width = ctx.PageWidth - ctx.Margins.left - ctx.Margins.right - ip.margins.left - ip.margins.right
+			ctx = newContext
+		} else {
+			ctx.Y += ip.margins.top
+			ctx.Height -= ip.margins.top + ip.margins.bottom
+			ctx.X += ip.margins.left
+			ctx.Width -= ip.margins.left + ip.margins.right
+		}
+	} else {
+		// Absolute.
+		ctx.X = ip.xPos
+		ctx.Y = ip.yPos
+	}
+
+	ctx, err := drawImportedPageOnBlock(blk, ip, ctx)
+	if err != nil {
+		return nil, ctx, err
+	}
+	blocks = append(blocks, blk)
+
+	if ip.positioning.isAbsolute() {
+		// Absolute drawing should not affect context.
+		ctx = origCtx
+	} else {
+		ctx.Y += ip.margins.bottom
+		ctx.Height -= ip.margins.bottom
+	}
+
+	return blocks, ctx, nil
+}
+
+// drawImportedPageOnBlock draws the imported page `ip` on block `blk`.
+func drawImportedPageOnBlock(blk *Block, ip *ImportedPage, ctx DrawContext) (DrawContext, error) {
+	origCtx := ctx
+
+	// Find a free name for the form.
+	num := 1
+	formName := core.PdfObjectName(fmt.Sprintf("Pg%d", num))
+	for blk.resources.HasXObjectByName(formName) {
+		num++
+		formName = core.PdfObjectName(fmt.Sprintf("Pg%d", num))
+	}
+	if err := blk.resources.SetXObjectFormByName(formName, ip.xform); err != nil {
+		return ctx, err
+	}
+
+	contentCreator := contentstream.NewContentCreator()
+	if ip.opacity < 1.0 {
+		// Find an available GS name.
+		i := 0
+		gsName := core.PdfObjectName(fmt.Sprintf("GS%d", i))
+		for blk.resources.HasExtGState(gsName) {
+			i++
+			gsName = core.PdfObjectName(fmt.Sprintf("GS%d", i))
+		}
+
+		gs0 := core.MakeDict()
+		gs0.Set("CA", core.MakeFloat(ip.opacity))
+		gs0.Set("ca", core.MakeFloat(ip.opacity))
+		if err := blk.resources.AddExtGState(gsName, core.MakeIndirectObject(gs0)); err != nil {
+			return ctx, err
+		}
+		contentCreator.Add_gs(gsName)
+	}
+
+	width := ip.width
+	height := ip.height
+	_, rotatedHeight := ip.rotatedSize()
+
+	// Calculate x coordinate based on the alignment.
+	xPos := ctx.X
+	yPos := ctx.PageHeight - ctx.Y - height
+	if ip.positioning.isRelative() {
+		yPos -= (rotatedHeight - height) / 2
+
+		switch ip.hAlignment {
+		case HorizontalAlignmentCenter:
+			xPos += (ctx.Width - width) / 2
+		case HorizontalAlignmentRight:
+			xPos = ctx.PageWidth - ctx.Margins.right - ip.margins.right - width
+		}
+	}
+
+	contentCreator.Translate(xPos, yPos)
+	if ip.angle != 0 {
+		// Make rotation origin the center of the page.
+		contentCreator.Translate(width/2, height/2)
+		contentCreator.RotateDeg(ip.angle)
+		contentCreator.Translate(-width/2, -height/2)
+	}
+
+	// Clip to the area of the drawable and draw the form.
+	contentCreator.Add_re(0, 0, width, height).Add_W().Add_n()
+	if ip.origWidth > 0 && ip.origHeight > 0 {
+		contentCreator.Scale(width/ip.origWidth, height/ip.origHeight)
+	}
+	contentCreator.Add_Do(formName)
+
+	ops := contentCreator.Operations()
+	ops.WrapIfNeeded()
+
+	blk.addContents(ops)
+
+	if ip.positioning.isRelative() {
+		ctx.Y += rotatedHeight
+		ctx.Height -= rotatedHeight
+		return ctx, nil
+	}
+
+	// Absolute positioning - return original context.
+	return origCtx, nil
+}
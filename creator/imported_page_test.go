@@ -0,0 +1,70 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+func TestImportedPage(t *testing.T) {
+	// Template page.
+	helvetica, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	template := model.NewPdfPage()
+	template.MediaBox = &model.PdfRectangle{Urx: 300, Ury: 200}
+	require.NoError(t, template.AddFont("F1", helvetica.ToPdfObject()))
+	content := "BT /F1 12 Tf 10 150 Td (Letterhead) Tj ET"
+	require.NoError(t, template.SetContentStreams([]string{content}, core.NewFlateEncoder()))
+
+	c := New()
+	imported, err := c.NewImportedPage(template)
+	require.NoError(t, err)
+	require.Equal(t, 300.0, imported.Width())
+	require.Equal(t, 200.0, imported.Height())
+	imported.ScaleToWidth(150)
+	require.Equal(t, 100.0, imported.Height())
+
+	// Drawn under the generated content.
+	imported.SetPos(0, 0)
+	require.NoError(t, c.Draw(imported))
+	p := c.NewParagraph("Generated content")
+	p.SetPos(50, 300)
+	require.NoError(t, c.Draw(p))
+
+	// Thumbnail in relative positioning.
+	thumbnail, err := c.NewImportedPage(template)
+	require.NoError(t, err)
+	thumbnail.ScaleToWidth(60)
+	thumbnail.SetMargins(0, 0, 10, 0)
+	require.NoError(t, c.Draw(thumbnail))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	contents, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(contents, " Do"))
+	require.Contains(t, contents, "re\nW\nn\n")
+
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := ex.ExtractText()
+	require.NoError(t, err)
+	require.Contains(t, text, "Letterhead")
+	require.Contains(t, text, "Generated content")
+}
@@ -0,0 +1,153 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+// keptTogether is implemented by the drawables which can be kept on one page.
+type keptTogether interface {
+	KeepTogether() bool
+}
+
+// keptWithNext is implemented by the drawables which can be kept on the page of the next
+// drawable.
+type keptWithNext interface {
+	KeepWithNext() bool
+}
+
+// keepsTogether returns whether the drawable `d` is kept on one page.
+func keepsTogether(d Drawable) bool {
+	k, ok := d.(keptTogether)
+	return ok && k.KeepTogether()
+}
+
+// keepsWithNext returns whether the drawable `d` is kept on the page of the next drawable.
+func keepsWithNext(d Drawable) bool {
+	k, ok := d.(keptWithNext)
+	return ok && k.KeepWithNext()
+}
+
+// atPageTop returns whether the position of `ctx` is at the top of its page.
+func atPageTop(ctx DrawContext) bool {
+	return ctx.Y <= ctx.Margins.top+1e-6
+}
+
+// nextPageContext returns the context at the top of the page following the page of `ctx`, with
+// the same horizontal position and width.
+func nextPageContext(ctx DrawContext) DrawContext {
+	ctx.Page++
+	ctx.Y = ctx.Margins.top
+	ctx.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom
+	return ctx
+}
+
+// isEmptyBlock returns whether nothing was drawn on `blk`.
+func isEmptyBlock(blk *Block) bool {
+	return len(*blk.contents) == 0 && len(blk.annotations) == 0
+}
+
+// firstContentBlock returns the index of the first of `blocks` on which contents were drawn, the
+// previous blocks being left empty by drawables moving to the next page. Returns 0 if no contents
+// were drawn.
+func firstContentBlock(blocks []*Block) int {
+	for i, blk := range blocks {
+		if !isEmptyBlock(blk) {
+			return i
+		}
+	}
+	return 0
+}
+
+// trialContext returns `ctx` for generating blocks on trial.
+func trialContext(ctx DrawContext) DrawContext {
+	ctx.trial = true
+	return ctx
+}
+
+// generateKeptBlocks generates the page blocks of `d` from `ctx`. A drawable kept together
+// which is split between pages is moved to the next page if it fits on it. The drawable is laid
+// out on trial first, so that it is only drawn from the context it is kept at.
+func generateKeptBlocks(d Drawable, ctx DrawContext) ([]*Block, DrawContext, error) {
+	if !keepsTogether(d) || atPageTop(ctx) {
+		return d.GeneratePageBlocks(ctx)
+	}
+	blocks, _, err := d.GeneratePageBlocks(trialContext(ctx))
+	if err != nil {
+		return nil, ctx, err
+	}
+	if len(blocks) < 2 || firstContentBlock(blocks) > 0 {
+		return d.GeneratePageBlocks(ctx)
+	}
+
+	nextCtx := nextPageContext(ctx)
+	nextBlocks, _, err := d.GeneratePageBlocks(trialContext(nextCtx))
+	if err != nil {
+		return nil, ctx, err
+	}
+	if len(nextBlocks) > 1 {
+		// Does not fit on a page.
+		return d.GeneratePageBlocks(ctx)
+	}
+	nextBlocks, nextCtx, err = d.GeneratePageBlocks(nextCtx)
+	if err != nil {
+		return nil, ctx, err
+	}
+	return append([]*Block{NewBlock(ctx.PageWidth, ctx.PageHeight)}, nextBlocks...), nextCtx, nil
+}
+
+// keptRun returns the number of the drawables starting `drawables` kept with the next ones,
+// including the first one not kept with the next one.
+func keptRun(drawables []Drawable) int {
+	for i, d := range drawables {
+		if !keepsWithNext(d) {
+			return i + 1
+		}
+	}
+	return len(drawables)
+}
+
+// breaksKeptRun returns whether the drawables `run`, kept with the next ones but the last one,
+// are split between pages when laid out from `ctx`, i.e. whether they should start on the next
+// page. The run is laid out on trial. Runs containing chapters are not laid out, chapters adding
+// their headings to the table of contents and the outline when laid out.
+func breaksKeptRun(run []Drawable, ctx DrawContext) (bool, error) {
+	if len(run) < 2 || atPageTop(ctx) {
+		return false, nil
+	}
+	for _, d := range run {
+		if _, ok := d.(*Chapter); ok {
+			return false, nil
+		}
+	}
+
+	ctx = trialContext(ctx)
+	for i, d := range run {
+		blocks, newCtx, err := generateKeptBlocks(d, ctx)
+		if err != nil {
+			return false, err
+		}
+		if i > 0 && len(blocks) > 0 && firstContentBlock(blocks) > 0 {
+			return true, nil
+		}
+		ctx = newCtx
+	}
+	return false, nil
+}
+
+// breakKeptRun checks whether the run of the drawables starting `drawables` kept with the next
+// ones is split between pages when laid out from `ctx`. If so, the context is moved to the next
+// page, and an empty block for it is appended to `blocks`, the blocks of the pages from the page of
+// `ctx`. Returns the number of drawables of the run.
+func breakKeptRun(drawables []Drawable, blocks []*Block, ctx DrawContext) (int, []*Block, DrawContext, error) {
+	n := keptRun(drawables)
+	breaks, err := breaksKeptRun(drawables[:n], ctx)
+	if err != nil || !breaks {
+		return n, blocks, ctx, err
+	}
+	if len(blocks) == 0 {
+		blocks = append(blocks, NewBlock(ctx.PageWidth, ctx.PageHeight))
+	}
+	blocks = append(blocks, NewBlock(ctx.PageWidth, ctx.PageHeight))
+	return n, blocks, nextPageContext(ctx), nil
+}
@@ -0,0 +1,200 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	goimage "image"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// drawSpacer draws an image filling the current page but the height `remaining`.
+func drawSpacer(t *testing.T, c *Creator, remaining float64) {
+	img, err := c.NewImageFromGoImage(goimage.NewGray(goimage.Rect(0, 0, 1, 100)))
+	require.NoError(t, err)
+	img.ScaleToHeight(c.Context().Height - remaining)
+	require.NoError(t, c.Draw(img))
+	require.InDelta(t, remaining, c.Context().Height, 0.01)
+}
+
+// countPageWords returns the number of the numbered words of each page of the output of `c`.
+func countPageWords(t *testing.T, c *Creator) []int {
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	var counts []int
+	for _, words := range readColumnWords(t, buf.Bytes()) {
+		counts = append(counts, len(words))
+	}
+	return counts
+}
+
+// numberedLines returns a styled paragraph of `count` lines made of the numbered words from
+// `start`.
+func numberedLines(c *Creator, start, count int) *StyledParagraph {
+	p := c.NewStyledParagraph()
+	for i := 0; i < count; i++ {
+		text := fmt.Sprintf("w%04d", start+i)
+		if i < count-1 {
+			text += "\n"
+		}
+		p.Append(text)
+	}
+	return p
+}
+
+// failingDrawable is a drawable kept with the next one whose drawing fails.
+type failingDrawable struct{}
+
+func (failingDrawable) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error) {
+	return nil, ctx, errors.New("drawing failed")
+}
+
+func (failingDrawable) KeepWithNext() bool {
+	return true
+}
+
+func TestKeepWithNext(t *testing.T) {
+	t.Run("creator", func(t *testing.T) {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 25)
+
+		heading := numberedLines(c, 0, 1)
+		heading.SetKeepWithNext(true)
+		require.NoError(t, c.Draw(heading))
+		body := numberedLines(c, 1, 5)
+		body.SetOrphans(2)
+		require.NoError(t, c.Draw(body))
+		require.Equal(t, []int{0, 6}, countPageWords(t, c))
+	})
+
+	t.Run("division", func(t *testing.T) {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 25)
+
+		heading := numberedLines(c, 0, 1)
+		heading.SetKeepWithNext(true)
+		div := c.NewDivision()
+		require.NoError(t, div.Add(heading))
+		body := numberedLines(c, 1, 5)
+		body.SetOrphans(2)
+		require.NoError(t, div.Add(body))
+		require.NoError(t, c.Draw(div))
+		require.Equal(t, []int{0, 6}, countPageWords(t, c))
+	})
+
+	t.Run("chapter", func(t *testing.T) {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 25)
+
+		chapter := c.NewChapter("w0000")
+		body := numberedLines(c, 1, 5)
+		body.SetOrphans(2)
+		require.NoError(t, chapter.Add(body))
+		require.NoError(t, c.Draw(chapter))
+		require.Equal(t, []int{0, 6}, countPageWords(t, c))
+	})
+
+	t.Run("not kept", func(t *testing.T) {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 25)
+
+		require.NoError(t, c.Draw(numberedLines(c, 0, 1)))
+		body := numberedLines(c, 1, 5)
+		body.SetOrphans(2)
+		require.NoError(t, c.Draw(body))
+		require.Equal(t, []int{1, 5}, countPageWords(t, c))
+	})
+
+	t.Run("context", func(t *testing.T) {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 25)
+
+		// The drawables waiting for the next one are drawn by NewPage, not by Context.
+		ctx := c.Context()
+		heading := numberedLines(c, 0, 1)
+		heading.SetKeepWithNext(true)
+		require.NoError(t, c.Draw(heading))
+		require.Equal(t, ctx, c.Context())
+		c.NewPage()
+		require.NoError(t, c.Draw(numberedLines(c, 1, 5)))
+		require.Equal(t, []int{1, 5}, countPageWords(t, c))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := New()
+		require.NoError(t, c.Draw(failingDrawable{}))
+		require.EqualError(t, c.Draw(numberedLines(c, 0, 1)), "drawing failed")
+
+		// The error of the drawing by NewPage is returned by the next Draw, which still draws.
+		require.NoError(t, c.Draw(failingDrawable{}))
+		c.NewPage()
+		require.EqualError(t, c.Draw(numberedLines(c, 1, 5)), "drawing failed")
+		require.NoError(t, c.Draw(numberedLines(c, 6, 1)))
+		require.Equal(t, []int{0, 6}, countPageWords(t, c))
+	})
+}
+
+func TestKeepTogether(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, 50)
+
+		table := c.NewTable(1)
+		table.SetKeepTogether(keep)
+		for i := 0; i < 10; i++ {
+			cell := table.NewCell()
+			require.NoError(t, cell.SetContent(numberedLines(c, i, 1)))
+		}
+		require.NoError(t, c.Draw(table))
+
+		counts := countPageWords(t, c)
+		require.Len(t, counts, 2)
+		require.Equal(t, 10, counts[0]+counts[1])
+		if keep {
+			require.Zero(t, counts[0])
+		} else {
+			require.NotZero(t, counts[0])
+		}
+	}
+}
+
+func TestKeepTableFooterFunc(t *testing.T) {
+	c := New()
+	c.NewPage()
+	drawSpacer(t, c, 50)
+
+	heading := numberedLines(c, 0, 1)
+	heading.SetKeepWithNext(true)
+	require.NoError(t, c.Draw(heading))
+
+	table := c.NewTable(1)
+	table.SetKeepTogether(true)
+	require.NoError(t, table.SetFooterRows(11, 11))
+	for i := 1; i <= 11; i++ {
+		cell := table.NewCell()
+		require.NoError(t, cell.SetContent(numberedLines(c, i, 1)))
+	}
+
+	// The footer function is only called for the page the table is drawn on, not when the table
+	// is laid out on trial.
+	var calls []TableFooterFunctionArgs
+	table.SetFooterFunc(func(table *Table, args TableFooterFunctionArgs) {
+		calls = append(calls, args)
+	})
+	require.NoError(t, c.Draw(table))
+	require.Equal(t, []TableFooterFunctionArgs{{PageNum: 1, FirstRow: 1, LastRow: 10, LastPage: true}}, calls)
+	require.Equal(t, []int{0, 12}, countPageWords(t, c))
+}
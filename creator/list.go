@@ -23,6 +23,9 @@ type listItem struct {
 // - Paragraph
 // - StyledParagraph
 // - List
+// - Division
+// - Table
+// - Image
 type List struct {
 	// The items of the list.
 	items []*listItem
@@ -44,6 +47,10 @@ type List struct {
 
 	// Default style used for internal operations.
 	defaultStyle TextStyle
+
+	// Controls whether the list is kept on one page, and on the page of the next drawable.
+	keepTogether bool
+	keepWithNext bool
 }
 
 // newList returns a new instance of List.
@@ -61,7 +68,8 @@ func newList(style TextStyle) *List {
 }
 
 // Add appends a new item to the list.
-// The supported components are: *Paragraph, *StyledParagraph and *List.
+// The supported components are: *Paragraph, *StyledParagraph, *List, *Division, *Table and
+// *Image.
 // Returns the marker used for the newly added item. The returned marker
 // object can be used to change the text and style of the marker for the
 // current item.
@@ -78,6 +86,7 @@ func (l *List) Add(item VectorDrawable) (*TextChunk, error) {
 		if t.defaultIndent {
 			t.indent = 15
 		}
+	case *Division, *Table, *Image:
 	default:
 		return nil, errors.New("this type of drawable is not supported in list")
 	}
@@ -131,6 +140,28 @@ func (l *List) SetMargins(left, right, top, bottom float64) {
 	l.margins.bottom = bottom
 }
 
+// SetKeepTogether sets whether the list is kept on one page, starting on the next page if it
+// does not fit on the current one.
+func (l *List) SetKeepTogether(keep bool) {
+	l.keepTogether = keep
+}
+
+// KeepTogether returns whether the list is kept on one page.
+func (l *List) KeepTogether() bool {
+	return l.keepTogether
+}
+
+// SetKeepWithNext sets whether the list is kept on the page of the start of the next
+// drawable.
+func (l *List) SetKeepWithNext(keep bool) {
+	l.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the list is kept on the page of the next drawable.
+func (l *List) KeepWithNext() bool {
+	return l.keepWithNext
+}
+
 // Width is not used. The list component is designed to fill into the available
 // width depending on the context. Returns 0.
 func (l *List) Width() float64 {
@@ -168,6 +199,8 @@ func (l *List) tableHeight(width float64) float64 {
 
 			height += sp.Height() + sp.margins.top + sp.margins.bottom
 			height += 0.5 * sp.getTextHeight()
+		case *Division:
+			height += t.layoutHeight(width)
 		default:
 			height += item.drawable.Height()
 		}
@@ -200,7 +233,7 @@ func (l *List) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext, error
 	// Draw items.
 	table := newTable(2)
 	table.SetColumnWidths(markerWidth, 1-markerWidth)
-	table.SetMargins(l.indent, 0, 0, 0)
+	table.SetMargins(l.margins.left+l.indent, l.margins.right, l.margins.top, l.margins.bottom)
 
 	for i, item := range l.items {
 		cell := table.NewCell()
@@ -5,9 +5,14 @@
 package creator
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/extractor"
 	"github.com/carmel/unipdf/model"
 )
 
@@ -153,3 +158,59 @@ func TestListAdvanced(t *testing.T) {
 		t.Fatalf("Fail: %v\n", err)
 	}
 }
+
+func TestListNestedDivisions(t *testing.T) {
+	c := New()
+	c.NewPage()
+
+	// The items of nested lists are divisions of their text and sublists.
+	newList := func(items ...VectorDrawable) *List {
+		list := c.NewList()
+		for _, item := range items {
+			_, err := list.Add(item)
+			require.NoError(t, err)
+		}
+		return list
+	}
+	newText := func(text string) *StyledParagraph {
+		p := c.NewStyledParagraph()
+		p.Append(text)
+		return p
+	}
+	newDivision := func(drawables ...VectorDrawable) *Division {
+		div := c.NewDivision()
+		for _, d := range drawables {
+			require.NoError(t, div.Add(d))
+		}
+		return div
+	}
+	inner := newList(newText("first"), newDivision(newText("second"), newList(newText("nested"))))
+	list := newList(newDivision(newText("item"), inner), newText("after"))
+	require.NoError(t, c.Draw(list))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	page, err := r.GetPage(1)
+	require.NoError(t, err)
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+
+	// Each item is below the previous one.
+	tops := map[string]float64{}
+	text := pageText.Text()
+	for _, word := range []string{"item", "first", "second", "nested", "after"} {
+		offset := strings.Index(text, word)
+		require.GreaterOrEqual(t, offset, 0, word)
+		marks, err := pageText.Marks().RangeOffset(offset, offset+len(word))
+		require.NoError(t, err)
+		tops[word] = marks.Elements()[0].BBox.Ury
+	}
+	require.Greater(t, tops["item"], tops["first"])
+	require.Greater(t, tops["first"], tops["second"])
+	require.Greater(t, tops["second"], tops["nested"])
+	require.Greater(t, tops["nested"]-tops["after"], 10.0)
+}
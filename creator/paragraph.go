@@ -36,6 +36,12 @@ type Paragraph struct {
 	// Text alignment: Align left/right/center/justify.
 	alignment TextAlignment
 
+	// Base direction of the text.
+	direction TextDirection
+
+	// OpenType features of the text.
+	features FontFeatures
+
 	// Wrapping properties.
 	enableWrap bool
 	wrapWidth  float64
@@ -63,6 +69,9 @@ type Paragraph struct {
 
 	// Text lines after wrapping to available width.
 	textLines []string
+
+	// Controls whether the paragraph is kept on the page of the next drawable.
+	keepWithNext bool
 }
 
 // newParagraph create a new text paragraph. Uses default parameters: Helvetica, WinAnsiEncoding and
@@ -104,6 +113,19 @@ func (p *Paragraph) SetTextAlignment(align TextAlignment) {
 	p.alignment = align
 }
 
+// SetTextDirection sets the base direction of the text, which determines the display order of
+// text mixing left-to-right and right-to-left scripts (TextDirectionAuto default). The last line
+// of justified right-to-left text is aligned to the right.
+func (p *Paragraph) SetTextDirection(dir TextDirection) {
+	p.direction = dir
+}
+
+// SetFontFeatures sets the OpenType features of the text, e.g. kerning, ligatures or small
+// capitals. Features only apply to fonts supporting shaping.
+func (p *Paragraph) SetFontFeatures(features FontFeatures) {
+	p.features = features
+}
+
 // SetLineHeight sets the line height (1.0 default).
 func (p *Paragraph) SetLineHeight(lineheight float64) {
 	p.lineHeight = lineheight
@@ -168,6 +190,17 @@ func (p *Paragraph) GetMargins() (float64, float64, float64, float64) {
 	return p.margins.left, p.margins.right, p.margins.top, p.margins.bottom
 }
 
+// SetKeepWithNext sets whether the paragraph is kept on the page of the start of the next
+// drawable.
+func (p *Paragraph) SetKeepWithNext(keep bool) {
+	p.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the paragraph is kept on the page of the next drawable.
+func (p *Paragraph) KeepWithNext() bool {
+	return p.keepWithNext
+}
+
 // SetWidth sets the the Paragraph width. This is essentially the wrapping width, i.e. the width the
 // text can extend to prior to wrapping over to next line.
 func (p *Paragraph) SetWidth(width float64) {
@@ -190,8 +223,26 @@ func (p *Paragraph) Height() float64 {
 	return float64(len(p.textLines)) * p.lineHeight * p.fontSize
 }
 
+// textChunks returns the text of the paragraph as a single text chunk.
+func (p *Paragraph) textChunks(text string) []*TextChunk {
+	return []*TextChunk{NewTextChunk(text, TextStyle{
+		Font:     p.textFont,
+		FontSize: p.fontSize,
+		Features: p.features,
+	})}
+}
+
 // getTextWidth calculates the text width as if all in one line (not taking wrapping into account).
 func (p *Paragraph) getTextWidth() float64 {
+	if chunks := p.textChunks(p.text); requiresShaping(chunks, p.direction) {
+		w, _, err := shapedLineWidth(chunks, p.direction)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to shape text: %v", err)
+			return -1
+		}
+		return w
+	}
+
 	w := 0.0
 
 	for _, r := range p.text {
@@ -213,6 +264,15 @@ func (p *Paragraph) getTextWidth() float64 {
 
 // getTextLineWidth calculates the text width of a provided line of text.
 func (p *Paragraph) getTextLineWidth(line string) float64 {
+	if chunks := p.textChunks(p.text); requiresShaping(chunks, p.direction) {
+		width, _, err := shapedLineWidth(p.textChunks(line), resolveTextDirection(chunks, p.direction))
+		if err != nil {
+			common.Log.Debug("ERROR: unable to shape text: %v", err)
+			return -1
+		}
+		return width
+	}
+
 	var width float64
 	for _, r := range line {
 		// Ignore newline for this.. Handles as if all in one line.
@@ -257,12 +317,23 @@ func (p *Paragraph) wrapText() error {
 		return nil
 	}
 
-	chunk := NewTextChunk(p.text, TextStyle{
-		Font:     p.textFont,
-		FontSize: p.fontSize,
-	})
+	chunks := p.textChunks(p.text)
+	if requiresShaping(chunks, p.direction) {
+		lines, err := wrapShapedChunks(chunks, p.wrapWidth, resolveTextDirection(chunks, p.direction))
+		if err != nil {
+			return err
+		}
 
-	lines, err := chunk.Wrap(p.wrapWidth)
+		p.textLines = make([]string, len(lines))
+		for i, line := range lines {
+			for _, chunk := range line {
+				p.textLines[i] += chunk.Text
+			}
+		}
+		return nil
+	}
+
+	lines, err := chunks[0].Wrap(p.wrapWidth)
 	if err != nil {
 		return err
 	}
@@ -369,12 +440,25 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 		Add_Tf(fontName, p.fontSize).
 		Add_TL(p.fontSize * p.lineHeight)
 
+	// Right-to-left, bidirectional and complex script text is laid out shaped.
+	paraChunks := p.textChunks(p.text)
+	shaped := requiresShaping(paraChunks, p.direction)
+	direction := resolveTextDirection(paraChunks, p.direction)
+
 	for idx, line := range p.textLines {
 		if idx != 0 {
 			// Move to next line if not first.
 			cc.Add_Tstar()
 		}
 
+		if shaped {
+			isLastLine := idx == len(p.textLines)-1
+			if err := p.drawShapedLine(cc, line, direction, isLastLine); err != nil {
+				return ctx, err
+			}
+			continue
+		}
+
 		// Get width of the line (excluding spaces).
 		w := 0.0
 		spaces := 0
@@ -467,3 +551,41 @@ func drawParagraphOnBlock(blk *Block, p *Paragraph, ctx DrawContext) (DrawContex
 
 	return ctx, nil
 }
+
+// drawShapedLine draws the shaped text `line`, a line of direction `dir`, with the current font.
+func (p *Paragraph) drawShapedLine(cc *contentstream.ContentCreator, line string, dir TextDirection,
+	isLastLine bool) error {
+	chunks := p.textChunks(line)
+	runs, rtl, err := layoutShapedLine(chunks, dir)
+	if err != nil {
+		return err
+	}
+
+	var width float64
+	var spaces int
+	for _, run := range runs {
+		width += run.width
+		spaces += run.spaces
+	}
+
+	// Justified lines widen their spaces, except the last line which is aligned on the side the
+	// text starts from.
+	wrapWidth := p.wrapWidth * 1000.0
+	var offset, extraSpace float64
+	switch {
+	case p.alignment == TextAlignmentJustify && spaces > 0 && !isLastLine:
+		extraSpace = (wrapWidth - width) / float64(spaces)
+	case p.alignment == TextAlignmentRight, p.alignment == TextAlignmentJustify && rtl:
+		offset = wrapWidth - width
+	case p.alignment == TextAlignmentCenter:
+		offset = (wrapWidth - width) / 2
+	}
+	if offset != 0 {
+		cc.Add_TJ(core.MakeFloat(-offset / p.fontSize))
+	}
+
+	for _, run := range runs {
+		writeShapedRun(cc, run, &chunks[0].Style, extraSpace)
+	}
+	return nil
+}
@@ -8,8 +8,6 @@ package creator
 import (
 	"errors"
 	"fmt"
-	"strings"
-	"unicode"
 
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/contentstream"
@@ -33,6 +31,9 @@ type StyledParagraph struct {
 	// Text alignment: Align left/right/center/justify.
 	alignment TextAlignment
 
+	// Base direction of the text.
+	direction TextDirection
+
 	// The line relative height (default 1).
 	lineHeight float64
 
@@ -40,6 +41,21 @@ type StyledParagraph struct {
 	enableWrap bool
 	wrapWidth  float64
 
+	// Hyphenator of the words of wrapped lines, nil to only hyphenate at soft hyphens.
+	hyphenator Hyphenator
+
+	// Algorithm choosing the positions lines are broken at.
+	lineBreaking LineBreaking
+
+	// Minimum numbers of lines of the paragraph left at the bottom of a page (orphans) and at the
+	// top of the next page (widows) when it is split between pages.
+	orphans int
+	widows  int
+
+	// Controls whether the paragraph is kept on one page, and on the page of the next drawable.
+	keepTogether bool
+	keepWithNext bool
+
 	// defaultWrap defines whether wrapping has been defined explictly or whether default behavior should
 	// be observed. Default behavior depends on context: normally wrap is expected, except for example in
 	// table cells wrapping is off by default.
@@ -151,6 +167,13 @@ func (p *StyledParagraph) SetTextAlignment(align TextAlignment) {
 	p.alignment = align
 }
 
+// SetTextDirection sets the base direction of the text, which determines the display order of
+// text mixing left-to-right and right-to-left scripts (TextDirectionAuto default). The last line
+// of justified right-to-left text is aligned to the right.
+func (p *StyledParagraph) SetTextDirection(dir TextDirection) {
+	p.direction = dir
+}
+
 // SetLineHeight sets the line height (1.0 default).
 func (p *StyledParagraph) SetLineHeight(lineheight float64) {
 	p.lineHeight = lineheight
@@ -162,6 +185,57 @@ func (p *StyledParagraph) SetEnableWrap(enableWrap bool) {
 	p.defaultWrap = false
 }
 
+// SetHyphenator sets the hyphenator of the words of wrapped lines, e.g. the patterns of a
+// language loaded with hyphenation.Load. Lines are also broken at the soft hyphens (U+00AD) of the
+// text, which are displayed as hyphens only when a line is broken there. Words with soft hyphens
+// are not hyphenated by the hyphenator. Pass nil to only hyphenate at soft hyphens (default).
+func (p *StyledParagraph) SetHyphenator(hyphenator Hyphenator) {
+	p.hyphenator = hyphenator
+	p.wrapText()
+}
+
+// SetLineBreaking sets the algorithm choosing the positions lines are broken at
+// (LineBreakingGreedy default). LineBreakingOptimal evens the spacing of the lines over the whole
+// paragraph, avoiding large gaps between the words of justified text.
+func (p *StyledParagraph) SetLineBreaking(lineBreaking LineBreaking) {
+	p.lineBreaking = lineBreaking
+	p.wrapText()
+}
+
+// SetOrphans sets the minimum number of lines of the paragraph left at the bottom of a page when it
+// is split between pages. The paragraph starts on the next page if fewer lines fit.
+func (p *StyledParagraph) SetOrphans(lines int) {
+	p.orphans = lines
+}
+
+// SetWidows sets the minimum number of lines of the paragraph drawn at the top of a page when it
+// is split between pages. Lines are moved from the previous page if fewer lines are left.
+func (p *StyledParagraph) SetWidows(lines int) {
+	p.widows = lines
+}
+
+// SetKeepTogether sets whether the paragraph is kept on one page, starting on the next page if it
+// does not fit on the current one.
+func (p *StyledParagraph) SetKeepTogether(keep bool) {
+	p.keepTogether = keep
+}
+
+// KeepTogether returns whether the paragraph is kept on one page.
+func (p *StyledParagraph) KeepTogether() bool {
+	return p.keepTogether
+}
+
+// SetKeepWithNext sets whether the paragraph is kept on the page of the start of the next
+// drawable, e.g. for headings.
+func (p *StyledParagraph) SetKeepWithNext(keep bool) {
+	p.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the paragraph is kept on the page of the next drawable.
+func (p *StyledParagraph) KeepWithNext() bool {
+	return p.keepWithNext
+}
+
 // SetPos sets absolute positioning with specified coordinates.
 func (p *StyledParagraph) SetPos(x, y float64) {
 	p.positioning = positionAbsolute
@@ -270,10 +344,20 @@ func (p *StyledParagraph) getLineHeight(idx int) (capHeight, height float64) {
 // getTextWidth calculates the text width as if all in one line (not taking
 // wrapping into account).
 func (p *StyledParagraph) getTextWidth() float64 {
+	chunks := removeBreakHints(splitFallbackChunks(p.chunks))
+	if requiresShaping(chunks, p.direction) {
+		width, _, err := shapedLineWidth(chunks, p.direction)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to shape text: %v", err)
+			return -1
+		}
+		return width
+	}
+
 	var width float64
-	lenChunks := len(p.chunks)
+	lenChunks := len(chunks)
 
-	for i, chunk := range p.chunks {
+	for i, chunk := range chunks {
 		style := &chunk.Style
 		lenRunes := len(chunk.Text)
 
@@ -291,11 +375,11 @@ func (p *StyledParagraph) getTextWidth() float64 {
 				return -1
 			}
 
-			width += style.FontSize * metrics.Wx
+			width += style.glyphWidth(metrics.Wx)
 
 			// Do not add character spacing for the last character of the line.
-			if r != ' ' && (i != lenChunks-1 || j != lenRunes-1) {
-				width += style.CharSpacing * 1000.0
+			if r == ' ' || i != lenChunks-1 || j != lenRunes-1 {
+				width += style.spacing(r)
 			}
 		}
 	}
@@ -305,6 +389,16 @@ func (p *StyledParagraph) getTextWidth() float64 {
 
 // getTextLineWidth calculates the text width of a provided collection of text chunks.
 func (p *StyledParagraph) getTextLineWidth(line []*TextChunk) float64 {
+	line = splitFallbackChunks(line)
+	if requiresShaping(line, p.direction) {
+		width, _, err := shapedLineWidth(line, p.direction)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to shape text: %v", err)
+			return -1
+		}
+		return width
+	}
+
 	var width float64
 	lenChunks := len(line)
 
@@ -326,11 +420,11 @@ func (p *StyledParagraph) getTextLineWidth(line []*TextChunk) float64 {
 				return -1
 			}
 
-			width += style.FontSize * metrics.Wx
+			width += style.glyphWidth(metrics.Wx)
 
 			// Do not add character spacing for the last character of the line.
-			if r != ' ' && (i != lenChunks-1 || j != lenRunes-1) {
-				width += style.CharSpacing * 1000.0
+			if r == ' ' || i != lenChunks-1 || j != lenRunes-1 {
+				width += style.spacing(r)
 			}
 		}
 	}
@@ -368,143 +462,51 @@ func (p *StyledParagraph) getTextHeight() float64 {
 	return height
 }
 
-// wrapText splits text into lines. It uses a simple greedy algorithm to wrap
-// fill the lines.
-// TODO: Consider the Knuth/Plass algorithm or an alternative.
+// wrapText splits text into lines. Lines are broken at the line break opportunities of the
+// Unicode line breaking algorithm, e.g. after spaces or between ideographs, and at the soft
+// hyphens and hyphenation positions of words, the algorithm set with SetLineBreaking choosing
+// among them. Words longer than a line are broken on the character.
 func (p *StyledParagraph) wrapText() error {
+	lines, _, err := p.wrapLines()
+	p.lines = lines
+	return err
+}
+
+// wrapLines wraps the text of the paragraph like wrapText, returning the lines with the indices of
+// their first runes in the text of the paragraph.
+func (p *StyledParagraph) wrapLines() ([][]*TextChunk, []int, error) {
+	// The text of the chunks is split in runs of the fonts displaying it.
+	chunks := splitFallbackChunks(p.chunks)
 	if !p.enableWrap || int(p.wrapWidth) <= 0 {
-		p.lines = [][]*TextChunk{p.chunks}
-		return nil
+		return [][]*TextChunk{removeBreakHints(chunks)}, []int{0}, nil
 	}
 
-	p.lines = [][]*TextChunk{}
-	var line []*TextChunk
-	var lineWidth float64
-
-	copyAnnotation := func(src *model.PdfAnnotation) *model.PdfAnnotation {
-		if src == nil {
-			return nil
-		}
-
-		var annotation *model.PdfAnnotation
-		switch t := src.GetContext().(type) {
-		case *model.PdfAnnotationLink:
-			if annot := copyLinkAnnotation(t); annot != nil {
-				annotation = annot.PdfAnnotation
-			}
-		}
+	return wrapChunkLines(chunks, wrapOptions{
+		width:        p.wrapWidth,
+		direction:    resolveTextDirection(chunks, p.direction),
+		shaped:       requiresShaping(chunks, p.direction),
+		justify:      p.alignment == TextAlignmentJustify,
+		hyphenator:   p.hyphenator,
+		lineBreaking: p.lineBreaking,
+	})
+}
 
-		return annotation
+// copyAnnotation returns a copy of the annotation `src` of a text chunk, for the parts of the
+// chunk wrapped on several lines.
+func copyAnnotation(src *model.PdfAnnotation) *model.PdfAnnotation {
+	if src == nil {
+		return nil
 	}
 
-	for _, chunk := range p.chunks {
-		style := chunk.Style
-		annotation := chunk.annotation
-
-		var (
-			part   []rune
-			widths []float64
-		)
-
-		for _, r := range chunk.Text {
-			// newline wrapping.
-			if r == '\u000A' { // LF
-				// moves to next line.
-				line = append(line, &TextChunk{
-					Text:       strings.TrimRightFunc(string(part), unicode.IsSpace),
-					Style:      style,
-					annotation: copyAnnotation(annotation),
-				})
-				p.lines = append(p.lines, line)
-				line = nil
-
-				lineWidth = 0
-				part = nil
-				widths = nil
-				continue
-			}
-			isSpace := r == ' '
-
-			metrics, found := style.Font.GetRuneMetrics(r)
-			if !found {
-				common.Log.Debug("Rune char metrics not found! %v\n", r)
-				return errors.New("glyph char metrics missing")
-			}
-			w := style.FontSize * metrics.Wx
-
-			charWidth := w
-			if !isSpace {
-				charWidth = w + style.CharSpacing*1000.0
-			}
-
-			if lineWidth+w > p.wrapWidth*1000.0 {
-				// Goes out of bounds: Wrap.
-				// Breaks on the character.
-				// TODO: when goes outside: back up to next space,
-				// otherwise break on the character.
-				idx := -1
-				if !isSpace {
-					for j := len(part) - 1; j >= 0; j-- {
-						if part[j] == ' ' {
-							idx = j
-							break
-						}
-					}
-				}
-
-				text := string(part)
-				if idx >= 0 {
-					text = string(part[0 : idx+1])
-
-					part = part[idx+1:]
-					part = append(part, r)
-					widths = widths[idx+1:]
-					widths = append(widths, charWidth)
-
-					lineWidth = 0
-					for _, width := range widths {
-						lineWidth += width
-					}
-				} else {
-					if isSpace {
-						lineWidth = 0
-						part = []rune{}
-						widths = []float64{}
-					} else {
-						lineWidth = charWidth
-						part = []rune{r}
-						widths = []float64{charWidth}
-					}
-				}
-
-				line = append(line, &TextChunk{
-					Text:       strings.TrimRightFunc(string(text), unicode.IsSpace),
-					Style:      style,
-					annotation: copyAnnotation(annotation),
-				})
-				p.lines = append(p.lines, line)
-				line = []*TextChunk{}
-			} else {
-				lineWidth += charWidth
-				part = append(part, r)
-				widths = append(widths, charWidth)
-			}
-		}
-
-		if len(part) > 0 {
-			line = append(line, &TextChunk{
-				Text:       string(part),
-				Style:      style,
-				annotation: copyAnnotation(annotation),
-			})
+	var annotation *model.PdfAnnotation
+	switch t := src.GetContext().(type) {
+	case *model.PdfAnnotationLink:
+		if annot := copyLinkAnnotation(t); annot != nil {
+			annotation = annot.PdfAnnotation
 		}
 	}
 
-	if len(line) > 0 {
-		p.lines = append(p.lines, line)
-	}
-
-	return nil
+	return annotation
 }
 
 // GeneratePageBlocks generates the page blocks. Multiple blocks are generated
@@ -543,26 +545,35 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 
 	// Draw paragraph blocks.
 	lines := p.lines
-	for {
+	for first := true; ; first = false {
+		// The lines fitting on the block, with widow and orphan control.
+		count := len(lines)
+		if p.positioning.isRelative() {
+			// Lines at the top of a page are not moved to the next page.
+			count = p.splitLines(p.fittingLines(lines, ctx.Height), len(lines), first && !atPageTop(origContext))
+		}
+
 		// Draw paragraph on block.
-		newCtx, remaining, err := drawStyledParagraphOnBlock(blk, p, lines, ctx)
-		if err != nil {
-			common.Log.Debug("ERROR: %v", err)
-			return nil, ctx, err
+		if count > 0 {
+			newCtx, _, err := drawStyledParagraphOnBlock(blk, p, lines[:count], ctx)
+			if err != nil {
+				common.Log.Debug("ERROR: %v", err)
+				return nil, ctx, err
+			}
+			ctx = newCtx
 		}
-		ctx = newCtx
 		blocks = append(blocks, blk)
 
 		// If the current block height was not sufficient for all the paragraph
 		// lines, create a new block to draw the remaining lines on.
-		if lines = remaining; len(remaining) == 0 {
+		if lines = lines[count:]; len(lines) == 0 {
 			break
 		}
 
 		// Create new page block.
 		blk = NewBlock(ctx.PageWidth, ctx.PageHeight)
 		ctx.Page++
-		newCtx = ctx
+		newCtx := ctx
 		newCtx.Y = ctx.Margins.top
 		newCtx.X = ctx.Margins.left + p.margins.left
 		newCtx.Height = ctx.PageHeight - ctx.Margins.top - ctx.Margins.bottom - p.margins.bottom
@@ -579,6 +590,118 @@ func (p *StyledParagraph) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawCon
 	return blocks, origContext, nil
 }
 
+// wrappedLineHeight returns the height of the wrapped `line` of the paragraph.
+func (p *StyledParagraph) wrappedLineHeight(line []*TextChunk) float64 {
+	var height float64
+	for _, chunk := range line {
+		if chunk.Style.FontSize > height {
+			height = chunk.Style.FontSize
+		}
+	}
+	return height * p.lineHeight
+}
+
+// linesHeight returns the height of the wrapped `lines` of the paragraph.
+func (p *StyledParagraph) linesHeight(lines [][]*TextChunk) float64 {
+	var height float64
+	for _, line := range lines {
+		height += p.wrappedLineHeight(line)
+	}
+	return height
+}
+
+// fittingLines returns the number of the first `lines` of the paragraph fitting in `height`.
+func (p *StyledParagraph) fittingLines(lines [][]*TextChunk, height float64) int {
+	var totalHeight float64
+	for i, line := range lines {
+		lineHeight := p.wrappedLineHeight(line)
+		if totalHeight+lineHeight > height {
+			return i
+		}
+		totalHeight += lineHeight
+	}
+	return len(lines)
+}
+
+// splitLines returns the number of the `total` lines left to draw of the paragraph to draw on a
+// page on which `fit` lines fit, leaving at least as many lines as set by SetOrphans on the page
+// of the `first` lines of the paragraph and by SetWidows on the next page if possible. The first
+// lines are moved to the next page when none are drawn, at least one line is drawn on the next
+// pages.
+func (p *StyledParagraph) splitLines(fit, total int, first bool) int {
+	if fit >= total {
+		return total
+	}
+
+	count := fit
+	if total-count < p.widows {
+		count = total - p.widows
+	}
+	if first {
+		if count < p.orphans || count < 1 {
+			return 0
+		}
+		return count
+	}
+	if count < 1 {
+		count = fit
+	}
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// drawPartOnBlock draws on `blk` the lines of the paragraph fitting the area of `ctx`, wrapped at
+// its width, and returns the updated context with a paragraph of the text left to draw, nil if
+// all the text was drawn. The top margin is applied to the `first` part of the paragraph only, and
+// the first line is drawn if `force` is true even if it does not fit. Returns false if no line
+// was drawn.
+func (p *StyledParagraph) drawPartOnBlock(blk *Block, ctx DrawContext, first, force bool) (DrawContext,
+	*StyledParagraph, bool, error) {
+	origCtx := ctx
+	if first {
+		ctx.Y += p.margins.top
+		ctx.Height -= p.margins.top
+	}
+	ctx.X += p.margins.left
+	ctx.Width -= p.margins.left + p.margins.right
+	ctx.Height -= p.margins.bottom
+
+	part := *p
+	part.SetWidth(ctx.Width)
+	lines, offsets, err := part.wrapLines()
+	if err != nil {
+		return origCtx, nil, false, err
+	}
+
+	// Count the lines fitting in the available height.
+	fit := p.fittingLines(lines, ctx.Height)
+	count := p.splitLines(fit, len(lines), first && !force)
+	if fit == 0 && !force {
+		count = 0
+	}
+	if count == 0 {
+		return origCtx, p, false, nil
+	}
+
+	ctx.Height = p.linesHeight(lines[:count])
+	newCtx, _, err := drawStyledParagraphOnBlock(blk, &part, lines[:count], ctx)
+	if err != nil {
+		return origCtx, nil, false, err
+	}
+	newCtx.X = origCtx.X
+	newCtx.Width = origCtx.Width
+	newCtx.Height = origCtx.Height - (newCtx.Y - origCtx.Y)
+	if count == len(lines) {
+		return newCtx, nil, true, nil
+	}
+
+	rest := *p
+	rest.chunks = sliceChunks(splitFallbackChunks(p.chunks), offsets[count])
+	return newCtx, &rest, true, nil
+}
+
 // Draw block on specified location on Page, adding to the content stream.
 func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextChunk, ctx DrawContext) (DrawContext, [][]*TextChunk, error) {
 	// Find first free index for the font resources of the paragraph.
@@ -653,6 +776,15 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 
 	cc.Add_BT()
 
+	// Right-to-left, bidirectional and complex script text is laid out shaped.
+	chunks := splitFallbackChunks(p.chunks)
+	shaped := requiresShaping(chunks, p.direction)
+	direction := resolveTextDirection(chunks, p.direction)
+
+	// The runs of text drawn with decorations, which lines are drawn over the text and
+	// backgrounds under it.
+	var decorations []textDecoration
+
 	currY := yPos
 	for idx, line := range lines {
 		currX := ctx.X
@@ -664,6 +796,23 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 
 		isLastLine := idx == len(lines)-1
 
+		if shaped {
+			var height float64
+			for _, chunk := range line {
+				if h := chunk.Style.FontSize * p.lineHeight; h > height {
+					height = h
+				}
+			}
+			lineDecorations, err := p.drawShapedLine(blk, cc, line, fonts[idx], direction, ctx, currX, currY,
+				yPos, height, isLastLine, defaultFontName, defaultFontSize)
+			if err != nil {
+				return ctx, nil, err
+			}
+			decorations = append(decorations, lineDecorations...)
+			currY -= height
+			continue
+		}
+
 		// Get width of the line (excluding spaces).
 		var (
 			width      float64
@@ -703,18 +852,18 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 					return ctx, nil, errors.New("unsupported text glyph")
 				}
 
-				chunkWidth += style.FontSize * metrics.Wx
+				chunkWidth += style.glyphWidth(metrics.Wx)
 
 				// Do not add character spacing for the last character of the line.
 				if i != lenChunk-1 {
-					chunkWidth += style.CharSpacing * 1000.0
+					chunkWidth += style.spacing(r)
 				}
 			}
 
 			chunkWidths = append(chunkWidths, chunkWidth)
 			width += chunkWidth
 
-			spaceWidth += float64(chunkSpaces) * spaceMetrics.Wx * style.FontSize
+			spaceWidth += float64(chunkSpaces) * (style.glyphWidth(spaceMetrics.Wx) + style.spacing(' '))
 			spaces += chunkSpaces
 		}
 		height *= p.lineHeight
@@ -723,12 +872,8 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 		var objs []core.PdfObject
 
 		wrapWidth := p.wrapWidth * 1000.0
-		if p.alignment == TextAlignmentJustify {
-			// Do not justify last line.
-			if spaces > 0 && !isLastLine {
-				spaceWidth = (wrapWidth - width) / float64(spaces) / defaultFontSize
-			}
-		} else if p.alignment == TextAlignmentCenter {
+		justify := p.alignment == TextAlignmentJustify && spaces > 0 && !isLastLine
+		if p.alignment == TextAlignmentCenter {
 			// Start with an offset of half of the remaining line space.
 			offset := (wrapWidth - width - spaceWidth) / 2
 			shift := offset / defaultFontSize
@@ -755,8 +900,6 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			style := &chunk.Style
 
 			r, g, b := style.Color.ToRGB()
-			fontName := defaultFontName
-			fontSize := defaultFontSize
 
 			// Set chunk rendering mode.
 			cc.Add_Tr(int64(style.RenderingMode))
@@ -764,15 +907,18 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			// Set chunk character spacing.
 			cc.Add_Tc(style.CharSpacing)
 
-			if p.alignment != TextAlignmentJustify || isLastLine {
-				spaceMetrics, found := style.Font.GetRuneMetrics(' ')
-				if !found {
-					return ctx, nil, errors.New("the font does not have a space glyph")
-				}
+			// Set chunk scaling, rise and outline.
+			setTextState(cc, style)
 
-				fontName = fonts[idx][k]
-				fontSize = style.FontSize
-				spaceWidth = spaceMetrics.Wx
+			// The spaces are shown as offsets of their advance, in thousandths of points,
+			// widened to fill the line of justified text.
+			spaceMetrics, found := style.Font.GetRuneMetrics(' ')
+			if !found {
+				return ctx, nil, errors.New("the font does not have a space glyph")
+			}
+			spaceAdvance := style.glyphWidth(spaceMetrics.Wx) + style.spacing(' ')
+			if justify {
+				spaceAdvance = (wrapWidth - width) / float64(spaces)
 			}
 			enc := style.Font.Encoder()
 
@@ -791,11 +937,13 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 						encStr = nil
 					}
 
-					cc.Add_Tf(fontName, fontSize).
-						Add_TL(fontSize * p.lineHeight).
-						Add_TJ([]core.PdfObject{core.MakeFloat(-spaceWidth)}...)
+					// The offsets are scaled horizontally along with the glyphs.
+					shift := spaceAdvance / style.FontSize / style.horizontalScale()
+					cc.Add_Tf(fonts[idx][k], style.FontSize).
+						Add_TL(style.FontSize * p.lineHeight).
+						Add_TJ([]core.PdfObject{core.MakeFloat(-shift)}...)
 
-					chunkWidths[k] += spaceWidth * fontSize
+					chunkWidths[k] += spaceAdvance
 				} else {
 					if _, ok := enc.RuneToCharcode(rn); !ok {
 						common.Log.Debug("unsupported rune in text encoding: %#x (%c)", rn, rn)
@@ -815,57 +963,15 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 			chunkWidth := chunkWidths[k] / 1000.0
 
 			// Add annotations.
-			if chunk.annotation != nil {
-				var annotRect *core.PdfObjectArray
-
-				// Process annotation.
-				if !chunk.annotationProcessed {
-					switch t := chunk.annotation.GetContext().(type) {
-					case *model.PdfAnnotationLink:
-						// Initialize annotation rectangle.
-						annotRect = core.MakeArray()
-						t.Rect = annotRect
-
-						// Reverse the Y axis of the destination coordinates.
-						// The user passes in the annotation coordinates as if
-						// position 0, 0 is at the top left of the page.
-						// However, position 0, 0 in the PDF is at the bottom
-						// left of the page.
-						annotDest, ok := t.Dest.(*core.PdfObjectArray)
-						if ok && annotDest.Len() == 5 {
-							t, ok := annotDest.Get(1).(*core.PdfObjectName)
-							if ok && t.String() == "XYZ" {
-								y, err := core.GetNumberAsFloat(annotDest.Get(3))
-								if err == nil {
-									annotDest.Set(3, core.MakeFloat(ctx.PageHeight-y))
-								}
-							}
-						}
-					}
-
-					chunk.annotationProcessed = true
-				}
-
-				// Set the coordinates of the annotation.
-				if annotRect != nil {
-					// Calculate rotated annotation position.
-					annotPos := draw.NewPoint(currX-ctx.X, currY-yPos).Rotate(p.angle)
-					annotPos.X += ctx.X
-					annotPos.Y += yPos
-
-					// Calculate rotated annotation bounding box.
-					offX, offY, annotW, annotH := rotateRect(chunkWidth, height, p.angle)
-					annotPos.X += offX
-					annotPos.Y += offY
-
-					annotRect.Clear()
-					annotRect.Append(core.MakeFloat(annotPos.X))
-					annotRect.Append(core.MakeFloat(annotPos.Y))
-					annotRect.Append(core.MakeFloat(annotPos.X + annotW))
-					annotRect.Append(core.MakeFloat(annotPos.Y + annotH))
-				}
-
-				blk.AddAnnotation(chunk.annotation)
+			p.addChunkAnnotation(blk, chunk, ctx, currX, currY, yPos, chunkWidth, height)
+
+			if style.hasDecorations() {
+				decorations = append(decorations, textDecoration{
+					x:        currX - ctx.X,
+					baseline: currY - yPos,
+					width:    chunkWidth,
+					style:    style,
+				})
 			}
 
 			currX += chunkWidth
@@ -875,13 +981,30 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 
 			// Reset character spacing.
 			cc.Add_Tc(0)
+
+			// Reset scaling, rise and outline.
+			resetTextState(cc, style)
 		}
 
 		currY -= height
 	}
 	cc.Add_ET()
+	drawTextDecorations(cc, decorations)
 	cc.Add_Q()
 
+	// The backgrounds of the text are filled before drawing it.
+	if len(decorations) > 0 {
+		bg := contentstream.NewContentCreator()
+		bg.Add_q()
+		bg.Translate(ctx.X, yPos)
+		if p.angle != 0 {
+			bg.RotateDeg(p.angle)
+		}
+		drawTextBackgrounds(bg, decorations)
+		bg.Add_Q()
+		blk.addContents(bg.Operations())
+	}
+
 	ops := cc.Operations()
 	ops.WrapIfNeeded()
 
@@ -900,3 +1023,139 @@ func drawStyledParagraphOnBlock(blk *Block, p *StyledParagraph, lines [][]*TextC
 
 	return ctx, nextBlockLines, nil
 }
+
+// drawShapedLine draws the shaped text of `line`, a line of direction `dir` drawn with the fonts
+// `fontNames`, at the position (currX, currY). The alignment offsets are applied with the default
+// font of the paragraph. The runs drawn with decorations are returned.
+func (p *StyledParagraph) drawShapedLine(blk *Block, cc *contentstream.ContentCreator, line []*TextChunk,
+	fontNames []core.PdfObjectName, dir TextDirection, ctx DrawContext, currX, currY, yPos, height float64,
+	isLastLine bool, defaultFontName core.PdfObjectName, defaultFontSize float64) ([]textDecoration, error) {
+	runs, rtl, err := layoutShapedLine(line, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var width float64
+	var spaces int
+	for _, run := range runs {
+		width += run.width
+		spaces += run.spaces
+	}
+
+	// Justified lines widen their spaces, except the last line which is aligned on the side the
+	// text starts from.
+	wrapWidth := p.wrapWidth * 1000.0
+	var offset, extraSpace float64
+	switch {
+	case p.alignment == TextAlignmentJustify && spaces > 0 && !isLastLine:
+		extraSpace = (wrapWidth - width) / float64(spaces)
+	case p.alignment == TextAlignmentRight, p.alignment == TextAlignmentJustify && rtl:
+		offset = wrapWidth - width
+	case p.alignment == TextAlignmentCenter:
+		offset = (wrapWidth - width) / 2
+	}
+	if offset != 0 {
+		cc.Add_Tf(defaultFontName, defaultFontSize).
+			Add_TL(defaultFontSize * p.lineHeight).
+			Add_TJ(core.MakeFloat(-offset / defaultFontSize))
+		currX += offset / 1000.0
+	}
+
+	// Render the runs, recording the extent of each chunk for its annotation.
+	extents := map[int][2]float64{}
+	var decorations []textDecoration
+	for _, run := range runs {
+		style := &line[run.chunk].Style
+		r, g, b := style.Color.ToRGB()
+		cc.Add_rg(r, g, b).
+			Add_Tf(fontNames[run.chunk], style.FontSize).
+			Add_TL(style.FontSize * p.lineHeight).
+			Add_Tr(int64(style.RenderingMode))
+		writeShapedRun(cc, run, style, extraSpace)
+
+		runWidth := (run.width + float64(run.spaces)*extraSpace) / 1000.0
+		ext, ok := extents[run.chunk]
+		if !ok {
+			ext = [2]float64{currX, currX}
+		}
+		ext[1] = currX + runWidth
+		extents[run.chunk] = ext
+		if style.hasDecorations() {
+			decorations = append(decorations, textDecoration{
+				x:        currX - ctx.X,
+				baseline: currY - yPos,
+				width:    runWidth,
+				style:    style,
+			})
+		}
+		currX += runWidth
+	}
+	cc.Add_Tr(int64(TextRenderingModeFill))
+
+	for k, chunk := range line {
+		if ext, ok := extents[k]; ok {
+			p.addChunkAnnotation(blk, chunk, ctx, ext[0], currY, yPos, ext[1]-ext[0], height)
+		}
+	}
+	return decorations, nil
+}
+
+// addChunkAnnotation adds the annotation of `chunk`, drawn at (currX, currY) with the size
+// `chunkWidth` x `height`, to `blk`. `yPos` is the vertical position of the paragraph.
+func (p *StyledParagraph) addChunkAnnotation(blk *Block, chunk *TextChunk, ctx DrawContext,
+	currX, currY, yPos, chunkWidth, height float64) {
+	if chunk.annotation == nil {
+		return
+	}
+
+	var annotRect *core.PdfObjectArray
+
+	// Process annotation.
+	if !chunk.annotationProcessed {
+		switch t := chunk.annotation.GetContext().(type) {
+		case *model.PdfAnnotationLink:
+			// Initialize annotation rectangle.
+			annotRect = core.MakeArray()
+			t.Rect = annotRect
+
+			// Reverse the Y axis of the destination coordinates.
+			// The user passes in the annotation coordinates as if
+			// position 0, 0 is at the top left of the page.
+			// However, position 0, 0 in the PDF is at the bottom
+			// left of the page.
+			annotDest, ok := t.Dest.(*core.PdfObjectArray)
+			if ok && annotDest.Len() == 5 {
+				t, ok := annotDest.Get(1).(*core.PdfObjectName)
+				if ok && t.String() == "XYZ" {
+					y, err := core.GetNumberAsFloat(annotDest.Get(3))
+					if err == nil {
+						annotDest.Set(3, core.MakeFloat(ctx.PageHeight-y))
+					}
+				}
+			}
+		}
+
+		chunk.annotationProcessed = true
+	}
+
+	// Set the coordinates of the annotation.
+	if annotRect != nil {
+		// Calculate rotated annotation position.
+		annotPos := draw.NewPoint(currX-ctx.X, currY-yPos).Rotate(p.angle)
+		annotPos.X += ctx.X
+		annotPos.Y += yPos
+
+		// Calculate rotated annotation bounding box.
+		offX, offY, annotW, annotH := rotateRect(chunkWidth, height, p.angle)
+		annotPos.X += offX
+		annotPos.Y += offY
+
+		annotRect.Clear()
+		annotRect.Append(core.MakeFloat(annotPos.X))
+		annotRect.Append(core.MakeFloat(annotPos.Y))
+		annotRect.Append(core.MakeFloat(annotPos.X + annotW))
+		annotRect.Append(core.MakeFloat(annotPos.Y + annotH))
+	}
+
+	blk.AddAnnotation(chunk.annotation)
+}
@@ -897,3 +897,30 @@ func TestStyledParagraphMultiBlock(t *testing.T) {
 	// Write output file.
 	testWriteAndRender(t, c, "styled_paragraph_multiblock.pdf")
 }
+
+func TestStyledParagraphWidowsOrphans(t *testing.T) {
+	// The numbers of lines fitting on the first page and the expected numbers of words per page.
+	testcases := []struct {
+		remaining float64
+		orphans   int
+		widows    int
+		expected  []int
+	}{
+		{remaining: 15, expected: []int{1, 4}},
+		{remaining: 15, orphans: 2, expected: []int{0, 5}},
+		{remaining: 45, expected: []int{4, 1}},
+		{remaining: 45, widows: 2, expected: []int{3, 2}},
+		{remaining: 45, orphans: 4, widows: 2, expected: []int{0, 5}},
+	}
+	for _, tc := range testcases {
+		c := New()
+		c.NewPage()
+		drawSpacer(t, c, tc.remaining)
+
+		p := numberedLines(c, 0, 5)
+		p.SetOrphans(tc.orphans)
+		p.SetWidows(tc.widows)
+		require.NoError(t, c.Draw(p))
+		require.Equal(t, tc.expected, countPageWords(t, c))
+	}
+}
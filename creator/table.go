@@ -54,6 +54,20 @@ type Table struct {
 	// Header rows.
 	headerStartRow int
 	headerEndRow   int
+
+	// Specifies whether the table has a footer.
+	hasFooter bool
+
+	// Footer rows.
+	footerStartRow int
+	footerEndRow   int
+
+	// Function called before drawing the footer rows on each page.
+	footerFunc func(table *Table, args TableFooterFunctionArgs)
+
+	// Controls whether the table is kept on one page, and on the page of the next drawable.
+	keepTogether bool
+	keepWithNext bool
 }
 
 // newTable create a new Table with a specified number of columns.
@@ -122,6 +136,28 @@ func (table *Table) GetMargins() (float64, float64, float64, float64) {
 	return table.margins.left, table.margins.right, table.margins.top, table.margins.bottom
 }
 
+// SetKeepTogether sets whether the table is kept on one page, starting on the next page if it
+// does not fit on the current one.
+func (table *Table) SetKeepTogether(keep bool) {
+	table.keepTogether = keep
+}
+
+// KeepTogether returns whether the table is kept on one page.
+func (table *Table) KeepTogether() bool {
+	return table.keepTogether
+}
+
+// SetKeepWithNext sets whether the table is kept on the page of the start of the next
+// drawable.
+func (table *Table) SetKeepWithNext(keep bool) {
+	table.keepWithNext = keep
+}
+
+// KeepWithNext returns whether the table is kept on the page of the next drawable.
+func (table *Table) KeepWithNext() bool {
+	return table.keepWithNext
+}
+
 // GetRowHeight returns the height of the specified row.
 func (table *Table) GetRowHeight(row int) (float64, error) {
 	if row < 1 || row > len(table.rowHeights) {
@@ -192,6 +228,101 @@ func (table *Table) SetHeaderRows(startRow, endRow int) error {
 	return nil
 }
 
+// SetFooterRows turns the selected table rows into footers that are drawn at the end of the
+// table, and repeated at the bottom of every page the table spans below the rows of the page.
+// startRow and endRow are inclusive. The footer rows should be the last rows of the table.
+func (table *Table) SetFooterRows(startRow, endRow int) error {
+	if startRow <= 0 {
+		return errors.New("footer start row must be greater than 0")
+	}
+	if endRow <= 0 {
+		return errors.New("footer end row must be greater than 0")
+	}
+	if startRow > endRow {
+		return errors.New("footer start row must be less than or equal to the end row")
+	}
+
+	table.hasFooter = true
+	table.footerStartRow = startRow
+	table.footerEndRow = endRow
+	return nil
+}
+
+// TableFooterFunctionArgs holds the input arguments to a table footer function.
+// It is designed as a struct, so additional parameters can be added in the future with backwards
+// compatibility.
+type TableFooterFunctionArgs struct {
+	// PageNum is the number of the page of the table, starting from 1.
+	PageNum int
+
+	// FirstRow and LastRow are the first and the last rows drawn on the page, excluding the
+	// header and footer rows.
+	FirstRow int
+	LastRow  int
+
+	// LastPage specifies whether the page is the last page of the table.
+	LastPage bool
+}
+
+// SetFooterFunc sets a function called on each page the table is drawn on, before drawing the
+// footer rows, e.g. to set the subtotals of the rows of the page in the footer cells. The contents
+// of the footer cells should not be higher than the contents they replace. The function is not
+// called when the table is laid out on trial, e.g. to check whether it fits on the current page
+// when kept together or kept with the next drawable, but only once the pages of the table are
+// settled.
+func (table *Table) SetFooterFunc(footerFunc func(table *Table, args TableFooterFunctionArgs)) {
+	table.footerFunc = footerFunc
+}
+
+// isHeaderRow returns whether `row` is a header row of the table.
+func (table *Table) isHeaderRow(row int) bool {
+	return table.hasHeader && row >= table.headerStartRow && row <= table.headerEndRow
+}
+
+// isFooterRow returns whether `row` is a footer row of the table.
+func (table *Table) isFooterRow(row int) bool {
+	return table.hasFooter && row >= table.footerStartRow && row <= table.footerEndRow
+}
+
+// drawFooters calls the footer function of the table with `args`, unless drawing on trial, and
+// draws the footer rows on `block`, from the position (x, y), for a table of width `tableWidth`.
+func (table *Table) drawFooters(block *Block, ctx DrawContext, x, y, tableWidth float64,
+	args TableFooterFunctionArgs) {
+	if table.footerFunc != nil && !ctx.trial {
+		table.footerFunc(table, args)
+	}
+	if !table.hasFooter {
+		return
+	}
+
+	for _, cell := range table.cells {
+		if !table.isFooterRow(cell.row) {
+			continue
+		}
+
+		// Get the position relative to the first footer row, and the dimensions of the cell.
+		var xrel, yrel, w, h float64
+		for i := 0; i < cell.col-1; i++ {
+			xrel += table.colWidths[i] * tableWidth
+		}
+		for i := table.footerStartRow - 1; i < cell.row-1; i++ {
+			yrel += table.rowHeights[i]
+		}
+		for i := 0; i < cell.colspan; i++ {
+			w += table.colWidths[cell.col+i-1] * tableWidth
+		}
+		for i := 0; i < cell.rowspan; i++ {
+			h += table.rowHeights[cell.row+i-1]
+		}
+
+		ctx.X = x + xrel
+		ctx.Y = y + yrel
+		ctx.Width = w
+		ctx.Height = ctx.PageHeight - ctx.Margins.bottom - ctx.Y
+		table.drawCell(block, cell, ctx, w, h)
+	}
+}
+
 // AddSubtable copies the cells of the subtable in the table, starting with the
 // specified position. The table row and column indices are 1-based, which
 // makes the position of the first cell of the first row of the table 1,1.
@@ -354,6 +485,13 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 				// Add diff to last row.
 				table.rowHeights[cell.row+cell.rowspan-2] += diffh
 			}
+		case *Barcode:
+			newh := t.Height() + t.margins.top + t.margins.bottom
+			if newh > h {
+				diffh := newh - h
+				// Add diff to last row.
+				table.rowHeights[cell.row+cell.rowspan-2] += diffh
+			}
 		case *Table:
 			tbl := t
 			newh := tbl.Height() + tbl.margins.top + tbl.margins.bottom
@@ -395,7 +533,7 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			}
 
 			// Get available width and height.
-			newh := div.Height() + div.margins.top + div.margins.bottom
+			newh := div.layoutHeight(w - cell.indent)
 			if newh > h {
 				diffh := newh - h
 				// Add diff to last row.
@@ -404,6 +542,18 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		}
 	}
 
+	// Height of the footer rows, reserved at the bottom of the pages.
+	var footerHeight float64
+	if table.hasFooter {
+		for i := table.footerStartRow - 1; i < table.footerEndRow && i < len(table.rowHeights); i++ {
+			footerHeight += table.rowHeights[i]
+		}
+	}
+
+	// Rows drawn on the current page, and the bottom of the drawn cells.
+	footerArgs := TableFooterFunctionArgs{PageNum: 1}
+	pageBottom := ulY
+
 	// Draw cells.
 	// row height, cell height
 	var drawingHeaders bool
@@ -439,8 +589,19 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			h += table.rowHeights[cell.row+i-1]
 		}
 
+		// The footer rows are drawn at the bottom of the pages.
+		if table.isFooterRow(cell.row) {
+			continue
+		}
+
 		ctx.Height = origHeight - yrel
-		if h > ctx.Height {
+		if h+footerHeight > ctx.Height {
+			// Draw the footer rows below the rows of the page.
+			if footerArgs.FirstRow > 0 {
+				table.drawFooters(block, ctx, ulX, pageBottom, tableWidth, footerArgs)
+			}
+			footerArgs = TableFooterFunctionArgs{PageNum: footerArgs.PageNum + 1}
+
 			// Go to next page.
 			blocks = append(blocks, block)
 			block = NewBlock(ctx.PageWidth, ctx.PageHeight)
@@ -453,6 +614,7 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 
 			startrow = cell.row - 1
 			yrel = 0
+			pageBottom = ulY
 
 			// Save state and jump back to the first header cell.
 			if table.hasHeader && startHeaderCell >= 0 {
@@ -472,137 +634,20 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 		ctx.X = ulX + xrel
 		ctx.Y = ulY + yrel
 
-		// Creating border
-		border := newBorder(ctx.X, ctx.Y, w, h)
-
-		if cell.backgroundColor != nil {
-			r := cell.backgroundColor.R()
-			g := cell.backgroundColor.G()
-			b := cell.backgroundColor.B()
-
-			border.SetFillColor(ColorRGBFromArithmetic(r, g, b))
-		}
-
-		border.LineStyle = cell.borderLineStyle
-
-		border.styleLeft = cell.borderStyleLeft
-		border.styleRight = cell.borderStyleRight
-		border.styleTop = cell.borderStyleTop
-		border.styleBottom = cell.borderStyleBottom
-
-		if cell.borderColorLeft != nil {
-			border.SetColorLeft(ColorRGBFromArithmetic(cell.borderColorLeft.R(), cell.borderColorLeft.G(), cell.borderColorLeft.B()))
-		}
-		if cell.borderColorBottom != nil {
-			border.SetColorBottom(ColorRGBFromArithmetic(cell.borderColorBottom.R(), cell.borderColorBottom.G(), cell.borderColorBottom.B()))
-		}
-		if cell.borderColorRight != nil {
-			border.SetColorRight(ColorRGBFromArithmetic(cell.borderColorRight.R(), cell.borderColorRight.G(), cell.borderColorRight.B()))
-		}
-		if cell.borderColorTop != nil {
-			border.SetColorTop(ColorRGBFromArithmetic(cell.borderColorTop.R(), cell.borderColorTop.G(), cell.borderColorTop.B()))
-		}
-
-		border.SetWidthBottom(cell.borderWidthBottom)
-		border.SetWidthLeft(cell.borderWidthLeft)
-		border.SetWidthRight(cell.borderWidthRight)
-		border.SetWidthTop(cell.borderWidthTop)
-
-		err := block.Draw(border)
-		if err != nil {
-			common.Log.Debug("ERROR: %v", err)
-		}
+		table.drawCell(block, cell, ctx, w, h)
 
-		if cell.content != nil {
-			cw := cell.content.Width()  // content width.
-			ch := cell.content.Height() // content height.
-			vertOffset := 0.0
-
-			switch t := cell.content.(type) {
-			case *Paragraph:
-				if t.enableWrap {
-					cw = t.getMaxLineWidth() / 1000.0
-				}
-			case *StyledParagraph:
-				if t.enableWrap {
-					cw = t.getMaxLineWidth() / 1000.0
-				}
-
-				// Calculate the height of the paragraph.
-				lineCapHeight, lineHeight := t.getLineHeight(0)
-				if len(t.lines) == 1 {
-					ch = lineCapHeight
-				} else {
-					ch = ch - lineHeight + lineCapHeight
-				}
-
-				// Account for the top offset the paragraph adds.
-				vertOffset = lineCapHeight - lineHeight
-
-				switch cell.verticalAlignment {
-				case CellVerticalAlignmentTop:
-					// Add a bit of space from the top border of the cell.
-					vertOffset += lineCapHeight * 0.5
-				case CellVerticalAlignmentBottom:
-					// Add a bit of space from the bottom border of the cell.
-					vertOffset -= lineCapHeight * 0.5
-				}
-			case *Table:
-				cw = w
-			case *List:
-				cw = w
-			}
-
-			// Account for horizontal alignment:
-			switch cell.horizontalAlignment {
-			case CellHorizontalAlignmentLeft:
-				// Account for indent.
-				ctx.X += cell.indent
-				ctx.Width -= cell.indent
-			case CellHorizontalAlignmentCenter:
-				// Difference between available space and content space.
-				dw := w - cw
-				if dw > 0 {
-					ctx.X += dw / 2
-					ctx.Width -= dw / 2
-				}
-			case CellHorizontalAlignmentRight:
-				if w > cw {
-					ctx.X = ctx.X + w - cw - cell.indent
-					ctx.Width -= cell.indent
-				}
-			}
-
-			ctx.Y += vertOffset
-
-			// Account for vertical alignment.
-			switch cell.verticalAlignment {
-			case CellVerticalAlignmentTop:
-				// Default: do nothing.
-			case CellVerticalAlignmentMiddle:
-				dh := h - ch
-				if dh > 0 {
-					ctx.Y += dh / 2
-					ctx.Height -= dh / 2
-				}
-			case CellVerticalAlignmentBottom:
-				if h > ch {
-					ctx.Y = ctx.Y + h - ch
-					ctx.Height = h
-				}
+		ctx.Y += h
+		ctx.Height -= h
+		pageBottom = math.Max(pageBottom, ctx.Y)
+		if !drawingHeaders && !table.isHeaderRow(cell.row) {
+			if footerArgs.FirstRow == 0 {
+				footerArgs.FirstRow = cell.row
 			}
-
-			err := block.DrawWithContext(cell.content, ctx)
-			if err != nil {
-				common.Log.Debug("ERROR: %v", err)
+			if lastRow := cell.row + cell.rowspan - 1; lastRow > footerArgs.LastRow {
+				footerArgs.LastRow = lastRow
 			}
-
-			ctx.Y -= vertOffset
 		}
 
-		ctx.Y += h
-		ctx.Height -= h
-
 		// Resume previous state after headers have been rendered.
 		if drawingHeaders && cellIdx+1 > endHeaderCell {
 			// Account for the height of the rendered headers.
@@ -615,6 +660,14 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 			drawingHeaders = false
 		}
 	}
+
+	// Draw the footer rows at the end of the table.
+	footerArgs.LastPage = true
+	table.drawFooters(block, ctx, ulX, pageBottom, tableWidth, footerArgs)
+	if table.hasFooter {
+		ctx.Y = pageBottom + footerHeight
+		ctx.Height = origHeight - (ctx.Y - ulY)
+	}
 	blocks = append(blocks, block)
 
 	if table.positioning.isAbsolute() {
@@ -632,6 +685,138 @@ func (table *Table) GeneratePageBlocks(ctx DrawContext) ([]*Block, DrawContext,
 	return blocks, ctx, nil
 }
 
+// drawCell draws `cell`, of width `w` and height `h`, on `block` at the position of `ctx`: its
+// borders, background and contents.
+func (table *Table) drawCell(block *Block, cell *TableCell, ctx DrawContext, w, h float64) {
+	// Creating border
+	border := newBorder(ctx.X, ctx.Y, w, h)
+
+	if cell.backgroundColor != nil {
+		r := cell.backgroundColor.R()
+		g := cell.backgroundColor.G()
+		b := cell.backgroundColor.B()
+
+		border.SetFillColor(ColorRGBFromArithmetic(r, g, b))
+	}
+
+	border.LineStyle = cell.borderLineStyle
+
+	border.styleLeft = cell.borderStyleLeft
+	border.styleRight = cell.borderStyleRight
+	border.styleTop = cell.borderStyleTop
+	border.styleBottom = cell.borderStyleBottom
+
+	if cell.borderColorLeft != nil {
+		border.SetColorLeft(ColorRGBFromArithmetic(cell.borderColorLeft.R(), cell.borderColorLeft.G(), cell.borderColorLeft.B()))
+	}
+	if cell.borderColorBottom != nil {
+		border.SetColorBottom(ColorRGBFromArithmetic(cell.borderColorBottom.R(), cell.borderColorBottom.G(), cell.borderColorBottom.B()))
+	}
+	if cell.borderColorRight != nil {
+		border.SetColorRight(ColorRGBFromArithmetic(cell.borderColorRight.R(), cell.borderColorRight.G(), cell.borderColorRight.B()))
+	}
+	if cell.borderColorTop != nil {
+		border.SetColorTop(ColorRGBFromArithmetic(cell.borderColorTop.R(), cell.borderColorTop.G(), cell.borderColorTop.B()))
+	}
+
+	border.SetWidthBottom(cell.borderWidthBottom)
+	border.SetWidthLeft(cell.borderWidthLeft)
+	border.SetWidthRight(cell.borderWidthRight)
+	border.SetWidthTop(cell.borderWidthTop)
+
+	err := block.Draw(border)
+	if err != nil {
+		common.Log.Debug("ERROR: %v", err)
+	}
+
+	if cell.content != nil {
+		cw := cell.content.Width()  // content width.
+		ch := cell.content.Height() // content height.
+		vertOffset := 0.0
+
+		switch t := cell.content.(type) {
+		case *Paragraph:
+			if t.enableWrap {
+				cw = t.getMaxLineWidth() / 1000.0
+			}
+		case *StyledParagraph:
+			if t.enableWrap {
+				cw = t.getMaxLineWidth() / 1000.0
+			}
+
+			// Calculate the height of the paragraph.
+			lineCapHeight, lineHeight := t.getLineHeight(0)
+			if len(t.lines) == 1 {
+				ch = lineCapHeight
+			} else {
+				ch = ch - lineHeight + lineCapHeight
+			}
+
+			// Account for the top offset the paragraph adds.
+			vertOffset = lineCapHeight - lineHeight
+
+			switch cell.verticalAlignment {
+			case CellVerticalAlignmentTop:
+				// Add a bit of space from the top border of the cell.
+				vertOffset += lineCapHeight * 0.5
+			case CellVerticalAlignmentBottom:
+				// Add a bit of space from the bottom border of the cell.
+				vertOffset -= lineCapHeight * 0.5
+			}
+		case *Table:
+			cw = w
+		case *List:
+			cw = w
+		}
+
+		// Account for horizontal alignment:
+		switch cell.horizontalAlignment {
+		case CellHorizontalAlignmentLeft:
+			// Account for indent.
+			ctx.X += cell.indent
+			ctx.Width -= cell.indent
+		case CellHorizontalAlignmentCenter:
+			// Difference between available space and content space.
+			dw := w - cw
+			if dw > 0 {
+				ctx.X += dw / 2
+				ctx.Width -= dw / 2
+			}
+		case CellHorizontalAlignmentRight:
+			if w > cw {
+				ctx.X = ctx.X + w - cw - cell.indent
+				ctx.Width -= cell.indent
+			}
+		}
+
+		ctx.Y += vertOffset
+
+		// Account for vertical alignment.
+		switch cell.verticalAlignment {
+		case CellVerticalAlignmentTop:
+			// Default: do nothing.
+		case CellVerticalAlignmentMiddle:
+			dh := h - ch
+			if dh > 0 {
+				ctx.Y += dh / 2
+				ctx.Height -= dh / 2
+			}
+		case CellVerticalAlignmentBottom:
+			if h > ch {
+				ctx.Y = ctx.Y + h - ch
+				ctx.Height = h
+			}
+		}
+
+		err := block.DrawWithContext(cell.content, ctx)
+		if err != nil {
+			common.Log.Debug("ERROR: %v", err)
+		}
+
+		ctx.Y -= vertOffset
+	}
+}
+
 // CellBorderStyle defines the table cell's border style.
 type CellBorderStyle int
 
@@ -941,6 +1126,8 @@ func (cell *TableCell) SetContent(vd VectorDrawable) error {
 		cell.content = vd
 	case *Image:
 		cell.content = vd
+	case *Barcode:
+		cell.content = vd
 	case *Table:
 		cell.content = vd
 	case *List:
@@ -6,6 +6,7 @@
 package creator
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -614,3 +615,52 @@ func TestTableHorizontalCellAlign(t *testing.T) {
 	require.NoError(t, c.Draw(table))
 	testWriteAndRender(t, c, "table_horizontal_cell_align.pdf")
 }
+
+func TestTableFooterRows(t *testing.T) {
+	c := New()
+	c.NewPage()
+
+	table := c.NewTable(1)
+	require.NoError(t, table.SetHeaderRows(1, 1))
+	require.NoError(t, table.SetFooterRows(102, 102))
+	for i := 1; i <= 102; i++ {
+		cell := table.NewCell()
+		require.NoError(t, cell.SetContent(numberedLines(c, i, 1)))
+	}
+
+	// The footer shows the last row of the page.
+	footer := c.NewStyledParagraph()
+	footer.Append("w9000")
+	table.cells[len(table.cells)-1].SetContent(footer)
+	var calls []TableFooterFunctionArgs
+	table.SetFooterFunc(func(table *Table, args TableFooterFunctionArgs) {
+		calls = append(calls, args)
+		footer.SetText(fmt.Sprintf("w%04d", 9000+args.LastRow))
+	})
+	require.NoError(t, c.Draw(table))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	pages := readColumnWords(t, buf.Bytes())
+	require.Greater(t, len(pages), 1)
+	require.Len(t, calls, len(pages))
+
+	firstRow := 2
+	for i, words := range pages {
+		args := calls[i]
+		require.Equal(t, i+1, args.PageNum)
+		require.Equal(t, firstRow, args.FirstRow)
+		require.Equal(t, i == len(pages)-1, args.LastPage)
+		firstRow = args.LastRow + 1
+
+		// The header, the rows of the page, and the footer below them.
+		require.Len(t, words, args.LastRow-args.FirstRow+3)
+		require.Equal(t, 1, words[0].index)
+		last := words[len(words)-1]
+		require.Equal(t, 9000+args.LastRow, last.index)
+		for _, w := range words[:len(words)-1] {
+			require.Greater(t, w.top, last.top)
+		}
+	}
+	require.Equal(t, 102, firstRow)
+}
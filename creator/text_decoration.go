@@ -0,0 +1,182 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// TextDecorationLineStyle is the style of a line drawn along text: an underline, strike-through
+// or overline.
+type TextDecorationLineStyle struct {
+	// The color of the line. The line has the color of the text if nil.
+	Color Color
+
+	// The position of the center of the line relative to the baseline of the text, in points,
+	// positive above the baseline. The line is positioned from the metrics of the font if 0.
+	Offset float64
+
+	// The thickness of the line, in points. The line has the underline thickness of the font
+	// if 0.
+	Thickness float64
+}
+
+// textDecoration is a run of text drawn with decorations or a background.
+type textDecoration struct {
+	// x and baseline are the start and baseline of the run, and width its width, in points
+	// relative to the origin of the paragraph.
+	x        float64
+	baseline float64
+	width    float64
+
+	style *TextStyle
+}
+
+// hasDecorations returns true if text of style `s` is drawn with decoration lines or a background.
+func (s *TextStyle) hasDecorations() bool {
+	return s.Underline || s.StrikeThrough || s.Overline || s.BackgroundColor != nil
+}
+
+// fontVerticalMetrics returns the ascent, descent and x-height of `font`, in glyph space units,
+// estimated if missing from its descriptor.
+func fontVerticalMetrics(font *model.PdfFont) (ascent, descent, xHeight float64) {
+	var capHeight float64
+	if descriptor, err := font.GetFontDescriptor(); err == nil && descriptor != nil {
+		ascent, _ = core.GetNumberAsFloat(descriptor.Ascent)
+		descent, _ = core.GetNumberAsFloat(descriptor.Descent)
+		capHeight, _ = core.GetNumberAsFloat(descriptor.CapHeight)
+		xHeight, _ = core.GetNumberAsFloat(descriptor.XHeight)
+	}
+	if capHeight <= 0 {
+		capHeight = 700
+	}
+	if ascent <= 0 {
+		ascent = capHeight
+	}
+	if descent >= 0 {
+		descent = -200
+	}
+	if xHeight <= 0 {
+		xHeight = capHeight * 2 / 3
+	}
+	return ascent, descent, xHeight
+}
+
+// decorationLines returns the enabled decoration lines of `s` along with their default offsets
+// relative to the baseline, in glyph space units.
+func (s *TextStyle) decorationLines() ([]TextDecorationLineStyle, []float64) {
+	var lines []TextDecorationLineStyle
+	var offsets []float64
+	ascent, _, xHeight := fontVerticalMetrics(s.Font)
+	if s.Underline {
+		position, _, ok := s.Font.UnderlineMetrics()
+		if !ok {
+			position = -100
+		}
+		lines = append(lines, s.UnderlineStyle)
+		offsets = append(offsets, position)
+	}
+	if s.StrikeThrough {
+		lines = append(lines, s.StrikeThroughStyle)
+		offsets = append(offsets, xHeight/2)
+	}
+	if s.Overline {
+		lines = append(lines, s.OverlineStyle)
+		offsets = append(offsets, ascent)
+	}
+	return lines, offsets
+}
+
+// drawTextBackgrounds adds the operators filling the backgrounds of `decorations`, from the
+// descent to the ascent of their fonts, to `cc`.
+func drawTextBackgrounds(cc *contentstream.ContentCreator, decorations []textDecoration) {
+	for _, d := range decorations {
+		style := d.style
+		if style.BackgroundColor == nil {
+			continue
+		}
+		ascent, descent, _ := fontVerticalMetrics(style.Font)
+		y := d.baseline + style.TextRise + descent*style.FontSize/1000.0
+		height := (ascent - descent) * style.FontSize / 1000.0
+
+		r, g, b := style.BackgroundColor.ToRGB()
+		cc.Add_rg(r, g, b).
+			Add_re(d.x, y, d.width, height).
+			Add_f()
+	}
+}
+
+// drawTextDecorations adds the operators drawing the underlines, strike-throughs and overlines
+// of `decorations` to `cc`.
+func drawTextDecorations(cc *contentstream.ContentCreator, decorations []textDecoration) {
+	for _, d := range decorations {
+		style := d.style
+		_, thickness, ok := style.Font.UnderlineMetrics()
+		if !ok || thickness <= 0 {
+			thickness = 50
+		}
+		thickness *= style.FontSize / 1000.0
+
+		lines, offsets := style.decorationLines()
+		for i, line := range lines {
+			offset := offsets[i] * style.FontSize / 1000.0
+			if line.Offset != 0 {
+				offset = line.Offset
+			}
+			lineThickness := thickness
+			if line.Thickness > 0 {
+				lineThickness = line.Thickness
+			}
+			color := line.Color
+			if color == nil {
+				color = style.Color
+			}
+
+			r, g, b := color.ToRGB()
+			y := d.baseline + style.TextRise + offset - lineThickness/2
+			cc.Add_rg(r, g, b).
+				Add_re(d.x, y, d.width, lineThickness).
+				Add_f()
+		}
+	}
+}
+
+// setTextState adds the operators setting the state of the text of style `style` applying to
+// whole runs, i.e. the horizontal scaling, text rise and outline, to `cc`.
+func setTextState(cc *contentstream.ContentCreator, style *TextStyle) {
+	if scale := style.horizontalScale(); scale != 1 {
+		cc.Add_Tz(scale * 100)
+	}
+	if style.TextRise != 0 {
+		cc.Add_Ts(style.TextRise)
+	}
+	if style.OutlineColor != nil {
+		r, g, b := style.OutlineColor.ToRGB()
+		cc.Add_RG(r, g, b)
+	}
+	if style.OutlineSize > 0 {
+		cc.Add_w(style.OutlineSize)
+	}
+}
+
+// resetTextState adds the operators restoring the text state set by setTextState for `style`
+// to `cc`.
+func resetTextState(cc *contentstream.ContentCreator, style *TextStyle) {
+	if style.horizontalScale() != 1 {
+		cc.Add_Tz(100)
+	}
+	if style.TextRise != 0 {
+		cc.Add_Ts(0)
+	}
+	if style.OutlineColor != nil {
+		cc.Add_RG(0, 0, 0)
+	}
+	if style.OutlineSize > 0 {
+		cc.Add_w(1)
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/model"
+)
+
+func TestTextStyleSpacing(t *testing.T) {
+	c := New()
+	p := c.NewStyledParagraph()
+	chunk := p.Append("ab cd ef")
+	chunk.Style.FontSize = 10
+	width := p.getTextWidth()
+
+	// Each space is widened by the word spacing.
+	chunk.Style.WordSpacing = 2
+	require.InDelta(t, width+2*2000, p.getTextWidth(), 1e-6)
+
+	// The glyphs and the character spacing are scaled horizontally, the word spacing is not.
+	chunk.Style.WordSpacing = 0
+	chunk.Style.CharSpacing = 1
+	spaced := p.getTextWidth()
+	chunk.Style.HorizontalScaling = 50
+	require.InDelta(t, spaced/2, p.getTextWidth(), 1e-6)
+
+	// The wrapped lines are measured alike.
+	chunk.Style.WordSpacing = 2
+	p.SetWidth(p.getTextWidth() / 1000.0)
+	require.Len(t, p.lines, 1)
+	require.InDelta(t, p.getTextWidth(), p.getTextLineWidth(p.lines[0]), 1e-6)
+}
+
+func TestTextDecorations(t *testing.T) {
+	c := New()
+	p := c.NewStyledParagraph()
+	p.SetPos(0, 0)
+	chunk := p.Append("underlined")
+	chunk.Style.Font = newStandard14Font(t, model.HelveticaName)
+	chunk.Style.FontSize = 10
+	chunk.Style.Underline = true
+	chunk.Style.BackgroundColor = ColorYellow
+
+	blk := NewBlock(200, 100)
+	require.NoError(t, blk.Draw(p))
+	content := blk.contents.String()
+
+	// The underline of the standard 14 fonts is 100 units below the baseline and 50 units thick.
+	width := p.getTextWidth() / 1000.0
+	require.Contains(t, content, "0 -1.25 "+fmt.Sprint(width)+" 0.5 re")
+	// The background, filled first, spans the descent and ascent of the font.
+	require.Less(t, strings.Index(content, "1 1 0 rg"), strings.Index(content, "BT"))
+
+	// Offsets and thicknesses set explicitly override the metrics of the font.
+	chunk.Style.Underline = false
+	chunk.Style.BackgroundColor = nil
+	chunk.Style.StrikeThrough = true
+	chunk.Style.StrikeThroughStyle = TextDecorationLineStyle{Color: ColorRed, Offset: 3, Thickness: 1}
+	blk = NewBlock(200, 100)
+	require.NoError(t, blk.Draw(p))
+	content = blk.contents.String()
+	require.Contains(t, content, "1 0 0 rg\n0 2.5 "+fmt.Sprint(width)+" 1 re")
+}
+
+func TestTextStyles(t *testing.T) {
+	c := New()
+	font := newStandard14Font(t, model.HelveticaName)
+
+	p := c.NewStyledParagraph()
+	p.SetTextAlignment(TextAlignmentJustify)
+	p.SetMargins(0, 0, 0, 10)
+
+	styled := func(text string, f func(style *TextStyle)) {
+		chunk := p.Append(text)
+		chunk.Style.Font = font
+		chunk.Style.FontSize = 12
+		f(&chunk.Style)
+	}
+	styled("Underlined, ", func(s *TextStyle) { s.Underline = true })
+	styled("struck through, ", func(s *TextStyle) {
+		s.StrikeThrough = true
+		s.StrikeThroughStyle.Color = ColorRed
+	})
+	styled("overlined, ", func(s *TextStyle) { s.Overline = true })
+	styled("highlighted, ", func(s *TextStyle) { s.BackgroundColor = ColorYellow })
+	styled("E = mc", func(s *TextStyle) {})
+	styled("2", func(s *TextStyle) {
+		s.FontSize = 8
+		s.TextRise = 5
+	})
+	styled(", H", func(s *TextStyle) {})
+	styled("2", func(s *TextStyle) {
+		s.FontSize = 8
+		s.TextRise = -3
+	})
+	styled("O, outlined, ", func(s *TextStyle) {
+		s.RenderingMode = TextRenderingModeFillStroke
+		s.Color = ColorWhite
+		s.OutlineColor = ColorBlue
+		s.OutlineSize = 0.5
+	})
+	styled("widely spaced words, ", func(s *TextStyle) { s.WordSpacing = 6 })
+	styled("condensed text.", func(s *TextStyle) {
+		s.HorizontalScaling = 75
+		s.Underline = true
+		s.UnderlineStyle = TextDecorationLineStyle{Color: ColorGreen, Offset: -3, Thickness: 1.5}
+	})
+	require.NoError(t, c.Draw(p))
+
+	// Shaped text is decorated alike.
+	shaped, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+	p = c.NewStyledParagraph()
+	chunk := p.Append("Kerned, underlined and raised AVATAR")
+	chunk.Style.Font = shaped
+	chunk.Style.Features = FontFeatures{FontFeatureKerning: true}
+	chunk.Style.Underline = true
+	chunk.Style.TextRise = 2
+	chunk.Style.WordSpacing = 3
+	chunk.Style.HorizontalScaling = 120
+	require.NoError(t, c.Draw(p))
+
+	// The styles apply to the wrapped text of table cells as well.
+	table := c.NewTable(2)
+	for i := 0; i < 2; i++ {
+		cell := table.NewCell()
+		cp := c.NewStyledParagraph()
+		chunk := cp.Append("Highlighted and underlined text wrapped in a table cell.")
+		chunk.Style.BackgroundColor = ColorRGBFrom8bit(200, 230, 255)
+		chunk.Style.Underline = true
+		chunk.Style.WordSpacing = float64(i)
+		require.NoError(t, cell.SetContent(cp))
+	}
+	require.NoError(t, c.Draw(table))
+
+	testWriteAndRender(t, c, "text_styles.pdf")
+}
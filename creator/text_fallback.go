@@ -0,0 +1,95 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"unicode"
+
+	"github.com/carmel/unipdf/model"
+)
+
+// fontFor returns the font of `style` displaying `r`: its font if it has a glyph for `r`, else
+// the first of its fallback fonts having one. The font of the style is returned if none has.
+func (style *TextStyle) fontFor(r rune) *model.PdfFont {
+	if style.Font.HasGlyph(r) {
+		return style.Font
+	}
+	for _, font := range style.FallbackFonts {
+		if font != nil && font.HasGlyph(r) {
+			return font
+		}
+	}
+	return style.Font
+}
+
+// followsPrecedingFont returns true if `r` is displayed with the font of the preceding text, i.e.
+// for whitespace, combining marks, joiners and variation selectors.
+func followsPrecedingFont(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.M, r) ||
+		r >= 0x200B && r <= 0x200D || r >= 0xFE00 && r <= 0xFE0F
+}
+
+// splitFallbackChunks returns `chunks` with the chunks of styles with fallback fonts split in
+// chunks of the fonts displaying their text. `chunks` is returned as is if no chunk is split.
+func splitFallbackChunks(chunks []*TextChunk) []*TextChunk {
+	var split []*TextChunk
+	for i, chunk := range chunks {
+		parts := chunk.splitByFont()
+		if split == nil && len(parts) == 1 && parts[0] == chunk {
+			continue
+		}
+		if split == nil {
+			split = append(split, chunks[:i]...)
+		}
+		split = append(split, parts...)
+	}
+	if split == nil {
+		return chunks
+	}
+	return split
+}
+
+// splitByFont splits the text of `tc` in chunks of the fonts of its style displaying it: its
+// font and fallback fonts. The chunks are styled with the font displaying their text and copy the
+// annotation of `tc`. Returns `tc` if the font of its style displays all its text.
+func (tc *TextChunk) splitByFont() []*TextChunk {
+	style := &tc.Style
+	if len(style.FallbackFonts) == 0 || style.Font == nil {
+		return []*TextChunk{tc}
+	}
+
+	var parts []*TextChunk
+	var text []rune
+	current := style.Font
+	flush := func() {
+		if len(text) == 0 {
+			return
+		}
+		partStyle := *style
+		partStyle.Font = current
+		partStyle.FallbackFonts = nil
+		parts = append(parts, &TextChunk{
+			Text:       string(text),
+			Style:      partStyle,
+			annotation: copyAnnotation(tc.annotation),
+		})
+		text = nil
+	}
+	for _, r := range tc.Text {
+		if !followsPrecedingFont(r) {
+			if font := style.fontFor(r); font != current {
+				flush()
+				current = font
+			}
+		}
+		text = append(text, r)
+	}
+	if len(parts) == 0 && current == style.Font {
+		return []*TextChunk{tc}
+	}
+	flush()
+	return parts
+}
@@ -0,0 +1,90 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+func TestTextChunkSplitByFont(t *testing.T) {
+	helvetica := newStandard14Font(t, model.HelveticaName)
+	freeSans, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+
+	style := TextStyle{Font: helvetica, FontSize: 10}
+	chunk := NewTextChunk("Invoice", style)
+	require.Equal(t, []*TextChunk{chunk}, chunk.splitByFont())
+
+	style.FallbackFonts = []*model.PdfFont{freeSans}
+	chunk = NewTextChunk("Invoice for דוד כהן, नमस्ते!", style)
+	chunk.SetAnnotation(newExternalLinkAnnotation("https://example.com"))
+	parts := chunk.splitByFont()
+
+	var texts []string
+	var fonts []*model.PdfFont
+	for _, part := range parts {
+		texts = append(texts, part.Text)
+		fonts = append(fonts, part.Style.Font)
+		require.Nil(t, part.Style.FallbackFonts)
+		require.NotNil(t, part.annotation)
+		require.NotSame(t, chunk.annotation, part.annotation)
+	}
+	// Spaces and marks are displayed with the font of the preceding text.
+	require.Equal(t, []string{"Invoice for ", "דוד כהן", ", ", "नमस्ते", "!"}, texts)
+	require.Equal(t, []*model.PdfFont{helvetica, freeSans, helvetica, freeSans, helvetica}, fonts)
+
+	// Runes no font has glyphs for are displayed with the font of the style.
+	chunk = NewTextChunk("中", style)
+	require.Equal(t, []*TextChunk{chunk}, chunk.splitByFont())
+
+	chunks := []*TextChunk{NewTextChunk("a", TextStyle{Font: helvetica}), chunk}
+	require.Equal(t, chunks, splitFallbackChunks(chunks))
+}
+
+func TestStyledParagraphFallbackFonts(t *testing.T) {
+	freeSans, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+
+	c := New()
+	p := c.NewStyledParagraph()
+	p.SetTextAlignment(TextAlignmentJustify)
+	chunk := p.Append(strings.Repeat("Customer नमस्ते दुनिया and more text. ", 6))
+	chunk.Style.FallbackFonts = []*model.PdfFont{freeSans}
+
+	// The fallback font is shaped within the text of the default font.
+	require.Greater(t, p.getTextWidth(), 0.0)
+	require.NoError(t, c.Draw(p))
+	require.Greater(t, len(p.lines), 1)
+	for _, line := range p.lines {
+		for _, chunk := range line {
+			if strings.ContainsRune(chunk.Text, 'न') {
+				require.Same(t, freeSans, chunk.Style.Font)
+			}
+		}
+	}
+
+	fname := testWrite(t, c, "text_fallback_fonts.pdf")
+	f, err := os.Open(fname)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := model.NewPdfReader(f)
+	require.NoError(t, err)
+	page, err := r.GetPage(1)
+	require.NoError(t, err)
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := e.ExtractText()
+	require.NoError(t, err)
+	require.Contains(t, text, "Customer")
+	require.Contains(t, text, "नमस्ते")
+}
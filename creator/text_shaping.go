@@ -0,0 +1,294 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"math"
+	"unicode"
+
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/internal/bidi"
+	"github.com/carmel/unipdf/model"
+)
+
+// complexScripts are the scripts whose text is displayed correctly only once shaped.
+var complexScripts = []*unicode.RangeTable{
+	unicode.Hebrew, unicode.Arabic, unicode.Syriac, unicode.Thaana,
+	unicode.Devanagari, unicode.Bengali, unicode.Gurmukhi, unicode.Gujarati, unicode.Oriya,
+	unicode.Tamil, unicode.Telugu, unicode.Kannada, unicode.Malayalam, unicode.Thai, unicode.Lao,
+}
+
+// requiresShaping returns true if the text of `chunks`, displayed with the base direction `dir`,
+// is laid out with the shaping layout, i.e. for right-to-left or bidirectional text and, with
+// fonts supporting shaping, for text with OpenType features, of complex scripts or with combining
+// marks. Other text maps each rune to a glyph.
+func requiresShaping(chunks []*TextChunk, dir TextDirection) bool {
+	if dir == TextDirectionRightToLeft {
+		return true
+	}
+	for _, chunk := range chunks {
+		runes := []rune(chunk.Text)
+		if bidi.RequiresReordering(runes) {
+			return true
+		}
+		if chunk.Style.Font == nil || !chunk.Style.Font.CanShape() {
+			continue
+		}
+		if len(chunk.Style.Features) > 0 {
+			return true
+		}
+		for _, r := range runes {
+			if unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me) || unicode.In(r, complexScripts...) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveDirection returns the base direction of the text `runes` of a paragraph of direction
+// `dir`.
+func resolveDirection(runes []rune, dir TextDirection) bidi.Direction {
+	switch dir {
+	case TextDirectionLeftToRight:
+		return bidi.LeftToRight
+	case TextDirectionRightToLeft:
+		return bidi.RightToLeft
+	}
+	if d := bidi.BaseDirection(runes); d != bidi.Auto {
+		return d
+	}
+	return bidi.LeftToRight
+}
+
+// resolveTextDirection returns the direction of the text of `chunks` displayed with the direction
+// `dir`, TextDirectionAuto resolving to the direction of its first strong directional character.
+func resolveTextDirection(chunks []*TextChunk, dir TextDirection) TextDirection {
+	if dir != TextDirectionAuto {
+		return dir
+	}
+	runes, _ := chunkRunes(chunks)
+	if bidi.BaseDirection(runes) == bidi.RightToLeft {
+		return TextDirectionRightToLeft
+	}
+	return TextDirectionLeftToRight
+}
+
+// shapedRun is the shaped text of a chunk displayed in a single direction.
+type shapedRun struct {
+	// chunk is the index of the chunk of the run.
+	chunk int
+	// glyphs are the glyphs of the run, in display order.
+	glyphs []model.ShapedGlyph
+	// width is the width of the run, in thousandths of points.
+	width float64
+	// spaces is the number of spaces of the run.
+	spaces int
+}
+
+// isClusterEnd returns true if the glyph `i` of `glyphs` is the last of its cluster.
+func isClusterEnd(glyphs []model.ShapedGlyph, i int) bool {
+	return i == len(glyphs)-1 || glyphs[i+1].Cluster != glyphs[i].Cluster
+}
+
+// shapeRun shapes `runes`, text of the chunk `chunk` of `chunks` at the embedding level `level`.
+func shapeRun(chunks []*TextChunk, chunk int, runes []rune, level uint8) (*shapedRun, error) {
+	style := &chunks[chunk].Style
+	glyphs, err := style.Font.ShapeText(runes, model.ShapeOptions{
+		RightToLeft: level%2 == 1,
+		Features:    style.Features.shapeFeatures(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	run := &shapedRun{chunk: chunk, glyphs: glyphs}
+	for i, g := range glyphs {
+		run.width += style.glyphWidth(g.Advance)
+		if string(g.Text) == " " {
+			run.width += style.spacing(' ')
+			run.spaces++
+		} else if isClusterEnd(glyphs, i) {
+			run.width += style.spacing(0)
+		}
+	}
+	return run, nil
+}
+
+// chunkRunes returns the runes of the text of `chunks`, without line feeds, along with the index
+// of the chunk of each rune.
+func chunkRunes(chunks []*TextChunk) ([]rune, []int) {
+	var runes []rune
+	var owners []int
+	for k, chunk := range chunks {
+		for _, r := range chunk.Text {
+			if r == '\u000A' { // LF
+				continue
+			}
+			runes = append(runes, r)
+			owners = append(owners, k)
+		}
+	}
+	return runes, owners
+}
+
+// forEachRun calls `f` for each range [start, end) of `runes` of a single chunk and level.
+func forEachRun(owners []int, levels []uint8, f func(start, end int) error) error {
+	for start := 0; start < len(owners); {
+		end := start + 1
+		for end < len(owners) && owners[end] == owners[start] && levels[end] == levels[start] {
+			end++
+		}
+		if err := f(start, end); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
+
+// layoutShapedLine shapes the text of the chunks of `line`, a line of a paragraph of direction
+// `dir`. The runs are returned in display order, along with whether the line is right-to-left.
+func layoutShapedLine(line []*TextChunk, dir TextDirection) ([]*shapedRun, bool, error) {
+	runes, owners := chunkRunes(line)
+	if len(runes) == 0 {
+		return nil, false, nil
+	}
+	para := bidi.NewParagraph(runes, resolveDirection(runes, dir))
+	levels := para.LineLevels(0, len(runes))
+
+	runOf := make([]int, len(runes))
+	var logical []*shapedRun
+	err := forEachRun(owners, levels, func(start, end int) error {
+		run, err := shapeRun(line, owners[start], runes[start:end], levels[start])
+		if err != nil {
+			return err
+		}
+		for i := start; i < end; i++ {
+			runOf[i] = len(logical)
+		}
+		logical = append(logical, run)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	runs := make([]*shapedRun, 0, len(logical))
+	last := -1
+	for _, i := range bidi.VisualOrder(levels) {
+		if runOf[i] != last {
+			last = runOf[i]
+			runs = append(runs, logical[last])
+		}
+	}
+	return runs, para.IsRightToLeft(), nil
+}
+
+// shapedLineWidth returns the width of the text of `chunks` displayed on a single line, in
+// thousandths of points, along with its number of spaces.
+func shapedLineWidth(chunks []*TextChunk, dir TextDirection) (float64, int, error) {
+	runs, _, err := layoutShapedLine(chunks, dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	var width float64
+	var spaces int
+	for _, run := range runs {
+		width += run.width
+		spaces += run.spaces
+	}
+	return width, spaces, nil
+}
+
+// measureShaped measures the runes of `t`, text of `chunks` of direction `dir`, shaped. Lines
+// can be broken between clusters as a last resort.
+func (t *wrapText) measureShaped(chunks []*TextChunk, dir TextDirection) error {
+	n := len(t.runes)
+	t.widths = make([]float64, n)
+	t.tracking = make([]float64, n)
+	t.breakable = make([]bool, n)
+	levels := bidi.NewParagraph(t.runes, resolveDirection(t.runes, dir)).Levels()
+	return forEachRun(t.owners, levels, func(start, end int) error {
+		run, err := shapeRun(chunks, t.owners[start], t.runes[start:end], levels[start])
+		if err != nil {
+			return err
+		}
+		style := &chunks[run.chunk].Style
+		for i, g := range run.glyphs {
+			idx := start + g.Cluster
+			t.breakable[idx] = true
+			if isBreakHint(t.runes[idx]) {
+				continue
+			}
+			t.widths[idx] += style.glyphWidth(g.Advance)
+			if string(g.Text) == " " {
+				t.widths[idx] += style.spacing(' ')
+			} else if isClusterEnd(run.glyphs, i) {
+				t.tracking[idx] += style.spacing(0)
+				t.widths[idx] += style.spacing(0)
+			}
+		}
+		return nil
+	})
+}
+
+// writeShapedRun adds the operators showing the glyphs of `run`, text of style `style`, to `cc`.
+// The spaces are widened by `extraSpace` thousandths of points, e.g. for justified text.
+func writeShapedRun(cc *contentstream.ContentCreator, run *shapedRun, style *TextStyle, extraSpace float64) {
+	fontSize := style.FontSize
+	// The adjustments are scaled horizontally along with the glyphs.
+	scale := style.horizontalScale()
+	setTextState(cc, style)
+	var objs []core.PdfObject
+	var encoded []byte
+	flushString := func() {
+		if len(encoded) > 0 {
+			objs = append(objs, core.MakeStringFromBytes(encoded))
+			encoded = nil
+		}
+	}
+	flush := func() {
+		flushString()
+		if len(objs) > 0 {
+			cc.Add_TJ(objs...)
+			objs = nil
+		}
+	}
+
+	var rise float64
+	for i, g := range run.glyphs {
+		// Glyphs displaced vertically, e.g. attached marks, are raised.
+		if g.YOffset != rise {
+			flush()
+			rise = g.YOffset
+			cc.Add_Ts(style.TextRise + rise*fontSize/1000.0)
+		}
+		if g.XOffset != 0 {
+			flushString()
+			objs = append(objs, core.MakeFloat(-g.XOffset))
+		}
+		encoded = append(encoded, g.Code...)
+
+		// The pen moves by the glyph width: adjust it to the advance of the glyph.
+		adjust := g.Width + g.XOffset - g.Advance
+		if string(g.Text) == " " {
+			adjust -= (extraSpace + style.spacing(' ')) / fontSize / scale
+		} else if style.CharSpacing != 0 && isClusterEnd(run.glyphs, i) {
+			adjust -= style.CharSpacing * 1000.0 / fontSize
+		}
+		if math.Abs(adjust) > 1e-6 {
+			flushString()
+			objs = append(objs, core.MakeFloat(adjust))
+		}
+	}
+	flush()
+	if rise != 0 && style.TextRise == 0 {
+		cc.Add_Ts(0)
+	}
+	resetTextState(cc, style)
+}
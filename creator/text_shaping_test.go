@@ -0,0 +1,195 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+func runsText(runs []*shapedRun) []string {
+	var texts []string
+	for _, run := range runs {
+		var text string
+		for _, g := range run.glyphs {
+			text += string(g.Text)
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func TestShapedLineLayout(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+	style := TextStyle{Font: font, FontSize: 10}
+	line := []*TextChunk{NewTextChunk("abc שלום def", style)}
+
+	require.True(t, requiresShaping(line, TextDirectionAuto))
+	require.Equal(t, TextDirectionLeftToRight, resolveTextDirection(line, TextDirectionAuto))
+
+	// The Hebrew word is displayed from right to left within left-to-right text.
+	runs, rtl, err := layoutShapedLine(line, TextDirectionLeftToRight)
+	require.NoError(t, err)
+	require.False(t, rtl)
+	require.Equal(t, []string{"abc ", "םולש", " def"}, runsText(runs))
+
+	// In a right-to-left paragraph the runs are displayed from right to left.
+	runs, rtl, err = layoutShapedLine(line, TextDirectionRightToLeft)
+	require.NoError(t, err)
+	require.True(t, rtl)
+	// The spaces between the words take the paragraph direction.
+	require.Equal(t, []string{"def", " םולש ", "abc"}, runsText(runs))
+
+	// Text of several chunks is split in runs of a single chunk.
+	bold := style
+	bold.FontSize = 12
+	line = []*TextChunk{NewTextChunk("שלום ", style), NewTextChunk("עולם", bold)}
+	runs, _, err = layoutShapedLine(line, TextDirectionAuto)
+	require.NoError(t, err)
+	require.Equal(t, []string{"םלוע", " םולש"}, runsText(runs))
+	require.Equal(t, 1, runs[0].chunk)
+}
+
+func TestShapedTextWrap(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+	style := TextStyle{Font: font, FontSize: 10}
+
+	text := strings.Repeat("नमस्ते दुनिया ", 10)
+	chunks := []*TextChunk{NewTextChunk(text, style)}
+	lines, err := wrapShapedChunks(chunks, 100, TextDirectionLeftToRight)
+	require.NoError(t, err)
+	require.Greater(t, len(lines), 1)
+
+	var wrapped []string
+	for _, line := range lines {
+		width, _, err := shapedLineWidth(line, TextDirectionLeftToRight)
+		require.NoError(t, err)
+		require.LessOrEqual(t, width, 100*1000.0)
+		for _, chunk := range line {
+			wrapped = append(wrapped, chunk.Text)
+		}
+	}
+	require.Equal(t, strings.TrimSpace(text), strings.Join(wrapped, " "))
+
+	// Line feeds end paragraphs.
+	chunks = []*TextChunk{NewTextChunk("שלום\n\nעולם", style)}
+	lines, err = wrapShapedChunks(chunks, 100, TextDirectionAuto)
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+	require.Equal(t, "", lines[1][0].Text)
+}
+
+func TestParagraphShaping(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile(testFreeSansTTFFile)
+	require.NoError(t, err)
+
+	c := New()
+	c.EnableFontSubsetting(font)
+
+	p := c.NewParagraph("प्रकाश और स्त्री")
+	p.SetFont(font)
+	require.NoError(t, c.Draw(p))
+
+	p = c.NewParagraph(strings.Repeat("שלום עולם ", 20))
+	p.SetFont(font)
+	p.SetTextDirection(TextDirectionRightToLeft)
+	p.SetTextAlignment(TextAlignmentJustify)
+	require.NoError(t, c.Draw(p))
+
+	sp := c.NewStyledParagraph()
+	sp.Append("Hello ").Style.Font = font
+	sp.Append("שלום").Style.Font = font
+	sp.SetTextAlignment(TextAlignmentRight)
+	require.NoError(t, c.Draw(sp))
+
+	fname := testWrite(t, c, "text_shaping.pdf")
+
+	// The glyphs of the conjuncts are extracted as their text.
+	f, err := os.Open(fname)
+	require.NoError(t, err)
+	defer f.Close()
+	r, err := model.NewPdfReader(f)
+	require.NoError(t, err)
+	page, err := r.GetPage(1)
+	require.NoError(t, err)
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := e.ExtractText()
+	require.NoError(t, err)
+	require.Contains(t, text, "प्र")
+	require.Contains(t, text, "स्त्री")
+	require.Contains(t, text, "Hello")
+}
+
+func TestParagraphRightToLeftSimpleFont(t *testing.T) {
+	c := New()
+	p := c.NewParagraph("(abc) def")
+	p.SetTextDirection(TextDirectionRightToLeft)
+	p.SetTextAlignment(TextAlignmentRight)
+	require.NoError(t, c.Draw(p))
+
+	width := p.getTextWidth()
+	require.Greater(t, width, 0.0)
+	testWrite(t, c, "text_shaping_rtl_simple.pdf")
+}
+
+func TestStyledParagraphFontFeatures(t *testing.T) {
+	font, err := model.NewCompositePdfFontFromTTFFile(testRobotoRegularTTFFile)
+	require.NoError(t, err)
+
+	c := New()
+	newParagraph := func(text string, features FontFeatures) *StyledParagraph {
+		p := c.NewStyledParagraph()
+		chunk := p.Append(text)
+		chunk.Style.Font = font
+		chunk.Style.Features = features
+		return p
+	}
+
+	// Kerning narrows the text.
+	plain := newParagraph("AVAVAV", nil)
+	kerned := newParagraph("AVAVAV", FontFeatures{FontFeatureKerning: true})
+	require.Less(t, kerned.getTextWidth(), plain.getTextWidth())
+	require.Equal(t, kerned.getTextWidth(), kerned.getTextLineWidth(kerned.chunks))
+
+	// Ligatures are enabled by default once features are set.
+	runs, _, err := layoutShapedLine(kerned.chunks, TextDirectionLeftToRight)
+	require.NoError(t, err)
+	require.Len(t, runs[0].glyphs, 6)
+	p := newParagraph("office", FontFeatures{FontFeatureSmallCaps: true})
+	runs, _, err = layoutShapedLine(p.chunks, TextDirectionLeftToRight)
+	require.NoError(t, err)
+	require.Len(t, runs[0].glyphs, 4)
+	require.Equal(t, "ffi", string(runs[0].glyphs[1].Text))
+	p = newParagraph("office", FontFeatures{FontFeatureLigatures: false, FontFeatureKerning: true})
+	runs, _, err = layoutShapedLine(p.chunks, TextDirectionLeftToRight)
+	require.NoError(t, err)
+	require.Len(t, runs[0].glyphs, 6)
+
+	// Wrapping measures the kerned text.
+	kerned = newParagraph(strings.Repeat("AVAVAV ", 20), FontFeatures{FontFeatureKerning: true})
+	kerned.SetWidth(100)
+	require.NoError(t, kerned.wrapText())
+	for _, line := range kerned.lines {
+		require.LessOrEqual(t, kerned.getTextLineWidth(line), 100*1000.0)
+	}
+	require.NoError(t, c.Draw(kerned))
+
+	para := c.NewParagraph("Figures 0123456789 in small caps")
+	para.SetFont(font)
+	para.SetFontFeatures(FontFeatures{FontFeatureSmallCaps: true, FontFeatureOldStyleFigures: true})
+	require.NoError(t, c.Draw(para))
+
+	testWrite(t, c, "text_font_features.pdf")
+}
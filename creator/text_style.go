@@ -17,14 +17,107 @@ type TextStyle struct {
 	// The font the text will use.
 	Font *model.PdfFont
 
+	// The fonts displaying the characters the font has no glyphs for, in order of preference,
+	// e.g. CJK fonts or fonts of symbols and emoji. The text of a chunk is displayed in runs of
+	// the first font having glyphs for them.
+	FallbackFonts []*model.PdfFont
+
 	// The size of the font.
 	FontSize float64
 
 	// The character spacing.
 	CharSpacing float64
 
+	// The word spacing, in points, widening each space.
+	WordSpacing float64
+
+	// The horizontal scaling of the glyphs and spacings, in percent (100 if 0).
+	HorizontalScaling float64
+
+	// The text rise, in points, raising the text above the baseline if positive, e.g. for
+	// superscripts, and lowering it if negative, e.g. for subscripts.
+	TextRise float64
+
 	// The rendering mode.
 	RenderingMode TextRenderingMode
+
+	// The color and line width of the outline of the glyphs drawn with a stroking rendering
+	// mode, e.g. TextRenderingModeFillStroke. Black and 1 point if not set.
+	OutlineColor Color
+	OutlineSize  float64
+
+	// The color of the background of the text, from the descent to the ascent of the font. The
+	// background is not filled if nil.
+	BackgroundColor Color
+
+	// The decoration lines of the text, along with their styles.
+	Underline          bool
+	UnderlineStyle     TextDecorationLineStyle
+	StrikeThrough      bool
+	StrikeThroughStyle TextDecorationLineStyle
+	Overline           bool
+	OverlineStyle      TextDecorationLineStyle
+
+	// The OpenType features of the text, e.g. kerning or small capitals. Text with features is
+	// laid out with the shaping of its font, which applies kerning and standard ligatures unless
+	// disabled. Features only apply to fonts supporting shaping (see model.PdfFont.CanShape).
+	Features FontFeatures
+}
+
+// FontFeature is an OpenType layout feature, identified by its tag.
+type FontFeature string
+
+// OpenType layout features.
+const (
+	// FontFeatureKerning adjusts the spacing between pairs of glyphs.
+	FontFeatureKerning FontFeature = "kern"
+	// FontFeatureLigatures replaces sequences of glyphs by ligatures, e.g. "fi".
+	FontFeatureLigatures FontFeature = "liga"
+	// FontFeatureSmallCaps displays lowercase letters as small capitals.
+	FontFeatureSmallCaps FontFeature = "smcp"
+	// FontFeatureTabularFigures displays figures of equal widths.
+	FontFeatureTabularFigures FontFeature = "tnum"
+	// FontFeatureOldStyleFigures displays figures of varying heights, matching lowercase text.
+	FontFeatureOldStyleFigures FontFeature = "onum"
+)
+
+// FontFeatures enables (true) or disables (false) OpenType layout features. The features not in
+// the map keep their default state.
+type FontFeatures map[FontFeature]bool
+
+// shapeFeatures returns the feature toggles of `f` for text shaping.
+func (f FontFeatures) shapeFeatures() map[string]bool {
+	if len(f) == 0 {
+		return nil
+	}
+	features := make(map[string]bool, len(f))
+	for feature, enabled := range f {
+		features[string(feature)] = enabled
+	}
+	return features
+}
+
+// horizontalScale returns the horizontal scaling factor of the glyphs of `s`, 1 if unscaled.
+func (s *TextStyle) horizontalScale() float64 {
+	if s.HorizontalScaling <= 0 {
+		return 1
+	}
+	return s.HorizontalScaling / 100.0
+}
+
+// glyphWidth returns the width of a glyph of width `wx` in glyph space units displayed with `s`,
+// in thousandths of points, without spacing.
+func (s *TextStyle) glyphWidth(wx float64) float64 {
+	return s.FontSize * wx * s.horizontalScale()
+}
+
+// spacing returns the spacing following the rune `r` displayed with `s`, in thousandths of
+// points: the word spacing after spaces and the character spacing after other runes.
+func (s *TextStyle) spacing(r rune) float64 {
+	if r == ' ' {
+		return s.WordSpacing * 1000.0
+	}
+	return s.CharSpacing * 1000.0 * s.horizontalScale()
 }
 
 // newTextStyle creates a new text style object using the specified font.
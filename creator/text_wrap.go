@@ -0,0 +1,564 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/internal/linebreak"
+)
+
+// Hyphenator finds the positions words can be hyphenated at, e.g. the patterns of a language of
+// the hyphenation package.
+type Hyphenator interface {
+	// Hyphenate returns the indices of the runes of `word` a hyphen can be inserted before, in
+	// increasing order.
+	Hyphenate(word string) []int
+}
+
+const (
+	// softHyphen marks a position a word can be hyphenated at. It is displayed as a hyphen only
+	// when a line is broken there.
+	softHyphen = '\u00AD'
+	// zeroWidthSpace marks a position a line can be broken at. It is not displayed.
+	zeroWidthSpace = '\u200B'
+)
+
+// isBreakHint returns true for the invisible runes marking break opportunities.
+func isBreakHint(r rune) bool {
+	return r == softHyphen || r == zeroWidthSpace
+}
+
+// removeBreakHints returns `chunks` with the soft hyphens and zero width spaces of their text
+// removed. `chunks` is returned as is if none has.
+func removeBreakHints(chunks []*TextChunk) []*TextChunk {
+	var stripped []*TextChunk
+	for i, chunk := range chunks {
+		if stripped == nil && strings.IndexFunc(chunk.Text, isBreakHint) < 0 {
+			continue
+		}
+		if stripped == nil {
+			stripped = append(stripped, chunks[:i]...)
+		}
+		stripped = append(stripped, &TextChunk{
+			Text:       strings.Map(dropBreakHint, chunk.Text),
+			Style:      chunk.Style,
+			annotation: chunk.annotation,
+		})
+	}
+	if stripped == nil {
+		return chunks
+	}
+	return stripped
+}
+
+// dropBreakHint maps the break hints to -1, removing them with strings.Map.
+func dropBreakHint(r rune) rune {
+	if isBreakHint(r) {
+		return -1
+	}
+	return r
+}
+
+// Knuth-Plass line breaking parameters, in the units of TeX.
+const (
+	// hyphenPenalty is the penalty of breaking lines at hyphens.
+	hyphenPenalty = 50
+	// linePenalty is added to the badness of each line, favoring fewer lines.
+	linePenalty = 10
+	// flaggedDemerits are added for consecutive lines ending with hyphens.
+	flaggedDemerits = 3000
+	// fitnessDemerits are added for consecutive lines of very different tightness.
+	fitnessDemerits = 100
+	// maxBadness is the badness of lines which spacing cannot be adjusted.
+	maxBadness = 10000
+)
+
+// wrapOptions are the options of wrapping text into lines.
+type wrapOptions struct {
+	// width is the width of the lines, in points.
+	width float64
+	// direction is the resolved base direction of the text.
+	direction TextDirection
+	// shaped specifies whether the text is measured shaped.
+	shaped bool
+	// justify specifies whether the lines are justified, their spaces being shrinkable.
+	justify bool
+	// hyphenator hyphenates the words, nil to only hyphenate at soft hyphens.
+	hyphenator Hyphenator
+	// lineBreaking is the algorithm choosing the line breaks.
+	lineBreaking LineBreaking
+}
+
+// wrapShapedChunks wraps the text of `chunks`, paragraphs of direction `dir`, into lines of
+// `width` points, measuring the text shaped.
+func wrapShapedChunks(chunks []*TextChunk, width float64, dir TextDirection) ([][]*TextChunk, error) {
+	return wrapChunks(chunks, wrapOptions{width: width, direction: dir, shaped: true})
+}
+
+// wrapChunks wraps the text of `chunks` into lines as specified by `opts`. Lines are broken at
+// the line break opportunities of the Unicode line breaking algorithm, e.g. after spaces or
+// between ideographs, at soft hyphens and hyphenation positions or, for words longer than a line,
+// between clusters. Line feeds end paragraphs. The annotations of the chunks are copied to the
+// chunks of each line.
+func wrapChunks(chunks []*TextChunk, opts wrapOptions) ([][]*TextChunk, error) {
+	lines, _, err := wrapChunkLines(chunks, opts)
+	return lines, err
+}
+
+// wrapChunkLines wraps the text of `chunks` into lines like wrapChunks, also returning the
+// offsets of the lines: the indices of their first runes in the text of the chunks.
+func wrapChunkLines(chunks []*TextChunk, opts wrapOptions) ([][]*TextChunk, []int, error) {
+	var lines [][]*TextChunk
+	var offsets []int
+	var runes []rune
+	var owners []int
+	var pos, start int
+	appendSegment := func(chunk int) error {
+		segment, starts, err := wrapParagraph(chunks, runes, owners, chunk, opts)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, segment...)
+		for _, s := range starts {
+			offsets = append(offsets, start+s)
+		}
+		return nil
+	}
+	for k, chunk := range chunks {
+		for _, r := range chunk.Text {
+			pos++
+			if r != '\u000A' { // LF
+				runes = append(runes, r)
+				owners = append(owners, k)
+				continue
+			}
+			if err := appendSegment(k); err != nil {
+				return nil, nil, err
+			}
+			runes, owners = nil, nil
+			start = pos
+		}
+	}
+	if len(runes) > 0 {
+		if err := appendSegment(owners[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return lines, offsets, nil
+}
+
+// sliceChunks returns the chunks of the text of `chunks` following its `offset` first runes.
+func sliceChunks(chunks []*TextChunk, offset int) []*TextChunk {
+	var sliced []*TextChunk
+	for _, chunk := range chunks {
+		runes := []rune(chunk.Text)
+		if offset >= len(runes) {
+			offset -= len(runes)
+			continue
+		}
+		part := *chunk
+		part.Text = string(runes[offset:])
+		sliced = append(sliced, &part)
+		offset = 0
+	}
+	return sliced
+}
+
+// wrapText is the text of a paragraph being wrapped.
+type wrapText struct {
+	runes []rune
+	// owners are the indices of the chunks of the runes.
+	owners []int
+	// widths are the widths of the runes, in thousandths of points, including the character
+	// spacing `tracking` not applied after the last rune of a line. The width of a cluster is
+	// that of its first rune.
+	widths   []float64
+	tracking []float64
+	// breakable specifies whether lines can be broken before the runes as a last resort, i.e.
+	// between clusters.
+	breakable []bool
+}
+
+// lineBreak is a position a paragraph can be broken at.
+type lineBreak struct {
+	// pos is the index of the rune starting the next line.
+	pos int
+	// hyphen is the width of the hyphen displayed at the end of the line, 0 if none.
+	hyphen float64
+	// flagged marks breaks after hyphens, penalized by the optimal line breaking.
+	flagged bool
+	// forced marks mandatory breaks.
+	forced bool
+}
+
+// wrapParagraph wraps `runes`, the text of a paragraph made of the runes of the chunks `owners`
+// of `chunks`, returning the lines with the indices of their first runes. An empty paragraph is
+// a line with an empty chunk of `chunk`.
+func wrapParagraph(chunks []*TextChunk, runes []rune, owners []int, chunk int,
+	opts wrapOptions) ([][]*TextChunk, []int, error) {
+	if len(runes) == 0 {
+		return [][]*TextChunk{makeWrappedLine(chunks, nil, nil, chunk, false)}, []int{0}, nil
+	}
+
+	text := &wrapText{runes: runes, owners: owners}
+	var err error
+	if opts.shaped {
+		err = text.measureShaped(chunks, opts.direction)
+	} else {
+		err = text.measure(chunks)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates := text.lineBreaks(chunks, opts.hyphenator)
+	maxWidth := opts.width * 1000.0
+	var breaks []lineBreak
+	if opts.lineBreaking == LineBreakingOptimal {
+		breaks = text.breakOptimal(candidates, maxWidth, opts.justify)
+	}
+	if breaks == nil {
+		breaks = text.breakGreedy(candidates, maxWidth)
+	}
+
+	lines := make([][]*TextChunk, 0, len(breaks))
+	starts := make([]int, 0, len(breaks))
+	start := 0
+	for _, b := range breaks {
+		lines = append(lines, makeWrappedLine(chunks, runes[start:b.pos], owners[start:b.pos], chunk,
+			b.hyphen > 0))
+		starts = append(starts, start)
+		start = b.pos
+	}
+	return lines, starts, nil
+}
+
+// makeWrappedLine returns the chunks of a line of the text `runes` of the chunks `owners` of
+// `chunks`, without trailing spaces and break hints, ended by a hyphen if `hyphen` is true. An
+// empty line is a line with an empty chunk of `chunk`.
+func makeWrappedLine(chunks []*TextChunk, runes []rune, owners []int, chunk int, hyphen bool) []*TextChunk {
+	end := len(runes)
+	for end > 0 && (unicode.IsSpace(runes[end-1]) || isBreakHint(runes[end-1])) {
+		end--
+	}
+	if end == 0 {
+		return []*TextChunk{{Style: chunks[chunk].Style, annotation: copyAnnotation(chunks[chunk].annotation)}}
+	}
+
+	var line []*TextChunk
+	for i := 0; i < end; {
+		j := i + 1
+		for j < end && owners[j] == owners[i] {
+			j++
+		}
+		src := chunks[owners[i]]
+		text := strings.Map(dropBreakHint, string(runes[i:j]))
+		if hyphen && j == end {
+			text += "-"
+		}
+		line = append(line, &TextChunk{
+			Text:       text,
+			Style:      src.Style,
+			annotation: copyAnnotation(src.annotation),
+		})
+		i = j
+	}
+	return line
+}
+
+// measure measures the runes of `t`, text of `chunks`, with the metrics of their fonts.
+func (t *wrapText) measure(chunks []*TextChunk) error {
+	n := len(t.runes)
+	t.widths = make([]float64, n)
+	t.tracking = make([]float64, n)
+	t.breakable = make([]bool, n)
+	for i, r := range t.runes {
+		t.breakable[i] = true
+		if isBreakHint(r) {
+			continue
+		}
+
+		style := &chunks[t.owners[i]].Style
+		metrics, found := style.Font.GetRuneMetrics(r)
+		if !found {
+			common.Log.Debug("Rune char metrics not found! %v\n", r)
+			return errors.New("glyph char metrics missing")
+		}
+		t.widths[i] = style.glyphWidth(metrics.Wx) + style.spacing(r)
+		if r != ' ' {
+			t.tracking[i] = style.spacing(r)
+		}
+	}
+	return nil
+}
+
+// lineBreaks returns the positions the text of `t` can be broken at, in increasing order, ending
+// with the end of the text. The words are hyphenated by `hyphenator` unless nil or hyphenated by
+// soft hyphens.
+func (t *wrapText) lineBreaks(chunks []*TextChunk, hyphenator Hyphenator) []lineBreak {
+	n := len(t.runes)
+	ops := linebreak.Opportunities(t.runes)
+	var hyphens map[int]bool
+	if hyphenator != nil {
+		hyphens = t.hyphenate(hyphenator)
+	}
+
+	var breaks []lineBreak
+	for i := 1; i < n; i++ {
+		prev := t.runes[i-1]
+		switch {
+		case ops[i] == linebreak.MandatoryBreak:
+			breaks = append(breaks, lineBreak{pos: i, forced: true})
+		case ops[i] == linebreak.Break && prev == softHyphen, ops[i] != linebreak.Break && hyphens[i]:
+			if w := t.hyphenWidth(chunks, i); w > 0 {
+				breaks = append(breaks, lineBreak{pos: i, hyphen: w, flagged: true})
+			}
+		case ops[i] == linebreak.Break:
+			flagged := prev == '-' || prev == '\u2010'
+			breaks = append(breaks, lineBreak{pos: i, flagged: flagged})
+		}
+	}
+	return append(breaks, lineBreak{pos: n, forced: true})
+}
+
+// hyphenate returns the positions the words of `t` are hyphenated at by `hyphenator`. The words
+// are the runs of letters and marks, those with soft hyphens being left as is.
+func (t *wrapText) hyphenate(hyphenator Hyphenator) map[int]bool {
+	hyphens := map[int]bool{}
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.Is(unicode.M, r) || r == softHyphen
+	}
+	for start := 0; start < len(t.runes); {
+		if !unicode.IsLetter(t.runes[start]) {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(t.runes) && isWordRune(t.runes[end]) {
+			end++
+		}
+		word := t.runes[start:end]
+		if !strings.ContainsRune(string(word), softHyphen) {
+			for _, pos := range hyphenator.Hyphenate(string(word)) {
+				if pos > 0 && pos < len(word) && t.breakable[start+pos] {
+					hyphens[start+pos] = true
+				}
+			}
+		}
+		start = end
+	}
+	return hyphens
+}
+
+// hyphenWidth returns the width of the hyphen ending a line broken before the rune `pos` of
+// `t`, in the style of the preceding rune. Returns 0 if the font has no hyphen.
+func (t *wrapText) hyphenWidth(chunks []*TextChunk, pos int) float64 {
+	style := &chunks[t.owners[pos-1]].Style
+	metrics, found := style.Font.GetRuneMetrics('-')
+	if !found {
+		return 0
+	}
+	return style.glyphWidth(metrics.Wx)
+}
+
+// lineEnd returns the end of the content of a line ending before the rune `end` of `t`, i.e.
+// without its trailing spaces, and at least `start`.
+func (t *wrapText) lineEnd(start, end int) int {
+	for end > start && (unicode.IsSpace(t.runes[end-1]) || isBreakHint(t.runes[end-1])) {
+		end--
+	}
+	return end
+}
+
+// lineWidth returns the width of the line of `t` from the rune `start` to the break `b`, in
+// thousandths of points.
+func (t *wrapText) lineWidth(start int, b lineBreak) float64 {
+	end := t.lineEnd(start, b.pos)
+	var width float64
+	for i := start; i < end; i++ {
+		width += t.widths[i]
+	}
+	if end > start {
+		width -= t.tracking[end-1]
+	}
+	return width + b.hyphen
+}
+
+// breakGreedy returns the breaks of the lines of `t` filled with as much text as fits in
+// `maxWidth` thousandths of points, choosing among the `candidates` breaks. Words longer than a
+// line are broken between clusters.
+func (t *wrapText) breakGreedy(candidates []lineBreak, maxWidth float64) []lineBreak {
+	var breaks []lineBreak
+	start, next := 0, 0
+	var lineWidth float64
+	for i, r := range t.runes {
+		// The candidates before the rune.
+		last := next
+		for last < len(candidates) && candidates[last].pos <= i {
+			last++
+		}
+		if last > 0 && candidates[last-1].forced && candidates[last-1].pos == i && i > start {
+			breaks = append(breaks, candidates[last-1])
+			start, next, lineWidth = i, last, 0
+		}
+
+		if r != ' ' && t.breakable[i] && i > start && lineWidth+t.widths[i]-t.tracking[i] > maxWidth {
+			// Break at the last candidate the line fits with, else before the rune.
+			b := lineBreak{pos: i}
+			for k := last - 1; k >= next; k-- {
+				c := candidates[k]
+				if c.pos > start && (c.hyphen == 0 || t.lineWidth(start, c) <= maxWidth) {
+					b = c
+					break
+				}
+			}
+			breaks = append(breaks, b)
+			start, lineWidth = b.pos, 0
+			for next < len(candidates) && candidates[next].pos <= start {
+				next++
+			}
+			for j := start; j < i; j++ {
+				lineWidth += t.widths[j]
+			}
+		}
+		lineWidth += t.widths[i]
+	}
+	return append(breaks, lineBreak{pos: len(t.runes), forced: true})
+}
+
+// breakNode is a feasible break of the optimal line breaking.
+type breakNode struct {
+	b lineBreak
+	// fitness is the tightness class of the line ending at the break, from 0 (tight) to 3 (very
+	// loose).
+	fitness int
+	// demerits are the total demerits of the lines up to the break.
+	demerits float64
+	// previous is the break starting the line, nil for the start of the paragraph.
+	previous *breakNode
+}
+
+// breakOptimal returns the breaks of the lines of `t` chosen among `candidates` with the
+// Knuth-Plass algorithm, minimizing the demerits of the lines of `maxWidth` thousandths of
+// points over the paragraph. Spaces stretch by half and, for justified lines, shrink by a third
+// of their widths. Returns nil if the paragraph cannot be broken into lines fitting in
+// `maxWidth`, e.g. for words longer than a line.
+func (t *wrapText) breakOptimal(candidates []lineBreak, maxWidth float64, justify bool) []lineBreak {
+	n := len(t.runes)
+	widths := make([]float64, n+1)
+	stretch := make([]float64, n+1)
+	shrink := make([]float64, n+1)
+	for i, r := range t.runes {
+		widths[i+1] = widths[i] + t.widths[i]
+		stretch[i+1], shrink[i+1] = stretch[i], shrink[i]
+		if r == ' ' {
+			stretch[i+1] += t.widths[i] / 2
+			if justify {
+				shrink[i+1] += t.widths[i] / 3
+			}
+		}
+	}
+
+	active := []*breakNode{{fitness: 1}}
+	for _, c := range candidates {
+		var best [4]*breakNode
+		remaining := active[:0:0]
+		for _, a := range active {
+			start := a.b.pos
+			end := t.lineEnd(start, c.pos)
+			width := widths[end] - widths[start] + c.hyphen
+			if end > start {
+				width -= t.tracking[end-1]
+			}
+
+			// The adjustment ratio of the spaces of the line.
+			var ratio float64
+			switch {
+			case width < maxWidth && c.forced:
+				ratio = 0
+			case width < maxWidth:
+				ratio = math.Inf(1)
+				if s := stretch[end] - stretch[start]; s > 0 {
+					ratio = (maxWidth - width) / s
+				}
+			case width > maxWidth:
+				ratio = math.Inf(-1)
+				if s := shrink[end] - shrink[start]; s > 0 {
+					ratio = (maxWidth - width) / s
+				}
+			}
+			if ratio < -1 {
+				// The line is too long, as are the lines ending at the next breaks.
+				continue
+			}
+			remaining = append(remaining, a)
+
+			badness := math.Min(100*math.Pow(math.Abs(ratio), 3), maxBadness)
+			demerits := math.Pow(linePenalty+badness, 2)
+			if c.hyphen > 0 || c.flagged {
+				demerits += hyphenPenalty * hyphenPenalty
+			}
+			if c.flagged && a.b.flagged {
+				demerits += flaggedDemerits
+			}
+			fitness := 3
+			switch {
+			case ratio < -0.5:
+				fitness = 0
+			case ratio <= 0.5:
+				fitness = 1
+			case ratio <= 1:
+				fitness = 2
+			}
+			if a.previous != nil && math.Abs(float64(fitness-a.fitness)) > 1 {
+				demerits += fitnessDemerits
+			}
+			demerits += a.demerits
+
+			// Ties keep the latest start, filling the previous lines.
+			if node := best[fitness]; node == nil || demerits <= node.demerits {
+				best[fitness] = &breakNode{b: c, fitness: fitness, demerits: demerits, previous: a}
+			}
+		}
+
+		active = remaining
+		if c.forced {
+			active = nil
+		}
+		for _, node := range best {
+			if node != nil {
+				active = append(active, node)
+			}
+		}
+		if len(active) == 0 {
+			return nil
+		}
+	}
+
+	// The last active node with the fewest demerits ends the paragraph.
+	var last *breakNode
+	for _, node := range active {
+		if node.b.pos == n && (last == nil || node.demerits < last.demerits) {
+			last = node
+		}
+	}
+	if last == nil {
+		return nil
+	}
+	var breaks []lineBreak
+	for node := last; node.previous != nil; node = node.previous {
+		breaks = append(breaks, node.b)
+	}
+	for i, j := 0, len(breaks)-1; i < j; i, j = i+1, j-1 {
+		breaks[i], breaks[j] = breaks[j], breaks[i]
+	}
+	return breaks
+}
@@ -0,0 +1,150 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package creator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/hyphenation"
+	"github.com/carmel/unipdf/model"
+)
+
+// lineTexts returns the text of each of `lines`.
+func lineTexts(lines [][]*TextChunk) []string {
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		for _, chunk := range line {
+			texts[i] += chunk.Text
+		}
+	}
+	return texts
+}
+
+// newUnitWrapText returns the text `text` to wrap, each rune being 1000 wide.
+func newUnitWrapText(text string) *wrapText {
+	runes := []rune(text)
+	t := &wrapText{
+		runes:     runes,
+		owners:    make([]int, len(runes)),
+		widths:    make([]float64, len(runes)),
+		tracking:  make([]float64, len(runes)),
+		breakable: make([]bool, len(runes)),
+	}
+	for i := range runes {
+		t.widths[i] = 1000
+		t.breakable[i] = true
+	}
+	return t
+}
+
+// breakTexts returns the lines of `t` broken at `breaks`.
+func breakTexts(t *wrapText, breaks []lineBreak) []string {
+	var lines []string
+	start := 0
+	for _, b := range breaks {
+		lines = append(lines, strings.TrimRight(string(t.runes[start:b.pos]), " "))
+		start = b.pos
+	}
+	return lines
+}
+
+func TestStyledParagraphBreakHints(t *testing.T) {
+	c := New()
+	p := c.NewStyledParagraph()
+	chunk := p.Append("extra\u00ADordinary one\u200Btwo")
+	chunk.Style.FontSize = 10
+
+	// Soft hyphens are displayed as hyphens at the end of lines only.
+	p.SetWidth(40)
+	require.Equal(t, []string{"extra-", "ordinary", "onetwo"}, lineTexts(p.lines))
+	p.SetWidth(30)
+	require.Equal(t, []string{"extra-", "ordina", "ry one", "two"}, lineTexts(p.lines))
+
+	// Break hints are not displayed in unwrapped text.
+	p.SetEnableWrap(false)
+	require.NoError(t, p.wrapText())
+	require.Equal(t, []string{"extraordinary onetwo"}, lineTexts(p.lines))
+	require.Equal(t, p.getTextWidth(), p.getTextLineWidth(p.lines[0]))
+}
+
+func TestStyledParagraphHyphenation(t *testing.T) {
+	en, err := hyphenation.Load("en")
+	require.NoError(t, err)
+
+	c := New()
+	p := c.NewStyledParagraph()
+	p.SetTextAlignment(TextAlignmentJustify)
+	text := "Hyphenation algorithms considerably improve the justification of paragraphs " +
+		"displayed in narrow columns, the characteristic weakness of typesetting systems."
+	p.Append(text)
+	p.SetWidth(80)
+	unhyphenated := lineTexts(p.lines)
+
+	p.SetHyphenator(en)
+	hyphenated := lineTexts(p.lines)
+	require.Less(t, len(hyphenated), len(unhyphenated))
+
+	var words []string
+	var hyphens int
+	for i, line := range hyphenated {
+		require.LessOrEqual(t, p.getTextLineWidth(p.lines[i]), 80*1000.0, line)
+		if strings.HasSuffix(line, "-") {
+			hyphens++
+			line = strings.TrimSuffix(line, "-") + "\u00AD"
+		} else {
+			line += " "
+		}
+		words = append(words, line)
+	}
+	require.Greater(t, hyphens, 0)
+	require.Equal(t, text, strings.TrimSpace(strings.ReplaceAll(strings.Join(words, ""), "\u00AD", "")))
+
+	// The optimal line breaking hyphenates as well, the spaces of justified lines shrinking by
+	// up to a third of their widths.
+	p.SetLineBreaking(LineBreakingOptimal)
+	require.LessOrEqual(t, len(p.lines), len(hyphenated))
+	for i, line := range p.lines {
+		require.NotEmpty(t, lineTexts([][]*TextChunk{line})[0])
+		require.LessOrEqual(t, p.getTextLineWidth(line), 80*1000.0*1.05, i)
+	}
+	require.NoError(t, c.Draw(p))
+	testWriteAndRender(t, c, "styled_paragraph_hyphenation.pdf")
+}
+
+func TestWrapIdeographs(t *testing.T) {
+	text := newUnitWrapText("日本語の文章です。「東京」へ行く")
+	breaks := text.breakGreedy(text.lineBreaks(nil, nil), 3000)
+	// Lines do not start with closing punctuation nor end with opening punctuation.
+	require.Equal(t, []string{"日本語", "の文章", "です。", "「東", "京」へ", "行く"}, breakTexts(text, breaks))
+
+	text = newUnitWrapText("한국어 텍스트")
+	breaks = text.breakGreedy(text.lineBreaks(nil, nil), 3000)
+	require.Equal(t, []string{"한국어", "텍스트"}, breakTexts(text, breaks))
+}
+
+func TestWrapOptimal(t *testing.T) {
+	// The greedy line breaking fills the first lines, leaving the last line loose; the optimal
+	// line breaking evens the lines out.
+	text := newUnitWrapText("aaaa bb c ddd ee ffff g hh")
+	candidates := text.lineBreaks(nil, nil)
+	greedy := text.breakGreedy(candidates, 9000)
+	require.Equal(t, []string{"aaaa bb c", "ddd ee", "ffff g hh"}, breakTexts(text, greedy))
+
+	optimal := text.breakOptimal(candidates, 9000, true)
+	require.Equal(t, []string{"aaaa bb", "c ddd ee", "ffff g hh"}, breakTexts(text, optimal))
+
+	// Words longer than a line are broken greedily.
+	text = newUnitWrapText("a abcdefgh b")
+	require.Nil(t, text.breakOptimal(text.lineBreaks(nil, nil), 4000, true))
+	style := TextStyle{Font: newStandard14Font(t, model.CourierName), FontSize: 1}
+	chunks := []*TextChunk{NewTextChunk("a abcdefgh b", style)}
+	lines, err := wrapChunks(chunks, wrapOptions{width: 2.4, lineBreaking: LineBreakingOptimal})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "abcd", "efgh", "b"}, lineTexts(lines))
+}
@@ -0,0 +1,38 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"github.com/carmel/unipdf/model"
+)
+
+// ExtractPagesText extracts the text of all the pages of `reader`, processing up to `workers`
+// pages concurrently (runtime.NumCPU() if `workers` <= 0). The returned slice holds the text of
+// page i+1 at index i. The objects of the pages are loaded one at a time, only the extraction of
+// their text runs in parallel (see model.PdfReader.ForEachPage).
+func ExtractPagesText(reader *model.PdfReader, workers int) ([]*PageText, error) {
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	pageTexts := make([]*PageText, numPages)
+	err = reader.ForEachPage(workers, func(pageNum int, page *model.PdfPage) error {
+		e, err := New(page)
+		if err != nil {
+			return err
+		}
+		pageText, _, _, err := e.ExtractPageText()
+		if err != nil {
+			return err
+		}
+		pageTexts[pageNum-1] = pageText
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pageTexts, nil
+}
@@ -0,0 +1,45 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package extractor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+func TestExtractPagesText(t *testing.T) {
+	helvetica, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	font := helvetica.ToPdfObject()
+
+	w := model.NewPdfWriter()
+	for i := 1; i <= 12; i++ {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: 200, Ury: 100}
+		require.NoError(t, page.AddFont("F1", font))
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (Page %d) Tj ET", i)
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewFlateEncoder()))
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := model.NewPdfReaderLazy(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	pageTexts, err := ExtractPagesText(reader, 4)
+	require.NoError(t, err)
+	require.Len(t, pageTexts, 12)
+	for i, pageText := range pageTexts {
+		require.Equal(t, fmt.Sprintf("Page %d", i+1), strings.TrimSpace(pageText.Text()))
+	}
+}
@@ -0,0 +1,962 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/creator"
+)
+
+// Converter converts HTML documents to the drawables of a creator.
+type Converter struct {
+	c *creator.Creator
+
+	// Fonts resolves the font families of the text to fonts.
+	Fonts *FontRegistry
+
+	// BaseDir is the directory the relative paths of the images are resolved from.
+	BaseDir string
+
+	// StyleSheet is a CSS style sheet applied to the documents, before their own style sheets.
+	StyleSheet string
+
+	// BaseStyle is the style of the text of the root element. Its font is replaced by the font
+	// of the default family of Fonts.
+	BaseStyle creator.TextStyle
+}
+
+// NewConverter returns a converter creating the drawables of the creator `c`, with the text
+// style of `c` and a registry of the standard 14 fonts.
+func NewConverter(c *creator.Creator) *Converter {
+	return &Converter{
+		c:         c,
+		Fonts:     NewFontRegistry(),
+		BaseStyle: c.NewTextStyle(),
+	}
+}
+
+// Convert converts the HTML document read from `r` to drawables, to be drawn in order with
+// Creator.Draw.
+func (conv *Converter) Convert(r io.Reader) ([]creator.Drawable, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return conv.ConvertString(string(src))
+}
+
+// ConvertString converts the HTML document `src` to drawables, to be drawn in order with
+// Creator.Draw.
+func (conv *Converter) ConvertString(src string) ([]creator.Drawable, error) {
+	root := parse(src)
+
+	sheet := conv.StyleSheet
+	forEachElement(root, func(n *node) {
+		if n.tag == "style" && len(n.children) > 0 {
+			sheet += "\n" + n.children[0].text
+		}
+	})
+	d := &document{
+		conv:  conv,
+		ua:    parseStyleSheet(userAgentStyleSheet),
+		rules: parseStyleSheet(sheet),
+	}
+
+	base := conv.BaseStyle
+	if base.FontSize <= 0 {
+		base.FontSize = 10
+	}
+	if base.Color == nil {
+		base.Color = creator.ColorBlack
+	}
+	rootStyle := &style{
+		fontFamily: conv.Fonts.Default,
+		fontSize:   base.FontSize,
+		color:      base.Color,
+		lineHeight: 1.2,
+		display:    "block",
+	}
+	d.rootSize = base.FontSize
+	d.base = base
+
+	f := &flow{doc: d, block: rootStyle}
+	if err := f.children(root, rootStyle); err != nil {
+		return nil, err
+	}
+	f.flush()
+	collapseMargins(f.items)
+	return f.items, nil
+}
+
+// Draw converts the HTML document read from `r` and draws it with the creator.
+func (conv *Converter) Draw(r io.Reader) error {
+	drawables, err := conv.Convert(r)
+	if err != nil {
+		return err
+	}
+	for _, d := range drawables {
+		if err := conv.c.Draw(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachElement calls `f` for the elements of the tree of `n`, in document order.
+func forEachElement(n *node, f func(n *node)) {
+	for _, child := range n.children {
+		if child.tag != "" {
+			f(child)
+			forEachElement(child, f)
+		}
+	}
+}
+
+// document is the state of the conversion of a document.
+type document struct {
+	conv *Converter
+	// ua and rules are the rules of the user agent and author style sheets.
+	ua    []*rule
+	rules []*rule
+	// rootSize is the font size of the root element, and base the style of its text.
+	rootSize float64
+	base     creator.TextStyle
+}
+
+// style returns the style of the element `n`, child of an element of style `parent`.
+func (d *document) style(n *node, parent *style) *style {
+	return computeStyle(n, parent, d.ua, d.rules, d.rootSize)
+}
+
+// textStyle returns the style of the text of an element of style `s`.
+func (d *document) textStyle(s *style) creator.TextStyle {
+	return s.textStyle(d.base, d.conv.Fonts)
+}
+
+// flow lays out a sequence of blocks, the drawables of which are collected in order.
+type flow struct {
+	doc   *document
+	items []creator.Drawable
+	// nested is true for the flows of table cells and list items, in which page breaks are
+	// ignored.
+	nested bool
+
+	// block is the style of the block containing the inline content, and left and right the
+	// insets of its content from the sides of the flow.
+	block *style
+	left  float64
+	right float64
+
+	// para is the paragraph of the inline content being laid out, nil if none, with its chunks.
+	para   *creator.StyledParagraph
+	chunks []*creator.TextChunk
+	// space is true if the inline content ends with collapsible whitespace.
+	space bool
+	// link is the target of the link of the inline content, "" if none.
+	link string
+}
+
+// add appends `d` to the drawables of `f`, ending the current paragraph.
+func (f *flow) add(d creator.Drawable) {
+	f.flush()
+	f.items = append(f.items, d)
+}
+
+// children lays out the children of the element `n` of style `s`.
+func (f *flow) children(n *node, s *style) error {
+	for _, child := range n.children {
+		if child.tag == "" {
+			f.text(child.text, s)
+			continue
+		}
+		cs := f.doc.style(child, s)
+		if cs.display == "none" {
+			continue
+		}
+		var err error
+		if cs.isBlock(child) {
+			err = f.blockElement(child, cs)
+		} else {
+			err = f.inlineElement(child, cs)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockElement lays out the block element `n` of style `s`.
+func (f *flow) blockElement(n *node, s *style) error {
+	if s.pageBreakBefore && !f.nested {
+		f.add(f.doc.conv.c.NewPageBreak())
+	}
+	f.flush()
+
+	var err error
+	switch {
+	case n.tag == "table":
+		var table *creator.Table
+		if table, err = f.table(n, s); err == nil && table != nil {
+			f.add(table)
+		}
+	case n.tag == "ul" || n.tag == "ol":
+		var list *creator.List
+		if list, err = f.list(n, s); err == nil && list != nil {
+			f.add(list)
+		}
+	case n.tag == "hr":
+		f.add(f.rule(s))
+	case s.background != nil || s.borderWidth > 0:
+		var table *creator.Table
+		if table, err = f.box(n, s); err == nil && table != nil {
+			f.add(table)
+		}
+	default:
+		err = f.blockContent(n, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.pageBreakAfter && !f.nested {
+		f.add(f.doc.conv.c.NewPageBreak())
+	}
+	return nil
+}
+
+// blockContent lays out the content of the block element `n` of style `s` in `f`, applying its
+// margins and padding to the drawables of its content.
+func (f *flow) blockContent(n *node, s *style) error {
+	block, left, right := f.block, f.left, f.right
+	f.block = s
+	f.left += s.margin.left + s.padding.left
+	f.right += s.margin.right + s.padding.right
+	start := len(f.items)
+
+	if n.tag == "pre" {
+		// A line feed following the start tag of a pre element is ignored.
+		if len(n.children) > 0 && n.children[0].tag == "" {
+			n.children[0].text = strings.TrimPrefix(strings.TrimPrefix(n.children[0].text, "\r"), "\n")
+		}
+	}
+	err := f.children(n, s)
+	f.flush()
+	f.block, f.left, f.right = block, left, right
+	if err != nil {
+		return err
+	}
+
+	if len(f.items) > start {
+		addVerticalMargins(f.items[start], s.margin.top, s.padding.top, 0, 0)
+		addVerticalMargins(f.items[len(f.items)-1], 0, 0, s.margin.bottom, s.padding.bottom)
+	}
+	return nil
+}
+
+// inlineElement lays out the inline element `n` of style `s`.
+func (f *flow) inlineElement(n *node, s *style) error {
+	switch n.tag {
+	case "br":
+		f.paragraph()
+		f.appendText("\n", s)
+		f.space = true
+		return nil
+	case "img":
+		img, err := f.image(n, s)
+		if err != nil {
+			return err
+		}
+		if img != nil {
+			f.add(img)
+		}
+		return nil
+	case "a":
+		link := f.link
+		if href := n.attr("href"); href != "" && !strings.HasPrefix(href, "#") {
+			f.link = href
+		}
+		err := f.children(n, s)
+		f.link = link
+		return err
+	}
+	return f.children(n, s)
+}
+
+// text lays out the text `text` of an element of style `s`. Unless preformatted, the whitespace
+// is collapsed.
+func (f *flow) text(text string, s *style) {
+	if s.pre {
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		text = strings.ReplaceAll(text, "\t", "    ")
+		if text != "" {
+			f.paragraph()
+			f.appendText(text, s)
+			f.space = false
+		}
+		return
+	}
+
+	var b strings.Builder
+	space := f.space || f.para == nil
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		b.WriteRune(r)
+		space = false
+	}
+	if b.Len() == 0 {
+		return
+	}
+	f.paragraph()
+	f.appendText(b.String(), s)
+	f.space = space
+}
+
+// paragraph returns the paragraph of the current inline content, created if needed.
+func (f *flow) paragraph() *creator.StyledParagraph {
+	if f.para == nil {
+		p := f.doc.conv.c.NewStyledParagraph()
+		p.SetTextAlignment(f.block.textAlign)
+		p.SetLineHeight(f.block.lineHeight)
+		p.SetMargins(f.left, f.right, 0, 0)
+		f.para = p
+		f.chunks = nil
+		f.space = true
+	}
+	return f.para
+}
+
+// appendText appends a chunk of the text `text` of style `s` to the current paragraph.
+func (f *flow) appendText(text string, s *style) {
+	var chunk *creator.TextChunk
+	if f.link != "" {
+		chunk = f.para.AddExternalLink(text, f.link)
+	} else {
+		chunk = f.para.Append(text)
+	}
+	chunk.Style = f.doc.textStyle(s)
+	f.chunks = append(f.chunks, chunk)
+}
+
+// flush ends the current paragraph, added to the drawables unless empty.
+func (f *flow) flush() {
+	if f.para == nil {
+		return
+	}
+	p, chunks := f.para, f.chunks
+	f.para, f.chunks = nil, nil
+
+	// The trailing whitespace is removed, except the line feeds of preformatted text.
+	for i := len(chunks) - 1; i >= 0; i-- {
+		chunk := chunks[i]
+		if f.block.pre {
+			chunk.Text = strings.TrimSuffix(chunk.Text, "\n")
+			break
+		}
+		chunk.Text = strings.TrimRight(chunk.Text, " \n")
+		if chunk.Text != "" {
+			break
+		}
+	}
+	for _, chunk := range chunks {
+		if chunk.Text != "" {
+			f.items = append(f.items, p)
+			return
+		}
+	}
+}
+
+// nestedFlow returns the flow of the content of a table cell or list item of style `s`.
+func (f *flow) nestedFlow(s *style) *flow {
+	return &flow{doc: f.doc, nested: true, block: s}
+}
+
+// content returns the drawables of `f` as a single drawable: the only drawable or a division
+// of the drawables, nil if empty.
+func (f *flow) content() (creator.VectorDrawable, error) {
+	f.flush()
+	collapseMargins(f.items)
+	var drawables []creator.VectorDrawable
+	for _, d := range f.items {
+		if vd, ok := d.(creator.VectorDrawable); ok {
+			drawables = append(drawables, vd)
+		}
+	}
+	switch len(drawables) {
+	case 0:
+		return nil, nil
+	case 1:
+		return drawables[0], nil
+	}
+	div := f.doc.conv.c.NewDivision()
+	for _, d := range drawables {
+		if err := div.Add(d); err != nil {
+			return nil, err
+		}
+	}
+	return div, nil
+}
+
+// box lays out the block element `n` of style `s` with a background or border, as a table of a
+// single cell.
+func (f *flow) box(n *node, s *style) (*creator.Table, error) {
+	inner := *s
+	inner.margin, inner.padding = box{}, box{}
+	sub := f.nestedFlow(&inner)
+	if err := sub.blockContent(n, &inner); err != nil {
+		return nil, err
+	}
+	content, err := sub.content()
+	if err != nil || content == nil {
+		return nil, err
+	}
+	addVerticalMargins(content, 0, s.padding.top, 0, s.padding.bottom)
+	addHorizontalMargins(content, 0, s.padding.right)
+
+	table := f.doc.conv.c.NewTable(1)
+	cell := table.NewCell()
+	cell.SetIndent(s.padding.left)
+	f.styleCell(cell, s, nil)
+	if err := cell.SetContent(content); err != nil {
+		return nil, err
+	}
+	table.SetMargins(f.left+s.margin.left, f.right+s.margin.right, s.margin.top, s.margin.bottom)
+	return table, nil
+}
+
+// rule returns a horizontal rule of style `s`, drawn as the bottom border of an empty table.
+func (f *flow) rule(s *style) *creator.Table {
+	table := f.doc.conv.c.NewTable(1)
+	cell := table.NewCell()
+	width := s.borderWidth
+	if width <= 0 {
+		width = 0.75
+	}
+	cell.SetBorder(creator.CellBorderSideBottom, creator.CellBorderStyleSingle, width)
+	color := s.borderColor
+	if color == nil {
+		color = creator.ColorRGBFrom8bit(128, 128, 128)
+	}
+	cell.SetBorderColor(color)
+	if err := table.SetRowHeight(1, s.fontSize/2); err != nil {
+		common.Log.Debug("ERROR: unable to set the height of a rule: %v", err)
+	}
+	top, bottom := s.margin.top, s.margin.bottom
+	if top == 0 && bottom == 0 {
+		top, bottom = s.fontSize/2, s.fontSize/2
+	}
+	table.SetMargins(f.left+s.margin.left, f.right+s.margin.right, top, bottom)
+	return table
+}
+
+// styleCell applies the background, border and vertical alignment of `s` to `cell`, the
+// background and border of `fallback` applying if not set.
+func (f *flow) styleCell(cell *creator.TableCell, s, fallback *style) {
+	background, borderWidth, borderColor := s.background, s.borderWidth, s.borderColor
+	if fallback != nil {
+		if background == nil {
+			background = fallback.background
+		}
+		if borderWidth <= 0 {
+			borderWidth, borderColor = fallback.borderWidth, fallback.borderColor
+		}
+	}
+	if background != nil {
+		cell.SetBackgroundColor(background)
+	}
+	if borderWidth > 0 {
+		cell.SetBorder(creator.CellBorderSideAll, creator.CellBorderStyleSingle, borderWidth)
+		if borderColor == nil {
+			borderColor = s.color
+		}
+		cell.SetBorderColor(borderColor)
+	}
+	switch s.verticalAlign {
+	case "middle":
+		cell.SetVerticalAlignment(creator.CellVerticalAlignmentMiddle)
+	case "bottom":
+		cell.SetVerticalAlignment(creator.CellVerticalAlignmentBottom)
+	}
+}
+
+// tableCell is a cell of a table being laid out.
+type tableCell struct {
+	node    *node
+	colspan int
+}
+
+// table lays out the table element `n` of style `s`. Each cell is laid out as a nested flow,
+// the rows of thead elements being repeated on the pages the table continues on.
+func (f *flow) table(n *node, s *style) (*creator.Table, error) {
+	// The rows of the table, and their styles, the rows of the tfoot elements being last.
+	type row struct {
+		node   *node
+		style  *style
+		header bool
+	}
+	var rows, footer []row
+	for _, child := range n.children {
+		if child.tag == "" {
+			continue
+		}
+		cs := f.doc.style(child, s)
+		if cs.display == "none" {
+			continue
+		}
+		switch child.tag {
+		case "tr":
+			rows = append(rows, row{node: child, style: cs})
+		case "thead", "tbody", "tfoot":
+			for _, tr := range child.children {
+				if tr.tag != "tr" {
+					continue
+				}
+				rs := f.doc.style(tr, cs)
+				if rs.background == nil {
+					rs.background = cs.background
+				}
+				r := row{node: tr, style: rs, header: child.tag == "thead"}
+				if child.tag == "tfoot" {
+					footer = append(footer, r)
+				} else {
+					rows = append(rows, r)
+				}
+			}
+		}
+	}
+	rows = append(rows, footer...)
+
+	// The table borders apply to the cells.
+	tableStyle := *s
+	if border, ok := n.attrs["border"]; ok && tableStyle.borderWidth <= 0 {
+		width, err := strconv.ParseFloat(border, 64)
+		if err != nil || border == "" {
+			width = 1
+		}
+		tableStyle.borderWidth = width * 0.75
+	}
+	tableStyle.background = nil
+
+	cols := 0
+	cells := make([][]tableCell, len(rows))
+	for i, r := range rows {
+		count := 0
+		for _, child := range r.node.children {
+			if child.tag != "td" && child.tag != "th" {
+				continue
+			}
+			colspan, err := strconv.Atoi(child.attr("colspan"))
+			if err != nil || colspan < 1 {
+				colspan = 1
+			}
+			cells[i] = append(cells[i], tableCell{node: child, colspan: colspan})
+			count += colspan
+		}
+		if count > cols {
+			cols = count
+		}
+	}
+	if cols == 0 {
+		return nil, nil
+	}
+
+	table := f.doc.conv.c.NewTable(cols)
+	if widths := columnWidths(cells, cols); widths != nil {
+		if err := table.SetColumnWidths(widths...); err != nil {
+			return nil, err
+		}
+	}
+
+	headerRows := 0
+	for i, r := range rows {
+		if r.header && i == headerRows {
+			headerRows++
+		}
+		count := 0
+		for _, c := range cells[i] {
+			cs := f.doc.style(c.node, r.style)
+			var cell *creator.TableCell
+			if c.colspan > 1 {
+				cell = table.MultiColCell(c.colspan)
+			} else {
+				cell = table.NewCell()
+			}
+			cell.SetIndent(cs.padding.left)
+			f.styleCell(cell, cs, &style{background: r.style.background,
+				borderWidth: tableStyle.borderWidth, borderColor: tableStyle.borderColor})
+
+			sub := f.nestedFlow(cs)
+			if err := sub.children(c.node, cs); err != nil {
+				return nil, err
+			}
+			content, err := sub.content()
+			if err != nil {
+				return nil, err
+			}
+			if content != nil {
+				addHorizontalMargins(content, 0, cs.padding.right)
+				if err := cell.SetContent(content); err != nil {
+					return nil, err
+				}
+			}
+			count += c.colspan
+		}
+		// Rows with fewer cells are completed with empty cells.
+		for ; count < cols; count++ {
+			cell := table.NewCell()
+			f.styleCell(cell, &style{}, &style{borderWidth: tableStyle.borderWidth,
+				borderColor: tableStyle.borderColor})
+		}
+	}
+	if headerRows > 0 {
+		if err := table.SetHeaderRows(1, headerRows); err != nil {
+			return nil, err
+		}
+	}
+
+	table.SetMargins(f.left+s.margin.left, f.right+s.margin.right, s.margin.top, s.margin.bottom)
+	return table, nil
+}
+
+// columnWidths returns the fractions of the width of a table of `cols` columns taken by each
+// column, from the percentage widths of the cells without colspan, nil if none is set. The
+// columns without width share the remaining width equally.
+func columnWidths(cells [][]tableCell, cols int) []float64 {
+	widths := make([]float64, cols)
+	set := false
+	for _, row := range cells {
+		col := 0
+		for _, c := range row {
+			value := strings.TrimSpace(c.node.attr("width"))
+			for _, d := range parseDeclarations(c.node.attr("style")) {
+				if d.property == "width" {
+					value = d.value
+				}
+			}
+			if c.colspan == 1 && strings.HasSuffix(value, "%") && widths[col] == 0 {
+				if w, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64); err == nil && w > 0 {
+					widths[col] = w / 100
+					set = true
+				}
+			}
+			col += c.colspan
+		}
+	}
+	if !set {
+		return nil
+	}
+
+	var total float64
+	unset := 0
+	for _, w := range widths {
+		total += w
+		if w == 0 {
+			unset++
+		}
+	}
+	if total > 1 || unset == 0 {
+		for i := range widths {
+			widths[i] /= total
+		}
+		if unset == 0 {
+			return widths
+		}
+		total = 1
+	}
+	if unset > 0 {
+		rest := (1 - total) / float64(unset)
+		for i, w := range widths {
+			if w == 0 {
+				widths[i] = rest
+			}
+		}
+	}
+	return widths
+}
+
+// listMarker returns the marker of the item `index` of a list of the list-style-type `listStyle`.
+func listMarker(listStyle string, index int) string {
+	switch listStyle {
+	case "none":
+		return ""
+	case "decimal":
+		return strconv.Itoa(index) + "."
+	case "lower-alpha", "lower-latin":
+		return alphaNumber(index, 'a') + "."
+	case "upper-alpha", "upper-latin":
+		return alphaNumber(index, 'A') + "."
+	case "lower-roman":
+		return strings.ToLower(romanNumber(index)) + "."
+	case "upper-roman":
+		return romanNumber(index) + "."
+	case "circle":
+		return "◦"
+	case "square":
+		return "▪"
+	}
+	return "•"
+}
+
+// alphaNumber returns the number `n` in letters from `first`: a, b, ..., z, aa, ab...
+func alphaNumber(n int, first rune) string {
+	var letters []rune
+	for n > 0 {
+		n--
+		letters = append([]rune{first + rune(n%26)}, letters...)
+		n /= 26
+	}
+	return string(letters)
+}
+
+// romanNumber returns the number `n` in uppercase roman numerals.
+func romanNumber(n int) string {
+	if n <= 0 || n >= 4000 {
+		return strconv.Itoa(n)
+	}
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// list lays out the list element `n` of style `s`. Each item is laid out as a nested flow.
+func (f *flow) list(n *node, s *style) (*creator.List, error) {
+	list := f.doc.conv.c.NewList()
+	items := 0
+	index := 1
+	if start, err := strconv.Atoi(n.attr("start")); err == nil {
+		index = start
+	}
+
+	for _, child := range n.children {
+		if child.tag == "" {
+			continue
+		}
+		cs := f.doc.style(child, s)
+		if cs.display == "none" {
+			continue
+		}
+		if value, err := strconv.Atoi(child.attr("value")); err == nil {
+			index = value
+		}
+
+		sub := f.nestedFlow(cs)
+		var err error
+		if child.tag == "li" {
+			err = sub.children(child, cs)
+		} else {
+			err = sub.blockElement(child, cs)
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := sub.content()
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			p := f.doc.conv.c.NewStyledParagraph()
+			p.Append(" ").Style = f.doc.textStyle(cs)
+			content = p
+		}
+
+		marker, err := list.Add(content)
+		if err != nil {
+			return nil, err
+		}
+		marker.Style = f.doc.textStyle(cs)
+		marker.Style.Underline, marker.Style.StrikeThrough, marker.Style.Overline = false, false, false
+		marker.Style.BackgroundColor = nil
+		marker.Text = listMarker(cs.listStyle, index)
+		if marker.Text != "" {
+			if _, ok := marker.Style.Font.GetRuneMetrics([]rune(marker.Text)[0]); !ok {
+				marker.Text = listMarker("disc", index)
+			}
+			marker.Text += " "
+		}
+		index++
+		items++
+	}
+	if items == 0 {
+		return nil, nil
+	}
+
+	list.SetMargins(f.left+s.margin.left+s.padding.left, f.right+s.margin.right+s.padding.right,
+		s.margin.top+s.padding.top, s.margin.bottom+s.padding.bottom)
+	return list, nil
+}
+
+// image lays out the image element `n` of style `s`, displayed as a block. The size of the image
+// is set by its width and height, in CSS pixels by default.
+func (f *flow) image(n *node, s *style) (*creator.Image, error) {
+	src := strings.TrimSpace(n.attr("src"))
+	if src == "" {
+		return nil, nil
+	}
+
+	var img *creator.Image
+	var err error
+	if strings.HasPrefix(src, "data:") {
+		comma := strings.IndexByte(src, ',')
+		if comma < 0 || !strings.HasSuffix(src[:comma], ";base64") {
+			return nil, errors.New("unsupported image data URL")
+		}
+		data, err := base64.StdEncoding.DecodeString(src[comma+1:])
+		if err != nil {
+			return nil, err
+		}
+		img, err = f.doc.conv.c.NewImageFromData(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		path := src
+		if u, err := url.Parse(src); err == nil && u.Scheme == "file" {
+			path = u.Path
+		} else if err == nil && u.Scheme != "" {
+			return nil, fmt.Errorf("unsupported image source %q", src)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(f.doc.conv.BaseDir, filepath.FromSlash(path))
+		}
+		if img, err = f.doc.conv.c.NewImageFromFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	width, hasWidth := parseLength(s.width, s.fontSize, f.doc.rootSize, 0)
+	height, hasHeight := parseLength(s.height, s.fontSize, f.doc.rootSize, 0)
+	hasWidth = hasWidth && width > 0
+	hasHeight = hasHeight && height > 0
+	switch {
+	case hasWidth && hasHeight:
+		img.SetWidth(width)
+		img.SetHeight(height)
+	case hasWidth:
+		img.ScaleToWidth(width)
+	case hasHeight:
+		img.ScaleToHeight(height)
+	default:
+		img.Scale(0.75, 0.75)
+	}
+
+	switch f.block.textAlign {
+	case creator.TextAlignmentCenter:
+		img.SetHorizontalAlignment(creator.HorizontalAlignmentCenter)
+	case creator.TextAlignmentRight:
+		img.SetHorizontalAlignment(creator.HorizontalAlignmentRight)
+	}
+	img.SetMargins(f.left+s.margin.left, f.right+s.margin.right, s.margin.top, s.margin.bottom)
+	return img, nil
+}
+
+// margins returns the margins of the drawable `d`, false if not supported.
+func margins(d creator.Drawable) (left, right, top, bottom float64, ok bool) {
+	switch t := d.(type) {
+	case *creator.StyledParagraph:
+		left, right, top, bottom = t.GetMargins()
+	case *creator.Table:
+		left, right, top, bottom = t.GetMargins()
+	case *creator.List:
+		left, right, top, bottom = t.Margins()
+	case *creator.Image:
+		left, right, top, bottom = t.GetMargins()
+	case *creator.Division:
+		left, right, top, bottom = t.GetMargins()
+	default:
+		return 0, 0, 0, 0, false
+	}
+	return left, right, top, bottom, true
+}
+
+// setMargins sets the margins of the drawable `d`.
+func setMargins(d creator.Drawable, left, right, top, bottom float64) {
+	switch t := d.(type) {
+	case *creator.StyledParagraph:
+		t.SetMargins(left, right, top, bottom)
+	case *creator.Table:
+		t.SetMargins(left, right, top, bottom)
+	case *creator.List:
+		t.SetMargins(left, right, top, bottom)
+	case *creator.Image:
+		t.SetMargins(left, right, top, bottom)
+	case *creator.Division:
+		t.SetMargins(left, right, top, bottom)
+	}
+}
+
+// addVerticalMargins adds the margin `marginTop` and padding `paddingTop` of a block to the top
+// margin of `d`, the first drawable of its content, and likewise for the bottom. Without padding,
+// the margins of the block and of its content collapse.
+func addVerticalMargins(d creator.Drawable, marginTop, paddingTop, marginBottom, paddingBottom float64) {
+	left, right, top, bottom, ok := margins(d)
+	if !ok {
+		return
+	}
+	collapse := func(inner, margin, padding float64) float64 {
+		if padding == 0 {
+			if margin > inner {
+				return margin
+			}
+			return inner
+		}
+		return inner + margin + padding
+	}
+	top = collapse(top, marginTop, paddingTop)
+	bottom = collapse(bottom, marginBottom, paddingBottom)
+	setMargins(d, left, right, top, bottom)
+}
+
+// addHorizontalMargins adds `left` and `right` to the horizontal margins of `d`.
+func addHorizontalMargins(d creator.Drawable, left, right float64) {
+	l, r, top, bottom, ok := margins(d)
+	if ok {
+		setMargins(d, l+left, r+right, top, bottom)
+	}
+}
+
+// collapseMargins collapses the bottom and top margins of the consecutive drawables of `items`:
+// the space between them is the largest of the margins.
+func collapseMargins(items []creator.Drawable) {
+	for i := 1; i < len(items); i++ {
+		_, _, _, prevBottom, ok := margins(items[i-1])
+		if !ok {
+			continue
+		}
+		left, right, top, bottom, ok := margins(items[i])
+		if !ok {
+			continue
+		}
+		if top > prevBottom {
+			top -= prevBottom
+		} else {
+			top = 0
+		}
+		setMargins(items[i], left, right, top, bottom)
+	}
+}
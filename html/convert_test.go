@@ -0,0 +1,121 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/creator"
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+const testDocument = `<!DOCTYPE html>
+<html>
+<head>
+<style>
+  h1 { color: #336699; border: 1px solid #336699 }
+  .total td { font-weight: bold }
+</style>
+</head>
+<body>
+<h1>Report title</h1>
+<p>Some <b>bold</b>, <i>italic</i> and <a href="https://example.com">linked</a> text.</p>
+<ul>
+  <li>First item
+  <li>Second item
+    <ol type="i"><li>Nested item</ol>
+</ul>
+<table border="1">
+  <thead><tr><th>Name<th>Amount</thead>
+  <tfoot><tr class="total"><td>Total<td>3</tfoot>
+  <tr><td>A<td>1
+  <tr><td colspan="2">B spans both columns
+</table>
+<div style="page-break-before: always; background: #eeeeee; padding: 4pt">Boxed text</div>
+<hr>
+<pre>  preformatted
+    text</pre>
+</body>
+</html>`
+
+func TestConvert(t *testing.T) {
+	c := creator.New()
+	drawables, err := NewConverter(c).ConvertString(testDocument)
+	require.NoError(t, err)
+
+	var types []string
+	for _, d := range drawables {
+		switch v := d.(type) {
+		case *creator.StyledParagraph:
+			types = append(types, "paragraph")
+		case *creator.List:
+			types = append(types, "list")
+		case *creator.Table:
+			types = append(types, "table")
+			if v.Cols() == 2 {
+				types[len(types)-1] += "2"
+			}
+		case *creator.PageBreak:
+			types = append(types, "break")
+		default:
+			types = append(types, "other")
+		}
+	}
+	// The heading with a border and the boxed division are one cell tables, the rule is a table
+	// with a bottom border.
+	require.Equal(t, []string{
+		"table", "paragraph", "list", "table2", "break", "table", "table", "paragraph",
+	}, types)
+
+	for _, d := range drawables {
+		require.NoError(t, c.Draw(d))
+	}
+	require.Equal(t, 2, c.Context().Page)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	text := extractText(t, buf.Bytes(), 1)
+	for _, s := range []string{"Report title", "bold", "linked", "Second item", "Nested item",
+		"Amount", "B spans both columns", "Total"} {
+		require.Contains(t, text, s)
+	}
+	require.NotContains(t, text, "Boxed text")
+	require.True(t, strings.Index(text, "B spans") < strings.Index(text, "Total"),
+		"footer rows are drawn last")
+	require.Contains(t, extractText(t, buf.Bytes(), 2), "Boxed text")
+}
+
+func TestFontRegistry(t *testing.T) {
+	r := NewFontRegistry()
+	font := r.Font(`"Open Sans", Times New Roman, serif`, true, true)
+	require.NotNil(t, font)
+	require.Equal(t, string(model.TimesBoldItalicName), font.BaseFont())
+
+	// The families missing a style fall back to the closest style.
+	regular := r.Font("courier", false, false)
+	r.Register("Custom", &FontFamily{Regular: regular})
+	require.Equal(t, regular, r.Font("custom", true, false))
+
+	require.Equal(t, string(model.HelveticaName), r.Font("unknown", false, false).BaseFont())
+}
+
+// extractText returns the text of page `pageNum` of the PDF `data`.
+func extractText(t *testing.T, data []byte, pageNum int) string {
+	r, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	page, err := r.GetPage(pageNum)
+	require.NoError(t, err)
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := e.ExtractText()
+	require.NoError(t, err)
+	return text
+}
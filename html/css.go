@@ -0,0 +1,361 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/carmel/unipdf/creator"
+)
+
+// declaration is a CSS property declaration.
+type declaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// parseDeclarations parses the CSS declarations `src`, e.g. the value of a style attribute:
+// "color: red; margin: 0 1em". Invalid declarations are ignored.
+func parseDeclarations(src string) []declaration {
+	var decls []declaration
+	for _, part := range strings.Split(stripComments(src), ";") {
+		colon := strings.IndexByte(part, ':')
+		if colon < 0 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(part[:colon]))
+		value := strings.TrimSpace(part[colon+1:])
+		important := false
+		if i := strings.Index(strings.ToLower(value), "!important"); i >= 0 {
+			important = true
+			value = strings.TrimSpace(value[:i])
+		}
+		if property == "" || value == "" {
+			continue
+		}
+		decls = append(decls, declaration{property: property, value: value, important: important})
+	}
+	return decls
+}
+
+// stripComments removes the comments of the CSS `src`.
+func stripComments(src string) string {
+	for {
+		start := strings.Index(src, "/*")
+		if start < 0 {
+			return src
+		}
+		end := strings.Index(src[start+2:], "*/")
+		if end < 0 {
+			return src[:start]
+		}
+		src = src[:start] + src[start+2+end+2:]
+	}
+}
+
+// simpleSelector is a compound selector matching a single element, e.g. "p.note".
+type simpleSelector struct {
+	tag     string
+	id      string
+	classes []string
+	// child is true if the element is a child of the element matched by the previous selector of
+	// the complex selector, a descendant otherwise.
+	child bool
+}
+
+// matches returns true if the element `n` matches `s`.
+func (s *simpleSelector) matches(n *node) bool {
+	if s.tag != "" && s.tag != "*" && s.tag != n.tag {
+		return false
+	}
+	if s.id != "" && s.id != n.attr("id") {
+		return false
+	}
+	classes := strings.Fields(n.attr("class"))
+	for _, class := range s.classes {
+		found := false
+		for _, c := range classes {
+			if c == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// rule is a CSS rule of a style sheet, with a single selector.
+type rule struct {
+	// selector is the complex selector of the rule: compound selectors separated by descendant or
+	// child combinators.
+	selector    []simpleSelector
+	specificity int
+	decls       []declaration
+}
+
+// matches returns true if the element `n` matches the selector of `r`.
+func (r *rule) matches(n *node) bool {
+	return matchSelector(r.selector, n)
+}
+
+// matchSelector returns true if `n` matches the complex selector `sel`.
+func matchSelector(sel []simpleSelector, n *node) bool {
+	last := len(sel) - 1
+	if last < 0 || !sel[last].matches(n) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	if sel[last].child {
+		return n.parent != nil && matchSelector(sel[:last], n.parent)
+	}
+	for p := n.parent; p != nil; p = p.parent {
+		if matchSelector(sel[:last], p) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseStyleSheet parses the rules of the CSS style sheet `src`, the rules with several selectors
+// being split. At-rules, e.g. @media, and the rules with unsupported selectors are ignored.
+func parseStyleSheet(src string) []*rule {
+	src = stripComments(src)
+	var rules []*rule
+	for len(src) > 0 {
+		open := strings.IndexByte(src, '{')
+		if open < 0 {
+			break
+		}
+		prelude := strings.TrimSpace(src[:open])
+		end := matchingBrace(src, open)
+		body := src[open+1 : end]
+		if end < len(src) {
+			end++
+		}
+		src = src[end:]
+
+		if strings.HasPrefix(prelude, "@") {
+			continue
+		}
+		decls := parseDeclarations(body)
+		for _, s := range strings.Split(prelude, ",") {
+			sel, specificity, ok := parseSelector(s)
+			if !ok {
+				continue
+			}
+			rules = append(rules, &rule{selector: sel, specificity: specificity, decls: decls})
+		}
+	}
+	return rules
+}
+
+// matchingBrace returns the index of the brace closing the brace at `open` in `src`, len(src) if
+// not closed.
+func matchingBrace(src string, open int) int {
+	depth := 0
+	for i := open; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(src)
+}
+
+// parseSelector parses the complex selector `src`, e.g. "div.note > p", returning its compound
+// selectors and specificity.
+func parseSelector(src string) ([]simpleSelector, int, bool) {
+	src = strings.ReplaceAll(src, ">", " > ")
+	var sel []simpleSelector
+	var specificity int
+	child := false
+	for _, field := range strings.Fields(src) {
+		if field == ">" {
+			if len(sel) == 0 {
+				return nil, 0, false
+			}
+			child = true
+			continue
+		}
+
+		s := simpleSelector{child: child}
+		child = false
+		for len(field) > 0 {
+			end := strings.IndexAny(field[1:], ".#")
+			if end < 0 {
+				end = len(field)
+			} else {
+				end++
+			}
+			part := field[:end]
+			field = field[end:]
+			switch {
+			case strings.ContainsAny(part, ":[()*+~") && part != "*":
+				// Pseudo-classes, attribute selectors and sibling combinators are not supported.
+				return nil, 0, false
+			case part[0] == '.' && len(part) > 1:
+				s.classes = append(s.classes, part[1:])
+				specificity += 10
+			case part[0] == '#' && len(part) > 1:
+				s.id = part[1:]
+				specificity += 100
+			case part[0] != '.' && part[0] != '#':
+				s.tag = strings.ToLower(part)
+				if part != "*" {
+					specificity++
+				}
+			default:
+				return nil, 0, false
+			}
+		}
+		sel = append(sel, s)
+	}
+	if len(sel) == 0 || child {
+		return nil, 0, false
+	}
+	return sel, specificity, true
+}
+
+// parseLength parses the CSS length `value`, returning it in points. Relative lengths are relative
+// to the font size `em`, of the root element `rem` and, for percentages, to `percent`.
+func parseLength(value string, em, rem, percent float64) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"rem", rem}, {"em", em}, {"ex", em / 2}, {"px", 0.75}, {"pt", 1}, {"pc", 12}, {"in", 72},
+		{"cm", 72 / 2.54}, {"mm", 72 / 25.4}, {"%", percent / 100},
+	}
+	scale := 0.75 // Unitless lengths are pixels.
+	for _, u := range units {
+		if strings.HasSuffix(value, u.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, u.suffix))
+			scale = u.scale
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f * scale, true
+}
+
+// parseBoxLengths parses the 1 to 4 lengths of a box shorthand property, e.g. margin, returning
+// the top, right, bottom and left lengths.
+func parseBoxLengths(value string, em, rem, percent float64) ([4]float64, bool) {
+	var box [4]float64
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 4 {
+		return box, false
+	}
+	lengths := make([]float64, len(fields))
+	for i, field := range fields {
+		l, ok := parseLength(field, em, rem, percent)
+		if !ok && field != "auto" {
+			return box, false
+		}
+		lengths[i] = l
+	}
+	switch len(lengths) {
+	case 1:
+		box = [4]float64{lengths[0], lengths[0], lengths[0], lengths[0]}
+	case 2:
+		box = [4]float64{lengths[0], lengths[1], lengths[0], lengths[1]}
+	case 3:
+		box = [4]float64{lengths[0], lengths[1], lengths[2], lengths[1]}
+	case 4:
+		box = [4]float64{lengths[0], lengths[1], lengths[2], lengths[3]}
+	}
+	return box, true
+}
+
+// namedColors are the CSS named colors supported, by name.
+var namedColors = map[string][3]byte{
+	"black": {0, 0, 0}, "silver": {192, 192, 192}, "gray": {128, 128, 128},
+	"grey": {128, 128, 128}, "white": {255, 255, 255}, "maroon": {128, 0, 0}, "red": {255, 0, 0},
+	"purple": {128, 0, 128}, "fuchsia": {255, 0, 255}, "magenta": {255, 0, 255},
+	"green": {0, 128, 0}, "lime": {0, 255, 0}, "olive": {128, 128, 0}, "yellow": {255, 255, 0},
+	"navy": {0, 0, 128}, "blue": {0, 0, 255}, "teal": {0, 128, 128}, "aqua": {0, 255, 255},
+	"cyan": {0, 255, 255}, "orange": {255, 165, 0}, "brown": {165, 42, 42},
+	"pink": {255, 192, 203}, "gold": {255, 215, 0}, "darkgray": {169, 169, 169},
+	"darkgrey": {169, 169, 169}, "lightgray": {211, 211, 211}, "lightgrey": {211, 211, 211},
+	"darkblue": {0, 0, 139}, "darkred": {139, 0, 0}, "darkgreen": {0, 100, 0},
+	"lightblue": {173, 216, 230}, "lightgreen": {144, 238, 144}, "lightyellow": {255, 255, 224},
+	"whitesmoke": {245, 245, 245}, "indigo": {75, 0, 130}, "violet": {238, 130, 238},
+}
+
+// parseColor parses the CSS color `value`: a named color, #rgb, #rrggbb or rgb(r, g, b). The
+// color is nil for "transparent".
+func parseColor(value string) (creator.Color, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "transparent" {
+		return nil, true
+	}
+	if rgb, ok := namedColors[value]; ok {
+		return creator.ColorRGBFrom8bit(rgb[0], rgb[1], rgb[2]), true
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) == 8 {
+			hex = hex[:6]
+		}
+		if len(hex) != 6 {
+			return nil, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return nil, false
+		}
+		return creator.ColorRGBFrom8bit(byte(v>>16), byte(v>>8), byte(v)), true
+	}
+
+	for _, fn := range []string{"rgba(", "rgb("} {
+		if !strings.HasPrefix(value, fn) || !strings.HasSuffix(value, ")") {
+			continue
+		}
+		args := strings.FieldsFunc(value[len(fn):len(value)-1], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '/'
+		})
+		if len(args) < 3 {
+			return nil, false
+		}
+		var rgb [3]byte
+		for i := 0; i < 3; i++ {
+			arg := args[i]
+			scale := 1.0
+			if strings.HasSuffix(arg, "%") {
+				arg = strings.TrimSuffix(arg, "%")
+				scale = 2.55
+			}
+			f, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, false
+			}
+			rgb[i] = byte(math.Max(0, math.Min(255, math.Round(f*scale))))
+		}
+		return creator.ColorRGBFrom8bit(rgb[0], rgb[1], rgb[2]), true
+	}
+	return nil, false
+}
@@ -0,0 +1,125 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/creator"
+)
+
+func TestParseDeclarations(t *testing.T) {
+	decls := parseDeclarations("color: red; /* comment */ margin:0 1em ;bad; FONT-WEIGHT: bold !important;")
+	require.Equal(t, []declaration{
+		{property: "color", value: "red"},
+		{property: "margin", value: "0 1em"},
+		{property: "font-weight", value: "bold", important: true},
+	}, decls)
+}
+
+func TestStyleSheetMatching(t *testing.T) {
+	root := parse(`<div id="main" class="box"><p class="note big">a <b>b</b></p></div><p>c</p>`)
+	div := root.children[0]
+	p := div.children[0]
+	b := p.children[1]
+	other := root.children[1]
+
+	rules := parseStyleSheet(`
+		@media print { p { color: red } }
+		p.note, #main > p { color: blue }
+		div b { font-weight: bold }
+		a:hover { color: red }
+	`)
+	require.Len(t, rules, 3)
+	require.Equal(t, 11, rules[0].specificity)
+	require.Equal(t, 101, rules[1].specificity)
+	require.Equal(t, 2, rules[2].specificity)
+
+	require.True(t, rules[0].matches(p))
+	require.False(t, rules[0].matches(other))
+	require.True(t, rules[1].matches(p))
+	require.False(t, rules[1].matches(b))
+	require.True(t, rules[2].matches(b))
+	require.False(t, rules[2].matches(p))
+}
+
+func TestParseValues(t *testing.T) {
+	lengths := []struct {
+		value    string
+		expected float64
+	}{
+		{"12pt", 12}, {"16px", 12}, {"16", 12}, {"1.5em", 15}, {"2rem", 24}, {"1in", 72},
+		{"25.4mm", 72}, {"50%", 50},
+	}
+	for _, tc := range lengths {
+		l, ok := parseLength(tc.value, 10, 12, 100)
+		require.True(t, ok, tc.value)
+		require.InDelta(t, tc.expected, l, 1e-9, tc.value)
+	}
+	_, ok := parseLength("auto", 10, 12, 100)
+	require.False(t, ok)
+
+	b, ok := parseBoxLengths("1pt 2pt 3pt", 10, 12, 0)
+	require.True(t, ok)
+	require.Equal(t, [4]float64{1, 2, 3, 2}, b)
+
+	colors := []struct {
+		value string
+		rgb   [3]float64
+	}{
+		{"red", [3]float64{1, 0, 0}},
+		{"#00f", [3]float64{0, 0, 1}},
+		{"#FFFF00", [3]float64{1, 1, 0}},
+		{"rgb(0, 255, 0)", [3]float64{0, 1, 0}},
+		{"rgba(100%, 0%, 100%, 0.5)", [3]float64{1, 0, 1}},
+	}
+	for _, tc := range colors {
+		c, ok := parseColor(tc.value)
+		require.True(t, ok, tc.value)
+		r, g, b := c.ToRGB()
+		require.Equal(t, tc.rgb, [3]float64{r, g, b}, tc.value)
+	}
+	c, ok := parseColor("transparent")
+	require.True(t, ok)
+	require.Nil(t, c)
+	_, ok = parseColor("#12345")
+	require.False(t, ok)
+}
+
+func TestComputeStyle(t *testing.T) {
+	root := parse(`<div style="font-size: 20pt; color: #ff0000">` +
+		`<p class="x" style="margin: 1em 0; text-align: justify">a <u><sup>b</sup></u></p></div>`)
+	div := root.children[0]
+	p := div.children[0]
+	u := p.children[1]
+	sup := u.children[0]
+
+	ua := parseStyleSheet(userAgentStyleSheet)
+	rules := parseStyleSheet(`.x { font-size: 0.5em !important; color: blue } p { text-align: right }`)
+	rootStyle := &style{fontFamily: "sans-serif", fontSize: 10, color: creator.ColorBlack, lineHeight: 1.2}
+
+	divStyle := computeStyle(div, rootStyle, ua, rules, 10)
+	require.Equal(t, 20.0, divStyle.fontSize)
+
+	// Important declarations override the style attribute, the style attribute overrides the
+	// style sheets.
+	pStyle := computeStyle(p, divStyle, ua, rules, 10)
+	require.Equal(t, 10.0, pStyle.fontSize)
+	require.Equal(t, box{top: 10, bottom: 10}, pStyle.margin)
+	require.Equal(t, creator.TextAlignmentJustify, pStyle.textAlign)
+	r, g, b := pStyle.color.ToRGB()
+	require.Equal(t, [3]float64{0, 0, 1}, [3]float64{r, g, b})
+
+	// Text decorations and rises propagate to the inline descendants.
+	uStyle := computeStyle(u, pStyle, ua, rules, 10)
+	require.True(t, uStyle.underline)
+	supStyle := computeStyle(sup, uStyle, ua, rules, 10)
+	require.True(t, supStyle.underline)
+	require.Less(t, supStyle.fontSize, uStyle.fontSize)
+	require.Greater(t, supStyle.rise, 0.0)
+}
@@ -0,0 +1,40 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package html converts documents written in a subset of HTML and CSS to the drawables of a
+// creator: styled paragraphs, lists, tables, images and page breaks, which are laid out and
+// paginated by the creator like any other drawable.
+//
+// The parser is lenient: omitted end tags (p, li, td, tr, ...) are inferred, unknown elements
+// are displayed as their content, and the script elements are ignored. The supported elements
+// are the headings, p, div, blockquote, pre, hr, br, ul, ol and li, table with its thead, tbody,
+// tfoot, tr, td and th sections, img (data URLs and files), a (external links), and the inline
+// text elements (span, b, strong, i, em, u, s, sup, sub, code, font, ...).
+//
+// The styles are cascaded from a user agent style sheet, the Converter style sheet, the style
+// elements of the document and the style attributes, with type, class, id, descendant and child
+// selectors. The supported properties are display, font-family, font-size, font-weight,
+// font-style, color, background(-color), text-align, line-height, letter-spacing, word-spacing,
+// text-decoration, vertical-align, white-space, list-style(-type), margin, padding, border,
+// border-width, border-color, width, height, and page-break-before/after. The lengths can be
+// given in pt, px, em, rem, %, in, cm and mm.
+//
+// Font families are resolved by a FontRegistry, which registers the standard 14 fonts by
+// default; other TrueType fonts can be registered as families.
+//
+// Limitations: the table cells cannot span rows, the borders are uniform on all sides and drawn
+// around table cells and boxes, images are laid out as blocks, and the vertical margins only
+// collapse between adjacent blocks.
+//
+// Example:
+//
+//	c := creator.New()
+//	conv := html.NewConverter(c)
+//	conv.StyleSheet = "h1 { color: #336699 }"
+//	if err := conv.Draw(strings.NewReader(src)); err != nil {
+//		return err
+//	}
+//	return c.WriteToFile("output.pdf")
+package html
@@ -0,0 +1,129 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"strings"
+
+	"github.com/carmel/unipdf/model"
+)
+
+// FontFamily is a family of fonts, in the four styles of text. The styles not set are displayed
+// with the regular font.
+type FontFamily struct {
+	Regular    *model.PdfFont
+	Bold       *model.PdfFont
+	Italic     *model.PdfFont
+	BoldItalic *model.PdfFont
+}
+
+// font returns the font of `f` displaying bold and/or italic text, falling back to the closest
+// style available.
+func (f *FontFamily) font(bold, italic bool) *model.PdfFont {
+	candidates := []*model.PdfFont{f.Regular}
+	switch {
+	case bold && italic:
+		candidates = []*model.PdfFont{f.BoldItalic, f.Bold, f.Italic, f.Regular}
+	case bold:
+		candidates = []*model.PdfFont{f.Bold, f.Regular}
+	case italic:
+		candidates = []*model.PdfFont{f.Italic, f.Regular}
+	}
+	for _, font := range candidates {
+		if font != nil {
+			return font
+		}
+	}
+	return nil
+}
+
+// NewFontFamilyFromTTFFiles returns the family of the TrueType fonts of the files `regular`,
+// `bold`, `italic` and `boldItalic`, embedded as composite fonts supporting all their glyphs. The
+// files of the styles not available can be empty.
+func NewFontFamilyFromTTFFiles(regular, bold, italic, boldItalic string) (*FontFamily, error) {
+	var fonts [4]*model.PdfFont
+	for i, path := range []string{regular, bold, italic, boldItalic} {
+		if path == "" {
+			continue
+		}
+		font, err := model.NewCompositePdfFontFromTTFFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fonts[i] = font
+	}
+	return &FontFamily{Regular: fonts[0], Bold: fonts[1], Italic: fonts[2], BoldItalic: fonts[3]}, nil
+}
+
+// FontRegistry resolves the font-family property of CSS to fonts. The family names are case
+// insensitive.
+type FontRegistry struct {
+	families map[string]*FontFamily
+
+	// Default is the name of the family of the text which families are not registered.
+	Default string
+}
+
+// NewFontRegistry returns a registry of the standard 14 fonts: the "Helvetica" family, also named
+// "sans-serif" and "Arial", the "Times" family, also named "serif" and "Times New Roman", and the
+// "Courier" family, also named "monospace" and "Courier New". The default family is
+// "sans-serif".
+func NewFontRegistry() *FontRegistry {
+	r := &FontRegistry{families: map[string]*FontFamily{}, Default: "sans-serif"}
+	std := []struct {
+		names                             []string
+		regular, bold, italic, boldItalic model.StdFontName
+	}{
+		{[]string{"helvetica", "sans-serif", "arial"},
+			model.HelveticaName, model.HelveticaBoldName, model.HelveticaObliqueName,
+			model.HelveticaBoldObliqueName},
+		{[]string{"times", "serif", "times new roman"},
+			model.TimesRomanName, model.TimesBoldName, model.TimesItalicName,
+			model.TimesBoldItalicName},
+		{[]string{"courier", "monospace", "courier new"},
+			model.CourierName, model.CourierBoldName, model.CourierObliqueName,
+			model.CourierBoldObliqueName},
+	}
+	for _, s := range std {
+		var fonts [4]*model.PdfFont
+		for i, name := range []model.StdFontName{s.regular, s.bold, s.italic, s.boldItalic} {
+			fonts[i], _ = model.NewStandard14Font(name)
+		}
+		family := &FontFamily{Regular: fonts[0], Bold: fonts[1], Italic: fonts[2], BoldItalic: fonts[3]}
+		for _, name := range s.names {
+			r.families[name] = family
+		}
+	}
+	return r
+}
+
+// Register registers `family` under the name `name`, replacing any family of the same name.
+func (r *FontRegistry) Register(name string, family *FontFamily) {
+	r.families[strings.ToLower(strings.TrimSpace(name))] = family
+}
+
+// Family returns the family registered under the name `name`, nil if not registered.
+func (r *FontRegistry) Family(name string) *FontFamily {
+	return r.families[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// Font returns the font of the first registered family of the CSS font-family list `families`,
+// e.g. `"Open Sans", Arial, sans-serif`, displaying bold and/or italic text. The default family
+// is used if none of the families is registered.
+func (r *FontRegistry) Font(families string, bold, italic bool) *model.PdfFont {
+	for _, name := range strings.Split(families, ",") {
+		name = strings.Trim(strings.TrimSpace(name), `"'`)
+		if family := r.Family(name); family != nil {
+			if font := family.font(bold, italic); font != nil {
+				return font
+			}
+		}
+	}
+	if family := r.Family(r.Default); family != nil {
+		return family.font(bold, italic)
+	}
+	return nil
+}
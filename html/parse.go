@@ -0,0 +1,246 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	stdhtml "html"
+	"strings"
+	"unicode"
+)
+
+// node is a node of a parsed HTML document: an element, or a text node if its tag is empty.
+type node struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	parent   *node
+	children []*node
+}
+
+// attr returns the value of the attribute `name` of `n`, "" if not set.
+func (n *node) attr(name string) string {
+	return n.attrs[name]
+}
+
+// voidElements are the elements without content nor end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// rawTextElements are the elements which content is text up to their end tag.
+var rawTextElements = map[string]bool{
+	"script": true, "style": true, "title": true, "textarea": true,
+}
+
+// autoClosed lists, for the elements which end tag can be omitted, the start tags closing them.
+var autoClosed = map[string][]string{
+	"p": {"address", "article", "aside", "blockquote", "div", "dl", "fieldset", "footer", "form",
+		"h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "ol", "p", "pre", "section", "table",
+		"ul"},
+	"li":    {"li"},
+	"dt":    {"dt", "dd"},
+	"dd":    {"dt", "dd"},
+	"td":    {"td", "th", "tr", "tbody", "thead", "tfoot"},
+	"th":    {"td", "th", "tr", "tbody", "thead", "tfoot"},
+	"tr":    {"tr", "tbody", "thead", "tfoot"},
+	"thead": {"tbody", "tfoot"},
+	"tbody": {"tbody", "tfoot"},
+}
+
+// scopeElements are the elements delimiting the elements closed implicitly by a start tag, e.g.
+// a list item nested in a list does not close the list item containing the list.
+var scopeElements = map[string]bool{
+	"ul": true, "ol": true, "table": true, "td": true, "th": true, "div": true, "blockquote": true,
+	"body": true, "html": true,
+}
+
+// parse parses the HTML document `src` leniently: unknown elements are kept, the omitted end tags
+// are inferred and stray end tags ignored. The returned node is the root of the document.
+func parse(src string) *node {
+	root := &node{tag: "#document"}
+	open := []*node{root}
+	current := func() *node { return open[len(open)-1] }
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		parent := current()
+		if k := len(parent.children); k > 0 && parent.children[k-1].tag == "" {
+			parent.children[k-1].text += text
+			return
+		}
+		parent.children = append(parent.children, &node{text: text, parent: parent})
+	}
+	// closeTo closes the open elements up to the last open element `tag`, if any.
+	closeTo := func(tag string) {
+		for i := len(open) - 1; i > 0; i-- {
+			if open[i].tag == tag {
+				open = open[:i]
+				return
+			}
+		}
+	}
+
+	for pos := 0; pos < len(src); {
+		lt := strings.IndexByte(src[pos:], '<')
+		if lt < 0 {
+			appendText(stdhtml.UnescapeString(src[pos:]))
+			break
+		}
+		appendText(stdhtml.UnescapeString(src[pos : pos+lt]))
+		pos += lt
+
+		rest := src[pos:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			pos += 4 + end + 3
+			continue
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			// Doctype and processing instructions.
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			pos += end + 1
+			continue
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			closeTo(strings.ToLower(strings.TrimSpace(rest[2:end])))
+			pos += end + 1
+			continue
+		}
+
+		tag, attrs, selfClosing, n := parseStartTag(rest)
+		if n == 0 {
+			// Not a tag: a literal '<'.
+			appendText("<")
+			pos++
+			continue
+		}
+		pos += n
+
+		// Start tags close the elements which end tags are omitted.
+	closing:
+		for i := len(open) - 1; i > 0; i-- {
+			el := open[i]
+			for _, t := range autoClosed[el.tag] {
+				if t == tag {
+					open = open[:i]
+					continue closing
+				}
+			}
+			if scopeElements[el.tag] {
+				break
+			}
+		}
+
+		parent := current()
+		el := &node{tag: tag, attrs: attrs, parent: parent}
+		parent.children = append(parent.children, el)
+		if voidElements[tag] || selfClosing {
+			continue
+		}
+		if rawTextElements[tag] {
+			end := strings.Index(strings.ToLower(src[pos:]), "</"+tag)
+			if end < 0 {
+				end = len(src) - pos
+			}
+			text := src[pos : pos+end]
+			if tag == "title" || tag == "textarea" {
+				text = stdhtml.UnescapeString(text)
+			}
+			el.children = []*node{{text: text, parent: el}}
+			pos += end
+			continue
+		}
+		open = append(open, el)
+	}
+	return root
+}
+
+// parseStartTag parses the start tag at the start of `src`, returning its lowercase name, its
+// attributes, whether it is self-closing and its length, 0 if `src` does not start with a tag.
+func parseStartTag(src string) (string, map[string]string, bool, int) {
+	i := 1
+	for i < len(src) && (isNameChar(rune(src[i]))) {
+		i++
+	}
+	if i == 1 || !unicode.IsLetter(rune(src[1])) {
+		return "", nil, false, 0
+	}
+	tag := strings.ToLower(src[1:i])
+	attrs := map[string]string{}
+
+	skipSpace := func() {
+		for i < len(src) && isSpace(src[i]) {
+			i++
+		}
+	}
+	for {
+		skipSpace()
+		if i >= len(src) {
+			return tag, attrs, false, len(src)
+		}
+		switch {
+		case src[i] == '>':
+			return tag, attrs, false, i + 1
+		case strings.HasPrefix(src[i:], "/>"):
+			return tag, attrs, true, i + 2
+		case src[i] == '/':
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(src) && !isSpace(src[i]) && src[i] != '=' && src[i] != '>' && src[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(src[start:i])
+		skipSpace()
+		if i >= len(src) || src[i] != '=' {
+			attrs[name] = ""
+			continue
+		}
+		i++
+		skipSpace()
+
+		var value string
+		if i < len(src) && (src[i] == '"' || src[i] == '\'') {
+			quote := src[i]
+			end := strings.IndexByte(src[i+1:], quote)
+			if end < 0 {
+				end = len(src) - i - 1
+			}
+			value = src[i+1 : i+1+end]
+			i += end + 2
+		} else {
+			start := i
+			for i < len(src) && !isSpace(src[i]) && src[i] != '>' {
+				i++
+			}
+			value = src[start:i]
+		}
+		attrs[name] = stdhtml.UnescapeString(value)
+	}
+}
+
+// isNameChar returns true if `r` can be part of a tag name.
+func isNameChar(r rune) bool {
+	return r == '-' || r == ':' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// isSpace returns true if `c` is HTML whitespace.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
@@ -0,0 +1,67 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// outline returns the tree of `n` as a string: the elements with their children in parentheses
+// and the text nodes quoted.
+func outline(n *node) string {
+	var parts []string
+	for _, child := range n.children {
+		if child.tag == "" {
+			parts = append(parts, `"`+child.text+`"`)
+			continue
+		}
+		part := child.tag
+		if len(child.children) > 0 {
+			part += "(" + outline(child) + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParse(t *testing.T) {
+	testcases := []struct {
+		src      string
+		expected string
+	}{
+		{`<p>a <b>bold</b> text</p>`, `p("a " b("bold") " text")`},
+		// Omitted end tags are inferred.
+		{`<p>one<p>two<div>three</div>`, `p("one") p("two") div("three")`},
+		{`<ul><li>a<li>b<ul><li>c</ul></ul>`, `ul(li("a") li("b" ul(li("c"))))`},
+		{`<table><tr><td>a<td>b<tr><td>c</table>`, `table(tr(td("a") td("b")) tr(td("c")))`},
+		// Void elements, comments, doctypes and stray end tags.
+		{`<!DOCTYPE html>a<br>b<!-- <p>c</p> --><img src=x.png/></span>`, `"a" br "b" img`},
+		// Character references are decoded, and the content of raw text elements kept as is.
+		{`<p>&lt;a&gt; &amp; &eacute;&#233;</p>`, `p("<a> & éé")`},
+		{`<style>p > b { color: red }</style>`, `style("p > b { color: red }")`},
+		{`a < b`, `"a < b"`},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, outline(parse(tc.src)), tc.src)
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	root := parse(`<td colspan=2 class='a b' STYLE="color: red" nowrap data-x="&quot;q&quot;">`)
+	require.Len(t, root.children, 1)
+	td := root.children[0]
+	require.Equal(t, "td", td.tag)
+	require.Equal(t, map[string]string{
+		"colspan": "2",
+		"class":   "a b",
+		"style":   "color: red",
+		"nowrap":  "",
+		"data-x":  `"q"`,
+	}, td.attrs)
+}
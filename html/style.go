@@ -0,0 +1,432 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package html
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/carmel/unipdf/creator"
+)
+
+// userAgentStyleSheet is the default style sheet of the elements.
+const userAgentStyleSheet = `
+head, script, style, title, meta, link { display: none }
+h1 { font-size: 2em; font-weight: bold; margin: 0.67em 0 }
+h2 { font-size: 1.5em; font-weight: bold; margin: 0.83em 0 }
+h3 { font-size: 1.17em; font-weight: bold; margin: 1em 0 }
+h4 { font-weight: bold; margin: 1.33em 0 }
+h5 { font-size: 0.83em; font-weight: bold; margin: 1.67em 0 }
+h6 { font-size: 0.67em; font-weight: bold; margin: 2.33em 0 }
+p, dl { margin: 1em 0 }
+ul, ol { margin: 1em 0; padding-left: 10pt }
+ul { list-style-type: disc }
+ol { list-style-type: decimal }
+li ul, li ol { margin: 0 }
+dd { margin-left: 30pt }
+blockquote { margin: 1em 30pt }
+pre { font-family: monospace; white-space: pre; margin: 1em 0 }
+code, kbd, samp, tt { font-family: monospace }
+b, strong, th, dt { font-weight: bold }
+i, em, cite, var, dfn { font-style: italic }
+u, ins { text-decoration: underline }
+s, strike, del { text-decoration: line-through }
+sup { vertical-align: super; font-size: smaller }
+sub { vertical-align: sub; font-size: smaller }
+small { font-size: smaller }
+big { font-size: larger }
+center, th { text-align: center }
+td, th { padding: 2pt }
+`
+
+// blockElements are the elements displayed as blocks, the other elements being displayed inline.
+var blockElements = map[string]bool{
+	"html": true, "body": true, "div": true, "p": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "ul": true, "ol": true, "li": true, "dl": true, "dt": true,
+	"dd": true, "table": true, "blockquote": true, "pre": true, "section": true, "article": true,
+	"header": true, "footer": true, "nav": true, "aside": true, "main": true, "address": true,
+	"figure": true, "figcaption": true, "form": true, "center": true, "hr": true,
+}
+
+// box is the lengths of the sides of a box, in points.
+type box struct {
+	top, right, bottom, left float64
+}
+
+// style is the computed style of an element.
+type style struct {
+	// The inherited properties.
+	fontFamily    string
+	fontSize      float64
+	bold          bool
+	italic        bool
+	color         creator.Color
+	textAlign     creator.TextAlignment
+	lineHeight    float64
+	letterSpacing float64
+	wordSpacing   float64
+	listStyle     string
+	pre           bool
+	// The text decorations propagate to the descendants.
+	underline   bool
+	lineThrough bool
+	overline    bool
+	// rise is the text rise of the text relative to the baseline of its block, in points.
+	rise float64
+	// textBackground is the background of the text of the inline elements.
+	textBackground creator.Color
+
+	// The properties of the element only.
+	display         string
+	background      creator.Color
+	margin          box
+	padding         box
+	borderWidth     float64
+	borderColor     creator.Color
+	width           string
+	height          string
+	verticalAlign   string
+	pageBreakBefore bool
+	pageBreakAfter  bool
+}
+
+// inherit returns the style of a child of an element of style `s`, before applying its own
+// declarations.
+func (s *style) inherit() *style {
+	child := *s
+	child.display = ""
+	child.background = nil
+	child.margin = box{}
+	child.padding = box{}
+	child.borderWidth = 0
+	child.borderColor = nil
+	child.width = ""
+	child.height = ""
+	child.verticalAlign = ""
+	child.pageBreakBefore = false
+	child.pageBreakAfter = false
+	return &child
+}
+
+// isBlock returns true if the element `n` of style `s` is displayed as a block.
+func (s *style) isBlock(n *node) bool {
+	switch s.display {
+	case "block", "list-item", "table":
+		return true
+	case "inline", "inline-block":
+		return false
+	}
+	return blockElements[n.tag]
+}
+
+// textStyle returns the style of the text of `s`, based on `base`, the font resolved by `fonts`.
+func (s *style) textStyle(base creator.TextStyle, fonts *FontRegistry) creator.TextStyle {
+	ts := base
+	if font := fonts.Font(s.fontFamily, s.bold, s.italic); font != nil {
+		ts.Font = font
+	}
+	ts.FontSize = s.fontSize
+	ts.Color = s.color
+	ts.CharSpacing = s.letterSpacing
+	ts.WordSpacing = s.wordSpacing
+	ts.Underline = s.underline
+	ts.StrikeThrough = s.lineThrough
+	ts.Overline = s.overline
+	ts.TextRise = s.rise
+	ts.BackgroundColor = s.textBackground
+	return ts
+}
+
+// weightedDeclaration is a declaration applying to an element, with its precedence in the
+// cascade.
+type weightedDeclaration struct {
+	declaration
+	weight int
+}
+
+// The precedences of the origins of the declarations, increasing. The declarations of style sheets
+// are ranked by the specificity of their selectors, and important declarations take precedence
+// over the others.
+const (
+	weightUserAgent  = 0
+	weightAttribute  = 1 << 16
+	weightStyleSheet = 2 << 16
+	weightInline     = 3 << 16
+	weightImportant  = 4 << 16
+)
+
+// computeStyle returns the style of the element `n`, child of an element of style `parent`,
+// styled by the user agent rules `ua`, the author rules `rules` and its attributes. `rootSize`
+// is the font size of the root element.
+func computeStyle(n *node, parent *style, ua, rules []*rule, rootSize float64) *style {
+	s := parent.inherit()
+
+	var decls []weightedDeclaration
+	for _, r := range ua {
+		if r.matches(n) {
+			for _, d := range r.decls {
+				decls = append(decls, weightedDeclaration{d, weightUserAgent + r.specificity})
+			}
+		}
+	}
+	for _, d := range attributeDeclarations(n) {
+		decls = append(decls, weightedDeclaration{d, weightAttribute})
+	}
+	for _, r := range rules {
+		if r.matches(n) {
+			for _, d := range r.decls {
+				weight := weightStyleSheet + r.specificity
+				if d.important {
+					weight += weightImportant
+				}
+				decls = append(decls, weightedDeclaration{d, weight})
+			}
+		}
+	}
+	for _, d := range parseDeclarations(n.attr("style")) {
+		weight := weightInline
+		if d.important {
+			weight += weightImportant
+		}
+		decls = append(decls, weightedDeclaration{d, weight})
+	}
+	sort.SliceStable(decls, func(i, j int) bool { return decls[i].weight < decls[j].weight })
+
+	// The font size is computed first, the other lengths being relative to it.
+	for _, d := range decls {
+		if d.property == "font-size" {
+			s.fontSize = parseFontSize(d.value, parent.fontSize, rootSize)
+		}
+	}
+	for _, d := range decls {
+		s.apply(d.declaration, rootSize)
+	}
+
+	switch s.verticalAlign {
+	case "super":
+		s.rise += parent.fontSize * 0.35
+	case "sub":
+		s.rise -= parent.fontSize * 0.2
+	}
+	// The background of inline elements extends to their inline descendants.
+	if s.isBlock(n) {
+		s.rise = 0
+		s.textBackground = nil
+	} else if s.background != nil {
+		s.textBackground = s.background
+	}
+	return s
+}
+
+// attributeDeclarations returns the declarations equivalent to the presentational attributes of
+// the element `n`, e.g. align or bgcolor.
+func attributeDeclarations(n *node) []declaration {
+	var decls []declaration
+	add := func(property, value string) {
+		if value != "" {
+			decls = append(decls, declaration{property: property, value: value})
+		}
+	}
+	add("text-align", n.attr("align"))
+	add("vertical-align", n.attr("valign"))
+	add("background-color", n.attr("bgcolor"))
+	add("width", n.attr("width"))
+	add("height", n.attr("height"))
+	if n.tag == "font" {
+		add("color", n.attr("color"))
+		add("font-family", n.attr("face"))
+	}
+	return decls
+}
+
+// fontSizeKeywords are the scales of the absolute font size keywords, relative to the font size
+// of the root element.
+var fontSizeKeywords = map[string]float64{
+	"xx-small": 0.6, "x-small": 0.75, "small": 0.89, "medium": 1, "large": 1.2, "x-large": 1.5,
+	"xx-large": 2, "xxx-large": 3,
+}
+
+// parseFontSize returns the font size of the value `value` of the font-size property, in points,
+// of an element which parent has the font size `parentSize`.
+func parseFontSize(value string, parentSize, rootSize float64) float64 {
+	value = strings.ToLower(value)
+	if scale, ok := fontSizeKeywords[value]; ok {
+		return rootSize * scale
+	}
+	switch value {
+	case "smaller":
+		return parentSize / 1.2
+	case "larger":
+		return parentSize * 1.2
+	}
+	if size, ok := parseLength(value, parentSize, rootSize, parentSize); ok && size > 0 {
+		return size
+	}
+	return parentSize
+}
+
+// apply applies the declaration `d` to `s`, `rootSize` being the font size of the root element.
+func (s *style) apply(d declaration, rootSize float64) {
+	value := strings.ToLower(d.value)
+	length := func(v string) (float64, bool) {
+		return parseLength(v, s.fontSize, rootSize, 0)
+	}
+
+	switch d.property {
+	case "display":
+		s.display = value
+	case "font-family":
+		s.fontFamily = d.value
+	case "font-weight":
+		switch value {
+		case "bold", "bolder":
+			s.bold = true
+		case "normal", "lighter":
+			s.bold = false
+		default:
+			if w, err := strconv.Atoi(value); err == nil {
+				s.bold = w >= 600
+			}
+		}
+	case "font-style":
+		s.italic = value == "italic" || value == "oblique"
+	case "color":
+		if c, ok := parseColor(value); ok && c != nil {
+			s.color = c
+		}
+	case "background-color", "background":
+		for _, field := range strings.Fields(value) {
+			if c, ok := parseColor(field); ok {
+				s.background = c
+			}
+		}
+	case "text-align":
+		switch value {
+		case "left", "start":
+			s.textAlign = creator.TextAlignmentLeft
+		case "right", "end":
+			s.textAlign = creator.TextAlignmentRight
+		case "center", "middle":
+			s.textAlign = creator.TextAlignmentCenter
+		case "justify":
+			s.textAlign = creator.TextAlignmentJustify
+		}
+	case "line-height":
+		if value == "normal" {
+			s.lineHeight = 1.2
+		} else if f, err := strconv.ParseFloat(value, 64); err == nil {
+			s.lineHeight = f
+		} else if l, ok := parseLength(value, s.fontSize, rootSize, s.fontSize); ok && s.fontSize > 0 {
+			s.lineHeight = l / s.fontSize
+		}
+	case "letter-spacing":
+		if l, ok := length(value); ok {
+			s.letterSpacing = l
+		} else if value == "normal" {
+			s.letterSpacing = 0
+		}
+	case "word-spacing":
+		if l, ok := length(value); ok {
+			s.wordSpacing = l
+		} else if value == "normal" {
+			s.wordSpacing = 0
+		}
+	case "text-decoration", "text-decoration-line":
+		if value == "none" {
+			s.underline, s.lineThrough, s.overline = false, false, false
+		}
+		for _, field := range strings.Fields(value) {
+			switch field {
+			case "underline":
+				s.underline = true
+			case "line-through":
+				s.lineThrough = true
+			case "overline":
+				s.overline = true
+			}
+		}
+	case "vertical-align":
+		s.verticalAlign = value
+		if l, ok := parseLength(value, s.fontSize, rootSize, s.fontSize*s.lineHeight); ok {
+			s.rise += l
+		}
+	case "white-space":
+		s.pre = strings.HasPrefix(value, "pre") || value == "break-spaces"
+	case "list-style-type", "list-style":
+		if fields := strings.Fields(value); len(fields) > 0 {
+			s.listStyle = fields[0]
+		}
+	case "margin", "padding":
+		b, ok := parseBoxLengths(value, s.fontSize, rootSize, 0)
+		if !ok {
+			return
+		}
+		sides := box{top: b[0], right: b[1], bottom: b[2], left: b[3]}
+		if d.property == "margin" {
+			s.margin = sides
+		} else {
+			s.padding = sides
+		}
+	case "margin-top", "margin-right", "margin-bottom", "margin-left",
+		"padding-top", "padding-right", "padding-bottom", "padding-left":
+		l, ok := length(value)
+		if !ok {
+			return
+		}
+		sides := &s.margin
+		if strings.HasPrefix(d.property, "padding") {
+			sides = &s.padding
+		}
+		switch d.property[strings.IndexByte(d.property, '-')+1:] {
+		case "top":
+			sides.top = l
+		case "right":
+			sides.right = l
+		case "bottom":
+			sides.bottom = l
+		case "left":
+			sides.left = l
+		}
+	case "border":
+		s.borderWidth = 0.75
+		for _, field := range strings.Fields(value) {
+			if c, ok := parseColor(field); ok {
+				s.borderColor = c
+			} else if l, ok := length(field); ok {
+				s.borderWidth = l
+			} else if field == "none" || field == "hidden" {
+				s.borderWidth = 0
+			}
+		}
+	case "border-width":
+		if l, ok := length(value); ok {
+			s.borderWidth = l
+		}
+	case "border-color":
+		if c, ok := parseColor(value); ok {
+			s.borderColor = c
+		}
+	case "width":
+		s.width = value
+	case "height":
+		s.height = value
+	case "page-break-before", "break-before":
+		s.pageBreakBefore = isPageBreak(value)
+	case "page-break-after", "break-after":
+		s.pageBreakAfter = isPageBreak(value)
+	}
+}
+
+// isPageBreak returns true if the value of a page-break-before/after or break-before/after
+// property forces a page break.
+func isPageBreak(value string) bool {
+	switch value {
+	case "always", "page", "left", "right", "recto", "verso":
+		return true
+	}
+	return false
+}
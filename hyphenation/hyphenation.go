@@ -0,0 +1,206 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package hyphenation finds the positions words can be hyphenated at with Liang's algorithm, the
+// hyphenation algorithm of TeX, from language specific patterns.
+//
+// Patterns are loaded from data files listing the patterns, as in the hyph-utf8 project files
+// (hyph-*.pat.txt), or TeX files with \patterns and \hyphenation blocks. The patterns of US
+// English, German and French are bundled:
+//
+//	patterns, err := hyphenation.Load("en-us")
+//	if err != nil {
+//		return err
+//	}
+//	positions := patterns.Hyphenate("hyphenation") // [2 6]: hy-phen-ation.
+package hyphenation
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Patterns are the hyphenation patterns and exceptions of a language.
+type Patterns struct {
+	// LeftMin and RightMin are the minimum numbers of characters of a word before and after a
+	// hyphen (2 and 3 by default).
+	LeftMin  int
+	RightMin int
+
+	// patterns maps the letters of the patterns to their values: the value at index i is that of
+	// the position before the letter i.
+	patterns map[string][]uint8
+	// maxLength is the number of letters of the longest pattern.
+	maxLength int
+	// exceptions maps the words hyphenated explicitly to their hyphen positions.
+	exceptions map[string][]int
+}
+
+// New returns empty patterns, to which patterns and exceptions are added with AddPattern and
+// AddException.
+func New() *Patterns {
+	return &Patterns{
+		LeftMin:    2,
+		RightMin:   3,
+		patterns:   map[string][]uint8{},
+		exceptions: map[string][]int{},
+	}
+}
+
+// Parse reads the patterns and exceptions of `r`. The patterns are separated by whitespace, text
+// following a `%` being a comment. The patterns of TeX \patterns{...} blocks and the hyphenated
+// words of \hyphenation{...} blocks, e.g. "ta-ble", are also read.
+func Parse(r io.Reader) (*Patterns, error) {
+	p := New()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	inExceptions := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '%'); i >= 0 {
+			line = line[:i]
+		}
+		for _, field := range strings.Fields(line) {
+			switch {
+			case strings.HasPrefix(field, `\hyphenation{`):
+				inExceptions = true
+				field = strings.TrimPrefix(field, `\hyphenation{`)
+			case strings.HasPrefix(field, `\patterns{`):
+				inExceptions = false
+				field = strings.TrimPrefix(field, `\patterns{`)
+			case strings.HasPrefix(field, `\`):
+				// Other TeX commands, e.g. \message, are ignored.
+				continue
+			}
+			closed := strings.HasSuffix(field, "}")
+			field = strings.TrimSuffix(field, "}")
+
+			var err error
+			if field != "" {
+				if inExceptions {
+					p.AddException(field)
+				} else {
+					err = p.AddPattern(field)
+				}
+			}
+			if err != nil {
+				return nil, err
+			}
+			if closed {
+				inExceptions = false
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// ParseFile reads the patterns and exceptions of the file `path` (see Parse).
+func ParseFile(path string) (*Patterns, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// AddPattern adds the pattern `pattern`: letters with the values of the positions between them,
+// e.g. "1na" or "hen5at", a dot marking the start or end of a word. The values can have several
+// digits, e.g. "a10c11", as used by pattern files to store exceptions overriding the patterns.
+func (p *Patterns) AddPattern(pattern string) error {
+	var letters []rune
+	values := []uint8{0}
+	for _, r := range pattern {
+		if r >= '0' && r <= '9' {
+			last := len(values) - 1
+			values[last] = values[last]*10 + uint8(r-'0')
+			continue
+		}
+		letters = append(letters, unicode.ToLower(r))
+		values = append(values, 0)
+	}
+	if len(letters) == 0 {
+		return errors.New("hyphenation pattern without letters")
+	}
+
+	p.patterns[string(letters)] = values
+	if len(letters) > p.maxLength {
+		p.maxLength = len(letters)
+	}
+	return nil
+}
+
+// AddException adds the hyphenation of a word overriding the patterns, e.g. "as-so-ciate".
+func (p *Patterns) AddException(hyphenated string) {
+	var word []rune
+	var positions []int
+	for _, r := range hyphenated {
+		if r == '-' {
+			positions = append(positions, len(word))
+			continue
+		}
+		word = append(word, unicode.ToLower(r))
+	}
+	p.exceptions[string(word)] = positions
+}
+
+// Hyphenate returns the positions `word` can be hyphenated at, in increasing order: the indices
+// of the runes of `word` a hyphen can be inserted before. The case of the letters is ignored.
+func (p *Patterns) Hyphenate(word string) []int {
+	runes := []rune(strings.ToLower(word))
+	n := len(runes)
+	if n < p.LeftMin+p.RightMin {
+		return nil
+	}
+
+	if positions, ok := p.exceptions[string(runes)]; ok {
+		var allowed []int
+		for _, pos := range positions {
+			if pos >= p.LeftMin && pos <= n-p.RightMin {
+				allowed = append(allowed, pos)
+			}
+		}
+		return allowed
+	}
+
+	// The values of the positions of the word delimited by dots: values[i] is the value of the
+	// position before the rune i of the delimited word, the rune i-1 of `word`.
+	delimited := make([]rune, 0, n+2)
+	delimited = append(delimited, '.')
+	delimited = append(delimited, runes...)
+	delimited = append(delimited, '.')
+	values := make([]uint8, len(delimited)+1)
+	for start := range delimited {
+		for end := start + 1; end <= len(delimited) && end-start <= p.maxLength; end++ {
+			pattern, ok := p.patterns[string(delimited[start:end])]
+			if !ok {
+				continue
+			}
+			for i, v := range pattern {
+				if v > values[start+i] {
+					values[start+i] = v
+				}
+			}
+		}
+	}
+
+	// Odd values allow hyphens.
+	var positions []int
+	for i := p.LeftMin; i <= n-p.RightMin; i++ {
+		if values[i+1]%2 == 1 {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,87 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package hyphenation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hyphenated returns `word` with hyphens at the positions `p` hyphenates it at.
+func hyphenated(p *Patterns, word string) string {
+	runes := []rune(word)
+	var b strings.Builder
+	positions := p.Hyphenate(word)
+	for i, r := range runes {
+		if len(positions) > 0 && positions[0] == i {
+			b.WriteRune('-')
+			positions = positions[1:]
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func TestParse(t *testing.T) {
+	// The patterns hyphenating "hyphenation" in The TeXbook, appendix H.
+	src := `% Comment.
+\patterns{ hy3ph he2n hena4 hen5at
+1na n2at 1tio 2io o2n }
+\hyphenation{ ta-ble }`
+	p, err := Parse(strings.NewReader(src))
+	require.NoError(t, err)
+
+	require.Equal(t, []int{2, 6}, p.Hyphenate("hyphenation"))
+	require.Equal(t, "Hy-phen-ation", hyphenated(p, "Hyphenation"))
+	require.Equal(t, "ta-ble", hyphenated(p, "table"))
+
+	// Hyphens are not placed before LeftMin or after RightMin characters.
+	p.LeftMin, p.RightMin = 3, 5
+	require.Equal(t, "hyphen-ation", hyphenated(p, "hyphenation"))
+	require.Empty(t, p.Hyphenate("table"))
+
+	// Values of several digits.
+	p, err = Parse(strings.NewReader(`\patterns{ 1t .t10a11b10l10e. }`))
+	require.NoError(t, err)
+	require.Equal(t, "ta-ble", hyphenated(p, "table"))
+
+	_, err = Parse(strings.NewReader("12"))
+	require.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	testcases := []struct {
+		lang  string
+		words []string
+	}{
+		{"en-us", []string{"hy-phen-a-tion", "al-go-rithm", "com-puter", "pro-gram-ming"}},
+		// Exceptions of the pattern file, stored as patterns of two digits values.
+		{"en", []string{"acad-e-mies", "acad-emy", "acro-nym"}},
+		{"EN", []string{"hy-phen-a-tion"}},
+		{"de", []string{"Sil-ben-tren-nung", "Wör-ter-buch"}},
+		{"fr", []string{"dic-tion-naire", "té-lé-phone"}},
+	}
+	for _, tc := range testcases {
+		p, err := Load(tc.lang)
+		require.NoError(t, err, tc.lang)
+		for _, word := range tc.words {
+			require.Equal(t, word, hyphenated(p, strings.ReplaceAll(word, "-", "")), tc.lang)
+		}
+	}
+
+	// Loaded patterns are shared.
+	en, err := Load("en")
+	require.NoError(t, err)
+	enUS, err := Load("en_US")
+	require.NoError(t, err)
+	require.Same(t, en, enUS)
+
+	_, err = Load("xx")
+	require.Error(t, err)
+	require.Equal(t, []string{"de", "de-1996", "en", "en-us", "fr"}, Languages())
+}
@@ -0,0 +1,80 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package hyphenation
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed patterns/*.pat.txt
+var patternFiles embed.FS
+
+// language is a language which patterns are bundled.
+type language struct {
+	// file is the name of the pattern file of the language.
+	file string
+	// leftMin and rightMin are the minimum numbers of characters before and after a hyphen.
+	leftMin, rightMin int
+}
+
+// languages are the bundled languages, by tag.
+var languages = map[string]language{
+	"en":      {"hyph-en-us.pat.txt", 2, 3},
+	"en-us":   {"hyph-en-us.pat.txt", 2, 3},
+	"de":      {"hyph-de-1996.pat.txt", 2, 2},
+	"de-1996": {"hyph-de-1996.pat.txt", 2, 2},
+	"fr":      {"hyph-fr.pat.txt", 2, 2},
+}
+
+var (
+	loadedMu sync.Mutex
+	loaded   = map[string]*Patterns{}
+)
+
+// Languages returns the tags of the languages which patterns are bundled: "en" and "en-us" (US
+// English), "de" and "de-1996" (German, reformed spelling) and "fr" (French), sorted.
+func Languages() []string {
+	tags := make([]string, 0, len(languages))
+	for tag := range languages {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Load returns the bundled patterns of the language `lang`, one of Languages (case insensitive,
+// "_" separators accepted). The patterns are parsed once and shared: they must not be modified.
+func Load(lang string) (*Patterns, error) {
+	tag := strings.ReplaceAll(strings.ToLower(lang), "_", "-")
+	l, ok := languages[tag]
+	if !ok {
+		return nil, fmt.Errorf("no hyphenation patterns for language %q", lang)
+	}
+
+	loadedMu.Lock()
+	defer loadedMu.Unlock()
+	if p, ok := loaded[l.file]; ok {
+		return p, nil
+	}
+
+	f, err := patternFiles.Open("patterns/" + l.file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p, err := Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	p.LeftMin, p.RightMin = l.leftMin, l.rightMin
+	loaded[l.file] = p
+	return p, nil
+}
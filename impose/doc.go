@@ -0,0 +1,33 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package impose places existing pages on new sheets for printing: N-up grids, saddle-stitch
+// booklets and poster tiling.
+//
+// A layout function (NUp, Booklet or Tile) arranges the pages on sheets, each page being placed
+// in a cell of a sheet. The placements can be adjusted (rotation, scale, position) before the
+// sheets are rendered to new pages with Render, which draws each source page as a Form XObject
+// clipped to its cell, with optional crop marks and bleed.
+//
+// Example:
+//
+//	pages := impose.ReaderPages(reader)
+//	opts := impose.DefaultOptions()
+//	opts.CropMarks = true
+//	sheets, err := impose.NUp(pages, 2, 2, opts)
+//	if err != nil {
+//		return err
+//	}
+//	sheetPages, err := impose.Render(sheets, opts)
+//	if err != nil {
+//		return err
+//	}
+//	w := model.NewPdfWriter()
+//	for _, page := range sheetPages {
+//		if err := w.AddPage(page); err != nil {
+//			return err
+//		}
+//	}
+package impose
@@ -0,0 +1,342 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package impose
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// Options specifies the sheets of a layout and how they are rendered. All lengths are in points.
+type Options struct {
+	// SheetWidth and SheetHeight are the dimensions of the sheets.
+	SheetWidth  float64
+	SheetHeight float64
+
+	// Margin is the margin of the sheets, where no page is placed.
+	Margin float64
+
+	// Gutter is the space between the cells of the sheets (NUp and Booklet).
+	Gutter float64
+
+	// Rotate is the clockwise rotation of the pages in degrees, a multiple of 90. The rotation
+	// is added to the Rotate attribute of the pages.
+	Rotate int
+
+	// AutoRotate rotates the pages by 90 degrees if they fit their cells better (NUp and
+	// Booklet).
+	AutoRotate bool
+
+	// Scale is the scale of the pages, 0 to fit the pages in their cells. For Tile, it is the
+	// enlargement of the pages (1 if 0).
+	Scale float64
+
+	// Overlap is the width of the area repeated on adjacent tiles (Tile).
+	Overlap float64
+
+	// Bleed is the width of the content of the pages drawn outside of their crop box, when
+	// available in their media box.
+	Bleed float64
+
+	// CropMarks draws crop marks at the corners of the pages.
+	CropMarks bool
+
+	// MarkLength is the length of the crop marks, MarkOffset the distance between the crop marks
+	// and the corners of the pages (at least Bleed), and MarkLineWidth the width of the crop
+	// marks.
+	MarkLength    float64
+	MarkOffset    float64
+	MarkLineWidth float64
+}
+
+// DefaultOptions returns the default options: A4 portrait sheets, with half inch margins and
+// a quarter inch gutter.
+func DefaultOptions() *Options {
+	return &Options{
+		SheetWidth:    595.2756,
+		SheetHeight:   841.8898,
+		Margin:        36,
+		Gutter:        18,
+		MarkLength:    12,
+		MarkOffset:    3,
+		MarkLineWidth: 0.25,
+	}
+}
+
+// Sheet is a side of a sheet, on which pages are placed.
+type Sheet struct {
+	Width      float64
+	Height     float64
+	Placements []*Placement
+}
+
+// Placement places a page in a cell of a sheet.
+type Placement struct {
+	// Page is the page placed, nil for a blank cell.
+	Page *model.PdfPage
+
+	// Cell is the area of the sheet where the page is placed. The page is clipped to the cell.
+	Cell model.PdfRectangle
+
+	// Rotate is the clockwise rotation of the page in degrees, a multiple of 90, added to the
+	// Rotate attribute of the page.
+	Rotate int
+
+	// Scale is the scale of the page, 0 to fit the page in the cell.
+	Scale float64
+
+	// OffsetX and OffsetY are the displacement of the center of the page from the center of
+	// the cell.
+	OffsetX float64
+	OffsetY float64
+}
+
+// ReaderPages returns the pages of the `readers`, in order.
+func ReaderPages(readers ...*model.PdfReader) []*model.PdfPage {
+	var pages []*model.PdfPage
+	for _, r := range readers {
+		pages = append(pages, r.PageList...)
+	}
+	return pages
+}
+
+// pageGeometry is the geometry of a page placed on a sheet: its box as displayed, with the lower
+// left corner at the origin (the space of the Form XObject of the page), and the rotation added
+// by the placement.
+type pageGeometry struct {
+	box    model.PdfRectangle
+	rotate int
+}
+
+// getPageGeometry returns the geometry of `page` rotated by `rotate` degrees.
+func getPageGeometry(page *model.PdfPage, rotate int) (*pageGeometry, error) {
+	w, h, err := page.GetDisplaySize()
+	if err != nil {
+		return nil, err
+	}
+	if rotate%90 != 0 {
+		return nil, fmt.Errorf("rotation %d is not a multiple of 90", rotate)
+	}
+	return &pageGeometry{
+		box:    model.PdfRectangle{Urx: w, Ury: h},
+		rotate: (rotate%360 + 360) % 360,
+	}, nil
+}
+
+// size returns the dimensions of the page as placed, before scaling.
+func (g *pageGeometry) size() (float64, float64) {
+	if g.rotate == 90 || g.rotate == 270 {
+		return g.box.Height(), g.box.Width()
+	}
+	return g.box.Width(), g.box.Height()
+}
+
+// fitScale returns the scale fitting the page in a cell of dimensions `width` x `height`.
+func (g *pageGeometry) fitScale(width, height float64) float64 {
+	w, h := g.size()
+	if w <= 0 || h <= 0 {
+		return 1
+	}
+	return math.Min(width/w, height/h)
+}
+
+// matrix returns the transformation from the form space to the sheet space placing the page with
+// scale `scale` and its lower left corner at (`x`, `y`).
+func (g *pageGeometry) matrix(scale, x, y float64) (a, b, c, d, e, f float64) {
+	w, h := g.box.Width(), g.box.Height()
+	switch g.rotate {
+	case 90:
+		a, b, c, d, e, f = 0, -1, 1, 0, 0, w
+	case 180:
+		a, b, c, d, e, f = -1, 0, 0, -1, w, h
+	case 270:
+		a, b, c, d, e, f = 0, 1, -1, 0, h, 0
+	default:
+		a, b, c, d, e, f = 1, 0, 0, 1, 0, 0
+	}
+	return a * scale, b * scale, c * scale, d * scale, e*scale + x, f*scale + y
+}
+
+// bestRotation returns `rotate`, or `rotate` + 90 if the page fits a cell of dimensions
+// `width` x `height` better when rotated by 90 more degrees.
+func bestRotation(page *model.PdfPage, rotate int, width, height float64) (int, error) {
+	g, err := getPageGeometry(page, rotate)
+	if err != nil {
+		return 0, err
+	}
+	rotated, err := getPageGeometry(page, rotate+90)
+	if err != nil {
+		return 0, err
+	}
+	if rotated.fitScale(width, height) > g.fitScale(width, height) {
+		return rotate + 90, nil
+	}
+	return rotate, nil
+}
+
+// Render renders the `sheets` to new pages. The pages placed on the sheets are converted to Form
+// XObjects, shared by the sheets where a page is placed several times.
+func Render(sheets []*Sheet, opts *Options) ([]*model.PdfPage, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	r := &renderer{opts: opts, forms: map[*model.PdfPage]*model.XObjectForm{}}
+	var pages []*model.PdfPage
+	for i, sheet := range sheets {
+		page, err := r.renderSheet(sheet)
+		if err != nil {
+			return nil, fmt.Errorf("sheet %d: %v", i+1, err)
+		}
+		pages = append(pages, page)
+	}
+	return pages, nil
+}
+
+// renderer renders sheets.
+type renderer struct {
+	opts  *Options
+	forms map[*model.PdfPage]*model.XObjectForm
+}
+
+// renderSheet renders `sheet` to a new page.
+func (r *renderer) renderSheet(sheet *Sheet) (*model.PdfPage, error) {
+	if sheet.Width <= 0 || sheet.Height <= 0 {
+		return nil, errors.New("invalid sheet dimensions")
+	}
+	page := model.NewPdfPage()
+	page.MediaBox = &model.PdfRectangle{Urx: sheet.Width, Ury: sheet.Height}
+
+	cc := contentstream.NewContentCreator()
+	var marks []model.PdfRectangle
+	for i, pl := range sheet.Placements {
+		if pl.Page == nil {
+			continue
+		}
+		g, err := getPageGeometry(pl.Page, pl.Rotate)
+		if err != nil {
+			return nil, err
+		}
+		form, err := r.pageForm(pl.Page)
+		if err != nil {
+			return nil, err
+		}
+		name := core.PdfObjectName(fmt.Sprintf("Pg%d", i))
+		if err := page.Resources.SetXObjectFormByName(name, form); err != nil {
+			return nil, err
+		}
+
+		// Page placed in the cell.
+		cell := pl.Cell
+		scale := pl.Scale
+		if scale <= 0 {
+			scale = g.fitScale(cell.Width(), cell.Height())
+		}
+		w, h := g.size()
+		w, h = w*scale, h*scale
+		x := (cell.Llx+cell.Urx)/2 - w/2 + pl.OffsetX
+		y := (cell.Lly+cell.Ury)/2 - h/2 + pl.OffsetY
+		placed := model.PdfRectangle{Llx: x, Lly: y, Urx: x + w, Ury: y + h}
+
+		// Clipped to the cell and the visible box of the page, extended by the bleed.
+		bleed := r.opts.Bleed
+		clip := intersect(expand(placed, bleed), expand(cell, bleed))
+		visible := intersect(placed, cell)
+		if isEmpty(clip) {
+			continue
+		}
+		cc.Add_q().
+			Add_re(clip.Llx, clip.Lly, clip.Width(), clip.Height()).
+			Add_W().
+			Add_n().
+			Add_cm(g.matrix(scale, x, y)).
+			Add_Do(name).
+			Add_Q()
+		if !isEmpty(visible) {
+			marks = append(marks, visible)
+		}
+	}
+	if r.opts.CropMarks && len(marks) > 0 {
+		r.drawCropMarks(cc, marks)
+	}
+
+	if err := page.SetContentStreams([]string{cc.String()}, core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// drawCropMarks draws crop marks at the corners of the rectangles `rects`.
+func (r *renderer) drawCropMarks(cc *contentstream.ContentCreator, rects []model.PdfRectangle) {
+	offset := math.Max(r.opts.MarkOffset, r.opts.Bleed)
+	length := r.opts.MarkLength
+	lineWidth := r.opts.MarkLineWidth
+	if lineWidth <= 0 {
+		lineWidth = 0.25
+	}
+	cc.Add_q().Add_w(lineWidth).Add_G(0)
+	for _, rect := range rects {
+		for _, corner := range [][2]float64{
+			{rect.Llx, rect.Lly}, {rect.Urx, rect.Lly}, {rect.Llx, rect.Ury}, {rect.Urx, rect.Ury},
+		} {
+			// Horizontal and vertical marks pointing away from the page.
+			dx, dy := -1.0, -1.0
+			if corner[0] == rect.Urx {
+				dx = 1
+			}
+			if corner[1] == rect.Ury {
+				dy = 1
+			}
+			cc.Add_m(corner[0]+dx*offset, corner[1]).Add_l(corner[0]+dx*(offset+length), corner[1])
+			cc.Add_m(corner[0], corner[1]+dy*offset).Add_l(corner[0], corner[1]+dy*(offset+length))
+		}
+	}
+	cc.Add_S().Add_Q()
+}
+
+// pageForm returns the Form XObject drawing `page`.
+func (r *renderer) pageForm(page *model.PdfPage) (*model.XObjectForm, error) {
+	if form, ok := r.forms[page]; ok {
+		return form, nil
+	}
+	form, err := page.ToXObjectForm()
+	if err != nil {
+		return nil, err
+	}
+	// The content outside of the crop box is kept for the bleed, the page being clipped when
+	// placed.
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	form.BBox = mediaBox.ToPdfObject()
+	r.forms[page] = form
+	return form, nil
+}
+
+// expand returns `rect` extended by `d` on each side.
+func expand(rect model.PdfRectangle, d float64) model.PdfRectangle {
+	return model.PdfRectangle{Llx: rect.Llx - d, Lly: rect.Lly - d, Urx: rect.Urx + d, Ury: rect.Ury + d}
+}
+
+// isEmpty returns true if `rect` has no area.
+func isEmpty(rect model.PdfRectangle) bool {
+	return rect.Urx <= rect.Llx || rect.Ury <= rect.Lly
+}
+
+// intersect returns the intersection of `a` and `b`, which is empty if they do not intersect.
+func intersect(a, b model.PdfRectangle) model.PdfRectangle {
+	return model.PdfRectangle{
+		Llx: math.Max(a.Llx, b.Llx),
+		Lly: math.Max(a.Lly, b.Lly),
+		Urx: math.Min(a.Urx, b.Urx),
+		Ury: math.Min(a.Ury, b.Ury),
+	}
+}
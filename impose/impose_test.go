@@ -0,0 +1,190 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package impose
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+// newTestPages returns `n` pages of dimensions `width` x `height` showing their page number.
+func newTestPages(t *testing.T, n int, width, height float64) []*model.PdfPage {
+	helvetica, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	font := helvetica.ToPdfObject()
+
+	var pages []*model.PdfPage
+	for i := 1; i <= n; i++ {
+		page := model.NewPdfPage()
+		page.MediaBox = &model.PdfRectangle{Urx: width, Ury: height}
+		require.NoError(t, page.AddFont("F1", font))
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (Page %d) Tj ET", i)
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewFlateEncoder()))
+		pages = append(pages, page)
+	}
+	return pages
+}
+
+// writeSheets renders and writes the `sheets` and returns a reader of the output.
+func writeSheets(t *testing.T, sheets []*Sheet, opts *Options) *model.PdfReader {
+	pages, err := Render(sheets, opts)
+	require.NoError(t, err)
+	w := model.NewPdfWriter()
+	for _, page := range pages {
+		require.NoError(t, w.AddPage(page))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader
+}
+
+func TestNUp(t *testing.T) {
+	pages := newTestPages(t, 5, 200, 300)
+	opts := DefaultOptions()
+	opts.CropMarks = true
+	sheets, err := NUp(pages, 2, 2, opts)
+	require.NoError(t, err)
+	require.Len(t, sheets, 2)
+	require.Len(t, sheets[0].Placements, 4)
+	require.Len(t, sheets[1].Placements, 1)
+
+	cellWidth := (opts.SheetWidth - 2*opts.Margin - opts.Gutter) / 2
+	top := sheets[0].Placements[1].Cell
+	require.InDelta(t, opts.Margin+cellWidth+opts.Gutter, top.Llx, 1e-9)
+	require.InDelta(t, opts.SheetHeight-opts.Margin, top.Ury, 1e-9)
+	bottom := sheets[0].Placements[2].Cell
+	require.InDelta(t, opts.Margin, bottom.Lly, 1e-9)
+
+	reader := writeSheets(t, sheets, opts)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	ex, err := extractor.New(page)
+	require.NoError(t, err)
+	text, err := ex.ExtractText()
+	require.NoError(t, err)
+	for i := 1; i <= 4; i++ {
+		require.Contains(t, text, fmt.Sprintf("Page %d", i))
+	}
+	require.NotContains(t, text, "Page 5")
+}
+
+func TestBooklet(t *testing.T) {
+	pages := newTestPages(t, 5, 200, 300)
+	opts := DefaultOptions()
+	opts.SheetWidth, opts.SheetHeight = opts.SheetHeight, opts.SheetWidth
+	sheets, err := Booklet(pages, opts)
+	require.NoError(t, err)
+	require.Len(t, sheets, 4)
+
+	order := func(sheet *Sheet) []int {
+		var nums []int
+		for _, pl := range sheet.Placements {
+			num := 0
+			for i, page := range pages {
+				if pl.Page == page {
+					num = i + 1
+				}
+			}
+			nums = append(nums, num)
+		}
+		return nums
+	}
+	// 8 pages, the last 3 are blank.
+	require.Equal(t, []int{0, 1}, order(sheets[0]))
+	require.Equal(t, []int{2, 0}, order(sheets[1]))
+	require.Equal(t, []int{0, 3}, order(sheets[2]))
+	require.Equal(t, []int{4, 5}, order(sheets[3]))
+
+	reader := writeSheets(t, sheets, opts)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 4, numPages)
+}
+
+func TestTile(t *testing.T) {
+	pages := newTestPages(t, 1, 200, 200)
+	opts := &Options{
+		SheetWidth:  300,
+		SheetHeight: 300,
+		Scale:       2,
+		Overlap:     20,
+		CropMarks:   true,
+		MarkLength:  10,
+	}
+	sheets, err := Tile(pages, opts)
+	require.NoError(t, err)
+	require.Len(t, sheets, 4)
+
+	// The enlarged page is 400x400, the tiles show 300x300 with steps of 280.
+	for i, sheet := range sheets {
+		row, col := i/2, i%2
+		pl := sheet.Placements[0]
+		require.Equal(t, 2.0, pl.Scale)
+		x := 150 + pl.OffsetX - 200
+		y := 150 + pl.OffsetY - 200
+		require.InDelta(t, -280*float64(col), x, 1e-9)
+		require.InDelta(t, 300+280*float64(row)-400, y, 1e-9)
+	}
+
+	rendered, err := Render(sheets, opts)
+	require.NoError(t, err)
+	content, err := rendered[0].GetAllContentStreams()
+	require.NoError(t, err)
+	require.True(t, strings.Contains(content, "re\nW\nn\n"))
+	require.True(t, strings.Contains(content, "/Pg0 Do"))
+	// Crop marks.
+	require.True(t, strings.Contains(content, "0.25 w"))
+}
+
+func TestPageGeometry(t *testing.T) {
+	page := newTestPages(t, 1, 200, 300)[0]
+	page.CropBox = &model.PdfRectangle{Llx: 10, Lly: 20, Urx: 110, Ury: 320}
+	rotate := int64(90)
+	page.Rotate = &rotate
+
+	// The page is displayed in landscape, clipped to the media box.
+	g, err := getPageGeometry(page, 0)
+	require.NoError(t, err)
+	require.Equal(t, model.PdfRectangle{Urx: 280, Ury: 100}, g.box)
+
+	for _, rotate := range []int{0, 90, 180, 270} {
+		g, err := getPageGeometry(page, rotate)
+		require.NoError(t, err)
+		w, h := g.size()
+		a, b, c, d, e, f := g.matrix(0.5, 50, 60)
+		for _, corner := range [][2]float64{{0, 0}, {280, 0}, {0, 100}, {280, 100}} {
+			x := a*corner[0] + c*corner[1] + e
+			y := b*corner[0] + d*corner[1] + f
+			require.True(t, x > 50-1e-9 && x < 50+w/2+1e-9, "rotate %d: x=%g", rotate, x)
+			require.True(t, y > 60-1e-9 && y < 60+h/2+1e-9, "rotate %d: y=%g", rotate, y)
+		}
+	}
+
+	// The top of the page is on the right when rotated by 90 degrees clockwise.
+	g, err = getPageGeometry(page, 90)
+	require.NoError(t, err)
+	a, b, c, d, e, f := g.matrix(1, 0, 0)
+	x, y := a*140+c*100+e, b*140+d*100+f
+	require.InDelta(t, 100, x, 1e-9)
+	require.InDelta(t, 140, y, 1e-9)
+
+	_, err = getPageGeometry(page, 45)
+	require.Error(t, err)
+
+	best, err := bestRotation(page, 0, 100, 300)
+	require.NoError(t, err)
+	require.Equal(t, 90, best)
+}
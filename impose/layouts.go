@@ -0,0 +1,203 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package impose
+
+import (
+	"errors"
+	"math"
+
+	"github.com/carmel/unipdf/model"
+)
+
+// NUp places the `pages` on sheets in grids of `cols` columns and `rows` rows, filled from left
+// to right and from top to bottom.
+func NUp(pages []*model.PdfPage, cols, rows int, opts *Options) ([]*Sheet, error) {
+	if cols < 1 || rows < 1 {
+		return nil, errors.New("invalid grid dimensions")
+	}
+	opts, err := checkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := gridCells(opts, cols, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var sheets []*Sheet
+	for start := 0; start < len(pages); start += len(cells) {
+		sheet := &Sheet{Width: opts.SheetWidth, Height: opts.SheetHeight}
+		for i, cell := range cells {
+			if start+i >= len(pages) {
+				break
+			}
+			pl, err := newPlacement(pages[start+i], cell, opts)
+			if err != nil {
+				return nil, err
+			}
+			sheet.Placements = append(sheet.Placements, pl)
+		}
+		sheets = append(sheets, sheet)
+	}
+	return sheets, nil
+}
+
+// Booklet places the `pages` on sheets for a saddle-stitched booklet: two pages side by side on
+// each side of a sheet, in the order in which the folded sheets are read. The sheets usually are
+// in landscape orientation. The returned sheets are the front and back sides of each sheet, in
+// order. Blank pages are added at the end of the booklet to make the number of pages a multiple
+// of four.
+func Booklet(pages []*model.PdfPage, opts *Options) ([]*Sheet, error) {
+	opts, err := checkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := gridCells(opts, 2, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	n := (len(pages) + 3) / 4 * 4
+	page := func(i int) *model.PdfPage {
+		if i < len(pages) {
+			return pages[i]
+		}
+		return nil
+	}
+	var sheets []*Sheet
+	for s := 0; s < n/4; s++ {
+		// The outer sheet holds the first two and the last two pages.
+		for _, side := range [][2]int{
+			{n - 1 - 2*s, 2 * s},
+			{2*s + 1, n - 2 - 2*s},
+		} {
+			sheet := &Sheet{Width: opts.SheetWidth, Height: opts.SheetHeight}
+			for i, idx := range side {
+				pl, err := newPlacement(page(idx), cells[i], opts)
+				if err != nil {
+					return nil, err
+				}
+				sheet.Placements = append(sheet.Placements, pl)
+			}
+			sheets = append(sheets, sheet)
+		}
+	}
+	return sheets, nil
+}
+
+// Tile enlarges each of the `pages` by the scale of `opts` and splits it into tiles printed on
+// several sheets, which are assembled as a poster. The tiles are ordered from left to right and
+// from top to bottom, and adjacent tiles share an overlapping area of the width specified by
+// `opts`.
+func Tile(pages []*model.PdfPage, opts *Options) ([]*Sheet, error) {
+	opts, err := checkOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	cell := model.PdfRectangle{
+		Llx: opts.Margin,
+		Lly: opts.Margin,
+		Urx: opts.SheetWidth - opts.Margin,
+		Ury: opts.SheetHeight - opts.Margin,
+	}
+	stepX := cell.Width() - opts.Overlap
+	stepY := cell.Height() - opts.Overlap
+	if opts.Overlap < 0 || stepX <= 0 || stepY <= 0 {
+		return nil, errors.New("invalid tile overlap")
+	}
+	centerX, centerY := (cell.Llx+cell.Urx)/2, (cell.Lly+cell.Ury)/2
+
+	var sheets []*Sheet
+	for _, page := range pages {
+		g, err := getPageGeometry(page, opts.Rotate)
+		if err != nil {
+			return nil, err
+		}
+		w, h := g.size()
+		w, h = w*scale, h*scale
+		cols := tileCount(w, opts.Overlap, stepX)
+		rows := tileCount(h, opts.Overlap, stepY)
+		for row := 0; row < rows; row++ {
+			for col := 0; col < cols; col++ {
+				// Lower left corner of the enlarged page on the sheet showing the tile.
+				x := cell.Llx - float64(col)*stepX
+				y := cell.Ury + float64(row)*stepY - h
+				sheet := &Sheet{Width: opts.SheetWidth, Height: opts.SheetHeight}
+				sheet.Placements = append(sheet.Placements, &Placement{
+					Page:    page,
+					Cell:    cell,
+					Rotate:  opts.Rotate,
+					Scale:   scale,
+					OffsetX: x + w/2 - centerX,
+					OffsetY: y + h/2 - centerY,
+				})
+				sheets = append(sheets, sheet)
+			}
+		}
+	}
+	return sheets, nil
+}
+
+// tileCount returns the number of tiles of width `step` + `overlap` covering `length`.
+func tileCount(length, overlap, step float64) int {
+	n := int(math.Ceil((length-overlap)/step - 1e-9))
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// checkOptions returns `opts` (the default options if nil) if the sheets can hold pages.
+func checkOptions(opts *Options) (*Options, error) {
+	if opts == nil {
+		opts = DefaultOptions()
+	}
+	if opts.SheetWidth-2*opts.Margin <= 0 || opts.SheetHeight-2*opts.Margin <= 0 {
+		return nil, errors.New("invalid sheet dimensions")
+	}
+	return opts, nil
+}
+
+// gridCells returns the cells of a grid of `cols` columns and `rows` rows in the area of the
+// sheets within the margins, ordered from left to right and from top to bottom.
+func gridCells(opts *Options, cols, rows int) ([]model.PdfRectangle, error) {
+	cellWidth := (opts.SheetWidth - 2*opts.Margin - float64(cols-1)*opts.Gutter) / float64(cols)
+	cellHeight := (opts.SheetHeight - 2*opts.Margin - float64(rows-1)*opts.Gutter) / float64(rows)
+	if cellWidth <= 0 || cellHeight <= 0 {
+		return nil, errors.New("sheet too small for the grid")
+	}
+
+	var cells []model.PdfRectangle
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			llx := opts.Margin + float64(col)*(cellWidth+opts.Gutter)
+			ury := opts.SheetHeight - opts.Margin - float64(row)*(cellHeight+opts.Gutter)
+			cells = append(cells, model.PdfRectangle{
+				Llx: llx,
+				Lly: ury - cellHeight,
+				Urx: llx + cellWidth,
+				Ury: ury,
+			})
+		}
+	}
+	return cells, nil
+}
+
+// newPlacement returns the placement of `page` in `cell`, rotated as specified by `opts`.
+func newPlacement(page *model.PdfPage, cell model.PdfRectangle, opts *Options) (*Placement, error) {
+	rotate := opts.Rotate
+	if page != nil && opts.AutoRotate {
+		var err error
+		if rotate, err = bestRotation(page, rotate, cell.Width(), cell.Height()); err != nil {
+			return nil, err
+		}
+	}
+	return &Placement{Page: page, Cell: cell, Rotate: rotate, Scale: opts.Scale}, nil
+}
@@ -0,0 +1,814 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package bidi implements the Unicode Bidirectional Algorithm (UAX #9) which determines the
+// display order of text mixing left-to-right and right-to-left scripts.
+//
+// The levels of a paragraph are resolved with NewParagraph. The levels of each of its lines, once
+// wrapped, are returned by LineLevels and VisualOrder returns the display order of a line.
+package bidi
+
+import (
+	xbidi "golang.org/x/text/unicode/bidi"
+)
+
+// Direction is the base direction of a paragraph.
+type Direction int
+
+const (
+	// Auto determines the direction from the first strong directional character of the
+	// paragraph, left-to-right if there is none (rules P2 and P3).
+	Auto Direction = iota
+	// LeftToRight is the left-to-right direction.
+	LeftToRight
+	// RightToLeft is the right-to-left direction.
+	RightToLeft
+)
+
+// maxDepth is the maximum explicit embedding level.
+const maxDepth = 125
+
+// class is a bidirectional character type.
+type class = xbidi.Class
+
+// Paragraph is a paragraph of text with its resolved embedding levels.
+type Paragraph struct {
+	runes        []rune
+	initialTypes []class
+	resultTypes  []class
+	levels       []int8
+	level        int8
+
+	// Matching isolate initiators and PDIs, or -1.
+	matchingPDI              []int
+	matchingIsolateInitiator []int
+}
+
+// NewParagraph resolves the embedding levels of `runes`, a paragraph of text displayed with the
+// base direction `dir`.
+func NewParagraph(runes []rune, dir Direction) *Paragraph {
+	n := len(runes)
+	p := &Paragraph{
+		runes:        runes,
+		initialTypes: make([]class, n),
+		levels:       make([]int8, n),
+	}
+	for i, r := range runes {
+		p.initialTypes[i] = runeClass(r)
+	}
+	p.resultTypes = append([]class(nil), p.initialTypes...)
+	p.determineMatchingIsolates()
+
+	switch dir {
+	case LeftToRight:
+		p.level = 0
+	case RightToLeft:
+		p.level = 1
+	default:
+		p.level = p.determineParagraphEmbeddingLevel(0, n)
+	}
+
+	p.determineExplicitEmbeddingLevels()
+	for _, seq := range p.determineIsolatingRunSequences() {
+		seq.resolveWeakTypes()
+		seq.resolvePairedBrackets()
+		seq.resolveNeutralTypes()
+		seq.resolveImplicitLevels()
+		seq.applyLevelsAndTypes()
+	}
+	p.assignLevelsToCharactersRemovedByX9()
+	return p
+}
+
+// IsRightToLeft returns true if the paragraph has a right-to-left base direction.
+func (p *Paragraph) IsRightToLeft() bool {
+	return p.level == 1
+}
+
+// Levels returns the resolved embedding levels of the runes of the paragraph, before the rules
+// applying to lines.
+func (p *Paragraph) Levels() []uint8 {
+	return p.LineLevels(0, len(p.runes))
+}
+
+// LineLevels returns the embedding levels of the runes [start, end) of the paragraph, displayed as
+// a line. Whitespace at the end of the line and before segment separators is reset to the
+// paragraph level (rule L1).
+func (p *Paragraph) LineLevels(start, end int) []uint8 {
+	levels := make([]uint8, end-start)
+	for i := range levels {
+		levels[i] = uint8(p.levels[start+i])
+	}
+
+	para := uint8(p.level)
+	for i := range levels {
+		t := p.initialTypes[start+i]
+		if t == xbidi.B || t == xbidi.S {
+			levels[i] = para
+			for j := i - 1; j >= 0 && isWhitespace(p.initialTypes[start+j]); j-- {
+				levels[j] = para
+			}
+		}
+	}
+	for j := len(levels) - 1; j >= 0 && isWhitespace(p.initialTypes[start+j]); j-- {
+		levels[j] = para
+	}
+	return levels
+}
+
+// VisualOrder returns the display order of runes of embedding levels `levels`, the indices of the
+// runes from left to right (rule L2).
+func VisualOrder(levels []uint8) []int {
+	n := len(levels)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	if n == 0 {
+		return order
+	}
+
+	var highest uint8
+	lowestOdd := uint8(maxDepth + 2)
+	for _, l := range levels {
+		if l > highest {
+			highest = l
+		}
+		if l%2 == 1 && l < lowestOdd {
+			lowestOdd = l
+		}
+	}
+
+	lv := append([]uint8(nil), levels...)
+	for level := highest; level >= lowestOdd; level-- {
+		for i := 0; i < n; {
+			if lv[i] < level {
+				i++
+				continue
+			}
+			j := i
+			for j < n && lv[j] >= level {
+				j++
+			}
+			for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+				order[a], order[b] = order[b], order[a]
+				lv[a], lv[b] = lv[b], lv[a]
+			}
+			i = j
+		}
+	}
+	return order
+}
+
+// BaseDirection returns the direction of the first strong directional character of `runes`,
+// ignoring the characters between isolate initiators and their matching PDI, or Auto if there
+// is none.
+func BaseDirection(runes []rune) Direction {
+	isolates := 0
+	for _, r := range runes {
+		switch runeClass(r) {
+		case xbidi.LRI, xbidi.RLI, xbidi.FSI:
+			isolates++
+		case xbidi.PDI:
+			if isolates > 0 {
+				isolates--
+			}
+		case xbidi.L:
+			if isolates == 0 {
+				return LeftToRight
+			}
+		case xbidi.R, xbidi.AL:
+			if isolates == 0 {
+				return RightToLeft
+			}
+		}
+	}
+	return Auto
+}
+
+// RequiresReordering returns true if the display order of `runes` can differ from their logical
+// order, i.e. if they contain right-to-left characters or explicit directional formatting
+// characters.
+func RequiresReordering(runes []rune) bool {
+	for _, r := range runes {
+		switch runeClass(r) {
+		case xbidi.R, xbidi.AL, xbidi.AN, xbidi.LRE, xbidi.RLE, xbidi.LRO, xbidi.RLO, xbidi.PDF,
+			xbidi.LRI, xbidi.RLI, xbidi.FSI, xbidi.PDI:
+			return true
+		}
+	}
+	return false
+}
+
+// runeClass returns the bidirectional character type of `r`.
+func runeClass(r rune) class {
+	props, _ := xbidi.LookupRune(r)
+	return props.Class()
+}
+
+// isRemovedByX9 returns true if the characters of type `t` are removed by rule X9.
+func isRemovedByX9(t class) bool {
+	switch t {
+	case xbidi.LRE, xbidi.RLE, xbidi.LRO, xbidi.RLO, xbidi.PDF, xbidi.BN:
+		return true
+	}
+	return false
+}
+
+// isWhitespace returns true if the characters of type `t` are reset to the paragraph level at the
+// end of lines (rule L1).
+func isWhitespace(t class) bool {
+	switch t {
+	case xbidi.WS, xbidi.LRI, xbidi.RLI, xbidi.FSI, xbidi.PDI:
+		return true
+	}
+	return isRemovedByX9(t)
+}
+
+// typeForLevel returns the strong type of the direction of embedding level `level`.
+func typeForLevel(level int8) class {
+	if level%2 == 0 {
+		return xbidi.L
+	}
+	return xbidi.R
+}
+
+// determineMatchingIsolates determines the matching PDI of the isolate initiators (BD9).
+func (p *Paragraph) determineMatchingIsolates() {
+	n := len(p.initialTypes)
+	p.matchingPDI = make([]int, n)
+	p.matchingIsolateInitiator = make([]int, n)
+	for i := range p.matchingPDI {
+		p.matchingPDI[i] = -1
+		p.matchingIsolateInitiator[i] = -1
+	}
+
+	for i, t := range p.initialTypes {
+		if t != xbidi.LRI && t != xbidi.RLI && t != xbidi.FSI {
+			continue
+		}
+		depth := 1
+		p.matchingPDI[i] = n
+		for j := i + 1; j < n; j++ {
+			switch p.initialTypes[j] {
+			case xbidi.LRI, xbidi.RLI, xbidi.FSI:
+				depth++
+			case xbidi.PDI:
+				depth--
+			}
+			if depth == 0 {
+				p.matchingPDI[i] = j
+				p.matchingIsolateInitiator[j] = i
+				break
+			}
+		}
+	}
+}
+
+// determineParagraphEmbeddingLevel returns the embedding level of the text [start, end) from its
+// first strong character (rules P2 and P3).
+func (p *Paragraph) determineParagraphEmbeddingLevel(start, end int) int8 {
+	for i := start; i < end; i++ {
+		switch p.resultTypes[i] {
+		case xbidi.L:
+			return 0
+		case xbidi.R, xbidi.AL:
+			return 1
+		case xbidi.LRI, xbidi.RLI, xbidi.FSI:
+			i = p.matchingPDI[i]
+		}
+	}
+	return 0
+}
+
+// directionalStatus is an entry of the directional status stack.
+type directionalStatus struct {
+	level    int8
+	override class
+	isolate  bool
+}
+
+// determineExplicitEmbeddingLevels resolves the explicit embedding levels (rules X1 to X8).
+func (p *Paragraph) determineExplicitEmbeddingLevels() {
+	stack := []directionalStatus{{level: p.level, override: xbidi.ON}}
+	last := func() directionalStatus { return stack[len(stack)-1] }
+	overflowIsolateCount := 0
+	overflowEmbeddingCount := 0
+	validIsolateCount := 0
+
+	for i, t := range p.resultTypes {
+		switch t {
+		case xbidi.RLE, xbidi.LRE, xbidi.RLO, xbidi.LRO, xbidi.RLI, xbidi.LRI, xbidi.FSI:
+			isIsolate := t == xbidi.RLI || t == xbidi.LRI || t == xbidi.FSI
+			isRTL := t == xbidi.RLE || t == xbidi.RLO || t == xbidi.RLI
+			if t == xbidi.FSI {
+				isRTL = p.determineParagraphEmbeddingLevel(i+1, p.matchingPDI[i]) == 1
+			}
+			if isIsolate {
+				p.levels[i] = last().level
+				if last().override != xbidi.ON {
+					p.resultTypes[i] = last().override
+				}
+			}
+
+			var level int8
+			if isRTL {
+				level = (last().level + 1) | 1
+			} else {
+				level = (last().level + 2) &^ 1
+			}
+			if level <= maxDepth && overflowIsolateCount == 0 && overflowEmbeddingCount == 0 {
+				if isIsolate {
+					validIsolateCount++
+				}
+				override := class(xbidi.ON)
+				switch t {
+				case xbidi.LRO:
+					override = xbidi.L
+				case xbidi.RLO:
+					override = xbidi.R
+				}
+				stack = append(stack, directionalStatus{level: level, override: override, isolate: isIsolate})
+				if !isIsolate {
+					p.levels[i] = level
+				}
+			} else if isIsolate {
+				overflowIsolateCount++
+			} else if overflowIsolateCount == 0 {
+				overflowEmbeddingCount++
+			}
+		case xbidi.PDI:
+			if overflowIsolateCount > 0 {
+				overflowIsolateCount--
+			} else if validIsolateCount > 0 {
+				overflowEmbeddingCount = 0
+				for !last().isolate {
+					stack = stack[:len(stack)-1]
+				}
+				stack = stack[:len(stack)-1]
+				validIsolateCount--
+			}
+			p.levels[i] = last().level
+			if last().override != xbidi.ON {
+				p.resultTypes[i] = last().override
+			}
+		case xbidi.PDF:
+			p.levels[i] = last().level
+			if overflowIsolateCount > 0 {
+				// Nothing to do.
+			} else if overflowEmbeddingCount > 0 {
+				overflowEmbeddingCount--
+			} else if !last().isolate && len(stack) >= 2 {
+				stack = stack[:len(stack)-1]
+			}
+		case xbidi.B:
+			stack = stack[:1]
+			overflowIsolateCount = 0
+			overflowEmbeddingCount = 0
+			validIsolateCount = 0
+			p.levels[i] = p.level
+		default:
+			p.levels[i] = last().level
+			if last().override != xbidi.ON {
+				p.resultTypes[i] = last().override
+			}
+		}
+	}
+}
+
+// determineLevelRuns returns the maximal runs of characters of the same level, ignoring the
+// characters removed by rule X9 (BD7).
+func (p *Paragraph) determineLevelRuns() [][]int {
+	var runs [][]int
+	var run []int
+	level := int8(-1)
+	for i, t := range p.initialTypes {
+		if isRemovedByX9(t) {
+			continue
+		}
+		if p.levels[i] != level {
+			if run != nil {
+				runs = append(runs, run)
+				run = nil
+			}
+			level = p.levels[i]
+		}
+		run = append(run, i)
+	}
+	if run != nil {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// determineIsolatingRunSequences returns the isolating run sequences of the paragraph (BD13 and
+// rule X10).
+func (p *Paragraph) determineIsolatingRunSequences() []*isolatingRunSequence {
+	levelRuns := p.determineLevelRuns()
+	runForCharacter := make([]int, len(p.initialTypes))
+	for i, run := range levelRuns {
+		for _, idx := range run {
+			runForCharacter[idx] = i
+		}
+	}
+
+	var sequences []*isolatingRunSequence
+	for _, run := range levelRuns {
+		first := run[0]
+		if p.initialTypes[first] == xbidi.PDI && p.matchingIsolateInitiator[first] != -1 {
+			// Continuation of the sequence of the isolate initiator.
+			continue
+		}
+		var indexes []int
+		for {
+			indexes = append(indexes, run...)
+			last := run[len(run)-1]
+			t := p.initialTypes[last]
+			if (t == xbidi.LRI || t == xbidi.RLI || t == xbidi.FSI) && p.matchingPDI[last] != len(p.initialTypes) {
+				run = levelRuns[runForCharacter[p.matchingPDI[last]]]
+				continue
+			}
+			break
+		}
+		sequences = append(sequences, p.newIsolatingRunSequence(indexes))
+	}
+	return sequences
+}
+
+// assignLevelsToCharactersRemovedByX9 assigns the level of the preceding character to the
+// characters removed by rule X9, so that they are displayed in place.
+func (p *Paragraph) assignLevelsToCharactersRemovedByX9() {
+	for i, t := range p.initialTypes {
+		if !isRemovedByX9(t) {
+			continue
+		}
+		p.resultTypes[i] = t
+		if i == 0 {
+			p.levels[i] = p.level
+		} else {
+			p.levels[i] = p.levels[i-1]
+		}
+	}
+}
+
+// isolatingRunSequence is a sequence of level runs resolved together (BD13).
+type isolatingRunSequence struct {
+	p        *Paragraph
+	indexes  []int
+	types    []class
+	levels   []int8
+	level    int8
+	sos, eos class
+}
+
+// newIsolatingRunSequence returns the isolating run sequence of the characters `indexes`.
+func (p *Paragraph) newIsolatingRunSequence(indexes []int) *isolatingRunSequence {
+	s := &isolatingRunSequence{
+		p:       p,
+		indexes: indexes,
+		types:   make([]class, len(indexes)),
+		levels:  make([]int8, len(indexes)),
+		level:   p.levels[indexes[0]],
+	}
+	for i, idx := range indexes {
+		s.types[i] = p.resultTypes[idx]
+	}
+
+	prevLevel := p.level
+	for i := indexes[0] - 1; i >= 0; i-- {
+		if !isRemovedByX9(p.initialTypes[i]) {
+			prevLevel = p.levels[i]
+			break
+		}
+	}
+	s.sos = typeForLevel(max8(prevLevel, s.level))
+
+	succLevel := p.level
+	last := indexes[len(indexes)-1]
+	switch p.initialTypes[last] {
+	case xbidi.LRI, xbidi.RLI, xbidi.FSI:
+	default:
+		for i := last + 1; i < len(p.initialTypes); i++ {
+			if !isRemovedByX9(p.initialTypes[i]) {
+				succLevel = p.levels[i]
+				break
+			}
+		}
+	}
+	s.eos = typeForLevel(max8(succLevel, s.level))
+	return s
+}
+
+// findRunLimit returns the end of the run of characters from `index` of the types `types`.
+func (s *isolatingRunSequence) findRunLimit(index int, types ...class) int {
+loop:
+	for ; index < len(s.types); index++ {
+		t := s.types[index]
+		for _, v := range types {
+			if t == v {
+				continue loop
+			}
+		}
+		break
+	}
+	return index
+}
+
+// resolveWeakTypes resolves the weak types (rules W1 to W7).
+func (s *isolatingRunSequence) resolveWeakTypes() {
+	types := s.types
+
+	// W1.
+	preceding := s.sos
+	for i, t := range types {
+		switch t {
+		case xbidi.NSM:
+			types[i] = preceding
+		case xbidi.LRI, xbidi.RLI, xbidi.FSI, xbidi.PDI:
+			preceding = xbidi.ON
+		default:
+			preceding = t
+		}
+	}
+
+	// W2.
+	for i, t := range types {
+		if t != xbidi.EN {
+			continue
+		}
+		for j := i - 1; j >= 0; j-- {
+			if tj := types[j]; tj == xbidi.L || tj == xbidi.R || tj == xbidi.AL {
+				if tj == xbidi.AL {
+					types[i] = xbidi.AN
+				}
+				break
+			}
+		}
+	}
+
+	// W3.
+	for i, t := range types {
+		if t == xbidi.AL {
+			types[i] = xbidi.R
+		}
+	}
+
+	// W4.
+	for i := 1; i < len(types)-1; i++ {
+		t := types[i]
+		if t != xbidi.ES && t != xbidi.CS {
+			continue
+		}
+		prev, succ := types[i-1], types[i+1]
+		if prev == xbidi.EN && succ == xbidi.EN {
+			types[i] = xbidi.EN
+		} else if t == xbidi.CS && prev == xbidi.AN && succ == xbidi.AN {
+			types[i] = xbidi.AN
+		}
+	}
+
+	// W5.
+	for i := 0; i < len(types); i++ {
+		if types[i] != xbidi.ET {
+			continue
+		}
+		start := i
+		limit := s.findRunLimit(start, xbidi.ET)
+		t := s.sos
+		if start > 0 {
+			t = types[start-1]
+		}
+		if t != xbidi.EN {
+			t = s.eos
+			if limit < len(types) {
+				t = types[limit]
+			}
+		}
+		if t == xbidi.EN {
+			for j := start; j < limit; j++ {
+				types[j] = xbidi.EN
+			}
+		}
+		i = limit
+	}
+
+	// W6.
+	for i, t := range types {
+		if t == xbidi.ES || t == xbidi.ET || t == xbidi.CS {
+			types[i] = xbidi.ON
+		}
+	}
+
+	// W7.
+	for i, t := range types {
+		if t != xbidi.EN {
+			continue
+		}
+		prevStrong := s.sos
+		for j := i - 1; j >= 0; j-- {
+			if tj := types[j]; tj == xbidi.L || tj == xbidi.R {
+				prevStrong = tj
+				break
+			}
+		}
+		if prevStrong == xbidi.L {
+			types[i] = xbidi.L
+		}
+	}
+}
+
+// maxBracketPairs is the maximum depth of the stack of opening brackets (BD16).
+const maxBracketPairs = 63
+
+// bracketPair is a pair of matching brackets, indices in the sequence.
+type bracketPair struct {
+	open, close int
+}
+
+// resolvePairedBrackets resolves the types of paired brackets (rule N0).
+func (s *isolatingRunSequence) resolvePairedBrackets() {
+	type opener struct {
+		closing rune
+		pos     int
+	}
+	var stack []opener
+	var pairs []bracketPair
+loop:
+	for i, idx := range s.indexes {
+		if s.types[i] != xbidi.ON {
+			continue
+		}
+		r := s.p.runes[idx]
+		props, _ := xbidi.LookupRune(r)
+		if !props.IsBracket() {
+			continue
+		}
+		pair, ok := Mirror(r)
+		if !ok {
+			continue
+		}
+		if props.IsOpeningBracket() {
+			if len(stack) == maxBracketPairs {
+				break loop
+			}
+			stack = append(stack, opener{closing: pair, pos: i})
+			continue
+		}
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].closing == r {
+				pairs = append(pairs, bracketPair{open: stack[j].pos, close: i})
+				stack = stack[:j]
+				break
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return
+	}
+	sortPairs(pairs)
+
+	dirEmbed := typeForLevel(s.level)
+	for _, pair := range pairs {
+		foundEmbed, foundOpposite := false, false
+		for k := pair.open + 1; k < pair.close; k++ {
+			switch strongType(s.types[k]) {
+			case dirEmbed:
+				foundEmbed = true
+			case xbidi.ON:
+			default:
+				foundOpposite = true
+			}
+		}
+
+		var resolved class
+		switch {
+		case foundEmbed:
+			resolved = dirEmbed
+		case foundOpposite:
+			prev := s.sos
+			for k := pair.open - 1; k >= 0; k-- {
+				if t := strongType(s.types[k]); t != xbidi.ON {
+					prev = t
+					break
+				}
+			}
+			resolved = dirEmbed
+			if prev != dirEmbed {
+				resolved = prev
+			}
+		default:
+			continue
+		}
+		s.setBracketType(pair.open, resolved)
+		s.setBracketType(pair.close, resolved)
+	}
+}
+
+// setBracketType sets the type of the bracket at `pos` and the following nonspacing marks to `t`.
+func (s *isolatingRunSequence) setBracketType(pos int, t class) {
+	s.types[pos] = t
+	for i := pos + 1; i < len(s.indexes); i++ {
+		if s.p.initialTypes[s.indexes[i]] != xbidi.NSM {
+			break
+		}
+		s.types[i] = t
+	}
+}
+
+// sortPairs sorts `pairs` by position of their opening bracket.
+func sortPairs(pairs []bracketPair) {
+	for i := 1; i < len(pairs); i++ {
+		for j := i; j > 0 && pairs[j].open < pairs[j-1].open; j-- {
+			pairs[j], pairs[j-1] = pairs[j-1], pairs[j]
+		}
+	}
+}
+
+// strongType returns the strong type of `t` for rule N0, numbers being treated as R.
+func strongType(t class) class {
+	switch t {
+	case xbidi.L:
+		return xbidi.L
+	case xbidi.R, xbidi.AL, xbidi.EN, xbidi.AN:
+		return xbidi.R
+	}
+	return xbidi.ON
+}
+
+// resolveNeutralTypes resolves the neutral types (rules N1 and N2).
+func (s *isolatingRunSequence) resolveNeutralTypes() {
+	types := s.types
+	neutrals := []class{xbidi.B, xbidi.S, xbidi.WS, xbidi.ON, xbidi.RLI, xbidi.LRI, xbidi.FSI, xbidi.PDI}
+	for i := 0; i < len(types); i++ {
+		switch types[i] {
+		case xbidi.WS, xbidi.ON, xbidi.B, xbidi.S, xbidi.RLI, xbidi.LRI, xbidi.FSI, xbidi.PDI:
+		default:
+			continue
+		}
+		start := i
+		limit := s.findRunLimit(start, neutrals...)
+
+		leading := s.sos
+		if start > 0 {
+			leading = numberAsR(types[start-1])
+		}
+		trailing := s.eos
+		if limit < len(types) {
+			trailing = numberAsR(types[limit])
+		}
+		resolved := typeForLevel(s.level)
+		if leading == trailing {
+			resolved = leading
+		}
+		for j := start; j < limit; j++ {
+			types[j] = resolved
+		}
+		i = limit
+	}
+}
+
+// numberAsR returns R for the number types, `t` otherwise.
+func numberAsR(t class) class {
+	if t == xbidi.AN || t == xbidi.EN {
+		return xbidi.R
+	}
+	return t
+}
+
+// resolveImplicitLevels resolves the implicit levels (rules I1 and I2).
+func (s *isolatingRunSequence) resolveImplicitLevels() {
+	for i, t := range s.types {
+		level := s.level
+		if level%2 == 0 {
+			switch t {
+			case xbidi.R:
+				level++
+			case xbidi.AN, xbidi.EN:
+				level += 2
+			}
+		} else {
+			switch t {
+			case xbidi.L, xbidi.EN, xbidi.AN:
+				level++
+			}
+		}
+		s.levels[i] = level
+	}
+}
+
+// applyLevelsAndTypes stores the resolved levels and types of the sequence in the paragraph.
+func (s *isolatingRunSequence) applyLevelsAndTypes() {
+	for i, idx := range s.indexes {
+		s.p.resultTypes[idx] = s.types[i]
+		s.p.levels[idx] = s.levels[i]
+	}
+}
+
+func max8(a, b int8) int8 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package bidi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// display returns `text` in display order, mirroring the runes at right-to-left levels.
+func display(text string, dir Direction) string {
+	runes := []rune(text)
+	levels := NewParagraph(runes, dir).Levels()
+	var out []rune
+	for _, i := range VisualOrder(levels) {
+		r := runes[i]
+		if levels[i]%2 == 1 {
+			if m, ok := Mirror(r); ok {
+				r = m
+			}
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+func TestParagraphLevels(t *testing.T) {
+	testcases := []struct {
+		text   string
+		dir    Direction
+		rtl    bool
+		levels []uint8
+	}{
+		{"abc", Auto, false, []uint8{0, 0, 0}},
+		{"אבג", Auto, true, []uint8{1, 1, 1}},
+		{"ab אב", Auto, false, []uint8{0, 0, 0, 1, 1}},
+		{"אב ab", Auto, true, []uint8{1, 1, 1, 2, 2}},
+		{"אב 12", Auto, true, []uint8{1, 1, 1, 2, 2}},
+		{"abc", RightToLeft, true, []uint8{2, 2, 2}},
+		{"123", Auto, false, []uint8{0, 0, 0}},
+		{"ab ", RightToLeft, true, []uint8{2, 2, 1}},
+	}
+	for _, tc := range testcases {
+		p := NewParagraph([]rune(tc.text), tc.dir)
+		require.Equal(t, tc.rtl, p.IsRightToLeft(), tc.text)
+		require.Equal(t, tc.levels, p.Levels(), tc.text)
+	}
+}
+
+func TestDisplayOrder(t *testing.T) {
+	testcases := []struct {
+		text     string
+		dir      Direction
+		expected string
+	}{
+		{"abc def", Auto, "abc def"},
+		{"אבג דהו", Auto, "והד גבא"},
+		{"abc אבג def", Auto, "abc גבא def"},
+		{"אבג abc דהו", Auto, "והד abc גבא"},
+		// Numbers keep their order in right-to-left text.
+		{"אבג 123", Auto, "123 גבא"},
+		{"אבג 1.5 ד", Auto, "ד 1.5 גבא"},
+		// Brackets are mirrored and paired.
+		{"א(ב)ג", Auto, "ג(ב)א"},
+		{"abc (אבג)", Auto, "abc (גבא)"},
+		{"אב (abc) גד", Auto, "דג (abc) בא"},
+		// Explicit direction.
+		{"abc", RightToLeft, "abc"},
+		{"abc אבג", RightToLeft, "גבא abc"},
+		// Explicit embeddings and isolates.
+		{"a‮bc‬d", Auto, "a‮‬cbd"},
+		{"א ⁦ab⁩ ב", Auto, "ב ⁩ab⁦ א"},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, display(tc.text, tc.dir), tc.text)
+	}
+}
+
+func TestLineLevels(t *testing.T) {
+	runes := []rune("אב \tab  ")
+	p := NewParagraph(runes, Auto)
+	levels := p.LineLevels(0, len(runes))
+	// Whitespace before the tab and at the end of the line is at paragraph level.
+	require.Equal(t, []uint8{1, 1, 1, 1, 2, 2, 1, 1}, levels)
+	require.Equal(t, []uint8{2, 2, 1, 1}, p.LineLevels(4, 8))
+}
+
+func TestVisualOrder(t *testing.T) {
+	require.Equal(t, []int{}, VisualOrder(nil))
+	require.Equal(t, []int{0, 1, 2}, VisualOrder([]uint8{0, 0, 0}))
+	require.Equal(t, []int{2, 1, 0}, VisualOrder([]uint8{1, 1, 1}))
+	require.Equal(t, []int{0, 3, 2, 1, 4}, VisualOrder([]uint8{0, 1, 1, 1, 0}))
+	require.Equal(t, []int{4, 2, 3, 1, 0}, VisualOrder([]uint8{1, 1, 2, 2, 1}))
+}
+
+func TestBaseDirection(t *testing.T) {
+	require.Equal(t, LeftToRight, BaseDirection([]rune("abc אבג")))
+	require.Equal(t, RightToLeft, BaseDirection([]rune("123 אבג abc")))
+	require.Equal(t, RightToLeft, BaseDirection([]rune("مرحبا")))
+	require.Equal(t, Auto, BaseDirection([]rune("123 !")))
+	require.Equal(t, LeftToRight, BaseDirection([]rune("⁧אבג⁩ abc")))
+}
+
+func TestRequiresReordering(t *testing.T) {
+	require.False(t, RequiresReordering([]rune("Hello, world! 123")))
+	require.True(t, RequiresReordering([]rune("Hello שלום")))
+	require.True(t, RequiresReordering([]rune("a‮b")))
+}
+
+func TestMirror(t *testing.T) {
+	for _, pair := range [][2]rune{{'(', ')'}, {'[', ']'}, {'<', '>'}, {'«', '»'}, {'≤', '≥'}} {
+		m, ok := Mirror(pair[0])
+		require.True(t, ok)
+		require.Equal(t, pair[1], m)
+		m, ok = Mirror(pair[1])
+		require.True(t, ok)
+		require.Equal(t, pair[0], m)
+	}
+	_, ok := Mirror('a')
+	require.False(t, ok)
+}
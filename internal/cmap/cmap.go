@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core"
@@ -133,7 +134,13 @@ func NewToUnicodeCMap(codeToRune map[CharCode]rune) *CMap {
 	for code, r := range codeToRune {
 		codeToUnicode[code] = string(r)
 	}
+	return NewToUnicodeCMapFromStrings(codeToUnicode)
+}
 
+// NewToUnicodeCMapFromStrings returns an identity CMap with codeToUnicode matching the
+// `codeToUnicode` arg. Unlike NewToUnicodeCMap, a character code can map to several runes, e.g.
+// the glyph of a ligature maps to the text of its components.
+func NewToUnicodeCMapFromStrings(codeToUnicode map[CharCode]string) *CMap {
 	cmap := &CMap{
 		name:  "Adobe-Identity-UCS",
 		ctype: 2,
@@ -145,9 +152,9 @@ func NewToUnicodeCMap(codeToRune map[CharCode]rune) *CMap {
 		},
 		codespaces:    []Codespace{{Low: 0, High: 0xffff}},
 		codeToUnicode: codeToUnicode,
-		unicodeToCode: make(map[string]CharCode, len(codeToRune)),
-		codeToCID:     make(map[CharCode]CharCode, len(codeToRune)),
-		cidToCode:     make(map[CharCode]CharCode, len(codeToRune)),
+		unicodeToCode: make(map[string]CharCode, len(codeToUnicode)),
+		codeToCID:     make(map[CharCode]CharCode, len(codeToUnicode)),
+		cidToCode:     make(map[CharCode]CharCode, len(codeToUnicode)),
 	}
 
 	cmap.computeInverseMappings()
@@ -508,7 +515,10 @@ func (cmap *CMap) toBfData() string {
 	prevRune := cmap.codeToUnicode[codes[0]]
 	for _, c := range codes[1:] {
 		currRune := cmap.codeToUnicode[c]
-		if c == currCharRange.code1+1 && lastRune(currRune) == lastRune(prevRune)+1 {
+		// Only single rune mappings are grouped in ranges, the text of ligatures is written as
+		// separate characters.
+		if c == currCharRange.code1+1 && isSingleRune(currRune) && isSingleRune(prevRune) &&
+			lastRune(currRune) == lastRune(prevRune)+1 {
 			currCharRange.code1 = c
 		} else {
 			charRanges = append(charRanges, currCharRange)
@@ -571,6 +581,11 @@ func lastRune(s string) rune {
 	return runes[len(runes)-1]
 }
 
+// isSingleRune returns true if `s` consists of one rune.
+func isSingleRune(s string) bool {
+	return utf8.RuneCountInString(s) == 1
+}
+
 // hexCode return the CMap hex code for `s`.
 func hexCode(s string) string {
 	runes := []rune(s)
@@ -702,3 +702,29 @@ func checkCmapWriteRead(t *testing.T, codeToUnicode map[CharCode]rune) {
 		}
 	}
 }
+
+// TestCMapCreationFromStrings checks that mappings of codes to several runes, e.g. ligatures, are
+// written and read back.
+func TestCMapCreationFromStrings(t *testing.T) {
+	codeToUnicode := map[CharCode]string{
+		0x0001: "f",
+		0x0002: "fi",
+		0x0003: "fj",
+		0x0004: "i",
+		0x0005: "j",
+		0x0010: "क्ष",
+	}
+	cmap0 := NewToUnicodeCMapFromStrings(codeToUnicode)
+	cmap, err := LoadCmapFromDataCID(cmap0.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to load CMap: %v", err)
+	}
+	if len(cmap.codeToUnicode) != len(codeToUnicode) {
+		t.Fatalf("Incorrect length. expected=%d test=%d", len(codeToUnicode), len(cmap.codeToUnicode))
+	}
+	for code, s := range codeToUnicode {
+		if u := cmap.codeToUnicode[code]; u != s {
+			t.Errorf("Unicode mismatch: code=0x%04x expected=%q test=%q", code, s, u)
+		}
+	}
+}
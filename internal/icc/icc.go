@@ -0,0 +1,205 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package icc provides minimal support for ICC color profiles, as needed for
+// PDF output intents and ICCBased color spaces: an sRGB profile generator and
+// a profile header parser.
+package icc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Header contains the fields of an ICC profile header that are relevant for PDF.
+type Header struct {
+	Size uint32
+	// Major and Minor are the profile version numbers, e.g. 2 and 1 for version 2.1.
+	Major int
+	Minor int
+	// Class is the profile/device class signature, e.g. "mntr" or "prtr".
+	Class string
+	// ColorSpace is the data color space signature, e.g. "RGB " or "CMYK".
+	ColorSpace string
+	// PCS is the profile connection space signature ("XYZ " or "Lab ").
+	PCS string
+}
+
+// NumComponents returns the number of color components of the profile color space
+// or 0 if unknown.
+func (h *Header) NumComponents() int {
+	switch h.ColorSpace {
+	case "GRAY":
+		return 1
+	case "RGB ", "Lab ", "XYZ ", "YCbr", "Luv ", "HSV ", "HLS ", "CMY ", "Yxy ":
+		return 3
+	case "CMYK":
+		return 4
+	}
+	return 0
+}
+
+// ErrInvalidProfile is returned when data does not contain a valid ICC profile.
+var ErrInvalidProfile = errors.New("invalid ICC profile")
+
+// ParseHeader parses the header of the ICC profile in `data`.
+func ParseHeader(data []byte) (*Header, error) {
+	if len(data) < 128 || string(data[36:40]) != "acsp" {
+		return nil, ErrInvalidProfile
+	}
+	h := &Header{
+		Size:       binary.BigEndian.Uint32(data[0:4]),
+		Major:      int(data[8]),
+		Minor:      int(data[9] >> 4),
+		Class:      string(data[12:16]),
+		ColorSpace: string(data[16:20]),
+		PCS:        string(data[20:24]),
+	}
+	if int(h.Size) > len(data) {
+		return nil, fmt.Errorf("%v: size %d exceeds data length %d", ErrInvalidProfile, h.Size, len(data))
+	}
+	return h, nil
+}
+
+var (
+	srgbOnce    sync.Once
+	srgbProfile []byte
+)
+
+// SRGBDescription is the description of the profile returned by SRGB.
+const SRGBDescription = "sRGB IEC61966-2.1"
+
+// SRGB returns a version 2 display profile for the sRGB IEC61966-2.1 color space.
+// The returned slice is shared and must not be modified.
+func SRGB() []byte {
+	srgbOnce.Do(func() {
+		srgbProfile = makeSRGB()
+	})
+	return srgbProfile
+}
+
+// tag is a tagged element of an ICC profile.
+type tag struct {
+	sig  string
+	data []byte
+}
+
+// makeSRGB builds the sRGB profile: D50-adapted primaries (Bradford) and a sampled
+// sRGB transfer curve shared by the three channels.
+func makeSRGB() []byte {
+	trc := makeCurve(1024, func(x float64) float64 {
+		if x <= 0.04045 {
+			return x / 12.92
+		}
+		return math.Pow((x+0.055)/1.055, 2.4)
+	})
+	tags := []tag{
+		{"desc", makeTextDescription(SRGBDescription)},
+		{"cprt", makeText("No copyright, use freely")},
+		{"wtpt", makeXYZ(0.9642, 1.0, 0.8249)},
+		{"rXYZ", makeXYZ(0.4361, 0.2225, 0.0139)},
+		{"gXYZ", makeXYZ(0.3851, 0.7169, 0.0971)},
+		{"bXYZ", makeXYZ(0.1431, 0.0606, 0.7141)},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+
+	// Lay out tag data after the header and tag table. Identical data is stored once.
+	offset := 128 + 4 + 12*len(tags)
+	var body bytes.Buffer
+	table := make([]byte, 0, 4+12*len(tags))
+	table = binary.BigEndian.AppendUint32(table, uint32(len(tags)))
+	offsets := map[*byte]int{}
+	for _, t := range tags {
+		off, ok := offsets[&t.data[0]]
+		if !ok {
+			off = offset + body.Len()
+			offsets[&t.data[0]] = off
+			body.Write(t.data)
+			for body.Len()%4 != 0 {
+				body.WriteByte(0)
+			}
+		}
+		table = append(table, t.sig...)
+		table = binary.BigEndian.AppendUint32(table, uint32(off))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(t.data)))
+	}
+
+	size := offset + body.Len()
+	header := make([]byte, 128)
+	binary.BigEndian.PutUint32(header[0:], uint32(size))
+	copy(header[8:], []byte{2, 0x10, 0, 0}) // Version 2.1.
+	copy(header[12:], "mntr")
+	copy(header[16:], "RGB ")
+	copy(header[20:], "XYZ ")
+	// Creation date: 2000-01-01 00:00:00.
+	binary.BigEndian.PutUint16(header[24:], 2000)
+	binary.BigEndian.PutUint16(header[26:], 1)
+	binary.BigEndian.PutUint16(header[28:], 1)
+	copy(header[36:], "acsp")
+	// Rendering intent: perceptual. PCS illuminant: D50.
+	copy(header[68:], makeXYZ(0.9642, 1.0, 0.8249)[8:])
+
+	out := make([]byte, 0, size)
+	out = append(out, header...)
+	out = append(out, table...)
+	out = append(out, body.Bytes()...)
+	return out
+}
+
+// s15Fixed16 encodes `v` as a signed 15.16 fixed point number.
+func s15Fixed16(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
+
+// makeXYZ returns an XYZType element with a single XYZ value.
+func makeXYZ(x, y, z float64) []byte {
+	b := make([]byte, 20)
+	copy(b, "XYZ ")
+	binary.BigEndian.PutUint32(b[8:], s15Fixed16(x))
+	binary.BigEndian.PutUint32(b[12:], s15Fixed16(y))
+	binary.BigEndian.PutUint32(b[16:], s15Fixed16(z))
+	return b
+}
+
+// makeCurve returns a curveType element with `n` samples of `f` over [0, 1].
+func makeCurve(n int, f func(float64) float64) []byte {
+	b := make([]byte, 12+2*n)
+	copy(b, "curv")
+	binary.BigEndian.PutUint32(b[8:], uint32(n))
+	for i := 0; i < n; i++ {
+		v := f(float64(i) / float64(n-1))
+		binary.BigEndian.PutUint16(b[12+2*i:], uint16(math.Round(v*65535)))
+	}
+	return b
+}
+
+// makeText returns a textType element.
+func makeText(s string) []byte {
+	b := make([]byte, 8, 8+len(s)+1)
+	copy(b, "text")
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// makeTextDescription returns a textDescriptionType element (ICC v2) with an ASCII description
+// and empty Unicode and ScriptCode descriptions.
+func makeTextDescription(s string) []byte {
+	b := make([]byte, 8, 8+4+len(s)+1+8+3+67)
+	copy(b, "desc")
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)+1))
+	b = append(b, s...)
+	b = append(b, 0)
+	// Unicode language code and count.
+	b = append(b, make([]byte, 8)...)
+	// ScriptCode code, count and the fixed size description.
+	b = append(b, make([]byte, 3+67)...)
+	return b
+}
@@ -0,0 +1,53 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package icc
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestSRGB(t *testing.T) {
+	data := SRGB()
+	h, err := ParseHeader(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(h.Size) != len(data) {
+		t.Fatalf("size mismatch: %d vs %d", h.Size, len(data))
+	}
+	if h.Major != 2 || h.Minor != 1 || h.Class != "mntr" || h.ColorSpace != "RGB " || h.PCS != "XYZ " {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	if h.NumComponents() != 3 {
+		t.Fatalf("unexpected number of components: %d", h.NumComponents())
+	}
+
+	// All tags must be within the profile and 4-byte aligned.
+	count := int(binary.BigEndian.Uint32(data[128:]))
+	if count != 9 {
+		t.Fatalf("unexpected tag count: %d", count)
+	}
+	for i := 0; i < count; i++ {
+		entry := data[132+12*i:]
+		off := binary.BigEndian.Uint32(entry[4:])
+		size := binary.BigEndian.Uint32(entry[8:])
+		if off%4 != 0 || int(off+size) > len(data) {
+			t.Fatalf("invalid tag %s: offset %d size %d", entry[:4], off, size)
+		}
+	}
+}
+
+func TestParseHeaderInvalid(t *testing.T) {
+	if _, err := ParseHeader([]byte("not a profile")); err == nil {
+		t.Fatal("expected error")
+	}
+	data := append([]byte(nil), SRGB()...)
+	binary.BigEndian.PutUint32(data, uint32(len(data)+1))
+	if _, err := ParseHeader(data); err == nil {
+		t.Fatal("expected size error")
+	}
+}
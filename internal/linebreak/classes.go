@@ -0,0 +1,210 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package linebreak
+
+import (
+	"unicode"
+)
+
+// class is a line breaking class of UAX #14.
+type class uint8
+
+// Line breaking classes. The classes resolved by rule LB1 (AI, SA, SG, XX and CJ) are not
+// represented: their characters are given their resolved class.
+const (
+	clsAL  class = iota // Alphabetic
+	clsBK               // Mandatory break
+	clsCR               // Carriage return
+	clsLF               // Line feed
+	clsNL               // Next line
+	clsSP               // Space
+	clsZW               // Zero width space
+	clsWJ               // Word joiner
+	clsGL               // Non-breaking glue
+	clsCM               // Combining mark
+	clsZWJ              // Zero width joiner
+	clsBA               // Break after
+	clsBB               // Break before
+	clsB2               // Break opportunity before and after
+	clsHY               // Hyphen
+	clsCB               // Contingent break
+	clsCL               // Close punctuation
+	clsCP               // Close parenthesis
+	clsEX               // Exclamation
+	clsIN               // Inseparable
+	clsNS               // Non starter
+	clsOP               // Open punctuation
+	clsQU               // Quotation
+	clsIS               // Infix numeric separator
+	clsNU               // Numeric
+	clsPO               // Postfix numeric
+	clsPR               // Prefix numeric
+	clsSY               // Symbols allowing break after
+	clsHL               // Hebrew letter
+	clsID               // Ideographic
+	clsEB               // Emoji base
+	clsEM               // Emoji modifier
+	clsH2               // Hangul LV syllable
+	clsH3               // Hangul LVT syllable
+	clsJL               // Hangul L jamo
+	clsJV               // Hangul V jamo
+	clsJT               // Hangul T jamo
+	clsRI               // Regional indicator
+)
+
+// asciiClasses are the classes of the printable ASCII characters, from 0x20.
+var asciiClasses = [...]class{
+	clsSP, clsEX, clsQU, clsAL, clsPR, clsPO, clsAL, clsQU, // space ! " # $ % & '
+	clsOP, clsCP, clsAL, clsPR, clsIS, clsHY, clsIS, clsSY, // ( ) * + , - . /
+	clsNU, clsNU, clsNU, clsNU, clsNU, clsNU, clsNU, clsNU, // 0-7
+	clsNU, clsNU, clsIS, clsIS, clsAL, clsAL, clsAL, clsEX, // 8 9 : ; < = > ?
+}
+
+// specialClasses are the classes of the characters which class is not derived from their general
+// category or script.
+var specialClasses = map[rune]class{
+	'[': clsOP, '\\': clsPR, ']': clsCP, '{': clsOP, '|': clsBA, '}': clsCL,
+	0x0009: clsBA, 0x000A: clsLF, 0x000B: clsBK, 0x000C: clsBK, 0x000D: clsCR, 0x0085: clsNL,
+	0x00A0: clsGL, 0x00A2: clsPO, 0x00A3: clsPR, 0x00A5: clsPR, 0x00A7: clsAL, 0x00AB: clsQU,
+	0x00AD: clsBA, 0x00B0: clsPO, 0x00B1: clsPR, 0x00B4: clsBB, 0x00BB: clsQU, 0x00BF: clsOP,
+	0x00A1: clsOP, 0x034F: clsGL, 0x058A: clsBA, 0x05BE: clsBA, 0x0F0B: clsBA, 0x0F0C: clsGL,
+	0x1680: clsBA, 0x180E: clsGL,
+	0x2007: clsGL, 0x2008: clsBA, 0x2009: clsBA, 0x200A: clsBA, 0x200B: clsZW, 0x200C: clsCM,
+	0x200D: clsZWJ, 0x2010: clsBA, 0x2011: clsGL, 0x2012: clsBA, 0x2013: clsBA, 0x2014: clsB2,
+	0x2018: clsQU, 0x2019: clsQU, 0x201A: clsOP, 0x201B: clsQU, 0x201C: clsQU, 0x201D: clsQU,
+	0x201E: clsOP, 0x201F: clsQU, 0x2024: clsIN, 0x2025: clsIN, 0x2026: clsIN, 0x2027: clsBA,
+	0x2028: clsBK, 0x2029: clsBK, 0x202F: clsGL, 0x2030: clsPO, 0x2031: clsPO, 0x2032: clsPO,
+	0x2033: clsPO, 0x2039: clsQU, 0x203A: clsQU, 0x203C: clsNS, 0x2044: clsIS, 0x2060: clsWJ,
+	0x20AC: clsPR, 0x2103: clsPO, 0x2116: clsPR, 0x2212: clsPR, 0x2213: clsPR,
+	0x3001: clsCL, 0x3002: clsCL, 0x3005: clsNS, 0x301C: clsNS, 0x303B: clsNS, 0x309B: clsNS,
+	0x309C: clsNS, 0x309D: clsNS, 0x309E: clsNS, 0x30A0: clsNS, 0x30FB: clsNS, 0x30FC: clsNS,
+	0x30FD: clsNS, 0x30FE: clsNS, 0xFE50: clsCL, 0xFE52: clsCL, 0xFEFF: clsWJ,
+	0xFF01: clsEX, 0xFF04: clsPR, 0xFF05: clsPO, 0xFF0C: clsCL, 0xFF0E: clsCL, 0xFF1A: clsNS,
+	0xFF1B: clsNS, 0xFF1F: clsEX, 0xFF61: clsCL, 0xFF64: clsCL, 0xFF65: clsNS, 0xFFE0: clsPO,
+	0xFFE1: clsPR, 0xFFE5: clsPR, 0xFFE6: clsPR,
+}
+
+// smallKana are the small hiragana and katakana, of class CJ resolved to NS (LB1).
+var smallKana = map[rune]bool{
+	0x3041: true, 0x3043: true, 0x3045: true, 0x3047: true, 0x3049: true, 0x3063: true,
+	0x3083: true, 0x3085: true, 0x3087: true, 0x308E: true, 0x3095: true, 0x3096: true,
+	0x30A1: true, 0x30A3: true, 0x30A5: true, 0x30A7: true, 0x30A9: true, 0x30C3: true,
+	0x30E3: true, 0x30E5: true, 0x30E7: true, 0x30EE: true, 0x30F5: true, 0x30F6: true,
+}
+
+// ideographic are the ranges of characters of class ID which are not Han, Hiragana or Katakana
+// letters: CJK symbols and punctuation, fullwidth forms and pictographs.
+var ideographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x2E80, Hi: 0x2FFF, Stride: 1}, {Lo: 0x3000, Hi: 0x303F, Stride: 1},
+		{Lo: 0x3040, Hi: 0x30FF, Stride: 1}, {Lo: 0x3100, Hi: 0x31FF, Stride: 1},
+		{Lo: 0x3200, Hi: 0x4DBF, Stride: 1}, {Lo: 0x4E00, Hi: 0x9FFF, Stride: 1},
+		{Lo: 0xA000, Hi: 0xA4CF, Stride: 1}, {Lo: 0xF900, Hi: 0xFAFF, Stride: 1},
+		{Lo: 0xFE30, Hi: 0xFE4F, Stride: 1}, {Lo: 0xFF00, Hi: 0xFF60, Stride: 1},
+		{Lo: 0xFFE0, Hi: 0xFFE6, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F000, Hi: 0x1F0FF, Stride: 1}, {Lo: 0x1F200, Hi: 0x1F2FF, Stride: 1},
+		{Lo: 0x1F300, Hi: 0x1F5FF, Stride: 1}, {Lo: 0x1F600, Hi: 0x1F64F, Stride: 1},
+		{Lo: 0x1F680, Hi: 0x1F6FF, Stride: 1}, {Lo: 0x1F900, Hi: 0x1F9FF, Stride: 1},
+		{Lo: 0x1FA70, Hi: 0x1FAFF, Stride: 1}, {Lo: 0x20000, Hi: 0x3FFFD, Stride: 1},
+	},
+}
+
+// emojiModifiers are the skin tone modifiers, of class EM.
+var emojiModifiers = &unicode.RangeTable{
+	R32: []unicode.Range32{{Lo: 0x1F3FB, Hi: 0x1F3FF, Stride: 1}},
+}
+
+// emojiBases are the main emoji accepting skin tone modifiers, of class EB.
+var emojiBases = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x261D, Hi: 0x261D, Stride: 1}, {Lo: 0x26F9, Hi: 0x26F9, Stride: 1},
+		{Lo: 0x270A, Hi: 0x270D, Stride: 1},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1F385, Hi: 0x1F385, Stride: 1}, {Lo: 0x1F3C2, Hi: 0x1F3C4, Stride: 1},
+		{Lo: 0x1F3C7, Hi: 0x1F3C7, Stride: 1}, {Lo: 0x1F3CA, Hi: 0x1F3CC, Stride: 1},
+		{Lo: 0x1F442, Hi: 0x1F443, Stride: 1}, {Lo: 0x1F446, Hi: 0x1F450, Stride: 1},
+		{Lo: 0x1F466, Hi: 0x1F478, Stride: 1}, {Lo: 0x1F47C, Hi: 0x1F47C, Stride: 1},
+		{Lo: 0x1F481, Hi: 0x1F483, Stride: 1}, {Lo: 0x1F485, Hi: 0x1F487, Stride: 1},
+		{Lo: 0x1F4AA, Hi: 0x1F4AA, Stride: 1}, {Lo: 0x1F574, Hi: 0x1F575, Stride: 1},
+		{Lo: 0x1F57A, Hi: 0x1F57A, Stride: 1}, {Lo: 0x1F590, Hi: 0x1F590, Stride: 1},
+		{Lo: 0x1F595, Hi: 0x1F596, Stride: 1}, {Lo: 0x1F645, Hi: 0x1F647, Stride: 1},
+		{Lo: 0x1F64B, Hi: 0x1F64F, Stride: 1}, {Lo: 0x1F6A3, Hi: 0x1F6A3, Stride: 1},
+		{Lo: 0x1F6B4, Hi: 0x1F6B6, Stride: 1}, {Lo: 0x1F6C0, Hi: 0x1F6C0, Stride: 1},
+		{Lo: 0x1F6CC, Hi: 0x1F6CC, Stride: 1}, {Lo: 0x1F90C, Hi: 0x1F90C, Stride: 1},
+		{Lo: 0x1F90F, Hi: 0x1F90F, Stride: 1}, {Lo: 0x1F918, Hi: 0x1F91F, Stride: 1},
+		{Lo: 0x1F926, Hi: 0x1F926, Stride: 1}, {Lo: 0x1F930, Hi: 0x1F939, Stride: 1},
+		{Lo: 0x1F93C, Hi: 0x1F93E, Stride: 1}, {Lo: 0x1F977, Hi: 0x1F977, Stride: 1},
+		{Lo: 0x1F9B5, Hi: 0x1F9B6, Stride: 1}, {Lo: 0x1F9B8, Hi: 0x1F9B9, Stride: 1},
+		{Lo: 0x1F9BB, Hi: 0x1F9BB, Stride: 1}, {Lo: 0x1F9CD, Hi: 0x1F9CF, Stride: 1},
+		{Lo: 0x1F9D1, Hi: 0x1F9DD, Stride: 1}, {Lo: 0x1FAC3, Hi: 0x1FAC5, Stride: 1},
+		{Lo: 0x1FAF0, Hi: 0x1FAF8, Stride: 1},
+	},
+}
+
+// runeClass returns the line breaking class of `r`, as resolved by rule LB1. The characters of
+// the scripts which words are not separated by spaces (SA), e.g. Thai, are resolved to AL and CM
+// as words cannot be found without dictionaries.
+func runeClass(r rune) class {
+	if r >= 0x20 && int(r-0x20) < len(asciiClasses) {
+		return asciiClasses[r-0x20]
+	}
+	if c, ok := specialClasses[r]; ok {
+		return c
+	}
+	switch {
+	case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r == '@', r == '^', r == '_', r == '`', r == '~':
+		return clsAL
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return clsH2
+		}
+		return clsH3
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return clsJL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return clsJV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return clsJT
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return clsRI
+	case unicode.Is(emojiModifiers, r):
+		return clsEM
+	case unicode.Is(emojiBases, r):
+		return clsEB
+	case smallKana[r], r >= 0x31F0 && r <= 0x31FF, r >= 0xFF67 && r <= 0xFF70:
+		return clsNS
+	}
+
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Mc, unicode.Me):
+		return clsCM
+	case unicode.Is(unicode.Cc, r):
+		return clsCM
+	case unicode.Is(unicode.Zs, r):
+		return clsBA
+	case unicode.Is(unicode.Ps, r):
+		return clsOP
+	case unicode.Is(unicode.Pe, r):
+		if unicode.Is(ideographic, r) {
+			return clsCL
+		}
+		return clsCP
+	case unicode.In(r, unicode.Pi, unicode.Pf):
+		return clsQU
+	case unicode.Is(unicode.Nd, r):
+		return clsNU
+	case unicode.Is(unicode.Sc, r):
+		return clsPR
+	case unicode.Is(unicode.Hebrew, r) && unicode.IsLetter(r):
+		return clsHL
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, ideographic):
+		return clsID
+	}
+	return clsAL
+}
@@ -0,0 +1,212 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package linebreak implements the Unicode Line Breaking Algorithm (UAX #14) which finds the
+// positions of text where lines can be broken, e.g. after spaces and hyphens, or between
+// ideographs but not before closing punctuation.
+//
+// The tailorable rules are applied in their default form. Text of the scripts which words are
+// not separated by spaces, e.g. Thai, is only broken at spaces as their words cannot be found
+// without dictionaries.
+package linebreak
+
+import (
+	"unicode"
+)
+
+// Opportunity is the line break opportunity at a position of text.
+type Opportunity uint8
+
+const (
+	// NoBreak prohibits breaking the line.
+	NoBreak Opportunity = iota
+	// Break allows breaking the line.
+	Break
+	// MandatoryBreak requires breaking the line, e.g. after a line feed.
+	MandatoryBreak
+)
+
+// Opportunities returns the line break opportunities of `runes`: the opportunity at index `i` is
+// that of breaking the line before the rune `i`. The opportunity at index 0 is NoBreak (rule
+// LB2) and the opportunity at index len(runes), the end of the text, is MandatoryBreak (LB3).
+func Opportunities(runes []rune) []Opportunity {
+	n := len(runes)
+	ops := make([]Opportunity, n+1)
+	ops[n] = MandatoryBreak
+	if n == 0 {
+		return ops
+	}
+
+	// Combining marks take the class of the character they follow (LB9), or are alphabetic
+	// (LB10).
+	raw := make([]class, n)
+	cls := make([]class, n)
+	attached := make([]bool, n)
+	for i, r := range runes {
+		raw[i] = runeClass(r)
+		cls[i] = raw[i]
+		if raw[i] != clsCM && raw[i] != clsZWJ {
+			continue
+		}
+		if i > 0 && !isBreakOrSpace(cls[i-1]) {
+			cls[i] = cls[i-1]
+			attached[i] = true
+		} else {
+			cls[i] = clsAL
+		}
+	}
+
+	riCount := 0
+	for i := 1; i < n; i++ {
+		if cls[i-1] == clsRI {
+			riCount++
+		} else if !attached[i-1] {
+			riCount = 0
+		}
+		ops[i] = pairOpportunity(runes, raw, cls, attached, i, riCount)
+	}
+	return ops
+}
+
+// isBreakOrSpace returns true for the classes of characters combining marks do not attach to.
+func isBreakOrSpace(c class) bool {
+	switch c {
+	case clsBK, clsCR, clsLF, clsNL, clsSP, clsZW:
+		return true
+	}
+	return false
+}
+
+// pairOpportunity returns the opportunity of breaking before the rune `i` of `runes`, of classes
+// `cls` resolved from the classes `raw`. `riCount` is the number of regional indicators before
+// the rune.
+func pairOpportunity(runes []rune, raw, cls []class, attached []bool, i, riCount int) Opportunity {
+	before, after := cls[i-1], cls[i]
+
+	// The class before the spaces preceding the position, for the rules with SP*.
+	j := i - 1
+	for j > 0 && cls[j] == clsSP {
+		j--
+	}
+	beforeSpaces := cls[j]
+
+	switch {
+	// LB4, LB5: mandatory breaks.
+	case before == clsBK:
+		return MandatoryBreak
+	case before == clsCR && after == clsLF:
+		return NoBreak
+	case before == clsCR || before == clsLF || before == clsNL:
+		return MandatoryBreak
+	// LB6, LB7.
+	case after == clsBK || after == clsCR || after == clsLF || after == clsNL:
+		return NoBreak
+	case after == clsSP || after == clsZW:
+		return NoBreak
+	// LB8: ZW SP* ÷.
+	case beforeSpaces == clsZW:
+		return Break
+	// LB8a, LB9: no break after joiners and before attached marks.
+	case raw[i-1] == clsZWJ || attached[i]:
+		return NoBreak
+	// LB11, LB12, LB12a: glue.
+	case before == clsWJ || after == clsWJ, before == clsGL:
+		return NoBreak
+	case after == clsGL && before != clsSP && before != clsBA && before != clsHY:
+		return NoBreak
+	// LB13.
+	case after == clsCL || after == clsCP || after == clsEX || after == clsIS || after == clsSY:
+		return NoBreak
+	// LB14 to LB17.
+	case beforeSpaces == clsOP:
+		return NoBreak
+	case beforeSpaces == clsQU && after == clsOP:
+		return NoBreak
+	case (beforeSpaces == clsCL || beforeSpaces == clsCP) && after == clsNS:
+		return NoBreak
+	case beforeSpaces == clsB2 && after == clsB2:
+		return NoBreak
+	// LB18: break after spaces.
+	case before == clsSP:
+		return Break
+	// LB19, LB20: quotations and contingent breaks.
+	case before == clsQU || after == clsQU:
+		return NoBreak
+	case before == clsCB || after == clsCB:
+		return Break
+	// LB21, LB21a, LB21b, LB22.
+	case after == clsBA || after == clsHY || after == clsNS || before == clsBB:
+		return NoBreak
+	case (before == clsHY || before == clsBA) && i >= 2 && cls[i-2] == clsHL:
+		return NoBreak
+	case before == clsSY && after == clsHL:
+		return NoBreak
+	case after == clsIN:
+		return NoBreak
+	}
+
+	if pairs[before]&(1<<after) != 0 {
+		return NoBreak
+	}
+
+	switch {
+	// LB30: no break between alphanumerics and parentheses, except East Asian ones.
+	case (before == clsAL || before == clsHL || before == clsNU) && after == clsOP &&
+		!unicode.Is(ideographic, runes[i]):
+		return NoBreak
+	case before == clsCP && (after == clsAL || after == clsHL || after == clsNU) &&
+		!unicode.Is(ideographic, runes[i-1]):
+		return NoBreak
+	// LB30a: regional indicators pair as flags.
+	case before == clsRI && after == clsRI && riCount%2 == 1:
+		return NoBreak
+	}
+	// LB31.
+	return Break
+}
+
+// pairs are the pairs of classes of rules LB23 to LB29 and LB30b prohibiting breaks between
+// them: the classes after which a break is prohibited have their bit set in the entry of the
+// class before.
+var pairs = func() map[class]uint64 {
+	rules := []struct {
+		before, after []class
+	}{
+		// LB23.
+		{[]class{clsAL, clsHL}, []class{clsNU}},
+		{[]class{clsNU}, []class{clsAL, clsHL}},
+		// LB23a.
+		{[]class{clsPR}, []class{clsID, clsEB, clsEM}},
+		{[]class{clsID, clsEB, clsEM}, []class{clsPO}},
+		// LB24.
+		{[]class{clsPR, clsPO}, []class{clsAL, clsHL}},
+		{[]class{clsAL, clsHL}, []class{clsPR, clsPO}},
+		// LB25.
+		{[]class{clsCL, clsCP, clsNU}, []class{clsPO, clsPR}},
+		{[]class{clsPO, clsPR}, []class{clsOP, clsNU}},
+		{[]class{clsHY, clsIS, clsNU, clsSY}, []class{clsNU}},
+		// LB26.
+		{[]class{clsJL}, []class{clsJL, clsJV, clsH2, clsH3}},
+		{[]class{clsJV, clsH2}, []class{clsJV, clsJT}},
+		{[]class{clsJT, clsH3}, []class{clsJT}},
+		// LB27.
+		{[]class{clsJL, clsJV, clsJT, clsH2, clsH3}, []class{clsPO}},
+		{[]class{clsPR}, []class{clsJL, clsJV, clsJT, clsH2, clsH3}},
+		// LB28, LB29.
+		{[]class{clsAL, clsHL}, []class{clsAL, clsHL}},
+		{[]class{clsIS}, []class{clsAL, clsHL}},
+		// LB30b.
+		{[]class{clsEB}, []class{clsEM}},
+	}
+	pairs := map[class]uint64{}
+	for _, rule := range rules {
+		for _, b := range rule.before {
+			for _, a := range rule.after {
+				pairs[b] |= 1 << a
+			}
+		}
+	}
+	return pairs
+}()
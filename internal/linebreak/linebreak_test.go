@@ -0,0 +1,76 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package linebreak
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// segments returns the segments of `text` between its line break opportunities, the segments
+// ending at mandatory breaks being followed by "!".
+func segments(text string) []string {
+	runes := []rune(text)
+	ops := Opportunities(runes)
+	var segs []string
+	start := 0
+	for i := 1; i <= len(runes); i++ {
+		switch ops[i] {
+		case Break:
+			segs = append(segs, string(runes[start:i]))
+			start = i
+		case MandatoryBreak:
+			seg := string(runes[start:i])
+			if i < len(runes) {
+				seg += "!"
+			}
+			segs = append(segs, seg)
+			start = i
+		}
+	}
+	return segs
+}
+
+func TestOpportunities(t *testing.T) {
+	testcases := []struct {
+		text     string
+		expected []string
+	}{
+		{"", nil},
+		{"Hello world", []string{"Hello ", "world"}},
+		{"Hello  world", []string{"Hello  ", "world"}},
+		{"well-known", []string{"well-", "known"}},
+		{"(a) b, c.", []string{"(a) ", "b, ", "c."}},
+		{"3.14 $100 50%", []string{"3.14 ", "$100 ", "50%"}},
+		{"line\nbreak", []string{"line\n!", "break"}},
+		{"crlf\r\nx", []string{"crlf\r\n!", "x"}},
+		{"no break", []string{"no break"}},
+		{"soft\u00ADhyphen", []string{"soft\u00AD", "hyphen"}},
+		{"zero\u200Bwidth", []string{"zero\u200B", "width"}},
+		{"word\u2060joiner", []string{"word\u2060joiner"}},
+		// Ideographs break between each other but not before closing punctuation and small kana.
+		{"日本語の文章。", []string{"日", "本", "語", "の", "文", "章。"}},
+		{"「東京」へ", []string{"「東", "京」", "へ"}},
+		{"ちょっと", []string{"ちょっ", "と"}},
+		{"한국어 텍스트", []string{"한", "국", "어 ", "텍", "스", "트"}},
+		{"CJK漢字mixed", []string{"CJK", "漢", "字", "mixed"}},
+		// Combining marks stay with their base.
+		{"e\u0301te ete", []string{"e\u0301te ", "ete"}},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, segments(tc.text), tc.text)
+	}
+}
+
+func TestOpportunitiesBounds(t *testing.T) {
+	runes := []rune(strings.Repeat("a b ", 3))
+	ops := Opportunities(runes)
+	require.Len(t, ops, len(runes)+1)
+	require.Equal(t, NoBreak, ops[0])
+	require.Equal(t, MandatoryBreak, ops[len(runes)])
+}
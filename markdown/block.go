@@ -0,0 +1,839 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// blockKind is the kind of a block of a Markdown document.
+type blockKind int
+
+const (
+	documentBlock blockKind = iota
+	paragraphBlock
+	headingBlock
+	thematicBreakBlock
+	codeBlock
+	htmlBlock
+	quoteBlock
+	listBlock
+	itemBlock
+	tableBlock
+)
+
+// columnAlign is the alignment of the cells of a table column.
+type columnAlign int
+
+const (
+	alignDefault columnAlign = iota
+	alignLeft
+	alignCenter
+	alignRight
+)
+
+// block is a block of a Markdown document. The container blocks (document, block quotes, lists
+// and list items) have children, the leaf blocks have content.
+type block struct {
+	kind     blockKind
+	parent   *block
+	children []*block
+	open     bool
+
+	// lines are the lines of the leaf blocks accepting lines.
+	lines []string
+
+	// content is the raw inline content of paragraphs and headings, and the text of code blocks.
+	content string
+
+	// level is the level of headings, 1 to 6.
+	level int
+
+	// fence is the opening fence of fenced code blocks, empty for indented code blocks.
+	fence       string
+	fenceIndent int
+
+	// info is the info string of fenced code blocks.
+	info string
+
+	// htmlEnd is the end condition of HTML blocks, empty if ended by a blank line.
+	htmlEnd string
+
+	// The marker of list items and lists: the bullet, or the delimiter of ordered lists.
+	ordered bool
+	marker  byte
+	start   int
+
+	// tight lists have no blank lines between their items.
+	tight bool
+
+	// contentIndent is the indentation of the content of list items.
+	contentIndent int
+
+	// startLine is the line number the block starts at.
+	startLine int
+
+	// align and rows are the column alignments and rows of the raw cells of tables, the header
+	// row first.
+	align []columnAlign
+	rows  [][]string
+
+	lastLineBlank bool
+}
+
+// lastChild returns the last child of `b`, nil if none.
+func (b *block) lastChild() *block {
+	if len(b.children) == 0 {
+		return nil
+	}
+	return b.children[len(b.children)-1]
+}
+
+// canContain returns true if blocks of kind `kind` can be children of `b`.
+func (b *block) canContain(kind blockKind) bool {
+	switch b.kind {
+	case documentBlock, quoteBlock, itemBlock:
+		return kind != itemBlock
+	case listBlock:
+		return kind == itemBlock
+	}
+	return false
+}
+
+// acceptsLines returns true if the lines of text are added to `b`.
+func (b *block) acceptsLines() bool {
+	switch b.kind {
+	case paragraphBlock, codeBlock, htmlBlock, tableBlock:
+		return true
+	}
+	return false
+}
+
+// endsWithBlankLine returns true if the last line of `b`, or of its last descendant for lists
+// and list items, is blank.
+func (b *block) endsWithBlankLine() bool {
+	for b != nil {
+		if b.lastLineBlank {
+			return true
+		}
+		if b.kind != listBlock && b.kind != itemBlock {
+			return false
+		}
+		b = b.lastChild()
+	}
+	return false
+}
+
+// linkReference is a link reference definition.
+type linkReference struct {
+	dest  string
+	title string
+}
+
+// blockParser parses the block structure of Markdown documents, line by line.
+type blockParser struct {
+	doc  *block
+	tip  *block
+	refs map[string]linkReference
+
+	// The current line, the offset of the text not consumed yet, and the offset and indentation
+	// of the next non-space character.
+	line         string
+	offset       int
+	nextNonspace int
+	indent       int
+	indented     bool
+	blank        bool
+	lineNumber   int
+
+	oldTip      *block
+	lastMatched *block
+	allClosed   bool
+}
+
+// continuation is the result of matching a line with an open block.
+type continuation int
+
+const (
+	continued continuation = iota
+	notContinued
+	lineConsumed
+)
+
+// parseBlocks parses the block structure of the Markdown document `src`. The link reference
+// definitions are returned by their normalized labels.
+func parseBlocks(src string) (*block, map[string]linkReference) {
+	doc := &block{kind: documentBlock, open: true}
+	p := &blockParser{doc: doc, tip: doc, oldTip: doc, refs: map[string]linkReference{}}
+
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	src = strings.ReplaceAll(src, "\x00", "�")
+	lines := strings.Split(src, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		p.lineNumber++
+		p.addLine(expandTabs(line))
+	}
+	for p.tip != nil {
+		p.finalize(p.tip)
+	}
+	return doc, p.refs
+}
+
+// expandTabs replaces the tabs of `line` by spaces, up to the next tab stop of 4 columns.
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// findNextNonspace sets the offset and indentation of the next non-space character.
+func (p *blockParser) findNextNonspace() {
+	i := p.offset
+	for i < len(p.line) && p.line[i] == ' ' {
+		i++
+	}
+	p.nextNonspace = i
+	p.indent = i - p.offset
+	p.indented = p.indent >= 4
+	p.blank = i == len(p.line)
+}
+
+// advance consumes `n` characters of the line.
+func (p *blockParser) advance(n int) {
+	p.offset += n
+	if p.offset > len(p.line) {
+		p.offset = len(p.line)
+	}
+}
+
+// advanceNextNonspace consumes the spaces up to the next non-space character.
+func (p *blockParser) advanceNextNonspace() {
+	p.offset = p.nextNonspace
+}
+
+// peek returns the character at offset `i` of the line, 0 past its end.
+func (p *blockParser) peek(i int) byte {
+	if i < len(p.line) {
+		return p.line[i]
+	}
+	return 0
+}
+
+// addLine incorporates the line `line` into the document.
+func (p *blockParser) addLine(line string) {
+	p.line = line
+	p.offset = 0
+	p.oldTip = p.tip
+
+	// The open blocks continued by the line are matched first.
+	container := p.doc
+	for {
+		last := container.lastChild()
+		if last == nil || !last.open {
+			break
+		}
+		container = last
+		p.findNextNonspace()
+		match := p.continues(container)
+		if match == lineConsumed {
+			return
+		}
+		if match == notContinued {
+			container = container.parent
+			break
+		}
+	}
+	p.allClosed = container == p.oldTip
+	p.lastMatched = container
+
+	// New blocks are started by the rest of the line.
+	matchedLeaf := container.acceptsLines() && container.kind != paragraphBlock &&
+		container.kind != tableBlock
+	for !matchedLeaf {
+		p.findNextNonspace()
+		started := p.startBlock(container)
+		if started == 0 {
+			p.advanceNextNonspace()
+			break
+		}
+		container = p.tip
+		matchedLeaf = started == 2
+	}
+
+	// The rest of the line is text, added to the current block.
+	if !p.allClosed && !p.blank && p.tip.kind == paragraphBlock {
+		// Lazy continuation line of a paragraph.
+		p.tip.lines = append(p.tip.lines, strings.TrimLeft(p.line[p.offset:], " "))
+		return
+	}
+
+	p.closeUnmatched()
+	if p.blank && container.lastChild() != nil {
+		container.lastChild().lastLineBlank = true
+	}
+	lastLineBlank := p.blank && !(container.kind == quoteBlock ||
+		(container.kind == codeBlock && container.fence != "") ||
+		(container.kind == itemBlock && len(container.children) == 0 && container.startLine == p.lineNumber))
+	for b := container; b != nil; b = b.parent {
+		b.lastLineBlank = lastLineBlank
+	}
+
+	switch {
+	case container.kind == tableBlock:
+		if !p.blank && p.offset < len(p.line) {
+			container.rows = append(container.rows, splitTableRow(p.line[p.offset:]))
+		}
+	case container.kind == paragraphBlock:
+		container.lines = append(container.lines, strings.TrimLeft(p.line[p.offset:], " "))
+	case container.kind == codeBlock && container.fence != "" && container.startLine == p.lineNumber:
+		// The line of the opening fence is not content.
+	case container.acceptsLines():
+		container.lines = append(container.lines, p.line[p.offset:])
+		if container.kind == htmlBlock && container.htmlEnd != "" &&
+			strings.Contains(p.line[p.offset:], container.htmlEnd) {
+			p.finalize(container)
+		}
+	case p.offset < len(p.line) && !p.blank:
+		p.addChild(paragraphBlock)
+		p.advanceNextNonspace()
+		p.tip.lines = append(p.tip.lines, p.line[p.offset:])
+	}
+}
+
+// continues matches the line with the open block `b`, consuming the markers of `b`.
+func (p *blockParser) continues(b *block) continuation {
+	switch b.kind {
+	case quoteBlock:
+		if p.indented || p.peek(p.nextNonspace) != '>' {
+			return notContinued
+		}
+		p.advanceNextNonspace()
+		p.advance(1)
+		if p.peek(p.offset) == ' ' {
+			p.advance(1)
+		}
+	case itemBlock:
+		switch {
+		case p.blank:
+			if len(b.children) == 0 {
+				// A list item can begin with at most one blank line.
+				return notContinued
+			}
+			p.advanceNextNonspace()
+		case p.indent >= b.contentIndent:
+			p.advance(b.contentIndent)
+		default:
+			return notContinued
+		}
+	case headingBlock, thematicBreakBlock:
+		return notContinued
+	case codeBlock:
+		if b.fence == "" {
+			switch {
+			case p.indent >= 4:
+				p.advance(4)
+			case p.blank:
+				p.advanceNextNonspace()
+			default:
+				return notContinued
+			}
+			return continued
+		}
+		if !p.indented {
+			rest := strings.TrimRight(p.line[p.nextNonspace:], " ")
+			if len(rest) >= len(b.fence) && strings.Trim(rest, b.fence[:1]) == "" {
+				p.finalize(b)
+				return lineConsumed
+			}
+		}
+		for i := 0; i < b.fenceIndent && p.peek(p.offset) == ' '; i++ {
+			p.advance(1)
+		}
+	case htmlBlock:
+		if p.blank && b.htmlEnd == "" {
+			return notContinued
+		}
+	case paragraphBlock, tableBlock:
+		if p.blank {
+			return notContinued
+		}
+	}
+	return continued
+}
+
+// closeUnmatched finalizes the blocks not continued by the current line.
+func (p *blockParser) closeUnmatched() {
+	if p.allClosed {
+		return
+	}
+	for p.oldTip != p.lastMatched {
+		parent := p.oldTip.parent
+		p.finalize(p.oldTip)
+		p.oldTip = parent
+	}
+	p.allClosed = true
+}
+
+// addChild adds a block of kind `kind` to the current block, or the closest of its parents
+// which can contain it, and makes it the current block.
+func (p *blockParser) addChild(kind blockKind) *block {
+	for !p.tip.canContain(kind) {
+		p.finalize(p.tip)
+	}
+	b := &block{kind: kind, parent: p.tip, open: true, startLine: p.lineNumber}
+	p.tip.children = append(p.tip.children, b)
+	p.tip = b
+	return b
+}
+
+// remove removes `b` from the children of its parent.
+func (b *block) remove() {
+	siblings := b.parent.children
+	for i, sibling := range siblings {
+		if sibling == b {
+			b.parent.children = append(siblings[:i:i], siblings[i+1:]...)
+			return
+		}
+	}
+}
+
+// finalize closes the block `b`, the current block becoming its parent.
+func (p *blockParser) finalize(b *block) {
+	b.open = false
+	p.tip = b.parent
+
+	switch b.kind {
+	case paragraphBlock:
+		content := p.parseReferences(strings.Join(b.lines, "\n"))
+		b.content = strings.TrimRight(content, " \n")
+		b.lines = nil
+		if b.content == "" {
+			b.remove()
+		}
+	case codeBlock:
+		lines := b.lines
+		if b.fence == "" {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+		}
+		if len(lines) > 0 {
+			b.content = strings.Join(lines, "\n") + "\n"
+		}
+		b.lines = nil
+	case listBlock:
+		b.tight = true
+		for i, item := range b.children {
+			lastItem := i == len(b.children)-1
+			if item.endsWithBlankLine() && !lastItem {
+				b.tight = false
+				break
+			}
+			for j, child := range item.children {
+				if child.endsWithBlankLine() && (!lastItem || j < len(item.children)-1) {
+					b.tight = false
+					break
+				}
+			}
+			if !b.tight {
+				break
+			}
+		}
+	}
+}
+
+var (
+	atxHeadingRe     = regexp.MustCompile(`^#{1,6}(?: +|$)`)
+	openingFenceRe   = regexp.MustCompile("^(?:`{3,}|~{3,})")
+	thematicBreakRe  = regexp.MustCompile(`^(?:(?:\* *){3,}|(?:_ *){3,}|(?:- *){3,})$`)
+	setextUnderline  = regexp.MustCompile(`^(?:=+|-+) *$`)
+	orderedMarkerRe  = regexp.MustCompile(`^(\d{1,9})([.)])`)
+	tableDelimiterRe = regexp.MustCompile(`^ *:?-+:? *$`)
+	htmlBlockOpenRe  = regexp.MustCompile(`^</?([A-Za-z][A-Za-z0-9-]*)(?:[ />]|$)`)
+)
+
+// htmlBlockTags are the tags starting HTML blocks ended by a blank line.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true,
+	"caption": true, "center": true, "col": true, "colgroup": true, "dd": true, "details": true,
+	"dialog": true, "dir": true, "div": true, "dl": true, "dt": true, "fieldset": true,
+	"figcaption": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "head": true, "header": true, "hr": true,
+	"html": true, "iframe": true, "legend": true, "li": true, "link": true, "main": true,
+	"menu": true, "nav": true, "ol": true, "p": true, "section": true, "summary": true,
+	"table": true, "tbody": true, "td": true, "tfoot": true, "th": true, "thead": true,
+	"title": true, "tr": true, "ul": true,
+}
+
+// startBlock starts the block the rest of the line begins with, if any, in `container`. It
+// returns 1 for a container block, 2 for a leaf block, the rest of the line being its content,
+// and 0 if no block starts.
+func (p *blockParser) startBlock(container *block) int {
+	rest := p.line[p.nextNonspace:]
+	if p.indented {
+		if p.tip.kind != paragraphBlock && !p.blank {
+			p.advance(4)
+			p.closeUnmatched()
+			p.addChild(codeBlock)
+			return 2
+		}
+		return 0
+	}
+	if p.blank {
+		return 0
+	}
+
+	switch {
+	case rest[0] == '>':
+		p.advanceNextNonspace()
+		p.advance(1)
+		if p.peek(p.offset) == ' ' {
+			p.advance(1)
+		}
+		p.closeUnmatched()
+		p.addChild(quoteBlock)
+		return 1
+
+	case atxHeadingRe.MatchString(rest):
+		p.closeUnmatched()
+		b := p.addChild(headingBlock)
+		level := strings.IndexFunc(rest, func(r rune) bool { return r != '#' })
+		if level < 0 {
+			level = len(rest)
+		}
+		b.level = level
+		b.content = atxHeadingContent(rest[level:])
+		p.offset = len(p.line)
+		return 2
+
+	case openingFenceRe.MatchString(rest):
+		fence := openingFenceRe.FindString(rest)
+		info := strings.TrimSpace(rest[len(fence):])
+		if fence[0] == '`' && strings.Contains(info, "`") {
+			break
+		}
+		p.closeUnmatched()
+		b := p.addChild(codeBlock)
+		b.fence = fence
+		b.fenceIndent = p.indent
+		b.info = unescapeString(info)
+		p.offset = len(p.line)
+		return 2
+
+	case rest[0] == '<':
+		end, ok := htmlBlockEnd(rest, container.kind == paragraphBlock)
+		if !ok {
+			break
+		}
+		p.closeUnmatched()
+		b := p.addChild(htmlBlock)
+		b.htmlEnd = end
+		return 2
+
+	case container.kind == paragraphBlock && len(container.lines) > 0 && p.startTable(container):
+		return 2
+
+	case container.kind == paragraphBlock && setextUnderline.MatchString(rest):
+		content := p.parseReferences(strings.Join(container.lines, "\n"))
+		content = strings.TrimRight(content, " \n")
+		if content == "" {
+			break
+		}
+		p.closeUnmatched()
+		container.kind = headingBlock
+		container.level = 1
+		if rest[0] == '-' {
+			container.level = 2
+		}
+		container.content = content
+		container.lines = nil
+		p.offset = len(p.line)
+		return 2
+
+	case thematicBreakRe.MatchString(strings.TrimRight(rest, " ")):
+		p.closeUnmatched()
+		p.addChild(thematicBreakBlock)
+		p.offset = len(p.line)
+		return 2
+	}
+
+	if item := p.parseListMarker(container); item != nil {
+		p.closeUnmatched()
+		if p.tip.kind != listBlock || p.tip.ordered != item.ordered || p.tip.marker != item.marker {
+			list := p.addChild(listBlock)
+			list.ordered, list.marker, list.start = item.ordered, item.marker, item.start
+		}
+		b := p.addChild(itemBlock)
+		b.ordered, b.marker, b.start, b.contentIndent = item.ordered, item.marker, item.start, item.contentIndent
+		return 1
+	}
+	return 0
+}
+
+// atxHeadingContent returns the content of an ATX heading from the text `text` following its
+// opening sequence, without the optional closing sequence.
+func atxHeadingContent(text string) string {
+	text = strings.TrimSpace(text)
+	if strings.Trim(text, "#") == "" {
+		return ""
+	}
+	trimmed := strings.TrimRight(text, "#")
+	if len(trimmed) < len(text) && strings.HasSuffix(trimmed, " ") {
+		text = trimmed
+	}
+	return strings.TrimSpace(text)
+}
+
+// htmlBlockEnd returns the end condition of the HTML block starting with `rest`: the text
+// ending the block, or an empty string if it is ended by a blank line. False is returned if no
+// HTML block starts.
+func htmlBlockEnd(rest string, inParagraph bool) (string, bool) {
+	lower := strings.ToLower(rest)
+	for _, tag := range []string{"script", "pre", "style", "textarea"} {
+		if strings.HasPrefix(lower, "<"+tag) {
+			next := byte('>')
+			if len(lower) > len(tag)+1 {
+				next = lower[len(tag)+1]
+			}
+			if next == ' ' || next == '>' {
+				return "</" + tag + ">", true
+			}
+		}
+	}
+	switch {
+	case strings.HasPrefix(rest, "<!--"):
+		return "-->", true
+	case strings.HasPrefix(rest, "<?"):
+		return "?>", true
+	case strings.HasPrefix(rest, "<![CDATA["):
+		return "]]>", true
+	case strings.HasPrefix(rest, "<!") && len(rest) > 2 && rest[2] >= 'A' && rest[2] <= 'Z':
+		return ">", true
+	}
+	if m := htmlBlockOpenRe.FindStringSubmatch(rest); m != nil && htmlBlockTags[strings.ToLower(m[1])] {
+		return "", true
+	}
+	if !inParagraph && inlineHTMLRe.MatchString(rest) {
+		if tail := strings.TrimSpace(rest[len(inlineHTMLRe.FindString(rest)):]); tail == "" {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// startTable starts a table if the current line is the delimiter row of a table which header
+// row is the last line of the paragraph `para`.
+func (p *blockParser) startTable(para *block) bool {
+	rest := strings.TrimRight(p.line[p.nextNonspace:], " ")
+	if !strings.Contains(rest, "|") && !strings.Contains(para.lines[len(para.lines)-1], "|") {
+		return false
+	}
+	delims := splitTableRow(rest)
+	header := splitTableRow(para.lines[len(para.lines)-1])
+	if len(delims) != len(header) {
+		return false
+	}
+	align := make([]columnAlign, len(delims))
+	for i, d := range delims {
+		if !tableDelimiterRe.MatchString(d) {
+			return false
+		}
+		d = strings.TrimSpace(d)
+		switch left, right := d[0] == ':', d[len(d)-1] == ':'; {
+		case left && right:
+			align[i] = alignCenter
+		case left:
+			align[i] = alignLeft
+		case right:
+			align[i] = alignRight
+		}
+	}
+
+	p.closeUnmatched()
+	para.lines = para.lines[:len(para.lines)-1]
+	if len(para.lines) > 0 {
+		p.finalize(para)
+	} else {
+		para.remove()
+		p.tip = para.parent
+	}
+	table := p.addChild(tableBlock)
+	table.align = align
+	table.rows = [][]string{header}
+	p.offset = len(p.line)
+	return true
+}
+
+// splitTableRow returns the raw cells of the table row `line`, split on the pipes not escaped.
+// The pipes at the start and end of the row are optional.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			b.WriteByte('|')
+			i++
+		case c == '|':
+			cells = append(cells, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(b.String()))
+}
+
+// parseListMarker parses the list item marker at the current position of the line, consuming
+// it and the spaces following it. It returns the properties of the list item, nil if the line
+// does not start a list item in `container`.
+func (p *blockParser) parseListMarker(container *block) *block {
+	if p.indent >= 4 {
+		return nil
+	}
+	rest := p.line[p.nextNonspace:]
+	item := &block{kind: itemBlock}
+	markerLength := 1
+	switch {
+	case rest[0] == '*' || rest[0] == '+' || rest[0] == '-':
+		item.marker = rest[0]
+	default:
+		m := orderedMarkerRe.FindStringSubmatch(rest)
+		if m == nil {
+			return nil
+		}
+		start, _ := strconv.Atoi(m[1])
+		if container.kind == paragraphBlock && start != 1 {
+			return nil
+		}
+		item.ordered = true
+		item.start = start
+		item.marker = m[2][0]
+		markerLength = len(m[0])
+	}
+	next := byte(0)
+	if markerLength < len(rest) {
+		next = rest[markerLength]
+	}
+	if next != 0 && next != ' ' {
+		return nil
+	}
+	// An empty list item cannot interrupt a paragraph.
+	if container.kind == paragraphBlock && strings.TrimSpace(rest[markerLength:]) == "" {
+		return nil
+	}
+
+	markerOffset := p.indent
+	p.advanceNextNonspace()
+	p.advance(markerLength)
+	spacesStart := p.offset
+	spaces := 0
+	for spaces < 5 && p.peek(p.offset) == ' ' {
+		p.advance(1)
+		spaces++
+	}
+	blankItem := p.offset == len(p.line)
+	padding := markerLength + spaces
+	if spaces >= 5 || spaces < 1 || blankItem {
+		padding = markerLength + 1
+		p.offset = spacesStart
+		if p.peek(p.offset) == ' ' {
+			p.advance(1)
+		}
+	}
+	item.contentIndent = markerOffset + padding
+	return item
+}
+
+var (
+	referenceLabelRe = regexp.MustCompile(`^\[((?:[^\\\[\]]|\\.){0,999})\]:`)
+	titleRe          = regexp.MustCompile(`^(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|\(((?:[^()\\]|\\.)*)\))`)
+)
+
+// parseReferences parses the link reference definitions at the start of the content `content`
+// of a paragraph, returning the rest of the content.
+func (p *blockParser) parseReferences(content string) string {
+	for strings.HasPrefix(content, "[") {
+		m := referenceLabelRe.FindStringSubmatch(content)
+		if m == nil || strings.TrimSpace(m[1]) == "" {
+			break
+		}
+		rest := skipSpaces(content[len(m[0]):], true)
+		dest, n, ok := parseLinkDestination(rest)
+		if !ok || (dest == "" && !strings.HasPrefix(rest, "<")) {
+			break
+		}
+		rest = rest[n:]
+
+		// The title is optional, and separated from the destination by whitespace.
+		title := ""
+		afterDest := rest
+		afterSpaces := skipSpaces(rest, true)
+		if len(afterSpaces) < len(rest) {
+			if t := titleRe.FindStringSubmatch(afterSpaces); t != nil {
+				lineEnd := skipSpaces(afterSpaces[len(t[0]):], false)
+				if lineEnd == "" || lineEnd[0] == '\n' {
+					title = unescapeString(t[1] + t[2] + t[3])
+					rest = lineEnd
+				}
+			}
+		}
+		if len(rest) == len(afterDest) {
+			lineEnd := skipSpaces(rest, false)
+			if lineEnd != "" && lineEnd[0] != '\n' {
+				break
+			}
+			rest = lineEnd
+		}
+		rest = strings.TrimPrefix(rest, "\n")
+
+		label := normalizeLabel(m[1])
+		if _, ok := p.refs[label]; !ok {
+			p.refs[label] = linkReference{dest: dest, title: title}
+		}
+		content = rest
+	}
+	return content
+}
+
+// skipSpaces returns `s` without its leading spaces, and at most one line feed if `newline`.
+func skipSpaces(s string, newline bool) string {
+	s = strings.TrimLeft(s, " ")
+	if newline && strings.HasPrefix(s, "\n") {
+		s = strings.TrimLeft(s[1:], " ")
+	}
+	return s
+}
+
+// normalizeLabel returns the normalized link label `label`: case folded, with the whitespace
+// collapsed.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
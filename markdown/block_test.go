@@ -0,0 +1,86 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dumpBlocks returns the children of `b` as a string: the containers with their children in
+// parentheses and the content of the leaves quoted.
+func dumpBlocks(b *block) string {
+	var parts []string
+	for _, child := range b.children {
+		var part string
+		switch child.kind {
+		case paragraphBlock:
+			part = fmt.Sprintf("p%q", child.content)
+		case headingBlock:
+			part = fmt.Sprintf("h%d%q", child.level, child.content)
+		case thematicBreakBlock:
+			part = "hr"
+		case codeBlock:
+			part = fmt.Sprintf("code[%s]%q", child.info, child.content)
+		case htmlBlock:
+			part = "html"
+		case quoteBlock:
+			part = "quote(" + dumpBlocks(child) + ")"
+		case listBlock:
+			kind := "ul"
+			if child.ordered {
+				kind = fmt.Sprintf("ol%d", child.start)
+			}
+			if !child.tight {
+				kind += "-loose"
+			}
+			part = kind + "(" + dumpBlocks(child) + ")"
+		case itemBlock:
+			part = "li(" + dumpBlocks(child) + ")"
+		case tableBlock:
+			part = fmt.Sprintf("table%v%q", child.align, child.rows)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseBlocks(t *testing.T) {
+	testcases := []struct {
+		src      string
+		expected string
+	}{
+		{"# Title #\n\nSome\ntext.\n", `h1"Title" p"Some\ntext."`},
+		{"Title\n===\nSub\n---\n", `h1"Title" h2"Sub"`},
+		{"a\n***\n- - -\n", `p"a" hr hr`},
+		{"```go\nfunc f() {\n\n}\n```\nafter", `code[go]"func f() {\n\n}\n" p"after"`},
+		{"    code\n      more\n\n    end\n\ntext", `code[]"code\n  more\n\nend\n" p"text"`},
+		{"> quote\nlazy\n> > nested", `quote(p"quote\nlazy" quote(p"nested"))`},
+		{"- a\n- b\n\n1. c\n2) d", `ul(li(p"a") li(p"b")) ol1(li(p"c")) ol2(li(p"d"))`},
+		{"- a\n\n- b", `ul-loose(li(p"a") li(p"b"))`},
+		{"- a\n  - b\n    c\n- d\n  ```\n  x\n  ```", `ul(li(p"a" ul(li(p"b\nc"))) li(p"d" code[]"x\n"))`},
+		{"text\n2. not a list\n- but a list", `p"text\n2. not a list" ul(li(p"but a list"))`},
+		{"<div>\n*html*\n</div>\n\ntext <b>x</b>", `html p"text <b>x</b>"`},
+		{"| a | b |\n|:--|--:|\n| 1 | 2 \\| 3 |\n\nafter", `table[1 3][["a" "b"] ["1" "2 | 3"]] p"after"`},
+		{"intro\na | b\n--- | :-:\nc | d", `p"intro" table[0 2][["a" "b"] ["c" "d"]]`},
+	}
+	for _, tc := range testcases {
+		doc, _ := parseBlocks(tc.src)
+		require.Equal(t, tc.expected, dumpBlocks(doc), tc.src)
+	}
+}
+
+func TestParseReferences(t *testing.T) {
+	doc, refs := parseBlocks("[Foo  Bar]: /url \"Title\"\n[baz]:\n  <my url>\n[foo bar]: /ignored\ntext\n")
+	require.Equal(t, `p"text"`, dumpBlocks(doc))
+	require.Equal(t, map[string]linkReference{
+		"foo bar": {dest: "/url", title: "Title"},
+		"baz":     {dest: "my url"},
+	}, refs)
+}
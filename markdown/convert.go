@@ -0,0 +1,582 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/creator"
+)
+
+// Converter converts Markdown documents to the drawables of a creator.
+type Converter struct {
+	c *creator.Creator
+
+	// Theme is the style of the converted documents.
+	Theme *Theme
+
+	// BaseDir is the directory the relative paths of the images are resolved from.
+	BaseDir string
+}
+
+// NewConverter returns a converter creating the drawables of the creator `c` with the default
+// theme.
+func NewConverter(c *creator.Creator) *Converter {
+	return &Converter{c: c, Theme: DefaultTheme()}
+}
+
+// Convert converts the Markdown document read from `r` to drawables, to be drawn in order with
+// Creator.Draw. The headings of the first levels of the theme start chapters, which contain the
+// drawables following them.
+func (conv *Converter) Convert(r io.Reader) ([]creator.Drawable, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return conv.ConvertString(string(src))
+}
+
+// ConvertString converts the Markdown document `src` to drawables, to be drawn in order with
+// Creator.Draw.
+func (conv *Converter) ConvertString(src string) ([]creator.Drawable, error) {
+	doc, refs := parseBlocks(src)
+	r := &renderer{conv: conv, theme: conv.Theme, refs: refs}
+	if err := r.document(doc); err != nil {
+		return nil, err
+	}
+	return r.items, nil
+}
+
+// Draw converts the Markdown document read from `r` and draws it with the creator.
+func (conv *Converter) Draw(r io.Reader) error {
+	drawables, err := conv.Convert(r)
+	if err != nil {
+		return err
+	}
+	for _, d := range drawables {
+		if err := conv.c.Draw(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderer lays out the blocks of a Markdown document.
+type renderer struct {
+	conv  *Converter
+	theme *Theme
+	refs  map[string]linkReference
+
+	// items are the drawables of the document, and chapters the chapters open at the current
+	// block, by level.
+	items    []creator.Drawable
+	chapters []openChapter
+}
+
+// openChapter is a chapter of the heading level `level`.
+type openChapter struct {
+	chapter *creator.Chapter
+	level   int
+}
+
+// inlineStyle is the style of the inline content being laid out.
+type inlineStyle struct {
+	base   creator.TextStyle
+	bold   bool
+	italic bool
+	strike bool
+	code   bool
+	link   string
+	isLink bool
+}
+
+// document lays out the blocks of the document `doc`.
+func (r *renderer) document(doc *block) error {
+	base := r.textStyle()
+	for _, b := range doc.children {
+		if b.kind == headingBlock && b.level <= r.theme.ChapterLevels {
+			r.chapter(b)
+			continue
+		}
+		drawables, err := r.block(b, base, false)
+		if err != nil {
+			return err
+		}
+		for _, d := range drawables {
+			if err := r.add(d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// add adds the drawable `d` to the current chapter, or to the drawables of the document.
+func (r *renderer) add(d creator.Drawable) error {
+	if n := len(r.chapters); n > 0 {
+		return r.chapters[n-1].chapter.Add(d)
+	}
+	r.items = append(r.items, d)
+	return nil
+}
+
+// chapter starts the chapter of the heading `b`, a subchapter of the closest chapter of a lower
+// level.
+func (r *renderer) chapter(b *block) {
+	title := strings.TrimSpace(plainText(parseInlines(b.content, r.refs)))
+	for n := len(r.chapters); n > 0 && r.chapters[n-1].level >= b.level; n-- {
+		r.chapters = r.chapters[:n-1]
+	}
+
+	var chapter *creator.Chapter
+	if n := len(r.chapters); n > 0 {
+		chapter = r.chapters[n-1].chapter.NewSubchapter(title)
+	} else {
+		chapter = r.conv.c.NewChapter(title)
+		r.items = append(r.items, chapter)
+	}
+	chapter.SetShowNumbering(r.theme.NumberChapters)
+	r.chapters = append(r.chapters, openChapter{chapter: chapter, level: b.level})
+
+	heading := chapter.GetHeading()
+	heading.SetFont(r.theme.font(true, false))
+	heading.SetFontSize(r.theme.HeadingSizes[b.level-1])
+	heading.SetLineHeight(r.theme.LineHeight)
+	if r.theme.HeadingColor != nil {
+		heading.SetColor(r.theme.HeadingColor)
+	}
+	heading.SetMargins(0, 0, r.theme.HeadingSpacing, r.theme.ParagraphSpacing)
+}
+
+// textStyle returns the style of the body text.
+func (r *renderer) textStyle() creator.TextStyle {
+	style := r.conv.c.NewTextStyle()
+	style.Font = r.theme.font(false, false)
+	style.FontSize = r.theme.FontSize
+	if r.theme.TextColor != nil {
+		style.Color = r.theme.TextColor
+	}
+	return style
+}
+
+// block lays out the block `b` with the text style `base`. The paragraphs of tight lists are
+// not spaced.
+func (r *renderer) block(b *block, base creator.TextStyle, tight bool) ([]creator.Drawable, error) {
+	spacing := r.theme.ParagraphSpacing
+	switch b.kind {
+	case paragraphBlock:
+		inlines := parseInlines(b.content, r.refs)
+		if len(inlines) == 1 && inlines[0].kind == imageInline {
+			img, err := r.image(inlines[0])
+			if err != nil {
+				return nil, err
+			}
+			if img != nil {
+				img.SetMargins(0, 0, 0, spacing)
+				return []creator.Drawable{img}, nil
+			}
+		}
+		p := r.paragraph(inlines, base)
+		if tight {
+			spacing = 0
+		}
+		p.SetMargins(0, 0, 0, spacing)
+		return []creator.Drawable{p}, nil
+
+	case headingBlock:
+		base.Font = r.theme.font(true, false)
+		base.FontSize = r.theme.HeadingSizes[b.level-1]
+		if r.theme.HeadingColor != nil {
+			base.Color = r.theme.HeadingColor
+		}
+		p := r.paragraph(parseInlines(b.content, r.refs), base)
+		p.SetMargins(0, 0, r.theme.HeadingSpacing, spacing)
+		p.SetKeepWithNext(true)
+		return []creator.Drawable{p}, nil
+
+	case thematicBreakBlock:
+		return []creator.Drawable{r.rule()}, nil
+
+	case codeBlock:
+		table, err := r.codeBlock(b, base)
+		if err != nil {
+			return nil, err
+		}
+		return []creator.Drawable{table}, nil
+
+	case quoteBlock:
+		table, err := r.quote(b, base)
+		if err != nil || table == nil {
+			return nil, err
+		}
+		return []creator.Drawable{table}, nil
+
+	case listBlock:
+		list, err := r.list(b, base, 0)
+		if err != nil {
+			return nil, err
+		}
+		list.SetMargins(r.theme.ListIndent, 0, 0, spacing)
+		return []creator.Drawable{list}, nil
+
+	case tableBlock:
+		table, err := r.table(b, base)
+		if err != nil {
+			return nil, err
+		}
+		return []creator.Drawable{table}, nil
+	}
+
+	// The HTML blocks are not displayed.
+	return nil, nil
+}
+
+// blocks lays out the blocks `blocks` as a single drawable: the only drawable or a division of
+// the drawables, nil if empty. The bottom margin of the last drawable is removed.
+func (r *renderer) blocks(blocks []*block, base creator.TextStyle, tight bool, level int) (creator.VectorDrawable, error) {
+	var drawables []creator.VectorDrawable
+	for _, b := range blocks {
+		var ds []creator.Drawable
+		var err error
+		if b.kind == listBlock {
+			var list *creator.List
+			if list, err = r.list(b, base, level+1); err == nil {
+				list.SetMargins(0, 0, 0, r.theme.ParagraphSpacing)
+				ds = []creator.Drawable{list}
+			}
+		} else {
+			ds, err = r.block(b, base, tight)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range ds {
+			if vd, ok := d.(creator.VectorDrawable); ok {
+				drawables = append(drawables, vd)
+			}
+		}
+	}
+	if len(drawables) == 0 {
+		return nil, nil
+	}
+	removeBottomMargin(drawables[len(drawables)-1])
+	if len(drawables) == 1 {
+		return drawables[0], nil
+	}
+
+	div := r.conv.c.NewDivision()
+	for _, d := range drawables {
+		if err := div.Add(d); err != nil {
+			return nil, err
+		}
+	}
+	return div, nil
+}
+
+// removeBottomMargin sets the bottom margin of the drawable `d` to 0.
+func removeBottomMargin(d creator.VectorDrawable) {
+	switch t := d.(type) {
+	case *creator.StyledParagraph:
+		left, right, top, _ := t.GetMargins()
+		t.SetMargins(left, right, top, 0)
+	case *creator.Table:
+		left, right, top, _ := t.GetMargins()
+		t.SetMargins(left, right, top, 0)
+	case *creator.List:
+		left, right, top, _ := t.Margins()
+		t.SetMargins(left, right, top, 0)
+	case *creator.Image:
+		left, right, top, _ := t.GetMargins()
+		t.SetMargins(left, right, top, 0)
+	case *creator.Division:
+		left, right, top, _ := t.GetMargins()
+		t.SetMargins(left, right, top, 0)
+	}
+}
+
+// paragraph returns a paragraph of the inline content `inlines`, of the text style `base`.
+func (r *renderer) paragraph(inlines []*inline, base creator.TextStyle) *creator.StyledParagraph {
+	p := r.conv.c.NewStyledParagraph()
+	p.SetLineHeight(r.theme.LineHeight)
+	r.appendInlines(p, inlines, inlineStyle{base: base})
+	if len(inlines) == 0 {
+		p.Append(" ").Style = base
+	}
+	return p
+}
+
+// appendInlines appends the chunks of the inline content `inlines` of style `s` to `p`.
+func (r *renderer) appendInlines(p *creator.StyledParagraph, inlines []*inline, s inlineStyle) {
+	for _, n := range inlines {
+		switch n.kind {
+		case textInline:
+			r.appendText(p, n.text, s)
+		case softBreakInline:
+			r.appendText(p, " ", s)
+		case hardBreakInline:
+			r.appendText(p, "\n", s)
+		case codeInline:
+			cs := s
+			cs.code = true
+			r.appendText(p, n.text, cs)
+		case emphasisInline, strongInline, strikethroughInline:
+			cs := s
+			cs.italic = cs.italic || n.kind == emphasisInline
+			cs.bold = cs.bold || n.kind == strongInline
+			cs.strike = cs.strike || n.kind == strikethroughInline
+			r.appendInlines(p, n.children, cs)
+		case linkInline:
+			cs := s
+			if !s.isLink {
+				cs.isLink = true
+				cs.link = n.dest
+			}
+			r.appendInlines(p, n.children, cs)
+		case imageInline:
+			// The images in text are displayed as their description.
+			cs := s
+			cs.italic = true
+			r.appendText(p, plainText(n.children), cs)
+		}
+	}
+}
+
+// appendText appends a chunk of the text `text` of style `s` to `p`.
+func (r *renderer) appendText(p *creator.StyledParagraph, text string, s inlineStyle) {
+	if text == "" {
+		return
+	}
+	var chunk *creator.TextChunk
+	if s.isLink && s.link != "" && !strings.HasPrefix(s.link, "#") {
+		chunk = p.AddExternalLink(text, s.link)
+	} else {
+		chunk = p.Append(text)
+	}
+
+	style := s.base
+	style.Font = r.theme.font(s.bold, s.italic)
+	if s.code {
+		style.Font = r.theme.CodeFont
+		style.FontSize *= r.theme.CodeFontScale
+		if r.theme.CodeColor != nil {
+			style.Color = r.theme.CodeColor
+		}
+		style.BackgroundColor = r.theme.CodeBackground
+	}
+	if s.isLink {
+		if r.theme.LinkColor != nil {
+			style.Color = r.theme.LinkColor
+		}
+		style.Underline = r.theme.LinkUnderline
+	}
+	style.StrikeThrough = s.strike
+	chunk.Style = style
+}
+
+// codeBlock returns the code block `b` as a table of a single cell.
+func (r *renderer) codeBlock(b *block, base creator.TextStyle) (*creator.Table, error) {
+	style := base
+	style.Font = r.theme.CodeFont
+	style.FontSize *= r.theme.CodeFontScale
+	if r.theme.CodeColor != nil {
+		style.Color = r.theme.CodeColor
+	}
+	text := strings.TrimSuffix(b.content, "\n")
+	if text == "" {
+		text = " "
+	}
+
+	p := r.conv.c.NewStyledParagraph()
+	p.SetLineHeight(r.theme.LineHeight)
+	p.Append(text).Style = style
+	padding := r.theme.CodeBlockPadding
+	p.SetMargins(0, padding, padding, padding)
+
+	table := r.conv.c.NewTable(1)
+	cell := table.NewCell()
+	cell.SetIndent(padding)
+	if r.theme.CodeBackground != nil {
+		cell.SetBackgroundColor(r.theme.CodeBackground)
+	}
+	if err := cell.SetContent(p); err != nil {
+		return nil, err
+	}
+	table.SetMargins(0, 0, 0, r.theme.ParagraphSpacing)
+	return table, nil
+}
+
+// quote returns the block quote `b` as a table of a single cell with a left border.
+func (r *renderer) quote(b *block, base creator.TextStyle) (*creator.Table, error) {
+	if r.theme.QuoteColor != nil {
+		base.Color = r.theme.QuoteColor
+	}
+	content, err := r.blocks(b.children, base, false, 0)
+	if err != nil || content == nil {
+		return nil, err
+	}
+
+	table := r.conv.c.NewTable(1)
+	cell := table.NewCell()
+	cell.SetIndent(r.theme.QuoteIndent)
+	if r.theme.QuoteBarWidth > 0 {
+		cell.SetBorder(creator.CellBorderSideLeft, creator.CellBorderStyleSingle, r.theme.QuoteBarWidth)
+		if r.theme.QuoteBarColor != nil {
+			cell.SetBorderColor(r.theme.QuoteBarColor)
+		}
+	}
+	if err := cell.SetContent(content); err != nil {
+		return nil, err
+	}
+	table.SetMargins(0, 0, 0, r.theme.ParagraphSpacing)
+	return table, nil
+}
+
+// list returns the list `b` of the nesting level `level`.
+func (r *renderer) list(b *block, base creator.TextStyle, level int) (*creator.List, error) {
+	list := r.conv.c.NewList()
+	number := b.start
+	for _, item := range b.children {
+		content, err := r.blocks(item.children, base, b.tight, level)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			p := r.conv.c.NewStyledParagraph()
+			p.Append(" ").Style = base
+			content = p
+		}
+
+		marker, err := list.Add(content)
+		if err != nil {
+			return nil, err
+		}
+		marker.Style = base
+		if b.ordered {
+			marker.Text = strconv.Itoa(number) + string(b.marker) + " "
+			number++
+		} else if len(r.theme.Bullets) > 0 {
+			bullet := r.theme.Bullets[level%len(r.theme.Bullets)]
+			if _, ok := base.Font.GetRuneMetrics([]rune(bullet)[0]); !ok {
+				bullet = "-"
+			}
+			marker.Text = bullet + " "
+		}
+	}
+	return list, nil
+}
+
+// table returns the table `b`, the cells of its first row being header cells repeated on the
+// pages the table continues on.
+func (r *renderer) table(b *block, base creator.TextStyle) (*creator.Table, error) {
+	cols := len(b.align)
+	table := r.conv.c.NewTable(cols)
+	padding := r.theme.TableCellPadding
+	for i, row := range b.rows {
+		style := base
+		if i == 0 {
+			style.Font = r.theme.font(true, false)
+		}
+		for col := 0; col < cols; col++ {
+			text := ""
+			if col < len(row) {
+				text = row[col]
+			}
+			p := r.paragraph(parseInlines(text, r.refs), style)
+			p.SetMargins(0, padding, padding, padding)
+			switch b.align[col] {
+			case alignCenter:
+				p.SetTextAlignment(creator.TextAlignmentCenter)
+			case alignRight:
+				p.SetTextAlignment(creator.TextAlignmentRight)
+			}
+
+			cell := table.NewCell()
+			cell.SetIndent(padding)
+			if r.theme.TableBorderWidth > 0 {
+				cell.SetBorder(creator.CellBorderSideAll, creator.CellBorderStyleSingle, r.theme.TableBorderWidth)
+				if r.theme.TableBorderColor != nil {
+					cell.SetBorderColor(r.theme.TableBorderColor)
+				}
+			}
+			if i == 0 && r.theme.TableHeaderBackground != nil {
+				cell.SetBackgroundColor(r.theme.TableHeaderBackground)
+			}
+			if err := cell.SetContent(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := table.SetHeaderRows(1, 1); err != nil {
+		return nil, err
+	}
+	table.SetMargins(0, 0, 0, r.theme.ParagraphSpacing)
+	return table, nil
+}
+
+// rule returns a thematic break, drawn as the bottom border of an empty table.
+func (r *renderer) rule() *creator.Table {
+	table := r.conv.c.NewTable(1)
+	cell := table.NewCell()
+	cell.SetBorder(creator.CellBorderSideBottom, creator.CellBorderStyleSingle, r.theme.RuleWidth)
+	if r.theme.RuleColor != nil {
+		cell.SetBorderColor(r.theme.RuleColor)
+	}
+	if err := table.SetRowHeight(1, r.theme.FontSize/2); err != nil {
+		common.Log.Debug("ERROR: unable to set the height of a rule: %v", err)
+	}
+	table.SetMargins(0, 0, 0, r.theme.FontSize/2+r.theme.ParagraphSpacing)
+	return table
+}
+
+// image returns the image `n`, nil if its source is a remote URL. The images wider than the
+// page are scaled down to its width.
+func (r *renderer) image(n *inline) (*creator.Image, error) {
+	src := n.dest
+	var img *creator.Image
+	var err error
+	if strings.HasPrefix(src, "data:") {
+		comma := strings.IndexByte(src, ',')
+		if comma < 0 || !strings.HasSuffix(src[:comma], ";base64") {
+			return nil, errors.New("unsupported image data URL")
+		}
+		data, err := base64.StdEncoding.DecodeString(src[comma+1:])
+		if err != nil {
+			return nil, err
+		}
+		if img, err = r.conv.c.NewImageFromData(data); err != nil {
+			return nil, err
+		}
+	} else {
+		path := src
+		if u, err := url.Parse(src); err == nil && u.Scheme == "file" {
+			path = u.Path
+		} else if err == nil && u.Scheme != "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.conv.BaseDir, filepath.FromSlash(path))
+		}
+		if img, err = r.conv.c.NewImageFromFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.theme.ImageScale > 0 {
+		img.Scale(r.theme.ImageScale, r.theme.ImageScale)
+	}
+	left, right, _, _ := r.conv.c.GetPageMargins()
+	if width := r.conv.c.Width() - left - right; img.Width() > width {
+		img.ScaleToWidth(width)
+	}
+	return img, nil
+}
@@ -0,0 +1,143 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/creator"
+	"github.com/carmel/unipdf/extractor"
+	"github.com/carmel/unipdf/model"
+)
+
+const testDocument = `Introduction text before the first heading.
+
+# Release notes
+
+Version **2.0** brings *many* improvements and ` + "`inline code`" + `.
+
+## Features
+
+- Fast rendering
+- Nested lists
+  1. first
+  2. second
+
+> A quoted remark.
+
+` + "```go" + `
+func main() {}
+` + "```" + `
+
+| Name | Value |
+|------|------:|
+| a    | 1     |
+
+#### Deep heading
+
+## Fixes
+
+---
+
+# Runbook
+`
+
+func TestConvert(t *testing.T) {
+	c := creator.New()
+	c.AddTOC = true
+	conv := NewConverter(c)
+	conv.Theme.NumberChapters = true
+	drawables, err := conv.ConvertString(testDocument)
+	require.NoError(t, err)
+
+	// The content before the first heading is not in a chapter.
+	require.Len(t, drawables, 3)
+	require.IsType(t, &creator.StyledParagraph{}, drawables[0])
+	require.IsType(t, &creator.Chapter{}, drawables[1])
+	require.IsType(t, &creator.Chapter{}, drawables[2])
+	require.Equal(t, "1. Release notes", drawables[1].(*creator.Chapter).GetHeading().Text())
+	require.Equal(t, "2. Runbook", drawables[2].(*creator.Chapter).GetHeading().Text())
+
+	for _, d := range drawables {
+		require.NoError(t, c.Draw(d))
+	}
+	var titles []string
+	for _, line := range c.TOC().Lines() {
+		titles = append(titles, line.Number.Text+" "+line.Title.Text)
+	}
+	require.Equal(t, []string{"1. Release notes", "1.1. Features", "1.2. Fixes", "2. Runbook"}, titles)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	r, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	outlines, err := r.GetOutlines()
+	require.NoError(t, err)
+	// The first outline item is the table of contents.
+	require.Len(t, outlines.Entries, 3)
+	require.Equal(t, "1. Release notes", outlines.Entries[1].Title)
+	require.Len(t, outlines.Entries[1].Entries, 2)
+
+	// The table of contents is the first page.
+	e, err := extractor.New(readPage(t, buf.Bytes(), 2))
+	require.NoError(t, err)
+	text, err := e.ExtractText()
+	require.NoError(t, err)
+	for _, s := range []string{"Introduction text", "Release notes", "inline code", "Nested lists",
+		"second", "A quoted remark.", "func main() {}", "Value", "Deep heading", "Runbook"} {
+		require.Contains(t, text, s)
+	}
+}
+
+func TestConvertInlineStyles(t *testing.T) {
+	c := creator.New()
+	drawables, err := NewConverter(c).ConvertString("plain **bold *both*** `code` [link](https://example.com)")
+	require.NoError(t, err)
+	require.Len(t, drawables, 1)
+	require.NoError(t, c.Draw(drawables[0]))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	page := readPage(t, buf.Bytes(), 1)
+	e, err := extractor.New(page)
+	require.NoError(t, err)
+	pageText, _, _, err := e.ExtractPageText()
+	require.NoError(t, err)
+
+	// The text drawn with each font, and the font sizes.
+	text := map[string]string{}
+	sizes := map[string]float64{}
+	for _, mark := range pageText.Marks().Elements() {
+		if mark.Font == nil {
+			continue
+		}
+		name := mark.Font.BaseFont()
+		text[name] += mark.Text
+		sizes[name] = mark.FontSize
+	}
+	require.Equal(t, "plainlink", strings.ReplaceAll(text["Helvetica"], " ", ""))
+	require.Equal(t, "bold", strings.TrimSpace(text["Helvetica-Bold"]))
+	require.Equal(t, "both", text["Helvetica-BoldOblique"])
+	require.Equal(t, "code", text["Courier"])
+	require.Equal(t, 9.0, sizes["Courier"])
+
+	annotations, err := page.GetAnnotations()
+	require.NoError(t, err)
+	require.Len(t, annotations, 1)
+}
+
+// readPage returns the page `pageNum` of the PDF `data`.
+func readPage(t *testing.T, data []byte, pageNum int) *model.PdfPage {
+	r, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	page, err := r.GetPage(pageNum)
+	require.NoError(t, err)
+	return page
+}
@@ -0,0 +1,29 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package markdown converts Markdown documents to the drawables of a creator.
+//
+// The documents are parsed following CommonMark: ATX and setext headings, paragraphs, block
+// quotes, bullet and ordered lists, fenced and indented code blocks, thematic breaks, emphasis,
+// code spans, links, images, autolinks and link reference definitions, with the tables and
+// strikethrough of GitHub Flavored Markdown. Raw HTML is not displayed, except line breaks.
+//
+// The headings of the first levels, set by Theme.ChapterLevels, are laid out as chapters of the
+// creator, which contain the blocks following them. The chapters are added to the outline of the
+// document, and to its table of contents when Creator.AddTOC is set. The other blocks are laid
+// out as styled paragraphs, lists, tables (for block quotes, code blocks and thematic breaks as
+// well) and images, with the styles of the Theme of the Converter.
+//
+// Example:
+//
+//	c := creator.New()
+//	c.AddTOC = true
+//	conv := markdown.NewConverter(c)
+//	conv.Theme.NumberChapters = true
+//	if err := conv.Draw(strings.NewReader(src)); err != nil {
+//		return err
+//	}
+//	return c.WriteToFile("output.pdf")
+package markdown
@@ -0,0 +1,554 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	stdhtml "html"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// inlineKind is the kind of an inline element of a Markdown document.
+type inlineKind int
+
+const (
+	textInline inlineKind = iota
+	softBreakInline
+	hardBreakInline
+	codeInline
+	emphasisInline
+	strongInline
+	strikethroughInline
+	linkInline
+	imageInline
+)
+
+// inline is an inline element of a Markdown document.
+type inline struct {
+	kind     inlineKind
+	text     string
+	children []*inline
+
+	// dest and title are the destination and title of links and images.
+	dest  string
+	title string
+
+	// delim is the character of the delimiter runs ('*', '_' or '~') and of the link openers
+	// ('[' or '!') not resolved yet, 0 for other elements.
+	delim    byte
+	count    int
+	original int
+	canOpen  bool
+	canClose bool
+
+	// active link openers can still be matched, and start at the offset `offset` of the source.
+	active bool
+	offset int
+}
+
+// inlineParser parses the inline content of paragraphs, headings and table cells.
+type inlineParser struct {
+	src   string
+	pos   int
+	refs  map[string]linkReference
+	nodes []*inline
+}
+
+// parseInlines parses the inline content `src`, resolving the reference links with `refs`.
+func parseInlines(src string, refs map[string]linkReference) []*inline {
+	p := &inlineParser{src: src, refs: refs}
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; c {
+		case '\n':
+			p.parseNewline()
+		case '\\':
+			p.parseBackslash()
+		case '`':
+			p.parseCodeSpan()
+		case '*', '_', '~':
+			p.parseDelimiters(c)
+		case '[':
+			p.nodes = append(p.nodes, &inline{text: "[", delim: '[', active: true, offset: p.pos + 1})
+			p.pos++
+		case '!':
+			if strings.HasPrefix(p.src[p.pos:], "![") {
+				p.nodes = append(p.nodes, &inline{text: "![", delim: '!', active: true, offset: p.pos + 2})
+				p.pos += 2
+			} else {
+				p.addText("!")
+				p.pos++
+			}
+		case ']':
+			p.parseCloseBracket()
+		case '<':
+			p.parseAngle()
+		case '&':
+			p.parseEntity()
+		default:
+			end := strings.IndexAny(p.src[p.pos:], "\n\\`*_~[]!<&")
+			if end < 0 {
+				end = len(p.src) - p.pos
+			}
+			p.addText(p.src[p.pos : p.pos+end])
+			p.pos += end
+		}
+	}
+	return mergeText(processEmphasis(p.nodes))
+}
+
+// addText appends the text `text` to the parsed elements.
+func (p *inlineParser) addText(text string) {
+	if n := len(p.nodes); n > 0 && p.nodes[n-1].kind == textInline && p.nodes[n-1].delim == 0 {
+		p.nodes[n-1].text += text
+		return
+	}
+	p.nodes = append(p.nodes, &inline{text: text})
+}
+
+// parseNewline parses a line ending: a hard break if preceded by two spaces or more, a soft break
+// otherwise.
+func (p *inlineParser) parseNewline() {
+	kind := softBreakInline
+	if n := len(p.nodes); n > 0 && p.nodes[n-1].kind == textInline && p.nodes[n-1].delim == 0 {
+		last := p.nodes[n-1]
+		if strings.HasSuffix(last.text, "  ") {
+			kind = hardBreakInline
+		}
+		last.text = strings.TrimRight(last.text, " ")
+	}
+	p.nodes = append(p.nodes, &inline{kind: kind})
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// parseBackslash parses a backslash escape, or a hard break if followed by a line ending.
+func (p *inlineParser) parseBackslash() {
+	p.pos++
+	switch {
+	case p.pos < len(p.src) && p.src[p.pos] == '\n':
+		p.nodes = append(p.nodes, &inline{kind: hardBreakInline})
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+			p.pos++
+		}
+	case p.pos < len(p.src) && isASCIIPunct(p.src[p.pos]):
+		p.addText(p.src[p.pos : p.pos+1])
+		p.pos++
+	default:
+		p.addText(`\`)
+	}
+}
+
+// parseCodeSpan parses a code span, or a literal run of backticks if not closed.
+func (p *inlineParser) parseCodeSpan() {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == '`' {
+		p.pos++
+	}
+	ticks := p.src[start:p.pos]
+	for i := p.pos; i < len(p.src); {
+		j := strings.Index(p.src[i:], ticks)
+		if j < 0 {
+			break
+		}
+		j += i
+		end := j + len(ticks)
+		if end < len(p.src) && p.src[end] == '`' || j > 0 && p.src[j-1] == '`' {
+			// A longer run of backticks.
+			for end < len(p.src) && p.src[end] == '`' {
+				end++
+			}
+			i = end
+			continue
+		}
+		code := strings.ReplaceAll(p.src[p.pos:j], "\n", " ")
+		if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		p.nodes = append(p.nodes, &inline{kind: codeInline, text: code})
+		p.pos = end
+		return
+	}
+	p.addText(ticks)
+}
+
+// parseDelimiters parses a run of the delimiter `c`, which can open and/or close emphasis or
+// strikethrough depending on the characters around it.
+func (p *inlineParser) parseDelimiters(c byte) {
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+	}
+	count := p.pos - start
+	if c == '~' && count > 2 {
+		p.addText(p.src[start:p.pos])
+		return
+	}
+
+	before, after := ' ', ' '
+	if start > 0 {
+		before, _ = utf8.DecodeLastRuneInString(p.src[:start])
+	}
+	if p.pos < len(p.src) {
+		after, _ = utf8.DecodeRuneInString(p.src[p.pos:])
+	}
+	leftFlanking := !unicode.IsSpace(after) &&
+		(!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	rightFlanking := !unicode.IsSpace(before) &&
+		(!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+	canOpen, canClose := leftFlanking, rightFlanking
+	if c == '_' {
+		canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	}
+	p.nodes = append(p.nodes, &inline{
+		text:     p.src[start:p.pos],
+		delim:    c,
+		count:    count,
+		original: count,
+		canOpen:  canOpen,
+		canClose: canClose,
+	})
+}
+
+// parseCloseBracket parses a closing bracket, ending a link or an image if it matches an opener
+// and is followed by a destination or a reference.
+func (p *inlineParser) parseCloseBracket() {
+	opener := -1
+	for i := len(p.nodes) - 1; i >= 0; i-- {
+		if d := p.nodes[i].delim; d == '[' || d == '!' {
+			opener = i
+			break
+		}
+	}
+	if opener < 0 {
+		p.addText("]")
+		p.pos++
+		return
+	}
+	o := p.nodes[opener]
+	if !o.active {
+		o.delim = 0
+		p.addText("]")
+		p.pos++
+		return
+	}
+
+	label := p.src[o.offset:p.pos]
+	after := p.pos + 1
+	dest, title, end, ok := p.parseLinkTail(after)
+	if !ok {
+		// Full, collapsed or shortcut reference link.
+		ref := label
+		end = after
+		if after < len(p.src) && p.src[after] == '[' {
+			if close := strings.IndexByte(p.src[after:], ']'); close > 0 {
+				if l := p.src[after+1 : after+close]; l != "" {
+					ref = l
+				}
+				end = after + close + 1
+			}
+		}
+		var r linkReference
+		r, ok = p.refs[normalizeLabel(ref)]
+		if ok && strings.TrimSpace(ref) != "" {
+			dest, title = r.dest, r.title
+		} else {
+			ok = false
+		}
+	}
+	if !ok {
+		o.delim = 0
+		p.addText("]")
+		p.pos++
+		return
+	}
+
+	kind := linkInline
+	if o.delim == '!' {
+		kind = imageInline
+	}
+	node := &inline{
+		kind:     kind,
+		dest:     dest,
+		title:    title,
+		children: mergeText(processEmphasis(append([]*inline(nil), p.nodes[opener+1:]...))),
+	}
+	p.nodes = append(p.nodes[:opener], node)
+	p.pos = end
+	if kind == linkInline {
+		// Links cannot contain other links.
+		for _, n := range p.nodes {
+			if n.delim == '[' {
+				n.active = false
+			}
+		}
+	}
+}
+
+// parseLinkTail parses the destination and title of an inline link, in parentheses at the
+// offset `pos`. The offset following the link is returned.
+func (p *inlineParser) parseLinkTail(pos int) (dest, title string, end int, ok bool) {
+	if pos >= len(p.src) || p.src[pos] != '(' {
+		return "", "", 0, false
+	}
+	rest := skipWhitespace(p.src[pos+1:])
+	if strings.HasPrefix(rest, ")") {
+		return "", "", len(p.src) - len(rest) + 1, true
+	}
+	dest, n, ok := parseLinkDestination(rest)
+	if !ok {
+		return "", "", 0, false
+	}
+	rest = rest[n:]
+	if trimmed := skipWhitespace(rest); len(trimmed) < len(rest) {
+		if t := titleRe.FindStringSubmatch(trimmed); t != nil {
+			title = unescapeString(t[1] + t[2] + t[3])
+			trimmed = trimmed[len(t[0]):]
+		}
+		rest = skipWhitespace(trimmed)
+	}
+	if !strings.HasPrefix(rest, ")") {
+		return "", "", 0, false
+	}
+	return dest, title, len(p.src) - len(rest) + 1, true
+}
+
+// skipWhitespace returns `s` without its leading spaces and line feeds.
+func skipWhitespace(s string) string {
+	return strings.TrimLeft(s, " \n")
+}
+
+// parseLinkDestination parses the link destination at the start of `s`, in angle brackets or
+// with balanced parentheses. It returns the destination and the length parsed.
+func parseLinkDestination(s string) (string, int, bool) {
+	if strings.HasPrefix(s, "<") {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '\n', '<':
+				return "", 0, false
+			case '>':
+				return unescapeString(s[1:i]), i + 1, true
+			}
+		}
+		return "", 0, false
+	}
+	depth := 0
+	i := 0
+loop:
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			i++
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case c <= ' ':
+			break loop
+		}
+	}
+	if depth != 0 {
+		return "", 0, false
+	}
+	return unescapeString(s[:i]), i, true
+}
+
+var (
+	autolinkRe   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9.+-]{1,31}:[^<> \x00-\x1f]*)>`)
+	emailRe      = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_` + "`" + `{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*)>`)
+	inlineHTMLRe = regexp.MustCompile(`^(?:<!--(?:[^-]|-[^-])*-->|</?([A-Za-z][A-Za-z0-9-]*)(?:\s+[A-Za-z_:][A-Za-z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>)`)
+	entityRe     = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+)
+
+// parseAngle parses an autolink or raw HTML. The raw HTML is not displayed, except line breaks.
+func (p *inlineParser) parseAngle() {
+	rest := p.src[p.pos:]
+	if m := autolinkRe.FindStringSubmatch(rest); m != nil {
+		p.nodes = append(p.nodes, &inline{kind: linkInline, dest: m[1], children: []*inline{{text: m[1]}}})
+		p.pos += len(m[0])
+		return
+	}
+	if m := emailRe.FindStringSubmatch(rest); m != nil {
+		p.nodes = append(p.nodes, &inline{kind: linkInline, dest: "mailto:" + m[1],
+			children: []*inline{{text: m[1]}}})
+		p.pos += len(m[0])
+		return
+	}
+	if m := inlineHTMLRe.FindStringSubmatch(rest); m != nil {
+		if strings.EqualFold(m[1], "br") {
+			p.nodes = append(p.nodes, &inline{kind: hardBreakInline})
+		}
+		p.pos += len(m[0])
+		return
+	}
+	p.addText("<")
+	p.pos++
+}
+
+// parseEntity parses an entity or numeric character reference.
+func (p *inlineParser) parseEntity() {
+	if m := entityRe.FindString(p.src[p.pos:]); m != "" {
+		p.addText(stdhtml.UnescapeString(m))
+		p.pos += len(m)
+		return
+	}
+	p.addText("&")
+	p.pos++
+}
+
+// processEmphasis resolves the delimiter runs of `nodes` to emphasis, strong emphasis and
+// strikethrough elements. The delimiters not matched remain as text.
+func processEmphasis(nodes []*inline) []*inline {
+	for i := 0; i < len(nodes); i++ {
+		closer := nodes[i]
+		if !isEmphasisDelimiter(closer) || !closer.canClose {
+			continue
+		}
+		for closer.count > 0 {
+			j := findOpener(nodes, i, closer)
+			if j < 0 {
+				break
+			}
+			opener := nodes[j]
+			use := 1
+			kind := emphasisInline
+			switch {
+			case closer.delim == '~':
+				use, kind = closer.count, strikethroughInline
+			case opener.count >= 2 && closer.count >= 2:
+				use, kind = 2, strongInline
+			}
+			opener.count -= use
+			closer.count -= use
+			opener.text = opener.text[:opener.count]
+			closer.text = closer.text[:closer.count]
+
+			node := &inline{kind: kind, children: append([]*inline(nil), nodes[j+1:i]...)}
+			rest := append([]*inline{node}, nodes[i:]...)
+			nodes = append(nodes[:j+1], rest...)
+			i = j + 2
+			if opener.count == 0 {
+				nodes = append(nodes[:j], nodes[j+1:]...)
+				i--
+			}
+		}
+		if closer.count == 0 {
+			nodes = append(nodes[:i], nodes[i+1:]...)
+			i--
+		}
+	}
+	return nodes
+}
+
+// isEmphasisDelimiter returns true if `n` is a delimiter run of emphasis or strikethrough.
+func isEmphasisDelimiter(n *inline) bool {
+	return n.delim == '*' || n.delim == '_' || n.delim == '~'
+}
+
+// findOpener returns the index of the closest delimiter run before the index `i` of `nodes`
+// which can be closed by `closer`, -1 if none.
+func findOpener(nodes []*inline, i int, closer *inline) int {
+	for j := i - 1; j >= 0; j-- {
+		opener := nodes[j]
+		if opener.delim != closer.delim || !opener.canOpen || opener.count == 0 {
+			continue
+		}
+		if closer.delim == '~' {
+			if opener.count == closer.count {
+				return j
+			}
+			continue
+		}
+		// The sum of the lengths of the runs cannot be a multiple of 3 if either can both open
+		// and close, unless both are.
+		if (opener.canClose || closer.canOpen) && (opener.original+closer.original)%3 == 0 &&
+			!(opener.original%3 == 0 && closer.original%3 == 0) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+// mergeText merges the adjacent text elements of `nodes` and drops the empty ones.
+func mergeText(nodes []*inline) []*inline {
+	var merged []*inline
+	for _, n := range nodes {
+		if n.kind == textInline {
+			if n.text == "" {
+				continue
+			}
+			if k := len(merged); k > 0 && merged[k-1].kind == textInline {
+				merged[k-1] = &inline{text: merged[k-1].text + n.text}
+				continue
+			}
+			n = &inline{text: n.text}
+		}
+		merged = append(merged, n)
+	}
+	return merged
+}
+
+// plainText returns the text of `nodes` without formatting.
+func plainText(nodes []*inline) string {
+	var b strings.Builder
+	for _, n := range nodes {
+		switch n.kind {
+		case textInline, codeInline:
+			b.WriteString(n.text)
+		case softBreakInline, hardBreakInline:
+			b.WriteByte(' ')
+		default:
+			b.WriteString(plainText(n.children))
+		}
+	}
+	return b.String()
+}
+
+// unescapeString resolves the backslash escapes and character references of `s`.
+func unescapeString(s string) string {
+	if !strings.ContainsAny(s, `\&`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteByte(s[i+1])
+			i++
+		case s[i] == '&':
+			if m := entityRe.FindString(s[i:]); m != "" {
+				b.WriteString(stdhtml.UnescapeString(m))
+				i += len(m) - 1
+				continue
+			}
+			b.WriteByte('&')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// isASCIIPunct returns true if `c` is an ASCII punctuation character.
+func isASCIIPunct(c byte) bool {
+	return c >= '!' && c <= '/' || c >= ':' && c <= '@' || c >= '[' && c <= '`' || c >= '{' && c <= '~'
+}
+
+// isPunct returns true if `r` is a punctuation or symbol character.
+func isPunct(r rune) bool {
+	return r < utf8.RuneSelf && isASCIIPunct(byte(r)) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
@@ -0,0 +1,73 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// dumpInlines returns `nodes` as a string: the elements with their children in parentheses and
+// the text quoted.
+func dumpInlines(nodes []*inline) string {
+	var parts []string
+	for _, n := range nodes {
+		var part string
+		switch n.kind {
+		case textInline:
+			part = fmt.Sprintf("%q", n.text)
+		case softBreakInline:
+			part = "sb"
+		case hardBreakInline:
+			part = "br"
+		case codeInline:
+			part = fmt.Sprintf("code%q", n.text)
+		case emphasisInline:
+			part = "em(" + dumpInlines(n.children) + ")"
+		case strongInline:
+			part = "strong(" + dumpInlines(n.children) + ")"
+		case strikethroughInline:
+			part = "del(" + dumpInlines(n.children) + ")"
+		case linkInline:
+			part = fmt.Sprintf("a[%s|%s](%s)", n.dest, n.title, dumpInlines(n.children))
+		case imageInline:
+			part = fmt.Sprintf("img[%s](%s)", n.dest, dumpInlines(n.children))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestParseInlines(t *testing.T) {
+	refs := map[string]linkReference{"ref": {dest: "/ref", title: "T"}}
+	testcases := []struct {
+		src      string
+		expected string
+	}{
+		{"*a* **b** ***c***", `em("a") " " strong("b") " " em(strong("c"))`},
+		{"_a_b _a_ __c__ snake_case_name", `"_a_b " em("a") " " strong("c") " snake_case_name"`},
+		{"**a *b* c** *d **e***", `strong("a " em("b") " c") " " em("d " strong("e"))`},
+		{"* a * and *unclosed", `"* a * and *unclosed"`},
+		{"~~gone~~ ~one~ ~~~no~~~", `del("gone") " " del("one") " ~~~no~~~"`},
+		{"`code *x*` `` a`b `` ``x", "code\"code *x*\" \" \" code\"a`b\" \" ``x\""},
+		{`\*not\* a \emphasis`, `"*not* a \\emphasis"`},
+		{"a  \nb\\\nc\nd", `"a" br "b" br "c" sb "d"`},
+		{`&amp; &copy; &#65; &bogus;`, `"& © A &bogus;"`},
+		{`[link](/url "title") [REF] [*x*][ref] [y][]`,
+			`a[/url|title]("link") " " a[/ref|T]("REF") " " a[/ref|T](em("x")) " [y][]"`},
+		{`[a [b](/b) c](/a)`, `"[a " a[/b|]("b") " c](/a)"`},
+		{`![alt *text*](img.png) <https://x.org/a> <me@x.org>`,
+			`img[img.png](` + `"alt " em("text")` + `) " " a[https://x.org/a|]("https://x.org/a") " " a[mailto:me@x.org|]("me@x.org")`},
+		{`a <span class="x">b</span><br/>c`, `"a b" br "c"`},
+		{`[a](<my url> 'b') [c](d(e)f)`, `a[my url|b]("a") " " a[d(e)f|]("c")`},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.expected, dumpInlines(parseInlines(tc.src, refs)), tc.src)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package markdown
+
+import (
+	"github.com/carmel/unipdf/creator"
+	"github.com/carmel/unipdf/model"
+)
+
+// Theme defines the styles of the documents converted from Markdown.
+type Theme struct {
+	// The fonts of the regular, bold, italic and bold italic text. The italic fonts fall back to
+	// the regular and bold fonts if not set.
+	Font           *model.PdfFont
+	BoldFont       *model.PdfFont
+	ItalicFont     *model.PdfFont
+	BoldItalicFont *model.PdfFont
+
+	// FontSize is the font size of the body text, and LineHeight the height of its lines,
+	// relative to the font size.
+	FontSize   float64
+	LineHeight float64
+	TextColor  creator.Color
+
+	// ParagraphSpacing is the space following the paragraphs and other blocks. The paragraphs of
+	// tight lists are not spaced.
+	ParagraphSpacing float64
+
+	// HeadingSizes are the font sizes of the headings of levels 1 to 6, displayed in bold, and
+	// HeadingSpacing the space preceding them.
+	HeadingSizes   [6]float64
+	HeadingColor   creator.Color
+	HeadingSpacing float64
+
+	// ChapterLevels is the number of heading levels laid out as chapters, listed in the outline
+	// and table of contents of the creator. The headings of the other levels, and the headings in
+	// block quotes and lists, are laid out as paragraphs.
+	ChapterLevels int
+
+	// NumberChapters shows the numbers of the chapters in their headings.
+	NumberChapters bool
+
+	// CodeFont is the monospace font of the code spans and blocks, displayed at CodeFontScale
+	// times the size of the surrounding text on the CodeBackground color. CodeBlockPadding is the
+	// padding of the code blocks.
+	CodeFont         *model.PdfFont
+	CodeFontScale    float64
+	CodeColor        creator.Color
+	CodeBackground   creator.Color
+	CodeBlockPadding float64
+
+	// LinkColor is the color of the links, underlined if LinkUnderline is set.
+	LinkColor     creator.Color
+	LinkUnderline bool
+
+	// The block quotes are indented by QuoteIndent, with a bar of QuoteBarWidth on their left.
+	QuoteColor    creator.Color
+	QuoteBarColor creator.Color
+	QuoteBarWidth float64
+	QuoteIndent   float64
+
+	// ListIndent is the indentation of the lists, and Bullets the markers of the items of
+	// unordered lists, by nesting level.
+	ListIndent float64
+	Bullets    []string
+
+	// The tables have borders of TableBorderWidth and cells padded by TableCellPadding, the cells
+	// of the header row being displayed in bold on TableHeaderBackground.
+	TableBorderColor      creator.Color
+	TableBorderWidth      float64
+	TableHeaderBackground creator.Color
+	TableCellPadding      float64
+
+	// RuleColor and RuleWidth are the style of the thematic breaks.
+	RuleColor creator.Color
+	RuleWidth float64
+
+	// ImageScale is the scale of the images, relative to their size in pixels. The images wider
+	// than the page are scaled down to its width.
+	ImageScale float64
+}
+
+// DefaultTheme returns the default theme: Helvetica body text and Courier code, with gray code
+// backgrounds and the headings of levels 1 to 3 as chapters.
+func DefaultTheme() *Theme {
+	std := func(name model.StdFontName) *model.PdfFont {
+		font, err := model.NewStandard14Font(name)
+		if err != nil {
+			return model.DefaultFont()
+		}
+		return font
+	}
+	gray := creator.ColorRGBFrom8bit(204, 204, 204)
+	return &Theme{
+		Font:                  std(model.HelveticaName),
+		BoldFont:              std(model.HelveticaBoldName),
+		ItalicFont:            std(model.HelveticaObliqueName),
+		BoldItalicFont:        std(model.HelveticaBoldObliqueName),
+		FontSize:              10,
+		LineHeight:            1.25,
+		TextColor:             creator.ColorBlack,
+		ParagraphSpacing:      6,
+		HeadingSizes:          [6]float64{20, 16, 13, 11, 10, 10},
+		HeadingColor:          creator.ColorRGBFrom8bit(34, 34, 34),
+		HeadingSpacing:        8,
+		ChapterLevels:         3,
+		CodeFont:              std(model.CourierName),
+		CodeFontScale:         0.9,
+		CodeColor:             creator.ColorRGBFrom8bit(51, 51, 51),
+		CodeBackground:        creator.ColorRGBFrom8bit(240, 240, 240),
+		CodeBlockPadding:      6,
+		LinkColor:             creator.ColorRGBFrom8bit(3, 102, 214),
+		QuoteColor:            creator.ColorRGBFrom8bit(96, 96, 96),
+		QuoteBarColor:         gray,
+		QuoteBarWidth:         3,
+		QuoteIndent:           10,
+		ListIndent:            6,
+		Bullets:               []string{"•", "-", "·"},
+		TableBorderColor:      gray,
+		TableBorderWidth:      0.5,
+		TableHeaderBackground: creator.ColorRGBFrom8bit(246, 248, 250),
+		TableCellPadding:      4,
+		RuleColor:             gray,
+		RuleWidth:             1,
+		ImageScale:            0.75,
+	}
+}
+
+// font returns the font of the text displayed in bold and/or italic.
+func (t *Theme) font(bold, italic bool) *model.PdfFont {
+	var candidates []*model.PdfFont
+	switch {
+	case bold && italic:
+		candidates = []*model.PdfFont{t.BoldItalicFont, t.BoldFont, t.ItalicFont}
+	case bold:
+		candidates = []*model.PdfFont{t.BoldFont}
+	case italic:
+		candidates = []*model.PdfFont{t.ItalicFont}
+	}
+	for _, font := range candidates {
+		if font != nil {
+			return font
+		}
+	}
+	return t.Font
+}
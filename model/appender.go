@@ -16,6 +16,7 @@ import (
 
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model/xmputil"
 )
 
 // PdfAppender appends new PDF content to an existing PDF document via incremental updates.
@@ -45,6 +46,14 @@ type PdfAppender struct {
 
 	prevRevisionSize int64
 	written          bool
+
+	// Document-level embedded files and portable collection.
+	embeddedFiles embeddedFiles
+	collection    *PdfCollection
+
+	// Document information and XMP metadata of the new revision, nil if unchanged.
+	info *PdfInfo
+	xmp  *xmputil.Packet
 }
 
 func getPageResources(p *PdfPage) map[core.PdfObjectName]core.PdfObject {
@@ -520,6 +529,33 @@ func (a *PdfAppender) ReplaceAcroForm(acroForm *PdfAcroForm) {
 	a.acroForm = acroForm
 }
 
+// AddEmbeddedFile attaches a file to the document, replacing an existing file with the same name.
+func (a *PdfAppender) AddEmbeddedFile(f *EmbeddedFile) error {
+	return a.embeddedFiles.add(f)
+}
+
+// RemoveEmbeddedFile removes the document-level embedded file with the specified name.
+func (a *PdfAppender) RemoveEmbeddedFile(name string) {
+	a.embeddedFiles.remove(name)
+}
+
+// SetCollection makes the document a portable collection (PDF portfolio) of its embedded files.
+// Requires PDF 1.7, the version of the document is raised to 1.7 if lower.
+func (a *PdfAppender) SetCollection(collection *PdfCollection) {
+	a.collection = collection
+}
+
+// SetDocInfo sets the document information dictionary of the new revision.
+func (a *PdfAppender) SetDocInfo(info *PdfInfo) {
+	a.info = info
+}
+
+// SetXMP sets the XMP metadata of the new revision. The packet is synchronized with the document
+// information dictionary as described in PdfWriter.SetXMP.
+func (a *PdfAppender) SetXMP(packet *xmputil.Packet) {
+	a.xmp = packet
+}
+
 // Write writes the Appender output to io.Writer.
 // It can only be called once and further invocations will result in an error.
 func (a *PdfAppender) Write(w io.Writer) error {
@@ -558,6 +594,16 @@ func (a *PdfAppender) Write(w io.Writer) error {
 		return errors.New("missing catalog")
 	}
 
+	if a.info != nil {
+		writer.SetDocInfo(a.info)
+	}
+	if a.xmp != nil {
+		if _, err := writer.writeXMPMetadata(a.xmp); err != nil {
+			return err
+		}
+		a.addNewObject(writer.catalog.Get("Metadata"))
+	}
+
 	// Add the keys which are not set.
 	for _, key := range catalog.Keys() {
 		if writer.catalog.Get(key) == nil {
@@ -569,6 +615,20 @@ func (a *PdfAppender) Write(w io.Writer) error {
 		writer.catalog.Set("AcroForm", a.acroForm.ToPdfObject())
 		a.updateObjectsDeep(a.acroForm.ToPdfObject(), nil)
 	}
+	if a.embeddedFiles.changed() {
+		existing, err := a.roReader.embeddedFileEntries()
+		if err != nil {
+			return err
+		}
+		if err := a.embeddedFiles.apply(writer.catalog, existing); err != nil {
+			return err
+		}
+		a.updateObjectsDeep(writer.catalog.Get("Names"), nil)
+		a.updateObjectsDeep(writer.catalog.Get("AF"), nil)
+	}
+	if a.collection != nil {
+		writer.catalog.Set("Collection", a.collection.ToPdfObject())
+	}
 
 	a.addNewObject(writer.infoObj)
 	a.addNewObject(writer.root)
@@ -705,6 +765,10 @@ func (a *PdfAppender) Write(w io.Writer) error {
 	writer.appendXrefPrevOffset = a.xrefOffset
 	writer.appendPrevRevisionSize = a.prevRevisionSize
 	writer.minorVersion = a.roReader.PdfVersion().Minor
+	if v := a.roReader.PdfVersion(); a.collection != nil && v.Major == 1 && v.Minor < 7 {
+		// Portable collections require PDF 1.7.
+		writer.minorVersion = 7
+	}
 	writer.appendReplaceMap = a.replaceObjects
 
 	xrefType := a.parser.GetXrefType()
@@ -0,0 +1,296 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// CollectionView specifies how a portable collection is initially presented (12.3.5, Table 153).
+type CollectionView string
+
+// Portable collection views.
+const (
+	CollectionViewDetails CollectionView = "D"
+	CollectionViewTile    CollectionView = "T"
+	CollectionViewHidden  CollectionView = "H"
+)
+
+// Collection field subtypes (Table 156). Fields with S, D and N subtypes get their values from
+// collection items, other subtypes refer to the properties of embedded files.
+const (
+	CollectionFieldText         = "S"
+	CollectionFieldDate         = "D"
+	CollectionFieldNumber       = "N"
+	CollectionFieldFileName     = "F"
+	CollectionFieldDescription  = "Desc"
+	CollectionFieldModDate      = "ModDate"
+	CollectionFieldCreationDate = "CreationDate"
+	CollectionFieldSize         = "Size"
+)
+
+// PdfCollectionField represents a field of the portable collection schema (Table 156).
+type PdfCollectionField struct {
+	// Key is the name of the field in the schema and in collection items.
+	Key string
+	// Subtype is the data type of the field, such as CollectionFieldText.
+	Subtype string
+	// Name is the display name of the field.
+	Name string
+	// Order is the relative order of the field in the user interface.
+	Order int
+	// Hidden indicates that the field is not initially visible.
+	Hidden bool
+	// Editable indicates that the field value can be edited by the user.
+	Editable bool
+}
+
+// PdfCollectionSort specifies the order in which collection items are shown (Table 158).
+type PdfCollectionSort struct {
+	// Fields are the keys of schema fields used for sorting, in order of priority.
+	Fields []string
+	// Ascending specifies the sort direction for each field. Defaults to ascending if not specified.
+	Ascending []bool
+}
+
+// PdfCollection represents a portable collection (PDF portfolio) dictionary (12.3.5, Table 153).
+type PdfCollection struct {
+	// Schema contains the fields shown for each item of the collection.
+	Schema []*PdfCollectionField
+	// D is the name of the embedded file that is initially presented.
+	D    string
+	View CollectionView
+	Sort *PdfCollectionSort
+}
+
+// NewPdfCollection returns a new portable collection with the details view.
+func NewPdfCollection() *PdfCollection {
+	return &PdfCollection{View: CollectionViewDetails}
+}
+
+// AddField adds a field to the collection schema and returns it.
+func (c *PdfCollection) AddField(key, subtype, name string) *PdfCollectionField {
+	field := &PdfCollectionField{
+		Key:     key,
+		Subtype: subtype,
+		Name:    name,
+		Order:   len(c.Schema) + 1,
+	}
+	c.Schema = append(c.Schema, field)
+	return field
+}
+
+// ToPdfObject returns the collection dictionary.
+func (c *PdfCollection) ToPdfObject() core.PdfObject {
+	dict := core.MakeDict()
+	dict.Set("Type", core.MakeName("Collection"))
+	if len(c.Schema) != 0 {
+		schema := core.MakeDict()
+		schema.Set("Type", core.MakeName("CollectionSchema"))
+		for _, f := range c.Schema {
+			fd := core.MakeDict()
+			fd.Set("Type", core.MakeName("CollectionField"))
+			fd.Set("Subtype", core.MakeName(f.Subtype))
+			fd.Set("N", makeTextString(f.Name))
+			if f.Order != 0 {
+				fd.Set("O", core.MakeInteger(int64(f.Order)))
+			}
+			if f.Hidden {
+				fd.Set("V", core.MakeBool(false))
+			}
+			if f.Editable {
+				fd.Set("E", core.MakeBool(true))
+			}
+			schema.Set(core.PdfObjectName(f.Key), fd)
+		}
+		dict.Set("Schema", schema)
+	}
+	if c.D != "" {
+		dict.Set("D", makeTextString(c.D))
+	}
+	if c.View != "" {
+		dict.Set("View", core.MakeName(string(c.View)))
+	}
+	if c.Sort != nil && len(c.Sort.Fields) != 0 {
+		sd := core.MakeDict()
+		sd.Set("Type", core.MakeName("CollectionSort"))
+		if len(c.Sort.Fields) == 1 {
+			sd.Set("S", core.MakeName(c.Sort.Fields[0]))
+		} else {
+			keys := core.MakeArray()
+			for _, key := range c.Sort.Fields {
+				keys.Append(core.MakeName(key))
+			}
+			sd.Set("S", keys)
+		}
+		switch len(c.Sort.Ascending) {
+		case 0:
+		case 1:
+			sd.Set("A", core.MakeBool(c.Sort.Ascending[0]))
+		default:
+			asc := core.MakeArray()
+			for _, a := range c.Sort.Ascending {
+				asc.Append(core.MakeBool(a))
+			}
+			sd.Set("A", asc)
+		}
+		dict.Set("Sort", sd)
+	}
+	return dict
+}
+
+// newPdfCollectionFromObj loads a portable collection from a collection dictionary.
+func newPdfCollectionFromObj(obj core.PdfObject) (*PdfCollection, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, core.ErrTypeError
+	}
+	c := &PdfCollection{}
+	if schema, ok := core.GetDict(dict.Get("Schema")); ok {
+		for _, key := range schema.Keys() {
+			fd, ok := core.GetDict(schema.Get(key))
+			if !ok {
+				continue
+			}
+			f := &PdfCollectionField{Key: string(key)}
+			if subtype, ok := core.GetName(fd.Get("Subtype")); ok {
+				f.Subtype = string(*subtype)
+			}
+			if name, ok := core.GetString(fd.Get("N")); ok {
+				f.Name = name.Decoded()
+			}
+			if order, ok := core.GetIntVal(fd.Get("O")); ok {
+				f.Order = order
+			}
+			if visible, ok := core.GetBoolVal(fd.Get("V")); ok {
+				f.Hidden = !visible
+			}
+			if editable, ok := core.GetBoolVal(fd.Get("E")); ok {
+				f.Editable = editable
+			}
+			c.Schema = append(c.Schema, f)
+		}
+		sort.SliceStable(c.Schema, func(i, j int) bool {
+			return c.Schema[i].Order < c.Schema[j].Order
+		})
+	}
+	if d, ok := core.GetString(dict.Get("D")); ok {
+		c.D = d.Decoded()
+	}
+	c.View = CollectionViewDetails
+	if view, ok := core.GetName(dict.Get("View")); ok {
+		c.View = CollectionView(*view)
+	}
+	if sd, ok := core.GetDict(dict.Get("Sort")); ok {
+		s := &PdfCollectionSort{}
+		switch t := core.ResolveReference(sd.Get("S")).(type) {
+		case *core.PdfObjectName:
+			s.Fields = append(s.Fields, string(*t))
+		case *core.PdfObjectArray:
+			for _, o := range t.Elements() {
+				if name, ok := core.GetName(o); ok {
+					s.Fields = append(s.Fields, string(*name))
+				}
+			}
+		}
+		switch t := core.ResolveReference(sd.Get("A")).(type) {
+		case *core.PdfObjectBool:
+			s.Ascending = append(s.Ascending, bool(*t))
+		case *core.PdfObjectArray:
+			for _, o := range t.Elements() {
+				if a, ok := core.GetBoolVal(o); ok {
+					s.Ascending = append(s.Ascending, a)
+				}
+			}
+		}
+		c.Sort = s
+	}
+	return c, nil
+}
+
+// makeCollectionItem creates a collection item dictionary (Table 159) from Go values.
+func makeCollectionItem(values map[string]interface{}) (*core.PdfObjectDictionary, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ci := core.MakeDict()
+	ci.Set("Type", core.MakeName("CollectionItem"))
+	for _, key := range keys {
+		var obj core.PdfObject
+		switch v := values[key].(type) {
+		case string:
+			obj = makeTextString(v)
+		case int:
+			obj = core.MakeInteger(int64(v))
+		case int64:
+			obj = core.MakeInteger(v)
+		case float64:
+			obj = core.MakeFloat(v)
+		case time.Time:
+			d, err := NewPdfDateFromTime(v)
+			if err != nil {
+				return nil, err
+			}
+			obj = d.ToPdfObject()
+		default:
+			return nil, fmt.Errorf("unsupported collection item value for %q: %T", key, v)
+		}
+		ci.Set(core.PdfObjectName(key), obj)
+	}
+	return ci, nil
+}
+
+// loadCollectionItem converts a collection item dictionary to Go values.
+// Strings that represent PDF dates are converted to time.Time.
+func loadCollectionItem(obj core.PdfObject) map[string]interface{} {
+	ci, ok := core.GetDict(obj)
+	if !ok {
+		common.Log.Debug("Invalid collection item (%T)", obj)
+		return nil
+	}
+	values := make(map[string]interface{})
+	for _, key := range ci.Keys() {
+		if key == "Type" {
+			continue
+		}
+		v := core.ResolveReference(ci.Get(key))
+		if d, ok := core.GetDict(v); ok {
+			// Collection subitem: the value is in the D entry.
+			v = core.ResolveReference(d.Get("D"))
+		}
+		switch t := v.(type) {
+		case *core.PdfObjectString:
+			if d, err := NewPdfDate(t.Str()); err == nil {
+				values[string(key)] = d.ToGoTime()
+			} else {
+				values[string(key)] = t.Decoded()
+			}
+		case *core.PdfObjectInteger:
+			values[string(key)] = int(*t)
+		case *core.PdfObjectFloat:
+			values[string(key)] = float64(*t)
+		}
+	}
+	return values
+}
+
+// GetCollection returns the portable collection (PDF portfolio) of the document.
+// Nil is returned if the document is not a portfolio.
+func (r *PdfReader) GetCollection() (*PdfCollection, error) {
+	obj := r.catalog.Get("Collection")
+	if obj == nil {
+		return nil, nil
+	}
+	return newPdfCollectionFromObj(obj)
+}
@@ -0,0 +1,405 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// AFRelationship specifies the relationship between an associated file and the PDF component
+// that refers to it (PDF 2.0, PDF/A-3, Table 43).
+type AFRelationship string
+
+// Associated file relationships.
+const (
+	AFRelationshipSource           AFRelationship = "Source"
+	AFRelationshipData             AFRelationship = "Data"
+	AFRelationshipAlternative      AFRelationship = "Alternative"
+	AFRelationshipSupplement       AFRelationship = "Supplement"
+	AFRelationshipEncryptedPayload AFRelationship = "EncryptedPayload"
+	AFRelationshipFormData         AFRelationship = "FormData"
+	AFRelationshipSchema           AFRelationship = "Schema"
+	AFRelationshipUnspecified      AFRelationship = "Unspecified"
+)
+
+// EmbeddedFile represents a file embedded in the document, together with its
+// file specification and embedded file parameters (7.11.3 and 7.11.4).
+type EmbeddedFile struct {
+	// Name is the key of the file in the EmbeddedFiles name tree. Defaults to FileName.
+	Name string
+	// FileName is the name of the file (UF and F entries of the file specification).
+	FileName    string
+	Description string
+	Content     []byte
+
+	// MimeType is the MIME type of the file (Subtype entry of the embedded file stream).
+	MimeType string
+	// Checksum is the MD5 checksum of the uncompressed file content.
+	Checksum []byte
+	// Size is the size of the uncompressed file content in bytes.
+	Size         int
+	CreationDate time.Time
+	ModDate      time.Time
+
+	// Relationship is the relationship of the file to the document. If set, the file is
+	// also listed in the AF array of the catalog, as required for PDF/A-3 (e.g. ZUGFeRD invoices).
+	Relationship AFRelationship
+
+	// CollectionItem contains values for fields of the portable collection schema (CI entry).
+	// Values can be strings, numbers (int or float64) or time.Time dates.
+	CollectionItem map[string]interface{}
+}
+
+// NewEmbeddedFile creates an embedded file with the specified file name and content.
+// The MIME type is guessed by the file extension, and the checksum and size are calculated
+// from the content. The modification date is set to the current time.
+func NewEmbeddedFile(fileName string, content []byte) *EmbeddedFile {
+	sum := md5.Sum(content)
+	mimeType := mime.TypeByExtension(filepath.Ext(fileName))
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		// Parameters such as charset are not allowed in the Subtype name.
+		mimeType = mediaType
+	}
+	return &EmbeddedFile{
+		Name:     fileName,
+		FileName: fileName,
+		Content:  content,
+		MimeType: mimeType,
+		Checksum: sum[:],
+		Size:     len(content),
+		ModDate:  time.Now(),
+	}
+}
+
+// NewEmbeddedFileFromFile creates an embedded file from the file at the specified path.
+func NewEmbeddedFileFromFile(path string) (*EmbeddedFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := NewEmbeddedFile(filepath.Base(path), content)
+	if info, err := os.Stat(path); err == nil {
+		f.ModDate = info.ModTime()
+	}
+	return f, nil
+}
+
+// VerifyChecksum checks if the content matches the checksum stored in the embedded file parameters.
+// It returns true if no checksum was specified.
+func (f *EmbeddedFile) VerifyChecksum() bool {
+	if len(f.Checksum) == 0 {
+		return true
+	}
+	sum := md5.Sum(f.Content)
+	return bytes.Equal(sum[:], f.Checksum)
+}
+
+// ToPdfFilespec creates a file specification with an embedded file stream. The content is compressed
+// with the Flate filter.
+func (f *EmbeddedFile) ToPdfFilespec() (*PdfFilespec, error) {
+	if f.FileName == "" {
+		return nil, errors.New("embedded file name is not specified")
+	}
+	stream, err := core.MakeStream(f.Content, core.NewFlateEncoder())
+	if err != nil {
+		return nil, err
+	}
+	stream.Set("Type", core.MakeName("EmbeddedFile"))
+	if f.MimeType != "" {
+		stream.Set("Subtype", core.MakeName(f.MimeType))
+	}
+
+	params := core.MakeDict()
+	size := f.Size
+	if size == 0 {
+		size = len(f.Content)
+	}
+	params.Set("Size", core.MakeInteger(int64(size)))
+	if len(f.Checksum) != 0 {
+		params.Set("CheckSum", core.MakeStringFromBytes(f.Checksum))
+	}
+	setDate := func(key core.PdfObjectName, t time.Time) {
+		if t.IsZero() {
+			return
+		}
+		if d, err := NewPdfDateFromTime(t); err == nil {
+			params.Set(key, d.ToPdfObject())
+		}
+	}
+	setDate("CreationDate", f.CreationDate)
+	setDate("ModDate", f.ModDate)
+	stream.Set("Params", params)
+
+	fs := NewPdfFilespec()
+	fs.F = core.MakeString(f.FileName)
+	fs.UF = core.MakeEncodedString(f.FileName, true)
+	if f.Description != "" {
+		fs.Desc = makeTextString(f.Description)
+	}
+	ef := core.MakeDict()
+	ef.Set("F", stream)
+	ef.Set("UF", stream)
+	fs.EF = ef
+	if f.Relationship != "" {
+		fs.AFRelationship = core.MakeName(string(f.Relationship))
+	}
+	if len(f.CollectionItem) != 0 {
+		ci, err := makeCollectionItem(f.CollectionItem)
+		if err != nil {
+			return nil, err
+		}
+		fs.CI = ci
+	}
+	return fs, nil
+}
+
+// NewEmbeddedFileFromFilespec loads an embedded file from a file specification.
+// An error is returned if the file specification does not contain an embedded file stream.
+func NewEmbeddedFileFromFilespec(fs *PdfFilespec) (*EmbeddedFile, error) {
+	ef, ok := core.GetDict(fs.EF)
+	if !ok {
+		return nil, errors.New("file specification has no embedded file")
+	}
+	var stream *core.PdfObjectStream
+	for _, key := range []core.PdfObjectName{"UF", "F", "Unix", "Mac", "DOS"} {
+		if stream, ok = core.GetStream(ef.Get(key)); ok {
+			break
+		}
+	}
+	if stream == nil {
+		return nil, errors.New("embedded file stream not found")
+	}
+	content, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &EmbeddedFile{
+		Content: content,
+		Size:    len(content),
+	}
+	for _, obj := range []core.PdfObject{fs.UF, fs.F, fs.Unix, fs.Mac, fs.DOS} {
+		if s, ok := core.GetString(obj); ok && s.Str() != "" {
+			f.FileName = s.Decoded()
+			break
+		}
+	}
+	f.Name = f.FileName
+	if s, ok := core.GetString(fs.Desc); ok {
+		f.Description = s.Decoded()
+	}
+	if subtype, ok := core.GetName(stream.Get("Subtype")); ok {
+		f.MimeType = string(*subtype)
+	}
+	if params, ok := core.GetDict(stream.Get("Params")); ok {
+		if size, ok := core.GetIntVal(params.Get("Size")); ok {
+			f.Size = size
+		}
+		if sum, ok := core.GetString(params.Get("CheckSum")); ok {
+			f.Checksum = sum.Bytes()
+		}
+		f.CreationDate = parseDateObj(params.Get("CreationDate"))
+		f.ModDate = parseDateObj(params.Get("ModDate"))
+	}
+	if rel, ok := core.GetName(fs.AFRelationship); ok {
+		f.Relationship = AFRelationship(*rel)
+	}
+	if fs.CI != nil {
+		f.CollectionItem = loadCollectionItem(fs.CI)
+	}
+	return f, nil
+}
+
+// parseDateObj parses a PDF date string. A zero time is returned for invalid dates.
+func parseDateObj(obj core.PdfObject) time.Time {
+	s, ok := core.GetString(obj)
+	if !ok {
+		return time.Time{}
+	}
+	d, err := NewPdfDate(s.Str())
+	if err != nil {
+		common.Log.Debug("Invalid date: %v", err)
+		return time.Time{}
+	}
+	return d.ToGoTime()
+}
+
+// GetEmbeddedFiles returns the files embedded at the document level, as listed in the
+// EmbeddedFiles name tree of the catalog (7.7.4). Files are returned in the order of the tree.
+func (r *PdfReader) GetEmbeddedFiles() ([]*EmbeddedFile, error) {
+	entries, err := r.embeddedFileEntries()
+	if err != nil {
+		return nil, err
+	}
+	var files []*EmbeddedFile
+	for _, e := range entries {
+		fs, err := NewPdfFilespecFromObj(e.Value)
+		if err != nil {
+			common.Log.Debug("ERROR: Invalid file specification %q: %v - skipping", e.Key, err)
+			continue
+		}
+		f, err := NewEmbeddedFileFromFilespec(fs)
+		if err != nil {
+			common.Log.Debug("ERROR: Failed to load embedded file %q: %v - skipping", e.Key, err)
+			continue
+		}
+		f.Name = e.Key
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// GetEmbeddedFile returns the document-level embedded file with the specified name.
+// Nil is returned if the file does not exist.
+func (r *PdfReader) GetEmbeddedFile(name string) (*EmbeddedFile, error) {
+	files, err := r.GetEmbeddedFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Name == name {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// embeddedFileEntries returns entries of the EmbeddedFiles name tree of the catalog.
+func (r *PdfReader) embeddedFileEntries() ([]nameTreeEntry, error) {
+	names, ok := core.GetDict(r.catalog.Get("Names"))
+	if !ok {
+		return nil, nil
+	}
+	root := names.Get("EmbeddedFiles")
+	if root == nil {
+		return nil, nil
+	}
+	return loadNameTree(root)
+}
+
+// embeddedFiles keeps track of document-level embedded files added or removed by the writer or appender.
+type embeddedFiles struct {
+	added   []*EmbeddedFile
+	removed map[string]struct{}
+}
+
+// add adds a file, replacing a previously added file with the same name.
+func (e *embeddedFiles) add(f *EmbeddedFile) error {
+	if f == nil {
+		return errors.New("embedded file is nil")
+	}
+	if f.Name == "" {
+		f.Name = f.FileName
+	}
+	if f.Name == "" {
+		return errors.New("embedded file name is not specified")
+	}
+	for i, af := range e.added {
+		if af.Name == f.Name {
+			e.added[i] = f
+			return nil
+		}
+	}
+	e.added = append(e.added, f)
+	return nil
+}
+
+// remove marks a file as removed.
+func (e *embeddedFiles) remove(name string) {
+	for i, af := range e.added {
+		if af.Name == name {
+			e.added = append(e.added[:i], e.added[i+1:]...)
+			break
+		}
+	}
+	if e.removed == nil {
+		e.removed = make(map[string]struct{})
+	}
+	e.removed[name] = struct{}{}
+}
+
+// changed reports whether any files were added or removed.
+func (e *embeddedFiles) changed() bool {
+	return len(e.added) != 0 || len(e.removed) != 0
+}
+
+// apply merges the changes with existing name tree entries and updates the catalog.
+// A new Names dictionary is set in the catalog, which preserves other name trees. Files with
+// a relationship are listed in the AF array of the catalog.
+func (e *embeddedFiles) apply(catalog *core.PdfObjectDictionary, existing []nameTreeEntry) error {
+	var entries []nameTreeEntry
+	dropped := make(map[core.PdfObject]struct{})
+	for _, ent := range existing {
+		_, removed := e.removed[ent.Key]
+		for _, f := range e.added {
+			if f.Name == ent.Key {
+				removed = true
+				break
+			}
+		}
+		if removed {
+			dropped[core.ResolveReference(ent.Value)] = struct{}{}
+			continue
+		}
+		entries = append(entries, ent)
+	}
+
+	// Drop associated files which are no longer embedded.
+	var afElems []core.PdfObject
+	if af, ok := core.GetArray(catalog.Get("AF")); ok {
+		for _, obj := range af.Elements() {
+			if _, ok := dropped[core.ResolveReference(obj)]; !ok {
+				afElems = append(afElems, obj)
+			}
+		}
+	}
+
+	for _, f := range e.added {
+		fs, err := f.ToPdfFilespec()
+		if err != nil {
+			return fmt.Errorf("embedded file %q: %v", f.Name, err)
+		}
+		obj := fs.ToPdfObject()
+		entries = append(entries, nameTreeEntry{Key: f.Name, Value: obj})
+		if f.Relationship != "" {
+			afElems = append(afElems, obj)
+		}
+	}
+
+	names := core.MakeDict()
+	if old, ok := core.GetDict(catalog.Get("Names")); ok {
+		for _, key := range old.Keys() {
+			names.Set(key, old.Get(key))
+		}
+	}
+	if len(entries) == 0 {
+		names.Remove("EmbeddedFiles")
+	} else {
+		names.Set("EmbeddedFiles", makeNameTree(entries))
+	}
+	if len(names.Keys()) == 0 {
+		catalog.Remove("Names")
+	} else {
+		catalog.Set("Names", names)
+	}
+
+	if len(afElems) == 0 {
+		catalog.Remove("AF")
+	} else {
+		catalog.Set("AF", core.MakeArray(afElems...))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+func TestEmbeddedFilesReadWrite(t *testing.T) {
+	created := time.Date(2020, 5, 1, 10, 20, 30, 0, time.UTC)
+
+	invoice := NewEmbeddedFile("factur-x.xml", []byte("<Invoice/>"))
+	invoice.Description = "Factur-X invoice"
+	invoice.MimeType = "text/xml"
+	invoice.CreationDate = created
+	invoice.ModDate = created
+	invoice.Relationship = AFRelationshipAlternative
+	invoice.CollectionItem = map[string]interface{}{"from": "ACME", "amount": 42}
+
+	mail := NewEmbeddedFile("mail.eml", []byte("Subject: hello\r\n\r\nbody"))
+	mail.Name = "message"
+	mail.MimeType = "message/rfc822"
+
+	collection := NewPdfCollection()
+	collection.AddField("from", CollectionFieldText, "From")
+	collection.AddField("amount", CollectionFieldNumber, "Amount").Hidden = true
+	collection.D = "factur-x.xml"
+	collection.Sort = &PdfCollectionSort{Fields: []string{"from", "amount"}, Ascending: []bool{true, false}}
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	require.NoError(t, w.AddEmbeddedFile(invoice))
+	require.NoError(t, w.AddEmbeddedFile(mail))
+	w.SetCollection(collection)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// Name tree entries are sorted by name.
+	f := files[0]
+	require.Equal(t, "factur-x.xml", f.Name)
+	require.Equal(t, "factur-x.xml", f.FileName)
+	require.Equal(t, "Factur-X invoice", f.Description)
+	require.Equal(t, []byte("<Invoice/>"), f.Content)
+	require.Equal(t, "text/xml", f.MimeType)
+	require.Equal(t, 10, f.Size)
+	require.True(t, f.VerifyChecksum())
+	require.True(t, created.Equal(f.CreationDate))
+	require.True(t, created.Equal(f.ModDate))
+	require.Equal(t, AFRelationshipAlternative, f.Relationship)
+	require.Equal(t, map[string]interface{}{"from": "ACME", "amount": 42}, f.CollectionItem)
+
+	f = files[1]
+	require.Equal(t, "message", f.Name)
+	require.Equal(t, "mail.eml", f.FileName)
+	require.Equal(t, "message/rfc822", f.MimeType)
+	require.True(t, f.VerifyChecksum())
+
+	// Only files with a relationship are associated with the document.
+	af, ok := core.GetArray(reader.catalog.Get("AF"))
+	require.True(t, ok)
+	require.Equal(t, 1, af.Len())
+
+	c, err := reader.GetCollection()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	require.Equal(t, "factur-x.xml", c.D)
+	require.Equal(t, CollectionViewDetails, c.View)
+	require.Len(t, c.Schema, 2)
+	require.Equal(t, "from", c.Schema[0].Key)
+	require.Equal(t, CollectionFieldText, c.Schema[0].Subtype)
+	require.False(t, c.Schema[0].Hidden)
+	require.Equal(t, "amount", c.Schema[1].Key)
+	require.True(t, c.Schema[1].Hidden)
+	require.Equal(t, collection.Sort, c.Sort)
+}
+
+func TestAppenderEmbeddedFiles(t *testing.T) {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("a.txt", []byte("a"))))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("b.txt", []byte("b"))))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	appender.RemoveEmbeddedFile("a.txt")
+	require.NoError(t, appender.AddEmbeddedFile(NewEmbeddedFile("c.txt", []byte("c"))))
+	appender.SetCollection(NewPdfCollection())
+
+	var out bytes.Buffer
+	require.NoError(t, appender.Write(&out))
+
+	reader, err = NewPdfReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.Equal(t, "b.txt", files[0].Name)
+	require.Equal(t, []byte("b"), files[0].Content)
+	require.Equal(t, "c.txt", files[1].Name)
+	require.Equal(t, []byte("c"), files[1].Content)
+
+	// Portable collections require PDF 1.7.
+	c, err := reader.GetCollection()
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	version, ok := core.GetNameVal(reader.catalog.Get("Version"))
+	require.True(t, ok)
+	require.Equal(t, "1.7", version)
+}
+
+func TestWriterRemoveEmbeddedFile(t *testing.T) {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("a.txt", []byte("a"))))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("b.txt", []byte("b"))))
+	w.RemoveEmbeddedFile("a.txt")
+	w.RemoveEmbeddedFile("missing.txt")
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "b.txt", files[0].Name)
+}
@@ -45,6 +45,9 @@ type PdfFilespec struct {
 	Desc core.PdfObject // Descriptive text associated with the file specification
 	CI   core.PdfObject // A collection item dictionary, which shall be used to create the user interface for portable collections
 
+	// AFRelationship specifies the relationship of an associated file to the component that refers to it (PDF 2.0).
+	AFRelationship core.PdfObject
+
 	container core.PdfObject
 }
 
@@ -87,6 +90,7 @@ func (f *PdfFilespec) ToPdfObject() core.PdfObject {
 	d.SetIfNotNil("RF", f.RF)
 	d.SetIfNotNil("Desc", f.Desc)
 	d.SetIfNotNil("CI", f.CI)
+	d.SetIfNotNil("AFRelationship", f.AFRelationship)
 
 	return f.container
 }
@@ -167,6 +171,9 @@ func NewPdfFilespecFromObj(obj core.PdfObject) (*PdfFilespec, error) {
 	if obj := dict.Get("CI"); obj != nil {
 		fs.CI = obj
 	}
+	if obj := dict.Get("AFRelationship"); obj != nil {
+		fs.AFRelationship = obj
+	}
 	return fs, nil
 }
 
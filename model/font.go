@@ -138,6 +138,8 @@ func newStandard14Font(basefont StdFontName) (pdfFontSimple, error) {
 		return pdfFontSimple{}, ErrFontNotSupported
 	}
 	std := stdFontToSimpleFont(fnt)
+	// The underline metrics of all the standard 14 fonts.
+	std.underlinePosition, std.underlineThickness = -100, 50
 	return std, nil
 }
 
@@ -598,6 +600,39 @@ func (font *PdfFont) GetRuneMetrics(r rune) (CharMetrics, bool) {
 	return fonts.CharMetrics{}, false
 }
 
+// HasGlyph returns true if `font` has a glyph for the rune `r`. Runes without glyphs are
+// displayed with the missing glyph of the font (.notdef), if at all.
+func (font *PdfFont) HasGlyph(r rune) bool {
+	switch t := font.context.(type) {
+	case *pdfFontType0:
+		if t.shaping != nil {
+			return t.shaping.hasGlyph(r)
+		}
+	case *pdfFontSimple:
+		if t.fontMetrics != nil {
+			_, ok := t.fontMetrics[r]
+			return ok
+		}
+	}
+	enc := font.Encoder()
+	if enc == nil {
+		return false
+	}
+	_, ok := enc.RuneToCharcode(r)
+	return ok
+}
+
+// UnderlineMetrics returns the position of the underline of `font` relative to the baseline,
+// negative below, and its thickness, in glyph space units. The metrics are known for the standard
+// 14 fonts and the fonts loaded from TrueType fonts, `ok` being false otherwise.
+func (font *PdfFont) UnderlineMetrics() (position, thickness float64, ok bool) {
+	base := font.baseFields()
+	if base == nil || base.underlineThickness <= 0 {
+		return 0, 0, false
+	}
+	return base.underlinePosition, base.underlineThickness, true
+}
+
 // GetCharMetrics returns the char metrics for character code `code`.
 // How it works:
 //  1. It calls the GetCharMetrics function for the underlying font, either a simple font or
@@ -683,6 +718,11 @@ type fontCommon struct {
 
 	// objectNumber helps us find the font in the PDF being processed. This helps with debugging.
 	objectNumber int64
+
+	// underlinePosition and underlineThickness are the underline metrics of the font program in
+	// glyph space units, both 0 if unknown.
+	underlinePosition  float64
+	underlineThickness float64
 }
 
 // asPdfObjectDictionary returns `base` as a core.PdfObjectDictionary.
@@ -109,6 +109,9 @@ type pdfFontType0 struct {
 	Encoding       core.PdfObject
 	DescendantFont *PdfFont // Can be either CIDFontType0 or CIDFontType2 font.
 	codeToCID      *cmap.CMap
+
+	// shaping is the text shaping state of fonts loaded from TrueType fonts with layout tables.
+	shaping *fontShaping
 }
 
 // pdfFontType0FromSkeleton returns a pdfFontType0 with its common fields initalized.
@@ -238,7 +241,16 @@ func (font *pdfFontType0) subsetRegistered() error {
 	case *textencoding.TrueTypeFontEncoder:
 		// Means the font has been loaded from TTF file.
 		runes = tenc.RegisteredRunes()
-		subset, err = fnt.SubsetKeepRunes(runes)
+		if font.shaping != nil {
+			// Keep the glyphs of shaped text, e.g. ligatures, that no rune maps to.
+			indices := fnt.LookupRunes(runes)
+			for _, gid := range font.shaping.subsetGlyphs(runes) {
+				indices = append(indices, unitype.GlyphIndex(gid))
+			}
+			subset, err = fnt.SubsetKeepIndices(indices)
+		} else {
+			subset, err = fnt.SubsetKeepRunes(runes)
+		}
 		if err != nil {
 			common.Log.Debug("ERROR: %v", err)
 			return err
@@ -336,6 +348,10 @@ func (font *pdfFontType0) ToPdfObject() core.PdfObject {
 		font.container = &core.PdfIndirectObject{}
 	}
 
+	if font.shaping != nil {
+		font.shaping.updateToUnicode(font)
+	}
+
 	d := font.baseFields().asPdfObjectDictionary("Type0")
 	font.container.PdfObject = d
 
@@ -864,14 +880,17 @@ func NewCompositePdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 	// Make root Type0 font.
 	type0 := pdfFontType0{
 		fontCommon: fontCommon{
-			subtype:  "Type0",
-			basefont: ttf.PostScriptName,
+			subtype:            "Type0",
+			basefont:           ttf.PostScriptName,
+			underlinePosition:  k * float64(ttf.UnderlinePosition),
+			underlineThickness: k * float64(ttf.UnderlineThickness),
 		},
 		DescendantFont: &PdfFont{
 			context: cidfont,
 		},
 		Encoding: core.MakeName("Identity-H"),
 		encoder:  ttf.NewEncoder(),
+		shaping:  newFontShaping(&ttf),
 	}
 
 	// Generate CMap for the Type 0 font, which is the inverse of ttf.Chars.
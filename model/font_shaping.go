@@ -0,0 +1,271 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/internal/bidi"
+	"github.com/carmel/unipdf/internal/cmap"
+	"github.com/carmel/unipdf/internal/textencoding"
+	"github.com/carmel/unipdf/model/internal/fonts"
+)
+
+// ShapeOptions are the options of text shaping.
+type ShapeOptions struct {
+	// RightToLeft is true for text of a right-to-left run, e.g. Arabic or Hebrew. The glyphs are
+	// then mirrored where needed and returned in visual order, i.e. from right to left reversed.
+	RightToLeft bool
+
+	// Features enables (true) or disables (false) OpenType features by tag, e.g. "liga", "kern",
+	// "smcp", "tnum" or "onum". The features not in the map keep their default state: the
+	// features of the scripts, kerning and standard ligatures are enabled, others are disabled.
+	// Features only apply to fonts supporting shaping.
+	Features map[string]bool
+}
+
+// ShapedGlyph is a glyph of shaped text. Metrics are in glyph space units, i.e. thousandths of the
+// font size.
+type ShapedGlyph struct {
+	// Code is the encoded character code of the glyph.
+	Code []byte
+	// Cluster is the index of the first rune of the text the glyph belongs to. The glyphs of a
+	// cluster, e.g. a ligature or an Indic syllable, can only be broken apart as a whole.
+	Cluster int
+	// Text is the text the glyph represents. It is empty for the glyphs sharing the text of a
+	// preceding glyph of the cluster.
+	Text []rune
+	// Width is the width of the glyph in the font dictionary.
+	Width float64
+	// Advance is the horizontal advance of the glyph after positioning, e.g. kerning.
+	Advance float64
+	// XOffset and YOffset are the displacement of the glyph from its nominal origin.
+	XOffset, YOffset float64
+}
+
+// CanShape returns true if `font` supports complex text shaping, i.e. is a composite font loaded
+// from a TrueType font with OpenType layout or kerning tables.
+func (font *PdfFont) CanShape() bool {
+	t, ok := font.context.(*pdfFontType0)
+	return ok && t.shaping != nil
+}
+
+// ShapeText converts `runes` to the glyphs displaying them. Fonts supporting shaping apply the
+// OpenType substitutions and positioning of the text's scripts: contextual forms, ligatures,
+// reordering of Indic syllables, mark attachment and kerning, along with the features of `opts`.
+// Other fonts map each rune to a glyph. Runes the font has no glyph for are dropped.
+func (font *PdfFont) ShapeText(runes []rune, opts ShapeOptions) ([]ShapedGlyph, error) {
+	if t, ok := font.context.(*pdfFontType0); ok && t.shaping != nil {
+		return t.shaping.shape(t, runes, opts), nil
+	}
+
+	glyphs := make([]ShapedGlyph, 0, len(runes))
+	for i, r := range runes {
+		glyph := r
+		if opts.RightToLeft {
+			if m, ok := bidi.Mirror(r); ok {
+				glyph = m
+			}
+		}
+		code, numMisses := font.RunesToCharcodeBytes([]rune{glyph})
+		if numMisses > 0 || len(code) == 0 {
+			common.Log.Debug("ShapeText: no glyph for rune %q in font %s", r, font)
+			continue
+		}
+		metrics, _ := font.GetRuneMetrics(glyph)
+		glyphs = append(glyphs, ShapedGlyph{
+			Code:    code,
+			Cluster: i,
+			Text:    []rune{r},
+			Width:   metrics.Wx,
+			Advance: metrics.Wx,
+		})
+	}
+	if opts.RightToLeft {
+		for i, j := 0, len(glyphs)-1; i < j; i, j = i+1, j-1 {
+			glyphs[i], glyphs[j] = glyphs[j], glyphs[i]
+		}
+	}
+	return glyphs, nil
+}
+
+// fontShaping is the shaping state of a composite font loaded from a TrueType font.
+type fontShaping struct {
+	mu     sync.Mutex
+	ttf    *fonts.TtfType
+	shaper *fonts.Shaper
+	// nominal maps the glyphs of the font's cmap to the rune the ToUnicode CMap maps them to.
+	nominal map[fonts.GID]rune
+	// runes are the runes of the ToUnicode CMap, restricted to the used runes after subsetting.
+	runes map[rune]fonts.GID
+	// glyphText is the text of the shaped glyphs that are not mapped from a single rune.
+	glyphText map[fonts.GID]string
+	// used are the glyphs used by shaped text.
+	used map[fonts.GID]struct{}
+	// dirty is true when the ToUnicode CMap misses glyphs of `glyphText`.
+	dirty bool
+}
+
+// newFontShaping returns the shaping state of a font loaded from `ttf`, or nil if the font has no
+// layout tables.
+func newFontShaping(ttf *fonts.TtfType) *fontShaping {
+	if !ttf.HasLayoutTables() {
+		return nil
+	}
+	// The encoder prunes the runes of the font when subsetting.
+	own := *ttf
+	own.Chars = maps.Clone(ttf.Chars)
+	ttf = &own
+
+	nominal := make(map[fonts.GID]rune, len(ttf.Chars))
+	for r, gid := range ttf.Chars {
+		if rn, ok := nominal[gid]; !ok || r < rn {
+			nominal[gid] = r
+		}
+	}
+	return &fontShaping{
+		ttf:       ttf,
+		shaper:    fonts.NewShaper(ttf),
+		nominal:   nominal,
+		runes:     ttf.Chars,
+		glyphText: map[fonts.GID]string{},
+		used:      map[fonts.GID]struct{}{},
+	}
+}
+
+// hasGlyph returns true if the font has a glyph for `r`.
+func (s *fontShaping) hasGlyph(r rune) bool {
+	gid, ok := s.ttf.Chars[r]
+	return ok && gid != 0
+}
+
+// shape shapes `runes` with the font `t` and registers the glyphs used.
+func (s *fontShaping) shape(t *pdfFontType0, runes []rune, opts ShapeOptions) []ShapedGlyph {
+	shaped := s.shaper.Shape(runes, fonts.ShapeOptions{
+		RightToLeft: opts.RightToLeft,
+		Features:    opts.Features,
+	})
+	k := 1000.0 / float64(s.ttf.UnitsPerEm)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	glyphs := make([]ShapedGlyph, 0, len(shaped))
+	for _, g := range shaped {
+		if g.GID == 0 {
+			common.Log.Debug("ShapeText: no glyph for %q in font %s", string(g.Text), t.basefont)
+			continue
+		}
+		width := 0.0
+		if int(g.GID) < len(s.ttf.Widths) {
+			width = float64(int(k * float64(s.ttf.Widths[g.GID])))
+		}
+		s.register(t, g, width)
+		glyphs = append(glyphs, ShapedGlyph{
+			Code:    []byte{byte(g.GID >> 8), byte(g.GID)},
+			Cluster: g.Cluster,
+			Text:    g.Text,
+			Width:   width,
+			Advance: k * float64(g.XAdvance),
+			XOffset: k * float64(g.XOffset),
+			YOffset: k * float64(g.YOffset),
+		})
+	}
+	return glyphs
+}
+
+// register records the use of the glyph `g` of width `width`, adding it to the widths of the
+// font and to the ToUnicode CMap when it is not mapped from a rune.
+func (s *fontShaping) register(t *pdfFontType0, g fonts.ShapedGlyph, width float64) {
+	s.used[g.GID] = struct{}{}
+	r, nominal := s.nominal[g.GID]
+	if nominal && t.encoder != nil {
+		// Register the rune for subsetting.
+		t.encoder.RuneToCharcode(r)
+	}
+	if !nominal {
+		if cidfont, ok := t.DescendantFont.context.(*pdfCIDFontType2); ok {
+			if _, done := cidfont.widths[textencoding.CharCode(g.GID)]; !done {
+				if cidfont.widths == nil {
+					cidfont.widths = map[textencoding.CharCode]float64{}
+				}
+				cidfont.widths[textencoding.CharCode(g.GID)] = width
+				if wArr, ok := core.GetArray(cidfont.W); ok {
+					wArr.Append(core.MakeInteger(int64(g.GID)), core.MakeInteger(int64(g.GID)),
+						core.MakeInteger(int64(width)))
+				}
+			}
+		}
+	}
+
+	// Glyphs of substituted forms map to the text they display, e.g. ligatures or the
+	// presentation forms of Arabic letters.
+	if len(g.Text) == 0 || (nominal && !isPresentationForm(r)) {
+		return
+	}
+	if _, ok := s.glyphText[g.GID]; !ok {
+		s.glyphText[g.GID] = string(g.Text)
+		s.dirty = true
+	}
+}
+
+// isPresentationForm returns true if `r` is an Unicode presentation form, i.e. a compatibility
+// character for a glyph of text normally written with other runes.
+func isPresentationForm(r rune) bool {
+	return r >= 0xFB00 && r <= 0xFDFF || r >= 0xFE70 && r <= 0xFEFF
+}
+
+// updateToUnicode rebuilds the ToUnicode CMap of `t` when glyphs were added by shaping.
+func (s *fontShaping) updateToUnicode(t *pdfFontType0) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty || t.toUnicodeCmap == nil {
+		return
+	}
+	t.toUnicodeCmap = s.toUnicode()
+	s.dirty = false
+}
+
+// toUnicode returns the ToUnicode CMap mapping the glyphs of the runes to them and the shaped
+// glyphs to their text.
+func (s *fontShaping) toUnicode() *cmap.CMap {
+	codeToUnicode := make(map[cmap.CharCode]string, len(s.runes)+len(s.glyphText))
+	lowest := make(map[cmap.CharCode]rune, len(s.runes))
+	for r, gid := range s.runes {
+		code := cmap.CharCode(gid)
+		if rn, ok := lowest[code]; !ok || r < rn {
+			lowest[code] = r
+			codeToUnicode[code] = string(r)
+		}
+	}
+	for gid, text := range s.glyphText {
+		codeToUnicode[cmap.CharCode(gid)] = text
+	}
+	return cmap.NewToUnicodeCMapFromStrings(codeToUnicode)
+}
+
+// subsetGlyphs returns the glyphs used by shaped text, and restricts the ToUnicode CMap to the
+// runes `runes` and the used glyphs.
+func (s *fontShaping) subsetGlyphs(runes []rune) []fonts.GID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runes = make(map[rune]fonts.GID, len(runes))
+	for _, r := range runes {
+		if gid, ok := s.ttf.Chars[r]; ok {
+			s.runes[r] = gid
+		}
+	}
+	s.dirty = true
+
+	gids := make([]fonts.GID, 0, len(s.used))
+	for gid := range s.used {
+		gids = append(gids, gid)
+	}
+	return gids
+}
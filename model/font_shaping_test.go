@@ -0,0 +1,63 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+func TestShapeTextComposite(t *testing.T) {
+	font, err := NewCompositePdfFontFromTTFFile("../creator/testdata/FreeSans.ttf")
+	require.NoError(t, err)
+	require.True(t, font.CanShape())
+
+	// The conjunct is a single glyph no rune maps to.
+	glyphs, err := font.ShapeText([]rune("प्र"), ShapeOptions{})
+	require.NoError(t, err)
+	require.Len(t, glyphs, 1)
+	require.Equal(t, "प्र", string(glyphs[0].Text))
+	require.Greater(t, glyphs[0].Width, 0.0)
+	code := glyphs[0].Code
+
+	// The glyph is added to the widths and the ToUnicode CMap of the font.
+	font.ToPdfObject()
+	text, _, numMisses := font.CharcodeBytesToUnicode(code)
+	require.Zero(t, numMisses)
+	require.Equal(t, "प्र", text)
+
+	t0 := font.context.(*pdfFontType0)
+	wArr, ok := core.GetArray(t0.DescendantFont.context.(*pdfCIDFontType2).W)
+	require.True(t, ok)
+	n := wArr.Len()
+	gid, _ := core.GetIntVal(wArr.Get(n - 3))
+	require.Equal(t, int(code[0])<<8|int(code[1]), gid)
+
+	// Right-to-left text is returned in visual order.
+	glyphs, err = font.ShapeText([]rune("אב"), ShapeOptions{RightToLeft: true})
+	require.NoError(t, err)
+	require.Len(t, glyphs, 2)
+	require.Equal(t, "ב", string(glyphs[0].Text))
+	require.Equal(t, 1, glyphs[0].Cluster)
+}
+
+func TestShapeTextSimple(t *testing.T) {
+	font, err := NewStandard14Font(HelveticaName)
+	require.NoError(t, err)
+	require.False(t, font.CanShape())
+
+	glyphs, err := font.ShapeText([]rune("a(b"), ShapeOptions{RightToLeft: true})
+	require.NoError(t, err)
+	require.Len(t, glyphs, 3)
+	require.Equal(t, []byte("b"), glyphs[0].Code)
+	require.Equal(t, []byte(")"), glyphs[1].Code)
+	require.Equal(t, "(", string(glyphs[1].Text))
+	require.Equal(t, 2, glyphs[0].Cluster)
+	require.Equal(t, 556.0, glyphs[0].Width)
+}
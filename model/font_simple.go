@@ -428,6 +428,9 @@ func NewPdfFontFromTTF(r io.ReadSeeker) (*PdfFont, error) {
 	truefont.LastChar = core.MakeInteger(int64(maxCode))
 
 	k := 1000.0 / float64(ttf.UnitsPerEm)
+	truefont.underlinePosition = k * float64(ttf.UnderlinePosition)
+	truefont.underlineThickness = k * float64(ttf.UnderlineThickness)
+
 	if len(ttf.Widths) <= 0 {
 		return nil, errors.New("ERROR: Missing required attribute (Widths)")
 	}
@@ -885,3 +885,37 @@ func TestNewFontFromFile(t *testing.T) {
 		t.Fatalf("Failed to load font from file. err=%v", err)
 	}
 }
+
+func TestFontHasGlyph(t *testing.T) {
+	helvetica, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	require.True(t, helvetica.HasGlyph('a'))
+	require.True(t, helvetica.HasGlyph('€'))
+	require.False(t, helvetica.HasGlyph('中'))
+
+	freeSans, err := model.NewCompositePdfFontFromTTFFile("../creator/testdata/FreeSans.ttf")
+	require.NoError(t, err)
+	require.True(t, freeSans.HasGlyph('a'))
+	require.True(t, freeSans.HasGlyph('ש'))
+	require.False(t, freeSans.HasGlyph('中'))
+}
+
+func TestFontUnderlineMetrics(t *testing.T) {
+	helvetica, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+	position, thickness, ok := helvetica.UnderlineMetrics()
+	require.True(t, ok)
+	require.Equal(t, -100.0, position)
+	require.Equal(t, 50.0, thickness)
+
+	for _, load := range []func(string) (*model.PdfFont, error){
+		model.NewPdfFontFromTTFFile, model.NewCompositePdfFontFromTTFFile,
+	} {
+		font, err := load("../creator/testdata/FreeSans.ttf")
+		require.NoError(t, err)
+		position, thickness, ok = font.UnderlineMetrics()
+		require.True(t, ok)
+		require.Less(t, position, 0.0)
+		require.Greater(t, thickness, 0.0)
+	}
+}
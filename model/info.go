@@ -0,0 +1,301 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// PdfInfoTrapped specifies whether the document has been modified to include trapping
+// information (Trapped entry of the document information dictionary).
+type PdfInfoTrapped string
+
+// Values of the Trapped entry.
+const (
+	TrappedTrue    PdfInfoTrapped = "True"
+	TrappedFalse   PdfInfoTrapped = "False"
+	TrappedUnknown PdfInfoTrapped = "Unknown"
+)
+
+// PdfInfo represents the document information dictionary (section 14.3.3 PDF32000_2008).
+type PdfInfo struct {
+	Title        *core.PdfObjectString
+	Author       *core.PdfObjectString
+	Subject      *core.PdfObjectString
+	Keywords     *core.PdfObjectString
+	Creator      *core.PdfObjectString
+	Producer     *core.PdfObjectString
+	CreationDate *PdfDate
+	ModifiedDate *PdfDate
+	Trapped      *PdfInfoTrapped
+
+	customInfo *core.PdfObjectDictionary
+}
+
+// standardInfoKeys are the keys of the document information dictionary defined by the standard.
+var standardInfoKeys = map[core.PdfObjectName]bool{
+	"Title": true, "Author": true, "Subject": true, "Keywords": true, "Creator": true,
+	"Producer": true, "CreationDate": true, "ModDate": true, "Trapped": true,
+}
+
+// NewPdfInfoFromObject loads a document information dictionary from `obj`. Invalid dates and
+// entries are ignored.
+func NewPdfInfoFromObject(obj core.PdfObject) (*PdfInfo, error) {
+	dict, ok := core.GetDict(obj)
+	if !ok {
+		return nil, ErrTypeCheck
+	}
+
+	info := &PdfInfo{}
+	info.Title, _ = core.GetString(dict.Get("Title"))
+	info.Author, _ = core.GetString(dict.Get("Author"))
+	info.Subject, _ = core.GetString(dict.Get("Subject"))
+	info.Keywords, _ = core.GetString(dict.Get("Keywords"))
+	info.Creator, _ = core.GetString(dict.Get("Creator"))
+	info.Producer, _ = core.GetString(dict.Get("Producer"))
+	info.CreationDate = infoDate(dict.Get("CreationDate"))
+	info.ModifiedDate = infoDate(dict.Get("ModDate"))
+	if name, ok := core.GetNameVal(dict.Get("Trapped")); ok {
+		trapped := PdfInfoTrapped(name)
+		info.Trapped = &trapped
+	} else if b, ok := core.GetBoolVal(dict.Get("Trapped")); ok {
+		// Some producers write a boolean instead of a name.
+		trapped := TrappedFalse
+		if b {
+			trapped = TrappedTrue
+		}
+		info.Trapped = &trapped
+	}
+
+	for _, key := range dict.Keys() {
+		if standardInfoKeys[key] {
+			continue
+		}
+		if s, ok := core.GetString(dict.Get(key)); ok {
+			if info.customInfo == nil {
+				info.customInfo = core.MakeDict()
+			}
+			info.customInfo.Set(key, s)
+		}
+	}
+	return info, nil
+}
+
+// infoDate returns the date represented by `obj`, or nil if `obj` is not a valid date string.
+func infoDate(obj core.PdfObject) *PdfDate {
+	s, ok := core.GetString(obj)
+	if !ok {
+		return nil
+	}
+	date, err := NewPdfDate(s.Str())
+	if err != nil {
+		common.Log.Debug("ERROR: invalid document information date %q: %v", s.Str(), err)
+		return nil
+	}
+	return &date
+}
+
+// CustomKeys returns the keys of the non-standard entries, sorted.
+func (info *PdfInfo) CustomKeys() []string {
+	if info.customInfo == nil {
+		return nil
+	}
+	var keys []string
+	for _, key := range info.customInfo.Keys() {
+		keys = append(keys, string(key))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CustomInfo returns the value of non-standard entry `name`, or nil if not present.
+func (info *PdfInfo) CustomInfo(name string) *core.PdfObjectString {
+	if info.customInfo == nil {
+		return nil
+	}
+	s, _ := core.GetString(info.customInfo.Get(core.PdfObjectName(name)))
+	return s
+}
+
+// SetCustomInfo sets non-standard entry `name` to `value`. An empty value removes the entry.
+func (info *PdfInfo) SetCustomInfo(name string, value string) error {
+	key := core.PdfObjectName(name)
+	if name == "" || standardInfoKeys[key] {
+		return errors.New("invalid custom info key")
+	}
+	if value == "" {
+		if info.customInfo != nil {
+			info.customInfo.Remove(key)
+		}
+		return nil
+	}
+	if info.customInfo == nil {
+		info.customInfo = core.MakeDict()
+	}
+	info.customInfo.Set(key, makeTextString(value))
+	return nil
+}
+
+// ToPdfObject returns the document information dictionary.
+func (info *PdfInfo) ToPdfObject() core.PdfObject {
+	dict := core.MakeDict()
+	for _, entry := range []struct {
+		key   core.PdfObjectName
+		value *core.PdfObjectString
+	}{
+		{"Title", info.Title},
+		{"Author", info.Author},
+		{"Subject", info.Subject},
+		{"Keywords", info.Keywords},
+		{"Creator", info.Creator},
+		{"Producer", info.Producer},
+	} {
+		if entry.value != nil {
+			dict.Set(entry.key, entry.value)
+		}
+	}
+	if info.CreationDate != nil {
+		dict.Set("CreationDate", info.CreationDate.ToPdfObject())
+	}
+	if info.ModifiedDate != nil {
+		dict.Set("ModDate", info.ModifiedDate.ToPdfObject())
+	}
+	if info.Trapped != nil {
+		dict.Set("Trapped", core.MakeName(string(*info.Trapped)))
+	}
+	if info.customInfo != nil {
+		for _, key := range info.customInfo.Keys() {
+			dict.Set(key, info.customInfo.Get(key))
+		}
+	}
+	return dict
+}
+
+// infoText returns the decoded value of `s`, or an empty string if nil.
+func infoText(s *core.PdfObjectString) string {
+	if s == nil {
+		return ""
+	}
+	return s.Decoded()
+}
+
+// xmpAuthorSeparator separates the entries of dc:creator in the Author entry.
+const xmpAuthorSeparator = "; "
+
+// UpdateXMP sets the properties of XMP packet `packet` that correspond to the entries of the
+// document information dictionary: dc:title, dc:creator, dc:description, pdf:Keywords,
+// xmp:CreatorTool, pdf:Producer, xmp:CreateDate, xmp:ModifyDate, xmp:MetadataDate and
+// pdf:Trapped. Custom entries are stored in the pdfx namespace. Properties whose values already
+// match are left untouched, so that their other items (e.g. other languages) are preserved.
+func (info *PdfInfo) UpdateXMP(packet *xmputil.Packet) {
+	if v := infoText(info.Title); v != "" && v != packet.Text(xmputil.NsDC, "title") {
+		packet.SetLangAlt(xmputil.NsDC, "title", v)
+	}
+	if v := infoText(info.Author); v != "" &&
+		v != strings.Join(packet.Values(xmputil.NsDC, "creator"), xmpAuthorSeparator) {
+		packet.SetArray(xmputil.NsDC, "creator", xmputil.KindSeq, []string{v})
+	}
+	if v := infoText(info.Subject); v != "" && v != packet.Text(xmputil.NsDC, "description") {
+		packet.SetLangAlt(xmputil.NsDC, "description", v)
+	}
+	for _, entry := range []struct {
+		value     *core.PdfObjectString
+		namespace string
+		name      string
+	}{
+		{info.Keywords, xmputil.NsPDF, "Keywords"},
+		{info.Creator, xmputil.NsXMP, "CreatorTool"},
+		{info.Producer, xmputil.NsPDF, "Producer"},
+	} {
+		if v := infoText(entry.value); v != "" && v != packet.Text(entry.namespace, entry.name) {
+			packet.SetText(entry.namespace, entry.name, v)
+		}
+	}
+
+	setDate := func(name string, date *PdfDate) {
+		if date == nil {
+			return
+		}
+		t := date.ToGoTime()
+		if old, ok := packet.Date(xmputil.NsXMP, name); !ok || !old.Equal(t) {
+			packet.SetDate(xmputil.NsXMP, name, t)
+		}
+	}
+	setDate("CreateDate", info.CreationDate)
+	setDate("ModifyDate", info.ModifiedDate)
+	setDate("MetadataDate", info.ModifiedDate)
+
+	if info.Trapped != nil {
+		packet.SetText(xmputil.NsPDF, "Trapped", string(*info.Trapped))
+	}
+	for _, key := range info.CustomKeys() {
+		v := infoText(info.CustomInfo(key))
+		if !isXMPName(key) || v == packet.Text(xmputil.NsPDFX, key) {
+			continue
+		}
+		packet.SetText(xmputil.NsPDFX, key, v)
+	}
+}
+
+// UpdateFromXMP sets the entries of the document information dictionary that are not set from
+// the corresponding properties of XMP packet `packet` (see UpdateXMP).
+func (info *PdfInfo) UpdateFromXMP(packet *xmputil.Packet) {
+	text := func(s **core.PdfObjectString, v string) {
+		if *s == nil && v != "" {
+			*s = makeTextString(v)
+		}
+	}
+	text(&info.Title, packet.Text(xmputil.NsDC, "title"))
+	text(&info.Author, strings.Join(packet.Values(xmputil.NsDC, "creator"), xmpAuthorSeparator))
+	text(&info.Subject, packet.Text(xmputil.NsDC, "description"))
+	text(&info.Keywords, packet.Text(xmputil.NsPDF, "Keywords"))
+	text(&info.Creator, packet.Text(xmputil.NsXMP, "CreatorTool"))
+	text(&info.Producer, packet.Text(xmputil.NsPDF, "Producer"))
+
+	date := func(d **PdfDate, name string) {
+		if *d != nil {
+			return
+		}
+		if t, ok := packet.Date(xmputil.NsXMP, name); ok {
+			if date, err := NewPdfDateFromTime(t); err == nil {
+				*d = &date
+			}
+		}
+	}
+	date(&info.CreationDate, "CreateDate")
+	date(&info.ModifiedDate, "ModifyDate")
+
+	if info.Trapped == nil {
+		switch trapped := PdfInfoTrapped(packet.Text(xmputil.NsPDF, "Trapped")); trapped {
+		case TrappedTrue, TrappedFalse, TrappedUnknown:
+			info.Trapped = &trapped
+		}
+	}
+	for _, prop := range packet.Properties() {
+		if prop.Namespace == xmputil.NsPDFX && prop.Kind == xmputil.KindText && info.CustomInfo(prop.Name) == nil {
+			info.SetCustomInfo(prop.Name, prop.Value)
+		}
+	}
+}
+
+// isXMPName returns true if `name` can be used as the name of an XMP property.
+func isXMPName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || '0' <= r && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
@@ -0,0 +1,122 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// writeInfoTestFile writes a single page document with document information `info` and XMP
+// metadata `packet` and returns a reader for it.
+func writeInfoTestFile(t *testing.T, info *PdfInfo, packet *xmputil.Packet) (*PdfReader, []byte) {
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(NewPdfPage()))
+	w.SetDocInfo(info)
+	w.SetXMP(packet)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader, buf.Bytes()
+}
+
+func TestPdfInfoRoundTrip(t *testing.T) {
+	created, err := NewPdfDateFromTime(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	require.NoError(t, err)
+	trapped := TrappedFalse
+	info := &PdfInfo{
+		Title:        makeTextString("Grüße"),
+		Author:       core.MakeString("Author"),
+		Producer:     core.MakeString("Producer"),
+		CreationDate: &created,
+		Trapped:      &trapped,
+	}
+	require.NoError(t, info.SetCustomInfo("Department", "Sales"))
+	require.Error(t, info.SetCustomInfo("Title", "Other"))
+
+	reader, _ := writeInfoTestFile(t, info, nil)
+	read, err := reader.GetPdfInfo()
+	require.NoError(t, err)
+	require.Equal(t, "Grüße", read.Title.Decoded())
+	require.Equal(t, "Author", read.Author.Decoded())
+	require.Nil(t, read.Creator)
+	require.Equal(t, TrappedFalse, *read.Trapped)
+	require.True(t, read.CreationDate.ToGoTime().Equal(created.ToGoTime()))
+	require.Equal(t, []string{"Department"}, read.CustomKeys())
+	require.Equal(t, "Sales", read.CustomInfo("Department").Decoded())
+
+	// No metadata stream is written unless requested.
+	packet, err := reader.GetXMP()
+	require.NoError(t, err)
+	require.Nil(t, packet)
+}
+
+func TestPdfInfoXMPSync(t *testing.T) {
+	packet := xmputil.New()
+	packet.SetLangAlt(xmputil.NsDC, "title", "XMP title")
+	packet.SetText(xmputil.NsPDF, "Keywords", "a, b")
+	packet.SetText(xmputil.NsPDFX, "Project", "Test")
+	packet.SetText("http://example.com/ns/", "custom", "value")
+
+	info := &PdfInfo{Title: core.MakeString("Info title"), Author: core.MakeString("Author")}
+	reader, _ := writeInfoTestFile(t, info, packet)
+
+	read, err := reader.GetPdfInfo()
+	require.NoError(t, err)
+	require.Equal(t, "Info title", read.Title.Decoded())
+	require.Equal(t, "a, b", read.Keywords.Decoded())
+	require.Equal(t, "Test", read.CustomInfo("Project").Decoded())
+
+	xmp, err := reader.GetXMP()
+	require.NoError(t, err)
+	require.NotNil(t, xmp)
+	require.Equal(t, "Info title", xmp.Text(xmputil.NsDC, "title"))
+	require.Equal(t, []string{"Author"}, xmp.Values(xmputil.NsDC, "creator"))
+	require.Equal(t, "a, b", xmp.Text(xmputil.NsPDF, "Keywords"))
+	require.Equal(t, "value", xmp.Text("http://example.com/ns/", "custom"))
+
+	// Entries missing from the information dictionary are taken from XMP.
+	info = &PdfInfo{}
+	info.UpdateFromXMP(xmp)
+	require.Equal(t, "Info title", info.Title.Decoded())
+	require.Equal(t, "Author", info.Author.Decoded())
+}
+
+func TestAppenderSetDocInfo(t *testing.T) {
+	reader, _ := writeInfoTestFile(t, &PdfInfo{Title: core.MakeString("Original")}, nil)
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+
+	info, err := reader.GetPdfInfo()
+	require.NoError(t, err)
+	info.Title = core.MakeString("Updated")
+	appender.SetDocInfo(info)
+	packet := xmputil.New()
+	packet.SetText(xmputil.NsXMP, "Rating", "3")
+	appender.SetXMP(packet)
+
+	var buf bytes.Buffer
+	require.NoError(t, appender.Write(&buf))
+	updated, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	read, err := updated.GetPdfInfo()
+	require.NoError(t, err)
+	require.Equal(t, "Updated", read.Title.Decoded())
+	xmp, err := updated.GetXMP()
+	require.NoError(t, err)
+	require.NotNil(t, xmp)
+	require.Equal(t, "Updated", xmp.Text(xmputil.NsDC, "title"))
+	require.Equal(t, "3", xmp.Text(xmputil.NsXMP, "Rating"))
+}
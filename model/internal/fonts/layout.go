@@ -0,0 +1,1012 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"errors"
+)
+
+// This file parses the OpenType layout tables (GDEF, GSUB and GPOS) used to shape text.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/chapter2
+//
+// The parsing is bounds safe: reads past the end of the table data return zero values so that
+// malformed tables result in missing substitutions and positionings rather than failures.
+
+// errLayoutTable is returned for layout tables that cannot be parsed.
+var errLayoutTable = errors.New("invalid layout table")
+
+// Lookup flags.
+const (
+	lookupRightToLeft         = 0x0001
+	lookupIgnoreBaseGlyphs    = 0x0002
+	lookupIgnoreLigatures     = 0x0004
+	lookupIgnoreMarks         = 0x0008
+	lookupUseMarkFilteringSet = 0x0010
+	lookupMarkAttachmentType  = 0xFF00
+)
+
+// Glyph classes of the GDEF table.
+const (
+	glyphClassBase      = 1
+	glyphClassLigature  = 2
+	glyphClassMark      = 3
+	glyphClassComponent = 4
+)
+
+// maxLayoutNesting limits the depth of extension subtables and nested lookups.
+const maxLayoutNesting = 8
+
+// layoutData is the data of a layout table, read from offsets relative to its start.
+type layoutData []byte
+
+// u16 returns the big endian unsigned 16 bit integer at `off`.
+func (d layoutData) u16(off int) uint16 {
+	if off < 0 || off+2 > len(d) {
+		return 0
+	}
+	return uint16(d[off])<<8 | uint16(d[off+1])
+}
+
+// i16 returns the big endian signed 16 bit integer at `off`.
+func (d layoutData) i16(off int) int16 {
+	return int16(d.u16(off))
+}
+
+// u32 returns the big endian unsigned 32 bit integer at `off`.
+func (d layoutData) u32(off int) uint32 {
+	return uint32(d.u16(off))<<16 | uint32(d.u16(off+2))
+}
+
+// tag returns the 4 byte tag at `off`.
+func (d layoutData) tag(off int) string {
+	if off < 0 || off+4 > len(d) {
+		return ""
+	}
+	return string(d[off : off+4])
+}
+
+// at returns the data from `off`, or nil if `off` is out of bounds.
+func (d layoutData) at(off int) layoutData {
+	if off <= 0 || off >= len(d) {
+		return nil
+	}
+	return d[off:]
+}
+
+// offset returns the data at the 16 bit offset stored at `off`, or nil for a null offset.
+func (d layoutData) offset(off int) layoutData {
+	return d.at(int(d.u16(off)))
+}
+
+// coverage maps the glyphs of a coverage table to their coverage index.
+type coverage map[GID]int
+
+// parseCoverage parses the coverage table `d`.
+func parseCoverage(d layoutData) coverage {
+	cov := make(coverage)
+	switch d.u16(0) {
+	case 1:
+		n := int(d.u16(2))
+		for i := 0; i < n; i++ {
+			cov[GID(d.u16(4+2*i))] = i
+		}
+	case 2:
+		n := int(d.u16(2))
+		for i := 0; i < n; i++ {
+			rec := 4 + 6*i
+			start, end, idx := d.u16(rec), d.u16(rec+2), int(d.u16(rec+4))
+			for g := int(start); g <= int(end); g++ {
+				cov[GID(g)] = idx + g - int(start)
+			}
+		}
+	}
+	return cov
+}
+
+// classDef maps glyphs to their class. Glyphs not in the map are in class 0.
+type classDef map[GID]uint16
+
+// parseClassDef parses the class definition table `d`.
+func parseClassDef(d layoutData) classDef {
+	cd := make(classDef)
+	switch d.u16(0) {
+	case 1:
+		start := int(d.u16(2))
+		n := int(d.u16(4))
+		for i := 0; i < n; i++ {
+			if c := d.u16(6 + 2*i); c != 0 {
+				cd[GID(start+i)] = c
+			}
+		}
+	case 2:
+		n := int(d.u16(2))
+		for i := 0; i < n; i++ {
+			rec := 4 + 6*i
+			start, end, c := d.u16(rec), d.u16(rec+2), d.u16(rec+4)
+			if c == 0 {
+				continue
+			}
+			for g := int(start); g <= int(end); g++ {
+				cd[GID(g)] = c
+			}
+		}
+	}
+	return cd
+}
+
+// gdefTable is the glyph definition table.
+type gdefTable struct {
+	glyphClasses     classDef
+	markAttachClass  classDef
+	markGlyphSets    []coverage
+	hasGlyphClasses  bool
+	hasMarkGlyphSets bool
+}
+
+// parseGDEF parses the GDEF table `d`.
+func parseGDEF(d layoutData) (*gdefTable, error) {
+	if len(d) < 12 || d.u16(0) != 1 {
+		return nil, errLayoutTable
+	}
+	gdef := &gdefTable{
+		glyphClasses:    classDef{},
+		markAttachClass: classDef{},
+	}
+	if cd := d.offset(4); cd != nil {
+		gdef.glyphClasses = parseClassDef(cd)
+		gdef.hasGlyphClasses = true
+	}
+	if cd := d.offset(10); cd != nil {
+		gdef.markAttachClass = parseClassDef(cd)
+	}
+	if d.u16(2) >= 2 {
+		if sets := d.offset(12); sets != nil {
+			n := int(sets.u16(2))
+			for i := 0; i < n; i++ {
+				cov := coverage{}
+				if c := sets.at(int(sets.u32(4 + 4*i))); c != nil {
+					cov = parseCoverage(c)
+				}
+				gdef.markGlyphSets = append(gdef.markGlyphSets, cov)
+			}
+			gdef.hasMarkGlyphSets = true
+		}
+	}
+	return gdef, nil
+}
+
+// layoutTable is a GSUB or GPOS table.
+type layoutTable struct {
+	scripts  map[string]*scriptTable
+	features []featureRecord
+	lookups  []*lookupTable
+}
+
+// scriptTable lists the language systems of a script.
+type scriptTable struct {
+	defaultLang *langSys
+	langs       map[string]*langSys
+}
+
+// langSys lists the features of a language system as indices in the feature list.
+type langSys struct {
+	required int
+	features []int
+}
+
+// featureRecord is a feature with the indices of its lookups in the lookup list.
+type featureRecord struct {
+	tag     string
+	lookups []int
+}
+
+// lookupTable is a lookup of a GSUB or GPOS table. All the subtables have the same type, that of
+// the extended subtables for extension lookups.
+type lookupTable struct {
+	typ       uint16
+	flag      uint16
+	markSet   int
+	subtables []interface{}
+}
+
+// parseLayoutTable parses the GSUB or GPOS table `d`.
+func parseLayoutTable(d layoutData, isGPOS bool) (*layoutTable, error) {
+	if len(d) < 10 || d.u16(0) != 1 {
+		return nil, errLayoutTable
+	}
+	t := &layoutTable{scripts: map[string]*scriptTable{}}
+
+	if sl := d.offset(4); sl != nil {
+		n := int(sl.u16(0))
+		for i := 0; i < n; i++ {
+			rec := 2 + 6*i
+			st := sl.offset(rec + 4)
+			if st == nil {
+				continue
+			}
+			script := &scriptTable{langs: map[string]*langSys{}}
+			if ls := st.offset(0); ls != nil {
+				script.defaultLang = parseLangSys(ls)
+			}
+			nl := int(st.u16(2))
+			for j := 0; j < nl; j++ {
+				lrec := 4 + 6*j
+				if ls := st.offset(lrec + 4); ls != nil {
+					script.langs[st.tag(lrec)] = parseLangSys(ls)
+				}
+			}
+			t.scripts[sl.tag(rec)] = script
+		}
+	}
+
+	if fl := d.offset(6); fl != nil {
+		n := int(fl.u16(0))
+		for i := 0; i < n; i++ {
+			rec := 2 + 6*i
+			feature := featureRecord{tag: fl.tag(rec)}
+			if ft := fl.offset(rec + 4); ft != nil {
+				nl := int(ft.u16(2))
+				for j := 0; j < nl; j++ {
+					feature.lookups = append(feature.lookups, int(ft.u16(4+2*j)))
+				}
+			}
+			t.features = append(t.features, feature)
+		}
+	}
+
+	if ll := d.offset(8); ll != nil {
+		n := int(ll.u16(0))
+		for i := 0; i < n; i++ {
+			t.lookups = append(t.lookups, parseLookup(ll.offset(2+2*i), isGPOS))
+		}
+	}
+	return t, nil
+}
+
+// parseLangSys parses the language system table `d`.
+func parseLangSys(d layoutData) *langSys {
+	ls := &langSys{required: -1}
+	if req := d.u16(2); req != 0xFFFF {
+		ls.required = int(req)
+	}
+	n := int(d.u16(4))
+	for i := 0; i < n; i++ {
+		ls.features = append(ls.features, int(d.u16(6+2*i)))
+	}
+	return ls
+}
+
+// parseLookup parses the lookup table `d`.
+func parseLookup(d layoutData, isGPOS bool) *lookupTable {
+	l := &lookupTable{typ: d.u16(0), flag: d.u16(2), markSet: -1}
+	n := int(d.u16(4))
+	if l.flag&lookupUseMarkFilteringSet != 0 {
+		l.markSet = int(d.u16(6 + 2*n))
+	}
+	extension := uint16(7)
+	if isGPOS {
+		extension = 9
+	}
+	for i := 0; i < n; i++ {
+		st := d.offset(6 + 2*i)
+		typ := l.typ
+		if typ == extension {
+			// Extension subtables hold the 32 bit offset of a subtable of another type.
+			typ = st.u16(2)
+			st = st.at(int(st.u32(4)))
+			if typ == extension {
+				continue
+			}
+			l.typ = typ
+		}
+		var sub interface{}
+		if isGPOS {
+			sub = parseGPOSSubtable(st, typ)
+		} else {
+			sub = parseGSUBSubtable(st, typ)
+		}
+		if sub != nil {
+			l.subtables = append(l.subtables, sub)
+		}
+	}
+	return l
+}
+
+// Lookup types of the GSUB table.
+const (
+	gsubSingle             = 1
+	gsubMultiple           = 2
+	gsubAlternate          = 3
+	gsubLigature           = 4
+	gsubContext            = 5
+	gsubChainingContext    = 6
+	gsubReverseChainSingle = 8
+)
+
+// Lookup types of the GPOS table.
+const (
+	gposSingle          = 1
+	gposPair            = 2
+	gposCursive         = 3
+	gposMarkToBase      = 4
+	gposMarkToLigature  = 5
+	gposMarkToMark      = 6
+	gposContext         = 7
+	gposChainingContext = 8
+)
+
+// singleSubst replaces glyphs by other glyphs.
+type singleSubst map[GID]GID
+
+// multipleSubst replaces glyphs by sequences of glyphs. It is also used for alternate
+// substitutions, the glyphs being replaced by one of the alternates.
+type multipleSubst map[GID][]GID
+
+// ligature is a ligature glyph with the components that follow the first one.
+type ligature struct {
+	glyph      GID
+	components []GID
+}
+
+// ligatureSubst replaces sequences of glyphs starting with the keys by ligatures, in order of
+// preference.
+type ligatureSubst map[GID][]ligature
+
+// alternateSubst replaces glyphs by one of their alternates.
+type alternateSubst multipleSubst
+
+// reverseChainSubst is a reverse chaining contextual single substitution.
+type reverseChainSubst struct {
+	coverage    coverage
+	backtrack   []coverage
+	lookahead   []coverage
+	substitutes []GID
+}
+
+// parseGSUBSubtable parses the subtable `d` of the GSUB lookup type `typ`.
+func parseGSUBSubtable(d layoutData, typ uint16) interface{} {
+	if d == nil {
+		return nil
+	}
+	format := d.u16(0)
+	switch typ {
+	case gsubSingle:
+		cov := parseCoverage(d.offset(2))
+		subst := make(singleSubst, len(cov))
+		for g, idx := range cov {
+			switch format {
+			case 1:
+				subst[g] = GID(uint16(int(g) + int(d.i16(4))))
+			case 2:
+				if idx < int(d.u16(4)) {
+					subst[g] = GID(d.u16(6 + 2*idx))
+				}
+			}
+		}
+		return subst
+	case gsubMultiple, gsubAlternate:
+		cov := parseCoverage(d.offset(2))
+		subst := make(multipleSubst, len(cov))
+		n := int(d.u16(4))
+		for g, idx := range cov {
+			if idx >= n {
+				continue
+			}
+			seq := d.offset(6 + 2*idx)
+			glyphs := make([]GID, seq.u16(0))
+			for i := range glyphs {
+				glyphs[i] = GID(seq.u16(2 + 2*i))
+			}
+			subst[g] = glyphs
+		}
+		if typ == gsubAlternate {
+			return alternateSubst(subst)
+		}
+		return subst
+	case gsubLigature:
+		cov := parseCoverage(d.offset(2))
+		subst := make(ligatureSubst, len(cov))
+		n := int(d.u16(4))
+		for g, idx := range cov {
+			if idx >= n {
+				continue
+			}
+			set := d.offset(6 + 2*idx)
+			nl := int(set.u16(0))
+			for i := 0; i < nl; i++ {
+				lig := set.offset(2 + 2*i)
+				l := ligature{glyph: GID(lig.u16(0))}
+				nc := int(lig.u16(2))
+				for j := 1; j < nc; j++ {
+					l.components = append(l.components, GID(lig.u16(4+2*(j-1))))
+				}
+				subst[g] = append(subst[g], l)
+			}
+		}
+		return subst
+	case gsubContext:
+		if c := parseContext(d); c != nil {
+			return c
+		}
+	case gsubChainingContext:
+		if c := parseChainingContext(d); c != nil {
+			return c
+		}
+	case gsubReverseChainSingle:
+		if format != 1 {
+			return nil
+		}
+		r := &reverseChainSubst{coverage: parseCoverage(d.offset(2))}
+		off := 4
+		r.backtrack, off = parseCoverageList(d, off)
+		r.lookahead, off = parseCoverageList(d, off)
+		n := int(d.u16(off))
+		for i := 0; i < n; i++ {
+			r.substitutes = append(r.substitutes, GID(d.u16(off+2+2*i)))
+		}
+		return r
+	}
+	return nil
+}
+
+// parseCoverageList parses the list of coverage offsets at `off` in `d` and returns it with the
+// offset following the list.
+func parseCoverageList(d layoutData, off int) ([]coverage, int) {
+	n := int(d.u16(off))
+	covs := make([]coverage, n)
+	for i := range covs {
+		covs[i] = parseCoverage(d.offset(off + 2 + 2*i))
+	}
+	return covs, off + 2 + 2*n
+}
+
+// glyphMatcher matches the glyphs at a position of a context.
+type glyphMatcher interface {
+	matches(g GID) bool
+}
+
+// glyphMatch matches a glyph.
+type glyphMatch GID
+
+func (m glyphMatch) matches(g GID) bool {
+	return GID(m) == g
+}
+
+// classMatch matches the glyphs of a class.
+type classMatch struct {
+	classes classDef
+	class   uint16
+}
+
+func (m classMatch) matches(g GID) bool {
+	return m.classes[g] == m.class
+}
+
+// coverageMatch matches the glyphs of a coverage table.
+type coverageMatch coverage
+
+func (m coverageMatch) matches(g GID) bool {
+	_, ok := m[g]
+	return ok
+}
+
+// seqLookup is a lookup applied to a glyph of a matched input sequence.
+type seqLookup struct {
+	index  int
+	lookup int
+}
+
+// contextRule is a rule of a (chaining) contextual lookup. The backtrack sequence is ordered by
+// distance from the input, the first glyph of the input being matched by the coverage.
+type contextRule struct {
+	backtrack []glyphMatcher
+	input     []glyphMatcher
+	lookahead []glyphMatcher
+	lookups   []seqLookup
+}
+
+// contextSubtable is a contextual or chaining contextual subtable of any format. The rules applying
+// to a glyph are selected by its coverage index (format 1), its class (format 2) or are the single
+// rule (format 3).
+type contextSubtable struct {
+	format   uint16
+	coverage coverage
+	classes  classDef
+	ruleSets [][]contextRule
+}
+
+// rules returns the rules of `c` applying to sequences starting with glyph `g`.
+func (c *contextSubtable) rules(g GID) []contextRule {
+	idx, ok := c.coverage[g]
+	if !ok {
+		return nil
+	}
+	switch c.format {
+	case 2:
+		idx = int(c.classes[g])
+	case 3:
+		idx = 0
+	}
+	if idx >= len(c.ruleSets) {
+		return nil
+	}
+	return c.ruleSets[idx]
+}
+
+// parseSeqLookups parses `n` sequence lookup records at `off` in `d`.
+func parseSeqLookups(d layoutData, off, n int) []seqLookup {
+	lookups := make([]seqLookup, n)
+	for i := range lookups {
+		lookups[i] = seqLookup{index: int(d.u16(off + 4*i)), lookup: int(d.u16(off + 4*i + 2))}
+	}
+	return lookups
+}
+
+// parseMatchers parses `n` glyphs or classes at `off` in `d` as matchers.
+func parseMatchers(d layoutData, off, n int, classes classDef) []glyphMatcher {
+	matchers := make([]glyphMatcher, n)
+	for i := range matchers {
+		v := d.u16(off + 2*i)
+		if classes != nil {
+			matchers[i] = classMatch{classes: classes, class: v}
+		} else {
+			matchers[i] = glyphMatch(v)
+		}
+	}
+	return matchers
+}
+
+// parseContext parses the contextual subtable `d`.
+func parseContext(d layoutData) *contextSubtable {
+	c := &contextSubtable{format: d.u16(0)}
+	switch c.format {
+	case 1, 2:
+		c.coverage = parseCoverage(d.offset(2))
+		off := 4
+		if c.format == 2 {
+			c.classes = parseClassDef(d.offset(4))
+			off = 6
+		}
+		n := int(d.u16(off))
+		c.ruleSets = make([][]contextRule, n)
+		for i := range c.ruleSets {
+			set := d.offset(off + 2 + 2*i)
+			nr := int(set.u16(0))
+			for j := 0; j < nr; j++ {
+				r := set.offset(2 + 2*j)
+				ng, nl := int(r.u16(0)), int(r.u16(2))
+				if ng == 0 {
+					continue
+				}
+				c.ruleSets[i] = append(c.ruleSets[i], contextRule{
+					input:   append([]glyphMatcher{nil}, parseMatchers(r, 4, ng-1, c.classes)...),
+					lookups: parseSeqLookups(r, 4+2*(ng-1), nl),
+				})
+			}
+		}
+	case 3:
+		ng, nl := int(d.u16(2)), int(d.u16(4))
+		if ng == 0 {
+			return nil
+		}
+		rule := contextRule{lookups: parseSeqLookups(d, 6+2*ng, nl)}
+		for i := 0; i < ng; i++ {
+			rule.input = append(rule.input, coverageMatch(parseCoverage(d.offset(6+2*i))))
+		}
+		c.coverage = coverage(rule.input[0].(coverageMatch))
+		c.ruleSets = [][]contextRule{{rule}}
+	default:
+		return nil
+	}
+	return c
+}
+
+// parseChainingContext parses the chaining contextual subtable `d`.
+func parseChainingContext(d layoutData) *contextSubtable {
+	c := &contextSubtable{format: d.u16(0)}
+	switch c.format {
+	case 1, 2:
+		c.coverage = parseCoverage(d.offset(2))
+		off := 4
+		var backtrackClasses, lookaheadClasses classDef
+		if c.format == 2 {
+			backtrackClasses = parseClassDef(d.offset(4))
+			c.classes = parseClassDef(d.offset(6))
+			lookaheadClasses = parseClassDef(d.offset(8))
+			off = 10
+		}
+		n := int(d.u16(off))
+		c.ruleSets = make([][]contextRule, n)
+		for i := range c.ruleSets {
+			set := d.offset(off + 2 + 2*i)
+			nr := int(set.u16(0))
+			for j := 0; j < nr; j++ {
+				r := set.offset(2 + 2*j)
+				var rule contextRule
+				p := 0
+				nb := int(r.u16(p))
+				rule.backtrack = parseMatchers(r, p+2, nb, backtrackClasses)
+				p += 2 + 2*nb
+				ni := int(r.u16(p))
+				if ni == 0 {
+					continue
+				}
+				rule.input = append([]glyphMatcher{nil}, parseMatchers(r, p+2, ni-1, c.classes)...)
+				p += 2 + 2*(ni-1)
+				na := int(r.u16(p))
+				rule.lookahead = parseMatchers(r, p+2, na, lookaheadClasses)
+				p += 2 + 2*na
+				rule.lookups = parseSeqLookups(r, p+2, int(r.u16(p)))
+				c.ruleSets[i] = append(c.ruleSets[i], rule)
+			}
+		}
+	case 3:
+		var rule contextRule
+		var covs []coverage
+		p := 2
+		covs, p = parseCoverageList(d, p)
+		for _, cov := range covs {
+			rule.backtrack = append(rule.backtrack, coverageMatch(cov))
+		}
+		covs, p = parseCoverageList(d, p)
+		if len(covs) == 0 {
+			return nil
+		}
+		for _, cov := range covs {
+			rule.input = append(rule.input, coverageMatch(cov))
+		}
+		covs, p = parseCoverageList(d, p)
+		for _, cov := range covs {
+			rule.lookahead = append(rule.lookahead, coverageMatch(cov))
+		}
+		rule.lookups = parseSeqLookups(d, p+2, int(d.u16(p)))
+		c.coverage = coverage(rule.input[0].(coverageMatch))
+		c.ruleSets = [][]contextRule{{rule}}
+	default:
+		return nil
+	}
+	return c
+}
+
+// valueRecord is a positioning adjustment in font units.
+type valueRecord struct {
+	xPlacement, yPlacement, xAdvance, yAdvance int16
+}
+
+// valueRecordSize returns the size in bytes of the value records of format `format`.
+func valueRecordSize(format uint16) int {
+	n := 0
+	for f := format; f != 0; f >>= 1 {
+		n += int(f & 1)
+	}
+	return 2 * n
+}
+
+// parseValueRecord parses the value record of format `format` at `off` in `d`. Device tables are
+// ignored.
+func parseValueRecord(d layoutData, off int, format uint16) valueRecord {
+	var v valueRecord
+	if format&0x1 != 0 {
+		v.xPlacement = d.i16(off)
+		off += 2
+	}
+	if format&0x2 != 0 {
+		v.yPlacement = d.i16(off)
+		off += 2
+	}
+	if format&0x4 != 0 {
+		v.xAdvance = d.i16(off)
+		off += 2
+	}
+	if format&0x8 != 0 {
+		v.yAdvance = d.i16(off)
+	}
+	return v
+}
+
+// anchor is an attachment point in font units.
+type anchor struct {
+	x, y int16
+}
+
+// parseAnchor parses the anchor table `d`, or returns nil if there is none.
+func parseAnchor(d layoutData) *anchor {
+	if d == nil {
+		return nil
+	}
+	return &anchor{x: d.i16(2), y: d.i16(4)}
+}
+
+// singlePos adjusts the position of glyphs.
+type singlePos map[GID]valueRecord
+
+// pairValues are the adjustments of the glyphs of a pair.
+type pairValues struct {
+	first, second valueRecord
+}
+
+// pairPos adjusts the position of pairs of glyphs, by glyph (format 1) or by classes (format 2).
+type pairPos struct {
+	coverage coverage
+	// hasSecond is true when the second glyph is adjusted and thus can't start another pair.
+	hasSecond bool
+	pairs     []map[GID]pairValues
+	classes1  classDef
+	classes2  classDef
+	values    [][]pairValues
+}
+
+// lookup returns the adjustments of pair `g1` `g2`.
+func (p *pairPos) lookup(g1, g2 GID) (pairValues, bool) {
+	idx, ok := p.coverage[g1]
+	if !ok {
+		return pairValues{}, false
+	}
+	if p.pairs != nil {
+		if idx >= len(p.pairs) {
+			return pairValues{}, false
+		}
+		v, ok := p.pairs[idx][g2]
+		return v, ok
+	}
+	c1, c2 := int(p.classes1[g1]), int(p.classes2[g2])
+	if c1 >= len(p.values) || c2 >= len(p.values[c1]) {
+		return pairValues{}, false
+	}
+	return p.values[c1][c2], true
+}
+
+// cursiveAnchors are the entry and exit anchors of a glyph.
+type cursiveAnchors struct {
+	entry, exit *anchor
+}
+
+// cursivePos attaches glyphs by their entry and exit anchors.
+type cursivePos map[GID]cursiveAnchors
+
+// markRecord is the class and anchor of a mark.
+type markRecord struct {
+	class  int
+	anchor *anchor
+}
+
+// markAttachPos attaches marks to base glyphs (mark-to-base) or to previous marks (mark-to-mark).
+type markAttachPos struct {
+	marks     coverage
+	markArray []markRecord
+	bases     coverage
+	// baseAnchors are the anchors of the base glyphs for each mark class.
+	baseAnchors [][]*anchor
+}
+
+// markLigPos attaches marks to the components of ligatures.
+type markLigPos struct {
+	marks     coverage
+	markArray []markRecord
+	ligatures coverage
+	// ligAnchors are the anchors of the ligatures for each component and mark class.
+	ligAnchors [][][]*anchor
+}
+
+// parseMarkArray parses the mark array `d`.
+func parseMarkArray(d layoutData) []markRecord {
+	n := int(d.u16(0))
+	marks := make([]markRecord, n)
+	for i := range marks {
+		marks[i] = markRecord{
+			class:  int(d.u16(2 + 4*i)),
+			anchor: parseAnchor(d.offset(2 + 4*i + 2)),
+		}
+	}
+	return marks
+}
+
+// parseAnchorMatrix parses the anchors of `numClasses` classes for each record of the array at
+// the start of `d`.
+func parseAnchorMatrix(d layoutData, numClasses int) [][]*anchor {
+	n := int(d.u16(0))
+	anchors := make([][]*anchor, n)
+	for i := range anchors {
+		anchors[i] = make([]*anchor, numClasses)
+		for c := range anchors[i] {
+			anchors[i][c] = parseAnchor(d.offset(2 + 2*(i*numClasses+c)))
+		}
+	}
+	return anchors
+}
+
+// parseGPOSSubtable parses the subtable `d` of the GPOS lookup type `typ`.
+func parseGPOSSubtable(d layoutData, typ uint16) interface{} {
+	if d == nil {
+		return nil
+	}
+	format := d.u16(0)
+	switch typ {
+	case gposSingle:
+		cov := parseCoverage(d.offset(2))
+		vf := d.u16(4)
+		pos := make(singlePos, len(cov))
+		for g, idx := range cov {
+			switch format {
+			case 1:
+				pos[g] = parseValueRecord(d, 6, vf)
+			case 2:
+				if idx < int(d.u16(6)) {
+					pos[g] = parseValueRecord(d, 8+idx*valueRecordSize(vf), vf)
+				}
+			}
+		}
+		return pos
+	case gposPair:
+		vf1, vf2 := d.u16(4), d.u16(6)
+		size1, size2 := valueRecordSize(vf1), valueRecordSize(vf2)
+		p := &pairPos{coverage: parseCoverage(d.offset(2)), hasSecond: vf2 != 0}
+		switch format {
+		case 1:
+			n := int(d.u16(8))
+			p.pairs = make([]map[GID]pairValues, n)
+			for i := range p.pairs {
+				set := d.offset(10 + 2*i)
+				np := int(set.u16(0))
+				p.pairs[i] = make(map[GID]pairValues, np)
+				for j := 0; j < np; j++ {
+					rec := 2 + j*(2+size1+size2)
+					p.pairs[i][GID(set.u16(rec))] = pairValues{
+						first:  parseValueRecord(set, rec+2, vf1),
+						second: parseValueRecord(set, rec+2+size1, vf2),
+					}
+				}
+			}
+		case 2:
+			p.classes1 = parseClassDef(d.offset(8))
+			p.classes2 = parseClassDef(d.offset(10))
+			n1, n2 := int(d.u16(12)), int(d.u16(14))
+			p.values = make([][]pairValues, n1)
+			for i := range p.values {
+				p.values[i] = make([]pairValues, n2)
+				for j := range p.values[i] {
+					rec := 16 + (i*n2+j)*(size1+size2)
+					p.values[i][j] = pairValues{
+						first:  parseValueRecord(d, rec, vf1),
+						second: parseValueRecord(d, rec+size1, vf2),
+					}
+				}
+			}
+		default:
+			return nil
+		}
+		return p
+	case gposCursive:
+		if format != 1 {
+			return nil
+		}
+		cov := parseCoverage(d.offset(2))
+		pos := make(cursivePos, len(cov))
+		n := int(d.u16(4))
+		for g, idx := range cov {
+			if idx >= n {
+				continue
+			}
+			pos[g] = cursiveAnchors{
+				entry: parseAnchor(d.offset(6 + 4*idx)),
+				exit:  parseAnchor(d.offset(6 + 4*idx + 2)),
+			}
+		}
+		return pos
+	case gposMarkToBase, gposMarkToMark:
+		if format != 1 {
+			return nil
+		}
+		numClasses := int(d.u16(6))
+		return &markAttachPos{
+			marks:       parseCoverage(d.offset(2)),
+			bases:       parseCoverage(d.offset(4)),
+			markArray:   parseMarkArray(d.offset(8)),
+			baseAnchors: parseAnchorMatrix(d.offset(10), numClasses),
+		}
+	case gposMarkToLigature:
+		if format != 1 {
+			return nil
+		}
+		numClasses := int(d.u16(6))
+		p := &markLigPos{
+			marks:     parseCoverage(d.offset(2)),
+			ligatures: parseCoverage(d.offset(4)),
+			markArray: parseMarkArray(d.offset(8)),
+		}
+		arr := d.offset(10)
+		n := int(arr.u16(0))
+		p.ligAnchors = make([][][]*anchor, n)
+		for i := range p.ligAnchors {
+			p.ligAnchors[i] = parseAnchorMatrix(arr.offset(2+2*i), numClasses)
+		}
+		return p
+	case gposContext:
+		if c := parseContext(d); c != nil {
+			return c
+		}
+	case gposChainingContext:
+		if c := parseChainingContext(d); c != nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// kernTable maps the pairs of glyphs of the legacy kerning table, keyed by kernPair, to the
+// adjustment of the advance of the first glyph.
+type kernTable map[uint32]int16
+
+// kernPair returns the key of the pair `left` `right` in a kernTable.
+func kernPair(left, right GID) uint32 {
+	return uint32(left)<<16 | uint32(right)
+}
+
+// Coverage bits of the subtables of the kern table.
+const (
+	kernHorizontal  = 0x0001
+	kernMinimum     = 0x0002
+	kernCrossStream = 0x0004
+	kernOverride    = 0x0008
+
+	kernAppleVertical    = 0x8000
+	kernAppleCrossStream = 0x4000
+)
+
+// parseKern parses the horizontal pair adjustments of the format 0 subtables of the kern table
+// `d`, either in the Microsoft (version 0) or Apple (version 1) layout. Returns nil if the table
+// has no such adjustments.
+// See https://docs.microsoft.com/en-us/typography/opentype/spec/kern
+func parseKern(d layoutData) kernTable {
+	kern := kernTable{}
+	addPairs := func(st layoutData, override bool) {
+		n := int(st.u16(0))
+		for i := 0; i < n; i++ {
+			rec := 8 + 6*i
+			key := kernPair(GID(st.u16(rec)), GID(st.u16(rec+2)))
+			if override {
+				kern[key] = st.i16(rec + 4)
+			} else {
+				kern[key] += st.i16(rec + 4)
+			}
+		}
+	}
+
+	if d.u16(0) == 0 {
+		n := int(d.u16(2))
+		off := 4
+		for i := 0; i < n && off < len(d); i++ {
+			length, coverage := int(d.u16(off+2)), d.u16(off+4)
+			format := coverage >> 8
+			if format == 0 && coverage&(kernHorizontal|kernMinimum|kernCrossStream) == kernHorizontal {
+				addPairs(d.at(off+6), coverage&kernOverride != 0)
+			}
+			// The 16 bit length of large subtables overflows: only the last can be read then.
+			if length < 6 {
+				break
+			}
+			off += length
+		}
+	} else if d.u32(0) == 0x00010000 {
+		n := int(d.u32(4))
+		off := 8
+		for i := 0; i < n && off < len(d); i++ {
+			length, coverage := int(d.u32(off)), d.u16(off+4)
+			if length < 8 {
+				break
+			}
+			format := coverage & 0x00FF
+			if format == 0 && coverage&(kernAppleVertical|kernAppleCrossStream) == 0 {
+				addPairs(d.at(off+8), false)
+			}
+			off += length
+		}
+	}
+	if len(kern) == 0 {
+		return nil
+	}
+	return kern
+}
@@ -0,0 +1,774 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/carmel/unipdf/internal/bidi"
+)
+
+// Shaper converts text to positioned glyphs with the OpenType layout tables (GSUB and GPOS) of a
+// TrueType font: contextual forms, ligatures, reordering of Indic syllables, mark attachment and
+// kerning. Fonts without layout tables are shaped with the character map and the glyph widths only.
+//
+// The shaping is modeled on the OpenType shaping documents and the HarfBuzz shaping engine.
+// See https://docs.microsoft.com/en-us/typography/script-development/standard
+type Shaper struct {
+	ttf *TtfType
+
+	mu    sync.Mutex
+	plans map[planKey]*shapePlan
+}
+
+// NewShaper returns a shaper for the font `ttf`.
+func NewShaper(ttf *TtfType) *Shaper {
+	return &Shaper{ttf: ttf, plans: map[planKey]*shapePlan{}}
+}
+
+// HasLayoutTables returns true if the font has GSUB, GPOS or kern tables.
+func (ttf *TtfType) HasLayoutTables() bool {
+	return ttf.gsub != nil || ttf.gpos != nil || ttf.kern != nil
+}
+
+// ShapeOptions are the options of text shaping.
+type ShapeOptions struct {
+	// RightToLeft is true for text displayed from right to left. The shaped glyphs are then
+	// returned in display order, from left to right.
+	RightToLeft bool
+
+	// Features enables (true) or disables (false) OpenType features by tag, e.g. "smcp" or
+	// "kern". The features not in the map keep their default state: the features of the script,
+	// kerning and standard ligatures are enabled, other features are disabled.
+	Features map[string]bool
+}
+
+// ShapedGlyph is a glyph resulting from text shaping. The distances are in font units.
+type ShapedGlyph struct {
+	GID GID
+
+	// Cluster is the index in the shaped text of the first rune of the cluster of the glyph. The
+	// runes of a cluster, e.g. an Indic syllable or the components of a ligature, are displayed
+	// by the glyphs of that cluster.
+	Cluster int
+
+	// Text is the text displayed by the glyph: several runes for ligatures and none for the
+	// glyphs following the first glyph of a decomposition.
+	Text []rune
+
+	// XAdvance is the distance the pen moves after the glyph.
+	XAdvance int32
+	// XOffset and YOffset displace the glyph from the pen position without moving the pen.
+	XOffset, YOffset int32
+}
+
+// Shape shapes `runes`, a run of text of a single direction, and returns its glyphs in display
+// order.
+func (s *Shaper) Shape(runes []rune, opts ShapeOptions) []ShapedGlyph {
+	var runs [][]ShapedGlyph
+	for _, run := range itemize(runes) {
+		runs = append(runs, s.shapeRun(runes[run.start:run.end], run.start, run.script, opts))
+	}
+	var glyphs []ShapedGlyph
+	if opts.RightToLeft {
+		for i := len(runs) - 1; i >= 0; i-- {
+			glyphs = append(glyphs, runs[i]...)
+		}
+	} else {
+		for _, run := range runs {
+			glyphs = append(glyphs, run...)
+		}
+	}
+	return glyphs
+}
+
+// shapeRun shapes `runes`, the runes from index `offset` of the text, all of script `script`.
+func (s *Shaper) shapeRun(runes []rune, offset int, script *scriptInfo, opts ShapeOptions) []ShapedGlyph {
+	plan := s.plan(script, opts.Features)
+	c := &shapeContext{
+		ttf:  s.ttf,
+		plan: plan,
+		buf:  &shapeBuffer{rtl: opts.RightToLeft},
+	}
+	c.mapRunes(runes, offset)
+
+	switch plan.kind {
+	case shaperArabic:
+		c.setupArabic()
+	case shaperIndic:
+		c.setupIndic()
+	case shaperThai:
+		c.decomposeSaraAm()
+	}
+
+	c.substitute()
+	c.removeDefaultIgnorables()
+	c.position()
+	return c.finish()
+}
+
+// shaperKind identifies the script specific shaping.
+type shaperKind int
+
+const (
+	shaperDefault shaperKind = iota
+	shaperArabic
+	shaperIndic
+	shaperThai
+)
+
+// scriptInfo describes a script.
+type scriptInfo struct {
+	table *unicode.RangeTable
+	// tags are the OpenType script tags in order of preference.
+	tags []string
+	kind shaperKind
+}
+
+// scripts are the scripts recognized when itemizing text.
+var scripts = []*scriptInfo{
+	{unicode.Latin, []string{"latn"}, shaperDefault},
+	{unicode.Greek, []string{"grek"}, shaperDefault},
+	{unicode.Cyrillic, []string{"cyrl"}, shaperDefault},
+	{unicode.Armenian, []string{"armn"}, shaperDefault},
+	{unicode.Georgian, []string{"geor"}, shaperDefault},
+	{unicode.Hebrew, []string{"hebr"}, shaperDefault},
+	{unicode.Arabic, []string{"arab"}, shaperArabic},
+	{unicode.Thaana, []string{"thaa"}, shaperDefault},
+	{unicode.Devanagari, []string{"dev2", "deva"}, shaperIndic},
+	{unicode.Bengali, []string{"bng2", "beng"}, shaperIndic},
+	{unicode.Gurmukhi, []string{"gur2", "guru"}, shaperIndic},
+	{unicode.Gujarati, []string{"gjr2", "gujr"}, shaperIndic},
+	{unicode.Oriya, []string{"ory2", "orya"}, shaperIndic},
+	{unicode.Tamil, []string{"tml2", "taml"}, shaperIndic},
+	{unicode.Telugu, []string{"tel2", "telu"}, shaperIndic},
+	{unicode.Kannada, []string{"knd2", "knda"}, shaperIndic},
+	{unicode.Malayalam, []string{"mlm2", "mlym"}, shaperIndic},
+	{unicode.Thai, []string{"thai"}, shaperThai},
+	{unicode.Lao, []string{"lao "}, shaperThai},
+	{unicode.Han, []string{"hani"}, shaperDefault},
+	{unicode.Hiragana, []string{"kana"}, shaperDefault},
+	{unicode.Katakana, []string{"kana"}, shaperDefault},
+	{unicode.Hangul, []string{"hang"}, shaperDefault},
+}
+
+// unknownScript is the script of the text of scripts not in `scripts`.
+var unknownScript = &scriptInfo{kind: shaperDefault}
+
+// runeScript returns the script of `r`, or nil for the runes used by several scripts.
+func runeScript(r rune) *scriptInfo {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return nil
+	}
+	for _, s := range scripts {
+		if unicode.Is(s.table, r) {
+			return s
+		}
+	}
+	return unknownScript
+}
+
+// scriptRun is a run of text of a single script.
+type scriptRun struct {
+	start, end int
+	script     *scriptInfo
+}
+
+// itemize splits `runes` in runs of a single script. The runes used by several scripts, e.g.
+// spaces, punctuation and combining marks, belong to the run of the preceding rune.
+func itemize(runes []rune) []scriptRun {
+	var runs []scriptRun
+	var current *scriptInfo
+	start := 0
+	for i, r := range runes {
+		s := runeScript(r)
+		if s == nil || s == current {
+			continue
+		}
+		if current == nil {
+			// Leading runes of no script belong to the first run.
+			current = s
+			continue
+		}
+		runs = append(runs, scriptRun{start: start, end: i, script: current})
+		start, current = i, s
+	}
+	if current == nil {
+		current = unknownScript
+	}
+	if start < len(runes) {
+		runs = append(runs, scriptRun{start: start, end: len(runes), script: current})
+	}
+	return runs
+}
+
+// Feature masks. A glyph is processed by the lookups of a feature when its mask has the mask bit
+// of the feature. The features without a specific mask bit apply to all glyphs.
+const (
+	maskGlobal uint32 = 1 << iota
+	maskIsol
+	maskFina
+	maskMedi
+	maskInit
+	maskRphf
+	maskPref
+	maskBlwf
+	maskAbvf
+	maskHalf
+	maskPstf
+)
+
+// featureMasks maps the features applying to specific glyphs to their mask bit.
+var featureMasks = map[string]uint32{
+	"isol": maskIsol,
+	"fina": maskFina,
+	"medi": maskMedi,
+	"init": maskInit,
+	"rphf": maskRphf,
+	"pref": maskPref,
+	"blwf": maskBlwf,
+	"abvf": maskAbvf,
+	"half": maskHalf,
+	"pstf": maskPstf,
+}
+
+// stageDef is a stage of the features applied together, followed by an optional pause.
+type stageDef struct {
+	features    []string
+	pause       func(c *shapeContext)
+	perSyllable bool
+}
+
+// defaultGSUBStages are the GSUB stages of scripts without specific shaping.
+var defaultGSUBStages = []stageDef{
+	{features: []string{"ccmp", "locl"}},
+	{features: []string{"rlig", "rclt", "calt", "liga", "clig"}},
+}
+
+// defaultGPOSStages are the GPOS stages of all scripts.
+var defaultGPOSStages = []stageDef{
+	{features: []string{"abvm", "blwm", "curs", "dist", "kern", "mark", "mkmk"}},
+}
+
+// planKey identifies a shape plan.
+type planKey struct {
+	script *scriptInfo
+	// features are the feature toggles of the plan, as returned by featuresKey.
+	features string
+}
+
+// featuresKey returns a canonical representation of the feature toggles `features`.
+func featuresKey(features map[string]bool) string {
+	if len(features) == 0 {
+		return ""
+	}
+	tags := make([]string, 0, len(features))
+	for tag, enabled := range features {
+		if enabled {
+			tags = append(tags, "+"+tag)
+		} else {
+			tags = append(tags, "-"+tag)
+		}
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
+
+// withFeatures returns the stages `defs` without the features disabled in `features`, followed
+// by a stage of the features enabled in `features` that are not in `defs`.
+func withFeatures(defs []stageDef, features map[string]bool) []stageDef {
+	if len(features) == 0 {
+		return defs
+	}
+	stages := make([]stageDef, len(defs), len(defs)+1)
+	seen := map[string]bool{}
+	for i, def := range defs {
+		stages[i] = def
+		stages[i].features = nil
+		for _, tag := range def.features {
+			seen[tag] = true
+			if enabled, ok := features[tag]; !ok || enabled {
+				stages[i].features = append(stages[i].features, tag)
+			}
+		}
+	}
+	var extra []string
+	for tag, enabled := range features {
+		if enabled && !seen[tag] {
+			extra = append(extra, tag)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		stages = append(stages, stageDef{features: extra})
+	}
+	return stages
+}
+
+// shapePlan is the lookups applied to shape text of a script.
+type shapePlan struct {
+	kind shaperKind
+	gsub []planStage
+	gpos []planStage
+	// gsubFeatures maps the GSUB features of the script to their lookups.
+	gsubFeatures map[string][]int
+	// manualJoiners is true when ZWJ and ZWNJ are not skipped when matching GSUB lookups.
+	manualJoiners bool
+	// zeroMarks is true when the advances of marks are zeroed after positioning.
+	zeroMarks bool
+	indic     *indicConfig
+	// oldSpec is true for fonts implementing the first version of the Indic specification, where
+	// the halants of post-base consonants follow them.
+	oldSpec bool
+	// legacyKern is true when the glyphs are kerned with the kern table, i.e. when kerning is
+	// enabled and the GPOS table of the font has no kerning for the script.
+	legacyKern bool
+}
+
+// planStage is a stage of a shape plan.
+type planStage struct {
+	lookups     []planLookup
+	pause       func(c *shapeContext)
+	perSyllable bool
+}
+
+// planLookup is a lookup with the mask of the glyphs it applies to.
+type planLookup struct {
+	index int
+	mask  uint32
+}
+
+// plan returns the shape plan of `script` with the feature toggles `features`.
+func (s *Shaper) plan(script *scriptInfo, features map[string]bool) *shapePlan {
+	key := planKey{script: script, features: featuresKey(features)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.plans[key]; ok {
+		return p
+	}
+
+	p := &shapePlan{kind: script.kind, zeroMarks: true}
+	gsubStages := defaultGSUBStages
+	switch script.kind {
+	case shaperArabic:
+		gsubStages = arabicGSUBStages
+	case shaperIndic:
+		gsubStages = indicGSUBStages
+		p.manualJoiners = true
+		p.zeroMarks = false
+		p.indic = newIndicConfig(script)
+		if s.ttf.gsub != nil {
+			_, ok := s.ttf.gsub.scripts[script.tags[0]]
+			p.oldSpec = !ok
+		}
+	}
+
+	var gsubLang *langSys
+	gsubLang, p.gsubFeatures = selectLangSys(s.ttf.gsub, script.tags)
+	p.gsub = buildStages(s.ttf.gsub, gsubLang, withFeatures(gsubStages, features))
+	gposLang, gposFeatures := selectLangSys(s.ttf.gpos, script.tags)
+	p.gpos = buildStages(s.ttf.gpos, gposLang, withFeatures(defaultGPOSStages, features))
+	if kern, ok := features["kern"]; !ok || kern {
+		p.legacyKern = s.ttf.kern != nil && len(gposFeatures["kern"]) == 0
+	}
+
+	s.plans[key] = p
+	return p
+}
+
+// selectLangSys returns the default language system of the first of the script `tags` in `t`,
+// or of the default script, with the lookups of its features.
+func selectLangSys(t *layoutTable, tags []string) (*langSys, map[string][]int) {
+	if t == nil {
+		return nil, nil
+	}
+	var ls *langSys
+	candidates := append(append([]string(nil), tags...), "DFLT", "dflt", "latn")
+	for _, tag := range candidates {
+		if script, ok := t.scripts[tag]; ok && script.defaultLang != nil {
+			ls = script.defaultLang
+			break
+		}
+	}
+	if ls == nil {
+		return nil, nil
+	}
+	features := map[string][]int{}
+	for _, fi := range ls.features {
+		if fi < len(t.features) {
+			f := t.features[fi]
+			features[f.tag] = append(features[f.tag], f.lookups...)
+		}
+	}
+	return ls, features
+}
+
+// buildStages returns the lookups of `ls` for the stages `defs`.
+func buildStages(t *layoutTable, ls *langSys, defs []stageDef) []planStage {
+	stages := make([]planStage, len(defs))
+	for i, def := range defs {
+		stages[i].pause = def.pause
+		stages[i].perSyllable = def.perSyllable
+		if ls == nil {
+			continue
+		}
+		masks := map[int]uint32{}
+		add := func(fi int, mask uint32) {
+			if fi < 0 || fi >= len(t.features) {
+				return
+			}
+			for _, li := range t.features[fi].lookups {
+				if li < len(t.lookups) {
+					masks[li] |= mask
+				}
+			}
+		}
+		if i == 0 {
+			add(ls.required, maskGlobal)
+		}
+		for _, tag := range def.features {
+			mask, ok := featureMasks[tag]
+			if !ok {
+				mask = maskGlobal
+			}
+			for _, fi := range ls.features {
+				if fi < len(t.features) && t.features[fi].tag == tag {
+					add(fi, mask)
+				}
+			}
+		}
+		for li, mask := range masks {
+			stages[i].lookups = append(stages[i].lookups, planLookup{index: li, mask: mask})
+		}
+		sort.Slice(stages[i].lookups, func(a, b int) bool {
+			return stages[i].lookups[a].index < stages[i].lookups[b].index
+		})
+	}
+	return stages
+}
+
+// Attachment types of positioned glyphs.
+const (
+	attachNone = iota
+	attachMark
+	attachCursive
+)
+
+// glyphInfo is a glyph of the shaping buffer.
+type glyphInfo struct {
+	gid  GID
+	r    rune
+	text []rune
+	// cluster is the index in the text of the first rune of the cluster of the glyph.
+	cluster int
+	// index is the logical order of the text of the glyph, kept when glyphs are reordered.
+	index int
+	mask  uint32
+	class uint16
+
+	// ligID identifies the ligature formed by the glyph or of which marks were skipped when it
+	// was formed, ligComp being the component they follow.
+	ligID    int
+	ligComp  int
+	numComps int
+
+	// syllable, cat and pos are the syllable, category and position of Indic glyphs.
+	syllable int
+	cat      uint8
+	pos      uint8
+
+	xAdv, xOff, yOff int32
+	attachTo         int
+	attachType       int
+}
+
+// shapeBuffer is the glyphs being shaped, in logical order.
+type shapeBuffer struct {
+	glyphs    []glyphInfo
+	rtl       bool
+	nextLigID int
+}
+
+// shapeContext is the state of the shaping of a run of text.
+type shapeContext struct {
+	ttf  *TtfType
+	plan *shapePlan
+	buf  *shapeBuffer
+	// syllableTypes are the types of the Indic syllables, by syllable index.
+	syllableTypes []uint8
+}
+
+// hasGlyph returns the glyph of `r`, and true if the font has one.
+func (c *shapeContext) hasGlyph(r rune) (GID, bool) {
+	gid, ok := c.ttf.Chars[r]
+	return gid, ok && gid != 0
+}
+
+// glyphClass returns the glyph class of `gid`, from the GDEF table or from the general category of
+// `r`, its rune.
+func (c *shapeContext) glyphClass(gid GID, r rune) uint16 {
+	if gdef := c.ttf.gdef; gdef != nil && gdef.hasGlyphClasses {
+		return gdef.glyphClasses[gid]
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me) {
+		return glyphClassMark
+	}
+	return glyphClassBase
+}
+
+// mapRunes fills the buffer with the glyphs of `runes`, the runes from index `offset` of the text.
+// Runes the font has no glyph for are decomposed, and combining marks composed with their base,
+// when the font has glyphs for the results.
+func (c *shapeContext) mapRunes(runes []rune, offset int) {
+	glyphs := make([]glyphInfo, 0, len(runes))
+	add := func(r rune, text []rune, cluster int) {
+		gid, _ := c.hasGlyph(r)
+		glyphs = append(glyphs, glyphInfo{
+			gid:     gid,
+			r:       r,
+			text:    text,
+			cluster: cluster,
+			mask:    maskGlobal,
+			class:   c.glyphClass(gid, r),
+		})
+	}
+
+	for i, r := range runes {
+		cluster := offset + i
+		if c.buf.rtl {
+			if m, ok := bidi.Mirror(r); ok {
+				if _, ok := c.hasGlyph(m); ok {
+					add(m, []rune{r}, cluster)
+					glyphs[len(glyphs)-1].r = r
+					continue
+				}
+			}
+		}
+
+		// Compose marks with the preceding rune when possible.
+		if n := len(glyphs); n > 0 && unicode.Is(unicode.Mn, r) && c.plan.kind != shaperIndic {
+			prev := &glyphs[n-1]
+			composed := []rune(norm.NFC.String(string([]rune{prev.r, r})))
+			if len(composed) == 1 {
+				if gid, ok := c.hasGlyph(composed[0]); ok {
+					prev.gid, prev.r = gid, composed[0]
+					prev.text = append(prev.text, r)
+					prev.class = c.glyphClass(gid, prev.r)
+					continue
+				}
+			}
+		}
+
+		_, ok := c.hasGlyph(r)
+		if !ok || (c.plan.kind == shaperIndic && isIndicSplitMatra(r)) {
+			if parts := c.decompose(r); parts != nil {
+				for j, p := range parts {
+					var text []rune
+					if j == 0 {
+						text = []rune{r}
+					}
+					add(p, text, cluster)
+				}
+				continue
+			}
+		}
+		add(r, []rune{r}, cluster)
+	}
+
+	for i := range glyphs {
+		glyphs[i].index = i
+		glyphs[i].attachTo = -1
+	}
+	c.buf.glyphs = glyphs
+}
+
+// decompose returns the canonical decomposition of `r` if it has one and the font has glyphs for
+// all its parts.
+func (c *shapeContext) decompose(r rune) []rune {
+	parts := []rune(norm.NFD.String(string(r)))
+	if len(parts) < 2 {
+		return nil
+	}
+	for _, p := range parts {
+		if _, ok := c.hasGlyph(p); !ok {
+			return nil
+		}
+	}
+	return parts
+}
+
+// substitute applies the GSUB stages of the plan.
+func (c *shapeContext) substitute() {
+	a := &layoutApplier{c: c, table: c.ttf.gsub}
+	for _, stage := range c.plan.gsub {
+		if a.table != nil {
+			a.perSyllable = stage.perSyllable
+			for _, l := range stage.lookups {
+				a.applyLookup(l.index, l.mask)
+			}
+		}
+		if stage.pause != nil {
+			stage.pause(c)
+		}
+	}
+}
+
+// position sets the advances of the glyphs and applies the GPOS stages of the plan.
+func (c *shapeContext) position() {
+	glyphs := c.buf.glyphs
+	for i := range glyphs {
+		if gid := int(glyphs[i].gid); gid < len(c.ttf.Widths) {
+			glyphs[i].xAdv = int32(c.ttf.Widths[gid])
+		}
+	}
+
+	a := &layoutApplier{c: c, table: c.ttf.gpos, gpos: true}
+	if a.table != nil {
+		for _, stage := range c.plan.gpos {
+			for _, l := range stage.lookups {
+				a.applyLookup(l.index, l.mask)
+			}
+		}
+	}
+
+	if c.plan.legacyKern {
+		c.kern()
+	}
+
+	if c.plan.zeroMarks {
+		adjust := c.ttf.gpos == nil && !c.buf.rtl
+		for i := range glyphs {
+			g := &glyphs[i]
+			if g.class != glyphClassMark {
+				continue
+			}
+			if adjust {
+				g.xOff -= g.xAdv
+			}
+			g.xAdv = 0
+		}
+	}
+}
+
+// kern adjusts the advances of the pairs of glyphs of the kern table of the font. Marks are
+// skipped, the pairs being the glyphs displayed next to each other.
+func (c *shapeContext) kern() {
+	glyphs := c.buf.glyphs
+	prev := -1
+	for i := range glyphs {
+		if glyphs[i].class == glyphClassMark {
+			continue
+		}
+		if prev >= 0 {
+			// The pairs are in display order, the advance of the left glyph being adjusted.
+			left, right := prev, i
+			if c.buf.rtl {
+				left, right = i, prev
+			}
+			glyphs[left].xAdv += int32(c.ttf.kern[kernPair(glyphs[left].gid, glyphs[right].gid)])
+		}
+		prev = i
+	}
+}
+
+// removeDefaultIgnorables removes the glyphs of default ignorable runes, e.g. joiners and
+// directional formatting characters, once they have served in substitutions.
+func (c *shapeContext) removeDefaultIgnorables() {
+	glyphs := c.buf.glyphs[:0]
+	for _, g := range c.buf.glyphs {
+		if isDefaultIgnorable(g.r) && len(g.text) <= 1 {
+			continue
+		}
+		glyphs = append(glyphs, g)
+	}
+	c.buf.glyphs = glyphs
+}
+
+// finish returns the shaped glyphs in display order with the offsets of attached glyphs resolved.
+func (c *shapeContext) finish() []ShapedGlyph {
+	glyphs := c.buf.glyphs
+	n := len(glyphs)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+		if c.buf.rtl {
+			order[i] = n - 1 - i
+		}
+	}
+
+	// Pen positions of the glyphs, by logical index.
+	pen := make([]int32, n)
+	var x int32
+	for _, i := range order {
+		pen[i] = x
+		x += glyphs[i].xAdv
+	}
+
+	type point struct {
+		x, y int32
+		done bool
+	}
+	origins := make([]point, n)
+	var resolve func(i, depth int) point
+	resolve = func(i, depth int) point {
+		if origins[i].done {
+			return origins[i]
+		}
+		g := &glyphs[i]
+		p := point{x: pen[i] + g.xOff, y: g.yOff, done: true}
+		if g.attachType != attachNone && g.attachTo >= 0 && g.attachTo < n && g.attachTo != i &&
+			depth < 2*maxLayoutNesting {
+			parent := resolve(g.attachTo, depth+1)
+			switch g.attachType {
+			case attachMark:
+				p.x, p.y = parent.x+g.xOff, parent.y+g.yOff
+			case attachCursive:
+				p.y = parent.y + g.yOff
+			}
+		}
+		origins[i] = p
+		return p
+	}
+
+	shaped := make([]ShapedGlyph, 0, n)
+	for _, i := range order {
+		g := &glyphs[i]
+		p := resolve(i, 0)
+		shaped = append(shaped, ShapedGlyph{
+			GID:      g.gid,
+			Cluster:  g.cluster,
+			Text:     g.text,
+			XAdvance: g.xAdv,
+			XOffset:  p.x - pen[i],
+			YOffset:  p.y,
+		})
+	}
+	return shaped
+}
+
+// isDefaultIgnorable returns true if `r` is a default ignorable code point, i.e. it is not
+// displayed.
+func isDefaultIgnorable(r rune) bool {
+	switch {
+	case r == 0x00AD, r == 0x034F, r == 0x061C, r == 0x115F, r == 0x1160, r == 0x17B4, r == 0x17B5,
+		r >= 0x180B && r <= 0x180F, r >= 0x200B && r <= 0x200F, r >= 0x202A && r <= 0x202E,
+		r >= 0x2060 && r <= 0x206F, r == 0x3164, r >= 0xFE00 && r <= 0xFE0F, r == 0xFEFF,
+		r == 0xFFA0, r >= 0xFFF0 && r <= 0xFFF8, r >= 0x1BCA0 && r <= 0x1BCA3,
+		r >= 0x1D173 && r <= 0x1D17A, r >= 0xE0000 && r <= 0xE0FFF:
+		return true
+	}
+	return false
+}
+
+const (
+	zwnj = 0x200C
+	zwj  = 0x200D
+)
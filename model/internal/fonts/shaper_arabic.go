@@ -0,0 +1,233 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"unicode"
+)
+
+// arabicGSUBStages are the GSUB stages of the Arabic script. The joining forms are applied one
+// feature at a time.
+var arabicGSUBStages = []stageDef{
+	{features: []string{"ccmp", "locl"}},
+	{features: []string{"isol"}},
+	{features: []string{"fina"}},
+	{features: []string{"medi"}},
+	{features: []string{"init"}},
+	{features: []string{"rlig"}},
+	{features: []string{"rclt", "calt", "liga", "clig", "mset"}},
+}
+
+// joiningType is the Arabic joining type of a rune.
+type joiningType uint8
+
+const (
+	joiningNone        joiningType = iota // U: non joining.
+	joiningRight                          // R: joins with the preceding letter only.
+	joiningDual                           // D: joins on both sides.
+	joiningCausing                        // C: causes the joining of the letters around it.
+	joiningTransparent                    // T: ignored by the joining.
+)
+
+// arabicJoiningRanges are the runes of the Arabic blocks joining on one or both sides.
+var arabicJoiningRanges = []struct {
+	first, last rune
+	typ         joiningType
+}{
+	{0x0620, 0x0620, joiningDual},
+	{0x0622, 0x0625, joiningRight},
+	{0x0626, 0x0626, joiningDual},
+	{0x0627, 0x0627, joiningRight},
+	{0x0628, 0x0628, joiningDual},
+	{0x0629, 0x0629, joiningRight},
+	{0x062A, 0x062E, joiningDual},
+	{0x062F, 0x0632, joiningRight},
+	{0x0633, 0x063F, joiningDual},
+	{0x0640, 0x0640, joiningCausing},
+	{0x0641, 0x0647, joiningDual},
+	{0x0648, 0x0648, joiningRight},
+	{0x0649, 0x064A, joiningDual},
+	{0x066E, 0x066F, joiningDual},
+	{0x0671, 0x0673, joiningRight},
+	{0x0675, 0x0677, joiningRight},
+	{0x0678, 0x0687, joiningDual},
+	{0x0688, 0x0699, joiningRight},
+	{0x069A, 0x06BF, joiningDual},
+	{0x06C0, 0x06C0, joiningRight},
+	{0x06C1, 0x06C2, joiningDual},
+	{0x06C3, 0x06CB, joiningRight},
+	{0x06CC, 0x06CC, joiningDual},
+	{0x06CD, 0x06CD, joiningRight},
+	{0x06CE, 0x06CE, joiningDual},
+	{0x06CF, 0x06CF, joiningRight},
+	{0x06D0, 0x06D1, joiningDual},
+	{0x06D2, 0x06D3, joiningRight},
+	{0x06D5, 0x06D5, joiningRight},
+	{0x06EE, 0x06EF, joiningRight},
+	{0x06FA, 0x06FC, joiningDual},
+	{0x06FF, 0x06FF, joiningDual},
+	{0x0750, 0x0758, joiningDual},
+	{0x0759, 0x075B, joiningRight},
+	{0x075C, 0x076A, joiningDual},
+	{0x076B, 0x076C, joiningRight},
+	{0x076D, 0x0770, joiningDual},
+	{0x0771, 0x0771, joiningRight},
+	{0x0772, 0x0772, joiningDual},
+	{0x0773, 0x0774, joiningRight},
+	{0x0775, 0x0777, joiningDual},
+	{0x0778, 0x0779, joiningRight},
+	{0x077A, 0x077F, joiningDual},
+	{0x08A0, 0x08A9, joiningDual},
+	{0x08AA, 0x08AC, joiningRight},
+	{0x08AE, 0x08AE, joiningRight},
+	{0x08AF, 0x08B0, joiningDual},
+	{0x08B1, 0x08B2, joiningRight},
+	{0x08B3, 0x08B4, joiningDual},
+	{0x08B6, 0x08B8, joiningDual},
+	{0x08B9, 0x08B9, joiningRight},
+	{0x08BA, 0x08BD, joiningDual},
+	{zwj, zwj, joiningCausing},
+}
+
+// arabicJoiningType returns the joining type of `r`.
+func arabicJoiningType(r rune) joiningType {
+	for _, rng := range arabicJoiningRanges {
+		if r >= rng.first && r <= rng.last {
+			return rng.typ
+		}
+	}
+	if r != zwnj && unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return joiningTransparent
+	}
+	return joiningNone
+}
+
+// Joining forms, the order of the presentation forms of the letters.
+const (
+	formNone = iota - 1
+	formIsol
+	formFina
+	formInit
+	formMedi
+)
+
+// arabicForms returns the joining forms of `runes`.
+func arabicForms(runes []rune) []int {
+	forms := make([]int, len(runes))
+	prev := -1
+	var prevType joiningType
+	for i, r := range runes {
+		t := arabicJoiningType(r)
+		forms[i] = formNone
+		switch t {
+		case joiningTransparent:
+			continue
+		case joiningNone:
+			prev = -1
+			continue
+		}
+
+		forms[i] = formIsol
+		if prev >= 0 && (prevType == joiningDual || prevType == joiningCausing) &&
+			(t == joiningDual || t == joiningRight || t == joiningCausing) {
+			switch forms[prev] {
+			case formIsol:
+				forms[prev] = formInit
+			case formFina:
+				forms[prev] = formMedi
+			}
+			forms[i] = formFina
+		}
+		prev, prevType = i, t
+	}
+	return forms
+}
+
+// setupArabic sets the masks of the joining forms of the glyphs and substitutes presentation forms
+// when the font has no joining features.
+func (c *shapeContext) setupArabic() {
+	glyphs := c.buf.glyphs
+	runes := make([]rune, len(glyphs))
+	for i := range glyphs {
+		runes[i] = glyphs[i].r
+	}
+	forms := arabicForms(runes)
+	formMasks := [...]uint32{maskIsol, maskFina, maskInit, maskMedi}
+	for i, f := range forms {
+		if f != formNone {
+			glyphs[i].mask |= formMasks[f]
+		}
+	}
+
+	features := c.plan.gsubFeatures
+	if len(features["isol"])+len(features["fina"])+len(features["medi"])+len(features["init"]) == 0 {
+		c.substitutePresentationForms(forms)
+	}
+}
+
+// arabicFormCounts are the number of presentation forms of the letters from U+0621, in the
+// Arabic Presentation Forms-B block from U+FE80.
+var arabicFormCounts = [...]uint8{
+	1, 2, 2, 2, 2, 4, 2, 4, 2, 4, 4, 4, 4, 4, 2, 2, 2, 2, 4, 4, 4, 4, 4, 4, 4, 4, // U+0621-U+063A
+	0, 0, 0, 0, 0, 0, // U+063B-U+0640
+	4, 4, 4, 4, 4, 4, 4, 2, 2, 4, // U+0641-U+064A
+}
+
+// arabicPresentationForm returns the presentation form `form` of `r`, if it has one.
+func arabicPresentationForm(r rune, form int) (rune, bool) {
+	if r < 0x0621 || r > 0x064A || form == formNone {
+		return 0, false
+	}
+	pf := rune(0xFE80)
+	for _, n := range arabicFormCounts[:r-0x0621] {
+		pf += rune(n)
+	}
+	if form >= int(arabicFormCounts[r-0x0621]) {
+		return 0, false
+	}
+	return pf + rune(form), true
+}
+
+// lamAlefLigatures are the isolated forms of the ligatures of lam with the alef variants, the final
+// forms following them.
+var lamAlefLigatures = map[rune]rune{
+	0x0622: 0xFEF5,
+	0x0623: 0xFEF7,
+	0x0625: 0xFEF9,
+	0x0627: 0xFEFB,
+}
+
+// substitutePresentationForms replaces the letters by their presentation forms `forms`, for fonts
+// without joining features but with glyphs for the Arabic Presentation Forms-B.
+func (c *shapeContext) substitutePresentationForms(forms []int) {
+	glyphs := c.buf.glyphs
+	out := glyphs[:0]
+	for i := 0; i < len(glyphs); i++ {
+		g := glyphs[i]
+		if g.r == 0x0644 && (forms[i] == formInit || forms[i] == formMedi) && i+1 < len(glyphs) {
+			if lig, ok := lamAlefLigatures[glyphs[i+1].r]; ok {
+				if forms[i] == formMedi {
+					lig++
+				}
+				if gid, ok := c.hasGlyph(lig); ok {
+					g.gid = gid
+					g.text = append(append([]rune(nil), g.text...), glyphs[i+1].text...)
+					g.class = c.glyphClass(gid, lig)
+					out = append(out, g)
+					i++
+					continue
+				}
+			}
+		}
+		if pf, ok := arabicPresentationForm(g.r, forms[i]); ok {
+			if gid, ok := c.hasGlyph(pf); ok {
+				g.gid = gid
+			}
+		}
+		out = append(out, g)
+	}
+	c.buf.glyphs = out
+}
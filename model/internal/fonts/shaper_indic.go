@@ -0,0 +1,601 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// indicGSUBStages are the GSUB stages of the Indic scripts. The syllables are reordered before
+// the basic features, each applied in its own stage, and again before the presentation features.
+var indicGSUBStages = []stageDef{
+	{features: []string{"locl", "ccmp"}, pause: (*shapeContext).initialReorderIndic},
+	{features: []string{"nukt"}, perSyllable: true},
+	{features: []string{"akhn"}, perSyllable: true},
+	{features: []string{"rphf"}, perSyllable: true},
+	{features: []string{"rkrf"}, perSyllable: true},
+	{features: []string{"pref"}, perSyllable: true},
+	{features: []string{"blwf"}, perSyllable: true},
+	{features: []string{"abvf"}, perSyllable: true},
+	{features: []string{"half"}, perSyllable: true},
+	{features: []string{"pstf"}, perSyllable: true},
+	{features: []string{"vatu"}, perSyllable: true},
+	{features: []string{"cjct"}, perSyllable: true, pause: (*shapeContext).finalReorderIndic},
+	{features: []string{"init", "pres", "abvs", "blws", "psts", "haln"}, perSyllable: true},
+	{features: []string{"calt", "liga", "clig"}},
+}
+
+// Categories of the runes of Indic syllables.
+const (
+	indicOther uint8 = iota
+	indicC
+	indicRa
+	indicV
+	indicN
+	indicH
+	indicM
+	indicSM
+	indicZWJ
+	indicZWNJ
+	indicPlaceholder
+)
+
+// Positions of the glyphs of Indic syllables, in display order.
+const (
+	posStart uint8 = iota
+	posRaToBecomeReph
+	posPreM
+	posPreC
+	posBaseC
+	posAfterMain
+	posAboveC
+	posBeforeSub
+	posBelowC
+	posAfterSub
+	posBeforePost
+	posPostC
+	posAfterPost
+	posFinalC
+	posSMVD
+	posEnd
+)
+
+// Types of Indic syllables.
+const (
+	syllableConsonant uint8 = iota
+	syllableVowel
+	syllableBroken
+	syllableOther
+)
+
+// indicConfig is the script specific data of the Indic shaping.
+type indicConfig struct {
+	// block is the first rune of the Unicode block of the script.
+	block rune
+	// rephPos is the position the reph moves to.
+	rephPos uint8
+	// blwfPre is true when the below-base forms also apply to the consonants before the base.
+	blwfPre bool
+	// preMatras are the offsets in the block of the matras displayed before the base.
+	preMatras []rune
+	// rightMatra, topMatra and bottomMatra are the positions of the other matras.
+	rightMatra, topMatra, bottomMatra uint8
+	// matraAfterChillu is true for the scripts without half forms, where the pre-base matras
+	// stay right before the base.
+	matraAfterChillu bool
+}
+
+// indicConfigs are the configurations of the Indic scripts, by Unicode block.
+var indicConfigs = []indicConfig{
+	{block: 0x0900, rephPos: posBeforePost, blwfPre: true, preMatras: []rune{0x3F, 0x4E},
+		rightMatra: posAfterSub, topMatra: posAfterSub, bottomMatra: posAfterSub},
+	{block: 0x0980, rephPos: posAfterSub, blwfPre: true, preMatras: []rune{0x3F, 0x47, 0x48},
+		rightMatra: posAfterPost, topMatra: posAfterSub, bottomMatra: posAfterSub},
+	{block: 0x0A00, rephPos: posBeforeSub, preMatras: []rune{0x3F},
+		rightMatra: posAfterPost, topMatra: posAfterPost, bottomMatra: posAfterPost},
+	{block: 0x0A80, rephPos: posBeforePost, blwfPre: true, preMatras: []rune{0x3F},
+		rightMatra: posAfterPost, topMatra: posAfterSub, bottomMatra: posAfterPost},
+	{block: 0x0B00, rephPos: posAfterMain, blwfPre: true, preMatras: []rune{0x47},
+		rightMatra: posAfterPost, topMatra: posAfterMain, bottomMatra: posAfterSub},
+	{block: 0x0B80, rephPos: posAfterPost, blwfPre: true, preMatras: []rune{0x46, 0x47, 0x48},
+		rightMatra: posAfterPost, topMatra: posAfterSub, bottomMatra: posAfterPost, matraAfterChillu: true},
+	{block: 0x0C00, rephPos: posAfterPost,
+		rightMatra: posBeforeSub, topMatra: posBeforeSub, bottomMatra: posBeforeSub},
+	{block: 0x0C80, rephPos: posAfterPost,
+		rightMatra: posBeforeSub, topMatra: posBeforeSub, bottomMatra: posBeforeSub},
+	{block: 0x0D00, rephPos: posAfterMain, blwfPre: true, preMatras: []rune{0x46, 0x47, 0x48},
+		rightMatra: posAfterPost, topMatra: posAfterSub, bottomMatra: posAfterSub, matraAfterChillu: true},
+}
+
+// newIndicConfig returns the configuration of the Indic `script`.
+func newIndicConfig(script *scriptInfo) *indicConfig {
+	for i := range indicConfigs {
+		if unicode.Is(script.table, indicConfigs[i].block+0x15) {
+			return &indicConfigs[i]
+		}
+	}
+	return &indicConfigs[0]
+}
+
+// category returns the category of `r` in Indic syllables.
+func (cfg *indicConfig) category(r rune) uint8 {
+	switch r {
+	case zwj:
+		return indicZWJ
+	case zwnj:
+		return indicZWNJ
+	case 0x00A0, 0x25CC:
+		return indicPlaceholder
+	}
+	off := r - cfg.block
+	if off < 0 || off >= 0x80 {
+		return indicOther
+	}
+	switch {
+	case off >= 0x01 && off <= 0x03, off >= 0x51 && off <= 0x54:
+		return indicSM
+	case off >= 0x04 && off <= 0x14, off == 0x60, off == 0x61:
+		return indicV
+	case off == 0x30:
+		return indicRa
+	case off >= 0x15 && off <= 0x39, off >= 0x58 && off <= 0x5F:
+		return indicC
+	case off == 0x3C:
+		return indicN
+	case off == 0x4D:
+		return indicH
+	}
+	if unicode.In(r, unicode.Mn, unicode.Mc) {
+		return indicM
+	}
+	return indicOther
+}
+
+// isIndicConsonant returns true for the categories that can be the base of a syllable.
+func isIndicConsonant(cat uint8) bool {
+	return cat == indicC || cat == indicRa
+}
+
+// matraPosition returns the position of the matra `r`.
+func (cfg *indicConfig) matraPosition(r rune) uint8 {
+	off := r - cfg.block
+	for _, m := range cfg.preMatras {
+		if off == m {
+			return posPreM
+		}
+	}
+	if unicode.Is(unicode.Mc, r) {
+		if cfg.block == 0x0C00 && r > 0x0C42 || cfg.block == 0x0C80 && r >= 0x0CC3 && r <= 0x0CD6 {
+			return posAfterSub
+		}
+		return cfg.rightMatra
+	}
+	if off >= 0x41 && off <= 0x44 || off == 0x62 || off == 0x63 {
+		return cfg.bottomMatra
+	}
+	return cfg.topMatra
+}
+
+// isIndicSplitMatra returns true if `r` is a matra of an Indic script made of several parts,
+// e.g. a pre-base and a post-base part.
+func isIndicSplitMatra(r rune) bool {
+	if r < 0x0900 || r >= 0x0D80 || !unicode.In(r, unicode.Mn, unicode.Mc) {
+		return false
+	}
+	return len([]rune(norm.NFD.String(string(r)))) > 1
+}
+
+// consonantPosition returns the position of the consonant `gid` following the base, depending
+// on the forms the font has for it.
+func (c *shapeContext) consonantPosition(gid GID) uint8 {
+	halant, ok := c.hasGlyph(c.plan.indic.block + 0x4D)
+	if !ok {
+		return posBaseC
+	}
+	for _, seq := range [][]GID{{halant, gid}, {gid, halant}} {
+		if c.wouldSubstitute("blwf", seq) {
+			return posBelowC
+		}
+		if c.wouldSubstitute("pstf", seq) {
+			return posPostC
+		}
+	}
+	return posBaseC
+}
+
+// wouldSubstitute returns true if the GSUB `feature` has a ligature or single substitution of
+// the sequence `seq`.
+func (c *shapeContext) wouldSubstitute(feature string, seq []GID) bool {
+	gsub := c.ttf.gsub
+	for _, li := range c.plan.gsubFeatures[feature] {
+		if li >= len(gsub.lookups) {
+			continue
+		}
+		for _, st := range gsub.lookups[li].subtables {
+			switch st := st.(type) {
+			case singleSubst:
+				if _, ok := st[seq[0]]; ok && len(seq) == 1 {
+					return true
+				}
+			case ligatureSubst:
+				for _, lig := range st[seq[0]] {
+					if len(lig.components) != len(seq)-1 {
+						continue
+					}
+					match := true
+					for k, comp := range lig.components {
+						if comp != seq[k+1] {
+							match = false
+							break
+						}
+					}
+					if match {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// setupIndic sets the categories and initial positions of the glyphs and segments them in
+// syllables.
+func (c *shapeContext) setupIndic() {
+	cfg := c.plan.indic
+	glyphs := c.buf.glyphs
+	for i := range glyphs {
+		g := &glyphs[i]
+		g.cat = cfg.category(g.r)
+		switch g.cat {
+		case indicC, indicRa:
+			g.pos = c.consonantPosition(g.gid)
+		case indicM:
+			g.pos = cfg.matraPosition(g.r)
+		case indicSM:
+			g.pos = posSMVD
+		case indicN, indicH, indicZWJ, indicZWNJ:
+			g.pos = posEnd
+		default:
+			g.pos = posBaseC
+		}
+	}
+
+	c.syllableTypes = c.syllableTypes[:0]
+	for i := 0; i < len(glyphs); {
+		start := i
+		typ := syllableOther
+		switch cat := glyphs[i].cat; {
+		case isIndicConsonant(cat) || cat == indicPlaceholder:
+			typ = syllableConsonant
+			i = indicConsonants(glyphs, i)
+			i = indicSyllableTail(glyphs, i)
+		case cat == indicV:
+			typ = syllableVowel
+			i++
+			if i < len(glyphs) && glyphs[i].cat == indicN {
+				i++
+			}
+			i = indicSyllableTail(glyphs, i)
+		case cat == indicM || cat == indicN || cat == indicH || cat == indicSM:
+			typ = syllableBroken
+			for i < len(glyphs) && (glyphs[i].cat == indicM || glyphs[i].cat == indicN ||
+				glyphs[i].cat == indicH || glyphs[i].cat == indicSM) {
+				i++
+			}
+		default:
+			i++
+		}
+		for j := start; j < i; j++ {
+			glyphs[j].syllable = len(c.syllableTypes)
+		}
+		c.syllableTypes = append(c.syllableTypes, typ)
+	}
+}
+
+// indicConsonants returns the end of the sequence of consonants joined by halants starting at
+// `i`.
+func indicConsonants(glyphs []glyphInfo, i int) int {
+	n := len(glyphs)
+	for {
+		i++
+		if i < n && glyphs[i].cat == indicN {
+			i++
+		}
+		if i >= n || glyphs[i].cat != indicH {
+			return i
+		}
+		j := i + 1
+		if j < n && (glyphs[j].cat == indicZWJ || glyphs[j].cat == indicZWNJ) {
+			j++
+		}
+		if j >= n || !isIndicConsonant(glyphs[j].cat) {
+			// The syllable ends with a dead consonant.
+			return j
+		}
+		i = j
+	}
+}
+
+// indicSyllableTail returns the end of the matras and syllable modifiers at `i`.
+func indicSyllableTail(glyphs []glyphInfo, i int) int {
+	n := len(glyphs)
+	for i < n && (glyphs[i].cat == indicM || glyphs[i].cat == indicZWJ || glyphs[i].cat == indicZWNJ) {
+		i++
+		for i < n && (glyphs[i].cat == indicN || glyphs[i].cat == indicH) {
+			i++
+		}
+	}
+	for i < n && glyphs[i].cat == indicSM {
+		i++
+	}
+	return i
+}
+
+// forEachSyllable calls `f` with the range of each consonant syllable of the buffer.
+func (c *shapeContext) forEachSyllable(f func(start, end int)) {
+	glyphs := c.buf.glyphs
+	for start := 0; start < len(glyphs); {
+		end := start + 1
+		for end < len(glyphs) && glyphs[end].syllable == glyphs[start].syllable {
+			end++
+		}
+		if id := glyphs[start].syllable; id < len(c.syllableTypes) && c.syllableTypes[id] == syllableConsonant {
+			f(start, end)
+		}
+		start = end
+	}
+}
+
+// initialReorderIndic finds the base consonant of the syllables, moves the pre-base matras
+// before it and sets the masks of the basic features.
+func (c *shapeContext) initialReorderIndic() {
+	c.forEachSyllable(c.initialReorderSyllable)
+}
+
+// initialReorderSyllable reorders the consonant syllable [start, end).
+func (c *shapeContext) initialReorderSyllable(start, end int) {
+	cfg := c.plan.indic
+	g := c.buf.glyphs
+
+	// A syllable starting with Ra Halant forms a reph when the font supports it.
+	limit := start
+	hasReph := false
+	if len(c.plan.gsubFeatures["rphf"]) > 0 && end-start >= 3 && g[start].cat == indicRa &&
+		g[start+1].cat == indicH && g[start+2].cat != indicZWJ && g[start+2].cat != indicZWNJ {
+		hasReph = true
+		limit = start + 2
+	}
+
+	// The base is the last consonant not having a below-base or post-base form.
+	base := end
+	seenBelow := false
+	for i := end; i > limit; {
+		i--
+		if isIndicConsonant(g[i].cat) {
+			p := g[i].pos
+			if p != posBelowC && (p != posPostC || seenBelow) {
+				base = i
+				break
+			}
+			if p == posBelowC {
+				seenBelow = true
+			}
+			base = i
+		} else if start < i && g[i].cat == indicZWJ && g[i-1].cat == indicH {
+			break
+		}
+	}
+	if base == end {
+		base = start
+		hasReph = false
+	}
+
+	// Fonts of the old specification form the below-base and post-base forms from the consonant
+	// followed by the halant.
+	if c.plan.oldSpec {
+		for i := base + 1; i < end; i++ {
+			if g[i].cat != indicH {
+				continue
+			}
+			j := end - 1
+			for j > i && !isIndicConsonant(g[j].cat) {
+				j--
+			}
+			if j > i {
+				tmp := g[i]
+				copy(g[i:j], g[i+1:j+1])
+				g[j] = tmp
+			}
+			break
+		}
+	}
+
+	for i := start; i < base; i++ {
+		if g[i].pos > posPreC {
+			g[i].pos = posPreC
+		}
+	}
+	g[base].pos = posBaseC
+	if hasReph {
+		g[start].pos = posRaToBecomeReph
+	}
+	for i := base + 1; i < end; i++ {
+		if isIndicConsonant(g[i].cat) && g[i].pos == posBaseC {
+			g[i].pos = posBelowC
+		}
+	}
+
+	// Nuktas, halants and joiners move with the glyph they follow.
+	last := posStart
+	for i := start; i < end; i++ {
+		switch g[i].cat {
+		case indicN, indicH, indicZWJ, indicZWNJ:
+			g[i].pos = last
+			if g[i].cat == indicH && last == posPreM {
+				for j := i; j > start; j-- {
+					if g[j-1].pos != posPreM {
+						g[i].pos = g[j-1].pos
+						break
+					}
+				}
+			}
+		default:
+			if g[i].pos != posSMVD {
+				last = g[i].pos
+			}
+		}
+	}
+
+	// Post-base consonants own the glyphs since the last consonant or matra.
+	lastC := base
+	for i := base + 1; i < end; i++ {
+		if isIndicConsonant(g[i].cat) {
+			for j := lastC + 1; j < i; j++ {
+				if g[j].pos < posSMVD {
+					g[j].pos = g[i].pos
+				}
+			}
+			lastC = i
+		} else if g[i].cat == indicM {
+			lastC = i
+		}
+	}
+
+	sortIndicSyllable(g[start:end])
+	for i := start; i < end; i++ {
+		if g[i].pos == posBaseC {
+			base = i
+			break
+		}
+	}
+
+	for i := start; i < end && g[i].pos == posRaToBecomeReph; i++ {
+		g[i].mask |= maskRphf
+	}
+	preMask := maskHalf
+	if cfg.blwfPre {
+		preMask |= maskBlwf
+	}
+	for i := start; i < base; i++ {
+		g[i].mask |= preMask
+	}
+	for i := base + 1; i < end; i++ {
+		g[i].mask |= maskBlwf | maskAbvf | maskPstf
+	}
+
+	// The glyphs of a syllable are displayed together.
+	cluster := g[start].cluster
+	for i := start; i < end; i++ {
+		if g[i].cluster < cluster {
+			cluster = g[i].cluster
+		}
+	}
+	for i := start; i < end; i++ {
+		g[i].cluster = cluster
+	}
+}
+
+// sortIndicSyllable sorts the glyphs of a syllable by position, keeping the order of the glyphs
+// of equal positions.
+func sortIndicSyllable(g []glyphInfo) {
+	for i := 1; i < len(g); i++ {
+		for j := i; j > 0 && g[j].pos < g[j-1].pos; j-- {
+			g[j], g[j-1] = g[j-1], g[j]
+		}
+	}
+}
+
+// finalReorderIndic moves the pre-base matras and the reph to their final positions once the
+// basic features have been applied.
+func (c *shapeContext) finalReorderIndic() {
+	c.forEachSyllable(c.finalReorderSyllable)
+}
+
+// finalReorderSyllable reorders the consonant syllable [start, end).
+func (c *shapeContext) finalReorderSyllable(start, end int) {
+	cfg := c.plan.indic
+	g := c.buf.glyphs
+
+	base := start
+	for base < end && g[base].pos < posBaseC {
+		base++
+	}
+	if start < base && base < end && g[base].pos > posBaseC {
+		base--
+	}
+	if base < end {
+		for start < base && (g[base].cat == indicN || g[base].cat == indicH) {
+			base--
+		}
+	}
+
+	// Pre-base matras move after the last explicit halant before the base, i.e. after the
+	// consonants that formed no half forms.
+	if start+1 < end && start < base {
+		newPos := base - 1
+		if base == end {
+			newPos = base - 2
+		}
+		if !cfg.matraAfterChillu {
+			for newPos > start && g[newPos].cat != indicM && g[newPos].cat != indicH {
+				newPos--
+			}
+			if g[newPos].cat == indicH && g[newPos].pos != posPreM {
+				if newPos+1 < end && (g[newPos+1].cat == indicZWJ || g[newPos+1].cat == indicZWNJ) {
+					newPos++
+				}
+			} else {
+				newPos = start
+			}
+		}
+		if start < newPos && g[newPos].pos != posPreM {
+			for i := newPos; i > start; i-- {
+				if g[i-1].pos != posPreM {
+					continue
+				}
+				oldPos := i - 1
+				if oldPos < base && base <= newPos {
+					base--
+				}
+				tmp := g[oldPos]
+				copy(g[oldPos:newPos], g[oldPos+1:newPos+1])
+				g[newPos] = tmp
+				newPos--
+			}
+		}
+	}
+
+	// The reph, formed when Ra Halant became a single glyph, moves to the position of the script.
+	if start+1 < end && g[start].pos == posRaToBecomeReph && g[start+1].pos != posRaToBecomeReph {
+		newPos := -1
+		if cfg.rephPos != posAfterPost {
+			for i := start + 1; i < base; i++ {
+				if g[i].cat == indicH {
+					newPos = i
+					if i+1 < end && (g[i+1].cat == indicZWJ || g[i+1].cat == indicZWNJ) {
+						newPos++
+					}
+					break
+				}
+			}
+		}
+		if newPos < 0 {
+			newPos = base
+			if newPos >= end {
+				newPos = end - 1
+			}
+			for newPos+1 < end && g[newPos+1].pos <= cfg.rephPos {
+				newPos++
+			}
+		}
+		tmp := g[start]
+		copy(g[start:newPos], g[start+1:newPos+1])
+		g[newPos] = tmp
+	}
+}
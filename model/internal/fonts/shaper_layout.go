@@ -0,0 +1,635 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import "sort"
+
+// maxContextLength limits the length of the sequences matched by contextual lookups.
+const maxContextLength = 64
+
+// layoutApplier applies the lookups of a GSUB or GPOS table to the buffer of a shape context.
+type layoutApplier struct {
+	c     *shapeContext
+	table *layoutTable
+	gpos  bool
+	// perSyllable restricts the matching of sequences to glyphs of the same syllable.
+	perSyllable bool
+
+	lookup  *lookupTable
+	mask    uint32
+	nesting int
+}
+
+// glyphs returns the glyphs of the buffer.
+func (a *layoutApplier) glyphs() []glyphInfo {
+	return a.c.buf.glyphs
+}
+
+// applyLookup applies the lookup of index `index` to the glyphs with mask `mask`.
+func (a *layoutApplier) applyLookup(index int, mask uint32) {
+	if index < 0 || index >= len(a.table.lookups) {
+		return
+	}
+	l := a.table.lookups[index]
+	a.lookup, a.mask = l, mask
+
+	if !a.gpos && l.typ == gsubReverseChainSingle {
+		for i := len(a.glyphs()) - 1; i >= 0; i-- {
+			if g := &a.glyphs()[i]; g.mask&mask != 0 && !a.skipped(g) {
+				a.applyReverseChain(l, i)
+			}
+		}
+		return
+	}
+
+	for i := 0; i < len(a.glyphs()); {
+		g := &a.glyphs()[i]
+		if g.mask&mask == 0 || a.skipped(g) {
+			i++
+			continue
+		}
+		n := len(a.glyphs())
+		next, ok := a.applyAt(l, i)
+		if !ok || (next <= i && len(a.glyphs()) >= n) {
+			next = i + 1
+		}
+		i = next
+	}
+}
+
+// applyNested applies the lookup of index `index` at position `i` as part of a contextual lookup.
+func (a *layoutApplier) applyNested(index, i int) {
+	if index < 0 || index >= len(a.table.lookups) || i >= len(a.glyphs()) ||
+		a.nesting >= maxLayoutNesting {
+		return
+	}
+	lookup, mask := a.lookup, a.mask
+	a.lookup, a.mask = a.table.lookups[index], ^uint32(0)
+	a.nesting++
+	if a.gpos || a.lookup.typ != gsubReverseChainSingle {
+		a.applyAt(a.lookup, i)
+	}
+	a.nesting--
+	a.lookup, a.mask = lookup, mask
+}
+
+// skipped returns true if `g` is ignored by the current lookup.
+func (a *layoutApplier) skipped(g *glyphInfo) bool {
+	flag := a.lookup.flag
+	switch g.class {
+	case glyphClassBase:
+		if flag&lookupIgnoreBaseGlyphs != 0 {
+			return true
+		}
+	case glyphClassLigature:
+		if flag&lookupIgnoreLigatures != 0 {
+			return true
+		}
+	case glyphClassMark:
+		if flag&lookupIgnoreMarks != 0 {
+			return true
+		}
+		gdef := a.c.ttf.gdef
+		if flag&lookupUseMarkFilteringSet != 0 && gdef != nil {
+			if a.lookup.markSet < 0 || a.lookup.markSet >= len(gdef.markGlyphSets) {
+				return true
+			}
+			if _, ok := gdef.markGlyphSets[a.lookup.markSet][g.gid]; !ok {
+				return true
+			}
+		}
+		if attachType := flag & lookupMarkAttachmentType; attachType != 0 && gdef != nil {
+			if gdef.markAttachClass[g.gid] != attachType>>8 {
+				return true
+			}
+		}
+	}
+	if isDefaultIgnorable(g.r) {
+		if g.r == zwj || g.r == zwnj {
+			return a.gpos || (!a.c.plan.manualJoiners && g.r == zwj)
+		}
+		return true
+	}
+	return false
+}
+
+// next returns the index of the glyph following `i` not ignored by the current lookup, or -1.
+func (a *layoutApplier) next(i int) int {
+	glyphs := a.glyphs()
+	for j := i + 1; j < len(glyphs); j++ {
+		if a.perSyllable && glyphs[j].syllable != glyphs[i].syllable {
+			return -1
+		}
+		if !a.skipped(&glyphs[j]) {
+			return j
+		}
+	}
+	return -1
+}
+
+// prev returns the index of the glyph preceding `i` not ignored by the current lookup, or -1.
+func (a *layoutApplier) prev(i int) int {
+	glyphs := a.glyphs()
+	for j := i - 1; j >= 0; j-- {
+		if a.perSyllable && glyphs[j].syllable != glyphs[i].syllable {
+			return -1
+		}
+		if !a.skipped(&glyphs[j]) {
+			return j
+		}
+	}
+	return -1
+}
+
+// applyAt applies the first subtable of `l` matching at position `i`. It returns the position
+// following the glyphs processed and true if a subtable matched.
+func (a *layoutApplier) applyAt(l *lookupTable, i int) (int, bool) {
+	for _, st := range l.subtables {
+		var next int
+		var ok bool
+		if a.gpos {
+			next, ok = a.applyGPOS(l, st, i)
+		} else {
+			next, ok = a.applyGSUB(st, i)
+		}
+		if ok {
+			return next, true
+		}
+	}
+	return i, false
+}
+
+// applyGSUB applies the GSUB subtable `st` at position `i`.
+func (a *layoutApplier) applyGSUB(st interface{}, i int) (int, bool) {
+	g := &a.glyphs()[i]
+	switch st := st.(type) {
+	case singleSubst:
+		if sub, ok := st[g.gid]; ok {
+			a.replace(i, sub)
+			return i + 1, true
+		}
+	case multipleSubst:
+		if seq, ok := st[g.gid]; ok {
+			a.expand(i, seq)
+			return i + len(seq), true
+		}
+	case alternateSubst:
+		if alts, ok := st[g.gid]; ok && len(alts) > 0 {
+			a.replace(i, alts[0])
+			return i + 1, true
+		}
+	case ligatureSubst:
+		for _, lig := range st[g.gid] {
+			if positions, ok := a.matchComponents(i, lig.components); ok {
+				a.ligate(positions, lig.glyph)
+				return i + 1, true
+			}
+		}
+	case *contextSubtable:
+		return a.applyContext(st, i)
+	}
+	return i, false
+}
+
+// replace replaces the glyph at `i` by `gid`.
+func (a *layoutApplier) replace(i int, gid GID) {
+	g := &a.glyphs()[i]
+	g.gid = gid
+	if gdef := a.c.ttf.gdef; gdef != nil && gdef.hasGlyphClasses {
+		g.class = gdef.glyphClasses[gid]
+	}
+}
+
+// expand replaces the glyph at `i` by the glyphs `seq`.
+func (a *layoutApplier) expand(i int, seq []GID) {
+	glyphs := a.glyphs()
+	g := glyphs[i]
+	if len(seq) == 0 {
+		// The glyph is deleted, its text goes to a neighbor.
+		if i > 0 {
+			glyphs[i-1].text = append(glyphs[i-1].text, g.text...)
+		} else if i+1 < len(glyphs) {
+			glyphs[i+1].text = append(append([]rune(nil), g.text...), glyphs[i+1].text...)
+		}
+		a.c.buf.glyphs = append(glyphs[:i], glyphs[i+1:]...)
+		return
+	}
+	out := make([]glyphInfo, 0, len(glyphs)+len(seq)-1)
+	out = append(out, glyphs[:i]...)
+	for j, gid := range seq {
+		ng := g
+		if j > 0 {
+			ng.text = nil
+		}
+		out = append(out, ng)
+		a.c.buf.glyphs = out
+		a.replace(len(out)-1, gid)
+	}
+	out = append(out, glyphs[i+1:]...)
+	a.c.buf.glyphs = out
+}
+
+// matchComponents matches the ligature `components` following the glyph at `i` and returns the
+// positions of all the components.
+func (a *layoutApplier) matchComponents(i int, components []GID) ([]int, bool) {
+	positions := []int{i}
+	glyphs := a.glyphs()
+	j := i
+	for _, comp := range components {
+		j = a.next(j)
+		if j < 0 || glyphs[j].gid != comp || glyphs[j].mask&a.mask == 0 {
+			return nil, false
+		}
+		positions = append(positions, j)
+	}
+	return positions, true
+}
+
+// ligate replaces the glyphs at `positions` by the ligature `gid`. The glyphs skipped between the
+// components, e.g. marks, are kept after the ligature and remember the component they follow.
+func (a *layoutApplier) ligate(positions []int, gid GID) {
+	glyphs := a.glyphs()
+	first, last := positions[0], positions[len(positions)-1]
+
+	a.c.buf.nextLigID++
+	ligID := a.c.buf.nextLigID
+	lig := glyphs[first]
+	lig.text = nil
+	lig.ligID, lig.ligComp, lig.numComps = ligID, 0, len(positions)
+	for k := first; k <= last; k++ {
+		if glyphs[k].cluster < lig.cluster {
+			lig.cluster = glyphs[k].cluster
+		}
+	}
+
+	// The text of the ligature is that of its components in logical order, as they may have
+	// been reordered.
+	components := make([]glyphInfo, len(positions))
+	for k, p := range positions {
+		components[k] = glyphs[p]
+	}
+	sort.SliceStable(components, func(a, b int) bool { return components[a].index < components[b].index })
+	lig.index = components[0].index
+	for _, g := range components {
+		lig.text = append(lig.text, g.text...)
+	}
+
+	out := make([]glyphInfo, 0, len(glyphs)-len(positions)+1)
+	out = append(out, glyphs[:first]...)
+	out = append(out, lig)
+	comp := 0
+	for k := first; k <= last; k++ {
+		if comp < len(positions) && positions[comp] == k {
+			comp++
+			continue
+		}
+		g := glyphs[k]
+		g.ligID, g.ligComp = ligID, comp
+		g.cluster = lig.cluster
+		out = append(out, g)
+	}
+	out = append(out, glyphs[last+1:]...)
+	a.c.buf.glyphs = out
+
+	a.replace(first, gid)
+	if gdef := a.c.ttf.gdef; gdef == nil || !gdef.hasGlyphClasses {
+		out[first].class = glyphClassLigature
+	}
+}
+
+// applyContext applies the contextual subtable `c` at position `i`.
+func (a *layoutApplier) applyContext(c *contextSubtable, i int) (int, bool) {
+	glyphs := a.glyphs()
+	for _, rule := range c.rules(glyphs[i].gid) {
+		positions := []int{i}
+		j := i
+		matched := true
+		for _, m := range rule.input[1:] {
+			if j = a.next(j); j < 0 || !m.matches(glyphs[j].gid) {
+				matched = false
+				break
+			}
+			positions = append(positions, j)
+		}
+		if !matched || !a.matchBacktrack(i, rule.backtrack) ||
+			!a.matchLookahead(positions[len(positions)-1], rule.lookahead) {
+			continue
+		}
+		return a.applySeqLookups(positions, rule.lookups), true
+	}
+	return i, false
+}
+
+// matchBacktrack returns true if the glyphs preceding `i` match `backtrack`.
+func (a *layoutApplier) matchBacktrack(i int, backtrack []glyphMatcher) bool {
+	j := i
+	for _, m := range backtrack {
+		if j = a.prev(j); j < 0 || !m.matches(a.glyphs()[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLookahead returns true if the glyphs following `i` match `lookahead`.
+func (a *layoutApplier) matchLookahead(i int, lookahead []glyphMatcher) bool {
+	j := i
+	for _, m := range lookahead {
+		if j = a.next(j); j < 0 || !m.matches(a.glyphs()[j].gid) {
+			return false
+		}
+	}
+	return true
+}
+
+// applySeqLookups applies `lookups` to the matched input glyphs at `positions` and returns the
+// position following the input once the buffer has been modified.
+func (a *layoutApplier) applySeqLookups(positions []int, lookups []seqLookup) int {
+	count := len(positions)
+	end := positions[count-1] + 1
+	for _, rec := range lookups {
+		idx := rec.index
+		if idx >= count {
+			continue
+		}
+		origLen := len(a.glyphs())
+		a.applyNested(rec.lookup, positions[idx])
+		delta := len(a.glyphs()) - origLen
+		if delta == 0 {
+			continue
+		}
+
+		// The nested lookup changed the number of glyphs, the positions of the following input
+		// glyphs are adjusted.
+		end += delta
+		if end < positions[idx] {
+			delta += positions[idx] - end
+			end = positions[idx]
+		}
+		next := idx + 1
+		if delta > 0 {
+			if delta+count > maxContextLength {
+				break
+			}
+			positions = append(positions, make([]int, delta)...)
+		} else {
+			if d := next - count; delta < d {
+				delta = d
+			}
+			next -= delta
+		}
+		copy(positions[next+delta:count+delta], positions[next:count])
+		next += delta
+		count += delta
+		for j := idx + 1; j < next; j++ {
+			positions[j] = positions[j-1] + 1
+		}
+		for ; next < count; next++ {
+			positions[next] += delta
+		}
+		positions = positions[:count]
+	}
+	return end
+}
+
+// applyReverseChain applies the reverse chaining single substitution lookup `l` at `i`.
+func (a *layoutApplier) applyReverseChain(l *lookupTable, i int) {
+	g := &a.glyphs()[i]
+	for _, st := range l.subtables {
+		r, ok := st.(*reverseChainSubst)
+		if !ok {
+			continue
+		}
+		idx, ok := r.coverage[g.gid]
+		if !ok || idx >= len(r.substitutes) {
+			continue
+		}
+		backtrack := make([]glyphMatcher, len(r.backtrack))
+		for k, cov := range r.backtrack {
+			backtrack[k] = coverageMatch(cov)
+		}
+		lookahead := make([]glyphMatcher, len(r.lookahead))
+		for k, cov := range r.lookahead {
+			lookahead[k] = coverageMatch(cov)
+		}
+		if a.matchBacktrack(i, backtrack) && a.matchLookahead(i, lookahead) {
+			a.replace(i, r.substitutes[idx])
+			return
+		}
+	}
+}
+
+// applyGPOS applies the GPOS subtable `st` of lookup `l` at position `i`.
+func (a *layoutApplier) applyGPOS(l *lookupTable, st interface{}, i int) (int, bool) {
+	glyphs := a.glyphs()
+	g := &glyphs[i]
+	switch st := st.(type) {
+	case singlePos:
+		if v, ok := st[g.gid]; ok {
+			a.adjust(i, v)
+			return i + 1, true
+		}
+	case *pairPos:
+		if _, ok := st.coverage[g.gid]; !ok {
+			return i, false
+		}
+		j := a.next(i)
+		if j < 0 {
+			return i, false
+		}
+		v, ok := st.lookup(g.gid, glyphs[j].gid)
+		if !ok {
+			return i, false
+		}
+		a.adjust(i, v.first)
+		a.adjust(j, v.second)
+		if st.hasSecond {
+			return j + 1, true
+		}
+		return j, true
+	case cursivePos:
+		exit := st[g.gid].exit
+		if exit == nil {
+			return i, false
+		}
+		j := a.next(i)
+		if j < 0 {
+			return i, false
+		}
+		entry := st[glyphs[j].gid].entry
+		if entry == nil {
+			return i, false
+		}
+		a.attachCursive(l, i, j, exit, entry)
+		return i + 1, true
+	case *markAttachPos:
+		if l.typ == gposMarkToMark {
+			return a.attachMarkToMark(st, i)
+		}
+		return a.attachMarkToBase(st, i)
+	case *markLigPos:
+		return a.attachMarkToLigature(st, i)
+	case *contextSubtable:
+		return a.applyContext(st, i)
+	}
+	return i, false
+}
+
+// adjust applies the value record `v` to the glyph at `i`.
+func (a *layoutApplier) adjust(i int, v valueRecord) {
+	g := &a.glyphs()[i]
+	g.xOff += int32(v.xPlacement)
+	g.yOff += int32(v.yPlacement)
+	g.xAdv += int32(v.xAdvance)
+}
+
+// attachCursive connects the exit anchor `exit` of glyph `i` to the entry anchor `entry` of glyph
+// `j`.
+func (a *layoutApplier) attachCursive(l *lookupTable, i, j int, exit, entry *anchor) {
+	glyphs := a.glyphs()
+	gi, gj := &glyphs[i], &glyphs[j]
+	if a.c.buf.rtl {
+		d := int32(exit.x) + gi.xOff
+		gi.xAdv -= d
+		gi.xOff -= d
+		gj.xAdv = int32(entry.x) + gj.xOff
+	} else {
+		gi.xAdv = int32(exit.x) + gi.xOff
+		d := int32(entry.x) + gj.xOff
+		gj.xAdv -= d
+		gj.xOff -= d
+	}
+
+	// The child glyph is aligned vertically on its parent.
+	child, parent := i, j
+	dy := int32(entry.y) - int32(exit.y)
+	if l.flag&lookupRightToLeft == 0 {
+		child, parent = j, i
+		dy = -dy
+	}
+	glyphs[child].attachType = attachCursive
+	glyphs[child].attachTo = parent
+	glyphs[child].yOff = dy
+}
+
+// findMarkBase returns the index of the glyph preceding the mark at `i` that is not a mark, or -1.
+func (a *layoutApplier) findMarkBase(i int) int {
+	glyphs := a.glyphs()
+	for j := i - 1; j >= 0; j-- {
+		if glyphs[j].class != glyphClassMark && !isDefaultIgnorable(glyphs[j].r) {
+			return j
+		}
+	}
+	return -1
+}
+
+// attachMark attaches the mark at `i` to the glyph at `j` by the anchors `markAnchor` and
+// `baseAnchor`.
+func (a *layoutApplier) attachMark(i, j int, markAnchor, baseAnchor *anchor) {
+	g := &a.glyphs()[i]
+	g.xOff = int32(baseAnchor.x) - int32(markAnchor.x)
+	g.yOff = int32(baseAnchor.y) - int32(markAnchor.y)
+	g.attachType = attachMark
+	g.attachTo = j
+}
+
+// markRecordOf returns the mark record of glyph `gid` in the coverage `marks`.
+func markRecordOf(marks coverage, markArray []markRecord, gid GID) (markRecord, bool) {
+	idx, ok := marks[gid]
+	if !ok || idx >= len(markArray) || markArray[idx].anchor == nil {
+		return markRecord{}, false
+	}
+	return markArray[idx], true
+}
+
+// attachMarkToBase applies the mark-to-base subtable `st` at `i`.
+func (a *layoutApplier) attachMarkToBase(st *markAttachPos, i int) (int, bool) {
+	glyphs := a.glyphs()
+	mark, ok := markRecordOf(st.marks, st.markArray, glyphs[i].gid)
+	if !ok {
+		return i, false
+	}
+	j := a.findMarkBase(i)
+	if j < 0 {
+		return i, false
+	}
+	idx, ok := st.bases[glyphs[j].gid]
+	if !ok || idx >= len(st.baseAnchors) || mark.class >= len(st.baseAnchors[idx]) {
+		return i, false
+	}
+	baseAnchor := st.baseAnchors[idx][mark.class]
+	if baseAnchor == nil {
+		return i, false
+	}
+	a.attachMark(i, j, mark.anchor, baseAnchor)
+	return i + 1, true
+}
+
+// attachMarkToLigature applies the mark-to-ligature subtable `st` at `i`.
+func (a *layoutApplier) attachMarkToLigature(st *markLigPos, i int) (int, bool) {
+	glyphs := a.glyphs()
+	mark, ok := markRecordOf(st.marks, st.markArray, glyphs[i].gid)
+	if !ok {
+		return i, false
+	}
+	j := a.findMarkBase(i)
+	if j < 0 {
+		return i, false
+	}
+	idx, ok := st.ligatures[glyphs[j].gid]
+	if !ok || idx >= len(st.ligAnchors) || len(st.ligAnchors[idx]) == 0 {
+		return i, false
+	}
+	components := st.ligAnchors[idx]
+
+	// The mark attaches to the component it followed when the ligature was formed, the last one
+	// otherwise.
+	comp := len(components) - 1
+	if lig, m := &glyphs[j], &glyphs[i]; lig.ligID != 0 && lig.ligID == m.ligID && m.ligComp > 0 {
+		if m.ligComp < len(components) {
+			comp = m.ligComp - 1
+		}
+	}
+	if mark.class >= len(components[comp]) || components[comp][mark.class] == nil {
+		return i, false
+	}
+	a.attachMark(i, j, mark.anchor, components[comp][mark.class])
+	return i + 1, true
+}
+
+// attachMarkToMark applies the mark-to-mark subtable `st` at `i`.
+func (a *layoutApplier) attachMarkToMark(st *markAttachPos, i int) (int, bool) {
+	glyphs := a.glyphs()
+	mark, ok := markRecordOf(st.marks, st.markArray, glyphs[i].gid)
+	if !ok {
+		return i, false
+	}
+	j := a.prev(i)
+	if j < 0 || glyphs[j].class != glyphClassMark {
+		return i, false
+	}
+
+	// Both marks must belong to the same ligature component.
+	m1, m2 := &glyphs[i], &glyphs[j]
+	if m1.ligID == m2.ligID {
+		if m1.ligID != 0 && m1.ligComp != m2.ligComp {
+			return i, false
+		}
+	} else if !(m1.ligID > 0 && m1.ligComp == 0) && !(m2.ligID > 0 && m2.ligComp == 0) {
+		return i, false
+	}
+
+	idx, ok := st.bases[m2.gid]
+	if !ok || idx >= len(st.baseAnchors) || mark.class >= len(st.baseAnchors[idx]) {
+		return i, false
+	}
+	baseAnchor := st.baseAnchors[idx][mark.class]
+	if baseAnchor == nil {
+		return i, false
+	}
+	a.attachMark(i, j, mark.anchor, baseAnchor)
+	return i + 1, true
+}
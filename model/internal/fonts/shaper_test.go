@@ -0,0 +1,177 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func loadShaper(t *testing.T, name string) (*Shaper, *TtfType) {
+	ttf, err := TtfParseFile(filepath.Join(fontDir, name))
+	require.NoError(t, err)
+	require.True(t, ttf.HasLayoutTables())
+	return NewShaper(&ttf), &ttf
+}
+
+func shapedText(glyphs []ShapedGlyph) []string {
+	var text []string
+	for _, g := range glyphs {
+		text = append(text, string(g.Text))
+	}
+	return text
+}
+
+func TestShapeLatin(t *testing.T) {
+	s, ttf := loadShaper(t, "roboto/Roboto-Regular.ttf")
+
+	// Ligature.
+	glyphs := s.Shape([]rune("fi"), ShapeOptions{})
+	require.Len(t, glyphs, 1)
+	require.Equal(t, "fi", string(glyphs[0].Text))
+	require.NotEqual(t, ttf.Chars['f'], glyphs[0].GID)
+
+	// Kerning.
+	glyphs = s.Shape([]rune("AV"), ShapeOptions{})
+	require.Len(t, glyphs, 2)
+	require.Less(t, glyphs[0].XAdvance, int32(ttf.Widths[ttf.Chars['A']]))
+
+	// Composition of a combining mark with its base.
+	glyphs = s.Shape([]rune("é"), ShapeOptions{})
+	require.Len(t, glyphs, 1)
+	require.Equal(t, ttf.Chars['é'], glyphs[0].GID)
+	require.Equal(t, "é", string(glyphs[0].Text))
+}
+
+func TestShapeFeatures(t *testing.T) {
+	s, ttf := loadShaper(t, "roboto/Roboto-Regular.ttf")
+
+	// Disabled ligatures and kerning.
+	glyphs := s.Shape([]rune("fi"), ShapeOptions{Features: map[string]bool{"liga": false}})
+	require.Equal(t, []string{"f", "i"}, shapedText(glyphs))
+	glyphs = s.Shape([]rune("AV"), ShapeOptions{Features: map[string]bool{"kern": false}})
+	require.Equal(t, int32(ttf.Widths[ttf.Chars['A']]), glyphs[0].XAdvance)
+
+	// Small capitals and old style figures are substituted glyphs displaying the same text.
+	for _, tc := range []struct {
+		feature string
+		r       rune
+	}{{"smcp", 'a'}, {"onum", '1'}} {
+		glyphs = s.Shape([]rune{tc.r}, ShapeOptions{Features: map[string]bool{tc.feature: true}})
+		require.Len(t, glyphs, 1)
+		require.NotEqual(t, ttf.Chars[tc.r], glyphs[0].GID, tc.feature)
+		require.Equal(t, string(tc.r), string(glyphs[0].Text))
+	}
+
+	// The plans of the feature toggles are distinct.
+	glyphs = s.Shape([]rune("a"), ShapeOptions{})
+	require.Equal(t, ttf.Chars['a'], glyphs[0].GID)
+}
+
+func TestShapeKernTable(t *testing.T) {
+	_, ttf := loadShaper(t, "roboto/Roboto-Regular.ttf")
+	a, v := ttf.Chars['A'], ttf.Chars['V']
+
+	// Version 0 kern table with a format 0 horizontal subtable of a single pair.
+	data := layoutData{
+		0, 0, 0, 1, // version, nTables
+		0, 0, 0, 20, 0, kernHorizontal, // version, length, coverage
+		0, 1, 0, 6, 0, 0, 0, 0, // nPairs, searchRange, entrySelector, rangeShift
+		byte(a >> 8), byte(a), byte(v >> 8), byte(v), 0xFF, 0x38, // A V -200
+	}
+	kern := parseKern(data)
+	require.Equal(t, kernTable{kernPair(a, v): -200}, kern)
+
+	// The kern table is used for fonts without kerning in their GPOS table.
+	ttf.gpos = nil
+	ttf.kern = kern
+	s := NewShaper(ttf)
+	glyphs := s.Shape([]rune("AVA"), ShapeOptions{})
+	require.Equal(t, int32(ttf.Widths[a])-200, glyphs[0].XAdvance)
+	require.Equal(t, int32(ttf.Widths[v]), glyphs[1].XAdvance)
+
+	glyphs = s.Shape([]rune("AV"), ShapeOptions{Features: map[string]bool{"kern": false}})
+	require.Equal(t, int32(ttf.Widths[a]), glyphs[0].XAdvance)
+}
+
+func TestShapeRightToLeft(t *testing.T) {
+	s, ttf := loadShaper(t, "FreeSans.ttf")
+
+	glyphs := s.Shape([]rune("שלום abc"), ShapeOptions{RightToLeft: true})
+	require.Len(t, glyphs, 8)
+	var clusters []int
+	for _, g := range glyphs {
+		clusters = append(clusters, g.Cluster)
+	}
+	require.Equal(t, []int{7, 6, 5, 4, 3, 2, 1, 0}, clusters)
+	require.Equal(t, ttf.Chars['ש'], glyphs[7].GID)
+
+	// Mirrored brackets.
+	glyphs = s.Shape([]rune("(א)"), ShapeOptions{RightToLeft: true})
+	require.Len(t, glyphs, 3)
+	require.Equal(t, ttf.Chars['('], glyphs[0].GID)
+	require.Equal(t, ")", string(glyphs[0].Text))
+}
+
+func TestShapeDevanagari(t *testing.T) {
+	s, ttf := loadShaper(t, "FreeSans.ttf")
+
+	testcases := []struct {
+		text     string
+		expected []string
+	}{
+		// The i matra is displayed before the consonant.
+		{"कि", []string{"ि", "क"}},
+		{"हिन्दी", []string{"ि", "ह", "न्", "द", "ी"}},
+		// Conjuncts, half forms and below-base forms.
+		{"क्षि", []string{"ि", "क्ष"}},
+		{"प्र", []string{"प्र"}},
+		{"स्त्री", []string{"स्", "त्र", "ी"}},
+		// The reph moves after the base consonant.
+		{"कर्म", []string{"क", "म", "र्"}},
+	}
+	for _, tc := range testcases {
+		glyphs := s.Shape([]rune(tc.text), ShapeOptions{})
+		require.Equal(t, tc.expected, shapedText(glyphs), tc.text)
+	}
+
+	glyphs := s.Shape([]rune("कि"), ShapeOptions{})
+	require.Equal(t, ttf.Chars['ि'], glyphs[0].GID)
+	require.Equal(t, 0, glyphs[0].Cluster)
+	require.Equal(t, 0, glyphs[1].Cluster)
+}
+
+func TestArabicForms(t *testing.T) {
+	testcases := []struct {
+		text  string
+		forms []int
+	}{
+		{"ب", []int{formIsol}},
+		{"بب", []int{formInit, formFina}},
+		{"ببب", []int{formInit, formMedi, formFina}},
+		// Alef does not join with the following letter.
+		{"ابب", []int{formIsol, formInit, formFina}},
+		// Marks are transparent.
+		{"بَب", []int{formInit, formNone, formFina}},
+		// ZWNJ prevents joining.
+		{"ب‌ب", []int{formIsol, formNone, formIsol}},
+		{"ب ب", []int{formIsol, formNone, formIsol}},
+	}
+	for _, tc := range testcases {
+		require.Equal(t, tc.forms, arabicForms([]rune(tc.text)), tc.text)
+	}
+
+	pf, ok := arabicPresentationForm('ب', formMedi)
+	require.True(t, ok)
+	require.Equal(t, rune(0xFE92), pf)
+	pf, ok = arabicPresentationForm('ا', formFina)
+	require.True(t, ok)
+	require.Equal(t, rune(0xFE8E), pf)
+	_, ok = arabicPresentationForm('ا', formInit)
+	require.False(t, ok)
+}
@@ -0,0 +1,56 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package fonts
+
+// saraAm maps the Thai and Lao SARA AM to the NIKHAHIT and SARA AA they are displayed with.
+var saraAm = map[rune][2]rune{
+	0x0E33: {0x0E4D, 0x0E32},
+	0x0EB3: {0x0ECD, 0x0EB2},
+}
+
+// isThaiAboveMark returns true if `r` is a Thai or Lao mark displayed above the consonant that
+// the NIKHAHIT of a SARA AM goes before.
+func isThaiAboveMark(r rune) bool {
+	switch {
+	case r == 0x0E31, r >= 0x0E34 && r <= 0x0E37, r >= 0x0E47 && r <= 0x0E4E,
+		r == 0x0EB1, r >= 0x0EB4 && r <= 0x0EB7, r >= 0x0EBB && r <= 0x0EBC, r >= 0x0EC8 && r <= 0x0ECD:
+		return true
+	}
+	return false
+}
+
+// decomposeSaraAm decomposes the SARA AM vowels into a NIKHAHIT placed before the preceding
+// above-base marks and a SARA AA, as fonts seldom handle them.
+func (c *shapeContext) decomposeSaraAm() {
+	var out []glyphInfo
+	for _, g := range c.buf.glyphs {
+		parts, ok := saraAm[g.r]
+		if !ok {
+			out = append(out, g)
+			continue
+		}
+		nikhahit, ok1 := c.hasGlyph(parts[0])
+		aa, ok2 := c.hasGlyph(parts[1])
+		if !ok1 || !ok2 {
+			out = append(out, g)
+			continue
+		}
+
+		n := g
+		n.gid, n.r, n.class = nikhahit, parts[0], c.glyphClass(nikhahit, parts[0])
+		pos := len(out)
+		for pos > 0 && isThaiAboveMark(out[pos-1].r) {
+			pos--
+		}
+		out = append(out, glyphInfo{})
+		copy(out[pos+1:], out[pos:])
+		out[pos] = n
+
+		g.gid, g.r, g.text, g.class = aa, parts[1], nil, c.glyphClass(aa, parts[1])
+		out = append(out, g)
+	}
+	c.buf.glyphs = out
+}
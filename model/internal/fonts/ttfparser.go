@@ -107,6 +107,13 @@ type TtfType struct {
 	Chars map[rune]GID
 	// GlyphNames is a list of glyphs from the "post" section of the TrueType file.
 	GlyphNames []GlyphName
+
+	// gdef, gsub and gpos are the OpenType layout tables used to shape text, nil if absent.
+	gdef *gdefTable
+	gsub *layoutTable
+	gpos *layoutTable
+	// kern are the pair adjustments of the legacy kerning table, nil if absent.
+	kern kernTable
 }
 
 // MakeToUnicode returns a ToUnicode CMap based on the encoding of `ttf`.
@@ -157,6 +164,7 @@ type ttfParser struct {
 	rec              TtfType
 	f                io.ReadSeeker
 	tables           map[string]uint32
+	lengths          map[string]uint32
 	numberOfHMetrics uint16
 	numGlyphs        uint16
 }
@@ -219,6 +227,7 @@ func (t *ttfParser) Parse() (TtfType, error) {
 	numTables := int(t.ReadUShort())
 	t.Skip(3 * 2) // searchRange, entrySelector, rangeShift
 	t.tables = make(map[string]uint32)
+	t.lengths = make(map[string]uint32)
 	var tag string
 	for j := 0; j < numTables; j++ {
 		tag, err = t.ReadStr(4)
@@ -227,8 +236,9 @@ func (t *ttfParser) Parse() (TtfType, error) {
 		}
 		t.Skip(4) // checkSum
 		offset := t.ReadULong()
-		t.Skip(4) // length
+		length := t.ReadULong()
 		t.tables[tag] = offset
+		t.lengths[tag] = length
 	}
 
 	common.Log.Trace(describeTables(t.tables))
@@ -303,9 +313,59 @@ func (t *ttfParser) ParseComponents() error {
 		}
 	}
 
+	// OpenType layout tables. They are only needed to shape text, so fonts with invalid layout
+	// tables can still be used without shaping.
+	t.parseLayoutTables()
+
 	return nil
 }
 
+// parseLayoutTables parses the GDEF, GSUB, GPOS and kern tables used to shape text.
+func (t *ttfParser) parseLayoutTables() {
+	if data := t.readTable("GDEF"); data != nil {
+		gdef, err := parseGDEF(data)
+		if err != nil {
+			common.Log.Debug("Unable to parse GDEF table: %v", err)
+		}
+		t.rec.gdef = gdef
+	}
+	if data := t.readTable("GSUB"); data != nil {
+		gsub, err := parseLayoutTable(data, false)
+		if err != nil {
+			common.Log.Debug("Unable to parse GSUB table: %v", err)
+		}
+		t.rec.gsub = gsub
+	}
+	if data := t.readTable("GPOS"); data != nil {
+		gpos, err := parseLayoutTable(data, true)
+		if err != nil {
+			common.Log.Debug("Unable to parse GPOS table: %v", err)
+		}
+		t.rec.gpos = gpos
+	}
+	if data := t.readTable("kern"); data != nil {
+		t.rec.kern = parseKern(data)
+	}
+}
+
+// readTable returns the data of the table named `tag`, or nil if the font has no such table or it
+// cannot be read.
+func (t *ttfParser) readTable(tag string) layoutData {
+	length, ok := t.lengths[tag]
+	if !ok || length == 0 {
+		return nil
+	}
+	if err := t.Seek(tag); err != nil {
+		return nil
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(t.f, data); err != nil {
+		common.Log.Debug("Unable to read %s table: %v", tag, err)
+		return nil
+	}
+	return data
+}
+
 func (t *ttfParser) ParseHead() error {
 	if err := t.Seek("head"); err != nil {
 		return err
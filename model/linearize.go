@@ -0,0 +1,623 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// SetLinearized sets whether the output is linearized ("fast web view"). Linearized files are
+// organized such that the first page can be displayed after reading only the beginning of the
+// file, and contain hint tables which locate the objects of the other pages. Linearized output
+// always uses cross-reference tables and does not support object streams or append mode.
+func (w *PdfWriter) SetLinearized(linearized bool) {
+	w.linearized = linearized
+}
+
+// linearizedLayout contains the objects of a linearized file grouped by section
+// (ISO 32000-1 Annex F.3), in file order.
+type linearizedLayout struct {
+	// docObjects are the document-level objects (part 4), starting with the catalog.
+	docObjects []core.PdfObject
+	// firstPage are the objects of the first page section (part 6), starting with the page object.
+	firstPage []core.PdfObject
+	// pages are the objects of the remaining pages (part 7), each starting with the page object.
+	pages [][]core.PdfObject
+	// shared are the objects shared by the remaining pages (part 8).
+	shared []core.PdfObject
+	// other are the objects not used by pages (part 9).
+	other []core.PdfObject
+	// pageShared are the shared object identifiers referenced by each of the remaining pages.
+	pageShared [][]int
+}
+
+// linearizedOffsets contains the positions of the parts of a linearized file.
+type linearizedOffsets struct {
+	objects       map[core.PdfObject]int64
+	linearization int64
+	firstPageXref int64
+	hint          int64
+	firstPageEnd  int64
+	mainXref      int64
+}
+
+// collectPages returns the page objects and page tree nodes of the page tree at `node`.
+func collectPages(node *core.PdfIndirectObject, pages, nodes *[]*core.PdfIndirectObject, visited map[core.PdfObject]bool) {
+	if node == nil || visited[node] {
+		return
+	}
+	visited[node] = true
+	dict, ok := core.GetDict(node.PdfObject)
+	if !ok {
+		return
+	}
+	if typ, _ := core.GetNameVal(dict.Get("Type")); typ == "Page" {
+		*pages = append(*pages, node)
+		return
+	}
+	*nodes = append(*nodes, node)
+	kids, _ := core.GetArray(dict.Get("Kids"))
+	if kids == nil {
+		return
+	}
+	for _, kid := range kids.Elements() {
+		if ind, ok := kid.(*core.PdfIndirectObject); ok {
+			collectPages(ind, pages, nodes, visited)
+		}
+	}
+}
+
+// linearizedReach returns the objects to write which are reachable from `start`, in depth-first
+// order starting with `start`. Parent entries are not followed and the traversal stops at
+// objects in `stop`.
+func (w *PdfWriter) linearizedReach(start core.PdfObject, stop map[core.PdfObject]bool) []core.PdfObject {
+	var objs []core.PdfObject
+	visited := map[core.PdfObject]bool{}
+	var walk func(obj core.PdfObject)
+	walk = func(obj core.PdfObject) {
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject, *core.PdfObjectStream:
+			if visited[t] || (t != start && stop[t]) || !w.hasObject(t) {
+				return
+			}
+			visited[t] = true
+			objs = append(objs, t)
+			if ind, ok := t.(*core.PdfIndirectObject); ok {
+				walk(ind.PdfObject)
+			} else {
+				walk(t.(*core.PdfObjectStream).PdfObjectDictionary)
+			}
+		case *core.PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				if key != "Parent" {
+					walk(t.Get(key))
+				}
+			}
+		case *core.PdfObjectArray:
+			for _, elem := range t.Elements() {
+				walk(elem)
+			}
+		}
+	}
+	walk(start)
+	return objs
+}
+
+// linearizedLayout groups the objects to write by section of the linearized file.
+func (w *PdfWriter) linearizedLayout() (*linearizedLayout, error) {
+	// The catalog is taken from the root object, which is the copy being written.
+	catalog, ok := core.GetDict(w.root.PdfObject)
+	if !ok {
+		return nil, errors.New("invalid catalog")
+	}
+	pagesObj, ok := catalog.Get("Pages").(*core.PdfIndirectObject)
+	if !ok {
+		return nil, errors.New("invalid Pages object")
+	}
+	var pages, nodes []*core.PdfIndirectObject
+	collectPages(pagesObj, &pages, &nodes, map[core.PdfObject]bool{})
+	if len(pages) == 0 {
+		return nil, errors.New("linearized output requires at least one page")
+	}
+
+	stop := map[core.PdfObject]bool{w.root: true, w.infoObj: true}
+	for _, obj := range pages {
+		stop[obj] = true
+	}
+	for _, obj := range nodes {
+		stop[obj] = true
+	}
+
+	l := &linearizedLayout{}
+	assigned := map[core.PdfObject]bool{}
+	sharedID := map[core.PdfObject]int{}
+
+	// First page section.
+	l.firstPage = w.linearizedReach(pages[0], stop)
+	for i, obj := range l.firstPage {
+		assigned[obj] = true
+		sharedID[obj] = i
+	}
+
+	// Remaining pages: objects used by a single page are private, others are shared.
+	reach := make([][]core.PdfObject, len(pages))
+	usage := map[core.PdfObject]int{}
+	for i := 1; i < len(pages); i++ {
+		reach[i] = w.linearizedReach(pages[i], stop)
+		for _, obj := range reach[i] {
+			usage[obj]++
+		}
+	}
+	for i := 1; i < len(pages); i++ {
+		for _, obj := range reach[i] {
+			if usage[obj] > 1 && !assigned[obj] {
+				assigned[obj] = true
+				sharedID[obj] = len(l.firstPage) + len(l.shared)
+				l.shared = append(l.shared, obj)
+			}
+		}
+	}
+	for i := 1; i < len(pages); i++ {
+		var private []core.PdfObject
+		var shared []int
+		for _, obj := range reach[i] {
+			if id, ok := sharedID[obj]; ok {
+				shared = append(shared, id)
+				continue
+			}
+			if !assigned[obj] {
+				assigned[obj] = true
+				private = append(private, obj)
+			}
+		}
+		l.pages = append(l.pages, private)
+		l.pageShared = append(l.pageShared, shared)
+	}
+
+	// Document-level objects needed to open the document.
+	l.docObjects = append(l.docObjects, w.root)
+	assigned[w.root] = true
+	if w.encryptObj != nil && w.hasObject(w.encryptObj) {
+		l.docObjects = append(l.docObjects, w.encryptObj)
+		assigned[w.encryptObj] = true
+	}
+	for _, key := range []core.PdfObjectName{"ViewerPreferences", "OpenAction", "AcroForm", "Threads"} {
+		obj := catalog.Get(key)
+		if obj == nil {
+			continue
+		}
+		for _, o := range w.linearizedReach(core.MakeArray(obj), stop) {
+			if !assigned[o] {
+				assigned[o] = true
+				l.docObjects = append(l.docObjects, o)
+			}
+		}
+	}
+
+	for _, obj := range w.objects {
+		if !assigned[obj] {
+			l.other = append(l.other, obj)
+		}
+	}
+	return l, nil
+}
+
+// numberObjects assigns object numbers in file order. The objects of the first page cross
+// reference section (linearization dictionary, document-level objects, hint stream and first
+// page) are numbered after the objects of the main section. Returns the number of the
+// linearization dictionary, and the number of the hint stream.
+func (l *linearizedLayout) numberObjects() (int64, int64, error) {
+	num := int64(1)
+	setNum := func(objs []core.PdfObject) error {
+		for _, obj := range objs {
+			switch t := obj.(type) {
+			case *core.PdfIndirectObject:
+				t.ObjectNumber, t.GenerationNumber = num, 0
+			case *core.PdfObjectStream:
+				t.ObjectNumber, t.GenerationNumber = num, 0
+			default:
+				common.Log.Debug("ERROR: Unsupported type in linearized output: %T", obj)
+				return ErrTypeCheck
+			}
+			num++
+		}
+		return nil
+	}
+	for _, objs := range l.pages {
+		if err := setNum(objs); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := setNum(l.shared); err != nil {
+		return 0, 0, err
+	}
+	if err := setNum(l.other); err != nil {
+		return 0, 0, err
+	}
+	linNum := num
+	num++
+	if err := setNum(l.docObjects); err != nil {
+		return 0, 0, err
+	}
+	hintNum := num
+	num++
+	if err := setNum(l.firstPage); err != nil {
+		return 0, 0, err
+	}
+	return linNum, hintNum, nil
+}
+
+// mainObjects returns the objects of the main cross-reference section in file order.
+func (l *linearizedLayout) mainObjects() []core.PdfObject {
+	var objs []core.PdfObject
+	for _, page := range l.pages {
+		objs = append(objs, page...)
+	}
+	objs = append(objs, l.shared...)
+	return append(objs, l.other...)
+}
+
+// renderObject returns the serialized indirect object `obj` numbered `num`.
+func (w *PdfWriter) renderObject(num int64, obj core.PdfObject) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, pos := w.writer, w.writePos
+	w.writer, w.writePos = bufio.NewWriter(&buf), 0
+	w.writeObject(int(num), obj)
+	if w.werr == nil {
+		w.werr = w.writer.Flush()
+	}
+	w.writer, w.writePos = writer, pos
+	return buf.Bytes(), w.werr
+}
+
+// writeLinearized writes out the objects as a linearized PDF file.
+func (w *PdfWriter) writeLinearized() error {
+	l, err := w.linearizedLayout()
+	if err != nil {
+		return err
+	}
+	linNum, hintNum, err := l.numberObjects()
+	if err != nil {
+		return err
+	}
+	mainObjects := l.mainObjects()
+	firstObjects := append(append([]core.PdfObject{}, l.docObjects...), l.firstPage...)
+
+	// Encrypt and serialize the objects.
+	w.crossReferenceMap = make(map[int]crossReference)
+	rendered := make(map[core.PdfObject][]byte, len(w.objects))
+	for _, objs := range [][]core.PdfObject{mainObjects, firstObjects} {
+		for _, obj := range objs {
+			num := objectNumber(obj)
+			if w.crypter != nil && obj != w.encryptObj {
+				if err := w.crypter.Encrypt(obj, num, 0); err != nil {
+					common.Log.Debug("ERROR: Failed encrypting (%s)", err)
+					return err
+				}
+			}
+			if rendered[obj], err = w.renderObject(num, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The hint stream length does not depend on the offsets in the hint tables.
+	makeHint := func(firstPageOffset, sharedOffset int64) ([]byte, error) {
+		data, sharedTable := l.hintTables(rendered, firstPageOffset, sharedOffset)
+		stream := &core.PdfObjectStream{PdfObjectDictionary: core.MakeDict(), Stream: data}
+		stream.ObjectNumber = hintNum
+		stream.Set("S", core.MakeInteger(sharedTable))
+		stream.Set("Length", core.MakeInteger(int64(len(data))))
+		if w.crypter != nil {
+			if err := w.crypter.Encrypt(stream, hintNum, 0); err != nil {
+				return nil, err
+			}
+		}
+		return w.renderObject(hintNum, stream)
+	}
+	hint, err := makeHint(0, 0)
+	if err != nil {
+		return err
+	}
+	hintLen := int64(len(hint))
+
+	header := fmt.Sprintf("%%PDF-%d.%d\n%%âãÏÓ\n", w.majorVersion, w.minorVersion)
+	mainXrefHeader := fmt.Sprintf("xref\r\n0 %d\r\n", linNum)
+
+	// The linearization dictionary and the first page cross-reference section contain offsets
+	// that depend on their own lengths. They are padded to the lengths of the previous
+	// iteration until the lengths are stable.
+	layout := func(linLen, firstXrefLen int) *linearizedOffsets {
+		offsets := &linearizedOffsets{objects: map[core.PdfObject]int64{}}
+		offsets.linearization = int64(len(header))
+		pos := int64(len(header) + linLen)
+		offsets.firstPageXref = pos
+		pos += int64(firstXrefLen)
+		for _, obj := range l.docObjects {
+			offsets.objects[obj] = pos
+			pos += int64(len(rendered[obj]))
+		}
+		offsets.hint = pos
+		pos += hintLen
+		for _, obj := range l.firstPage {
+			offsets.objects[obj] = pos
+			pos += int64(len(rendered[obj]))
+		}
+		offsets.firstPageEnd = pos
+		for _, obj := range mainObjects {
+			offsets.objects[obj] = pos
+			pos += int64(len(rendered[obj]))
+		}
+		offsets.mainXref = pos
+		return offsets
+	}
+
+	var mainXref strings.Builder
+	var linDict, firstXref string
+	var offsets *linearizedOffsets
+	linLen, firstXrefLen := 0, 0
+	for {
+		offsets = layout(linLen, firstXrefLen)
+
+		mainXref.Reset()
+		mainXref.WriteString(mainXrefHeader)
+		mainXref.WriteString(fmt.Sprintf("%.10d %.5d f\r\n", 0, 65535))
+		for _, obj := range mainObjects {
+			mainXref.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets.objects[obj], 0))
+		}
+		mainXref.WriteString(fmt.Sprintf("trailer\n<< /Size %d >>\nstartxref\n%d\n%%%%EOF\n", linNum, offsets.firstPageXref))
+		fileLen := offsets.mainXref + int64(mainXref.Len())
+
+		linDict = fmt.Sprintf("%d 0 obj\n<< /Linearized 1 /L %d /H [ %d %d ] /O %d /E %d /N %d /T %d >>",
+			linNum, fileLen, offsets.hint, hintLen, objectNumber(l.firstPage[0]), offsets.firstPageEnd,
+			len(l.pages)+1, offsets.mainXref+int64(len(mainXrefHeader))-1)
+		firstXref = w.firstPageXref(l, offsets, linNum)
+
+		const linSuffix = "\nendobj\n"
+		const xrefSuffix = "\nstartxref\n0\n%%EOF\n"
+		if len(linDict)+len(linSuffix) <= linLen && len(firstXref)+len(xrefSuffix) <= firstXrefLen {
+			linDict += strings.Repeat(" ", linLen-len(linDict)-len(linSuffix)) + linSuffix
+			firstXref += strings.Repeat(" ", firstXrefLen-len(firstXref)-len(xrefSuffix)) + xrefSuffix
+			break
+		}
+		if n := len(linDict) + len(linSuffix); n > linLen {
+			linLen = n
+		}
+		if n := len(firstXref) + len(xrefSuffix); n > firstXrefLen {
+			firstXrefLen = n
+		}
+	}
+
+	// Offsets in the hint tables are given as if the hint stream was not present.
+	var sharedOffset int64
+	if len(l.shared) > 0 {
+		sharedOffset = offsets.objects[l.shared[0]] - hintLen
+	}
+	hint, err = makeHint(offsets.objects[l.firstPage[0]]-hintLen, sharedOffset)
+	if err != nil {
+		return err
+	}
+	if int64(len(hint)) != hintLen {
+		return errors.New("hint stream length changed")
+	}
+
+	w.writeString(header)
+	w.writeString(linDict)
+	w.writeString(firstXref)
+	for _, obj := range l.docObjects {
+		w.writeBytes(rendered[obj])
+	}
+	w.writeBytes(hint)
+	for _, obj := range l.firstPage {
+		w.writeBytes(rendered[obj])
+	}
+	for _, obj := range mainObjects {
+		w.writeBytes(rendered[obj])
+	}
+	if w.werr == nil && w.writePos != offsets.mainXref {
+		return fmt.Errorf("linearized output offset mismatch: %d != %d", w.writePos, offsets.mainXref)
+	}
+	w.writeString(mainXref.String())
+
+	if w.werr == nil {
+		w.werr = w.writer.Flush()
+	}
+	return w.werr
+}
+
+// firstPageXref returns the first page cross-reference section and trailer, without the
+// startxref marker.
+func (w *PdfWriter) firstPageXref(l *linearizedLayout, offsets *linearizedOffsets, linNum int64) string {
+	var sb strings.Builder
+	count := int64(len(l.docObjects)+len(l.firstPage)) + 2
+	sb.WriteString(fmt.Sprintf("xref\r\n%d %d\r\n", linNum, count))
+	sb.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets.linearization, 0))
+	for _, obj := range l.docObjects {
+		sb.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets.objects[obj], 0))
+	}
+	sb.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets.hint, 0))
+	for _, obj := range l.firstPage {
+		sb.WriteString(fmt.Sprintf("%.10d %.5d n\r\n", offsets.objects[obj], 0))
+	}
+
+	trailer := core.MakeDict()
+	trailer.Set("Size", core.MakeInteger(linNum+count))
+	trailer.Set("Root", w.root)
+	trailer.Set("Info", w.infoObj)
+	trailer.Set("Prev", core.MakeInteger(offsets.mainXref))
+	if w.crypter != nil {
+		trailer.Set("Encrypt", w.encryptObj)
+	}
+	if w.ids != nil {
+		trailer.Set("ID", w.ids)
+	}
+	sb.WriteString("trailer\n")
+	sb.WriteString(trailer.WriteString())
+	return sb.String()
+}
+
+// hintTables returns the data of the primary hint stream, containing the page offset hint table
+// and the shared object hint table, and the offset of the shared object hint table.
+// `firstPageOffset` is the offset of the first page object and `sharedOffset` is the offset of
+// the first object of the shared objects section.
+func (l *linearizedLayout) hintTables(rendered map[core.PdfObject][]byte,
+	firstPageOffset, sharedOffset int64) ([]byte, int64) {
+	length := func(objs []core.PdfObject) uint64 {
+		var n int
+		for _, obj := range objs {
+			n += len(rendered[obj])
+		}
+		return uint64(n)
+	}
+	numPages := len(l.pages) + 1
+	numObjects := make([]uint64, numPages)
+	lengths := make([]uint64, numPages)
+	shared := make([][]int, numPages)
+	numObjects[0], lengths[0] = uint64(len(l.firstPage)), length(l.firstPage)
+	for i, objs := range l.pages {
+		numObjects[i+1], lengths[i+1] = uint64(len(objs)), length(objs)
+		shared[i+1] = l.pageShared[i]
+	}
+	minMax := func(vals []uint64) (uint64, uint64) {
+		min, max := vals[0], vals[0]
+		for _, v := range vals {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		return min, max
+	}
+	minObjects, maxObjects := minMax(numObjects)
+	minLength, maxLength := minMax(lengths)
+	maxShared := 0
+	for _, s := range shared {
+		if len(s) > maxShared {
+			maxShared = len(s)
+		}
+	}
+	numGroups := len(l.firstPage) + len(l.shared)
+	objectsBits := bits.Len64(maxObjects - minObjects)
+	lengthBits := bits.Len64(maxLength - minLength)
+	sharedCountBits := bits.Len(uint(maxShared))
+	sharedIDBits := bits.Len(uint(numGroups - 1))
+
+	// Page offset hint table (ISO 32000-1 Tables F.3 and F.4). As in common practice, the
+	// content stream of a page is considered to span the whole page section.
+	hw := &hintWriter{}
+	hw.write(minObjects, 32)
+	hw.write(uint64(firstPageOffset), 32)
+	hw.write(uint64(objectsBits), 16)
+	hw.write(minLength, 32)
+	hw.write(uint64(lengthBits), 16)
+	hw.write(0, 32)
+	hw.write(0, 16)
+	hw.write(minLength, 32)
+	hw.write(uint64(lengthBits), 16)
+	hw.write(uint64(sharedCountBits), 16)
+	hw.write(uint64(sharedIDBits), 16)
+	hw.write(0, 16)
+	hw.write(1, 16)
+	for _, n := range numObjects {
+		hw.write(n-minObjects, objectsBits)
+	}
+	hw.align()
+	for _, n := range lengths {
+		hw.write(n-minLength, lengthBits)
+	}
+	hw.align()
+	for _, s := range shared {
+		hw.write(uint64(len(s)), sharedCountBits)
+	}
+	hw.align()
+	for _, s := range shared {
+		for _, id := range s {
+			hw.write(uint64(id), sharedIDBits)
+		}
+	}
+	hw.align()
+	for _, n := range lengths {
+		hw.write(n-minLength, lengthBits)
+	}
+	hw.align()
+	sharedTable := int64(len(hw.data))
+
+	// Shared object hint table (Tables F.5 and F.6). Each shared object is a group.
+	groups := make([]uint64, 0, numGroups)
+	for _, obj := range l.firstPage {
+		groups = append(groups, uint64(len(rendered[obj])))
+	}
+	var firstShared int64
+	if len(l.shared) > 0 {
+		firstShared = objectNumber(l.shared[0])
+	}
+	for _, obj := range l.shared {
+		groups = append(groups, uint64(len(rendered[obj])))
+	}
+	minGroup, maxGroup := minMax(groups)
+	groupBits := bits.Len64(maxGroup - minGroup)
+	hw.write(uint64(firstShared), 32)
+	hw.write(uint64(sharedOffset), 32)
+	hw.write(uint64(len(l.firstPage)), 32)
+	hw.write(uint64(numGroups), 32)
+	hw.write(0, 16)
+	hw.write(minGroup, 32)
+	hw.write(uint64(groupBits), 16)
+	for _, n := range groups {
+		hw.write(n-minGroup, groupBits)
+	}
+	hw.align()
+	for range groups {
+		hw.write(0, 1)
+	}
+	hw.align()
+	return hw.data, sharedTable
+}
+
+// hintWriter writes the bit fields of hint tables.
+type hintWriter struct {
+	data  []byte
+	nbits uint // Number of bits used in the last byte, 0 if aligned.
+}
+
+// write writes the `n` least significant bits of `v`, most significant bit first.
+func (hw *hintWriter) write(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if hw.nbits == 0 {
+			hw.data = append(hw.data, 0)
+		}
+		if v>>uint(i)&1 == 1 {
+			hw.data[len(hw.data)-1] |= 1 << (7 - hw.nbits)
+		}
+		hw.nbits = (hw.nbits + 1) % 8
+	}
+}
+
+// align pads to the next byte boundary.
+func (hw *hintWriter) align() {
+	hw.nbits = 0
+}
+
+// objectNumber returns the object number of an indirect or stream object, 0 otherwise.
+func objectNumber(obj core.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		return t.ObjectNumber
+	case *core.PdfObjectStream:
+		return t.ObjectNumber
+	}
+	return 0
+}
@@ -0,0 +1,142 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/core/security"
+)
+
+// writeLinearizedTestFile writes a 3 page document. The first font is used by all pages, the
+// second one by pages 2 and 3 only.
+func writeLinearizedTestFile(t *testing.T, linearized bool, encrypt bool) []byte {
+	helvetica, err := NewStandard14Font(HelveticaName)
+	require.NoError(t, err)
+	courier, err := NewStandard14Font(CourierName)
+	require.NoError(t, err)
+	font1, font2 := helvetica.ToPdfObject(), courier.ToPdfObject()
+
+	w := NewPdfWriter()
+	for i := 1; i <= 3; i++ {
+		page := NewPdfPage()
+		require.NoError(t, page.AddFont("F1", font1))
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (Page %d) Tj ET", i)
+		if i > 1 {
+			require.NoError(t, page.AddFont("F2", font2))
+			content += " BT /F2 12 Tf 10 30 Td (Courier) Tj ET"
+		}
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewFlateEncoder()))
+		require.NoError(t, w.AddPage(page))
+	}
+	if encrypt {
+		require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: AES_128bit, Permissions: security.PermOwner}))
+	}
+	w.SetLinearized(linearized)
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+// checkLinearization checks the linearization parameters and hint tables of `data`.
+func checkLinearization(t *testing.T, data []byte, reader *PdfReader) {
+	lin := reader.GetLinearization()
+	require.NotNil(t, lin)
+	require.Equal(t, int64(len(data)), lin.Length)
+	require.Equal(t, 3, lin.NumPages)
+	require.Equal(t, reader.PageList[0].GetPageAsIndirectObject().ObjectNumber, lin.FirstPageObject)
+	require.Regexp(t, `^\d+ 0 obj`, string(data[lin.HintOffset:lin.HintOffset+20]))
+	require.Equal(t, "endobj\n", string(data[lin.HintOffset+lin.HintLength-7:lin.HintOffset+lin.HintLength]))
+	require.Equal(t, "0000000000 65535 f", string(data[lin.MainXrefOffset+1:lin.MainXrefOffset+19]))
+
+	hints, err := reader.GetLinearizationHints()
+	require.NoError(t, err)
+	require.Len(t, hints.Pages, 3)
+	for i, page := range hints.Pages {
+		num := reader.PageList[i].GetPageAsIndirectObject().ObjectNumber
+		require.True(t, bytes.HasPrefix(data[page.Offset:], []byte(fmt.Sprintf("%d 0 obj", num))), "page %d", i+1)
+	}
+	require.Equal(t, lin.FirstPageEnd, hints.Pages[0].Offset+hints.Pages[0].Length)
+	require.Equal(t, hints.Pages[1].Offset, lin.FirstPageEnd)
+	require.Empty(t, hints.Pages[0].SharedGroups)
+
+	// Pages 2 and 3 share the first page font and the second font.
+	require.Len(t, hints.Pages[1].SharedGroups, 2)
+	require.Equal(t, hints.Pages[1].SharedGroups, hints.Pages[2].SharedGroups)
+	for _, g := range hints.SharedGroups {
+		require.Regexp(t, `^\d+ 0 obj`, string(data[g.Offset:g.Offset+20]))
+	}
+}
+
+func TestLinearizedOutput(t *testing.T) {
+	data := writeLinearizedTestFile(t, true, false)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 3, numPages)
+	for i, page := range reader.PageList {
+		content, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, content, fmt.Sprintf("(Page %d)", i+1))
+	}
+	checkLinearization(t, data, reader)
+
+	// The first page can be loaded from the first page section only.
+	lin := reader.GetLinearization()
+	firstPage, err := NewPdfReaderFirstPage(bytes.NewReader(data[:lin.FirstPageEnd]))
+	require.NoError(t, err)
+	require.Len(t, firstPage.PageList, 1)
+	require.Equal(t, 3, firstPage.GetLinearization().NumPages)
+	content, err := firstPage.PageList[0].GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, content, "(Page 1)")
+	font, found := firstPage.PageList[0].Resources.GetFontByName("F1")
+	require.True(t, found)
+	_, err = NewPdfFontFromPdfObject(font)
+	require.NoError(t, err)
+}
+
+func TestLinearizedOutputEncrypted(t *testing.T) {
+	data := writeLinearizedTestFile(t, true, true)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	ok, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	content, err := reader.PageList[2].GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, content, "(Page 3)")
+	checkLinearization(t, data, reader)
+}
+
+func TestNotLinearized(t *testing.T) {
+	data := writeLinearizedTestFile(t, false, false)
+
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Nil(t, reader.GetLinearization())
+	_, err = reader.GetLinearizationHints()
+	require.Equal(t, core.ErrNotLinearized, err)
+
+	_, err = NewPdfReaderFirstPage(bytes.NewReader(data))
+	require.Equal(t, core.ErrNotLinearized, err)
+
+	// Appending to a linearized file invalidates the linearization.
+	data = writeLinearizedTestFile(t, true, false)
+	data = append(data, []byte("\n% comment\n")...)
+	reader, err = NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Nil(t, reader.GetLinearization())
+}
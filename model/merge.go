@@ -0,0 +1,907 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// MergeInput specifies a document merged by MergeDocuments.
+type MergeInput struct {
+	// Reader is the reader of the document.
+	Reader *PdfReader
+
+	// Pages are the numbers (starting from 1) of the pages to merge, in order. All the pages of
+	// the document are merged if empty.
+	Pages []int
+
+	// OutlineTitle, if not empty, is the title of an outline item pointing to the first merged
+	// page of the document, under which the outline items of the document are placed.
+	OutlineTitle string
+}
+
+// MergeDocuments returns a writer containing the selected pages of the `inputs` documents, in
+// order. Unlike adding the pages of the documents to a writer with AddPage, the document-level
+// structures of the documents are merged:
+//   - the outlines are combined,
+//   - the named destinations are combined, clashing names being renamed,
+//   - the interactive form fields are combined, clashing top-level field names being renamed,
+//   - the page labels are combined (if any document has page labels),
+//   - the logical structure trees are combined (if any document has a structure tree),
+//   - identical fonts and images are stored once.
+//
+// Outline items, destinations and form field widgets pointing to pages which are not merged are
+// removed. XFA forms are not merged.
+func MergeDocuments(inputs ...MergeInput) (*PdfWriter, error) {
+	w := NewPdfWriter()
+	m := &merger{
+		w:          &w,
+		destNames:  map[string]struct{}{},
+		fieldNames: map[string]struct{}{},
+	}
+	for i, in := range inputs {
+		if err := m.merge(in); err != nil {
+			return nil, fmt.Errorf("document %d: %v", i+1, err)
+		}
+	}
+	if err := m.finish(); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// merger merges documents into a writer.
+type merger struct {
+	w        *PdfWriter
+	pageObjs []*core.PdfIndirectObject
+
+	// Named destinations.
+	destNames map[string]struct{}
+	dests     []nameTreeEntry
+
+	// Top-level outline items.
+	outlines []*core.PdfIndirectObject
+
+	// Form.
+	acroForm   *core.PdfObjectDictionary
+	fields     *core.PdfObjectArray
+	fieldNames map[string]struct{}
+
+	// Page labels.
+	hasLabels bool
+	labels    []numberTreeEntry
+
+	// Structure tree.
+	structRoot    *core.PdfIndirectObject
+	structKids    *core.PdfObjectArray
+	parentTree    []numberTreeEntry
+	nextStructKey int64
+}
+
+// merge merges the document specified by `in`.
+func (m *merger) merge(in MergeInput) error {
+	r := in.Reader
+	if r == nil {
+		return errors.New("nil reader")
+	}
+	pageNums := in.Pages
+	if len(pageNums) == 0 {
+		for i := range r.PageList {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	c := &mergeCopier{
+		pages:       map[core.PdfObject]*core.PdfIndirectObject{},
+		copies:      map[core.PdfObject]core.PdfObject{},
+		destRenames: map[string]string{},
+	}
+
+	// The pages are registered first so that the references to the merged pages are mapped to
+	// the new pages, and the references to the other pages are removed.
+	type mergedPage struct {
+		orig   *core.PdfIndirectObject
+		obj    *core.PdfIndirectObject
+		number int
+	}
+	var pages []mergedPage
+	for _, num := range pageNums {
+		if num < 1 || num > len(r.PageList) {
+			return fmt.Errorf("page %d out of range (%d pages)", num, len(r.PageList))
+		}
+		orig, ok := core.GetIndirect(r.PageList[num-1].ToPdfObject())
+		if !ok {
+			return errors.New("page should be an indirect object")
+		}
+		obj := &core.PdfIndirectObject{}
+		if _, ok := c.pages[orig]; !ok {
+			c.pages[orig] = obj
+		}
+		pages = append(pages, mergedPage{orig: orig, obj: obj, number: num})
+	}
+
+	catalog := r.catalog
+	if err := m.renameDests(c, catalog); err != nil {
+		return err
+	}
+	structRoot := core.ResolveReference(catalog.Get("StructTreeRoot"))
+	if _, ok := core.GetDict(structRoot); ok {
+		c.hasStruct = true
+		c.structOffset = m.nextStructKey
+		m.initStructTree()
+		c.copies[structRoot] = m.structRoot
+	}
+
+	// Pages.
+	annots := map[core.PdfObject]struct{}{}
+	for _, page := range pages {
+		dict, ok := core.GetDict(page.orig.PdfObject)
+		if !ok {
+			return errors.New("page object should be a dictionary")
+		}
+		page.obj.PdfObject = c.copyPage(dict)
+		pDict := page.obj.PdfObject.(*core.PdfObjectDictionary)
+		if arr, ok := core.GetArray(pDict.Get("Annots")); ok {
+			for _, annot := range arr.Elements() {
+				annots[annot] = struct{}{}
+			}
+		}
+		if err := m.w.addPageObject(page.obj, pDict); err != nil {
+			return err
+		}
+		m.pageObjs = append(m.pageObjs, page.obj)
+	}
+	var firstPage core.PdfObject = core.MakeNull()
+	if len(pages) > 0 {
+		firstPage = pages[0].obj
+	}
+
+	if err := m.mergeDests(c, catalog); err != nil {
+		return err
+	}
+	m.mergeOutlines(c, catalog, in.OutlineTitle, firstPage)
+	m.mergeForm(c, catalog, annots)
+
+	var pageIndices []int
+	for _, page := range pages {
+		pageIndices = append(pageIndices, page.number-1)
+	}
+	if err := m.mergePageLabels(r, pageIndices); err != nil {
+		return err
+	}
+	if c.hasStruct {
+		return m.mergeStructTree(c, catalog, structRoot)
+	}
+	return nil
+}
+
+// renameDests registers the names of the named destinations of the document with catalog
+// `catalog`, renaming the names used by the documents merged before.
+func (m *merger) renameDests(c *mergeCopier, catalog *core.PdfObjectDictionary) error {
+	entries, err := loadDests(catalog)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, ok := c.destRenames[e.Key]; ok {
+			continue
+		}
+		c.destRenames[e.Key] = uniqueName(e.Key, m.destNames)
+	}
+	return nil
+}
+
+// mergeDests adds the named destinations of the document with catalog `catalog` which point to
+// merged pages.
+func (m *merger) mergeDests(c *mergeCopier, catalog *core.PdfObjectDictionary) error {
+	entries, err := loadDests(catalog)
+	if err != nil {
+		return err
+	}
+	added := map[string]struct{}{}
+	for _, e := range entries {
+		name := c.destRenames[e.Key]
+		if _, ok := added[name]; ok {
+			continue
+		}
+		dest := c.copy(e.Value)
+		if !isMergedDest(dest) {
+			continue
+		}
+		added[name] = struct{}{}
+		m.dests = append(m.dests, nameTreeEntry{Key: name, Value: dest})
+	}
+	return nil
+}
+
+// loadDests returns the named destinations of the Dests dictionary and of the Dests name tree of
+// the document with catalog `catalog`.
+func loadDests(catalog *core.PdfObjectDictionary) ([]nameTreeEntry, error) {
+	var entries []nameTreeEntry
+	if dests, ok := core.GetDict(catalog.Get("Dests")); ok {
+		for _, key := range dests.Keys() {
+			entries = append(entries, nameTreeEntry{Key: string(key), Value: dests.Get(key)})
+		}
+	}
+	if names, ok := core.GetDict(catalog.Get("Names")); ok && names.Get("Dests") != nil {
+		tree, err := loadNameTree(names.Get("Dests"))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, tree...)
+	}
+	return entries, nil
+}
+
+// isMergedDest returns true if destination `dest` (a destination array or a dictionary with a D
+// entry) points to a merged page.
+func isMergedDest(dest core.PdfObject) bool {
+	if dict, ok := core.GetDict(dest); ok {
+		dest = dict.Get("D")
+	}
+	arr, ok := core.GetArray(dest)
+	if !ok || arr.Len() == 0 {
+		return false
+	}
+	return !core.IsNullObject(arr.Get(0))
+}
+
+// mergeOutlines adds the outline items of the document with catalog `catalog`, under an item with
+// title `title` pointing to `firstPage` if `title` is not empty.
+func (m *merger) mergeOutlines(c *mergeCopier, catalog *core.PdfObjectDictionary, title string, firstPage core.PdfObject) {
+	var items []*core.PdfIndirectObject
+	root := core.ResolveReference(catalog.Get("Outlines"))
+	if outlines, ok := core.GetDict(root); ok && outlines.Get("First") != nil {
+		// The items are attached to the new outline root.
+		c.copies[root] = core.MakeNull()
+		first := c.copy(outlines.Get("First"))
+		items = outlineSiblings(first)
+		visited := map[core.PdfObject]struct{}{}
+		for _, item := range items {
+			pruneOutlineDests(item, visited)
+		}
+	}
+
+	if title == "" {
+		m.outlines = append(m.outlines, items...)
+		return
+	}
+	dict := core.MakeDict()
+	dict.Set("Title", makeTextString(title))
+	dict.Set("Dest", core.MakeArray(firstPage, core.MakeName("Fit")))
+	item := core.MakeIndirectObject(dict)
+	linkOutlineItems(item, items)
+	m.outlines = append(m.outlines, item)
+}
+
+// outlineSiblings returns the outline item `first` followed by its next siblings.
+func outlineSiblings(first core.PdfObject) []*core.PdfIndirectObject {
+	var items []*core.PdfIndirectObject
+	visited := map[core.PdfObject]struct{}{}
+	for obj := first; obj != nil; {
+		item, ok := core.GetIndirect(obj)
+		if !ok {
+			break
+		}
+		if _, ok := visited[item]; ok {
+			common.Log.Debug("ERROR: Outline item loop detected")
+			break
+		}
+		visited[item] = struct{}{}
+		dict, ok := core.GetDict(item.PdfObject)
+		if !ok {
+			break
+		}
+		items = append(items, item)
+		obj = dict.Get("Next")
+	}
+	return items
+}
+
+// pruneOutlineDests removes the destinations and GoTo actions of outline item `item` and its
+// descendants which point to pages that are not merged.
+func pruneOutlineDests(item *core.PdfIndirectObject, visited map[core.PdfObject]struct{}) {
+	if _, ok := visited[item]; ok {
+		return
+	}
+	visited[item] = struct{}{}
+	dict, ok := core.GetDict(item.PdfObject)
+	if !ok {
+		return
+	}
+	if dest := dict.Get("Dest"); dest != nil {
+		if _, ok := core.GetArray(dest); ok && !isMergedDest(dest) {
+			dict.Remove("Dest")
+		}
+	}
+	if action, ok := core.GetDict(dict.Get("A")); ok {
+		if s, _ := core.GetNameVal(action.Get("S")); s == "GoTo" {
+			if _, ok := core.GetArray(action.Get("D")); ok && !isMergedDest(action.Get("D")) {
+				dict.Remove("A")
+			}
+		}
+	}
+	for _, child := range outlineSiblings(dict.Get("First")) {
+		pruneOutlineDests(child, visited)
+	}
+}
+
+// linkOutlineItems sets the outline items `items` as the children of the outline item or root
+// `parent`. The parent is open: its count is the number of visible descendants.
+func linkOutlineItems(parent *core.PdfIndirectObject, items []*core.PdfIndirectObject) {
+	dict := parent.PdfObject.(*core.PdfObjectDictionary)
+	if len(items) == 0 {
+		return
+	}
+	var count int64
+	for i, item := range items {
+		itemDict := item.PdfObject.(*core.PdfObjectDictionary)
+		itemDict.Set("Parent", parent)
+		itemDict.Remove("Prev")
+		itemDict.Remove("Next")
+		if i > 0 {
+			itemDict.Set("Prev", items[i-1])
+		}
+		if i < len(items)-1 {
+			itemDict.Set("Next", items[i+1])
+		}
+		count++
+		if n, ok := core.GetIntVal(itemDict.Get("Count")); ok && n > 0 {
+			count += int64(n)
+		}
+	}
+	dict.Set("First", items[0])
+	dict.Set("Last", items[len(items)-1])
+	dict.Set("Count", core.MakeInteger(count))
+}
+
+// mergeForm adds the form fields of the document with catalog `catalog` which have widgets in the
+// merged annotations `annots`.
+func (m *merger) mergeForm(c *mergeCopier, catalog *core.PdfObjectDictionary, annots map[core.PdfObject]struct{}) {
+	form, ok := core.GetDict(catalog.Get("AcroForm"))
+	if !ok {
+		return
+	}
+	if form.Get("XFA") != nil {
+		common.Log.Debug("WARN: XFA form not merged")
+	}
+	if m.acroForm == nil {
+		m.acroForm = core.MakeDict()
+		m.fields = core.MakeArray()
+		m.acroForm.Set("Fields", m.fields)
+	}
+
+	if fields, ok := core.GetArray(form.Get("Fields")); ok {
+		for _, field := range fields.Elements() {
+			copied := c.copy(field)
+			if !pruneFieldWidgets(copied, annots) {
+				continue
+			}
+			dict, ok := core.GetDict(copied)
+			if !ok {
+				continue
+			}
+			if name, ok := core.GetString(dict.Get("T")); ok {
+				if unique := uniqueName(name.Decoded(), m.fieldNames); unique != name.Decoded() {
+					dict.Set("T", makeTextString(unique))
+				}
+			}
+			m.fields.Append(copied)
+		}
+	}
+
+	for _, key := range []core.PdfObjectName{"NeedAppearances", "SigFlags"} {
+		switch t := core.TraceToDirectObject(form.Get(key)).(type) {
+		case *core.PdfObjectBool:
+			if bool(*t) {
+				m.acroForm.Set(key, core.MakeBool(true))
+			}
+		case *core.PdfObjectInteger:
+			flags, _ := core.GetIntVal(m.acroForm.Get(key))
+			m.acroForm.Set(key, core.MakeInteger(int64(flags)|int64(*t)))
+		}
+	}
+	for _, key := range []core.PdfObjectName{"DA", "Q"} {
+		if m.acroForm.Get(key) == nil && form.Get(key) != nil {
+			m.acroForm.Set(key, c.copy(form.Get(key)))
+		}
+	}
+
+	// The resources are merged by category, the resources of the first documents taking
+	// precedence.
+	dr, ok := core.GetDict(c.copy(form.Get("DR")))
+	if !ok {
+		return
+	}
+	mergedDR, ok := core.GetDict(m.acroForm.Get("DR"))
+	if !ok {
+		m.acroForm.Set("DR", dr)
+		return
+	}
+	for _, category := range dr.Keys() {
+		src, ok := core.GetDict(dr.Get(category))
+		dst, ok2 := core.GetDict(mergedDR.Get(category))
+		if !ok || !ok2 {
+			if mergedDR.Get(category) == nil {
+				mergedDR.Set(category, dr.Get(category))
+			}
+			continue
+		}
+		for _, name := range src.Keys() {
+			if dst.Get(name) == nil {
+				dst.Set(name, src.Get(name))
+			}
+		}
+	}
+}
+
+// pruneFieldWidgets removes the widgets of the field `field` and its descendants that are not in
+// the merged annotations `annots`. Returns false if the field had widgets and none are left.
+func pruneFieldWidgets(field core.PdfObject, annots map[core.PdfObject]struct{}) bool {
+	dict, ok := core.GetDict(field)
+	if !ok {
+		return false
+	}
+	kids, ok := core.GetArray(dict.Get("Kids"))
+	if !ok || kids.Len() == 0 {
+		if subtype, _ := core.GetNameVal(dict.Get("Subtype")); subtype != "Widget" {
+			// Field without widget.
+			return true
+		}
+		_, ok := annots[field]
+		return ok
+	}
+	kept := core.MakeArray()
+	for _, kid := range kids.Elements() {
+		if pruneFieldWidgets(kid, annots) {
+			kept.Append(kid)
+		}
+	}
+	dict.Set("Kids", kept)
+	return kept.Len() > 0
+}
+
+// mergePageLabels adds the page labels of the pages of reader `r` with indices `pageIndices` to
+// the page labels of the merged document. The pages of documents without page labels are
+// labelled with their original page numbers.
+func (m *merger) mergePageLabels(r *PdfReader, pageIndices []int) error {
+	var ranges []numberTreeEntry
+	labels, err := r.GetPageLabels()
+	if err != nil {
+		return err
+	}
+	if labels != nil {
+		if ranges, err = loadNumberTree(labels); err != nil {
+			return err
+		}
+		m.hasLabels = true
+	}
+	if len(ranges) == 0 || ranges[0].Key != 0 {
+		style := core.MakeDict()
+		style.Set("S", core.MakeName("D"))
+		ranges = append([]numberTreeEntry{{Key: 0, Value: style}}, ranges...)
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Key < ranges[j].Key
+	})
+
+	base := int64(len(m.pageObjs) - len(pageIndices))
+	prevRange, prevIndex := -1, -1
+	for i, index := range pageIndices {
+		rangeIdx := sort.Search(len(ranges), func(j int) bool {
+			return ranges[j].Key > int64(index)
+		}) - 1
+		if rangeIdx == prevRange && index == prevIndex+1 {
+			prevIndex = index
+			continue
+		}
+		prevRange, prevIndex = rangeIdx, index
+
+		// A new label range starts at the page.
+		style := core.MakeDict()
+		start := int64(1)
+		if src, ok := core.GetDict(ranges[rangeIdx].Value); ok {
+			for _, key := range src.Keys() {
+				style.Set(key, core.TraceToDirectObject(src.Get(key)))
+			}
+			if st, ok := core.GetIntVal(src.Get("St")); ok {
+				start = int64(st)
+			}
+		}
+		start += int64(index) - ranges[rangeIdx].Key
+		style.Remove("St")
+		if start != 1 {
+			style.Set("St", core.MakeInteger(start))
+		}
+		m.labels = append(m.labels, numberTreeEntry{Key: base + int64(i), Value: style})
+	}
+	return nil
+}
+
+// initStructTree creates the structure tree root of the merged document.
+func (m *merger) initStructTree() {
+	if m.structRoot != nil {
+		return
+	}
+	m.structKids = core.MakeArray()
+	dict := core.MakeDict()
+	dict.Set("Type", core.MakeName("StructTreeRoot"))
+	dict.Set("K", m.structKids)
+	m.structRoot = core.MakeIndirectObject(dict)
+}
+
+// mergeStructTree adds the structure tree `structRoot` of the document with catalog `catalog` to
+// the structure tree of the merged document. The StructParents and StructParent keys of the
+// document have been offset by the copier.
+func (m *merger) mergeStructTree(c *mergeCopier, catalog *core.PdfObjectDictionary, structRoot core.PdfObject) error {
+	root, _ := core.GetDict(structRoot)
+	switch k := core.TraceToDirectObject(c.copy(root.Get("K"))).(type) {
+	case *core.PdfObjectArray:
+		m.structKids.Append(k.Elements()...)
+	case nil:
+	default:
+		m.structKids.Append(c.copy(root.Get("K")))
+	}
+
+	next := c.structOffset
+	if root.Get("ParentTree") != nil {
+		entries, err := loadNumberTree(root.Get("ParentTree"))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			key := e.Key + c.structOffset
+			m.parentTree = append(m.parentTree, numberTreeEntry{Key: key, Value: c.copy(e.Value)})
+			if key >= next {
+				next = key + 1
+			}
+		}
+	}
+	if n, ok := core.GetIntVal(root.Get("ParentTreeNextKey")); ok && int64(n)+c.structOffset > next {
+		next = int64(n) + c.structOffset
+	}
+	m.nextStructKey = next
+
+	// Role and class maps, the entries of the first documents taking precedence.
+	merged := m.structRoot.PdfObject.(*core.PdfObjectDictionary)
+	for _, key := range []core.PdfObjectName{"RoleMap", "ClassMap"} {
+		src, ok := core.GetDict(c.copy(root.Get(key)))
+		if !ok {
+			continue
+		}
+		dst, ok := core.GetDict(merged.Get(key))
+		if !ok {
+			merged.Set(key, src)
+			continue
+		}
+		for _, name := range src.Keys() {
+			if dst.Get(name) == nil {
+				dst.Set(name, src.Get(name))
+			}
+		}
+	}
+
+	if markInfo, ok := core.GetDict(catalog.Get("MarkInfo")); ok {
+		if marked, ok := core.GetBoolVal(markInfo.Get("Marked")); ok && marked {
+			info := core.MakeDict()
+			info.Set("Marked", core.MakeBool(true))
+			m.w.catalog.Set("MarkInfo", info)
+		}
+	}
+	if m.w.catalog.Get("Lang") == nil && catalog.Get("Lang") != nil {
+		m.w.catalog.Set("Lang", core.TraceToDirectObject(catalog.Get("Lang")))
+	}
+	return nil
+}
+
+// finish deduplicates the resources of the merged pages and sets the merged document-level
+// structures in the catalog.
+func (m *merger) finish() error {
+	m.dedupeResources()
+
+	if len(m.outlines) > 0 {
+		dict := core.MakeDict()
+		dict.Set("Type", core.MakeName("Outlines"))
+		root := core.MakeIndirectObject(dict)
+		linkOutlineItems(root, m.outlines)
+		m.w.catalog.Set("Outlines", root)
+		if err := m.w.addObjects(root); err != nil {
+			return err
+		}
+	}
+	if len(m.dests) > 0 {
+		names := core.MakeDict()
+		names.Set("Dests", makeNameTree(m.dests))
+		if err := m.w.SetNamedDestinations(names); err != nil {
+			return err
+		}
+	}
+	if m.acroForm != nil {
+		form := core.MakeIndirectObject(m.acroForm)
+		m.w.catalog.Set("AcroForm", form)
+		if err := m.w.addObjects(form); err != nil {
+			return err
+		}
+	}
+	if m.hasLabels {
+		if err := m.w.SetPageLabels(makeNumberTree(m.labels)); err != nil {
+			return err
+		}
+	}
+	if m.structRoot != nil {
+		dict := m.structRoot.PdfObject.(*core.PdfObjectDictionary)
+		dict.Set("ParentTree", core.MakeIndirectObject(makeNumberTree(m.parentTree)))
+		dict.Set("ParentTreeNextKey", core.MakeInteger(m.nextStructKey))
+		m.w.catalog.Set("StructTreeRoot", m.structRoot)
+		if err := m.w.addObjects(m.structRoot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dedupeResources replaces the fonts and images of the resources of the merged pages (and of the
+// form XObjects they use) by the first identical font or image.
+func (m *merger) dedupeResources() {
+	keys := &mergeKeys{
+		memo:   map[core.PdfObject]string{},
+		active: map[core.PdfObject]struct{}{},
+	}
+	canonical := map[string]core.PdfObject{}
+	visited := map[*core.PdfObjectDictionary]struct{}{}
+
+	var visit func(obj core.PdfObject)
+	visit = func(obj core.PdfObject) {
+		resources, ok := core.GetDict(obj)
+		if !ok {
+			return
+		}
+		if _, ok := visited[resources]; ok {
+			return
+		}
+		visited[resources] = struct{}{}
+
+		for _, category := range []core.PdfObjectName{"Font", "XObject"} {
+			dict, ok := core.GetDict(resources.Get(category))
+			if !ok {
+				continue
+			}
+			for _, name := range dict.Keys() {
+				obj := dict.Get(name)
+				if category == "XObject" {
+					stream, ok := core.GetStream(obj)
+					if !ok {
+						continue
+					}
+					if subtype, _ := core.GetNameVal(stream.Get("Subtype")); subtype == "Form" {
+						visit(stream.Get("Resources"))
+						continue
+					} else if subtype != "Image" {
+						continue
+					}
+				}
+				key, ok := keys.key(obj)
+				if !ok {
+					continue
+				}
+				if first, ok := canonical[key]; ok {
+					dict.Set(name, first)
+				} else {
+					canonical[key] = obj
+				}
+			}
+		}
+	}
+	for _, pageObj := range m.pageObjs {
+		if dict, ok := core.GetDict(pageObj.PdfObject); ok {
+			visit(dict.Get("Resources"))
+		}
+	}
+}
+
+// mergeKeys computes keys identifying the content of objects: objects with identical content
+// have the same key.
+type mergeKeys struct {
+	memo   map[core.PdfObject]string
+	active map[core.PdfObject]struct{}
+}
+
+// key returns the key of `obj`, false if it cannot be computed (the object contains a loop).
+func (k *mergeKeys) key(obj core.PdfObject) (string, bool) {
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject, *core.PdfObjectStream:
+		if key, ok := k.memo[obj]; ok {
+			return key, key != ""
+		}
+		if _, ok := k.active[obj]; ok {
+			return "", false
+		}
+		k.active[obj] = struct{}{}
+		var key string
+		var ok bool
+		if ind, isInd := t.(*core.PdfIndirectObject); isInd {
+			key, ok = k.key(ind.PdfObject)
+			key = "R(" + key + ")"
+		} else {
+			stream := t.(*core.PdfObjectStream)
+			key, ok = k.key(stream.PdfObjectDictionary)
+			sum := sha256.Sum256(stream.Stream)
+			key = "S(" + key + hex.EncodeToString(sum[:]) + ")"
+		}
+		delete(k.active, obj)
+		if !ok {
+			key = ""
+		}
+		k.memo[obj] = key
+		return key, ok
+	case *core.PdfObjectDictionary:
+		var b strings.Builder
+		b.WriteString("<<")
+		names := t.Keys()
+		sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+		for _, name := range names {
+			val, ok := k.key(t.Get(name))
+			if !ok {
+				return "", false
+			}
+			b.WriteString(core.MakeName(string(name)).WriteString())
+			b.WriteString(" ")
+			b.WriteString(val)
+		}
+		b.WriteString(">>")
+		return b.String(), true
+	case *core.PdfObjectArray:
+		var b strings.Builder
+		b.WriteString("[")
+		for _, elem := range t.Elements() {
+			val, ok := k.key(elem)
+			if !ok {
+				return "", false
+			}
+			b.WriteString(val)
+			b.WriteString(" ")
+		}
+		b.WriteString("]")
+		return b.String(), true
+	case nil:
+		return "null", true
+	}
+	return obj.WriteString(), true
+}
+
+// mergeCopier copies the objects of a merged document. The references to the pages of the
+// document are replaced by references to the merged pages, or by null if not merged.
+type mergeCopier struct {
+	// pages maps the merged pages of the document to the new pages.
+	pages map[core.PdfObject]*core.PdfIndirectObject
+	// copies maps the copied indirect objects and streams to their copies.
+	copies map[core.PdfObject]core.PdfObject
+	// destRenames maps the names of the named destinations of the document to their new names.
+	destRenames map[string]string
+
+	// hasStruct is true if the document has a structure tree, whose StructParents and
+	// StructParent keys are offset by structOffset.
+	hasStruct    bool
+	structOffset int64
+}
+
+// copyPage copies page dictionary `dict`, including the inherited attributes.
+func (c *mergeCopier) copyPage(dict *core.PdfObjectDictionary) *core.PdfObjectDictionary {
+	page := core.MakeDict()
+	page.Merge(dict)
+	inheritPageAttributes(page)
+	page.Remove("Parent")
+	return c.copyDict(page)
+}
+
+// copy returns a copy of `obj`. Indirect objects and streams are copied once.
+func (c *mergeCopier) copy(obj core.PdfObject) core.PdfObject {
+	obj = core.ResolveReference(obj)
+	if copied, ok := c.copies[obj]; ok {
+		return copied
+	}
+
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		if dict, ok := t.PdfObject.(*core.PdfObjectDictionary); ok {
+			if typ, _ := core.GetNameVal(dict.Get("Type")); typ == "Page" {
+				if page, ok := c.pages[t]; ok {
+					return page
+				}
+				return core.MakeNull()
+			}
+		}
+		ind := &core.PdfIndirectObject{}
+		c.copies[t] = ind
+		ind.PdfObject = c.copy(t.PdfObject)
+		if ind.PdfObject == nil {
+			ind.PdfObject = core.MakeNull()
+		}
+		return ind
+	case *core.PdfObjectStream:
+		stream := &core.PdfObjectStream{Stream: t.Stream}
+		c.copies[t] = stream
+		stream.PdfObjectDictionary = c.copyDict(t.PdfObjectDictionary)
+		return stream
+	case *core.PdfObjectDictionary:
+		return c.copyDict(t)
+	case *core.PdfObjectArray:
+		arr := core.MakeArray()
+		for _, elem := range t.Elements() {
+			copied := c.copy(elem)
+			if copied == nil {
+				copied = core.MakeNull()
+			}
+			arr.Append(copied)
+		}
+		return arr
+	}
+	return obj
+}
+
+// copyDict returns a copy of `dict`, renaming the named destinations and offsetting the structure
+// tree keys.
+func (c *mergeCopier) copyDict(dict *core.PdfObjectDictionary) *core.PdfObjectDictionary {
+	copied := core.MakeDict()
+	s, _ := core.GetNameVal(dict.Get("S"))
+	for _, key := range dict.Keys() {
+		val := dict.Get(key)
+		switch {
+		case key == "StructParents" || key == "StructParent":
+			n, ok := core.GetIntVal(val)
+			if !ok || !c.hasStruct {
+				continue
+			}
+			val = core.MakeInteger(int64(n) + c.structOffset)
+		case key == "Dest" || key == "D" && s == "GoTo":
+			val = c.renameDest(val)
+		}
+		if val = c.copy(val); val != nil {
+			copied.Set(key, val)
+		}
+	}
+	return copied
+}
+
+// renameDest returns the new name of destination `dest` if it is a named destination. The names
+// are converted to strings as the named destinations are merged in the Dests name tree.
+func (c *mergeCopier) renameDest(dest core.PdfObject) core.PdfObject {
+	var name string
+	switch t := core.TraceToDirectObject(dest).(type) {
+	case *core.PdfObjectName:
+		name = string(*t)
+	case *core.PdfObjectString:
+		name = t.Decoded()
+	default:
+		return dest
+	}
+	if renamed, ok := c.destRenames[name]; ok {
+		name = renamed
+	}
+	return makeTextString(name)
+}
+
+// uniqueName returns `name`, or `name` with a numeric suffix if it is in `used`. The returned
+// name is added to `used`.
+func uniqueName(name string, used map[string]struct{}) string {
+	unique := name
+	for i := 1; ; i++ {
+		if _, ok := used[unique]; !ok {
+			break
+		}
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[unique] = struct{}{}
+	return unique
+}
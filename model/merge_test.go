@@ -0,0 +1,161 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+// newMergeTestReader returns a reader of a document of 3 pages with an outline item, a named
+// destination, two form fields, page labels and a structure tree.
+func newMergeTestReader(t *testing.T) *PdfReader {
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 10 0 R /Names << /Dests << /Names [(intro) [3 0 R /Fit]] >> >> " +
+			"/AcroForm << /Fields [12 0 R 13 0 R] /DR << /Font << /Helv 9 0 R >> >> /DA (/Helv 0 Tf 0 g) >> " +
+			"/PageLabels << /Nums [0 << /S /r >> 2 << /S /D >>] >> /StructTreeRoot 14 0 R /MarkInfo << /Marked true >> >>",
+		"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R] /Count 3 /MediaBox [0 0 200 200] /Resources << /Font << /F1 9 0 R >> >> >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 6 0 R /StructParents 0 >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 7 0 R /Annots [12 0 R] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 8 0 R /Annots [13 0 R] >>",
+		"<< /Length 35 >>\nstream\nBT /F1 12 Tf 10 10 Td (Page 1) Tj ET\nendstream",
+		"<< /Length 35 >>\nstream\nBT /F1 12 Tf 10 10 Td (Page 2) Tj ET\nendstream",
+		"<< /Length 35 >>\nstream\nBT /F1 12 Tf 10 10 Td (Page 3) Tj ET\nendstream",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Outlines /First 11 0 R /Last 11 0 R /Count 1 >>",
+		"<< /Title (Second) /Parent 10 0 R /Dest [4 0 R /Fit] >>",
+		"<< /FT /Tx /T (name) /Type /Annot /Subtype /Widget /Rect [10 10 100 30] /P 4 0 R >>",
+		"<< /FT /Tx /T (other) /Type /Annot /Subtype /Widget /Rect [10 10 100 30] /P 5 0 R >>",
+		"<< /Type /StructTreeRoot /K 15 0 R /ParentTree << /Nums [0 [15 0 R]] >> /ParentTreeNextKey 1 >>",
+		"<< /Type /StructElem /S /P /P 14 0 R /Pg 3 0 R /K 0 >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	var offsets []int
+	for i, obj := range objs {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader
+}
+
+func TestMergeDocuments(t *testing.T) {
+	w, err := MergeDocuments(
+		MergeInput{Reader: newMergeTestReader(t), OutlineTitle: "First document"},
+		MergeInput{Reader: newMergeTestReader(t), Pages: []int{1, 2}},
+	)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 5, numPages)
+	pageNumber := func(obj core.PdfObject) int {
+		for i, page := range reader.PageList {
+			if page.GetPageAsIndirectObject() == core.ResolveReference(obj) {
+				return i + 1
+			}
+		}
+		return 0
+	}
+
+	// Outlines.
+	outline, err := reader.GetOutlines()
+	require.NoError(t, err)
+	require.Len(t, outline.Entries, 2)
+	require.Equal(t, "First document", outline.Entries[0].Title)
+	require.Equal(t, int64(0), outline.Entries[0].Dest.Page)
+	require.Len(t, outline.Entries[0].Entries, 1)
+	require.Equal(t, "Second", outline.Entries[0].Entries[0].Title)
+	require.Equal(t, int64(1), outline.Entries[0].Entries[0].Dest.Page)
+	require.Equal(t, "Second", outline.Entries[1].Title)
+	require.Equal(t, int64(4), outline.Entries[1].Dest.Page)
+
+	// Named destinations, the clashing name is renamed.
+	names, err := reader.GetNamedDestinations()
+	require.NoError(t, err)
+	namesDict, ok := core.GetDict(names)
+	require.True(t, ok)
+	dests, err := loadNameTree(namesDict.Get("Dests"))
+	require.NoError(t, err)
+	require.Len(t, dests, 2)
+	require.Equal(t, "intro", dests[0].Key)
+	require.Equal(t, 1, pageNumber(dests[0].Value.(*core.PdfObjectArray).Get(0)))
+	require.Equal(t, "intro_1", dests[1].Key)
+	require.Equal(t, 4, pageNumber(dests[1].Value.(*core.PdfObjectArray).Get(0)))
+
+	// Form fields, the clashing name is renamed and the field of the page not merged is removed.
+	require.NotNil(t, reader.AcroForm)
+	var fieldNames []string
+	for _, field := range reader.AcroForm.AllFields() {
+		fieldNames = append(fieldNames, field.PartialName())
+	}
+	require.Equal(t, []string{"name", "other", "name_1"}, fieldNames)
+
+	// Page labels.
+	labels, err := reader.GetPageLabels()
+	require.NoError(t, err)
+	entries, err := loadNumberTree(labels)
+	require.NoError(t, err)
+	var keys []int64
+	var styles []string
+	for _, e := range entries {
+		keys = append(keys, e.Key)
+		style, _ := core.GetNameVal(e.Value.(*core.PdfObjectDictionary).Get("S"))
+		styles = append(styles, style)
+	}
+	require.Equal(t, []int64{0, 2, 3}, keys)
+	require.Equal(t, []string{"r", "D", "r"}, styles)
+
+	// The identical fonts are merged.
+	font1, ok := core.GetIndirect(reader.PageList[0].Resources.Font.(*core.PdfObjectDictionary).Get("F1"))
+	require.True(t, ok)
+	font4, ok := core.GetIndirect(reader.PageList[3].Resources.Font.(*core.PdfObjectDictionary).Get("F1"))
+	require.True(t, ok)
+	require.Equal(t, font1.ObjectNumber, font4.ObjectNumber)
+
+	// Structure trees.
+	structRoot, ok := core.GetDict(reader.catalog.Get("StructTreeRoot"))
+	require.True(t, ok)
+	kids, ok := core.GetArray(structRoot.Get("K"))
+	require.True(t, ok)
+	require.Equal(t, 2, kids.Len())
+	parentTree, err := loadNumberTree(structRoot.Get("ParentTree"))
+	require.NoError(t, err)
+	require.Len(t, parentTree, 2)
+	require.Equal(t, int64(1), parentTree[1].Key)
+	structParents, ok := core.GetIntVal(reader.PageList[3].StructParents)
+	require.True(t, ok)
+	require.Equal(t, 1, structParents)
+	for i := 0; i < kids.Len(); i++ {
+		elem, ok := core.GetDict(kids.Get(i))
+		require.True(t, ok)
+		require.Equal(t, []int{1, 4}[i], pageNumber(elem.Get("Pg")))
+	}
+}
+
+func TestMergeDocumentsPageRange(t *testing.T) {
+	_, err := MergeDocuments(MergeInput{Reader: newMergeTestReader(t), Pages: []int{4}})
+	require.Error(t, err)
+}
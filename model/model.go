@@ -6,6 +6,8 @@
 package model
 
 import (
+	"sync"
+
 	"github.com/carmel/unipdf/core"
 )
 
@@ -27,7 +29,11 @@ type PdfModel interface {
 // for each time it is used.  Thus, it is only used for special cases, commonly where the same
 // object is used by two higher level objects. (Example PDF Widgets owned by both Page Annotations,
 // and the interactive form - AcroForm).
+//
+// The modelManager is safe for concurrent use, as models are loaded on demand while processing
+// the pages of a reader concurrently.
 type modelManager struct {
+	mu             sync.RWMutex
 	primitiveCache map[PdfModel]core.PdfObject
 	modelCache     map[core.PdfObject]PdfModel
 }
@@ -42,12 +48,16 @@ func newModelManager() *modelManager {
 
 // Register registers (caches) a model to primitive object relationship.
 func (mm *modelManager) Register(primitive core.PdfObject, model PdfModel) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
 	mm.primitiveCache[model] = primitive
 	mm.modelCache[primitive] = model
 }
 
 // GetPrimitiveFromModel returns the primitive object corresponding to the input `model`.
 func (mm *modelManager) GetPrimitiveFromModel(model PdfModel) core.PdfObject {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
 	primitive, has := mm.primitiveCache[model]
 	if !has {
 		return nil
@@ -57,6 +67,8 @@ func (mm *modelManager) GetPrimitiveFromModel(model PdfModel) core.PdfObject {
 
 // GetModelFromPrimitive returns the model corresponding to the `primitive` PdfObject.
 func (mm *modelManager) GetModelFromPrimitive(primitive core.PdfObject) PdfModel {
+	mm.mu.RLock()
+	defer mm.mu.RUnlock()
 	model, has := mm.modelCache[primitive]
 	if !has {
 		return nil
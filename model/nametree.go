@@ -0,0 +1,160 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// nameTreeEntry is a key-value pair of a name tree (7.9.6).
+type nameTreeEntry struct {
+	Key   string
+	Value core.PdfObject
+}
+
+// loadNameTree walks the name tree with the specified root node and returns all its entries in order.
+func loadNameTree(root core.PdfObject) ([]nameTreeEntry, error) {
+	var entries []nameTreeEntry
+	visited := map[core.PdfObject]struct{}{}
+
+	var walk func(node core.PdfObject) error
+	walk = func(node core.PdfObject) error {
+		node = core.ResolveReference(node)
+		if _, ok := visited[node]; ok {
+			return errors.New("name tree loop detected")
+		}
+		visited[node] = struct{}{}
+
+		dict, ok := core.GetDict(node)
+		if !ok {
+			common.Log.Debug("ERROR: Invalid name tree node (%T)", node)
+			return core.ErrTypeError
+		}
+		if names, ok := core.GetArray(dict.Get("Names")); ok {
+			for i := 0; i+1 < names.Len(); i += 2 {
+				key, ok := core.GetString(names.Get(i))
+				if !ok {
+					common.Log.Debug("ERROR: Invalid name tree key (%T) - skipping", names.Get(i))
+					continue
+				}
+				entries = append(entries, nameTreeEntry{Key: key.Decoded(), Value: names.Get(i + 1)})
+			}
+		}
+		if kids, ok := core.GetArray(dict.Get("Kids")); ok {
+			for _, kid := range kids.Elements() {
+				if err := walk(kid); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// makeNameTree creates a single-node name tree from the entries. Entries are sorted by key.
+func makeNameTree(entries []nameTreeEntry) *core.PdfObjectDictionary {
+	sorted := make([]nameTreeEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	names := core.MakeArray()
+	for _, e := range sorted {
+		names.Append(makeTextString(e.Key), e.Value)
+	}
+	dict := core.MakeDict()
+	dict.Set("Names", names)
+	return dict
+}
+
+// numberTreeEntry is a key-value pair of a number tree (7.9.7).
+type numberTreeEntry struct {
+	Key   int64
+	Value core.PdfObject
+}
+
+// loadNumberTree walks the number tree with the specified root node and returns all its entries in
+// order.
+func loadNumberTree(root core.PdfObject) ([]numberTreeEntry, error) {
+	var entries []numberTreeEntry
+	visited := map[core.PdfObject]struct{}{}
+
+	var walk func(node core.PdfObject) error
+	walk = func(node core.PdfObject) error {
+		node = core.ResolveReference(node)
+		if _, ok := visited[node]; ok {
+			return errors.New("number tree loop detected")
+		}
+		visited[node] = struct{}{}
+
+		dict, ok := core.GetDict(node)
+		if !ok {
+			common.Log.Debug("ERROR: Invalid number tree node (%T)", node)
+			return core.ErrTypeError
+		}
+		if nums, ok := core.GetArray(dict.Get("Nums")); ok {
+			for i := 0; i+1 < nums.Len(); i += 2 {
+				key, ok := core.GetIntVal(nums.Get(i))
+				if !ok {
+					common.Log.Debug("ERROR: Invalid number tree key (%T) - skipping", nums.Get(i))
+					continue
+				}
+				entries = append(entries, numberTreeEntry{Key: int64(key), Value: nums.Get(i + 1)})
+			}
+		}
+		if kids, ok := core.GetArray(dict.Get("Kids")); ok {
+			for _, kid := range kids.Elements() {
+				if err := walk(kid); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// makeNumberTree creates a single-node number tree from the entries. Entries are sorted by key.
+func makeNumberTree(entries []numberTreeEntry) *core.PdfObjectDictionary {
+	sorted := make([]numberTreeEntry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	nums := core.MakeArray()
+	for _, e := range sorted {
+		nums.Append(core.MakeInteger(e.Key), e.Value)
+	}
+	dict := core.MakeDict()
+	dict.Set("Nums", nums)
+	return dict
+}
+
+// makeTextString creates a text string object. UTF-16BE encoding is used only for non-ASCII text.
+func makeTextString(s string) *core.PdfObjectString {
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return core.MakeEncodedString(s, true)
+		}
+	}
+	return core.MakeString(s)
+}
@@ -16,6 +16,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/carmel/unipdf/common"
@@ -405,6 +406,151 @@ func (p *PdfPage) GetMediaBox() (*PdfRectangle, error) {
 	return nil, errors.New("media box not defined")
 }
 
+// GetCropBox gets the inheritable crop box value, clipped to the media box. The media box is
+// returned if the page has no crop box.
+func (p *PdfPage) GetCropBox() (*PdfRectangle, error) {
+	mediaBox, err := p.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+
+	cropBox := p.CropBox
+	if cropBox == nil {
+		if arr, ok := core.GetArray(p.getParentAttribute("CropBox")); ok {
+			if cropBox, err = NewPdfRectangle(*arr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if cropBox == nil {
+		return mediaBox, nil
+	}
+
+	box := &PdfRectangle{
+		Llx: math.Max(math.Min(mediaBox.Llx, mediaBox.Urx), math.Min(cropBox.Llx, cropBox.Urx)),
+		Lly: math.Max(math.Min(mediaBox.Lly, mediaBox.Ury), math.Min(cropBox.Lly, cropBox.Ury)),
+		Urx: math.Min(math.Max(mediaBox.Llx, mediaBox.Urx), math.Max(cropBox.Llx, cropBox.Urx)),
+		Ury: math.Min(math.Max(mediaBox.Lly, mediaBox.Ury), math.Max(cropBox.Lly, cropBox.Ury)),
+	}
+	if box.Urx <= box.Llx || box.Ury <= box.Lly {
+		common.Log.Debug("ERROR: Crop box outside of media box, using media box")
+		return mediaBox, nil
+	}
+	return box, nil
+}
+
+// GetRotate gets the inheritable rotation of the page in degrees (clockwise), normalized to
+// 0, 90, 180 or 270.
+func (p *PdfPage) GetRotate() (int64, error) {
+	var rotate int64
+	if p.Rotate != nil {
+		rotate = *p.Rotate
+	} else if obj := p.getParentAttribute("Rotate"); obj != nil {
+		val, ok := core.GetIntVal(obj)
+		if !ok {
+			return 0, errors.New("invalid Page Rotate object")
+		}
+		rotate = int64(val)
+	}
+	if rotate%90 != 0 {
+		return 0, fmt.Errorf("invalid page rotation %d", rotate)
+	}
+	return (rotate%360 + 360) % 360, nil
+}
+
+// getParentAttribute returns the inheritable attribute `key` from the parent nodes of the page.
+func (p *PdfPage) getParentAttribute(key core.PdfObjectName) core.PdfObject {
+	visited := map[core.PdfObject]struct{}{}
+	node := p.Parent
+	for node != nil {
+		if _, ok := visited[node]; ok {
+			break
+		}
+		visited[node] = struct{}{}
+		dict, ok := core.GetDict(node)
+		if !ok {
+			break
+		}
+		if obj := dict.Get(key); obj != nil {
+			return obj
+		}
+		node = dict.Get("Parent")
+	}
+	return nil
+}
+
+// ToXObjectForm returns a Form XObject drawing the page as it is displayed: the content of the
+// page clipped to its crop box and rotated as specified by its Rotate attribute. The Matrix of
+// the form maps the crop box to a rectangle with the lower left corner at the origin, whose
+// dimensions are returned by GetDisplaySize. The annotations of the page are not drawn.
+func (p *PdfPage) ToXObjectForm() (*XObjectForm, error) {
+	box, err := p.GetCropBox()
+	if err != nil {
+		return nil, err
+	}
+	rotate, err := p.GetRotate()
+	if err != nil {
+		return nil, err
+	}
+	content, err := p.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+	resources := p.Resources
+	if resources == nil {
+		if resources, err = p.getParentResources(); err != nil {
+			return nil, err
+		}
+		if resources == nil {
+			resources = NewPdfPageResources()
+		}
+	}
+
+	// Rotation of the crop box moved to the origin.
+	w, h := box.Width(), box.Height()
+	var a, b, c, d, e, f float64
+	switch rotate {
+	case 90:
+		a, b, c, d, e, f = 0, -1, 1, 0, 0, w
+	case 180:
+		a, b, c, d, e, f = -1, 0, 0, -1, w, h
+	case 270:
+		a, b, c, d, e, f = 0, 1, -1, 0, h, 0
+	default:
+		a, b, c, d, e, f = 1, 0, 0, 1, 0, 0
+	}
+	e -= a*box.Llx + c*box.Lly
+	f -= b*box.Llx + d*box.Lly
+
+	xform := NewXObjectForm()
+	xform.FormType = core.MakeInteger(1)
+	xform.BBox = box.ToPdfObject()
+	xform.Matrix = core.MakeArrayFromFloats([]float64{a, b, c, d, e, f})
+	xform.Resources = resources
+	xform.Group = p.Group
+	if err := xform.SetContentStream([]byte(content), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	return xform, nil
+}
+
+// GetDisplaySize returns the dimensions of the page as displayed (crop box and rotation), which
+// are the dimensions of the Form XObject returned by ToXObjectForm.
+func (p *PdfPage) GetDisplaySize() (float64, float64, error) {
+	box, err := p.GetCropBox()
+	if err != nil {
+		return 0, 0, err
+	}
+	rotate, err := p.GetRotate()
+	if err != nil {
+		return 0, 0, err
+	}
+	if rotate == 90 || rotate == 270 {
+		return box.Height(), box.Width(), nil
+	}
+	return box.Width(), box.Height(), nil
+}
+
 // getParentResources searches for page resources in the parent nodes of the page.
 func (p *PdfPage) getParentResources() (*PdfPageResources, error) {
 	node := p.Parent
@@ -9,6 +9,8 @@ import (
 	"io"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core"
 )
@@ -336,6 +338,51 @@ endobj
 	}
 }
 
+func TestPageToXObjectForm(t *testing.T) {
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 200, Ury: 300}
+	require.NoError(t, page.SetContentStreams([]string{"0 0 m 10 10 l S"}, core.NewFlateEncoder()))
+
+	// Inherited crop box (clipped to the media box) and rotation.
+	parent := core.MakeDict()
+	parent.Set("CropBox", core.MakeArrayFromFloats([]float64{10, 20, 110, 320}))
+	parent.Set("Rotate", core.MakeInteger(-90))
+	page.Parent = core.MakeIndirectObject(parent)
+
+	box, err := page.GetCropBox()
+	require.NoError(t, err)
+	require.Equal(t, PdfRectangle{Llx: 10, Lly: 20, Urx: 110, Ury: 300}, *box)
+	rotate, err := page.GetRotate()
+	require.NoError(t, err)
+	require.Equal(t, int64(270), rotate)
+	width, height, err := page.GetDisplaySize()
+	require.NoError(t, err)
+	require.Equal(t, 280.0, width)
+	require.Equal(t, 100.0, height)
+
+	xform, err := page.ToXObjectForm()
+	require.NoError(t, err)
+	require.Equal(t, "[10 20 110 300]", xform.BBox.WriteString())
+	xform.ToPdfObject()
+	content, err := xform.GetContentStream()
+	require.NoError(t, err)
+	require.Equal(t, "0 0 m 10 10 l S", string(content))
+
+	// The matrix maps the crop box to (0, 0, 280, 100), the top of the page being on the left.
+	vals, ok := core.GetArray(xform.Matrix)
+	require.True(t, ok)
+	m, _ := vals.ToFloat64Array()
+	transform := func(x, y float64) (float64, float64) {
+		return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+	}
+	x, y := transform(10, 20)
+	require.InDelta(t, 280, x, 1e-9)
+	require.InDelta(t, 0, y, 1e-9)
+	x, y = transform(110, 300)
+	require.InDelta(t, 0, x, 1e-9)
+	require.InDelta(t, 100, y, 1e-9)
+}
+
 // Test rectangle parsing and loading.
 func TestRect(t *testing.T) {
 	rawText := `<< /MediaBox [0 0 613.644043 802.772034] >>`
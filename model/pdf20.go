@@ -0,0 +1,165 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// Pdf20Error is returned when the output of a writer in PDF 2.0 mode contains a feature that is
+// deprecated in PDF 2.0 (ISO 32000-2) and cannot be removed.
+type Pdf20Error struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *Pdf20Error) Error() string {
+	return fmt.Sprintf("PDF 2.0: %s", e.Reason)
+}
+
+// SetPdf20 sets whether the writer produces a PDF 2.0 document (ISO 32000-2). In PDF 2.0 mode:
+//   - the version is set to 2.0 (header and catalog),
+//   - the document information is written as XMP metadata, and only the CreationDate and
+//     ModDate entries of the deprecated document information dictionary are kept,
+//   - encryption is limited to AES-256, which is used by default,
+//   - the deprecated ProcSet resources, XObject names and NeedAppearances form flag are removed,
+//     and Write returns a *Pdf20Error if the document contains other deprecated features (XFA
+//     forms, movie and sound annotations and actions).
+//
+// PDF 2.0 output cannot be combined with PDF/A output or streaming.
+func (w *PdfWriter) SetPdf20(enabled bool) {
+	w.pdf20 = enabled
+	if enabled {
+		w.majorVersion, w.minorVersion = 2, 0
+	}
+}
+
+// pdf20DeprecatedActions are action types deprecated in PDF 2.0.
+var pdf20DeprecatedActions = map[string]bool{
+	"Movie": true,
+	"Sound": true,
+}
+
+// pdf20DeprecatedAnnotations are annotation subtypes deprecated in PDF 2.0.
+var pdf20DeprecatedAnnotations = map[string]bool{
+	"Movie": true,
+	"Sound": true,
+}
+
+// applyPdf20 checks and converts the objects to be written for PDF 2.0 output, and moves the
+// document information to the XMP metadata.
+func (w *PdfWriter) applyPdf20() error {
+	if w.pdfa != nil {
+		return &Pdf20Error{Reason: "PDF/A output is not supported"}
+	}
+	w.majorVersion, w.minorVersion = 2, 0
+
+	if w.crypter != nil {
+		// AES-256 is the only security handler version (V 5) not deprecated.
+		if v, _ := core.GetIntVal(w.encryptDict.Get("V")); v != 5 {
+			return &Pdf20Error{Reason: "only AES-256 encryption is allowed"}
+		}
+	}
+
+	if acroForm, ok := core.GetDict(w.catalog.Get("AcroForm")); ok {
+		if acroForm.Get("XFA") != nil {
+			return &Pdf20Error{Reason: "XFA forms are not allowed"}
+		}
+		acroForm.Remove("NeedAppearances")
+	}
+
+	for _, obj := range w.objects {
+		var err error
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			err = w.visitPdf20(t.PdfObject)
+		case *core.PdfObjectStream:
+			err = w.visitPdf20Dict(t.PdfObjectDictionary)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.writePdf20Metadata()
+}
+
+// visitPdf20 checks direct dictionaries and arrays. Indirect objects and streams are checked
+// separately as they are in the list of objects to write.
+func (w *PdfWriter) visitPdf20(obj core.PdfObject) error {
+	switch t := obj.(type) {
+	case *core.PdfObjectDictionary:
+		return w.visitPdf20Dict(t)
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			if err := w.visitPdf20(elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// visitPdf20Dict checks dictionary `dict` and removes its deprecated entries.
+func (w *PdfWriter) visitPdf20Dict(dict *core.PdfObjectDictionary) error {
+	for _, key := range dict.Keys() {
+		if err := w.visitPdf20(dict.Get(key)); err != nil {
+			return err
+		}
+	}
+
+	typ, _ := core.GetNameVal(dict.Get("Type"))
+	subtype, _ := core.GetNameVal(dict.Get("Subtype"))
+	if s, ok := core.GetNameVal(dict.Get("S")); ok && pdf20DeprecatedActions[s] {
+		return &Pdf20Error{Reason: fmt.Sprintf("%s actions are deprecated", s)}
+	}
+	if (typ == "Annot" || dict.Get("Rect") != nil) && pdf20DeprecatedAnnotations[subtype] {
+		return &Pdf20Error{Reason: fmt.Sprintf("%s annotations are deprecated", subtype)}
+	}
+	if resources, ok := core.GetDict(dict.Get("Resources")); ok {
+		resources.Remove("ProcSet")
+	}
+	if subtype == "Image" || subtype == "Form" {
+		dict.Remove("Name")
+	}
+	return nil
+}
+
+// writePdf20Metadata writes the document information as XMP metadata and removes the deprecated
+// entries of the document information dictionary.
+func (w *PdfWriter) writePdf20Metadata() error {
+	packet := w.xmp
+	if packet == nil {
+		packet = xmputil.New()
+	}
+	if _, err := w.writeXMPMetadata(packet); err != nil {
+		return err
+	}
+
+	info, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return ErrTypeCheck
+	}
+	dates := core.MakeDict()
+	now := time.Now().Truncate(time.Second)
+	for _, key := range []core.PdfObjectName{"CreationDate", "ModDate"} {
+		date := info.Get(key)
+		if date == nil {
+			pdfDate, err := NewPdfDateFromTime(now)
+			if err != nil {
+				return err
+			}
+			date = pdfDate.ToPdfObject()
+		}
+		dates.Set(key, date)
+	}
+	w.infoObj.PdfObject = dates
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/core/security"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// newPdf20TestWriter returns a writer with a page using the deprecated ProcSet resources.
+func newPdf20TestWriter(t *testing.T) *PdfWriter {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.MediaBox = &PdfRectangle{Urx: 200, Ury: 100}
+	page.Resources.ProcSet = core.MakeArray(core.MakeName("PDF"), core.MakeName("Text"))
+	require.NoError(t, page.SetContentStreams([]string{"0 0 10 10 re f"}, core.NewFlateEncoder()))
+	require.NoError(t, w.AddPage(page))
+	w.SetDocInfo(&PdfInfo{Title: core.MakeString("Modern"), Author: core.MakeString("Writer")})
+	return &w
+}
+
+func TestPdf20Output(t *testing.T) {
+	w := newPdf20TestWriter(t)
+	w.SetPdf20(true)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-2.0\n")))
+	require.Contains(t, buf.String(), "/Type /XRef")
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, core.Version{Major: 2, Minor: 0}, reader.PdfVersion())
+	version, _ := core.GetNameVal(reader.catalog.Get("Version"))
+	require.Equal(t, "2.0", version)
+
+	// Only the dates are kept in the information dictionary, the rest is in the XMP metadata.
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	info, ok := core.GetDict(trailer.Get("Info"))
+	require.True(t, ok)
+	require.ElementsMatch(t, []core.PdfObjectName{"CreationDate", "ModDate"}, info.Keys())
+	packet, err := reader.GetXMP()
+	require.NoError(t, err)
+	require.Equal(t, "Modern", packet.Text(xmputil.NsDC, "title"))
+
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	require.Nil(t, page.Resources.ProcSet)
+}
+
+func TestPdf20Encryption(t *testing.T) {
+	w := newPdf20TestWriter(t)
+	w.SetPdf20(true)
+	require.Error(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Algorithm: AES_128bit}))
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	ok, err := reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	encrypt, ok := core.GetDict(trailer.Get("Encrypt"))
+	require.True(t, ok)
+	v, _ := core.GetIntVal(encrypt.Get("V"))
+	require.Equal(t, 5, v)
+
+	// Options without an algorithm default to AES-256.
+	w = newPdf20TestWriter(t)
+	w.SetPdf20(true)
+	perms := security.PermPrinting
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), &EncryptOptions{Permissions: perms}))
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+	reader, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	ok, err = reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, perms, reader.GetAccessPermissions())
+	trailer, err = reader.GetTrailer()
+	require.NoError(t, err)
+	encrypt, ok = core.GetDict(trailer.Get("Encrypt"))
+	require.True(t, ok)
+	v, _ = core.GetIntVal(encrypt.Get("V"))
+	require.Equal(t, 5, v)
+
+	// Encryption set up before switching to PDF 2.0.
+	w = newPdf20TestWriter(t)
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+	w.SetPdf20(true)
+	err = w.Write(&bytes.Buffer{})
+	require.IsType(t, &Pdf20Error{}, err)
+}
+
+func TestPdf20Deprecated(t *testing.T) {
+	w := newPdf20TestWriter(t)
+	w.SetPdf20(true)
+	annot := core.MakeDict()
+	annot.Set("Type", core.MakeName("Annot"))
+	annot.Set("Subtype", core.MakeName("Movie"))
+	annot.Set("Rect", core.MakeArray(core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(10), core.MakeInteger(10)))
+	pages, ok := core.GetDict(w.pages)
+	require.True(t, ok)
+	kids, ok := core.GetArray(pages.Get("Kids"))
+	require.True(t, ok)
+	pageDict, ok := core.GetDict(kids.Get(0))
+	require.True(t, ok)
+	pageDict.Set("Annots", core.MakeArray(annot))
+
+	err := w.Write(&bytes.Buffer{})
+	require.IsType(t, &Pdf20Error{}, err)
+	require.Contains(t, err.Error(), "Movie annotations")
+
+	w = newPdf20TestWriter(t)
+	w.SetPdf20(true)
+	w.SetPdfA(&PdfAOptions{Conformance: PdfA2B})
+	require.IsType(t, &Pdf20Error{}, w.Write(&bytes.Buffer{}))
+}
+
+func TestWriterCrossReferenceStream(t *testing.T) {
+	// Cross-reference stream for a PDF 1.3 document.
+	w := newPdf20TestWriter(t)
+	w.SetCrossReferenceStream(true)
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-1.5\n")))
+	require.Contains(t, buf.String(), "/Type /XRef")
+	require.NotContains(t, buf.String(), "trailer")
+	_, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// Cross-reference table for a PDF 1.7 document.
+	w = newPdf20TestWriter(t)
+	w.SetVersion(1, 7)
+	w.SetCrossReferenceStream(false)
+	buf.Reset()
+	require.NoError(t, w.Write(&buf))
+	require.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-1.7\n")))
+	require.Contains(t, buf.String(), "trailer")
+	require.NotContains(t, buf.String(), "/Type /XRef")
+	_, err = NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+}
@@ -0,0 +1,617 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/internal/icc"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// PdfAConformance is a PDF/A conformance level (ISO 19005).
+type PdfAConformance int
+
+// Supported PDF/A conformance levels. Level B ensures reliable reproduction of the visual
+// appearance of the document.
+const (
+	// PdfA1B is PDF/A-1b (ISO 19005-1), based on PDF 1.4. Transparency, JPEG 2000 images,
+	// optional content and embedded files are not allowed.
+	PdfA1B PdfAConformance = iota + 1
+	// PdfA2B is PDF/A-2b (ISO 19005-2), based on PDF 1.7. Only PDF files can be embedded.
+	PdfA2B
+	// PdfA3B is PDF/A-3b (ISO 19005-3). Files of any type can be embedded.
+	PdfA3B
+)
+
+// Part returns the part of ISO 19005 that defines the conformance level.
+func (c PdfAConformance) Part() int {
+	return int(c)
+}
+
+// String returns the name of the conformance level, e.g. "PDF/A-1b".
+func (c PdfAConformance) String() string {
+	return fmt.Sprintf("PDF/A-%db", c.Part())
+}
+
+// PdfAOptions specifies how a PDF/A document is produced.
+type PdfAOptions struct {
+	Conformance PdfAConformance
+
+	// Fonts maps base font names of fonts that are not embedded, such as the standard 14 fonts,
+	// to embedded simple fonts (e.g. loaded with NewPdfFontFromTTFFile) that replace them in the
+	// output. The replacements must use the same encoding as the fonts they replace.
+	// Writing fails if a font is not embedded and has no replacement.
+	Fonts map[string]*PdfFont
+}
+
+// PdfAError is returned when the output contains a construct that is not allowed at
+// the requested PDF/A conformance level and cannot be converted.
+type PdfAError struct {
+	Conformance PdfAConformance
+	Reason      string
+}
+
+// Error implements the error interface.
+func (e *PdfAError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Conformance, e.Reason)
+}
+
+// SetPdfA makes the writer produce a PDF/A document with the specified options. The output
+// includes XMP metadata with PDF/A identification and an sRGB output intent. DeviceCMYK colors
+// and images are converted to DeviceRGB and non-embedded fonts are replaced as specified in the
+// options. Write returns a *PdfAError if the document cannot be made conforming.
+// Passing nil disables PDF/A output.
+func (w *PdfWriter) SetPdfA(opts *PdfAOptions) {
+	w.pdfa = opts
+}
+
+// pdfaForbiddenActions are action types not allowed in PDF/A documents.
+var pdfaForbiddenActions = map[string]bool{
+	"Launch":      true,
+	"Sound":       true,
+	"Movie":       true,
+	"ResetForm":   true,
+	"ImportData":  true,
+	"JavaScript":  true,
+	"Hide":        true,
+	"SetOCGState": true,
+	"Rendition":   true,
+	"Trans":       true,
+	"GoTo3DView":  true,
+}
+
+// pdfaForbiddenAnnotations are annotation subtypes not allowed in PDF/A documents.
+var pdfaForbiddenAnnotations = map[string]bool{
+	"Sound":     true,
+	"Movie":     true,
+	"Screen":    true,
+	"3D":        true,
+	"RichMedia": true,
+}
+
+// PdfAForbidsAction returns whether actions of type `actionType` (the S entry of the action
+// dictionaries) are not allowed in PDF/A documents.
+func PdfAForbidsAction(actionType string) bool {
+	return pdfaForbiddenActions[actionType]
+}
+
+// PdfAForbidsAnnotation returns whether annotations of subtype `subtype` are not allowed in the
+// documents conforming to part `part` of PDF/A (1, 2 or 3). File attachment annotations are not
+// allowed in PDF/A-1 documents only.
+func PdfAForbidsAnnotation(subtype string, part int) bool {
+	return pdfaForbiddenAnnotations[subtype] || (subtype == "FileAttachment" && part == 1)
+}
+
+// pdfaContentStream is a content stream together with the resources it uses.
+type pdfaContentStream struct {
+	stream    *core.PdfObjectStream
+	resources *core.PdfObjectDictionary
+}
+
+// pdfaConverter checks and converts the objects of a writer for PDF/A output.
+type pdfaConverter struct {
+	w    *PdfWriter
+	opts *PdfAOptions
+
+	contents  []pdfaContentStream
+	resources map[*core.PdfObjectDictionary]struct{}
+}
+
+func (c *pdfaConverter) errorf(format string, args ...interface{}) error {
+	return &PdfAError{Conformance: c.opts.Conformance, Reason: fmt.Sprintf(format, args...)}
+}
+
+// checkPdfAEmbeddedFiles checks the embedded files added to the writer against the PDF/A level
+// and sets the associated file properties required by PDF/A-3.
+func (w *PdfWriter) checkPdfAEmbeddedFiles() error {
+	conf := w.pdfa.Conformance
+	for _, f := range w.embeddedFiles.added {
+		switch conf {
+		case PdfA1B:
+			return &PdfAError{Conformance: conf, Reason: "embedded files are not allowed"}
+		case PdfA2B:
+			if !bytes.HasPrefix(f.Content, []byte("%PDF-")) {
+				return &PdfAError{Conformance: conf, Reason: fmt.Sprintf("embedded file %q is not a PDF/A document", f.Name)}
+			}
+		case PdfA3B:
+			if f.Relationship == "" {
+				f.Relationship = AFRelationshipUnspecified
+			}
+			if f.MimeType == "" {
+				f.MimeType = "application/octet-stream"
+			}
+		}
+	}
+	return nil
+}
+
+// applyPdfA checks and converts the objects to be written for PDF/A output, and adds the
+// metadata, output intent and file identifier required by PDF/A.
+func (w *PdfWriter) applyPdfA() error {
+	opts := w.pdfa
+	conf := opts.Conformance
+	if conf < PdfA1B || conf > PdfA3B {
+		return fmt.Errorf("unsupported PDF/A conformance level: %d", conf)
+	}
+	c := &pdfaConverter{
+		w:         w,
+		opts:      opts,
+		resources: map[*core.PdfObjectDictionary]struct{}{},
+	}
+
+	if w.crypter != nil {
+		return c.errorf("encryption is not allowed")
+	}
+	if conf == PdfA1B {
+		if w.majorVersion > 1 || w.minorVersion > 4 {
+			w.majorVersion, w.minorVersion = 1, 4
+		}
+		// Cross-reference and object streams require PDF 1.5.
+		useCrossReferenceStream := false
+		w.useCrossReferenceStream = &useCrossReferenceStream
+		if w.catalog.Get("OCProperties") != nil {
+			return c.errorf("optional content is not allowed")
+		}
+	} else if w.majorVersion > 1 {
+		w.majorVersion, w.minorVersion = 1, 7
+	}
+
+	objects := make([]core.PdfObject, len(w.objects))
+	copy(objects, w.objects)
+	for _, obj := range objects {
+		var err error
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			err = c.visit(t.PdfObject)
+		case *core.PdfObjectStream:
+			if err = c.checkStream(t); err == nil {
+				err = c.visitDict(t.PdfObjectDictionary, t)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := c.convertContents(); err != nil {
+		return err
+	}
+	return w.writePdfAMetadata()
+}
+
+// visit checks direct dictionaries and arrays. Indirect objects and streams are checked
+// separately as they are in the list of objects to write.
+func (c *pdfaConverter) visit(obj core.PdfObject) error {
+	switch t := obj.(type) {
+	case *core.PdfObjectDictionary:
+		return c.visitDict(t, nil)
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			if err := c.visit(elem); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// visitDict checks dictionary `dict`, which is the dictionary of `stream` if not nil.
+func (c *pdfaConverter) visitDict(dict *core.PdfObjectDictionary, stream *core.PdfObjectStream) error {
+	for _, key := range dict.Keys() {
+		if err := c.visit(dict.Get(key)); err != nil {
+			return err
+		}
+	}
+
+	typ, _ := core.GetNameVal(dict.Get("Type"))
+	subtype, _ := core.GetNameVal(dict.Get("Subtype"))
+	if s, ok := core.GetNameVal(dict.Get("S")); ok && PdfAForbidsAction(s) {
+		return c.errorf("%s actions are not allowed", s)
+	}
+	if group, ok := core.GetDict(dict.Get("Group")); ok && c.opts.Conformance == PdfA1B {
+		if s, _ := core.GetNameVal(group.Get("S")); s == "Transparency" {
+			return c.errorf("transparency groups are not allowed")
+		}
+	}
+	resources, _ := core.GetDict(dict.Get("Resources"))
+	if resources != nil {
+		if err := c.checkResources(resources); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case typ == "Font":
+		return c.checkFont(dict)
+	case typ == "Page":
+		switch t := core.TraceToDirectObject(dict.Get("Contents")).(type) {
+		case *core.PdfObjectStream:
+			c.contents = append(c.contents, pdfaContentStream{t, resources})
+		case *core.PdfObjectArray:
+			for _, elem := range t.Elements() {
+				if s, ok := core.GetStream(elem); ok {
+					c.contents = append(c.contents, pdfaContentStream{s, resources})
+				}
+			}
+		}
+		if annots, ok := core.GetArray(dict.Get("Annots")); ok {
+			for _, obj := range annots.Elements() {
+				if annot, ok := core.GetDict(obj); ok {
+					if err := c.checkAnnotation(annot); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case stream == nil:
+	case subtype == "Form":
+		c.contents = append(c.contents, pdfaContentStream{stream, resources})
+	case subtype == "Image":
+		return c.checkImage(stream)
+	default:
+		if patternType, _ := core.GetIntVal(dict.Get("PatternType")); patternType == 1 {
+			c.contents = append(c.contents, pdfaContentStream{stream, resources})
+		}
+	}
+	return nil
+}
+
+// checkStream checks the filters of a stream.
+func (c *pdfaConverter) checkStream(stream *core.PdfObjectStream) error {
+	if stream.Get("F") != nil || stream.Get("FFilter") != nil {
+		return c.errorf("external stream data is not allowed")
+	}
+	var filters []string
+	switch t := core.TraceToDirectObject(stream.Get("Filter")).(type) {
+	case *core.PdfObjectName:
+		filters = append(filters, string(*t))
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			if name, ok := core.GetNameVal(elem); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+	for _, filter := range filters {
+		switch filter {
+		case core.StreamEncodingFilterNameLZW:
+			return c.errorf("LZW compression is not allowed")
+		case core.StreamEncodingFilterNameCrypt:
+			return c.errorf("Crypt filters are not allowed")
+		case core.StreamEncodingFilterNameJPX:
+			if c.opts.Conformance == PdfA1B {
+				return c.errorf("JPEG 2000 images are not allowed")
+			}
+		}
+	}
+	return nil
+}
+
+// checkResources checks the graphics states of a resource dictionary.
+func (c *pdfaConverter) checkResources(resources *core.PdfObjectDictionary) error {
+	if _, ok := c.resources[resources]; ok {
+		return nil
+	}
+	c.resources[resources] = struct{}{}
+
+	extGStates, ok := core.GetDict(resources.Get("ExtGState"))
+	if !ok {
+		return nil
+	}
+	for _, key := range extGStates.Keys() {
+		gs, ok := core.GetDict(extGStates.Get(key))
+		if !ok {
+			continue
+		}
+		if gs.Get("TR") != nil {
+			return c.errorf("transfer functions are not allowed")
+		}
+		if tr2, ok := core.GetNameVal(gs.Get("TR2")); gs.Get("TR2") != nil && (!ok || tr2 != "Default") {
+			return c.errorf("transfer functions are not allowed")
+		}
+		if c.opts.Conformance != PdfA1B {
+			continue
+		}
+		if smask := gs.Get("SMask"); smask != nil {
+			if name, ok := core.GetNameVal(smask); !ok || name != "None" {
+				return c.errorf("soft masks are not allowed")
+			}
+		}
+		for _, alphaKey := range []core.PdfObjectName{"CA", "ca"} {
+			if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(gs.Get(alphaKey))); err == nil && alpha != 1 {
+				return c.errorf("constant opacity other than 1.0 is not allowed")
+			}
+		}
+		if bm, ok := core.GetNameVal(gs.Get("BM")); ok && bm != "Normal" && bm != "Compatible" {
+			return c.errorf("blend mode %s is not allowed", bm)
+		}
+	}
+	return nil
+}
+
+// checkAnnotation checks an annotation and sets its flags so that it is printed.
+func (c *pdfaConverter) checkAnnotation(annot *core.PdfObjectDictionary) error {
+	subtype, _ := core.GetNameVal(annot.Get("Subtype"))
+	if PdfAForbidsAnnotation(subtype, c.opts.Conformance.Part()) {
+		return c.errorf("%s annotations are not allowed", subtype)
+	}
+	if c.opts.Conformance == PdfA1B {
+		if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(annot.Get("CA"))); err == nil && alpha != 1 {
+			return c.errorf("annotation opacity other than 1.0 is not allowed")
+		}
+	}
+	if subtype == "Popup" {
+		return nil
+	}
+	// Annotation flags (Table 165): Invisible (1), Hidden (2), Print (3), NoView (6).
+	const (
+		flagInvisible = 1 << 0
+		flagHidden    = 1 << 1
+		flagPrint     = 1 << 2
+		flagNoView    = 1 << 5
+	)
+	flags, _ := core.GetIntVal(annot.Get("F"))
+	flags = flags&^(flagInvisible|flagHidden|flagNoView) | flagPrint
+	annot.Set("F", core.MakeInteger(int64(flags)))
+	return nil
+}
+
+// fontEmbedded reports whether the font program of a simple or CID font is embedded.
+func fontEmbedded(font *core.PdfObjectDictionary) bool {
+	descriptor, ok := core.GetDict(font.Get("FontDescriptor"))
+	if !ok {
+		return false
+	}
+	for _, key := range []core.PdfObjectName{"FontFile", "FontFile2", "FontFile3"} {
+		if _, ok := core.GetStream(descriptor.Get(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFont checks that a font is embedded, replacing it with a font specified in the options
+// if not.
+func (c *pdfaConverter) checkFont(font *core.PdfObjectDictionary) error {
+	subtype, _ := core.GetNameVal(font.Get("Subtype"))
+	baseFont, _ := core.GetNameVal(font.Get("BaseFont"))
+	switch subtype {
+	case "Type3", "CIDFontType0", "CIDFontType2":
+		// Type 3 glyphs are content streams. CID fonts are checked with their Type 0 font.
+		if procs, ok := core.GetDict(font.Get("CharProcs")); ok {
+			resources, _ := core.GetDict(font.Get("Resources"))
+			for _, key := range procs.Keys() {
+				if s, ok := core.GetStream(procs.Get(key)); ok {
+					c.contents = append(c.contents, pdfaContentStream{s, resources})
+				}
+			}
+		}
+		return nil
+	case "Type0":
+		descendants, _ := core.GetArray(font.Get("DescendantFonts"))
+		if descendants == nil || descendants.Len() == 0 {
+			return c.errorf("font %s has no descendant font", baseFont)
+		}
+		if cidFont, ok := core.GetDict(descendants.Get(0)); !ok || !fontEmbedded(cidFont) {
+			return c.errorf("font %s is not embedded", baseFont)
+		}
+		return nil
+	}
+	if fontEmbedded(font) {
+		return nil
+	}
+
+	replacement := c.opts.Fonts[baseFont]
+	if replacement == nil {
+		return c.errorf("font %s is not embedded", baseFont)
+	}
+	rdict, ok := core.GetDict(replacement.ToPdfObject())
+	rsubtype, _ := core.GetNameVal(rdict.Get("Subtype"))
+	if !ok || (rsubtype != "TrueType" && rsubtype != "Type1") || !fontEmbedded(rdict) {
+		return c.errorf("replacement of font %s is not an embedded simple font", baseFont)
+	}
+	common.Log.Debug("PDF/A: replacing font %s with %s", baseFont, replacement.BaseFont())
+	for _, key := range font.Keys() {
+		if rdict.Get(key) == nil {
+			font.Remove(key)
+		}
+	}
+	for _, key := range rdict.Keys() {
+		font.Set(key, rdict.Get(key))
+	}
+	return c.w.addObjects(font)
+}
+
+// usesDeviceCMYK reports whether images in color space `cs` have DeviceCMYK samples.
+func usesDeviceCMYK(cs PdfColorspace) bool {
+	switch t := cs.(type) {
+	case *PdfColorspaceDeviceCMYK:
+		return true
+	case *PdfColorspaceSpecialIndexed:
+		_, ok := t.Base.(*PdfColorspaceDeviceCMYK)
+		return ok
+	}
+	return false
+}
+
+// checkImage checks an image XObject and converts DeviceCMYK images to DeviceRGB.
+func (c *pdfaConverter) checkImage(stream *core.PdfObjectStream) error {
+	if c.opts.Conformance == PdfA1B && stream.Get("SMask") != nil {
+		return c.errorf("soft masks are not allowed")
+	}
+	if interpolate, ok := core.GetBoolVal(stream.Get("Interpolate")); ok && interpolate {
+		stream.Set("Interpolate", core.MakeBool(false))
+	}
+	stream.Remove("Alternates")
+	stream.Remove("OPI")
+
+	if mask, _ := core.GetBoolVal(stream.Get("ImageMask")); mask {
+		return nil
+	}
+	ximg, err := NewXObjectImageFromStream(stream)
+	if err != nil {
+		return err
+	}
+	if !usesDeviceCMYK(ximg.ColorSpace) {
+		return nil
+	}
+	img, err := ximg.ToImage()
+	if err != nil {
+		return err
+	}
+	rgb, err := ximg.ColorSpace.ImageToRGB(*img)
+	if err != nil {
+		return err
+	}
+	// The decode array is applied by the conversion.
+	ximg.Decode = nil
+	ximg.Filter = core.NewFlateEncoder()
+	if err := ximg.SetImage(&rgb, NewPdfColorspaceDeviceRGB()); err != nil {
+		return err
+	}
+	ximg.ToPdfObject()
+	return nil
+}
+
+// cmykColorspaces returns the names of color space resources that are DeviceCMYK.
+func cmykColorspaces(resources *core.PdfObjectDictionary) map[string]bool {
+	names := map[string]bool{}
+	if resources == nil {
+		return names
+	}
+	colorspaces, ok := core.GetDict(resources.Get("ColorSpace"))
+	if !ok {
+		return names
+	}
+	for _, key := range colorspaces.Keys() {
+		if name, ok := core.GetNameVal(colorspaces.Get(key)); ok && name == "DeviceCMYK" {
+			names[string(key)] = true
+		}
+	}
+	return names
+}
+
+// convertContents converts DeviceCMYK colors of content streams and color space resources
+// to DeviceRGB, as the sRGB output intent does not allow DeviceCMYK.
+func (c *pdfaConverter) convertContents() error {
+	for _, content := range c.contents {
+		data, err := core.DecodeStream(content.stream)
+		if err != nil {
+			return err
+		}
+		converted, changed, err := convertContentCMYK(data, cmykColorspaces(content.resources))
+		if err == errInlineImageCMYK {
+			return c.errorf("DeviceCMYK inline images are not allowed with an sRGB output intent")
+		}
+		if err != nil {
+			return err
+		}
+		if !changed {
+			continue
+		}
+		encoder := core.NewFlateEncoder()
+		encoded, err := encoder.EncodeBytes(converted)
+		if err != nil {
+			return err
+		}
+		content.stream.Stream = encoded
+		content.stream.Remove("DecodeParms")
+		content.stream.Set("Filter", core.MakeName(core.StreamEncodingFilterNameFlate))
+		content.stream.Set("Length", core.MakeInteger(int64(len(encoded))))
+	}
+
+	for resources := range c.resources {
+		colorspaces, ok := core.GetDict(resources.Get("ColorSpace"))
+		if !ok {
+			continue
+		}
+		for name := range cmykColorspaces(resources) {
+			colorspaces.Set(core.PdfObjectName(name), core.MakeName("DeviceRGB"))
+		}
+	}
+	return nil
+}
+
+// writePdfAMetadata adds the XMP metadata stream and the sRGB output intent to the catalog, and
+// sets the file identifier.
+func (w *PdfWriter) writePdfAMetadata() error {
+	info, ok := core.GetDict(w.infoObj)
+	if !ok {
+		return ErrTypeCheck
+	}
+	now := time.Now().Truncate(time.Second)
+	for _, key := range []core.PdfObjectName{"CreationDate", "ModDate"} {
+		if info.Get(key) == nil {
+			date, err := NewPdfDateFromTime(now)
+			if err != nil {
+				return err
+			}
+			info.Set(key, date.ToPdfObject())
+		}
+	}
+
+	packet := w.xmp
+	if packet == nil {
+		packet = xmputil.New()
+	}
+	packet.SetText(xmputil.NsPdfAID, "part", strconv.Itoa(w.pdfa.Conformance.Part()))
+	packet.SetText(xmputil.NsPdfAID, "conformance", "B")
+	packet.SetText(xmputil.NsDC, "format", "application/pdf")
+	xmp, err := w.writeXMPMetadata(packet)
+	if err != nil {
+		return err
+	}
+
+	if w.catalog.Get("OutputIntents") == nil {
+		profile, err := core.MakeStream(icc.SRGB(), core.NewFlateEncoder())
+		if err != nil {
+			return err
+		}
+		profile.Set("N", core.MakeInteger(3))
+		intent := core.MakeDict()
+		intent.Set("Type", core.MakeName("OutputIntent"))
+		intent.Set("S", core.MakeName("GTS_PDFA1"))
+		intent.Set("OutputConditionIdentifier", core.MakeString(icc.SRGBDescription))
+		intent.Set("Info", core.MakeString(icc.SRGBDescription))
+		intent.Set("RegistryName", core.MakeString("http://www.color.org"))
+		intent.Set("DestOutputProfile", profile)
+		w.catalog.Set("OutputIntents", core.MakeArray(intent))
+		w.addObject(profile)
+	}
+
+	if w.ids == nil {
+		sum := md5.Sum(append(xmp, now.String()...))
+		id := core.MakeHexString(string(sum[:]))
+		w.ids = core.MakeArray(id, id)
+	}
+	return nil
+}
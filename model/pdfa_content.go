@@ -0,0 +1,280 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+)
+
+// errInlineImageCMYK is returned when a content stream contains an inline image in the
+// DeviceCMYK color space, which cannot be converted in place.
+var errInlineImageCMYK = errors.New("inline image uses DeviceCMYK")
+
+// contentLexer splits a content stream into tokens. It only distinguishes operators
+// from operands, which is enough to rewrite operators without interpreting the content.
+type contentLexer struct {
+	data []byte
+	pos  int
+}
+
+func isContentWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isContentDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// next returns the next token and whether it is an operator. io.EOF is returned at the
+// end of the content stream.
+func (l *contentLexer) next() ([]byte, bool, error) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isContentWhitespace(c) {
+			l.pos++
+			continue
+		}
+		if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		break
+	}
+	if l.pos >= len(l.data) {
+		return nil, false, io.EOF
+	}
+
+	start := l.pos
+	switch c := l.data[l.pos]; c {
+	case '(':
+		depth := 0
+		for ; l.pos < len(l.data); l.pos++ {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if depth == 0 {
+				l.pos++
+				return l.data[start:l.pos], false, nil
+			}
+		}
+		return nil, false, errors.New("unterminated string")
+	case '<':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '<' {
+			l.pos += 2
+			return l.data[start:l.pos], false, nil
+		}
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return nil, false, errors.New("unterminated hex string")
+		}
+		l.pos += end + 1
+		return l.data[start:l.pos], false, nil
+	case '>':
+		if l.pos+1 < len(l.data) && l.data[l.pos+1] == '>' {
+			l.pos += 2
+			return l.data[start:l.pos], false, nil
+		}
+		return nil, false, errors.New("unexpected '>'")
+	case '[', ']', '{', '}':
+		l.pos++
+		return l.data[start:l.pos], false, nil
+	case '/':
+		l.pos++
+	}
+	for l.pos < len(l.data) && !isContentWhitespace(l.data[l.pos]) && !isContentDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	tok := l.data[start:l.pos]
+	if len(tok) == 0 {
+		return nil, false, errors.New("unexpected delimiter")
+	}
+	if tok[0] == '/' {
+		return tok, false, nil
+	}
+	switch string(tok) {
+	case "true", "false", "null":
+		return tok, false, nil
+	}
+	_, err := strconv.ParseFloat(string(tok), 64)
+	return tok, err != nil, nil
+}
+
+// inlineImageData returns the data of an inline image up to and including the EI operator.
+// Must be called after the ID operator.
+func (l *contentLexer) inlineImageData() ([]byte, error) {
+	// A single white-space character follows the ID operator.
+	l.pos++
+	start := l.pos
+	for i := start; i+1 < len(l.data); i++ {
+		if l.data[i] != 'E' || l.data[i+1] != 'I' || i == start || !isContentWhitespace(l.data[i-1]) {
+			continue
+		}
+		if i+2 == len(l.data) || isContentWhitespace(l.data[i+2]) {
+			l.pos = i + 2
+			return l.data[start:l.pos], nil
+		}
+	}
+	return nil, errors.New("inline image not terminated by EI")
+}
+
+// cmykToRGBOperands converts 4 numeric CMYK operands to RGB. The conversion is the one used by
+// PdfColorspaceDeviceCMYK.ColorToRGB. Returns false if the operands are not 4 numbers.
+func cmykToRGBOperands(operands [][]byte) ([][]byte, bool) {
+	if len(operands) != 4 {
+		return nil, false
+	}
+	var v [4]float64
+	for i, op := range operands {
+		f, err := strconv.ParseFloat(string(op), 64)
+		if err != nil {
+			return nil, false
+		}
+		v[i] = f
+	}
+	k := v[3]
+	rgb := make([][]byte, 3)
+	for i := 0; i < 3; i++ {
+		c := 1 - (v[i]*(1-k) + k)
+		c = math.Round(c*10000) / 10000
+		rgb[i] = []byte(strconv.FormatFloat(c, 'f', -1, 64))
+	}
+	return rgb, true
+}
+
+// convertContentCMYK rewrites DeviceCMYK color operators in content stream `data` to their
+// DeviceRGB equivalents. `cmykNames` contains the names of color space resources which are
+// DeviceCMYK and are converted to DeviceRGB by the caller. Returns the converted content and
+// whether it was changed.
+func convertContentCMYK(data []byte, cmykNames map[string]bool) ([]byte, bool, error) {
+	type colorState struct{ fill, stroke bool }
+	var (
+		l        = contentLexer{data: data}
+		out      bytes.Buffer
+		operands [][]byte
+		state    colorState
+		stack    []colorState
+		changed  bool
+		inImage  bool
+	)
+
+	isCMYKName := func(name []byte) bool {
+		return string(name) == "/DeviceCMYK" || cmykNames[string(name[1:])]
+	}
+	emit := func(op string, operands [][]byte) {
+		for _, o := range operands {
+			out.Write(o)
+			out.WriteByte(' ')
+		}
+		out.WriteString(op)
+		out.WriteByte('\n')
+	}
+
+	for {
+		tok, isOp, err := l.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+
+		op := string(tok)
+		switch op {
+		case "q":
+			stack = append(stack, state)
+		case "Q":
+			if n := len(stack); n > 0 {
+				state = stack[n-1]
+				stack = stack[:n-1]
+			}
+		case "k", "K":
+			if rgb, ok := cmykToRGBOperands(operands); ok {
+				operands = rgb
+				if op == "k" {
+					op = "rg"
+				} else {
+					op = "RG"
+				}
+				changed = true
+			}
+		case "cs", "CS":
+			isCMYK := len(operands) == 1 && len(operands[0]) > 1 && operands[0][0] == '/' && isCMYKName(operands[0])
+			if op == "cs" {
+				state.fill = isCMYK
+			} else {
+				state.stroke = isCMYK
+			}
+			if isCMYK && string(operands[0]) == "/DeviceCMYK" {
+				operands[0] = []byte("/DeviceRGB")
+				changed = true
+			}
+		case "sc", "scn":
+			if state.fill {
+				if rgb, ok := cmykToRGBOperands(operands); ok {
+					operands = rgb
+					changed = true
+				}
+			}
+		case "SC", "SCN":
+			if state.stroke {
+				if rgb, ok := cmykToRGBOperands(operands); ok {
+					operands = rgb
+					changed = true
+				}
+			}
+		case "BI":
+			inImage = true
+		case "ID":
+			if !inImage {
+				break
+			}
+			for i := 0; i+1 < len(operands); i += 2 {
+				key := string(operands[i])
+				if (key == "/CS" || key == "/ColorSpace") && len(operands[i+1]) > 1 && operands[i+1][0] == '/' {
+					name := operands[i+1]
+					if string(name) == "/CMYK" || isCMYKName(name) {
+						return nil, false, errInlineImageCMYK
+					}
+				}
+			}
+			imgData, err := l.inlineImageData()
+			if err != nil {
+				return nil, false, err
+			}
+			emit(op, operands)
+			out.Write(imgData)
+			out.WriteByte('\n')
+			operands = nil
+			inImage = false
+			continue
+		}
+		emit(op, operands)
+		operands = nil
+	}
+	if !changed {
+		return data, false, nil
+	}
+	return out.Bytes(), true, nil
+}
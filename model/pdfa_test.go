@@ -0,0 +1,180 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+// newPdfATestPage returns a page that shows text in Helvetica with a DeviceCMYK fill color.
+func newPdfATestPage(t *testing.T) *PdfPage {
+	font, err := NewStandard14Font(HelveticaName)
+	require.NoError(t, err)
+
+	page := NewPdfPage()
+	require.NoError(t, page.AddFont("F1", font.ToPdfObject()))
+	require.NoError(t, page.SetContentStreams([]string{
+		"0 0 0 1 k BT /F1 12 Tf 10 10 Td (k K 1 0 0 0 k) Tj ET\n/DeviceCMYK CS 1 0 0 0 SC 0 0 100 100 re S",
+	}, core.NewFlateEncoder()))
+	return page
+}
+
+func TestPdfAOutput(t *testing.T) {
+	replacement, err := NewPdfFontFromTTFFile("./testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+
+	w := NewPdfWriter()
+	require.NoError(t, w.AddPage(newPdfATestPage(t)))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("data.xml", []byte("<data/>"))))
+	w.SetPdfA(&PdfAOptions{
+		Conformance: PdfA3B,
+		Fonts:       map[string]*PdfFont{string(HelveticaName): replacement},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+
+	// XMP identification.
+	metadata, ok := core.GetStream(reader.catalog.Get("Metadata"))
+	require.True(t, ok)
+	require.Nil(t, metadata.Get("Filter"))
+	require.Contains(t, string(metadata.Stream), "<pdfaid:part>3</pdfaid:part>")
+	require.Contains(t, string(metadata.Stream), "<pdfaid:conformance>B</pdfaid:conformance>")
+
+	// Output intent.
+	intents, ok := core.GetArray(reader.catalog.Get("OutputIntents"))
+	require.True(t, ok)
+	intent, ok := core.GetDict(intents.Get(0))
+	require.True(t, ok)
+	s, _ := core.GetNameVal(intent.Get("S"))
+	require.Equal(t, "GTS_PDFA1", s)
+	_, ok = core.GetStream(intent.Get("DestOutputProfile"))
+	require.True(t, ok)
+
+	// File identifier.
+	trailer, err := reader.GetTrailer()
+	require.NoError(t, err)
+	ids, ok := core.GetArray(trailer.Get("ID"))
+	require.True(t, ok)
+	require.Equal(t, 2, ids.Len())
+
+	// Embedded file is associated with the document.
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, AFRelationshipUnspecified, files[0].Relationship)
+
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+
+	// Font is replaced with the embedded font.
+	fontObj, ok := page.Resources.GetFontByName("F1")
+	require.True(t, ok)
+	font, ok := core.GetDict(fontObj)
+	require.True(t, ok)
+	require.True(t, fontEmbedded(font))
+
+	// DeviceCMYK colors are converted.
+	content, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Contains(t, content, "0 0 0 rg")
+	require.Contains(t, content, "/DeviceRGB CS")
+	require.Contains(t, content, "0 1 1 SC")
+	require.Contains(t, content, "(k K 1 0 0 0 k) Tj")
+}
+
+func TestPdfAErrors(t *testing.T) {
+	write := func(conf PdfAConformance, page *PdfPage, setup func(w *PdfWriter)) error {
+		w := NewPdfWriter()
+		require.NoError(t, w.AddPage(page))
+		if setup != nil {
+			setup(&w)
+		}
+		w.SetPdfA(&PdfAOptions{Conformance: conf})
+		return w.Write(&bytes.Buffer{})
+	}
+	requirePdfAError := func(err error, reason string) {
+		pdfaErr, ok := err.(*PdfAError)
+		require.True(t, ok, "unexpected error: %v", err)
+		require.Equal(t, reason, pdfaErr.Reason)
+	}
+
+	// Non-embedded font without replacement.
+	err := write(PdfA2B, newPdfATestPage(t), nil)
+	requirePdfAError(err, "font Helvetica is not embedded")
+
+	// Transparency is only allowed in PDF/A-2 and later.
+	transparent := func() *PdfPage {
+		page := NewPdfPage()
+		gs := core.MakeDict()
+		gs.Set("ca", core.MakeFloat(0.5))
+		require.NoError(t, page.AddExtGState("GS0", gs))
+		require.NoError(t, page.SetContentStreams([]string{"/GS0 gs 0 0 10 10 re f"}, nil))
+		return page
+	}
+	requirePdfAError(write(PdfA1B, transparent(), nil), "constant opacity other than 1.0 is not allowed")
+	require.NoError(t, write(PdfA2B, transparent(), nil))
+
+	// LZW compression.
+	lzw := NewPdfPage()
+	lzwEncoder := core.NewLZWEncoder()
+	lzwEncoder.EarlyChange = 0
+	require.NoError(t, lzw.SetContentStreams([]string{"0 0 10 10 re f"}, lzwEncoder))
+	requirePdfAError(write(PdfA2B, lzw, nil), "LZW compression is not allowed")
+
+	// Encryption.
+	err = write(PdfA2B, NewPdfPage(), func(w *PdfWriter) {
+		require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+	})
+	requirePdfAError(err, "encryption is not allowed")
+
+	// Embedded files.
+	err = write(PdfA1B, NewPdfPage(), func(w *PdfWriter) {
+		require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("a.txt", []byte("a"))))
+	})
+	requirePdfAError(err, "embedded files are not allowed")
+	err = write(PdfA2B, NewPdfPage(), func(w *PdfWriter) {
+		require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("a.txt", []byte("a"))))
+	})
+	requirePdfAError(err, `embedded file "a.txt" is not a PDF/A document`)
+}
+
+func TestConvertContentCMYK(t *testing.T) {
+	content := "q /CS0 cs 0 0 0 0.5 scn Q 0 0 0 0.5 sc\n" +
+		"BI /W 1 /H 1 /BPC 8 /CS /G ID \x00EI\n" +
+		"% 1 0 0 0 k\n<6b> Tj"
+	out, changed, err := convertContentCMYK([]byte(content), map[string]bool{"CS0": true})
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "q\n/CS0 cs\n0.5 0.5 0.5 scn\nQ\n0 0 0 0.5 sc\n"+
+		"BI\n/W 1 /H 1 /BPC 8 /CS /G ID\n\x00EI\n<6b> Tj\n", string(out))
+
+	_, changed, err = convertContentCMYK([]byte("0 g 1 1 1 rg"), nil)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	_, _, err = convertContentCMYK([]byte("BI /W 1 /H 1 /BPC 8 /CS /CMYK ID \x00\x00\x00\x00 EI"), nil)
+	require.Equal(t, errInlineImageCMYK, err)
+}
+
+func TestPdfAForbidden(t *testing.T) {
+	require.True(t, PdfAForbidsAction("JavaScript"))
+	require.False(t, PdfAForbidsAction("URI"))
+
+	// File attachments are only allowed from PDF/A-2.
+	require.True(t, PdfAForbidsAnnotation("Movie", 2))
+	require.True(t, PdfAForbidsAnnotation("FileAttachment", 1))
+	require.False(t, PdfAForbidsAnnotation("FileAttachment", 2))
+	require.False(t, PdfAForbidsAnnotation("Link", 1))
+}
@@ -6,6 +6,8 @@
 package model
 
 import (
+	"crypto"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +17,7 @@ import (
 	"github.com/carmel/unipdf/common"
 	"github.com/carmel/unipdf/core"
 	"github.com/carmel/unipdf/core/security"
+	"github.com/carmel/unipdf/model/xmputil"
 )
 
 // PdfReader represents a PDF file reader. It is a frontend to the lower level parsing mechanism and provides
@@ -37,6 +40,9 @@ type PdfReader struct {
 	// than loading entire document into memory on load.
 	isLazy bool
 
+	// First page mode: only the first page of a linearized file is loaded.
+	firstPageOnly bool
+
 	// For tracking traversal (cache).
 	traversed map[core.PdfObject]struct{}
 	rs        io.ReadSeeker
@@ -67,8 +73,9 @@ func NewPdfReader(rs io.ReadSeeker) (*PdfReader, error) {
 		return nil, err
 	}
 
-	// Load pdf doc structure if not encrypted.
-	if !isEncrypted {
+	// Load pdf doc structure if not encrypted, or readable without
+	// authentication.
+	if !isEncrypted || !parser.IsAuthenticationRequired() {
 		err = pdfReader.loadStructure()
 		if err != nil {
 			return nil, err
@@ -110,8 +117,9 @@ func NewPdfReaderLazy(rs io.ReadSeeker) (*PdfReader, error) {
 		return nil, err
 	}
 
-	// Load pdf doc structure if not encrypted.
-	if !isEncrypted {
+	// Load pdf doc structure if not encrypted, or readable without
+	// authentication.
+	if !isEncrypted || !parser.IsAuthenticationRequired() {
 		err = pdfReader.loadStructure()
 		if err != nil {
 			return nil, err
@@ -121,6 +129,59 @@ func NewPdfReaderLazy(rs io.ReadSeeker) (*PdfReader, error) {
 	return pdfReader, nil
 }
 
+// NewPdfReaderFirstPage creates a new PdfReader for the first page of a linearized PDF file in
+// `rs`, in lazy-loading mode. Only the first page section of the file is read, so `rs` only needs
+// to contain the file data up to the end of the first page section, as given by
+// core.Linearization.FirstPageEnd. The page list contains only the first page. The total number
+// of pages is available from GetLinearization.
+// core.ErrNotLinearized is returned if the file is not linearized.
+func NewPdfReaderFirstPage(rs io.ReadSeeker) (*PdfReader, error) {
+	pdfReader := &PdfReader{
+		rs:            rs,
+		traversed:     map[core.PdfObject]struct{}{},
+		modelManager:  newModelManager(),
+		isLazy:        true,
+		firstPageOnly: true,
+	}
+
+	parser, err := core.NewParserFirstPage(rs)
+	if err != nil {
+		return nil, err
+	}
+	pdfReader.parser = parser
+
+	isEncrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the first page if not encrypted, or readable without
+	// authentication.
+	if !isEncrypted || !parser.IsAuthenticationRequired() {
+		err = pdfReader.loadStructure()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pdfReader, nil
+}
+
+// GetLinearization returns the linearization parameters of the file, or nil if the file is not
+// linearized (or no longer linearized due to incremental updates).
+func (r *PdfReader) GetLinearization() *core.Linearization {
+	return r.parser.GetLinearization()
+}
+
+// GetLinearizationHints returns the locations of the page and shared object sections of a
+// linearized file, as given by its hint tables.
+func (r *PdfReader) GetLinearizationHints() (*core.LinearizationHints, error) {
+	if r.parser.IsAuthenticationRequired() {
+		return nil, fmt.Errorf("file need to be decrypted first")
+	}
+	return r.parser.GetLinearizationHints()
+}
+
 // PdfVersion returns version of the PDF file.
 func (r *PdfReader) PdfVersion() core.Version {
 	return r.parser.PdfVersion()
@@ -140,6 +201,8 @@ func (r *PdfReader) GetEncryptionMethod() string {
 // Decrypt decrypts the PDF file with a specified password.  Also tries to
 // decrypt with an empty password.  Returns true if successful,
 // false otherwise.
+// Documents in which only the embedded files are encrypted are loaded without authentication,
+// decrypting them is only needed to read their embedded files.
 func (r *PdfReader) Decrypt(password []byte) (bool, error) {
 	success, err := r.parser.Decrypt(password)
 	if err != nil {
@@ -148,6 +211,35 @@ func (r *PdfReader) Decrypt(password []byte) (bool, error) {
 	if !success {
 		return false, nil
 	}
+	if r.catalog != nil {
+		// Loaded without authentication.
+		return true, nil
+	}
+
+	err = r.loadStructure()
+	if err != nil {
+		common.Log.Debug("ERROR: Fail to load structure (%s)", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DecryptWithCertificate decrypts the PDF file protected by the public-key security handler (Adobe.PubSec)
+// using the certificate of one of the recipients and the corresponding private key.
+// Returns true if successful, false if the certificate is not among the document recipients.
+func (r *PdfReader) DecryptWithCertificate(cert *x509.Certificate, pkey crypto.PrivateKey) (bool, error) {
+	success, err := r.parser.DecryptWithCertificate(cert, pkey)
+	if err != nil {
+		return false, err
+	}
+	if !success {
+		return false, nil
+	}
+	if r.catalog != nil {
+		// Loaded without authentication.
+		return true, nil
+	}
 
 	err = r.loadStructure()
 	if err != nil {
@@ -158,6 +250,16 @@ func (r *PdfReader) Decrypt(password []byte) (bool, error) {
 	return true, nil
 }
 
+// GetAccessPermissions returns permissions granted after a successful decryption.
+// Full permissions are returned for documents that are not encrypted.
+func (r *PdfReader) GetAccessPermissions() security.Permissions {
+	crypter := r.parser.GetCrypter()
+	if crypter == nil {
+		return security.PermOwner
+	}
+	return crypter.GetAccessPermissions()
+}
+
 // CheckAccessRights checks access rights and permissions for a specified password.  If either user/owner
 // password is specified,  full rights are granted, otherwise the access rights are specified by the
 // Permissions flag.
@@ -171,7 +273,7 @@ func (r *PdfReader) CheckAccessRights(password []byte) (bool, security.Permissio
 
 // Loads the structure of the pdf file: pages, outlines, etc.
 func (r *PdfReader) loadStructure() error {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
+	if r.parser.IsAuthenticationRequired() {
 		return fmt.Errorf("file need to be decrypted first")
 	}
 
@@ -179,6 +281,9 @@ func (r *PdfReader) loadStructure() error {
 	if trailerDict == nil {
 		return fmt.Errorf("missing trailer")
 	}
+	if r.firstPageOnly {
+		return r.loadFirstPage(trailerDict)
+	}
 
 	// Catalog.
 	root, ok := trailerDict.Get("Root").(*core.PdfObjectReference)
@@ -266,8 +371,54 @@ func (r *PdfReader) loadStructure() error {
 	return nil
 }
 
+// loadFirstPage loads the catalog and the first page of a linearized file.
+func (r *PdfReader) loadFirstPage(trailerDict *core.PdfObjectDictionary) error {
+	lin := r.parser.GetLinearization()
+	if lin == nil {
+		return core.ErrNotLinearized
+	}
+
+	root, ok := trailerDict.Get("Root").(*core.PdfObjectReference)
+	if !ok {
+		return fmt.Errorf("invalid Root (trailer: %s)", trailerDict)
+	}
+	oc, err := r.parser.LookupByReference(*root)
+	if err != nil {
+		return err
+	}
+	catalog, ok := core.GetDict(oc)
+	if !ok {
+		return errors.New("invalid catalog")
+	}
+
+	obj, err := r.parser.LookupByNumber(int(lin.FirstPageObject))
+	if err != nil {
+		return err
+	}
+	pageObj, ok := obj.(*core.PdfIndirectObject)
+	if !ok {
+		return errors.New("first page object invalid")
+	}
+	pageDict, ok := pageObj.PdfObject.(*core.PdfObjectDictionary)
+	if !ok {
+		return errors.New("first page object invalid")
+	}
+	page, err := r.newPdfPageFromDict(pageDict)
+	if err != nil {
+		return err
+	}
+	page.setContainer(pageObj)
+
+	r.root = root
+	r.catalog = catalog
+	r.pageCount = lin.NumPages
+	r.pageList = []*core.PdfIndirectObject{pageObj}
+	r.PageList = []*PdfPage{page}
+	return nil
+}
+
 func (r *PdfReader) loadOutlines() (*PdfOutlineTreeNode, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
+	if r.parser.IsAuthenticationRequired() {
 		return nil, fmt.Errorf("file need to be decrypted first")
 	}
 
@@ -619,7 +770,7 @@ func (r *PdfReader) AcroFormNeedsRepair() (bool, error) {
 
 // loadForms loads the AcroForm.
 func (r *PdfReader) loadForms() (*PdfAcroForm, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
+	if r.parser.IsAuthenticationRequired() {
 		return nil, fmt.Errorf("file need to be decrypted first")
 	}
 
@@ -774,7 +925,7 @@ func (r *PdfReader) buildPageList(node *core.PdfIndirectObject, parent *core.Pdf
 
 // GetNumPages returns the number of pages in the document.
 func (r *PdfReader) GetNumPages() (int, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
+	if r.parser.IsAuthenticationRequired() {
 		return 0, fmt.Errorf("file need to be decrypted first")
 	}
 	return len(r.pageList), nil
@@ -822,7 +973,7 @@ func (r *PdfReader) PageFromIndirectObject(ind *core.PdfIndirectObject) (*PdfPag
 
 // GetPage returns the PdfPage model for the specified page number.
 func (r *PdfReader) GetPage(pageNumber int) (*PdfPage, error) {
-	if r.parser.GetCrypter() != nil && !r.parser.IsAuthenticated() {
+	if r.parser.IsAuthenticationRequired() {
 		return nil, fmt.Errorf("file needs to be decrypted first")
 	}
 	if len(r.pageList) < pageNumber {
@@ -894,6 +1045,60 @@ func (r *PdfReader) GetPageLabels() (core.PdfObject, error) {
 	return obj, nil
 }
 
+// GetRevisions returns the revisions of an incrementally updated PDF file, oldest first.
+func (r *PdfReader) GetRevisions() ([]*core.Revision, error) {
+	return r.parser.GetRevisions()
+}
+
+// GetRevision returns a reader of the document as it was at revision `index` (see GetRevisions),
+// e.g. the revision covered by a signature. The reader is lazy-loading if `r` is. It reads from
+// the same source as `r`, so they should not be used concurrently. Encrypted documents need to be
+// decrypted separately.
+func (r *PdfReader) GetRevision(index int) (*PdfReader, error) {
+	rs, err := r.parser.RevisionReader(index)
+	if err != nil {
+		return nil, err
+	}
+	if r.isLazy {
+		return NewPdfReaderLazy(rs)
+	}
+	return NewPdfReader(rs)
+}
+
+// CompareRevisions returns the objects added, changed or removed in revision `to` relative to
+// revision `from`.
+func (r *PdfReader) CompareRevisions(from, to int) (*core.RevisionChanges, error) {
+	return r.parser.CompareRevisions(from, to)
+}
+
+// GetPdfInfo returns the document information dictionary of the PDF. An empty PdfInfo is
+// returned if the trailer has no Info entry.
+func (r *PdfReader) GetPdfInfo() (*PdfInfo, error) {
+	trailer, err := r.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	obj := trailer.Get("Info")
+	if core.ResolveReference(obj) == nil {
+		return &PdfInfo{}, nil
+	}
+	return NewPdfInfoFromObject(obj)
+}
+
+// GetXMP returns the XMP metadata of the catalog Metadata stream, or nil if the document has no
+// metadata stream.
+func (r *PdfReader) GetXMP() (*xmputil.Packet, error) {
+	stream, ok := core.GetStream(r.catalog.Get("Metadata"))
+	if !ok {
+		return nil, nil
+	}
+	data, err := core.DecodeStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	return xmputil.Parse(data)
+}
+
 // Inspect inspects the object types, subtypes and content in the PDF file returning a map of
 // object type to number of instances of each.
 func (r *PdfReader) Inspect() (map[string]int, error) {
@@ -0,0 +1,74 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"runtime"
+	"sync"
+)
+
+// ForEachPage calls `fn` for each page of the reader, with page numbers starting at 1, from up to
+// `workers` goroutines running concurrently (runtime.NumCPU() if `workers` <= 0). Processing
+// stops at the first error returned by `fn`, which is returned.
+//
+// The pages can be processed concurrently as the reader loads objects safely from concurrent
+// goroutines. `fn` must not modify objects shared between pages, such as fonts and images.
+// The loading of the objects from the file is serialized by the parser, which reads the file
+// through a single io.ReadSeeker: only the processing of the loaded objects (decoding streams,
+// parsing content streams, etc.) runs in parallel, and documents whose processing is dominated
+// by reading their objects are not processed faster with more workers.
+func (r *PdfReader) ForEachPage(workers int, fn func(pageNum int, page *PdfPage) error) error {
+	numPages, err := r.GetNumPages()
+	if err != nil {
+		return err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > numPages {
+		workers = numPages
+	}
+
+	pageNums := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	failed := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return firstErr != nil
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pageNum := range pageNums {
+				if failed() {
+					continue
+				}
+				page, err := r.GetPage(pageNum)
+				if err == nil {
+					err = fn(pageNum, page)
+				}
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for pageNum := 1; pageNum <= numPages && !failed(); pageNum++ {
+		pageNums <- pageNum
+	}
+	close(pageNums)
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,181 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderForEachPage(t *testing.T) {
+	data := writeStreamingTestFile(t, 20, true)
+	for _, lazy := range []bool{false, true} {
+		newReader := NewPdfReader
+		if lazy {
+			newReader = NewPdfReaderLazy
+		}
+		reader, err := newReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		contents := make([]string, 20)
+		err = reader.ForEachPage(4, func(pageNum int, page *PdfPage) error {
+			content, err := page.GetAllContentStreams()
+			if err != nil {
+				return err
+			}
+			// The shared font is loaded concurrently.
+			if _, ok := page.Resources.GetFontByName("F1"); !ok {
+				return errors.New("font not found")
+			}
+			contents[pageNum-1] = content
+			return nil
+		})
+		require.NoError(t, err)
+		for i, content := range contents {
+			require.Contains(t, content, fmt.Sprintf("(Page %d)", i+1))
+		}
+
+		// Processing stops at the first error.
+		var calls int32
+		errFail := errors.New("fail")
+		err = reader.ForEachPage(2, func(pageNum int, page *PdfPage) error {
+			atomic.AddInt32(&calls, 1)
+			return errFail
+		})
+		require.Equal(t, errFail, err)
+		require.Less(t, int(atomic.LoadInt32(&calls)), 20)
+	}
+}
+
+// writeObjectStreamTestFile writes a document with `numPages` pages whose page objects are stored
+// in an object stream, decoded with a PNG predictor whose /Columns is an indirect object.
+func writeObjectStreamTestFile(t *testing.T, numPages int) []byte {
+	const columns = 8
+	// Objects: 1 catalog, 2 page tree, 3 font, 4 columns, 5 object stream, 6 xref stream,
+	// 7.. page contents followed by the pages (in the object stream).
+	contentNum := func(i int) int { return 7 + i }
+	pageNum := func(i int) int { return 7 + numPages + i }
+
+	var header, body bytes.Buffer
+	for i := 0; i < numPages; i++ {
+		fmt.Fprintf(&header, "%d %d ", pageNum(i), body.Len())
+		fmt.Fprintf(&body, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] "+
+			"/Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >> ", contentNum(i))
+	}
+	first := header.Len()
+	data := append(header.Bytes(), body.Bytes()...)
+	for len(data)%columns != 0 {
+		data = append(data, ' ')
+	}
+	var encoded bytes.Buffer
+	zw := zlib.NewWriter(&encoded)
+	for i := 0; i < len(data); i += columns {
+		// PNG None filter for each row.
+		_, err := zw.Write(append([]byte{0}, data[i:i+columns]...))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	var buf bytes.Buffer
+	offsets := map[int]int{}
+	startObj := func(num int) {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n", num)
+	}
+	buf.WriteString("%PDF-1.7\n")
+	startObj(1)
+	buf.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	startObj(2)
+	buf.WriteString("<< /Type /Pages /Kids [")
+	for i := 0; i < numPages; i++ {
+		fmt.Fprintf(&buf, " %d 0 R", pageNum(i))
+	}
+	fmt.Fprintf(&buf, " ] /Count %d >>\nendobj\n", numPages)
+	startObj(3)
+	buf.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	startObj(4)
+	fmt.Fprintf(&buf, "%d\nendobj\n", columns)
+	startObj(5)
+	fmt.Fprintf(&buf, "<< /Type /ObjStm /N %d /First %d /Filter /FlateDecode "+
+		"/DecodeParms << /Predictor 12 /Columns 4 0 R >> /Length %d >>\nstream\n",
+		numPages, first, encoded.Len())
+	buf.Write(encoded.Bytes())
+	buf.WriteString("\nendstream\nendobj\n")
+	for i := 0; i < numPages; i++ {
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (Page %d) Tj ET", i+1)
+		startObj(contentNum(i))
+		fmt.Fprintf(&buf, "<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(content), content)
+	}
+
+	// Cross-reference stream with the offsets of the objects and the indexes of the pages in the
+	// object stream.
+	size := pageNum(numPages)
+	var xref bytes.Buffer
+	for num := 0; num < size; num++ {
+		switch {
+		case num == 0:
+			xref.Write([]byte{0, 0, 0, 0, 0, 0xff, 0xff})
+		case num >= pageNum(0):
+			xref.Write([]byte{2, 0, 0, 0, 5, 0, byte(num - pageNum(0))})
+		default:
+			if num == 6 {
+				offsets[6] = buf.Len()
+			}
+			off := offsets[num]
+			xref.Write([]byte{1, byte(off >> 24), byte(off >> 16), byte(off >> 8), byte(off), 0, 0})
+		}
+	}
+	xrefOffset := offsets[6]
+	fmt.Fprintf(&buf, "6 0 obj\n<< /Type /XRef /Size %d /W [1 4 2] /Root 1 0 R /Length %d >>\nstream\n",
+		size, xref.Len())
+	buf.Write(xref.Bytes())
+	fmt.Fprintf(&buf, "\nendstream\nendobj\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return buf.Bytes()
+}
+
+func TestReaderForEachPageIndirectDecodeParms(t *testing.T) {
+	const numPages = 20
+	data := writeObjectStreamTestFile(t, numPages)
+	for _, lazy := range []bool{false, true} {
+		newReader := NewPdfReader
+		if lazy {
+			newReader = NewPdfReaderLazy
+		}
+
+		// The object stream is decoded while loading the pages concurrently, with the lookup
+		// lock held: its nested indirect decode parameters are resolved beforehand.
+		contents := make([]string, numPages)
+		done := make(chan error, 1)
+		go func() {
+			reader, err := newReader(bytes.NewReader(data))
+			if err != nil {
+				done <- err
+				return
+			}
+			done <- reader.ForEachPage(4, func(pageNum int, page *PdfPage) error {
+				content, err := page.GetAllContentStreams()
+				contents[pageNum-1] = content
+				return err
+			})
+		}()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("deadlock reading the pages")
+		}
+		for i, content := range contents {
+			require.Contains(t, content, fmt.Sprintf("(Page %d)", i+1))
+		}
+	}
+}
@@ -47,3 +47,52 @@ func TestReaderLazy(t *testing.T) {
 	err = writer.Write(&buf)
 	require.NoError(t, err)
 }
+
+func TestReaderRevisions(t *testing.T) {
+	// Linearized files have two xref sections in a single revision.
+	data := writeLinearizedTestFile(t, true, false)
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	revisions, err := reader.GetRevisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	require.Equal(t, int64(len(data)), revisions[0].End)
+
+	appender, err := NewPdfAppender(reader)
+	require.NoError(t, err)
+	appender.RemovePage(3)
+	appender.SetDocInfo(&PdfInfo{Title: core.MakeString("Updated")})
+	var buf bytes.Buffer
+	require.NoError(t, appender.Write(&buf))
+
+	updated, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	revisions, err = updated.GetRevisions()
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, int64(len(data)), revisions[1].Offset)
+	require.Equal(t, int64(buf.Len()), revisions[1].End)
+
+	numPages, err := updated.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+
+	original, err := updated.GetRevision(0)
+	require.NoError(t, err)
+	numPages, err = original.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 3, numPages)
+	info, err := original.GetPdfInfo()
+	require.NoError(t, err)
+	require.Nil(t, info.Title)
+
+	changes, err := updated.CompareRevisions(0, 1)
+	require.NoError(t, err)
+	require.NotEmpty(t, changes.Added)
+	pages := updated.pagesContainer.ObjectNumber
+	require.Contains(t, changes.Changed, int(pages))
+	require.Empty(t, changes.Removed)
+
+	_, err = updated.GetRevision(2)
+	require.Equal(t, core.ErrRangeError, err)
+}
@@ -0,0 +1,256 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"errors"
+	"io"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// RepairOptions specifies the repairs attempted by a reader created with NewPdfReaderWithRepair.
+type RepairOptions struct {
+	// Repairs made by the parser (cross-reference table, trailer, streams, object streams).
+	core.RepairOptions
+
+	// RebuildPageTree reconstructs the catalog and the page tree if they cannot be loaded,
+	// from the readable parts of the page tree and the orphaned page objects of the file.
+	RebuildPageTree bool
+}
+
+// DefaultRepairOptions returns repair options with all repairs enabled.
+func DefaultRepairOptions() *RepairOptions {
+	return &RepairOptions{
+		RepairOptions:   *core.DefaultRepairOptions(),
+		RebuildPageTree: true,
+	}
+}
+
+// NewPdfReaderWithRepair returns a new PdfReader for `rs` like NewPdfReader, repairing the damage
+// found in the file as specified by `opts` (all repairs if nil), so that corrupted files can be
+// processed rather than rejected. The repairs made are listed by GetRepairReport.
+//
+// Repairs are made in memory only: the content of damaged streams may be incomplete, and pages
+// which cannot be found are missing from the page list.
+func NewPdfReaderWithRepair(rs io.ReadSeeker, opts *RepairOptions) (*PdfReader, error) {
+	if opts == nil {
+		opts = DefaultRepairOptions()
+	}
+	pdfReader := &PdfReader{
+		rs:           rs,
+		traversed:    map[core.PdfObject]struct{}{},
+		modelManager: newModelManager(),
+		isLazy:       false,
+	}
+
+	parser, err := core.NewParserWithRepair(rs, &opts.RepairOptions)
+	if err != nil {
+		return nil, err
+	}
+	pdfReader.parser = parser
+
+	isEncrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return nil, err
+	}
+	if isEncrypted {
+		return pdfReader, nil
+	}
+
+	err = pdfReader.loadStructure()
+	if err == nil && len(pdfReader.pageList) > 0 {
+		return pdfReader, nil
+	}
+	if !opts.RebuildPageTree {
+		if err == nil {
+			return pdfReader, nil
+		}
+		return nil, err
+	}
+	if err != nil {
+		common.Log.Debug("ERROR: Failed to load structure, rebuilding page tree: %v", err)
+	}
+	if err := pdfReader.repairStructure(); err != nil {
+		return nil, err
+	}
+	return pdfReader, nil
+}
+
+// GetRepairReport returns the report of the repairs made while reading the file, nil if the reader
+// has not been created with NewPdfReaderWithRepair.
+func (r *PdfReader) GetRepairReport() *core.RepairReport {
+	return r.parser.RepairReport()
+}
+
+// repairStructure loads the structure of a file whose catalog or page tree is damaged. The catalog
+// is reconstructed if missing, and the page tree is replaced by a flat page tree made of the pages
+// reachable from the original tree followed by the orphaned page objects.
+func (r *PdfReader) repairStructure() error {
+	report := r.parser.RepairReport()
+	trailerDict := r.parser.GetTrailer()
+	if trailerDict == nil {
+		return errors.New("missing trailer")
+	}
+	r.pageList = []*core.PdfIndirectObject{}
+	r.PageList = []*PdfPage{}
+
+	// Catalog.
+	var catalogObj *core.PdfIndirectObject
+	var catalog *core.PdfObjectDictionary
+	if root, ok := trailerDict.Get("Root").(*core.PdfObjectReference); ok {
+		if obj, err := r.parser.LookupByReference(*root); err == nil {
+			if ind, ok := obj.(*core.PdfIndirectObject); ok {
+				if dict, ok := ind.PdfObject.(*core.PdfObjectDictionary); ok {
+					catalogObj, catalog = ind, dict
+				}
+			}
+		}
+	}
+	if catalog == nil {
+		catalog = core.MakeDict()
+		catalog.Set("Type", core.MakeName("Catalog"))
+		catalogObj = core.MakeIndirectObject(catalog)
+		report.Add(core.RepairCatalog, 0, "catalog reconstructed")
+	}
+
+	// Pages reachable from the original page tree, then orphaned pages.
+	var pageObjs []*core.PdfIndirectObject
+	found := map[*core.PdfIndirectObject]struct{}{}
+	var walk func(obj core.PdfObject, visited map[core.PdfObject]struct{})
+	walk = func(obj core.PdfObject, visited map[core.PdfObject]struct{}) {
+		node, ok := core.GetIndirect(obj)
+		if !ok {
+			return
+		}
+		if _, ok := visited[node]; ok {
+			return
+		}
+		visited[node] = struct{}{}
+		dict, ok := node.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			return
+		}
+		if name, _ := core.GetNameVal(dict.Get("Type")); name == "Page" {
+			if _, ok := found[node]; !ok {
+				found[node] = struct{}{}
+				pageObjs = append(pageObjs, node)
+			}
+			return
+		}
+		if kids, ok := core.GetArray(dict.Get("Kids")); ok {
+			for _, kid := range kids.Elements() {
+				walk(kid, visited)
+			}
+		}
+	}
+	walk(catalog.Get("Pages"), map[core.PdfObject]struct{}{})
+
+	numTree := len(pageObjs)
+	for _, num := range r.parser.GetObjectNums() {
+		obj, err := r.parser.LookupByNumber(num)
+		if err != nil {
+			continue
+		}
+		ind, ok := obj.(*core.PdfIndirectObject)
+		if !ok {
+			continue
+		}
+		dict, ok := ind.PdfObject.(*core.PdfObjectDictionary)
+		if !ok {
+			continue
+		}
+		if name, _ := core.GetNameVal(dict.Get("Type")); name != "Page" {
+			continue
+		}
+		if _, ok := found[ind]; ok {
+			continue
+		}
+		found[ind] = struct{}{}
+		pageObjs = append(pageObjs, ind)
+		report.Add(core.RepairPageTree, num, "orphaned page recovered")
+	}
+	if len(pageObjs) == 0 {
+		return errors.New("no pages found")
+	}
+
+	// The new page tree is flat, the attributes inherited from the original tree are copied to
+	// the pages.
+	kids := core.MakeArray()
+	pages := core.MakeDict()
+	pages.Set("Type", core.MakeName("Pages"))
+	pages.Set("Kids", kids)
+	pages.Set("Count", core.MakeInteger(int64(len(pageObjs))))
+	pagesObj := core.MakeIndirectObject(pages)
+	for _, pageObj := range pageObjs {
+		dict := pageObj.PdfObject.(*core.PdfObjectDictionary)
+		inheritPageAttributes(dict)
+		dict.Set("Parent", pagesObj)
+		kids.Append(pageObj)
+	}
+	catalog.Set("Pages", pagesObj)
+	report.Add(core.RepairPageTree, 0, "page tree rebuilt with %d pages (%d from the original tree)", len(pageObjs), numTree)
+
+	for _, pageObj := range pageObjs {
+		if !r.isLazy {
+			if err := r.traverseObjectData(pageObj); err != nil {
+				common.Log.Debug("ERROR: Failed to traverse page %d: %v", pageObj.ObjectNumber, err)
+			}
+		}
+		page, err := r.newPdfPageFromDict(pageObj.PdfObject.(*core.PdfObjectDictionary))
+		if err != nil {
+			common.Log.Debug("ERROR: Skipping invalid page %d: %v", pageObj.ObjectNumber, err)
+			continue
+		}
+		page.setContainer(pageObj)
+		r.pageList = append(r.pageList, pageObj)
+		r.PageList = append(r.PageList, page)
+	}
+	if len(r.pageList) == 0 {
+		return errors.New("no valid pages found")
+	}
+
+	r.root = catalogObj
+	r.catalog = catalog
+	r.pages = pages
+	r.pagesContainer = pagesObj
+	r.pageCount = len(r.pageList)
+
+	var err error
+	if r.outlineTree, err = r.loadOutlines(); err != nil {
+		common.Log.Debug("ERROR: Ignoring invalid outlines: %v", err)
+		r.outlineTree = nil
+	}
+	if r.AcroForm, err = r.loadForms(); err != nil {
+		common.Log.Debug("ERROR: Ignoring invalid forms: %v", err)
+		r.AcroForm = nil
+	}
+	return nil
+}
+
+// inheritPageAttributes copies the inheritable attributes of the page with dictionary `dict` that
+// are defined by the ancestors of the page to the page dictionary.
+func inheritPageAttributes(dict *core.PdfObjectDictionary) {
+	visited := map[core.PdfObject]struct{}{}
+	node := dict.Get("Parent")
+	for node != nil {
+		if _, ok := visited[node]; ok {
+			break
+		}
+		visited[node] = struct{}{}
+		parent, ok := core.GetDict(node)
+		if !ok {
+			break
+		}
+		for _, key := range []core.PdfObjectName{"Resources", "MediaBox", "CropBox", "Rotate"} {
+			if dict.Get(key) == nil && parent.Get(key) != nil {
+				dict.Set(key, parent.Get(key))
+			}
+		}
+		node = parent.Get("Parent")
+	}
+}
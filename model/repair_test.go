@@ -0,0 +1,80 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+func TestReaderRepairPageTree(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := map[int]int{}
+	for num, obj := range []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		// Kid 9 does not exist.
+		"<< /Type /Pages /Kids [3 0 R 9 0 R] /Count 2 /MediaBox [0 0 200 100] >>",
+		"<< /Type /Page /Parent 2 0 R /Contents 5 0 R >>",
+		// Orphaned page.
+		"<< /Type /Page /MediaBox [0 0 300 300] /Contents 6 0 R >>",
+		"<< /Length 9 >>\nstream\n(Page 1) \nendstream",
+		"<< /Length 9 >>\nstream\n(Page 2) \nendstream",
+	} {
+		offsets[num+1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num+1, obj)
+	}
+	xref := buf.Len()
+	buf.WriteString("xref\n0 7\n0000000000 65535 f \n")
+	for num := 1; num <= 6; num++ {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offsets[num])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size 7 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", xref)
+
+	_, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.Error(t, err)
+
+	opts := DefaultRepairOptions()
+	opts.RebuildPageTree = false
+	_, err = NewPdfReaderWithRepair(bytes.NewReader(buf.Bytes()), opts)
+	require.Error(t, err)
+
+	reader, err := NewPdfReaderWithRepair(bytes.NewReader(buf.Bytes()), nil)
+	require.NoError(t, err)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 2, numPages)
+
+	for i, width := range []float64{200, 300} {
+		page, err := reader.GetPage(i + 1)
+		require.NoError(t, err)
+		mbox, err := page.GetMediaBox()
+		require.NoError(t, err)
+		require.Equal(t, width, mbox.Width())
+		content, err := page.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Contains(t, content, fmt.Sprintf("(Page %d)", i+1))
+	}
+
+	report := reader.GetRepairReport()
+	require.True(t, report.Repaired())
+	var types []core.RepairType
+	for _, repair := range report.Repairs {
+		types = append(types, repair.Type)
+	}
+	require.Equal(t, []core.RepairType{core.RepairPageTree, core.RepairPageTree}, types)
+	require.Equal(t, 4, report.Repairs[0].ObjectNumber)
+
+	// Readers created without repair have no report.
+	reader, err = NewPdfReader(bytes.NewReader(writeStreamingTestFile(t, 2, false)))
+	require.NoError(t, err)
+	require.Nil(t, reader.GetRepairReport())
+}
@@ -0,0 +1,171 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/contentstream"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// colorUsage is a use of a device color space by a content stream or image.
+type colorUsage struct {
+	// space is DeviceGray, DeviceRGB or DeviceCMYK.
+	space string
+	// object is the number of the page or image object using the color space.
+	object int64
+}
+
+// colorScanner collects the device color spaces used by the content of a page.
+type colorScanner struct {
+	usage []colorUsage
+	seen  map[colorUsage]bool
+	forms map[*core.PdfObjectStream]bool
+}
+
+// scanColorUsage returns the device color spaces used on `page`, including by images and
+// form XObjects painted on the page.
+func scanColorUsage(page *model.PdfPage) ([]colorUsage, error) {
+	s := &colorScanner{
+		seen:  map[colorUsage]bool{},
+		forms: map[*core.PdfObjectStream]bool{},
+	}
+	content, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+	var resources *core.PdfObjectDictionary
+	if page.Resources != nil {
+		resources, _ = core.GetDict(page.Resources.GetContainingPdfObject())
+	}
+	s.scan(content, resources, objectNumber(page.GetPageAsIndirectObject()))
+	return s.usage, nil
+}
+
+func (s *colorScanner) add(space string, object int64) {
+	u := colorUsage{space: space, object: object}
+	if !s.seen[u] {
+		s.seen[u] = true
+		s.usage = append(s.usage, u)
+	}
+}
+
+// scan collects the color spaces used by content stream `content` of object `object`.
+func (s *colorScanner) scan(content string, resources *core.PdfObjectDictionary, object int64) {
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		common.Log.Debug("ERROR: unable to parse content stream of object %d: %v", object, err)
+		return
+	}
+	resource := func(category core.PdfObjectName, name core.PdfObject) core.PdfObject {
+		n, ok := core.GetName(name)
+		if !ok || resources == nil {
+			return nil
+		}
+		dict, ok := core.GetDict(resources.Get(category))
+		if !ok {
+			return nil
+		}
+		return dict.Get(*n)
+	}
+
+	for _, op := range *ops {
+		switch op.Operand {
+		case "g", "G":
+			s.add("DeviceGray", object)
+		case "rg", "RG":
+			s.add("DeviceRGB", object)
+		case "k", "K":
+			s.add("DeviceCMYK", object)
+		case "cs", "CS":
+			if len(op.Params) == 1 {
+				s.addColorSpace(op.Params[0], resources, object, 0)
+			}
+		case "BI":
+			if len(op.Params) == 1 {
+				if img, ok := op.Params[0].(*contentstream.ContentStreamInlineImage); ok && img.ColorSpace != nil {
+					s.addColorSpace(img.ColorSpace, resources, object, 0)
+				}
+			}
+		case "sh":
+			if len(op.Params) == 1 {
+				if shading, ok := core.GetDict(resource("Shading", op.Params[0])); ok {
+					s.addColorSpace(shading.Get("ColorSpace"), resources, object, 0)
+				}
+			}
+		case "Do":
+			if len(op.Params) != 1 {
+				continue
+			}
+			ref := resource("XObject", op.Params[0])
+			xobj, ok := core.GetStream(ref)
+			if !ok {
+				continue
+			}
+			switch subtype, _ := core.GetNameVal(xobj.Get("Subtype")); subtype {
+			case "Image":
+				if mask, _ := core.GetBoolVal(xobj.Get("ImageMask")); !mask {
+					s.addColorSpace(xobj.Get("ColorSpace"), nil, objectNumber(ref), 0)
+				}
+			case "Form":
+				if s.forms[xobj] {
+					continue
+				}
+				s.forms[xobj] = true
+				data, err := core.DecodeStream(xobj)
+				if err != nil {
+					common.Log.Debug("ERROR: unable to decode form XObject: %v", err)
+					continue
+				}
+				formResources, ok := core.GetDict(xobj.Get("Resources"))
+				if !ok {
+					formResources = resources
+				}
+				s.scan(string(data), formResources, objectNumber(ref))
+			}
+		}
+	}
+}
+
+// addColorSpace adds the device color spaces of color space `cs`, which can be a name of a
+// color space resource.
+func (s *colorScanner) addColorSpace(cs core.PdfObject, resources *core.PdfObjectDictionary, object int64, depth int) {
+	if depth > 8 {
+		return
+	}
+	switch t := core.TraceToDirectObject(cs).(type) {
+	case *core.PdfObjectName:
+		switch *t {
+		case "DeviceGray", "G":
+			s.add("DeviceGray", object)
+		case "DeviceRGB", "RGB":
+			s.add("DeviceRGB", object)
+		case "DeviceCMYK", "CMYK":
+			s.add("DeviceCMYK", object)
+		case "Pattern":
+		default:
+			if resources == nil {
+				return
+			}
+			if colorspaces, ok := core.GetDict(resources.Get("ColorSpace")); ok {
+				s.addColorSpace(colorspaces.Get(*t), nil, object, depth+1)
+			}
+		}
+	case *core.PdfObjectArray:
+		family, _ := core.GetNameVal(t.Get(0))
+		switch family {
+		case "Indexed", "I", "Pattern":
+			if t.Len() > 1 {
+				s.addColorSpace(t.Get(1), resources, object, depth+1)
+			}
+		case "Separation", "DeviceN":
+			if t.Len() > 2 {
+				s.addColorSpace(t.Get(2), resources, object, depth+1)
+			}
+		}
+	}
+}
@@ -0,0 +1,13 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package validator checks PDF documents for conformance with PDF/A-1, PDF/A-2 and PDF/A-3
+// (ISO 19005, level B requirements) and performs basic PDF/UA-1 (ISO 14289-1) checks.
+//
+// Validation results are machine-readable: each violation carries the profile, the rule (the
+// clause of the standard), and the object number and page number it relates to, if known.
+// The checks cover the most common causes of non-conformance and do not replace a full
+// conformance test suite.
+package validator
@@ -0,0 +1,177 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"math"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/internal/textencoding"
+	"github.com/carmel/unipdf/model"
+	"github.com/carmel/unipdf/model/internal/fonts"
+)
+
+// widthTolerance is the allowed difference between glyph widths in the font dictionary and in
+// the font program, in glyph space units (1/1000 em).
+const widthTolerance = 1
+
+// checkFont checks that a font program is embedded and that glyph widths are consistent with it.
+// Type 0 fonts are checked through their descendant CIDFonts, which are also font dictionaries.
+func (c *pdfaChecker) checkFont(font *core.PdfObjectDictionary, num int64) {
+	subtype, _ := core.GetNameVal(font.Get("Subtype"))
+	baseFont, _ := core.GetNameVal(font.Get("BaseFont"))
+	switch subtype {
+	case "Type1", "MMType1", "TrueType", "CIDFontType0", "CIDFontType2":
+	default:
+		return
+	}
+
+	descriptor, ok := core.GetDict(font.Get("FontDescriptor"))
+	if !ok {
+		c.addf(ruleFontEmbedding, num, 0, "font %s is not embedded", baseFont)
+		return
+	}
+	embedded := false
+	for _, key := range []core.PdfObjectName{"FontFile", "FontFile2", "FontFile3"} {
+		if _, ok := core.GetStream(descriptor.Get(key)); ok {
+			embedded = true
+		}
+	}
+	if !embedded {
+		c.addf(ruleFontEmbedding, num, 0, "font %s is not embedded", baseFont)
+		return
+	}
+
+	// Widths are only checked for TrueType font programs.
+	fontFile, ok := core.GetStream(descriptor.Get("FontFile2"))
+	if !ok {
+		return
+	}
+	ttf, err := fonts.NewFontFile2FromPdfObject(fontFile)
+	if err != nil {
+		c.addf(ruleFontEmbedding, objectNumber(descriptor.Get("FontFile2")), 0, "font program of %s is invalid: %v", baseFont, err)
+		return
+	}
+	if ttf.UnitsPerEm == 0 {
+		return
+	}
+	programWidth := func(gid fonts.GID) (float64, bool) {
+		if gid == 0 || int(gid) >= len(ttf.Widths) {
+			return 0, false
+		}
+		return float64(ttf.Widths[gid]) * 1000 / float64(ttf.UnitsPerEm), true
+	}
+
+	var code int
+	var width, expected float64
+	var mismatch bool
+	if subtype == "TrueType" {
+		code, width, expected, mismatch = checkSimpleWidths(font, descriptor, &ttf, programWidth)
+	} else {
+		code, width, expected, mismatch = checkCIDWidths(font, programWidth)
+	}
+	if mismatch {
+		c.addf(ruleFontWidths, num, 0, "width of glyph %d of font %s is %g, font program width is %g",
+			code, baseFont, width, expected)
+	}
+}
+
+// checkSimpleWidths compares the Widths array of a simple TrueType font with the font program.
+// Returns the first inconsistent character code with its widths.
+func checkSimpleWidths(font, descriptor *core.PdfObjectDictionary, ttf *fonts.TtfType,
+	programWidth func(fonts.GID) (float64, bool)) (int, float64, float64, bool) {
+	widths, ok := core.GetArray(font.Get("Widths"))
+	if !ok {
+		return 0, 0, 0, false
+	}
+	firstChar, _ := core.GetIntVal(font.Get("FirstChar"))
+
+	// Symbolic fonts without an encoding map codes directly to the (3,0) cmap.
+	flags, _ := core.GetIntVal(descriptor.Get("Flags"))
+	symbolic := flags&4 != 0 && font.Get("Encoding") == nil
+	var encoder textencoding.TextEncoder
+	if !symbolic {
+		pdfFont, err := model.NewPdfFontFromPdfObject(font)
+		if err != nil {
+			common.Log.Debug("ERROR: unable to load font: %v", err)
+			return 0, 0, 0, false
+		}
+		encoder = pdfFont.Encoder()
+	}
+
+	for i, obj := range widths.Elements() {
+		width, err := core.GetNumberAsFloat(core.TraceToDirectObject(obj))
+		if err != nil {
+			continue
+		}
+		code := firstChar + i
+		var gid fonts.GID
+		if symbolic {
+			if gid, ok = ttf.Chars[rune(0xF000+code)]; !ok {
+				gid = ttf.Chars[rune(code)]
+			}
+		} else if encoder != nil {
+			if r, ok := encoder.CharcodeToRune(textencoding.CharCode(code)); ok {
+				gid = ttf.Chars[r]
+			}
+		}
+		expected, ok := programWidth(gid)
+		if ok && math.Abs(width-expected) > widthTolerance {
+			return code, width, expected, true
+		}
+	}
+	return 0, 0, 0, false
+}
+
+// checkCIDWidths compares the W array of a CIDFontType2 font with the font program.
+// Only fonts with identity CID to GID mapping are checked.
+func checkCIDWidths(font *core.PdfObjectDictionary, programWidth func(fonts.GID) (float64, bool)) (int, float64, float64, bool) {
+	if name, ok := core.GetNameVal(font.Get("CIDToGIDMap")); font.Get("CIDToGIDMap") != nil && (!ok || name != "Identity") {
+		return 0, 0, 0, false
+	}
+	w, ok := core.GetArray(font.Get("W"))
+	if !ok {
+		return 0, 0, 0, false
+	}
+	check := func(cid int, obj core.PdfObject) (float64, float64, bool) {
+		width, err := core.GetNumberAsFloat(core.TraceToDirectObject(obj))
+		if err != nil {
+			return 0, 0, false
+		}
+		expected, ok := programWidth(fonts.GID(cid))
+		return width, expected, ok && math.Abs(width-expected) > widthTolerance
+	}
+
+	// W entries are either "c [w1 w2 ...]" or "cfirst clast w".
+	elems := w.Elements()
+	for i := 0; i < len(elems); {
+		first, ok := core.GetIntVal(elems[i])
+		if !ok || i+1 >= len(elems) {
+			break
+		}
+		if arr, ok := core.GetArray(elems[i+1]); ok {
+			for j, obj := range arr.Elements() {
+				if width, expected, bad := check(first+j, obj); bad {
+					return first + j, width, expected, true
+				}
+			}
+			i += 2
+			continue
+		}
+		last, ok := core.GetIntVal(elems[i+1])
+		if !ok || i+2 >= len(elems) {
+			break
+		}
+		for cid := first; cid <= last; cid++ {
+			if width, expected, bad := check(cid, elems[i+2]); bad {
+				return cid, width, expected, true
+			}
+		}
+		i += 3
+	}
+	return 0, 0, 0, false
+}
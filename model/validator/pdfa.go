@@ -0,0 +1,409 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/internal/icc"
+	"github.com/carmel/unipdf/model"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// pdfaRule is a PDF/A requirement, specified as the clauses of ISO 19005 parts 1, 2 and 3.
+type pdfaRule [3]string
+
+// PDF/A rules.
+var (
+	ruleFileTrailer       = pdfaRule{"6.1.3", "6.1.3", "6.1.3"}
+	ruleExternalStreams   = pdfaRule{"6.1.7", "6.1.7.1", "6.1.7.1"}
+	ruleFilters           = pdfaRule{"6.1.10", "6.1.7.2", "6.1.7.2"}
+	ruleEmbeddedFiles     = pdfaRule{"6.1.11", "6.8", "6.8"}
+	ruleOutputIntent      = pdfaRule{"6.2.2", "6.2.3", "6.2.3"}
+	ruleDeviceColorSpaces = pdfaRule{"6.2.3.3", "6.2.4.3", "6.2.4.3"}
+	ruleInterpolate       = pdfaRule{"6.2.4", "6.2.8", "6.2.8"}
+	ruleTransferFunctions = pdfaRule{"6.2.8", "6.2.5", "6.2.5"}
+	ruleFontEmbedding     = pdfaRule{"6.3.4", "6.2.11.4.1", "6.2.11.4.1"}
+	ruleFontWidths        = pdfaRule{"6.3.6", "6.2.11.5", "6.2.11.5"}
+	ruleTransparency      = pdfaRule{"6.4", "", ""}
+	ruleAnnotationTypes   = pdfaRule{"6.5.2", "6.3.1", "6.3.1"}
+	ruleAnnotationFlags   = pdfaRule{"6.5.3", "6.3.2", "6.3.2"}
+	ruleActions           = pdfaRule{"6.6.1", "6.6.1", "6.6.1"}
+	ruleMetadata          = pdfaRule{"6.7.2", "6.6.2.1", "6.6.2.1"}
+	ruleInfoConsistency   = pdfaRule{"6.7.3", "6.6.2.3", "6.6.2.3"}
+	ruleIdentification    = pdfaRule{"6.7.11", "6.6.4", "6.6.4"}
+)
+
+// pdfaChecker checks a document against a PDF/A profile.
+type pdfaChecker struct {
+	*validator
+	profile Profile
+	part    int
+	// intentComponents is the number of components of the PDF/A output intent profile,
+	// 0 if there is no output intent.
+	intentComponents int
+}
+
+func (c *pdfaChecker) addf(rule pdfaRule, object int64, page int, format string, args ...interface{}) {
+	c.validator.addf(c.profile, rule[c.part-1], object, page, format, args...)
+}
+
+// checkPdfA validates the document against a PDF/A profile.
+func (v *validator) checkPdfA(profile Profile) error {
+	c := &pdfaChecker{validator: v, profile: profile, part: profile.pdfaPart()}
+
+	if v.trailer.Get("Encrypt") != nil {
+		c.addf(ruleFileTrailer, 0, 0, "document is encrypted")
+	}
+	if ids, ok := core.GetArray(v.trailer.Get("ID")); !ok || ids.Len() != 2 {
+		c.addf(ruleFileTrailer, 0, 0, "file identifier is missing")
+	}
+
+	c.checkMetadata()
+	c.checkOutputIntents()
+	c.checkEmbeddedFiles()
+
+	for _, num := range v.reader.GetObjectNums() {
+		obj, err := v.reader.GetIndirectObjectByNumber(num)
+		if err != nil {
+			return err
+		}
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			c.visit(t.PdfObject, int64(num))
+		case *core.PdfObjectStream:
+			c.checkStream(t, int64(num))
+			c.visitDict(t.PdfObjectDictionary, int64(num), t)
+		}
+	}
+
+	for i, page := range v.reader.PageList {
+		if err := c.checkPage(page, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visit checks direct dictionaries and arrays contained in object `num`.
+func (c *pdfaChecker) visit(obj core.PdfObject, num int64) {
+	switch t := obj.(type) {
+	case *core.PdfObjectDictionary:
+		c.visitDict(t, num, nil)
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			c.visit(elem, num)
+		}
+	}
+}
+
+// visitDict checks a dictionary contained in object `num`. `stream` is the stream of the
+// dictionary, if any.
+func (c *pdfaChecker) visitDict(dict *core.PdfObjectDictionary, num int64, stream *core.PdfObjectStream) {
+	for _, key := range dict.Keys() {
+		c.visit(dict.Get(key), num)
+	}
+
+	typ, _ := core.GetNameVal(dict.Get("Type"))
+	subtype, _ := core.GetNameVal(dict.Get("Subtype"))
+	if s, ok := core.GetNameVal(dict.Get("S")); ok && model.PdfAForbidsAction(s) {
+		c.addf(ruleActions, num, 0, "%s action is not allowed", s)
+	}
+	if c.part == 1 {
+		if group, ok := core.GetDict(dict.Get("Group")); ok {
+			if s, _ := core.GetNameVal(group.Get("S")); s == "Transparency" {
+				c.addf(ruleTransparency, num, 0, "transparency group is not allowed")
+			}
+		}
+	}
+
+	switch {
+	case typ == "Font":
+		c.checkFont(dict, num)
+	case typ == "ExtGState":
+		c.checkExtGState(dict, num)
+	case stream != nil && subtype == "Image":
+		if interpolate, _ := core.GetBoolVal(dict.Get("Interpolate")); interpolate {
+			c.addf(ruleInterpolate, num, 0, "image interpolation is not allowed")
+		}
+		if c.part == 1 && dict.Get("SMask") != nil {
+			c.addf(ruleTransparency, num, 0, "image soft mask is not allowed")
+		}
+	}
+}
+
+// checkStream checks the filters of a stream.
+func (c *pdfaChecker) checkStream(stream *core.PdfObjectStream, num int64) {
+	for _, key := range []core.PdfObjectName{"F", "FFilter", "FDecodeParms"} {
+		if stream.Get(key) != nil {
+			c.addf(ruleExternalStreams, num, 0, "stream dictionary contains %s key", key)
+		}
+	}
+	var filters []string
+	switch t := core.TraceToDirectObject(stream.Get("Filter")).(type) {
+	case *core.PdfObjectName:
+		filters = append(filters, string(*t))
+	case *core.PdfObjectArray:
+		for _, elem := range t.Elements() {
+			if name, ok := core.GetNameVal(elem); ok {
+				filters = append(filters, name)
+			}
+		}
+	}
+	for _, filter := range filters {
+		switch filter {
+		case core.StreamEncodingFilterNameLZW, core.StreamEncodingFilterNameCrypt:
+			c.addf(ruleFilters, num, 0, "%s filter is not allowed", filter)
+		case core.StreamEncodingFilterNameJPX:
+			if c.part == 1 {
+				c.addf(ruleFilters, num, 0, "%s filter is not allowed", filter)
+			}
+		}
+	}
+}
+
+// checkExtGState checks a graphics state parameter dictionary.
+func (c *pdfaChecker) checkExtGState(gs *core.PdfObjectDictionary, num int64) {
+	if gs.Get("TR") != nil {
+		c.addf(ruleTransferFunctions, num, 0, "transfer function is not allowed")
+	}
+	if tr2 := gs.Get("TR2"); tr2 != nil {
+		if name, _ := core.GetNameVal(tr2); name != "Default" {
+			c.addf(ruleTransferFunctions, num, 0, "transfer function is not allowed")
+		}
+	}
+	if c.part != 1 {
+		return
+	}
+	if smask := gs.Get("SMask"); smask != nil {
+		if name, _ := core.GetNameVal(smask); name != "None" {
+			c.addf(ruleTransparency, num, 0, "soft mask is not allowed")
+		}
+	}
+	for _, key := range []core.PdfObjectName{"CA", "ca"} {
+		if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(gs.Get(key))); err == nil && alpha != 1 {
+			c.addf(ruleTransparency, num, 0, "%s value %g is not allowed", key, alpha)
+		}
+	}
+	if bm, ok := core.GetNameVal(gs.Get("BM")); ok && bm != "Normal" && bm != "Compatible" {
+		c.addf(ruleTransparency, num, 0, "blend mode %s is not allowed", bm)
+	}
+}
+
+// checkMetadata checks the XMP metadata, the PDF/A identification and its consistency with the
+// document information dictionary.
+func (c *pdfaChecker) checkMetadata() {
+	metaNum := objectNumber(c.catalog.Get("Metadata"))
+	stream, ok := core.GetStream(c.catalog.Get("Metadata"))
+	if !ok {
+		c.addf(ruleMetadata, c.catalogNum, 0, "catalog does not contain a metadata stream")
+		return
+	}
+	if c.part == 1 && stream.Get("Filter") != nil {
+		c.addf(ruleMetadata, metaNum, 0, "metadata stream shall not be filtered")
+	}
+	if c.xmpErr != nil {
+		c.addf(ruleMetadata, metaNum, 0, "invalid XMP metadata: %v", c.xmpErr)
+		return
+	}
+
+	part := c.xmp.Text(xmputil.NsPdfAID, "part")
+	conformance := c.xmp.Text(xmputil.NsPdfAID, "conformance")
+	switch {
+	case part == "":
+		c.addf(ruleIdentification, metaNum, 0, "PDF/A identification schema is missing")
+	case part != strconv.Itoa(c.part):
+		c.addf(ruleIdentification, metaNum, 0, "pdfaid:part is %s, expected %d", part, c.part)
+	}
+	if part != "" && conformance != "A" && conformance != "B" && (c.part == 1 || conformance != "U") {
+		c.addf(ruleIdentification, metaNum, 0, "invalid pdfaid:conformance %q", conformance)
+	}
+
+	info, ok := core.GetDict(c.trailer.Get("Info"))
+	if !ok {
+		return
+	}
+	infoNum := objectNumber(c.trailer.Get("Info"))
+	for _, p := range []struct {
+		key          core.PdfObjectName
+		space, local string
+	}{
+		{"Title", xmputil.NsDC, "title"},
+		{"Author", xmputil.NsDC, "creator"},
+		{"Subject", xmputil.NsDC, "description"},
+		{"Keywords", xmputil.NsPDF, "Keywords"},
+		{"Creator", xmputil.NsXMP, "CreatorTool"},
+		{"Producer", xmputil.NsPDF, "Producer"},
+	} {
+		s, ok := core.GetString(info.Get(p.key))
+		if !ok || s.Decoded() == "" {
+			continue
+		}
+		if xv := c.xmp.Text(p.space, p.local); xv != s.Decoded() {
+			c.addf(ruleInfoConsistency, infoNum, 0, "%s %q does not match XMP value %q", p.key, s.Decoded(), xv)
+		}
+	}
+	for _, p := range []struct {
+		key   core.PdfObjectName
+		local string
+	}{
+		{"CreationDate", "CreateDate"},
+		{"ModDate", "ModifyDate"},
+	} {
+		s, ok := core.GetString(info.Get(p.key))
+		if !ok {
+			continue
+		}
+		date, err := model.NewPdfDate(s.Str())
+		if err != nil {
+			continue
+		}
+		xv := c.xmp.Text(xmputil.NsXMP, p.local)
+		if xdate, ok := xmputil.ParseDate(xv); !ok || !xdate.Equal(date.ToGoTime()) {
+			c.addf(ruleInfoConsistency, infoNum, 0, "%s does not match XMP value %q", p.key, xv)
+		}
+	}
+}
+
+// checkOutputIntents checks the PDF/A output intents and their ICC profiles.
+func (c *pdfaChecker) checkOutputIntents() {
+	intents, ok := core.GetArray(c.catalog.Get("OutputIntents"))
+	if !ok {
+		return
+	}
+	var profile *core.PdfObjectStream
+	for _, obj := range intents.Elements() {
+		intent, ok := core.GetDict(obj)
+		if !ok {
+			continue
+		}
+		if s, _ := core.GetNameVal(intent.Get("S")); s != "GTS_PDFA1" {
+			continue
+		}
+		num := objectNumber(obj)
+		dest, ok := core.GetStream(intent.Get("DestOutputProfile"))
+		if !ok {
+			if oci, _ := core.GetString(intent.Get("OutputConditionIdentifier")); oci == nil {
+				c.addf(ruleOutputIntent, num, 0, "output intent has no destination profile")
+			}
+			continue
+		}
+		if profile != nil && profile != dest {
+			c.addf(ruleOutputIntent, num, 0, "output intents have different destination profiles")
+			continue
+		}
+		profile = dest
+	}
+	if profile == nil {
+		return
+	}
+
+	num := objectNumber(profile)
+	data, err := core.DecodeStream(profile)
+	if err != nil {
+		c.addf(ruleOutputIntent, num, 0, "destination profile cannot be decoded: %v", err)
+		return
+	}
+	h, err := icc.ParseHeader(data)
+	if err != nil {
+		c.addf(ruleOutputIntent, num, 0, "invalid destination profile: %v", err)
+		return
+	}
+	if h.Class != "mntr" && h.Class != "prtr" {
+		c.addf(ruleOutputIntent, num, 0, "destination profile class %q is not allowed", h.Class)
+	}
+	if (c.part == 1 && h.Major > 2) || h.Major > 4 {
+		c.addf(ruleOutputIntent, num, 0, "destination profile version %d.%d is not allowed", h.Major, h.Minor)
+	}
+	if n, ok := core.GetIntVal(profile.Get("N")); ok && n != h.NumComponents() {
+		c.addf(ruleOutputIntent, num, 0, "N is %d but profile has %d components", n, h.NumComponents())
+	}
+	c.intentComponents = h.NumComponents()
+}
+
+// checkEmbeddedFiles checks document-level embedded files.
+func (c *pdfaChecker) checkEmbeddedFiles() {
+	files, err := c.reader.GetEmbeddedFiles()
+	if err != nil || len(files) == 0 {
+		return
+	}
+	for _, f := range files {
+		switch c.part {
+		case 1:
+			c.addf(ruleEmbeddedFiles, 0, 0, "embedded file %q is not allowed", f.Name)
+		case 2:
+			if !bytes.HasPrefix(f.Content, []byte("%PDF-")) {
+				c.addf(ruleEmbeddedFiles, 0, 0, "embedded file %q is not a PDF document", f.Name)
+			}
+		case 3:
+			if f.Relationship == "" {
+				c.addf(ruleEmbeddedFiles, 0, 0, "embedded file %q has no AFRelationship", f.Name)
+			}
+			if f.MimeType == "" {
+				c.addf(ruleEmbeddedFiles, 0, 0, "embedded file %q has no MIME type", f.Name)
+			}
+		}
+	}
+}
+
+// checkPage checks the annotations and color spaces of a page.
+func (c *pdfaChecker) checkPage(page *model.PdfPage, pageNum int) error {
+	annots, _ := core.GetArray(page.Annots)
+	if annots == nil {
+		if dict, ok := core.GetDict(page.GetPageAsIndirectObject()); ok {
+			annots, _ = core.GetArray(dict.Get("Annots"))
+		}
+	}
+	if annots != nil {
+		for _, obj := range annots.Elements() {
+			annot, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			c.checkAnnotation(annot, objectNumber(obj), pageNum)
+		}
+	}
+
+	usage, err := scanColorUsage(page)
+	if err != nil {
+		return err
+	}
+	for _, u := range usage {
+		if u.space == "DeviceGray" && c.intentComponents != 0 {
+			continue
+		}
+		if (u.space == "DeviceRGB" && c.intentComponents == 3) || (u.space == "DeviceCMYK" && c.intentComponents == 4) {
+			continue
+		}
+		c.addf(ruleDeviceColorSpaces, u.object, pageNum, "%s used without a matching output intent", u.space)
+	}
+	return nil
+}
+
+// checkAnnotation checks an annotation of page `pageNum`.
+func (c *pdfaChecker) checkAnnotation(annot *core.PdfObjectDictionary, num int64, pageNum int) {
+	subtype, _ := core.GetNameVal(annot.Get("Subtype"))
+	if model.PdfAForbidsAnnotation(subtype, c.part) {
+		c.addf(ruleAnnotationTypes, num, pageNum, "%s annotation is not allowed", subtype)
+	}
+	if c.part == 1 {
+		if alpha, err := core.GetNumberAsFloat(core.TraceToDirectObject(annot.Get("CA"))); err == nil && alpha != 1 {
+			c.addf(ruleTransparency, num, pageNum, "annotation CA value %g is not allowed", alpha)
+		}
+	}
+	if subtype == "Popup" {
+		return
+	}
+	flags, _ := core.GetIntVal(annot.Get("F"))
+	if flags&4 == 0 {
+		c.addf(ruleAnnotationFlags, num, pageNum, "Print flag of %s annotation is not set", subtype)
+	}
+	if flags&(1|2|32) != 0 {
+		c.addf(ruleAnnotationFlags, num, pageNum, "%s annotation is hidden", subtype)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// PDF/UA-1 rules (clauses of ISO 14289-1).
+const (
+	ruleUAIdentification = "5"
+	ruleUATagged         = "7.1"
+	ruleUALanguage       = "7.2"
+	ruleUAAltText        = "7.3"
+	ruleUAHeadings       = "7.4.2"
+	ruleUAAnnotations    = "7.18.1"
+)
+
+// checkPdfUA performs basic PDF/UA-1 checks: the document is tagged and identified, has a title
+// and a language, figures have alternate descriptions, headings are properly nested and
+// annotations have descriptions.
+func (v *validator) checkPdfUA() error {
+	addf := func(rule string, object int64, page int, format string, args ...interface{}) {
+		v.addf(PdfUA1, rule, object, page, format, args...)
+	}
+
+	metaNum := objectNumber(v.catalog.Get("Metadata"))
+	switch {
+	case v.xmp == nil:
+		addf(ruleUAIdentification, v.catalogNum, 0, "catalog does not contain valid XMP metadata")
+	case v.xmp.Text(xmputil.NsPdfUAID, "part") != "1":
+		addf(ruleUAIdentification, metaNum, 0, "PDF/UA identification schema is missing")
+	}
+	if v.xmp != nil && v.xmp.Text(xmputil.NsDC, "title") == "" {
+		addf(ruleUATagged, metaNum, 0, "document title (dc:title) is missing")
+	}
+	prefs, _ := core.GetDict(v.catalog.Get("ViewerPreferences"))
+	if prefs == nil {
+		addf(ruleUATagged, v.catalogNum, 0, "DisplayDocTitle viewer preference is not set")
+	} else if display, _ := core.GetBoolVal(prefs.Get("DisplayDocTitle")); !display {
+		addf(ruleUATagged, v.catalogNum, 0, "DisplayDocTitle viewer preference is not set")
+	}
+
+	marked := false
+	if markInfo, ok := core.GetDict(v.catalog.Get("MarkInfo")); ok {
+		marked, _ = core.GetBoolVal(markInfo.Get("Marked"))
+	}
+	if !marked {
+		addf(ruleUATagged, v.catalogNum, 0, "document is not marked as tagged")
+	}
+	if lang, ok := core.GetString(v.catalog.Get("Lang")); !ok || lang.Decoded() == "" {
+		addf(ruleUALanguage, v.catalogNum, 0, "document language is not specified")
+	}
+
+	root, ok := core.GetDict(v.catalog.Get("StructTreeRoot"))
+	if !ok {
+		addf(ruleUATagged, v.catalogNum, 0, "structure tree is missing")
+	} else if err := v.checkStructTree(root, addf); err != nil {
+		return err
+	}
+
+	for i, page := range v.reader.PageList {
+		annots, ok := core.GetArray(page.Annots)
+		if !ok {
+			continue
+		}
+		for _, obj := range annots.Elements() {
+			annot, ok := core.GetDict(obj)
+			if !ok {
+				continue
+			}
+			subtype, _ := core.GetNameVal(annot.Get("Subtype"))
+			switch subtype {
+			case "Widget", "Popup", "PrinterMark", "Link":
+				continue
+			}
+			if s, ok := core.GetString(annot.Get("Contents")); !ok || s.Decoded() == "" {
+				addf(ruleUAAnnotations, objectNumber(obj), i+1, "%s annotation has no Contents", subtype)
+			}
+		}
+	}
+	return nil
+}
+
+// checkStructTree walks the structure tree in document order and checks figures and headings.
+func (v *validator) checkStructTree(root *core.PdfObjectDictionary,
+	addf func(rule string, object int64, page int, format string, args ...interface{})) error {
+	roleMap, _ := core.GetDict(root.Get("RoleMap"))
+	role := func(s string) string {
+		// Custom structure types are mapped to standard types, possibly through several steps.
+		for i := 0; i < 8 && roleMap != nil; i++ {
+			mapped, ok := core.GetNameVal(roleMap.Get(core.PdfObjectName(s)))
+			if !ok || mapped == s {
+				break
+			}
+			s = mapped
+		}
+		return s
+	}
+
+	visited := map[core.PdfObject]bool{}
+	prevLevel := 0
+	var walk func(obj core.PdfObject, page int) error
+	walk = func(obj core.PdfObject, page int) error {
+		num := objectNumber(obj)
+		obj = core.ResolveReference(obj)
+		if visited[obj] {
+			return errors.New("structure tree loop detected")
+		}
+
+		switch t := core.TraceToDirectObject(obj).(type) {
+		case *core.PdfObjectArray:
+			for _, kid := range t.Elements() {
+				if err := walk(kid, page); err != nil {
+					return err
+				}
+			}
+			return nil
+		case *core.PdfObjectDictionary:
+			visited[obj] = true
+			if typ, _ := core.GetNameVal(t.Get("Type")); typ == "MCR" || typ == "OBJR" {
+				return nil
+			}
+			if pg := objectNumber(t.Get("Pg")); pg != 0 {
+				page = v.pageNums[pg]
+			}
+			s, ok := core.GetNameVal(t.Get("S"))
+			if ok {
+				switch structType := role(s); structType {
+				case "Figure":
+					alt, _ := core.GetString(t.Get("Alt"))
+					actual, _ := core.GetString(t.Get("ActualText"))
+					if (alt == nil || alt.Decoded() == "") && (actual == nil || actual.Decoded() == "") {
+						addf(ruleUAAltText, num, page, "figure has no alternate description")
+					}
+				case "H1", "H2", "H3", "H4", "H5", "H6":
+					level, _ := strconv.Atoi(structType[1:])
+					switch {
+					case prevLevel == 0 && level != 1:
+						addf(ruleUAHeadings, num, page, "first heading is %s, expected H1", structType)
+					case level > prevLevel+1 && prevLevel != 0:
+						addf(ruleUAHeadings, num, page, "heading level skipped: H%d follows H%d", level, prevLevel)
+					}
+					prevLevel = level
+				}
+			}
+			if kids := t.Get("K"); kids != nil {
+				return walk(kids, page)
+			}
+		}
+		return nil
+	}
+	return walk(root.Get("K"), 0)
+}
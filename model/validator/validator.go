@@ -0,0 +1,253 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+	"github.com/carmel/unipdf/model/xmputil"
+)
+
+// Profile is a conformance specification checked by the validator.
+type Profile string
+
+// Supported profiles.
+const (
+	PdfA1  Profile = "PDF/A-1"
+	PdfA2  Profile = "PDF/A-2"
+	PdfA3  Profile = "PDF/A-3"
+	PdfUA1 Profile = "PDF/UA-1"
+)
+
+// pdfaPart returns the part of ISO 19005 for PDF/A profiles and 0 otherwise.
+func (p Profile) pdfaPart() int {
+	switch p {
+	case PdfA1:
+		return 1
+	case PdfA2:
+		return 2
+	case PdfA3:
+		return 3
+	}
+	return 0
+}
+
+// Violation is a failed conformance rule.
+type Violation struct {
+	Profile Profile `json:"profile"`
+	// Rule is the clause of the standard that is violated, e.g. "6.3.4".
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+	// Object is the number of the offending object, 0 if not applicable.
+	Object int64 `json:"object,omitempty"`
+	// Page is the number of the page (starting from 1) on which the violation occurs,
+	// 0 if not applicable.
+	Page int `json:"page,omitempty"`
+}
+
+// String returns a human-readable description of the violation.
+func (v Violation) String() string {
+	s := fmt.Sprintf("%s %s: %s", v.Profile, v.Rule, v.Message)
+	if v.Object != 0 {
+		s += fmt.Sprintf(" (object %d)", v.Object)
+	}
+	if v.Page != 0 {
+		s += fmt.Sprintf(" (page %d)", v.Page)
+	}
+	return s
+}
+
+// Report contains the results of a validation.
+type Report struct {
+	// Profiles are the profiles the document was validated against.
+	Profiles   []Profile   `json:"profiles"`
+	Violations []Violation `json:"violations"`
+}
+
+// Compliant returns true if there are no violations of `profile`. It returns false if the
+// document was not validated against `profile`.
+func (r *Report) Compliant(profile Profile) bool {
+	found := false
+	for _, p := range r.Profiles {
+		found = found || p == profile
+	}
+	if !found {
+		return false
+	}
+	for _, v := range r.Violations {
+		if v.Profile == profile {
+			return false
+		}
+	}
+	return true
+}
+
+// Valid returns true if there are no violations.
+func (r *Report) Valid() bool {
+	return len(r.Violations) == 0
+}
+
+// ErrNoProfile is returned by Validate when no profiles are specified and the document does
+// not claim conformance with any supported profile.
+var ErrNoProfile = errors.New("no profile to validate against")
+
+// Validate checks the document of `reader` for conformance with `profiles`. If no profiles are
+// specified, the document is validated against the profiles it claims conformance with in its
+// XMP metadata. Only the level B requirements of PDF/A are checked, also for documents that
+// claim level A or U conformance. Encrypted documents must be decrypted prior to validation.
+func Validate(reader *model.PdfReader, profiles ...Profile) (*Report, error) {
+	v, err := newValidator(reader)
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		profiles = v.claimedProfiles()
+		if len(profiles) == 0 {
+			return nil, ErrNoProfile
+		}
+	}
+
+	v.report.Profiles = profiles
+	for _, profile := range profiles {
+		switch {
+		case profile.pdfaPart() != 0:
+			if err := v.checkPdfA(profile); err != nil {
+				return nil, err
+			}
+		case profile == PdfUA1:
+			if err := v.checkPdfUA(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported profile: %s", profile)
+		}
+	}
+
+	sort.SliceStable(v.report.Violations, func(i, j int) bool {
+		a, b := v.report.Violations[i], v.report.Violations[j]
+		if a.Profile != b.Profile {
+			return a.Profile < b.Profile
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.Object < b.Object
+	})
+	return v.report, nil
+}
+
+// ClaimedProfiles returns the profiles the document of `reader` claims conformance with in
+// its XMP metadata.
+func ClaimedProfiles(reader *model.PdfReader) ([]Profile, error) {
+	v, err := newValidator(reader)
+	if err != nil {
+		return nil, err
+	}
+	return v.claimedProfiles(), nil
+}
+
+// validator holds the state of a validation.
+type validator struct {
+	reader  *model.PdfReader
+	trailer *core.PdfObjectDictionary
+	catalog *core.PdfObjectDictionary
+	// catalogNum is the object number of the catalog.
+	catalogNum int64
+	// pageNums maps object numbers of page objects to page numbers.
+	pageNums map[int64]int
+	// xmp is the document XMP metadata, nil if missing or invalid.
+	xmp    *xmputil.Packet
+	xmpErr error
+
+	report *Report
+}
+
+func newValidator(reader *model.PdfReader) (*validator, error) {
+	trailer, err := reader.GetTrailer()
+	if err != nil {
+		return nil, err
+	}
+	v := &validator{
+		reader:   reader,
+		trailer:  trailer,
+		pageNums: map[int64]int{},
+		report:   &Report{},
+	}
+	catalog, ok := core.GetDict(trailer.Get("Root"))
+	if !ok {
+		return nil, errors.New("catalog not found")
+	}
+	v.catalog = catalog
+	if ind, ok := core.GetIndirect(trailer.Get("Root")); ok {
+		v.catalogNum = ind.ObjectNumber
+	}
+	for i, page := range reader.PageList {
+		if ind := page.GetPageAsIndirectObject(); ind != nil {
+			v.pageNums[ind.ObjectNumber] = i + 1
+		}
+	}
+
+	if stream, ok := core.GetStream(v.catalog.Get("Metadata")); ok {
+		data, err := core.DecodeStream(stream)
+		if err == nil {
+			v.xmp, err = xmputil.Parse(data)
+		}
+		if err != nil {
+			common.Log.Debug("ERROR: invalid XMP metadata: %v", err)
+			v.xmp, v.xmpErr = nil, err
+		}
+	}
+	return v, nil
+}
+
+// claimedProfiles returns the profiles identified in the XMP metadata.
+func (v *validator) claimedProfiles() []Profile {
+	if v.xmp == nil {
+		return nil
+	}
+	var profiles []Profile
+	switch v.xmp.Text(xmputil.NsPdfAID, "part") {
+	case "1":
+		profiles = append(profiles, PdfA1)
+	case "2":
+		profiles = append(profiles, PdfA2)
+	case "3":
+		profiles = append(profiles, PdfA3)
+	}
+	if v.xmp.Text(xmputil.NsPdfUAID, "part") == "1" {
+		profiles = append(profiles, PdfUA1)
+	}
+	return profiles
+}
+
+// addf adds a violation of `rule` of `profile`.
+func (v *validator) addf(profile Profile, rule string, object int64, page int, format string, args ...interface{}) {
+	v.report.Violations = append(v.report.Violations, Violation{
+		Profile: profile,
+		Rule:    rule,
+		Message: fmt.Sprintf(format, args...),
+		Object:  object,
+		Page:    page,
+	})
+}
+
+// objectNumber returns the object number of `obj` if it is a reference or indirect object.
+func objectNumber(obj core.PdfObject) int64 {
+	switch t := obj.(type) {
+	case *core.PdfObjectReference:
+		return t.ObjectNumber
+	case *core.PdfIndirectObject:
+		return t.ObjectNumber
+	case *core.PdfObjectStream:
+		return t.ObjectNumber
+	}
+	return 0
+}
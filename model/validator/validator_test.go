@@ -0,0 +1,196 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package validator
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+	"github.com/carmel/unipdf/model"
+)
+
+// newTestPage returns a page showing Helvetica text in DeviceRGB.
+func newTestPage(t *testing.T) *model.PdfPage {
+	font, err := model.NewStandard14Font(model.HelveticaName)
+	require.NoError(t, err)
+
+	page := model.NewPdfPage()
+	require.NoError(t, page.AddFont("F1", font.ToPdfObject()))
+	require.NoError(t, page.SetContentStreams([]string{
+		"1 0 0 rg BT /F1 12 Tf 10 10 Td (Hello) Tj ET",
+	}, core.NewFlateEncoder()))
+	return page
+}
+
+// writeTestDocument writes a single page document, optionally as PDF/A, and returns a reader.
+func writeTestDocument(t *testing.T, opts *model.PdfAOptions) *model.PdfReader {
+	w := model.NewPdfWriter()
+	require.NoError(t, w.AddPage(newTestPage(t)))
+	if opts != nil {
+		w.SetPdfA(opts)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+
+	reader, err := model.NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	return reader
+}
+
+// rules returns the rules of the violations of `profile`.
+func rules(report *Report, profile Profile) map[string]bool {
+	m := map[string]bool{}
+	for _, v := range report.Violations {
+		if v.Profile == profile {
+			m[v.Rule] = true
+		}
+	}
+	return m
+}
+
+func TestValidatePdfA(t *testing.T) {
+	replacement, err := model.NewPdfFontFromTTFFile("../testdata/font/OpenSans-Regular.ttf")
+	require.NoError(t, err)
+
+	reader := writeTestDocument(t, &model.PdfAOptions{
+		Conformance: model.PdfA2B,
+		Fonts:       map[string]*model.PdfFont{string(model.HelveticaName): replacement},
+	})
+
+	profiles, err := ClaimedProfiles(reader)
+	require.NoError(t, err)
+	require.Equal(t, []Profile{PdfA2}, profiles)
+
+	report, err := Validate(reader)
+	require.NoError(t, err)
+	require.Empty(t, report.Violations)
+	require.True(t, report.Compliant(PdfA2))
+	require.False(t, report.Compliant(PdfA1))
+}
+
+func TestValidatePdfAViolations(t *testing.T) {
+	reader := writeTestDocument(t, nil)
+
+	_, err := Validate(reader)
+	require.Equal(t, ErrNoProfile, err)
+
+	report, err := Validate(reader, PdfA1, PdfA2)
+	require.NoError(t, err)
+	require.False(t, report.Valid())
+	require.False(t, report.Compliant(PdfA2))
+
+	a1 := rules(report, PdfA1)
+	require.True(t, a1["6.3.4"], "font embedding")
+	require.True(t, a1["6.7.2"], "metadata")
+	require.True(t, a1["6.2.3.3"], "device color space")
+
+	a2 := rules(report, PdfA2)
+	require.True(t, a2["6.2.11.4.1"], "font embedding")
+	require.True(t, a2["6.6.2.1"], "metadata")
+	require.True(t, a2["6.2.4.3"], "device color space")
+
+	for _, v := range report.Violations {
+		if v.Rule == "6.2.3.3" {
+			require.Equal(t, 1, v.Page)
+		}
+	}
+}
+
+// buildPDF returns a PDF file with objects `objects`, numbered from 1. Object 1 is the catalog.
+func buildPDF(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.7\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestValidatePdfUA(t *testing.T) {
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:pdfuaid="http://www.aiim.org/pdfua/ns/id/" pdfuaid:part="1">
+<dc:title xmlns:dc="http://purl.org/dc/elements/1.1/"><rdf:Alt><rdf:li xml:lang="x-default">Test</rdf:li></rdf:Alt></dc:title>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`
+
+	newObjects := func(catalog, figure, heading string) []string {
+		return []string{
+			catalog,
+			"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 100 100] /Annots [8 0 R] >>",
+			fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp)+1, xmp),
+			"<< /Type /StructTreeRoot /K [6 0 R] /RoleMap << /Image /Figure >> >>",
+			"<< /Type /StructElem /S /Document /P 5 0 R /K [7 0 R 9 0 R] >>",
+			"<< /Type /StructElem /S /Image /P 6 0 R /Pg 3 0 R " + figure + " /K 0 >>",
+			"<< /Type /Annot /Subtype /Text /Rect [0 0 10 10] /Contents (Note) >>",
+			"<< /Type /StructElem /S /" + heading + " /P 6 0 R /Pg 3 0 R /K 1 >>",
+		}
+	}
+	validate := func(objects []string) *Report {
+		reader, err := model.NewPdfReader(bytes.NewReader(buildPDF(objects)))
+		require.NoError(t, err)
+		report, err := Validate(reader)
+		require.NoError(t, err)
+		require.Equal(t, []Profile{PdfUA1}, report.Profiles)
+		return report
+	}
+
+	catalog := "<< /Type /Catalog /Pages 2 0 R /Metadata 4 0 R /StructTreeRoot 5 0 R " +
+		"/MarkInfo << /Marked true >> /Lang (en) /ViewerPreferences << /DisplayDocTitle true >> >>"
+	report := validate(newObjects(catalog, "/Alt (Logo)", "H1"))
+	require.Empty(t, report.Violations)
+	require.True(t, report.Compliant(PdfUA1))
+
+	report = validate(newObjects("<< /Type /Catalog /Pages 2 0 R /Metadata 4 0 R /StructTreeRoot 5 0 R >>", "", "H2"))
+	require.Equal(t, map[string]bool{"7.1": true, "7.2": true, "7.3": true, "7.4.2": true}, rules(report, PdfUA1))
+	for _, v := range report.Violations {
+		switch v.Rule {
+		case "7.3":
+			require.Equal(t, int64(7), v.Object)
+			require.Equal(t, 1, v.Page)
+		case "7.4.2":
+			require.Equal(t, int64(9), v.Object)
+		}
+	}
+}
+
+func TestClaimedProfiles(t *testing.T) {
+	// The identification can be given as attributes or elements, in any rdf:Description.
+	xmp := `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="2">
+<pdfaid:conformance>B</pdfaid:conformance>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:pdfuaid="http://www.aiim.org/pdfua/ns/id/">
+<pdfuaid:part>1</pdfuaid:part>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>`
+	data := buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R /Metadata 3 0 R >>",
+		"<< /Type /Pages /Kids [] /Count 0 >>",
+		fmt.Sprintf("<< /Type /Metadata /Subtype /XML /Length %d >>\nstream\n%s\nendstream", len(xmp)+1, xmp),
+	})
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	profiles, err := ClaimedProfiles(reader)
+	require.NoError(t, err)
+	require.Equal(t, []Profile{PdfA2, PdfUA1}, profiles)
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/carmel/unipdf/core"
 	"github.com/carmel/unipdf/core/security"
 	"github.com/carmel/unipdf/core/security/crypt"
+	"github.com/carmel/unipdf/model/xmputil"
 )
 
 var pdfAuthor = ""
@@ -49,6 +50,9 @@ func getPdfAuthor() string {
 }
 
 // SetPdfAuthor sets the Author attribute of the output PDF.
+//
+// Deprecated: the setting applies to all writers created afterwards and is not safe for
+// concurrent use. Use PdfWriter.SetDocInfo instead.
 func SetPdfAuthor(author string) {
 	pdfAuthor = author
 }
@@ -58,6 +62,8 @@ func getPdfCreationDate() time.Time {
 }
 
 // SetPdfCreationDate sets the CreationDate attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfCreationDate(creationDate time.Time) {
 	pdfCreationDate = creationDate
 }
@@ -72,6 +78,8 @@ func getPdfCreator() string {
 }
 
 // SetPdfCreator sets the Creator attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfCreator(creator string) {
 	pdfCreator = creator
 }
@@ -81,6 +89,8 @@ func getPdfKeywords() string {
 }
 
 // SetPdfKeywords sets the Keywords attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfKeywords(keywords string) {
 	pdfKeywords = keywords
 }
@@ -90,8 +100,10 @@ func getPdfModifiedDate() time.Time {
 }
 
 // SetPdfModifiedDate sets the ModDate attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfModifiedDate(modifiedDate time.Time) {
-	pdfCreationDate = modifiedDate
+	pdfModifiedDate = modifiedDate
 }
 
 // func getPdfProducer() string {
@@ -105,6 +117,8 @@ func SetPdfModifiedDate(modifiedDate time.Time) {
 // }
 
 // SetPdfProducer sets the Producer attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfProducer(producer string) {
 	pdfProducer = producer
 }
@@ -114,6 +128,8 @@ func getPdfSubject() string {
 }
 
 // SetPdfSubject sets the Subject attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfSubject(subject string) {
 	pdfSubject = subject
 }
@@ -123,6 +139,8 @@ func getPdfTitle() string {
 }
 
 // SetPdfTitle sets the Title attribute of the output PDF.
+//
+// Deprecated: use PdfWriter.SetDocInfo instead.
 func SetPdfTitle(title string) {
 	pdfTitle = title
 }
@@ -184,6 +202,25 @@ type PdfWriter struct {
 
 	// Cache of objects traversed while resolving references.
 	traversed map[core.PdfObject]struct{}
+
+	// Document-level embedded files and portable collection.
+	embeddedFiles embeddedFiles
+	collection    *PdfCollection
+
+	// PDF/A output options, nil if PDF/A output is not requested.
+	pdfa *PdfAOptions
+
+	// Whether to write a linearized file.
+	linearized bool
+
+	// Whether to write a PDF 2.0 document, see SetPdf20.
+	pdf20 bool
+
+	// XMP metadata written to the catalog Metadata stream, nil if not set.
+	xmp *xmputil.Packet
+
+	// State of streaming output, nil unless StartStreaming has been called.
+	streaming *streamingState
 }
 
 // NewPdfWriter initializes a new PdfWriter.
@@ -430,6 +467,15 @@ func (w *PdfWriter) SetVersion(majorVersion, minorVersion int) {
 	w.minorVersion = minorVersion
 }
 
+// SetCrossReferenceStream sets whether the cross-reference section of the output is written as a
+// cross-reference stream (PDF 1.5) or as a classic cross-reference table, independently of the
+// PDF version. By default a cross-reference stream is written for PDF 1.5 and above.
+// The version is raised to 1.5 if needed when using a cross-reference stream. Object streams (see
+// optimize.Options.UseObjectStreams) always require a cross-reference stream.
+func (w *PdfWriter) SetCrossReferenceStream(enabled bool) {
+	w.useCrossReferenceStream = &enabled
+}
+
 // SetOCProperties sets the optional content properties.
 func (w *PdfWriter) SetOCProperties(ocProperties core.PdfObject) error {
 	dict := w.catalog
@@ -468,6 +514,103 @@ func (w *PdfWriter) SetPageLabels(pageLabels core.PdfObject) error {
 	return w.addObjects(pageLabels)
 }
 
+// AddEmbeddedFile attaches a file to the document. The file is added to the EmbeddedFiles
+// name tree of the catalog, replacing an existing file with the same name.
+func (w *PdfWriter) AddEmbeddedFile(f *EmbeddedFile) error {
+	return w.embeddedFiles.add(f)
+}
+
+// RemoveEmbeddedFile removes the embedded file with the specified name, added with
+// AddEmbeddedFile or listed in the EmbeddedFiles name tree of the catalog.
+func (w *PdfWriter) RemoveEmbeddedFile(name string) {
+	w.embeddedFiles.remove(name)
+}
+
+// SetCollection makes the output document a portable collection (PDF portfolio) of its embedded files.
+// Requires PDF 1.7, the version of the output is raised to 1.7 if lower.
+func (w *PdfWriter) SetCollection(collection *PdfCollection) {
+	w.collection = collection
+}
+
+// writeEmbeddedFiles updates the catalog with embedded files and the portable collection.
+func (w *PdfWriter) writeEmbeddedFiles() error {
+	if w.embeddedFiles.changed() {
+		var existing []nameTreeEntry
+		if names, ok := core.GetDict(w.catalog.Get("Names")); ok && names.Get("EmbeddedFiles") != nil {
+			var err error
+			existing, err = loadNameTree(names.Get("EmbeddedFiles"))
+			if err != nil {
+				return err
+			}
+		}
+		if err := w.embeddedFiles.apply(w.catalog, existing); err != nil {
+			return err
+		}
+		for _, key := range []core.PdfObjectName{"Names", "AF"} {
+			if obj := w.catalog.Get(key); obj != nil {
+				if err := w.addObjects(obj); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if w.collection != nil {
+		w.catalog.Set("Collection", w.collection.ToPdfObject())
+		if w.majorVersion == 1 && w.minorVersion < 7 {
+			w.minorVersion = 7
+		}
+	}
+	return nil
+}
+
+// SetDocInfo sets the document information dictionary of the output, replacing the default
+// entries set from the package-level settings (SetPdfAuthor etc).
+func (w *PdfWriter) SetDocInfo(info *PdfInfo) {
+	if info == nil {
+		w.infoObj.PdfObject = core.MakeDict()
+		return
+	}
+	w.infoObj.PdfObject = info.ToPdfObject()
+}
+
+// GetDocInfo returns the document information dictionary of the output.
+func (w *PdfWriter) GetDocInfo() (*PdfInfo, error) {
+	return NewPdfInfoFromObject(w.infoObj)
+}
+
+// SetXMP sets the XMP metadata of the output. When writing, the packet and the document
+// information dictionary are synchronized: missing information entries are taken from the packet
+// and the corresponding packet properties are updated from the information entries.
+func (w *PdfWriter) SetXMP(packet *xmputil.Packet) {
+	w.xmp = packet
+}
+
+// writeXMPMetadata synchronizes `packet` with the document information dictionary and sets it as
+// the catalog metadata stream. Returns the serialized packet.
+func (w *PdfWriter) writeXMPMetadata(packet *xmputil.Packet) ([]byte, error) {
+	info, err := NewPdfInfoFromObject(w.infoObj)
+	if err != nil {
+		return nil, err
+	}
+	info.UpdateFromXMP(packet)
+	info.UpdateXMP(packet)
+	w.infoObj.PdfObject = info.ToPdfObject()
+
+	data, err := packet.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := core.MakeStream(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Set("Type", core.MakeName("Metadata"))
+	metadata.Set("Subtype", core.MakeName("XML"))
+	w.catalog.Set("Metadata", metadata)
+	w.addObject(metadata)
+	return data, nil
+}
+
 // SetOptimizer sets the optimizer to optimize PDF before writing.
 func (w *PdfWriter) SetOptimizer(optimizer Optimizer) {
 	w.optimizer = optimizer
@@ -495,6 +638,9 @@ func (w *PdfWriter) addObject(obj core.PdfObject) bool {
 
 		w.objects = append(w.objects, obj)
 		w.objectsMap[obj] = struct{}{}
+		if w.streaming != nil {
+			w.streaming.number(obj)
+		}
 		return true
 	}
 
@@ -645,7 +791,12 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 	}
 
 	common.Log.Trace("Traversal done")
+	return w.addPageObject(pageObj, pDict)
+}
 
+// addPageObject appends the page object `pageObj` with dictionary `pDict` to the page tree of the
+// writer and adds the objects of the page for writing. Inherited attributes must already be set.
+func (w *PdfWriter) addPageObject(pageObj *core.PdfIndirectObject, pDict *core.PdfObjectDictionary) error {
 	// Update the dictionary.
 	// Reuses the input object, updating the fields.
 	pDict.Set("Parent", w.pages)
@@ -678,6 +829,9 @@ func (w *PdfWriter) AddPage(page *PdfPage) error {
 		return err
 	}
 
+	if w.streaming != nil {
+		return w.flushStreamingPage(pDict)
+	}
 	return nil
 }
 
@@ -898,6 +1052,30 @@ func (w *PdfWriter) updateObjectNumbers() {
 type EncryptOptions struct {
 	Permissions security.Permissions
 	Algorithm   EncryptionAlgorithm
+
+	// PlainMetadata leaves the document-level XMP metadata stream unencrypted,
+	// so it can be read by indexers without authentication. Requires AES.
+	PlainMetadata bool
+	// EmbeddedFilesOnly encrypts only embedded file streams. Page content, strings and other
+	// streams are left unencrypted and the document can be opened without authentication, the
+	// password being only required to read the embedded files (see PdfReader.Decrypt). Requires AES.
+	EmbeddedFilesOnly bool
+}
+
+// cryptConfig returns the encryption config that corresponds to the options.
+// Nil is returned if default behavior is requested.
+func (opt *EncryptOptions) cryptConfig() *core.EncryptConfig {
+	if opt == nil || (!opt.PlainMetadata && !opt.EmbeddedFilesOnly) {
+		return nil
+	}
+	cfg := &core.EncryptConfig{
+		PlainMetadata: opt.PlainMetadata,
+	}
+	if opt.EmbeddedFilesOnly {
+		cfg.StreamFilter = core.CryptFilterIdentity
+		cfg.StringFilter = core.CryptFilterIdentity
+	}
+	return cfg
 }
 
 // EncryptionAlgorithm is used in EncryptOptions to change the default algorithm used to encrypt the document.
@@ -913,31 +1091,76 @@ const (
 )
 
 // Encrypt encrypts the output file with a specified user/owner password.
+// In PDF 2.0 mode (see SetPdf20), AES-256 is used when the Algorithm of the options is left at
+// its zero value.
 func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions) error {
 	algo := RC4_128bit
-	if options != nil {
+	if w.pdf20 {
+		algo = AES_256bit
+	}
+	if options != nil && (!w.pdf20 || options.Algorithm != RC4_128bit) {
 		algo = options.Algorithm
 	}
+	if w.pdf20 && algo != AES_256bit {
+		return &Pdf20Error{Reason: "only AES-256 encryption is allowed"}
+	}
 	perm := security.PermOwner
 	if options != nil {
 		perm = options.Permissions
 	}
 
-	var cf crypt.Filter
+	cf, err := newCryptFilter(algo)
+	if err != nil {
+		return err
+	}
+	crypter, info, err := core.PdfCryptNewEncryptConfig(cf, userPass, ownerPass, perm, options.cryptConfig())
+	if err != nil {
+		return err
+	}
+	w.setCrypter(crypter, info)
+	return nil
+}
+
+// EncryptWithCertificates encrypts the output file for a set of recipients using the public-key
+// security handler (Adobe.PubSec, adbe.pkcs7.s5). Each recipient can open the document with a private
+// key corresponding to its certificate and is granted the permissions specified for it.
+// AES-256 is used by default; the Permissions field of the options is ignored.
+func (w *PdfWriter) EncryptWithCertificates(recipients []security.Recipient, options *EncryptOptions) error {
+	algo := AES_256bit
+	if options != nil {
+		algo = options.Algorithm
+	}
+	if w.pdf20 && algo != AES_256bit {
+		return &Pdf20Error{Reason: "only AES-256 encryption is allowed"}
+	}
+
+	cf, err := newCryptFilter(algo)
+	if err != nil {
+		return err
+	}
+	crypter, info, err := core.PdfCryptNewEncryptPubKey(cf, recipients, options.cryptConfig())
+	if err != nil {
+		return err
+	}
+	w.setCrypter(crypter, info)
+	return nil
+}
+
+// newCryptFilter creates a crypt filter for the specified encryption algorithm.
+func newCryptFilter(algo EncryptionAlgorithm) (crypt.Filter, error) {
 	switch algo {
 	case RC4_128bit:
-		cf = crypt.NewFilterV2(16)
+		return crypt.NewFilterV2(16), nil
 	case AES_128bit:
-		cf = crypt.NewFilterAESV2()
+		return crypt.NewFilterAESV2(), nil
 	case AES_256bit:
-		cf = crypt.NewFilterAESV3()
-	default:
-		return fmt.Errorf("unsupported algorithm: %v", options.Algorithm)
-	}
-	crypter, info, err := core.PdfCryptNewEncrypt(cf, userPass, ownerPass, perm)
-	if err != nil {
-		return err
+		return crypt.NewFilterAESV3(), nil
 	}
+	return nil, fmt.Errorf("unsupported algorithm: %v", algo)
+}
+
+// setCrypter sets the document crypter and prepares the encryption dictionary.
+func (w *PdfWriter) setCrypter(crypter *core.PdfCrypt, info *core.EncryptInfo) {
 	w.crypter = crypter
 	if info.Major != 0 {
 		w.SetVersion(info.Major, info.Minor)
@@ -950,8 +1173,6 @@ func (w *PdfWriter) Encrypt(userPass, ownerPass []byte, options *EncryptOptions)
 	io := core.MakeIndirectObject(info.Encrypt)
 	w.encryptObj = io
 	w.addObject(io)
-
-	return nil
 }
 
 // Wrapper function to handle writing out string.
@@ -984,46 +1205,32 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 	// 	fmt.Printf("To get rid of the watermark - Please get a license on https://unidoc.io\n")
 	// }
 
-	// Outlines.
-	if w.outlineTree != nil {
-		common.Log.Trace("OutlineTree: %+v", w.outlineTree)
-		outlines := w.outlineTree.ToPdfObject()
-		common.Log.Trace("Outlines: %+v (%T, p:%p)", outlines, outlines, outlines)
-		w.catalog.Set("Outlines", outlines)
-		err := w.addObjects(outlines)
-		if err != nil {
+	if w.streaming != nil {
+		return errors.New("writer is streaming, use FinishStreaming")
+	}
+
+	if err := w.addDocumentObjects(); err != nil {
+		return err
+	}
+	w.resolvePendingObjects()
+
+	// PDF/A conversion and metadata.
+	if w.pdfa == nil && !w.pdf20 && w.xmp != nil {
+		if _, err := w.writeXMPMetadata(w.xmp); err != nil {
 			return err
 		}
 	}
-
-	// Form fields.
-	if w.acroForm != nil {
-		common.Log.Trace("Writing acro forms")
-		indObj := w.acroForm.ToPdfObject()
-		common.Log.Trace("AcroForm: %+v", indObj)
-		w.catalog.Set("AcroForm", indObj)
-		err := w.addObjects(indObj)
-		if err != nil {
+	if w.pdfa != nil {
+		if err := w.applyPdfA(); err != nil {
 			return err
 		}
 	}
-
-	// Check pending objects prior to write.
-	for pendingObj, pendingObjDicts := range w.pendingObjects {
-		if !w.hasObject(pendingObj) {
-			common.Log.Debug("WARN Pending object %+v %T (%p) never added for writing", pendingObj, pendingObj, pendingObj)
-			for _, pendingObjDict := range pendingObjDicts {
-				for _, key := range pendingObjDict.Keys() {
-					val := pendingObjDict.Get(key)
-					if val == pendingObj {
-						common.Log.Debug("Pending object found! and replaced with null")
-						pendingObjDict.Set(key, core.MakeNull())
-						break
-					}
-				}
-			}
+	if w.pdf20 {
+		if err := w.applyPdf20(); err != nil {
+			return err
 		}
 	}
+
 	// Set version in the catalog.
 	w.catalog.Set("Version", core.MakeName(fmt.Sprintf("%d.%d", w.majorVersion, w.minorVersion)))
 
@@ -1067,6 +1274,18 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		}
 	}
 
+	if w.pdfa != nil && w.pdfa.Conformance == PdfA1B && len(objectsInObjectStreams) != 0 {
+		return &PdfAError{Conformance: PdfA1B, Reason: "object streams are not allowed"}
+	}
+	if w.linearized {
+		if w.appendMode {
+			return errors.New("linearized output is not supported in append mode")
+		}
+		if len(objectsInObjectStreams) != 0 {
+			return errors.New("object streams are not supported in linearized output")
+		}
+		return w.writeLinearized()
+	}
 	if useCrossReferenceStream && w.majorVersion == 1 && w.minorVersion < 5 {
 		w.minorVersion = 5
 	}
@@ -1131,6 +1350,71 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		w.writeObject(int(objectNumber), obj)
 	}
 
+	return w.writeXref(useCrossReferenceStream)
+}
+
+// addDocumentObjects adds the document-level objects (outlines, forms and embedded files) to the
+// catalog and the objects to write.
+func (w *PdfWriter) addDocumentObjects() error {
+	// Outlines.
+	if w.outlineTree != nil {
+		common.Log.Trace("OutlineTree: %+v", w.outlineTree)
+		outlines := w.outlineTree.ToPdfObject()
+		common.Log.Trace("Outlines: %+v (%T, p:%p)", outlines, outlines, outlines)
+		w.catalog.Set("Outlines", outlines)
+		err := w.addObjects(outlines)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Form fields.
+	if w.acroForm != nil {
+		common.Log.Trace("Writing acro forms")
+		indObj := w.acroForm.ToPdfObject()
+		common.Log.Trace("AcroForm: %+v", indObj)
+		w.catalog.Set("AcroForm", indObj)
+		err := w.addObjects(indObj)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Embedded files.
+	if w.pdfa != nil {
+		if err := w.checkPdfAEmbeddedFiles(); err != nil {
+			return err
+		}
+	}
+	if err := w.writeEmbeddedFiles(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resolvePendingObjects replaces the references to pending objects that have never been added
+// for writing with null objects.
+func (w *PdfWriter) resolvePendingObjects() {
+	for pendingObj, pendingObjDicts := range w.pendingObjects {
+		if !w.hasObject(pendingObj) {
+			common.Log.Debug("WARN Pending object %+v %T (%p) never added for writing", pendingObj, pendingObj, pendingObj)
+			for _, pendingObjDict := range pendingObjDicts {
+				for _, key := range pendingObjDict.Keys() {
+					val := pendingObjDict.Get(key)
+					if val == pendingObj {
+						common.Log.Debug("Pending object found! and replaced with null")
+						pendingObjDict.Set(key, core.MakeNull())
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// writeXref writes the cross-reference table or stream (if `useCrossReferenceStream` is true) of
+// the objects written so far, followed by the trailer, and flushes the output.
+func (w *PdfWriter) writeXref(useCrossReferenceStream bool) error {
 	xrefOffset := w.writePos
 	var maxIndex int
 	for idx := range w.crossReferenceMap {
@@ -1203,6 +1487,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// If encrypted!
 		if w.crypter != nil {
 			crossReferenceStream.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
 			crossReferenceStream.Set("ID", w.ids)
 			common.Log.Trace("Ids: %s", w.ids)
 		}
@@ -1256,6 +1542,8 @@ func (w *PdfWriter) Write(writer io.Writer) error {
 		// If encrypted!
 		if w.crypter != nil {
 			trailer.Set("Encrypt", w.encryptObj)
+		}
+		if w.ids != nil {
 			trailer.Set("ID", w.ids)
 			common.Log.Trace("Ids: %s", w.ids)
 		}
@@ -0,0 +1,231 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/carmel/unipdf/common"
+	"github.com/carmel/unipdf/core"
+)
+
+// streamingState is the state of a writer in streaming mode.
+type streamingState struct {
+	// Last object number assigned. Objects are numbered as they are added to the writer.
+	lastNumber int64
+
+	// Objects which are numbered but only written by FinishStreaming, as they can change until
+	// then (catalog, page tree, document information and parents of written objects).
+	deferred    []core.PdfObject
+	deferredMap map[core.PdfObject]struct{}
+
+	finished bool
+}
+
+// number assigns the next object number to `obj`.
+func (s *streamingState) number(obj core.PdfObject) {
+	s.lastNumber++
+	switch t := obj.(type) {
+	case *core.PdfIndirectObject:
+		t.ObjectNumber = s.lastNumber
+		t.GenerationNumber = 0
+	case *core.PdfObjectStream:
+		t.ObjectNumber = s.lastNumber
+		t.GenerationNumber = 0
+	}
+}
+
+// deferObject marks `obj` for writing by FinishStreaming.
+func (s *streamingState) deferObject(obj core.PdfObject) {
+	if _, ok := s.deferredMap[obj]; ok {
+		return
+	}
+	s.deferred = append(s.deferred, obj)
+	s.deferredMap[obj] = struct{}{}
+}
+
+// StartStreaming switches the writer to streaming output to `out`, for generating documents too
+// large to be kept in memory. The file header is written immediately, and each page added with
+// AddPage is written out right away together with the objects it references. The page tree,
+// catalog, document information, outlines, forms and cross-reference table are written by
+// FinishStreaming, which must be called once all pages have been added. Write cannot be used on
+// a streaming writer.
+//
+// Objects shared between pages, such as fonts and images, are written when first referenced and
+// must not be modified afterwards. The writer keeps track of them until the end, but releases the
+// data of the written streams and the content streams of the written pages. As a result, the
+// streams of pages loaded with a PdfReader cannot be read from it anymore once written.
+// Streaming is not supported in append mode, nor together with encryption, optimization,
+// linearization, PDF/A or PDF 2.0 output. The version must be set before calling StartStreaming.
+func (w *PdfWriter) StartStreaming(out io.Writer) error {
+	switch {
+	case w.streaming != nil:
+		return errors.New("writer is already streaming")
+	case w.appendMode:
+		return errors.New("streaming is not supported in append mode")
+	case w.crypter != nil:
+		return errors.New("streaming is not supported with encryption")
+	case w.optimizer != nil:
+		return errors.New("streaming is not supported with an optimizer")
+	case w.linearized:
+		return errors.New("streaming is not supported with linearized output")
+	case w.pdfa != nil:
+		return errors.New("streaming is not supported with PDF/A output")
+	case w.pdf20:
+		return errors.New("streaming is not supported with PDF 2.0 output")
+	}
+
+	w.streaming = &streamingState{deferredMap: map[core.PdfObject]struct{}{}}
+	for _, obj := range []core.PdfObject{w.infoObj, w.root, w.pages} {
+		w.streaming.deferObject(obj)
+	}
+	for _, obj := range w.objects {
+		w.streaming.number(obj)
+	}
+
+	w.writer = bufio.NewWriter(out)
+	w.writePos = 0
+	w.crossReferenceMap = map[int]crossReference{
+		0: {Type: 0, ObjectNumber: 0, Generation: 0xFFFF},
+	}
+	w.writeString(fmt.Sprintf("%%PDF-%d.%d\n", w.majorVersion, w.minorVersion))
+	w.writeString("%âãÏÓ\n")
+
+	// Write the pages added so far.
+	return w.flushStreaming()
+}
+
+// flushStreaming writes out the objects added since the last flush, except the deferred ones.
+func (w *PdfWriter) flushStreaming() error {
+	// Parents which have not been added yet are numbered now, so that they can be referenced, but
+	// written at the end as they may still change (e.g. form fields getting more kids).
+	for pendingObj := range w.pendingObjects {
+		switch pendingObj.(type) {
+		case *core.PdfIndirectObject, *core.PdfObjectStream:
+		default:
+			continue
+		}
+		if w.addObject(pendingObj) {
+			w.streaming.deferObject(pendingObj)
+		}
+		delete(w.pendingObjects, pendingObj)
+	}
+
+	for _, obj := range w.objects {
+		if _, ok := w.streaming.deferredMap[obj]; ok {
+			continue
+		}
+		w.writeObject(int(objectNumber(obj)), obj)
+
+		// The data of written streams (images, forms, appearance streams...) is released. The
+		// streams are still tracked by w.objectsMap, so that later references reuse their numbers
+		// instead of writing them again, but only their dictionaries are kept.
+		if stream, ok := obj.(*core.PdfObjectStream); ok {
+			stream.Stream = nil
+		}
+	}
+	w.objects = nil
+	// The traversal cache is only needed while adding objects and would keep all the written
+	// objects in memory.
+	w.traversed = map[core.PdfObject]struct{}{}
+
+	if w.werr == nil {
+		w.werr = w.writer.Flush()
+	}
+	return w.werr
+}
+
+// flushStreamingPage writes out the objects of the page with dictionary `pDict`, which has just
+// been added, and releases its content streams altogether.
+func (w *PdfWriter) flushStreamingPage(pDict *core.PdfObjectDictionary) error {
+	if err := w.flushStreaming(); err != nil {
+		return err
+	}
+
+	// The page dictionary is kept (it can be referenced by outlines and annotations written
+	// later), but its content streams are replaced by objects which only hold their numbers.
+	release := func(obj core.PdfObject) core.PdfObject {
+		stream, ok := obj.(*core.PdfObjectStream)
+		if !ok {
+			return obj
+		}
+		delete(w.objectsMap, stream)
+		return &core.PdfIndirectObject{PdfObjectReference: stream.PdfObjectReference, PdfObject: core.MakeNull()}
+	}
+	switch t := pDict.Get("Contents").(type) {
+	case *core.PdfObjectStream:
+		pDict.Set("Contents", release(t))
+	case *core.PdfObjectArray:
+		contents := core.MakeArray()
+		for _, obj := range t.Elements() {
+			contents.Append(release(obj))
+		}
+		pDict.Set("Contents", contents)
+	}
+	return nil
+}
+
+// FinishStreaming completes the output of a streaming writer, see StartStreaming. The remaining
+// objects (page tree, catalog, document information, outlines, forms, embedded files and XMP
+// metadata) are written, followed by the cross-reference table and the trailer.
+func (w *PdfWriter) FinishStreaming() error {
+	if w.streaming == nil {
+		return errors.New("writer is not streaming")
+	}
+	if w.streaming.finished {
+		return errors.New("streaming output already finished")
+	}
+	w.streaming.finished = true
+	if w.crypter != nil {
+		return errors.New("streaming is not supported with encryption")
+	}
+
+	if err := w.addDocumentObjects(); err != nil {
+		return err
+	}
+	if w.xmp != nil {
+		if _, err := w.writeXMPMetadata(w.xmp); err != nil {
+			return err
+		}
+	}
+
+	// The deferred objects are complete now, add the objects they reference.
+	for _, obj := range w.streaming.deferred {
+		var err error
+		switch t := obj.(type) {
+		case *core.PdfIndirectObject:
+			err = w.addObjects(t.PdfObject)
+		case *core.PdfObjectStream:
+			err = w.addObjects(t.PdfObjectDictionary)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	w.resolvePendingObjects()
+
+	useCrossReferenceStream := w.majorVersion > 1 || (w.majorVersion == 1 && w.minorVersion > 4)
+	if w.useCrossReferenceStream != nil {
+		useCrossReferenceStream = *w.useCrossReferenceStream
+	}
+	if useCrossReferenceStream && w.majorVersion == 1 && w.minorVersion < 5 {
+		// The header has been written already, the catalog version takes precedence.
+		w.minorVersion = 5
+	}
+	w.catalog.Set("Version", core.MakeName(fmt.Sprintf("%d.%d", w.majorVersion, w.minorVersion)))
+
+	common.Log.Trace("Writing %d remaining objects", len(w.objects)+len(w.streaming.deferred))
+	for _, obj := range append(w.objects, w.streaming.deferred...) {
+		w.writeObject(int(objectNumber(obj)), obj)
+	}
+	w.objects = nil
+	w.streaming.deferred = nil
+
+	return w.writeXref(useCrossReferenceStream)
+}
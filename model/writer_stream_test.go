@@ -0,0 +1,154 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package model
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core"
+)
+
+// writeStreamingTestFile writes a document of `numPages` pages using a shared font with a
+// streaming writer, and an outline item pointing to the second page.
+func writeStreamingTestFile(t *testing.T, numPages int, xrefStream bool) []byte {
+	helvetica, err := NewStandard14Font(HelveticaName)
+	require.NoError(t, err)
+	font := helvetica.ToPdfObject()
+
+	var buf bytes.Buffer
+	w := NewPdfWriter()
+	if xrefStream {
+		w.SetVersion(1, 5)
+	}
+	w.SetDocInfo(&PdfInfo{Title: core.MakeString("Streamed")})
+	require.NoError(t, w.StartStreaming(&buf))
+	require.Error(t, w.StartStreaming(&buf))
+
+	var pages []*PdfPage
+	for i := 1; i <= numPages; i++ {
+		page := NewPdfPage()
+		require.NoError(t, page.AddFont("F1", font))
+		content := fmt.Sprintf("BT /F1 12 Tf 10 10 Td (Page %d) Tj ET", i)
+		require.NoError(t, page.SetContentStreams([]string{content}, core.NewFlateEncoder()))
+		require.NoError(t, w.AddPage(page))
+		pages = append(pages, page)
+
+		// Written objects are not kept.
+		require.Empty(t, w.objects)
+		require.Greater(t, buf.Len(), 0)
+	}
+
+	outline := NewOutline()
+	dest := NewOutlineDest(1, 0, 0)
+	dest.PageObj = pages[1].GetPageAsIndirectObject()
+	outline.Add(NewOutlineItem("Second page", dest))
+	w.AddOutlineTree(&outline.ToPdfOutline().PdfOutlineTreeNode)
+
+	require.Error(t, w.Write(&bytes.Buffer{}))
+	require.NoError(t, w.FinishStreaming())
+	require.Error(t, w.FinishStreaming())
+	return buf.Bytes()
+}
+
+func TestStreamingWriter(t *testing.T) {
+	for _, xrefStream := range []bool{false, true} {
+		data := writeStreamingTestFile(t, 20, xrefStream)
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 20, numPages)
+
+		var fontNum int64
+		for i, page := range reader.PageList {
+			content, err := page.GetAllContentStreams()
+			require.NoError(t, err)
+			require.Contains(t, content, fmt.Sprintf("(Page %d)", i+1))
+
+			// The font is written once.
+			fonts, ok := core.GetDict(page.Resources.Font)
+			require.True(t, ok)
+			font, ok := core.GetIndirect(fonts.Get("F1"))
+			require.True(t, ok)
+			if i == 0 {
+				fontNum = font.ObjectNumber
+			}
+			require.Equal(t, fontNum, font.ObjectNumber)
+		}
+
+		info, err := reader.GetPdfInfo()
+		require.NoError(t, err)
+		require.Equal(t, "Streamed", info.Title.Decoded())
+
+		outlines, err := reader.GetOutlines()
+		require.NoError(t, err)
+		require.Len(t, outlines.Entries, 1)
+		require.Equal(t, int64(1), outlines.Entries[0].Dest.Page)
+	}
+}
+
+func TestStreamingWriterUnsupported(t *testing.T) {
+	w := NewPdfWriter()
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), nil))
+	require.Error(t, w.StartStreaming(&bytes.Buffer{}))
+
+	w = NewPdfWriter()
+	w.SetLinearized(true)
+	require.Error(t, w.StartStreaming(&bytes.Buffer{}))
+
+	w = NewPdfWriter()
+	require.Error(t, w.FinishStreaming())
+}
+
+func TestStreamingWriterReleasesStreams(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPdfWriter()
+	require.NoError(t, w.StartStreaming(&buf))
+
+	// Each page has its own image, whose data is released once the page is written.
+	const numPages = 50
+	var images []*core.PdfObjectStream
+	for i := 0; i < numPages; i++ {
+		img := &Image{
+			Width:            64,
+			Height:           64,
+			BitsPerComponent: 8,
+			ColorComponents:  1,
+			Data:             bytes.Repeat([]byte{byte(i)}, 64*64),
+		}
+		ximg, err := NewXObjectImageFromImage(img, nil, core.NewFlateEncoder())
+		require.NoError(t, err)
+		stream, ok := ximg.ToPdfObject().(*core.PdfObjectStream)
+		require.True(t, ok)
+		require.NotEmpty(t, stream.Stream)
+
+		page := NewPdfPage()
+		require.NoError(t, page.AddImageResource("Im1", ximg))
+		require.NoError(t, page.SetContentStreams([]string{"q 64 0 0 64 0 0 cm /Im1 Do Q"}, core.NewFlateEncoder()))
+		require.NoError(t, w.AddPage(page))
+		require.Nil(t, stream.Stream)
+		images = append(images, stream)
+	}
+	require.NoError(t, w.FinishStreaming())
+
+	// The images are written once each, with their data.
+	reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, reader.PageList, numPages)
+	for i, page := range reader.PageList {
+		ximg, err := page.Resources.GetXObjectImageByName("Im1")
+		require.NoError(t, err)
+		require.Equal(t, images[i].ObjectNumber, ximg.primitive.ObjectNumber)
+		img, err := ximg.ToImage()
+		require.NoError(t, err)
+		require.Equal(t, bytes.Repeat([]byte{byte(i)}, 64*64), img.Data)
+	}
+}
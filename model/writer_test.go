@@ -7,11 +7,17 @@ package model
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/core/security"
+	"github.com/carmel/unipdf/model/xmputil"
 )
 
 // Tests loading annotations from file, writing back out and reloading.
@@ -127,3 +133,161 @@ func TestWriterErrorHandling(t *testing.T) {
 	err = w.Write(&out)
 	require.Error(t, err)
 }
+
+// loadTestRecipient loads the test certificate and private key from testdata.
+func loadTestRecipient(t *testing.T) (*x509.Certificate, crypto.PrivateKey) {
+	data, err := os.ReadFile("testdata/pubcert.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+
+	data, err = os.ReadFile("testdata/privkey.pem")
+	require.NoError(t, err)
+	block, _ = pem.Decode(data)
+	require.NotNil(t, block)
+	pkey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	return cert, pkey
+}
+
+// TestWriterEncryptWithCertificates tests writing and reading back a document
+// encrypted with the public-key security handler.
+func TestWriterEncryptWithCertificates(t *testing.T) {
+	cert, pkey := loadTestRecipient(t)
+	perms := security.PermPrinting | security.PermExtractGraphics
+
+	for _, algo := range []EncryptionAlgorithm{RC4_128bit, AES_128bit, AES_256bit} {
+		w := NewPdfWriter()
+		page := NewPdfPage()
+		page.AddContentStreamByString("BT /F1 12 Tf (Hello) Tj ET")
+		require.NoError(t, w.AddPage(page))
+		err := w.EncryptWithCertificates([]security.Recipient{
+			{Certificate: cert, Permissions: perms},
+		}, &EncryptOptions{Algorithm: algo})
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, w.Write(&buf))
+
+		reader, err := NewPdfReader(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		encrypted, err := reader.IsEncrypted()
+		require.NoError(t, err)
+		require.True(t, encrypted)
+
+		ok, err := reader.Decrypt([]byte(""))
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		ok, err = reader.DecryptWithCertificate(cert, pkey)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, perms, reader.GetAccessPermissions())
+
+		numPages, err := reader.GetNumPages()
+		require.NoError(t, err)
+		require.Equal(t, 1, numPages)
+		p, err := reader.GetPage(1)
+		require.NoError(t, err)
+		content, err := p.GetAllContentStreams()
+		require.NoError(t, err)
+		require.Equal(t, "BT /F1 12 Tf (Hello) Tj ET", content)
+	}
+}
+
+// writeEncryptOptionsTestFile writes a document with an embedded file and XMP metadata, encrypted
+// with `options`.
+func writeEncryptOptionsTestFile(t *testing.T, options *EncryptOptions) []byte {
+	w := NewPdfWriter()
+	page := NewPdfPage()
+	page.AddContentStreamByString("BT /F1 12 Tf (Hello) Tj ET")
+	require.NoError(t, w.AddPage(page))
+	require.NoError(t, w.AddEmbeddedFile(NewEmbeddedFile("secret.txt", []byte("secret content"))))
+	packet := xmputil.New()
+	packet.SetText(xmputil.NsXMP, "Label", "plain-label")
+	w.SetXMP(packet)
+	require.NoError(t, w.Encrypt([]byte("user"), []byte("owner"), options))
+
+	var buf bytes.Buffer
+	require.NoError(t, w.Write(&buf))
+	return buf.Bytes()
+}
+
+func TestWriterEncryptEmbeddedFilesOnly(t *testing.T) {
+	data := writeEncryptOptionsTestFile(t, &EncryptOptions{
+		Algorithm:         AES_256bit,
+		Permissions:       security.PermOwner,
+		EmbeddedFilesOnly: true,
+	})
+	require.NotContains(t, string(data), "secret content")
+
+	// The document is read without authentication, but its embedded files.
+	reader, err := NewPdfReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	encrypted, err := reader.IsEncrypted()
+	require.NoError(t, err)
+	require.True(t, encrypted)
+	numPages, err := reader.GetNumPages()
+	require.NoError(t, err)
+	require.Equal(t, 1, numPages)
+	page, err := reader.GetPage(1)
+	require.NoError(t, err)
+	content, err := page.GetAllContentStreams()
+	require.NoError(t, err)
+	require.Equal(t, "BT /F1 12 Tf (Hello) Tj ET", content)
+	xmp, err := reader.GetXMP()
+	require.NoError(t, err)
+	require.Equal(t, "plain-label", xmp.Text(xmputil.NsXMP, "Label"))
+
+	entries, err := reader.embeddedFileEntries()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	fs, err := NewPdfFilespecFromObj(entries[0].Value)
+	require.NoError(t, err)
+	_, err = NewEmbeddedFileFromFilespec(fs)
+	require.Error(t, err)
+
+	// The embedded files are decrypted with the password.
+	ok, err := reader.Decrypt([]byte("wrong"))
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = reader.Decrypt([]byte("user"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	files, err := reader.GetEmbeddedFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, []byte("secret content"), files[0].Content)
+	require.Equal(t, 1, len(reader.PageList))
+}
+
+func TestWriterEncryptPlainMetadata(t *testing.T) {
+	for _, plain := range []bool{false, true} {
+		data := writeEncryptOptionsTestFile(t, &EncryptOptions{
+			Algorithm:     AES_128bit,
+			Permissions:   security.PermOwner,
+			PlainMetadata: plain,
+		})
+
+		// The metadata can be read from the file by indexers.
+		require.Equal(t, plain, bytes.Contains(data, []byte("plain-label")))
+
+		reader, err := NewPdfReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		_, err = reader.GetNumPages()
+		require.Error(t, err)
+		ok, err := reader.Decrypt([]byte("user"))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		xmp, err := reader.GetXMP()
+		require.NoError(t, err)
+		require.Equal(t, "plain-label", xmp.Text(xmputil.NsXMP, "Label"))
+		files, err := reader.GetEmbeddedFiles()
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, []byte("secret content"), files[0].Content)
+	}
+}
@@ -0,0 +1,12 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+// Package xmputil provides a parser and serializer for XMP metadata packets (ISO 16684-1), as
+// used for the document-level Metadata stream of PDF files.
+//
+// A packet is represented as an ordered list of top-level properties, each identified by its
+// namespace URI and local name. Simple text values, language alternatives and ordered/unordered
+// arrays can be read and modified; other (structured) property values are preserved as-is.
+package xmputil
@@ -0,0 +1,629 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package xmputil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Common XMP namespaces.
+const (
+	NsX       = "adobe:ns:meta/"
+	NsRDF     = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	NsXML     = "http://www.w3.org/XML/1998/namespace"
+	NsDC      = "http://purl.org/dc/elements/1.1/"
+	NsXMP     = "http://ns.adobe.com/xap/1.0/"
+	NsXMPMM   = "http://ns.adobe.com/xap/1.0/mm/"
+	NsPDF     = "http://ns.adobe.com/pdf/1.3/"
+	NsPDFX    = "http://ns.adobe.com/pdfx/1.3/"
+	NsPdfAID  = "http://www.aiim.org/pdfa/ns/id/"
+	NsPdfUAID = "http://www.aiim.org/pdfua/ns/id/"
+)
+
+// defaultPrefixes are the preferred prefixes of the common namespaces.
+var defaultPrefixes = map[string]string{
+	NsX:       "x",
+	NsRDF:     "rdf",
+	NsXML:     "xml",
+	NsDC:      "dc",
+	NsXMP:     "xmp",
+	NsXMPMM:   "xmpMM",
+	NsPDF:     "pdf",
+	NsPDFX:    "pdfx",
+	NsPdfAID:  "pdfaid",
+	NsPdfUAID: "pdfuaid",
+}
+
+// ErrNoRDF is returned when parsing an XMP packet that does not contain an rdf:RDF element.
+var ErrNoRDF = errors.New("xmp: rdf:RDF element not found")
+
+// Kind is the kind of an XMP property value.
+type Kind int
+
+// Kinds of property values.
+const (
+	// KindText is a simple text value.
+	KindText Kind = iota
+	// KindLangAlt is a language alternative (rdf:Alt), e.g. dc:title.
+	KindLangAlt
+	// KindSeq is an ordered array (rdf:Seq), e.g. dc:creator.
+	KindSeq
+	// KindBag is an unordered array (rdf:Bag), e.g. dc:subject.
+	KindBag
+	// KindStructured is any other value (structures, qualified values, resources), which is
+	// preserved but cannot be accessed as text.
+	KindStructured
+)
+
+// Item is an item of an array or language alternative value.
+type Item struct {
+	// Lang is the xml:lang qualifier of the item, e.g. "x-default". Only used by language
+	// alternatives.
+	Lang  string
+	Value string
+}
+
+// Property is a top-level XMP property.
+type Property struct {
+	Namespace string
+	Name      string
+	Kind      Kind
+
+	// Value is the value of KindText properties.
+	Value string
+	// Items are the items of KindLangAlt, KindSeq and KindBag properties.
+	Items []Item
+
+	// node is the parsed element of KindStructured properties.
+	node *node
+}
+
+// Text returns the value of the property as a string: the value of text properties, the default
+// (or first) item of language alternatives and the first item of arrays.
+func (prop *Property) Text() string {
+	switch prop.Kind {
+	case KindText:
+		return prop.Value
+	case KindLangAlt:
+		if i := prop.defaultItem(); i >= 0 {
+			return prop.Items[i].Value
+		}
+	case KindSeq, KindBag:
+		if len(prop.Items) > 0 {
+			return prop.Items[0].Value
+		}
+	}
+	return ""
+}
+
+// Values returns the values of the items of array and language alternative properties, or the
+// value of text properties as a single element slice.
+func (prop *Property) Values() []string {
+	switch prop.Kind {
+	case KindText:
+		return []string{prop.Value}
+	case KindLangAlt, KindSeq, KindBag:
+		values := make([]string, len(prop.Items))
+		for i, item := range prop.Items {
+			values[i] = item.Value
+		}
+		return values
+	}
+	return nil
+}
+
+// defaultItem returns the index of the x-default item of a language alternative, or of the
+// first item if there is none. Returns -1 if there are no items.
+func (prop *Property) defaultItem() int {
+	for i, item := range prop.Items {
+		if item.Lang == "x-default" {
+			return i
+		}
+	}
+	if len(prop.Items) > 0 {
+		return 0
+	}
+	return -1
+}
+
+// Packet is an XMP metadata packet.
+type Packet struct {
+	props []*Property
+
+	// Namespace prefixes (URI to prefix) as declared in the parsed packet or registered.
+	prefixes map[string]string
+}
+
+// New returns a new empty XMP packet.
+func New() *Packet {
+	return &Packet{prefixes: map[string]string{}}
+}
+
+// Parse parses the XMP packet `data`. The top-level properties are collected from all the
+// rdf:Description elements of the packet.
+func Parse(data []byte) (*Packet, error) {
+	root, err := parseNodes(data)
+	if err != nil {
+		return nil, err
+	}
+	rdf := root.find(NsRDF, "RDF")
+	if rdf == nil {
+		return nil, ErrNoRDF
+	}
+
+	p := New()
+	root.collectPrefixes(p.prefixes)
+	for _, desc := range rdf.children {
+		if !desc.is(NsRDF, "Description") {
+			continue
+		}
+		for _, attr := range desc.attr {
+			if isSyntaxAttr(attr.Name) {
+				continue
+			}
+			p.Set(&Property{Namespace: attr.Name.Space, Name: attr.Name.Local, Kind: KindText, Value: attr.Value})
+		}
+		for _, child := range desc.children {
+			p.Set(parseProperty(child))
+		}
+	}
+	return p, nil
+}
+
+// parseProperty returns the property represented by element `n`.
+func parseProperty(n *node) *Property {
+	prop := &Property{Namespace: n.name.Space, Name: n.name.Local, Kind: KindStructured, node: n}
+	for _, attr := range n.attr {
+		if attr.Name.Space != "xmlns" && !(attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			// Qualifiers, resources and parse types are not handled as simple values.
+			return prop
+		}
+	}
+
+	switch {
+	case len(n.children) == 0:
+		prop.Kind = KindText
+		prop.Value = n.text
+	case len(n.children) == 1:
+		container := n.children[0]
+		var kind Kind
+		switch {
+		case container.is(NsRDF, "Alt"):
+			kind = KindLangAlt
+		case container.is(NsRDF, "Seq"):
+			kind = KindSeq
+		case container.is(NsRDF, "Bag"):
+			kind = KindBag
+		default:
+			return prop
+		}
+		var items []Item
+		for _, li := range container.children {
+			if !li.is(NsRDF, "li") || len(li.children) > 0 {
+				return prop
+			}
+			item := Item{Value: li.text}
+			for _, attr := range li.attr {
+				switch {
+				case attr.Name.Space == NsXML && attr.Name.Local == "lang":
+					item.Lang = attr.Value
+				case attr.Name.Space != "xmlns":
+					return prop
+				}
+			}
+			items = append(items, item)
+		}
+		prop.Kind = kind
+		prop.Items = items
+	}
+	if prop.Kind != KindStructured {
+		prop.node = nil
+	}
+	return prop
+}
+
+// Properties returns the top-level properties of the packet in document order. The returned
+// properties can be modified in place.
+func (p *Packet) Properties() []*Property {
+	props := make([]*Property, len(p.props))
+	copy(props, p.props)
+	return props
+}
+
+// Property returns the property `name` of namespace `ns`, or nil if not present.
+func (p *Packet) Property(ns, name string) *Property {
+	for _, prop := range p.props {
+		if prop.Namespace == ns && prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// Has returns true if the packet contains property `name` of namespace `ns`.
+func (p *Packet) Has(ns, name string) bool {
+	return p.Property(ns, name) != nil
+}
+
+// Set adds property `prop` to the packet, replacing any property with the same name.
+func (p *Packet) Set(prop *Property) {
+	for i, old := range p.props {
+		if old.Namespace == prop.Namespace && old.Name == prop.Name {
+			p.props[i] = prop
+			return
+		}
+	}
+	p.props = append(p.props, prop)
+}
+
+// Remove removes property `name` of namespace `ns`. Returns true if the property was present.
+func (p *Packet) Remove(ns, name string) bool {
+	for i, prop := range p.props {
+		if prop.Namespace == ns && prop.Name == name {
+			p.props = append(p.props[:i], p.props[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Text returns the text value of property `name` of namespace `ns` (see Property.Text), or an
+// empty string if not present.
+func (p *Packet) Text(ns, name string) string {
+	if prop := p.Property(ns, name); prop != nil {
+		return prop.Text()
+	}
+	return ""
+}
+
+// Values returns the values of property `name` of namespace `ns` (see Property.Values), or nil
+// if not present.
+func (p *Packet) Values(ns, name string) []string {
+	if prop := p.Property(ns, name); prop != nil {
+		return prop.Values()
+	}
+	return nil
+}
+
+// SetText sets property `name` of namespace `ns` to the simple text `value`.
+func (p *Packet) SetText(ns, name, value string) {
+	p.Set(&Property{Namespace: ns, Name: name, Kind: KindText, Value: value})
+}
+
+// SetLangAlt sets the default language (x-default) item of language alternative property
+// `name` of namespace `ns` to `value`. Other languages of an existing property are kept.
+func (p *Packet) SetLangAlt(ns, name, value string) {
+	prop := p.Property(ns, name)
+	if prop == nil || prop.Kind != KindLangAlt {
+		p.Set(&Property{Namespace: ns, Name: name, Kind: KindLangAlt,
+			Items: []Item{{Lang: "x-default", Value: value}}})
+		return
+	}
+	if i := prop.defaultItem(); i >= 0 {
+		prop.Items[i] = Item{Lang: "x-default", Value: value}
+		return
+	}
+	prop.Items = []Item{{Lang: "x-default", Value: value}}
+}
+
+// SetArray sets property `name` of namespace `ns` to an array of kind `kind` (KindSeq or
+// KindBag) with items `values`.
+func (p *Packet) SetArray(ns, name string, kind Kind, values []string) error {
+	if kind != KindSeq && kind != KindBag {
+		return fmt.Errorf("xmp: invalid array kind %d", kind)
+	}
+	items := make([]Item, len(values))
+	for i, v := range values {
+		items[i] = Item{Value: v}
+	}
+	p.Set(&Property{Namespace: ns, Name: name, Kind: kind, Items: items})
+	return nil
+}
+
+// Date returns the date value of property `name` of namespace `ns`. The second return value is
+// false if the property is missing or is not a valid date.
+func (p *Packet) Date(ns, name string) (time.Time, bool) {
+	return ParseDate(p.Text(ns, name))
+}
+
+// SetDate sets property `name` of namespace `ns` to date `t`.
+func (p *Packet) SetDate(ns, name string, t time.Time) {
+	p.SetText(ns, name, FormatDate(t))
+}
+
+// RegisterNamespace sets the prefix used for namespace `ns` when serializing the packet.
+func (p *Packet) RegisterNamespace(ns, prefix string) {
+	p.prefixes[ns] = prefix
+}
+
+// FormatDate formats `t` as an XMP date.
+func FormatDate(t time.Time) string {
+	return t.Format("2006-01-02T15:04:05Z07:00")
+}
+
+// ParseDate parses an XMP date (the subset of ISO 8601 defined by the XMP specification).
+func ParseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{
+		"2006-01-02T15:04:05.999999999Z07:00",
+		"2006-01-02T15:04:05Z07:00",
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Marshal serializes the packet, including the xpacket wrapper. All the properties are written
+// in a single rdf:Description element.
+func (p *Packet) Marshal() ([]byte, error) {
+	e := newEncoder(p.prefixes)
+	e.declare(NsX)
+	e.declare(NsRDF)
+	for _, prop := range p.props {
+		if !isNCName(prop.Name) || prop.Namespace == "" {
+			return nil, fmt.Errorf("xmp: invalid property name %q (namespace %q)", prop.Name, prop.Namespace)
+		}
+		e.declare(prop.Namespace)
+		if prop.node != nil {
+			prop.node.walk(func(n *node) {
+				e.declare(n.name.Space)
+				for _, attr := range n.attr {
+					if attr.Name.Space != "" && attr.Name.Space != "xmlns" {
+						e.declare(attr.Name.Space)
+					}
+				}
+			})
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	b.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	fmt.Fprintf(&b, " <rdf:RDF xmlns:rdf=\"%s\">\n", NsRDF)
+	b.WriteString("  <rdf:Description rdf:about=\"\"")
+	for _, ns := range e.order {
+		if ns == NsX || ns == NsRDF || ns == NsXML {
+			continue
+		}
+		fmt.Fprintf(&b, "\n    xmlns:%s=\"%s\"", e.prefixes[ns], escape(ns))
+	}
+	b.WriteString(">\n")
+
+	for _, prop := range p.props {
+		name := e.qname(prop.Namespace, prop.Name)
+		switch prop.Kind {
+		case KindText:
+			fmt.Fprintf(&b, "   <%s>%s</%s>\n", name, escape(prop.Value), name)
+		case KindLangAlt, KindSeq, KindBag:
+			container := map[Kind]string{KindLangAlt: "rdf:Alt", KindSeq: "rdf:Seq", KindBag: "rdf:Bag"}[prop.Kind]
+			fmt.Fprintf(&b, "   <%s>\n    <%s>\n", name, container)
+			for _, item := range prop.Items {
+				if prop.Kind == KindLangAlt {
+					lang := item.Lang
+					if lang == "" {
+						lang = "x-default"
+					}
+					fmt.Fprintf(&b, "     <rdf:li xml:lang=\"%s\">%s</rdf:li>\n", escape(lang), escape(item.Value))
+				} else {
+					fmt.Fprintf(&b, "     <rdf:li>%s</rdf:li>\n", escape(item.Value))
+				}
+			}
+			fmt.Fprintf(&b, "    </%s>\n   </%s>\n", container, name)
+		case KindStructured:
+			b.WriteString("   ")
+			e.writeNode(&b, prop.node)
+			b.WriteString("\n")
+		}
+	}
+
+	b.WriteString("  </rdf:Description>\n")
+	b.WriteString(" </rdf:RDF>\n")
+	b.WriteString("</x:xmpmeta>\n")
+	b.WriteString("<?xpacket end=\"w\"?>")
+	return b.Bytes(), nil
+}
+
+// encoder assigns unique prefixes to the namespaces of a serialized packet.
+type encoder struct {
+	preferred map[string]string
+	prefixes  map[string]string // Namespace URI to prefix.
+	used      map[string]bool
+	order     []string
+}
+
+func newEncoder(preferred map[string]string) *encoder {
+	return &encoder{
+		preferred: preferred,
+		prefixes:  map[string]string{NsXML: "xml"},
+		used:      map[string]bool{"xml": true, "xmlns": true},
+	}
+}
+
+// declare assigns a prefix to namespace `ns` if not done yet.
+func (e *encoder) declare(ns string) {
+	if _, ok := e.prefixes[ns]; ok || ns == "" {
+		return
+	}
+	prefix := ""
+	for _, candidate := range []string{e.preferred[ns], defaultPrefixes[ns]} {
+		if candidate != "" && isNCName(candidate) && !e.used[candidate] {
+			prefix = candidate
+			break
+		}
+	}
+	for i := 1; prefix == ""; i++ {
+		if candidate := fmt.Sprintf("ns%d", i); !e.used[candidate] {
+			prefix = candidate
+		}
+	}
+	e.prefixes[ns] = prefix
+	e.used[prefix] = true
+	e.order = append(e.order, ns)
+}
+
+// qname returns the qualified name of `local` in namespace `ns`.
+func (e *encoder) qname(ns, local string) string {
+	if ns == "" {
+		return local
+	}
+	return e.prefixes[ns] + ":" + local
+}
+
+// writeNode writes element `n` and its descendants.
+func (e *encoder) writeNode(b *bytes.Buffer, n *node) {
+	name := e.qname(n.name.Space, n.name.Local)
+	b.WriteString("<" + name)
+	for _, attr := range n.attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			// Namespaces are declared on the rdf:Description element.
+			continue
+		}
+		fmt.Fprintf(b, " %s=\"%s\"", e.qname(attr.Name.Space, attr.Name.Local), escape(attr.Value))
+	}
+	if len(n.children) == 0 && n.text == "" {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteString(">")
+	if len(n.children) == 0 {
+		b.WriteString(escape(n.text))
+	}
+	for _, child := range n.children {
+		e.writeNode(b, child)
+	}
+	b.WriteString("</" + name + ">")
+}
+
+// escape escapes `s` for use in XML character data and attribute values.
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// isSyntaxAttr returns true if `name` is an RDF syntax or namespace declaration attribute of an
+// rdf:Description element, rather than a property.
+func isSyntaxAttr(name xml.Name) bool {
+	return name.Space == NsRDF || name.Space == NsXML || name.Space == "xmlns" || name.Space == ""
+}
+
+// isNCName returns true if `s` is a valid XML name without colons.
+func isNCName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// node is a parsed XML element.
+type node struct {
+	name     xml.Name
+	attr     []xml.Attr
+	children []*node
+	// text is the character data of elements without children.
+	text string
+}
+
+// parseNodes parses `data` into a tree of elements. The returned node is a synthetic root
+// containing the top-level elements.
+func parseNodes(data []byte) (*node, error) {
+	root := &node{}
+	stack := []*node{root}
+	var text strings.Builder
+
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attr: append([]xml.Attr(nil), t.Attr...)}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			if len(n.children) == 0 {
+				n.text = text.String()
+			}
+			stack = stack[:len(stack)-1]
+			text.Reset()
+		}
+	}
+	if len(stack) != 1 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return root, nil
+}
+
+// is returns true if `n` is element `local` of namespace `ns`.
+func (n *node) is(ns, local string) bool {
+	return n.name.Space == ns && n.name.Local == local
+}
+
+// find returns the first element `local` of namespace `ns` in `n` and its descendants, in
+// document order.
+func (n *node) find(ns, local string) *node {
+	if n.is(ns, local) {
+		return n
+	}
+	for _, child := range n.children {
+		if found := child.find(ns, local); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// walk calls `f` for `n` and its descendants.
+func (n *node) walk(f func(n *node)) {
+	f(n)
+	for _, child := range n.children {
+		child.walk(f)
+	}
+}
+
+// collectPrefixes collects the namespace prefixes declared in `n` and its descendants.
+func (n *node) collectPrefixes(prefixes map[string]string) {
+	n.walk(func(n *node) {
+		for _, attr := range n.attr {
+			if attr.Name.Space == "xmlns" {
+				if _, ok := prefixes[attr.Value]; !ok {
+					prefixes[attr.Value] = attr.Name.Local
+				}
+			}
+		}
+	})
+}
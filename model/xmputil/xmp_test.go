@@ -0,0 +1,102 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package xmputil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPacket = `<?xpacket begin="` + "\ufeff" + `" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:pdfaid="http://www.aiim.org/pdfa/ns/id/" pdfaid:part="2">
+<pdfaid:conformance>B</pdfaid:conformance>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title><rdf:Alt><rdf:li xml:lang="de">Titel</rdf:li><rdf:li xml:lang="x-default">Title &amp; more</rdf:li></rdf:Alt></dc:title>
+<dc:creator><rdf:Seq><rdf:li>First</rdf:li><rdf:li>Second</rdf:li></rdf:Seq></dc:creator>
+</rdf:Description>
+<rdf:Description rdf:about="" xmlns:xmp="http://ns.adobe.com/xap/1.0/" xmlns:my="http://example.com/ns/my/">
+<xmp:CreateDate>2020-05-06T07:08:09+02:00</xmp:CreateDate>
+<my:info rdf:parseType="Resource"><my:name>test</my:name></my:info>
+</rdf:Description>
+</rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(testPacket))
+	require.NoError(t, err)
+
+	require.Equal(t, "2", p.Text(NsPdfAID, "part"))
+	require.Equal(t, "B", p.Text(NsPdfAID, "conformance"))
+	require.Equal(t, "Title & more", p.Text(NsDC, "title"))
+	require.Equal(t, KindLangAlt, p.Property(NsDC, "title").Kind)
+	require.Equal(t, "First", p.Text(NsDC, "creator"))
+	require.Equal(t, []string{"First", "Second"}, p.Values(NsDC, "creator"))
+	require.False(t, p.Has(NsPDF, "Producer"))
+
+	date, ok := p.Date(NsXMP, "CreateDate")
+	require.True(t, ok)
+	require.True(t, date.Equal(time.Date(2020, 5, 6, 5, 8, 9, 0, time.UTC)))
+
+	custom := p.Property("http://example.com/ns/my/", "info")
+	require.NotNil(t, custom)
+	require.Equal(t, KindStructured, custom.Kind)
+
+	_, err = Parse([]byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\"/>"))
+	require.Equal(t, ErrNoRDF, err)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	p, err := Parse([]byte(testPacket))
+	require.NoError(t, err)
+
+	p.SetLangAlt(NsDC, "title", "New <title>")
+	require.NoError(t, p.SetArray(NsDC, "subject", KindBag, []string{"a", "b"}))
+	p.SetText(NsPDF, "Producer", "unipdf")
+	p.SetText("http://example.com/ns/other/", "key", "value")
+	require.True(t, p.Remove(NsPdfAID, "conformance"))
+	require.False(t, p.Remove(NsPdfAID, "conformance"))
+
+	data, err := p.Marshal()
+	require.NoError(t, err)
+	require.Contains(t, string(data), `xmlns:my="http://example.com/ns/my/"`)
+	require.Contains(t, string(data), `xmlns:ns1="http://example.com/ns/other/"`)
+
+	q, err := Parse(data)
+	require.NoError(t, err)
+	require.Equal(t, "New <title>", q.Text(NsDC, "title"))
+	require.Equal(t, []Item{{Lang: "de", Value: "Titel"}, {Lang: "x-default", Value: "New <title>"}},
+		q.Property(NsDC, "title").Items)
+	require.Equal(t, []string{"a", "b"}, q.Values(NsDC, "subject"))
+	require.Equal(t, KindBag, q.Property(NsDC, "subject").Kind)
+	require.Equal(t, "unipdf", q.Text(NsPDF, "Producer"))
+	require.Equal(t, "value", q.Text("http://example.com/ns/other/", "key"))
+	require.Equal(t, "2", q.Text(NsPdfAID, "part"))
+	require.False(t, q.Has(NsPdfAID, "conformance"))
+
+	custom := q.Property("http://example.com/ns/my/", "info")
+	require.NotNil(t, custom)
+	require.Equal(t, KindStructured, custom.Kind)
+	require.Len(t, custom.node.children, 1)
+	require.Equal(t, "test", custom.node.children[0].text)
+
+	// Serialization is stable.
+	again, err := q.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, string(data), string(again))
+}
+
+func TestMarshalInvalidName(t *testing.T) {
+	p := New()
+	p.SetText(NsPDF, "1st", "value")
+	_, err := p.Marshal()
+	require.Error(t, err)
+}
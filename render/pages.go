@@ -0,0 +1,45 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"image"
+
+	"github.com/carmel/unipdf/model"
+)
+
+// RenderPages renders all the pages of `reader`, processing up to `workers` pages concurrently
+// (runtime.NumCPU() if `workers` <= 0). The returned slice holds the image of page i+1 at index i.
+// The objects of the pages are loaded one at a time, only their rendering runs in parallel (see
+// model.PdfReader.ForEachPage).
+func (d *ImageDevice) RenderPages(reader *model.PdfReader, workers int) ([]image.Image, error) {
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	images := make([]image.Image, numPages)
+	err = reader.ForEachPage(workers, func(pageNum int, page *model.PdfPage) error {
+		img, err := d.Render(page)
+		if err != nil {
+			return err
+		}
+		images[pageNum-1] = img
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// RenderPagesToPath renders all the pages of `reader` like RenderPages, saving the image of each
+// page at the location returned by `outputPath` for its page number (starting at 1). The images
+// are not kept in memory.
+func (d *ImageDevice) RenderPagesToPath(reader *model.PdfReader, workers int, outputPath func(pageNum int) string) error {
+	return reader.ForEachPage(workers, func(pageNum int, page *model.PdfPage) error {
+		return d.RenderToPath(page, outputPath(pageNum))
+	})
+}
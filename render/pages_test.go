@@ -0,0 +1,65 @@
+/*
+ * This file is subject to the terms and conditions defined in
+ * file 'LICENSE.md', which is part of this source code package.
+ */
+
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/carmel/unipdf/creator"
+	"github.com/carmel/unipdf/model"
+)
+
+// writeSharedResourcesFile writes a document of `numPages` pages sharing an embedded font and an
+// image.
+func writeSharedResourcesFile(t *testing.T, numPages int) []byte {
+	font, err := model.NewCompositePdfFontFromTTFFile("../creator/testdata/FreeSans.ttf")
+	require.NoError(t, err)
+
+	c := creator.New()
+	c.SetPageSize(creator.PageSize{200, 150})
+	c.SetPageMargins(10, 10, 10, 10)
+	img, err := c.NewImageFromFile("../creator/testdata/logo.png")
+	require.NoError(t, err)
+	img.ScaleToWidth(60)
+	for i := 1; i <= numPages; i++ {
+		c.NewPage()
+		p := c.NewParagraph(fmt.Sprintf("Page %d of the document", i))
+		p.SetFont(font)
+		require.NoError(t, c.Draw(p))
+		require.NoError(t, c.Draw(img))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Write(&buf))
+	return buf.Bytes()
+}
+
+// Run with -race to check the concurrent loading of the shared objects.
+func TestRenderPages(t *testing.T) {
+	data := writeSharedResourcesFile(t, 8)
+	reader, err := model.NewPdfReaderLazy(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	device := NewImageDevice()
+	images, err := device.RenderPages(reader, 4)
+	require.NoError(t, err)
+	require.Len(t, images, 8)
+
+	// The pages are rendered as when rendered one by one.
+	reader, err = model.NewPdfReaderLazy(bytes.NewReader(data))
+	require.NoError(t, err)
+	for i, img := range images {
+		page, err := reader.GetPage(i + 1)
+		require.NoError(t, err)
+		expected, err := device.Render(page)
+		require.NoError(t, err)
+		require.Equal(t, expected, img, "page %d", i+1)
+	}
+}